@@ -0,0 +1,70 @@
+package conf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// onionBindSuffix marks a bind address as the target of the Tor onion
+// service. Peers reach it through Tor only, so it is not advertised.
+const onionBindSuffix = "=onion"
+
+// ResolveBinds returns the addresses to listen on: the binds if any are
+// given, otherwise the listen addresses. A bind without a port gets port, or
+// defaultPort if port is empty, and a non-empty port replaces the one of the
+// listen addresses. The binds marked with =onion are returned apart in
+// onionAddrs.
+func ResolveBinds(binds, listenAddrs []string, port, defaultPort string) (addrs, onionAddrs []string, err error) {
+	if port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, nil, fmt.Errorf("invalid port %s", port)
+		}
+	}
+	if len(binds) == 0 {
+		addrs = make([]string, 0, len(listenAddrs))
+		for _, addr := range listenAddrs {
+			if port != "" {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid listen address %s: %v", addr, err)
+				}
+				addr = net.JoinHostPort(host, port)
+			}
+			addrs = append(addrs, addr)
+		}
+		return addrs, nil, nil
+	}
+
+	if port == "" {
+		port = defaultPort
+	}
+	addrs = make([]string, 0, len(binds))
+	for _, bind := range binds {
+		addr := strings.TrimSuffix(bind, onionBindSuffix)
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+		}
+		if !validListenAddr(addr) {
+			return nil, nil, fmt.Errorf("invalid bind address %s", bind)
+		}
+
+		if strings.HasSuffix(bind, onionBindSuffix) {
+			onionAddrs = append(onionAddrs, addr)
+		} else {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, onionAddrs, nil
+}
+
+// validListenAddr reports whether addr is an IP, or an empty host, and a port.
+func validListenAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || (host != "" && net.ParseIP(host) == nil) {
+		return false
+	}
+	_, err = strconv.ParseUint(port, 10, 16)
+	return err == nil
+}
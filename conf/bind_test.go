@@ -0,0 +1,46 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveBinds(t *testing.T) {
+	tests := []struct {
+		binds       []string
+		listenAddrs []string
+		port        string
+		addrs       []string
+		onionAddrs  []string
+	}{
+		{nil, []string{"127.0.0.1:18333"}, "", []string{"127.0.0.1:18333"}, nil},
+		{nil, []string{"127.0.0.1:18333", ":18333"}, "9000", []string{"127.0.0.1:9000", ":9000"}, nil},
+		{[]string{"10.0.0.1"}, []string{"127.0.0.1:18333"}, "", []string{"10.0.0.1:8333"}, nil},
+		{[]string{"10.0.0.1", "10.0.0.2:9001"}, nil, "9000", []string{"10.0.0.1:9000", "10.0.0.2:9001"}, nil},
+		{[]string{"::1", "[::1]:9001"}, nil, "", []string{"[::1]:8333", "[::1]:9001"}, nil},
+		{[]string{"127.0.0.1:8334=onion", "10.0.0.1"}, nil, "", []string{"10.0.0.1:8333"}, []string{"127.0.0.1:8334"}},
+	}
+
+	for i, test := range tests {
+		addrs, onionAddrs, err := ResolveBinds(test.binds, test.listenAddrs, test.port, "8333")
+		if err != nil {
+			t.Errorf("#%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(addrs, test.addrs) {
+			t.Errorf("#%d: got addresses %v, want %v", i, addrs, test.addrs)
+		}
+		if !reflect.DeepEqual(onionAddrs, test.onionAddrs) {
+			t.Errorf("#%d: got onion addresses %v, want %v", i, onionAddrs, test.onionAddrs)
+		}
+	}
+
+	if _, _, err := ResolveBinds(nil, []string{"127.0.0.1:18333"}, "port", "8333"); err == nil {
+		t.Errorf("an invalid port should be rejected")
+	}
+	for _, bind := range []string{"localhost:8333", "10.0.0.1:8333=tor", "1.2.3"} {
+		if _, _, err := ResolveBinds([]string{bind}, nil, "", "8333"); err == nil {
+			t.Errorf("bind %s should be rejected", bind)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package conf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// reservedFileDescriptors are the file descriptors kept for the block files,
+// the databases and the RPC clients when checking MaxPeers.
+const reservedFileDescriptors = 150
+
+// OptionsError lists every problem found in the options of a configuration.
+type OptionsError []string
+
+func (e OptionsError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+// CheckOptions checks c for options that exclude each other, values out of
+// range and malformed addresses. It returns an OptionsError listing all the
+// problems found, nil if there are none.
+func (c *Configuration) CheckOptions() error {
+	problems := c.checkOptions()
+	if len(problems) == 0 {
+		return nil
+	}
+	return OptionsError(problems)
+}
+
+func (c *Configuration) checkOptions() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Options that exclude each other.
+	if c.P2PNet.RegTest && c.P2PNet.TestNet {
+		add("regtest and testnet can not be used together, choose one network")
+	}
+	if c.Chain.Prune && c.Chain.TxIndex {
+		add("prune is incompatible with txindex, the index needs the pruned blocks; disable one of them")
+	}
+
+	// Values out of range.
+	if c.Excessiveblocksize <= OneMegaByte {
+		add("excessiveblocksize %d must be over 1,000,000 bytes (1MB)", c.Excessiveblocksize)
+	} else if c.Mining.BlockMaxSize > c.Excessiveblocksize {
+		add("blockmaxsize %d can not exceed excessiveblocksize %d",
+			c.Mining.BlockMaxSize, c.Excessiveblocksize)
+	}
+	if c.Mempool.MaxPoolSize < 0 {
+		add("maxmempool %d must not be negative", c.Mempool.MaxPoolSize)
+	}
+	if c.Mempool.MaxOrphanTx < 0 {
+		add("maxorphantx %d must not be negative", c.Mempool.MaxOrphanTx)
+	}
+	if c.Mempool.MaxTxFee < 0 {
+		add("maxtxfee %d must not be negative, use 0 for no limit", c.Mempool.MaxTxFee)
+	}
+	if c.Mempool.MinRelayTxFee < 0 {
+		add("minrelaytxfee %d must not be negative", c.Mempool.MinRelayTxFee)
+	}
+	if c.Mempool.IncrementalRelayFee < 0 {
+		add("incrementalrelayfee %d must not be negative", c.Mempool.IncrementalRelayFee)
+	}
+	if c.Mempool.MinFeeRate < 0 {
+		add("Mempool.MinFeeRate %d must not be negative", c.Mempool.MinFeeRate)
+	}
+	if c.Chain.CheckBlocks < 0 {
+		add("checkblocks %d must not be negative, use 0 for all", c.Chain.CheckBlocks)
+	}
+	if c.Chain.CheckLevel < 0 || c.Chain.CheckLevel > 4 {
+		add("checklevel %d must be between 0 and 4", c.Chain.CheckLevel)
+	}
+	if c.Chain.MaxTipAge < 0 {
+		add("maxtipage %d must not be negative", c.Chain.MaxTipAge)
+	}
+	if c.P2PNet.BanDuration < 0 {
+		add("P2PNet.BanDuration %d must not be negative", c.P2PNet.BanDuration)
+	}
+	if c.P2PNet.PeerTimeout < 0 {
+		add("peertimeout %d must not be negative", c.P2PNet.PeerTimeout)
+	}
+	if c.P2PNet.MaxPeers < 0 {
+		add("P2PNet.MaxPeers %d must not be negative", c.P2PNet.MaxPeers)
+	} else if limit, ok := fileDescriptorLimit(); ok &&
+		uint64(c.P2PNet.MaxPeers)+reservedFileDescriptors > limit {
+		add("P2PNet.MaxPeers %d is over the %d file descriptors available for peers, "+
+			"lower it or raise the open files limit", c.P2PNet.MaxPeers, limit-reservedFileDescriptors)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		add("unknown log format '%s', use text or json", c.Log.Format)
+	}
+	for _, network := range c.P2PNet.OnlyNets {
+		if network != "ipv4" && network != "ipv6" && network != "onion" &&
+			network != "cjdns" {
+			add("unknown network '%s' in onlynet, use ipv4, ipv6, onion or cjdns", network)
+		}
+	}
+
+	// Malformed addresses. The default port only completes the binds given
+	// without one, it has no bearing on whether they are valid.
+	if _, _, err := ResolveBinds(c.P2PNet.Binds, c.P2PNet.ListenAddrs, c.P2PNet.Port, "0"); err != nil {
+		add("P2P listen address: %v", err)
+	}
+	if _, _, err := ResolveBinds(c.RPC.RPCBinds, c.RPC.RPCListeners, c.RPC.RPCPort, "0"); err != nil {
+		add("RPC listen address: %v", err)
+	}
+	for _, addr := range c.P2PNet.ConnectPeersOnStart {
+		if !validHostPort(addr) {
+			add("invalid peer address '%s' in ConnectPeersOnStart, use host:port", addr)
+		}
+	}
+	if c.P2PNet.Proxy != "" && !validHostPort(c.P2PNet.Proxy) {
+		add("invalid proxy '%s', use host:port", c.P2PNet.Proxy)
+	}
+	if c.P2PNet.OnionProxy != "" && !validHostPort(c.P2PNet.OnionProxy) {
+		add("invalid onion proxy '%s', use host:port", c.P2PNet.OnionProxy)
+	}
+	if c.P2PNet.TorControl != "" && !validHostPort(c.P2PNet.TorControl) {
+		add("invalid torcontrol '%s', use host:port", c.P2PNet.TorControl)
+	}
+	for _, addr := range c.P2PNet.ExternalIPs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "0")
+		}
+		if !validHostPort(addr) {
+			add("invalid external address '%s', use host or host:port", addr)
+		}
+	}
+	if c.PProf.Port != "" {
+		if _, err := strconv.ParseUint(c.PProf.Port, 10, 16); err != nil {
+			add("invalid profile port '%s'", c.PProf.Port)
+		}
+	}
+
+	return problems
+}
+
+// validHostPort reports whether addr is a non-empty host and a port.
+func validHostPort(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	_, err = strconv.ParseUint(port, 10, 16)
+	return err == nil
+}
@@ -0,0 +1,81 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checktest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig([]string{
+		"--datadir=" + dir,
+		"--regtest",
+		"--testnet",
+		"--prune",
+		"--txindex",
+		"--maxmempool=-1",
+		"--minrelaytxfee=-0.00001",
+		"--checklevel=5",
+		"--maxtipage=-1",
+		"--excessiveblocksize=1000",
+		"--logformat=xml",
+		"--onlynet=ipx",
+		"--proxy=127.0.0.1",
+		"--whitelist=10.0.0.300",
+		"--bind=example.com:8333",
+		"--profile=pprof",
+	})
+	assert.Nil(t, config)
+	problems, ok := err.(OptionsError)
+	if !ok {
+		t.Fatalf("LoadConfig returned %v, want an OptionsError", err)
+	}
+	assert.Equal(t, OptionsError{
+		"invalid minrelaytxfee '-0.00001': invalid bitcoin amount",
+		"invalid whitelist '10.0.0.300', use an IP or a CIDR subnet",
+		"regtest and testnet can not be used together, choose one network",
+		"prune is incompatible with txindex, the index needs the pruned blocks; disable one of them",
+		"excessiveblocksize 1000 must be over 1,000,000 bytes (1MB)",
+		"maxmempool -1 must not be negative",
+		"checklevel 5 must be between 0 and 4",
+		"maxtipage -1 must not be negative",
+		"unknown log format 'xml', use text or json",
+		"unknown network 'ipx' in onlynet, use ipv4, ipv6, onion or cjdns",
+		"P2P listen address: invalid bind address example.com:8333",
+		"invalid proxy '127.0.0.1', use host:port",
+		"invalid profile port 'pprof'",
+	}, problems)
+}
+
+func TestCheckOptions(t *testing.T) {
+	cfg := &Configuration{Excessiveblocksize: 32000000}
+	cfg.Log.Format = "text"
+	cfg.P2PNet.MaxPeers = 125
+	cfg.P2PNet.ConnectPeersOnStart = []string{"127.0.0.1:8333", "[::1]:8333"}
+	cfg.P2PNet.ExternalIPs = []string{"1.2.3.4", "1.2.3.4:8333"}
+	assert.Nil(t, cfg.CheckOptions())
+
+	cfg.P2PNet.MaxPeers = -1
+	cfg.P2PNet.ConnectPeersOnStart = append(cfg.P2PNet.ConnectPeersOnStart, "127.0.0.1")
+	cfg.P2PNet.ExternalIPs = append(cfg.P2PNet.ExternalIPs, "1.2.3.4:port")
+	cfg.Mining.BlockMaxSize = 64000000
+	assert.Equal(t, OptionsError{
+		"blockmaxsize 64000000 can not exceed excessiveblocksize 32000000",
+		"P2PNet.MaxPeers -1 must not be negative",
+		"invalid peer address '127.0.0.1' in ConnectPeersOnStart, use host:port",
+		"invalid external address '1.2.3.4:port', use host or host:port",
+	}, cfg.CheckOptions())
+
+	if limit, ok := fileDescriptorLimit(); ok {
+		cfg = &Configuration{Excessiveblocksize: 32000000}
+		cfg.Log.Format = "text"
+		cfg.P2PNet.MaxPeers = int(limit)
+		assert.NotNil(t, cfg.CheckOptions())
+	}
+}
@@ -3,10 +3,13 @@ package conf
 import (
 	"errors"
 	"fmt"
+	"github.com/copernet/copernicus/util/amount"
+	"github.com/jessevdk/go-flags"
 	"github.com/spf13/viper"
 	"gopkg.in/go-playground/validator.v8"
 	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"path"
@@ -34,6 +37,13 @@ const (
 	defaultProjectDir     = "github.com/copernet/copernicus"
 
 	OneMegaByte = 1000000
+
+	// defaultMaxMempool is the default of --maxmempool.
+	defaultMaxMempool = 300000000
+
+	// DefaultGCPercent is the default of --gcpercent, low to keep the bursty
+	// allocations of block processing from growing the heap.
+	DefaultGCPercent = 10
 )
 
 // Configuration defines all configurations for application
@@ -49,6 +59,8 @@ type Configuration struct {
 	// }
 	RPC struct {
 		RPCListeners         []string // Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)
+		RPCBinds             []string // Listen for RPC connections on these addresses instead of RPCListeners
+		RPCPort              string   // RPC port, replaces the one of RPCListeners and completes RPCBinds
 		RPCUser              string   // Username for RPC connections
 		RPCPass              string   // Password for RPC connections
 		RPCLimitUser         string   //Username for limited RPC connections
@@ -64,6 +76,7 @@ type Configuration struct {
 		Level    string   //description:"Define level of log,include trace, debug, info, warn, error"
 		Module   []string // only output the specified module's log when using log.Print(...)
 		FileName string   // the name of log file
+		Format   string   `default:"text"` // format of the log lines, text or json
 	}
 	Mempool struct {
 		MinFeeRate           int64  //
@@ -74,9 +87,15 @@ type Configuration struct {
 		MaxPoolSize          int64  `default:"300000000"` // Default for MaxPoolSize, maximum megabytes of mempool memory usage
 		MaxPoolExpiry        int    `default:"336"`       // Default for -mempoolexpiry, expiration time for mempool transactions in hours
 		CheckFrequency       uint64 `default:"4294967296"`
+		MaxOrphanTx          int    `default:"100"`      // Default for -maxorphantx, maximum number of orphan transactions kept
+		MaxTxFee             int64  `default:"10000000"` // Default for -maxtxfee, highest absolute fee in satoshis of an accepted transaction, 0 for no limit
+		MinRelayTxFee        int64  `default:"1000"`     // Default for -minrelaytxfee, lowest fee rate in satoshis per kB of an accepted transaction
+		IncrementalRelayFee  int64  `default:"1000"`     // Default for -incrementalrelayfee, fee rate in satoshis per kB the mempool min fee is raised by over an evicted package
 	}
 	P2PNet struct {
 		ListenAddrs         []string `validate:"require" default:"1234"`
+		Binds               []string // Listen on these addresses instead of ListenAddrs, addr[:port][=onion]
+		Port                string   // Listen port, replaces the one of ListenAddrs and completes Binds
 		MaxPeers            int      `default:"128"`
 		TargetOutbound      int      `default:"64"`
 		ConnectPeersOnStart []string
@@ -86,18 +105,28 @@ type Configuration struct {
 		RegTest             bool `default:"false"`
 		SimNet              bool
 		DisableListen       bool     `default:"true"`
-		BlocksOnly          bool     `default:"false"` //Do not accept transactions from remote peers.
+		BlocksOnly          bool     `default:"false"` // Do not request, accept or relay the transactions of peers
 		BanDuration         int64    `default:"86400"` // How long to ban misbehaving peers
 		Proxy               string   // Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
+		OnionProxy          string   // Connect to tor hidden services via this SOCKS5 proxy instead of Proxy
+		ProxyRandomize      bool     `default:"true"` // Randomize proxy credentials per connection for Tor stream isolation
+		TorControl          string   // Tor control port used to create an onion service for the P2P port
+		TorPassword         string   // Password for the Tor control port
 		UserAgentComments   []string // Comment to add to the user agent -- See BIP 14 for more information.
 		DisableDNSSeed      bool     //Disable DNS seeding for peers
 		DisableRPC          bool     `default:"false"`
 		DisableTLS          bool     `default:"false"`
 		Whitelists          []*net.IPNet
-		NoOnion             bool     `default:"true"`  // Disable connecting to tor hidden services
-		Upnp                bool     `default:"false"` // Use UPnP to map our listening port outside of NAT
-		ExternalIPs         []string // Add an ip to the list of local addresses we claim to listen on to peers
-		MaxTimeAdjustment   uint64   `default:"4200"`
+		WhiteBinds          []*net.TCPAddr // Bind to these addresses and whitelist peers connecting to them
+		WhitelistForceRelay bool           `default:"true"`  // Relay the transactions of whitelisted peers even if they miss the fee limits
+		NoOnion             bool           `default:"true"`  // Disable connecting to tor hidden services
+		CJDNSReachable      bool           `default:"false"` // Assume fc00::/8 addresses are reachable through a CJDNS tunnel
+		Upnp                bool           `default:"false"` // Use UPnP to map our listening port outside of NAT
+		ExternalIPs         []string       // Add an ip to the list of local addresses we claim to listen on to peers
+		MaxTimeAdjustment   uint64         `default:"4200"`
+		PeerTimeout         int64          `default:"60"` // Seconds a peer may take to complete the version handshake
+		MaxUploadTarget     uint64         `default:"0"`  // Outbound traffic target in MiB per 24h, 0 is no limit
+		OnlyNets            []string       // Only connect out to nodes in these networks (ipv4, ipv6, onion or cjdns)
 		//AddCheckpoints      []model.Checkpoint
 	}
 	AddrMgr struct {
@@ -107,6 +136,7 @@ type Configuration struct {
 	Protocol struct {
 		NoPeerBloomFilters bool `default:"true"`
 		DisableCheckpoints bool `default:"true"`
+		PeerBlockFilters   bool `default:"false"` // Serve the BIP157 compact block filters to peers
 	}
 	Script struct {
 		AcceptDataCarrier   bool `default:"true"`
@@ -123,15 +153,25 @@ type Configuration struct {
 		AssumeValid         string
 		UtxoHashStartHeight int32 `default:"-1"`
 		UtxoHashEndHeight   int32 `default:"-1"`
+		TxIndex             bool  `default:"false"` // Maintain a full transaction index, used by the getrawtransaction rpc call
+		BlockFilterIndex    bool  `default:"false"` // Maintain the BIP158 basic block filters, used by the getblockfilter rpc call
+		Prune               bool  `default:"false"` // Allow deleting old block and undo files with the pruneblockchain rpc call
+		CheckBlocks         int32 `default:"6"`     // Number of last blocks verified at startup, 0 for all
+		CheckLevel          int32 `default:"1"`     // How thorough the startup verification of the blocks is, 0-4
+		MaxTipAge           int64 `default:"86400"` // Seconds the tip may be old for the node to leave initial block download
 	}
 	Mining struct {
-		BlockMinTxFee int64  // default DefaultBlockMinTxFee
-		BlockMaxSize  uint64 // default DefaultMaxGeneratedBlockSize
+		BlockMinTxFee int64  `default:"1000"`            // Lowest fee rate in satoshis per kB for transactions included in mined blocks
+		BlockMaxSize  uint64 `default:"2000000"`         // Maximum size of mined blocks, coinbase included
 		Strategy      string `default:"ancestorfeerate"` // option:ancestorfee/ancestorfeerate
+		GenEnabled    bool   `default:"false"`           // Run the internal CPU miner, meant for regtest automation
+		GenProcLimit  int    `default:"1"`               // Number of mining goroutines, -1 for one per CPU
+		GenAddress    string // Address the internal miner pays to, the wallet mining address if empty
 	}
 	PProf struct {
-		IP   string `default:"localhost"`
-		Port string `default:"6060"`
+		IP        string `default:"localhost"` // Address the profile server listens on, keep it a loopback one
+		Port      string // Serve the net/http/pprof profiles on this port, disabled if empty
+		GCPercent int    `default:"10"` // Garbage collection target percentage, negative to disable the collector
 	}
 	BlockIndex struct {
 		CheckBlockIndex bool
@@ -151,6 +191,21 @@ var (
 
 // InitConfig init configuration
 func InitConfig(args []string) *Configuration {
+	config, err := LoadConfig(args)
+	if err != nil {
+		// The command line parser prints its own errors.
+		if _, ok := err.(*flags.Error); !ok {
+			println("Error: " + err.Error())
+		}
+		return nil
+	}
+	return config
+}
+
+// LoadConfig reads the configuration file and the command line args, and
+// checks the options before any subsystem uses them. All the problems found
+// in the options are returned at once in an OptionsError.
+func LoadConfig(args []string) (*Configuration, error) {
 	// parse command line parameter to set program datadir
 	defaultDataDir := AppDataDir(defaultDataDirname, false)
 	DataDir = defaultDataDir
@@ -158,7 +213,7 @@ func InitConfig(args []string) *Configuration {
 	opts, err := InitArgs(args)
 	if err != nil {
 		//fmt.Println("\033[0;31mparse cmd line fail: %v\033[0m\n")
-		return nil
+		return nil, err
 	}
 
 	Args = opts
@@ -168,10 +223,6 @@ func InitConfig(args []string) *Configuration {
 		os.Exit(0)
 	}
 
-	if opts.RegTest && opts.TestNet {
-		panic("Both testnet and regtest are true")
-	}
-
 	if len(opts.DataDir) > 0 {
 		DataDir = opts.DataDir
 	}
@@ -245,9 +296,10 @@ func InitConfig(args []string) *Configuration {
 	}
 
 	// parse config
-	file := must(os.Open(destConfig)).(*os.File)
-	defer file.Close()
-	must(nil, viper.ReadConfig(file))
+	settings, err := readConfigFile(destConfig)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %v", destConfig, err)
+	}
 	must(nil, viper.Unmarshal(config))
 
 	// set data dir
@@ -255,7 +307,13 @@ func InitConfig(args []string) *Configuration {
 	config.Excessiveblocksize = opts.Excessiveblocksize
 	config.Mempool.LimitAncestorCount = opts.Limitancestorcount
 	config.Script.PromiscuousMempoolFlags = opts.PromiscuousMempoolFlags
-	config.Mempool.MaxPoolSize = opts.MaxMempool
+	// The configuration file sets the mempool size unless --maxmempool does,
+	// so a reload picks up a new size from the file.
+	if opts.MaxMempool != defaultMaxMempool {
+		config.Mempool.MaxPoolSize = opts.MaxMempool
+	}
+	config.Mempool.MaxOrphanTx = opts.MaxOrphanTx
+	config.Mempool.MaxTxFee = opts.MaxTxFee
 
 	config.RPC.RPCKey = filepath.Join(defaultDataDir, "rpc.key")
 	config.RPC.RPCCert = filepath.Join(defaultDataDir, "rpc.cert")
@@ -278,16 +336,78 @@ func InitConfig(args []string) *Configuration {
 		config.Chain.UtxoHashStartHeight = opts.UtxoHashStartHeigh
 		config.Chain.UtxoHashEndHeight = opts.UtxoHashEndHeigh
 	}
-	if opts.Excessiveblocksize <= 1000000 {
-		println("Error: Excessive block size must be > 1,000,000 bytes (1MB)")
-		return nil
+	if opts.BlockMinTxFee >= 0 {
+		config.Mining.BlockMinTxFee = opts.BlockMinTxFee
 	}
-	if opts.Excessiveblocksize < config.Mining.BlockMaxSize {
-		println("Error: Max generated block size (blockmaxsize) cannot exceed the excessive block size (excessiveblocksize)")
-		return nil
+	if opts.BlockMaxSize > 0 {
+		config.Mining.BlockMaxSize = opts.BlockMaxSize
+	}
+	if opts.Gen {
+		config.Mining.GenEnabled = true
+	}
+	if opts.GenProcLimit != 0 {
+		config.Mining.GenProcLimit = opts.GenProcLimit
+	}
+	if opts.GenAddress != "" {
+		config.Mining.GenAddress = opts.GenAddress
+	}
+	var problems []string
+	if opts.MinRelayTxFee != "" {
+		problems = append(problems, initFeeRate(&config.Mempool.MinRelayTxFee, "minrelaytxfee", opts.MinRelayTxFee)...)
+	}
+	if opts.IncrementalRelayFee != "" {
+		problems = append(problems, initFeeRate(&config.Mempool.IncrementalRelayFee, "incrementalrelayfee", opts.IncrementalRelayFee)...)
 	}
 	if len(opts.Whitelists) > 0 {
-		initWhitelists(config, opts)
+		problems = append(problems, initWhitelists(config, opts)...)
+	}
+	if len(opts.WhiteBinds) > 0 {
+		problems = append(problems, initWhiteBinds(config, opts)...)
+	}
+	if len(opts.Binds) > 0 {
+		config.P2PNet.Binds = opts.Binds
+	}
+	if opts.Port != "" {
+		config.P2PNet.Port = opts.Port
+	}
+	if len(opts.RPCBinds) > 0 {
+		config.RPC.RPCBinds = opts.RPCBinds
+	}
+	if opts.RPCPort != "" {
+		config.RPC.RPCPort = opts.RPCPort
+	}
+	if opts.WhitelistForceRelay == 0 {
+		config.P2PNet.WhitelistForceRelay = false
+	}
+	if opts.BlocksOnly {
+		config.P2PNet.BlocksOnly = true
+	}
+	if opts.Proxy != "" {
+		config.P2PNet.Proxy = opts.Proxy
+	}
+	if opts.OnionProxy != "" {
+		config.P2PNet.OnionProxy = opts.OnionProxy
+	}
+	if opts.ProxyRandomize == 0 {
+		config.P2PNet.ProxyRandomize = false
+	}
+	if opts.TorControl != "" {
+		config.P2PNet.TorControl = opts.TorControl
+	}
+	if opts.TorPassword != "" {
+		config.P2PNet.TorPassword = opts.TorPassword
+	}
+	if config.P2PNet.Proxy != "" || config.P2PNet.OnionProxy != "" {
+		config.P2PNet.NoOnion = false
+	}
+	if opts.LogFormat != "" {
+		config.Log.Format = opts.LogFormat
+	}
+	if opts.CJDNSReachable {
+		config.P2PNet.CJDNSReachable = true
+	}
+	if len(opts.OnlyNets) > 0 {
+		config.P2PNet.OnlyNets = opts.OnlyNets
 	}
 	if opts.SpendZeroConfChange == 0 {
 		config.Wallet.SpendZeroConfChange = false
@@ -298,23 +418,71 @@ func InitConfig(args []string) *Configuration {
 	if opts.MaxTimeAdjustment > 0 {
 		config.P2PNet.MaxTimeAdjustment = opts.MaxTimeAdjustment
 	}
+	if opts.PeerTimeout > 0 {
+		config.P2PNet.PeerTimeout = opts.PeerTimeout
+	}
+	if opts.MaxUploadTarget > 0 {
+		config.P2PNet.MaxUploadTarget = opts.MaxUploadTarget
+	}
 	if len(opts.AssumeValid) > 0 {
 		config.Chain.AssumeValid = opts.AssumeValid
 	}
+	if opts.TxIndex {
+		config.Chain.TxIndex = true
+	}
+	if opts.BlockFilterIndex {
+		config.Chain.BlockFilterIndex = true
+	}
+	if opts.Prune {
+		config.Chain.Prune = true
+	}
+	if opts.Profile != "" {
+		config.PProf.Port = opts.Profile
+	}
+	if opts.GCPercent != nil {
+		config.PProf.GCPercent = *opts.GCPercent
+	}
+	if opts.CheckBlocks != nil {
+		config.Chain.CheckBlocks = *opts.CheckBlocks
+	}
+	if opts.CheckLevel != nil {
+		config.Chain.CheckLevel = *opts.CheckLevel
+	}
+	if opts.MaxTipAge != nil {
+		config.Chain.MaxTipAge = *opts.MaxTipAge
+	} else if config.P2PNet.RegTest && !hasSetting(settings, "Chain", "MaxTipAge") {
+		// regtest chains are mined on demand, their tip may be of any age,
+		// whether regtest is set by --regtest or the configuration file,
+		// unless the configuration file sets maxtipage
+		config.Chain.MaxTipAge = math.MaxInt64
+	}
+	if opts.PeerBlockFilters {
+		config.Protocol.PeerBlockFilters = true
+	}
 
-	return config
+	problems = append(problems, config.checkOptions()...)
+	if len(problems) > 0 {
+		return nil, OptionsError(problems)
+	}
+	return config, nil
 }
 
-func initWhitelists(config *Configuration, opts *Opts) {
+// initWhitelists sets the whitelists of config from opts, and returns the
+// problems with those that are invalid.
+func initWhitelists(config *Configuration, opts *Opts) []string {
+	var problems []string
 	var ip net.IP
 	config.P2PNet.Whitelists = make([]*net.IPNet, 0, len(opts.Whitelists))
 	for _, addr := range opts.Whitelists {
+		if addr == "" {
+			continue
+		}
 		_, ipnet, err := net.ParseCIDR(addr)
 
 		if err != nil {
 			ip = net.ParseIP(addr)
 			if ip == nil {
-				fmt.Fprintln(os.Stderr, fmt.Sprintf("[Error]The whitelist value of '%s' is invalid", addr))
+				problems = append(problems, fmt.Sprintf("invalid whitelist '%s', use an IP or a CIDR subnet", addr))
 				continue
 			}
 
@@ -333,6 +501,34 @@ func initWhitelists(config *Configuration, opts *Opts) {
 		}
 		config.P2PNet.Whitelists = append(config.P2PNet.Whitelists, ipnet)
 	}
+	return problems
+}
+
+// initWhiteBinds sets the whitebinds of config from opts, and returns the
+// problems with those that are invalid.
+func initWhiteBinds(config *Configuration, opts *Opts) []string {
+	var problems []string
+	config.P2PNet.WhiteBinds = make([]*net.TCPAddr, 0, len(opts.WhiteBinds))
+	for _, addr := range opts.WhiteBinds {
+		bind, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid whitebind '%s': %v", addr, err))
+			continue
+		}
+		config.P2PNet.WhiteBinds = append(config.P2PNet.WhiteBinds, bind)
+	}
+	return problems
+}
+
+// initFeeRate sets the fee rate in satoshis per kB at rate from the value in
+// BCH/kB of the option name, and returns the problem with an invalid value.
+func initFeeRate(rate *int64, name string, value string) []string {
+	perK, err := amount.ParseAmount(value)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid %s '%s': %v", name, value, err)}
+	}
+	*rate = int64(perK)
+	return nil
 }
 
 func must(i interface{}, err error) interface{} {
@@ -5,6 +5,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"net"
 	"os"
@@ -194,6 +195,8 @@ func getDefaultConfiguration(args defaultArgs) *Configuration {
 		Excessiveblocksize: defaultExcessiveblocksize,
 		RPC: struct {
 			RPCListeners         []string
+			RPCBinds             []string
+			RPCPort              string
 			RPCUser              string
 			RPCPass              string
 			RPCLimitUser         string
@@ -208,6 +211,14 @@ func getDefaultConfiguration(args defaultArgs) *Configuration {
 			RPCCert: filepath.Join(defaultDataDir, "rpc.cert"),
 			RPCKey:  filepath.Join(defaultDataDir, "rpc.key"),
 		},
+		Log: struct {
+			Level    string
+			Module   []string
+			FileName string
+			Format   string `default:"text"`
+		}{
+			Format: "text",
+		},
 		Mempool: struct {
 			MinFeeRate           int64  //
 			LimitAncestorCount   int    // Default for -limitancestorcount, max number of in-mempool ancestors
@@ -217,14 +228,24 @@ func getDefaultConfiguration(args defaultArgs) *Configuration {
 			MaxPoolSize          int64  `default:"300000000"` // Default for MaxPoolSize, maximum megabytes of mempool memory usage
 			MaxPoolExpiry        int    `default:"336"`       // Default for -mempoolexpiry, expiration time for mempool transactions in hours
 			CheckFrequency       uint64 `default:"4294967296"`
+			MaxOrphanTx          int    `default:"100"`      // Default for -maxorphantx, maximum number of orphan transactions kept
+			MaxTxFee             int64  `default:"10000000"` // Default for -maxtxfee, highest absolute fee in satoshis of an accepted transaction, 0 for no limit
+			MinRelayTxFee        int64  `default:"1000"`     // Default for -minrelaytxfee, lowest fee rate in satoshis per kB of an accepted transaction
+			IncrementalRelayFee  int64  `default:"1000"`     // Default for -incrementalrelayfee, fee rate in satoshis per kB the mempool min fee is raised by over an evicted package
 		}{
-			MaxPoolSize:        300000000,
-			CheckFrequency:     4294967296,
-			LimitAncestorCount: 50000,
-			MaxPoolExpiry:      336,
+			MaxPoolSize:         300000000,
+			CheckFrequency:      4294967296,
+			LimitAncestorCount:  50000,
+			MaxPoolExpiry:       336,
+			MaxOrphanTx:         100,
+			MaxTxFee:            10000000,
+			MinRelayTxFee:       1000,
+			IncrementalRelayFee: 1000,
 		},
 		P2PNet: struct {
 			ListenAddrs         []string `validate:"require" default:"1234"`
+			Binds               []string // Listen on these addresses instead of ListenAddrs, addr[:port][=onion]
+			Port                string   // Listen port, replaces the one of ListenAddrs and completes Binds
 			MaxPeers            int      `default:"128"`
 			TargetOutbound      int      `default:"64"`
 			ConnectPeersOnStart []string
@@ -234,40 +255,54 @@ func getDefaultConfiguration(args defaultArgs) *Configuration {
 			RegTest             bool `default:"false"`
 			SimNet              bool
 			DisableListen       bool     `default:"true"`
-			BlocksOnly          bool     `default:"false"` //Do not accept transactions from remote peers.
+			BlocksOnly          bool     `default:"false"` // Do not request, accept or relay the transactions of peers
 			BanDuration         int64    `default:"86400"` // How long to ban misbehaving peers
 			Proxy               string   // Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
+			OnionProxy          string   // Connect to tor hidden services via this SOCKS5 proxy instead of Proxy
+			ProxyRandomize      bool     `default:"true"` // Randomize proxy credentials per connection for Tor stream isolation
+			TorControl          string   // Tor control port used to create an onion service for the P2P port
+			TorPassword         string   // Password for the Tor control port
 			UserAgentComments   []string // Comment to add to the user agent -- See BIP 14 for more information.
 			DisableDNSSeed      bool     //Disable DNS seeding for peers
 			DisableRPC          bool     `default:"false"`
 			DisableTLS          bool     `default:"false"`
 			Whitelists          []*net.IPNet
-			NoOnion             bool     `default:"true"`  // Disable connecting to tor hidden services
-			Upnp                bool     `default:"false"` // Use UPnP to map our listening port outside of NAT
-			ExternalIPs         []string // Add an ip to the list of local addresses we claim to listen on to peers
-			MaxTimeAdjustment   uint64   `default:"4200"`
+			WhiteBinds          []*net.TCPAddr // Bind to these addresses and whitelist peers connecting to them
+			WhitelistForceRelay bool           `default:"true"`  // Relay the transactions of whitelisted peers even if they miss the fee limits
+			NoOnion             bool           `default:"true"`  // Disable connecting to tor hidden services
+			CJDNSReachable      bool           `default:"false"` // Assume fc00::/8 addresses are reachable through a CJDNS tunnel
+			Upnp                bool           `default:"false"` // Use UPnP to map our listening port outside of NAT
+			ExternalIPs         []string       // Add an ip to the list of local addresses we claim to listen on to peers
+			MaxTimeAdjustment   uint64         `default:"4200"`
+			PeerTimeout         int64          `default:"60"` // Seconds a peer may take to complete the version handshake
+			MaxUploadTarget     uint64         `default:"0"`  // Outbound traffic target in MiB per 24h, 0 is no limit
+			OnlyNets            []string       // Only connect out to nodes in these networks (ipv4, ipv6, onion or cjdns)
 			//AddCheckpoints      []model.Checkpoint
 		}{
-			ListenAddrs:       []string{"1234"},
-			MaxPeers:          128,
-			TargetOutbound:    64,
-			DisableBanning:    false,
-			BanThreshold:      100,
-			DisableListen:     true,
-			BlocksOnly:        false,
-			BanDuration:       86400,
-			DisableRPC:        false,
-			Upnp:              false,
-			DisableTLS:        false,
-			NoOnion:           true,
-			TestNet:           testNet,
-			RegTest:           regTestNet,
-			Whitelists:        whiteList,
-			MaxTimeAdjustment: 4200,
+			ListenAddrs:         []string{"1234"},
+			MaxPeers:            128,
+			TargetOutbound:      64,
+			DisableBanning:      false,
+			BanThreshold:        100,
+			DisableListen:       true,
+			BlocksOnly:          false,
+			BanDuration:         86400,
+			DisableRPC:          false,
+			Upnp:                false,
+			DisableTLS:          false,
+			NoOnion:             true,
+			ProxyRandomize:      true,
+			TestNet:             testNet,
+			RegTest:             regTestNet,
+			Whitelists:          whiteList,
+			MaxTimeAdjustment:   4200,
+			PeerTimeout:         60,
+			WhitelistForceRelay: true,
 		},
 		Protocol: struct {
 			NoPeerBloomFilters bool `default:"true"`
 			DisableCheckpoints bool `default:"true"`
+			PeerBlockFilters   bool `default:"false"`
 		}{NoPeerBloomFilters: true, DisableCheckpoints: true},
 		Script: struct {
 			AcceptDataCarrier   bool `default:"true"`
@@ -290,22 +325,38 @@ func getDefaultConfiguration(args defaultArgs) *Configuration {
 			AssumeValid         string
 			UtxoHashStartHeight int32 `default:"-1"`
 			UtxoHashEndHeight   int32 `default:"-1"`
+			TxIndex             bool  `default:"false"`
+			BlockFilterIndex    bool  `default:"false"`
+			Prune               bool  `default:"false"`
+			CheckBlocks         int32 `default:"6"`
+			CheckLevel          int32 `default:"1"`
+			MaxTipAge           int64 `default:"86400"`
 		}{
 			AssumeValid:         "",
 			UtxoHashStartHeight: args.UtxoHashStartHeight,
 			UtxoHashEndHeight:   args.UtxoHashEndHeight,
+			CheckBlocks:         6,
+			CheckLevel:          1,
+			MaxTipAge:           86400,
 		},
 		Mining: struct {
-			BlockMinTxFee int64  // default DefaultBlockMinTxFee
-			BlockMaxSize  uint64 // default DefaultMaxGeneratedBlockSize
+			BlockMinTxFee int64  `default:"1000"`            // Lowest fee rate in satoshis per kB for transactions included in mined blocks
+			BlockMaxSize  uint64 `default:"2000000"`         // Maximum size of mined blocks, coinbase included
 			Strategy      string `default:"ancestorfeerate"` // option:ancestorfee/ancestorfeerate
+			GenEnabled    bool   `default:"false"`           // Run the internal CPU miner, meant for regtest automation
+			GenProcLimit  int    `default:"1"`               // Number of mining goroutines, -1 for one per CPU
+			GenAddress    string // Address the internal miner pays to, the wallet mining address if empty
 		}{
-			Strategy: "ancestorfeerate",
+			BlockMinTxFee: 1000,
+			BlockMaxSize:  2000000,
+			Strategy:      "ancestorfeerate",
+			GenProcLimit:  1,
 		},
 		PProf: struct {
-			IP   string `default:"localhost"`
-			Port string `default:"6060"`
-		}{IP: "localhost", Port: "6060"},
+			IP        string `default:"localhost"`
+			Port      string
+			GCPercent int `default:"10"`
+		}{IP: "localhost", GCPercent: 10},
 		AddrMgr: struct {
 			SimNet       bool
 			ConnectPeers []string
@@ -439,6 +490,77 @@ func TestInitConfig2(t *testing.T) {
 	}
 }
 
+func TestLoadConfigRelayFees(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feetest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), config.Mempool.MinRelayTxFee)
+	assert.Equal(t, int64(1000), config.Mempool.IncrementalRelayFee)
+
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--minrelaytxfee=0.00002", "--incrementalrelayfee=0.0001"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2000), config.Mempool.MinRelayTxFee)
+	assert.Equal(t, int64(10000), config.Mempool.IncrementalRelayFee)
+}
+
+func TestLoadConfigMaxTipAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tipagetest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(24*60*60), config.Chain.MaxTipAge)
+
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--maxtipage=3600"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3600), config.Chain.MaxTipAge)
+
+	// regtest tips may be of any age unless maxtipage is given
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--regtest"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), config.Chain.MaxTipAge)
+
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--regtest", "--maxtipage=3600"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3600), config.Chain.MaxTipAge)
+
+	writeConfigFile(t, filepath.Join(dir, defaultConfigFilename), "P2PNet:\n  RegTest: true\n")
+	config, err = LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.True(t, config.P2PNet.RegTest)
+	assert.Equal(t, int64(math.MaxInt64), config.Chain.MaxTipAge)
+
+	// a maxtipage set in the configuration file is kept on regtest
+	writeConfigFile(t, filepath.Join(dir, defaultConfigFilename),
+		"P2PNet:\n  RegTest: true\nChain:\n  MaxTipAge: 7200\n")
+	config, err = LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7200), config.Chain.MaxTipAge)
+
+	writeConfigFile(t, filepath.Join(dir, defaultConfigFilename), "Chain:\n  MaxTipAge: 7200\n")
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--regtest"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7200), config.Chain.MaxTipAge)
+}
+
+func TestLoadConfigBlocksOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blocksonlytest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.False(t, config.P2PNet.BlocksOnly)
+
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--blocksonly"})
+	assert.Nil(t, err)
+	assert.True(t, config.P2PNet.BlocksOnly)
+}
+
 func TestSetUnitTestDataDir(t *testing.T) {
 	args := []string{"--testnet"}
 	Cfg = InitConfig(args)
@@ -0,0 +1,47 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// dataDirLockFile is the file of the data directory locked by the node using
+// it.
+const dataDirLockFile = ".lock"
+
+// ErrDataDirLocked is returned by LockDataDir when another process holds the
+// lock of the data directory.
+var ErrDataDirLocked = errors.New("another instance is using this data directory")
+
+// DataDirLock is the lock of a data directory taken by LockDataDir.
+type DataDirLock struct {
+	file *os.File
+}
+
+// LockDataDir takes the lock of the data directory dir, so a single node
+// uses its databases. The lock is an OS lock on the .lock file of dir, which
+// the OS drops when the process exits, so the file a crashed node leaves
+// behind is taken over by the next one.
+func LockDataDir(dir string) (*DataDirLock, error) {
+	file, err := openLockedFile(filepath.Join(dir, dataDirLockFile))
+	if err == errFileLocked {
+		return nil, ErrDataDirLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock data directory %s: %v", dir, err)
+	}
+
+	// The pid is only a hint for the operator, the lock is what counts.
+	if err := file.Truncate(0); err == nil {
+		file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	return &DataDirLock{file: file}, nil
+}
+
+// Release releases the lock of the data directory.
+func (l *DataDirLock) Release() error {
+	return l.file.Close()
+}
@@ -0,0 +1,23 @@
+package conf
+
+import (
+	"errors"
+	"os"
+)
+
+// errFileLocked is returned by openLockedFile when the file is locked by
+// another process.
+var errFileLocked = errors.New("file locked")
+
+// openLockedFile opens the file at path, creating it if needed, for the
+// exclusive use of this process until it is closed.
+func openLockedFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, os.ModeExclusive|0644)
+	if err != nil {
+		if _, statErr := os.Stat(path); statErr == nil {
+			return nil, errFileLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,38 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDataDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datadirlocktest")
+	if err != nil {
+		t.Fatalf("create data dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The lock file left behind by a crashed node does not hold the lock.
+	if err := ioutil.WriteFile(filepath.Join(dir, dataDirLockFile), []byte("1\n"), 0644); err != nil {
+		t.Fatalf("write stale lock file failed: %v", err)
+	}
+	first, err := LockDataDir(dir)
+	if err != nil {
+		t.Fatalf("lock data dir with a stale lock file failed: %v", err)
+	}
+
+	if _, err := LockDataDir(dir); err != ErrDataDirLocked {
+		t.Fatalf("second lock of the data dir returned %v, want %v", err, ErrDataDirLocked)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("release data dir lock failed: %v", err)
+	}
+	second, err := LockDataDir(dir)
+	if err != nil {
+		t.Fatalf("lock released data dir failed: %v", err)
+	}
+	second.Release()
+}
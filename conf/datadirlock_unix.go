@@ -0,0 +1,32 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package conf
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errFileLocked is returned by openLockedFile when the file is locked by
+// another process.
+var errFileLocked = errors.New("file locked")
+
+// openLockedFile opens the file at path, creating it if needed, and takes an
+// exclusive lock on it, held until the file is closed.
+func openLockedFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errFileLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is the error of opening a file another process has
+// opened without sharing it.
+const errorSharingViolation syscall.Errno = 32
+
+// errFileLocked is returned by openLockedFile when the file is locked by
+// another process.
+var errFileLocked = errors.New("file locked")
+
+// openLockedFile opens the file at path, creating it if needed, without
+// sharing it with other processes until it is closed.
+func openLockedFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, errFileLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package conf
+
+// fileDescriptorLimit returns false, the open files limit is not checked on
+// this platform.
+func fileDescriptorLimit() (uint64, bool) {
+	return 0, false
+}
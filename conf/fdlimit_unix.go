@@ -0,0 +1,27 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package conf
+
+import "syscall"
+
+// fileLimitWant is the open files limit net/limits.SetLimits raises the soft
+// limit to, within the hard limit.
+const fileLimitWant = 2048
+
+// fileDescriptorLimit returns the number of files the node can open once
+// net/limits.SetLimits raised the limit, and false if it is unknown.
+func fileDescriptorLimit() (uint64, bool) {
+	var rLimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit); err != nil {
+		return 0, false
+	}
+	limit := uint64(rLimit.Cur)
+	if limit <= fileLimitWant {
+		limit = fileLimitWant
+		if uint64(rLimit.Max) < limit {
+			limit = uint64(rLimit.Max)
+		}
+	}
+	return limit, true
+}
@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// includeConfKey is the setting of a configuration file listing the files it
+// includes, relative to its directory unless they are absolute paths.
+const includeConfKey = "IncludeConf"
+
+// readConfigFile reads the configuration file at path into viper, merged
+// with the files it includes. An included file overrides the settings of the
+// file including it and of the files included before it. The merged settings
+// are returned.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	settings, err := loadConfigFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	return settings, viper.ReadConfig(bytes.NewReader(data))
+}
+
+// hasSetting returns whether key of section is set in settings. Unlike
+// viper.IsSet it ignores the defaults. Keys are matched regardless of case,
+// like viper does.
+func hasSetting(settings map[string]interface{}, section, key string) bool {
+	for k, value := range settings {
+		if !strings.EqualFold(k, section) {
+			continue
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k := range values {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// loadConfigFile returns the settings of the configuration file at path and
+// of the files it includes. including are the files including it, to detect
+// an include cycle.
+func loadConfigFile(path string, including []string) (map[string]interface{}, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range including {
+		if parent == path {
+			return nil, fmt.Errorf("include cycle: %s -> %s",
+				strings.Join(including, " -> "), path)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var content map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	settings := stringKeys(content)
+
+	var includes []string
+	for key, value := range settings {
+		if !strings.EqualFold(key, includeConfKey) {
+			continue
+		}
+		delete(settings, key)
+		switch value := value.(type) {
+		case nil:
+		case string:
+			includes = append(includes, value)
+		case []interface{}:
+			for _, include := range value {
+				includes = append(includes, fmt.Sprint(include))
+			}
+		default:
+			return nil, fmt.Errorf("%s of %s must be a path or a list of paths", key, path)
+		}
+	}
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		included, err := loadConfigFile(include, append(including, path))
+		if err != nil {
+			return nil, err
+		}
+		mergeSettings(settings, included)
+	}
+	return settings, nil
+}
+
+// mergeSettings sets the settings of src in dst, merging the sections both
+// have. Keys are matched regardless of case, like viper does.
+func mergeSettings(dst, src map[string]interface{}) {
+	for key, value := range src {
+		dstKey := key
+		for k := range dst {
+			if strings.EqualFold(k, key) {
+				dstKey = k
+				break
+			}
+		}
+
+		srcSection, ok := value.(map[string]interface{})
+		dstSection, dstOk := dst[dstKey].(map[string]interface{})
+		if ok && dstOk {
+			mergeSettings(dstSection, srcSection)
+			continue
+		}
+		dst[dstKey] = value
+	}
+}
+
+// stringKeys converts the sections yaml decodes to maps keyed by string.
+func stringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	settings := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if section, ok := value.(map[interface{}]interface{}); ok {
+			value = stringKeys(section)
+		}
+		settings[fmt.Sprint(key)] = value
+	}
+	return settings
+}
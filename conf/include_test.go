@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatalf("create config dir failed: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+}
+
+func TestIncludeConf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "includetest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFile(t, filepath.Join(dir, defaultConfigFilename), `
+IncludeConf: [conf.d/mining.yml, conf.d/override.yml]
+RPC:
+  RPCUser: base
+Log:
+  Level: error
+Mining:
+  BlockMaxSize: 2000000
+  Strategy:
+`)
+	writeConfigFile(t, filepath.Join(dir, "conf.d", "mining.yml"), `
+Mining:
+  BlockMaxSize: 2500000
+  Strategy: ancestorfee
+`)
+	writeConfigFile(t, filepath.Join(dir, "conf.d", "override.yml"), `
+Log:
+  Level: info
+mining:
+  blockmaxsize: 3000000
+`)
+
+	config, err := LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3000000), config.Mining.BlockMaxSize)
+	assert.Equal(t, "ancestorfee", config.Mining.Strategy)
+	assert.Equal(t, "info", config.Log.Level)
+	assert.Equal(t, "base", config.RPC.RPCUser)
+
+	config, err = LoadConfig([]string{"--datadir=" + dir, "--blockmaxsize=4000000"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4000000), config.Mining.BlockMaxSize)
+	assert.Equal(t, "info", config.Log.Level)
+}
+
+func TestIncludeConfCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "includetest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeConfigFile(t, filepath.Join(dir, defaultConfigFilename), "IncludeConf: a.yml\n")
+	writeConfigFile(t, filepath.Join(dir, "a.yml"), "IncludeConf: ["+filepath.Join(dir, defaultConfigFilename)+"]\n")
+
+	config, err := LoadConfig([]string{"--datadir=" + dir})
+	assert.Nil(t, config)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "include cycle")
+	}
+}
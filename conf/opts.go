@@ -21,8 +21,26 @@ type Opts struct {
 	UtxoHashEndHeigh   int32 `long:"utxohashendheight" default:"-1" description:"Which height finish logging out the utxos hash at"`
 
 	Whitelists         []string `long:"whitelist" description:"whitelist"`
+	WhiteBinds         []string `long:"whitebind" description:"Bind to given address and whitelist peers connecting to it"`
+	Binds              []string `long:"bind" description:"Bind to given address[:port] and always listen on it, append =onion to mark it as the Tor onion service target"`
+	Port               string   `long:"port" description:"Listen for connections on <port> (default: 8333, testnet: 18333, regtest: 18444)"`
+	RPCBinds           []string `long:"rpcbind" description:"Bind the RPC server to given address[:port], can be given multiple times"`
+	RPCPort            string   `long:"rpcport" description:"Listen for RPC connections on <port> (default: 8334, testnet and regtest: 18334)"`
+	Proxy              string   `long:"proxy" description:"Connect through SOCKS5 proxy (eg. 127.0.0.1:9050)"`
+	OnionProxy         string   `long:"onion" description:"Use separate SOCKS5 proxy to reach peers via Tor hidden services (default: proxy)"`
+	ProxyRandomize     uint8    `long:"proxyrandomize" default:"1" description:"Randomize credentials for every proxy connection, enabling Tor stream isolation"`
+	TorControl         string   `long:"torcontrol" description:"Tor control port used to create an onion service for the P2P port (eg. 127.0.0.1:9051)"`
+	TorPassword        string   `long:"torpassword" description:"Tor control port password"`
+	CJDNSReachable     bool     `long:"cjdnsreachable" description:"Assume this host is configured to reach fc00::/8 addresses through a CJDNS tunnel"`
+	OnlyNets           []string `long:"onlynet" description:"Only connect out to nodes in the given network (ipv4, ipv6, onion or cjdns), can be given multiple times"`
+	BlockMinTxFee      int64    `long:"blockmintxfee" default:"-1" description:"Set lowest fee rate (in satoshis per kB) for transactions to be included in block creation"`
+	BlockMaxSize       uint64   `long:"blockmaxsize" description:"Set maximum block size in bytes for block creation"`
+	Gen                bool     `long:"gen" description:"Mine blocks with the internal CPU miner, meant for regtest"`
+	GenProcLimit       int      `long:"genproclimit" description:"Set the number of goroutines used by the internal miner, -1 for one per CPU (default: 1)"`
+	GenAddress         string   `long:"genaddress" description:"Pay the blocks mined by the internal miner to this address (default: the wallet mining address)"`
 	Excessiveblocksize uint64   `long:"excessiveblocksize" default:"32000000" description:"excessive block size"`
 	BanScore           uint32   `long:"banscore" default:"100" description:"Threshold for disconnecting misbehaving peers"`
+	LogFormat          string   `long:"logformat" description:"Format of the log lines, text or json (default: text)"`
 
 	ReplayProtectionActivationTime int64  `long:"replayprotectionactivationtime" default:"-1"`
 	MagneticAnomalyTime            int64  `long:"magneticanomalyactivationtime" default:"-1"`
@@ -31,10 +49,27 @@ type Opts struct {
 	Limitancestorcount             int    `long:"limitancestorcount" default:"50000"`
 	BlockVersion                   int32  `long:"blockversion" default:"-1" description:"regtest block version"`
 	MaxMempool                     int64  `long:"maxmempool" default:"300000000"`
+	MaxOrphanTx                    int    `long:"maxorphantx" default:"100" description:"Keep at most this many unconnectable transactions in memory"`
+	MaxTxFee                       int64  `long:"maxtxfee" default:"10000000" description:"Reject transactions paying an absolute fee over this many satoshis, 0 to disable"`
+	MinRelayTxFee                  string `long:"minrelaytxfee" description:"Reject transactions paying a fee rate under this many BCH/kB (default: 0.00001)"`
+	IncrementalRelayFee            string `long:"incrementalrelayfee" description:"Raise the mempool min fee this many BCH/kB over the fee rate of the transactions it evicts (default: 0.00001)"`
 	SpendZeroConfChange            uint8  `long:"spendzeroconfchange" default:"1"`
 	MaxTimeAdjustment              uint64 `long:"maxtimeadjustment" default:"4200" description:"Maximum allowed median peer time offset adjustment. Local perspective of time may be influenced by peers forward or backward by this amount."`
+	PeerTimeout                    int64  `long:"peertimeout" default:"60" description:"Disconnect peers that do not complete the version handshake within this many seconds"`
+	MaxUploadTarget                uint64 `long:"maxuploadtarget" default:"0" description:"Tries to keep outbound traffic under the given target (in MiB per 24h), 0 = no limit"`
+	WhitelistForceRelay            uint8  `long:"whitelistforcerelay" default:"1" description:"Relay transactions from whitelisted peers even if they do not meet the mempool min fee"`
+	BlocksOnly                     bool   `long:"blocksonly" description:"Do not request, accept or relay transactions of peers, except from whitelisted peers with whitelistforcerelay"`
 	MinimumChainWork               string `long:"minimumchainwork"`
 	AssumeValid                    string `long:"assumevalid"`
+	TxIndex                        bool   `long:"txindex" description:"Maintain a full transaction index, used by the getrawtransaction rpc call"`
+	BlockFilterIndex               bool   `long:"blockfilterindex" description:"Maintain the BIP158 basic block filters, used by the getblockfilter rpc call"`
+	Prune                          bool   `long:"prune" description:"Allow deleting old block and undo files with the pruneblockchain rpc call"`
+	PeerBlockFilters               bool   `long:"peerblockfilters" description:"Serve the BIP157 compact block filters to peers, requires blockfilterindex"`
+	CheckBlocks                    *int32 `long:"checkblocks" description:"How many blocks to verify at startup, 0 for all (default: 6)"`
+	CheckLevel                     *int32 `long:"checklevel" description:"How thorough the startup verification of the blocks is, 0-4 (default: 1)"`
+	MaxTipAge                      *int64 `long:"maxtipage" description:"Consider the node in initial block download while its tip is older than this many seconds (default: 86400, regtest: no limit)"`
+	Profile                        string `long:"profile" description:"Serve the net/http/pprof profiles on this port of localhost"`
+	GCPercent                      *int   `long:"gcpercent" description:"Garbage collection target percentage, negative to disable the collector (default: 10)"`
 }
 
 func InitArgs(args []string) (*Opts, error) {
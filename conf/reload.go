@@ -0,0 +1,106 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// reloadableSettings are the settings Reload applies while the node runs,
+// by their name in the configuration file. The others need a restart.
+var reloadableSettings = map[string]bool{
+	"Log.Level":                   true,
+	"Mempool.MinRelayTxFee":       true,
+	"Mempool.IncrementalRelayFee": true,
+	"Mempool.MaxPoolSize":         true,
+	"P2PNet.BanDuration":          true,
+	"P2PNet.ConnectPeersOnStart":  true,
+}
+
+// reloadLock guards the reloadable settings, which Reload writes while other
+// goroutines read them through the getters below.
+var reloadLock sync.RWMutex
+
+// Reload copies into c the reloadable settings newCfg changes, and returns
+// the names of the settings it applied and of the changed ones it ignored
+// because they need a restart. It is safe for concurrent use with the
+// getters of the reloadable settings.
+func (c *Configuration) Reload(newCfg *Configuration) (applied []string, ignored []string) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	cur := reflect.ValueOf(c).Elem()
+	next := reflect.ValueOf(newCfg).Elem()
+	t := cur.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() != reflect.Struct {
+			if !reflect.DeepEqual(cur.Field(i).Interface(), next.Field(i).Interface()) {
+				ignored = append(ignored, t.Field(i).Name)
+			}
+			continue
+		}
+
+		structType := t.Field(i).Type
+		for j := 0; j < structType.NumField(); j++ {
+			curField := cur.Field(i).Field(j)
+			nextField := next.Field(i).Field(j)
+			if reflect.DeepEqual(curField.Interface(), nextField.Interface()) {
+				continue
+			}
+
+			name := fmt.Sprintf("%s.%s", t.Field(i).Name, structType.Field(j).Name)
+			if !reloadableSettings[name] {
+				ignored = append(ignored, name)
+				continue
+			}
+			curField.Set(nextField)
+			applied = append(applied, name)
+		}
+	}
+	return applied, ignored
+}
+
+// LogLevel returns the reloadable Log.Level setting.
+func (c *Configuration) LogLevel() string {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.Log.Level
+}
+
+// MinRelayTxFee returns the reloadable Mempool.MinRelayTxFee setting.
+func (c *Configuration) MinRelayTxFee() int64 {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.Mempool.MinRelayTxFee
+}
+
+// IncrementalRelayFee returns the reloadable Mempool.IncrementalRelayFee
+// setting.
+func (c *Configuration) IncrementalRelayFee() int64 {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.Mempool.IncrementalRelayFee
+}
+
+// MaxPoolSize returns the reloadable Mempool.MaxPoolSize setting.
+func (c *Configuration) MaxPoolSize() int64 {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.Mempool.MaxPoolSize
+}
+
+// BanDuration returns the reloadable P2PNet.BanDuration setting.
+func (c *Configuration) BanDuration() int64 {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.P2PNet.BanDuration
+}
+
+// ConnectPeersOnStart returns the reloadable P2PNet.ConnectPeersOnStart
+// setting.
+func (c *Configuration) ConnectPeersOnStart() []string {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return c.P2PNet.ConnectPeersOnStart
+}
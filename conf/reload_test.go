@@ -0,0 +1,42 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReload(t *testing.T) {
+	cfg := &Configuration{}
+	cfg.Log.Level = "info"
+	cfg.Mempool.MaxPoolSize = 300000000
+	cfg.Mempool.MinRelayTxFee = 1000
+	cfg.RPC.RPCUser = "user"
+	cfg.P2PNet.ConnectPeersOnStart = []string{"127.0.0.1:8333"}
+
+	newCfg := &Configuration{}
+	*newCfg = *cfg
+	newCfg.Log.Level = "debug"
+	newCfg.Mempool.MaxPoolSize = 5000000
+	newCfg.Mempool.MinRelayTxFee = 2000
+	newCfg.Mempool.MinFeeRate = 3000
+	newCfg.RPC.RPCUser = "other"
+	newCfg.Reindex = true
+	newCfg.P2PNet.ConnectPeersOnStart = []string{"127.0.0.1:8333", "127.0.0.2:8333"}
+
+	applied, ignored := cfg.Reload(newCfg)
+	assert.Equal(t, []string{"Log.Level", "Mempool.MaxPoolSize", "Mempool.MinRelayTxFee", "P2PNet.ConnectPeersOnStart"}, applied)
+	assert.Equal(t, []string{"Reindex", "RPC.RPCUser", "Mempool.MinFeeRate"}, ignored)
+
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, int64(5000000), cfg.MaxPoolSize())
+	assert.Equal(t, int64(2000), cfg.MinRelayTxFee())
+	assert.Equal(t, int64(0), cfg.Mempool.MinFeeRate)
+	assert.Equal(t, []string{"127.0.0.1:8333", "127.0.0.2:8333"}, cfg.ConnectPeersOnStart())
+	assert.Equal(t, "user", cfg.RPC.RPCUser)
+	assert.False(t, cfg.Reindex)
+
+	applied, ignored = cfg.Reload(newCfg)
+	assert.Empty(t, applied)
+	assert.Equal(t, []string{"Reindex", "RPC.RPCUser", "Mempool.MinFeeRate"}, ignored)
+}
@@ -0,0 +1,65 @@
+package errcode
+
+// Keys of the details validation attaches to the errors it returns.
+const (
+	DetailTxID      = "txid"
+	DetailInput     = "input"
+	DetailBlockHash = "blockhash"
+)
+
+// errDetail is a detail of an error, the details of a ProjectError are a
+// list shared by the copies WithDetail makes of it.
+type errDetail struct {
+	key   string
+	value interface{}
+	next  *errDetail
+}
+
+// detailError carries a detail of an error that is not a ProjectError.
+type detailError struct {
+	err   error
+	key   string
+	value interface{}
+}
+
+func (e detailError) Error() string {
+	return e.err.Error()
+}
+
+func (e detailError) Unwrap() error {
+	return e.err
+}
+
+// WithDetail returns err with value attached under key, such as the txid of
+// the transaction or the index of the input that failed validation. The
+// message, the code and the reject code of err are unchanged.
+func WithDetail(err error, key string, value interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(ProjectError); ok {
+		e.details = &errDetail{key: key, value: value, next: e.details}
+		return e
+	}
+	return detailError{err: err, key: key, value: value}
+}
+
+// Detail returns the value attached to err, or to an error it wraps, under
+// key by WithDetail. The value attached last wins.
+func Detail(err error, key string) (interface{}, bool) {
+	for ; err != nil; err = unwrap(err) {
+		switch e := err.(type) {
+		case ProjectError:
+			for d := e.details; d != nil; d = d.next {
+				if d.key == key {
+					return d.value, true
+				}
+			}
+		case detailError:
+			if e.key == key {
+				return e.value, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package errcode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDetail(t *testing.T) {
+	scriptErr := MakeError(RejectInvalid, "mandatory-script-verify-flag-failed (%s)",
+		New(ScriptErrEvalFalse))
+	err := WithDetail(WithDetail(scriptErr, DetailTxID, "txid"), DetailInput, 1)
+
+	input, ok := Detail(err, DetailInput)
+	assert.True(t, ok)
+	assert.Equal(t, 1, input)
+	txid, ok := Detail(err, DetailTxID)
+	assert.True(t, ok)
+	assert.Equal(t, "txid", txid)
+	_, ok = Detail(err, DetailBlockHash)
+	assert.False(t, ok)
+	_, ok = Detail(scriptErr, DetailInput)
+	assert.False(t, ok)
+
+	assert.Equal(t, scriptErr.Error(), err.Error())
+	code, reason, ok := IsRejectCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, RejectInvalid, code)
+	assert.Equal(t, "mandatory-script-verify-flag-failed (Script evaluated without error "+
+		"but finished with a false/empty top stack element)", reason)
+
+	wrapped := WithDetail(Wrap(RejectInvalid, prefixError{"connect block: ", err}), DetailInput, 2)
+	input, ok = Detail(wrapped, DetailInput)
+	assert.True(t, ok)
+	assert.Equal(t, 2, input)
+	txid, ok = Detail(wrapped, DetailTxID)
+	assert.True(t, ok)
+	assert.Equal(t, "txid", txid)
+
+	plain := WithDetail(fmt.Errorf("plain"), DetailInput, 3)
+	input, ok = Detail(plain, DetailInput)
+	assert.True(t, ok)
+	assert.Equal(t, 3, input)
+	assert.Equal(t, "plain", plain.Error())
+	assert.Nil(t, WithDetail(nil, DetailInput, 0))
+}
@@ -19,12 +19,25 @@ type ProjectError struct {
 	Desc   string
 
 	ErrorCode fmt.Stringer
+	// Cause is the error wrapped by Wrap, nil for an error made by New or
+	// NewError.
+	Cause error
+
+	details *errDetail
 }
 
 func (e ProjectError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("module: %s, errcode: %v: %s: %v", e.Module, e.Code, e.Desc, e.Cause)
+	}
 	return fmt.Sprintf("module: %s, errcode: %v: %s", e.Module, e.Code, e.Desc)
 }
 
+// Unwrap returns the cause of an error made by Wrap.
+func (e ProjectError) Unwrap() error {
+	return e.Cause
+}
+
 func getCode(errCode fmt.Stringer) (int, string) {
 	code := 0
 	module := ""
@@ -51,16 +64,45 @@ func getCode(errCode fmt.Stringer) (int, string) {
 	case RejectCode:
 		code = int(t)
 		module = "tx_validation"
+	case InternalRejectCode:
+		code = int(t)
+		module = "tx_validation"
 	default:
 	}
 
 	return code, module
 }
 
+// IsErrorCode reports whether err, or any error it wraps, has errCode.
 func IsErrorCode(err error, errCode fmt.Stringer) bool {
-	e, ok := err.(ProjectError)
 	code, _ := getCode(errCode)
-	return ok && code == e.Code
+	for ; err != nil; err = unwrap(err) {
+		if e, ok := err.(ProjectError); ok && code == e.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// asProjectError returns the first error with an errcode in the chain of
+// errors err wraps, starting with err itself.
+func asProjectError(err error) (ProjectError, bool) {
+	for ; err != nil; err = unwrap(err) {
+		if e, ok := err.(ProjectError); ok && e.ErrorCode != nil {
+			return e, true
+		}
+	}
+	return ProjectError{}, false
+}
+
+// unwrap returns the error err wraps, or nil if it does not wrap one. It
+// stands in for errors.Unwrap, which needs Go 1.13.
+func unwrap(err error) error {
+	u, ok := err.(interface{ Unwrap() error })
+	if !ok {
+		return nil
+	}
+	return u.Unwrap()
 }
 
 func New(errCode fmt.Stringer) error {
@@ -78,14 +120,29 @@ func NewError(errCode fmt.Stringer, desc string) error {
 	}
 }
 
+// Wrap returns an error with errCode that keeps cause, so a failure deep in
+// validation can be classified by its code while the cause still tells which
+// input or script operation failed. Its Unwrap method returns cause.
+func Wrap(errCode fmt.Stringer, cause error) error {
+	code, module := getCode(errCode)
+
+	return ProjectError{
+		Module:    module,
+		Code:      code,
+		Desc:      errCode.String(),
+		ErrorCode: errCode,
+		Cause:     cause,
+	}
+}
+
 func MakeError(code RejectCode, format string, innerErr error) error {
 	return NewError(code, fmt.Sprintf(format, shortDesc(innerErr)))
 }
 
 // IsRejectCode BIP61 reject code; never send internal reject codes over P2P.
 func IsRejectCode(err error) (RejectCode, string, bool) {
-	e, ok := err.(ProjectError)
-	if ok && e.ErrorCode != nil {
+	e, ok := asProjectError(err)
+	if ok {
 		switch t := e.ErrorCode.(type) {
 		case RejectCode:
 			return t, e.Desc, true
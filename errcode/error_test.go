@@ -25,6 +25,8 @@ func TestIsErrorCode(t *testing.T) {
 			"module: script, errcode: " + strconv.Itoa(int(ScriptErrOK)) + ": No error"},
 		{TxErrNoPreviousOut, true,
 			"module: transaction, errcode: " + strconv.Itoa(int(TxErrNoPreviousOut)) + ": Missing inputs"},
+		{RejectHighFee, true,
+			"module: tx_validation, errcode: " + strconv.Itoa(int(RejectHighFee)) + ": RejectHighFee"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -48,4 +50,40 @@ func TestIsRejectCode(t *testing.T) {
 
 	_, _, ok = IsRejectCode(New(ErrorOutOfDiskSpace))
 	assert.False(t, ok)
+
+	code, _, ok = IsRejectCode(prefixError{"accept tx: ", New(RejectDuplicate)})
+	assert.Equal(t, code, RejectDuplicate)
+	assert.True(t, ok)
+}
+
+// prefixError prefixes the message of the error it wraps.
+type prefixError struct {
+	prefix string
+	err    error
+}
+
+func (e prefixError) Error() string {
+	return e.prefix + e.err.Error()
+}
+
+func (e prefixError) Unwrap() error {
+	return e.err
+}
+
+func TestWrap(t *testing.T) {
+	scriptErr := New(ScriptErrEvalFalse)
+	cause := prefixError{"input 1: ", scriptErr}
+	err := Wrap(RejectInvalid, cause)
+
+	assert.True(t, IsErrorCode(err, RejectInvalid))
+	assert.True(t, IsErrorCode(err, ScriptErrEvalFalse))
+	assert.False(t, IsErrorCode(err, ScriptErrOpReturn))
+	assert.Equal(t, cause, unwrap(err))
+	assert.Equal(t, scriptErr, unwrap(unwrap(err)))
+
+	code, _, ok := IsRejectCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, RejectInvalid, code)
+	assert.Contains(t, err.Error(), "input 1: ")
+	assert.Contains(t, err.Error(), ScriptErrEvalFalse.String())
 }
@@ -1,5 +1,7 @@
 package errcode
 
+import "fmt"
+
 type RPCErr int
 
 const (
@@ -9,10 +11,38 @@ const (
 	ErrorNotExistInRPCMap
 )
 
+// These constants are the JSON-RPC error numbers of Bitcoin Core, an error
+// is reported to RPC clients with one of them.
+const (
+	RPCMiscError            RPCErr = -1
+	RPCTypeError            RPCErr = -3
+	RPCInvalidAddressOrKey  RPCErr = -5
+	RPCOutOfMemory          RPCErr = -7
+	RPCInvalidParameter     RPCErr = -8
+	RPCDatabaseError        RPCErr = -20
+	RPCDeserializationError RPCErr = -22
+	RPCVerifyError          RPCErr = -25
+	RPCVerifyRejected       RPCErr = -26
+	RPCVerifyAlreadyInChain RPCErr = -27
+	RPCInWarmup             RPCErr = -28
+)
+
 var rpcDesc = map[RPCErr]string{
 	ModelValid:   "Valid",
 	ModelInvalid: "Invalid",
 	ModelError:   "Error",
+
+	RPCMiscError:            "RPC_MISC_ERROR",
+	RPCTypeError:            "RPC_TYPE_ERROR",
+	RPCInvalidAddressOrKey:  "RPC_INVALID_ADDRESS_OR_KEY",
+	RPCOutOfMemory:          "RPC_OUT_OF_MEMORY",
+	RPCInvalidParameter:     "RPC_INVALID_PARAMETER",
+	RPCDatabaseError:        "RPC_DATABASE_ERROR",
+	RPCDeserializationError: "RPC_DESERIALIZATION_ERROR",
+	RPCVerifyError:          "RPC_VERIFY_ERROR",
+	RPCVerifyRejected:       "RPC_VERIFY_REJECTED",
+	RPCVerifyAlreadyInChain: "RPC_VERIFY_ALREADY_IN_CHAIN",
+	RPCInWarmup:             "RPC_IN_WARMUP",
 }
 
 func (re RPCErr) String() string {
@@ -23,3 +53,42 @@ func (re RPCErr) String() string {
 
 	return "Unknown error code!"
 }
+
+// ToRPCError maps err to the JSON-RPC error number and message an RPC client
+// is given for it. Transactions rejected by a rule map to RPCVerifyRejected
+// with the reject code and reason, like Bitcoin Core does, other validation
+// failures to RPCVerifyError. The errcode of an error err wraps is used if err
+// has none, an error without any errcode maps to RPCMiscError.
+func ToRPCError(err error) (int, string) {
+	e, ok := asProjectError(err)
+	if !ok {
+		return int(RPCMiscError), err.Error()
+	}
+
+	switch t := e.ErrorCode.(type) {
+	case RejectCode:
+		return int(RPCVerifyRejected), fmt.Sprintf("%d: %s", t, e.Desc)
+	case InternalRejectCode:
+		return int(RPCVerifyRejected), e.Desc
+	case MemPoolErr:
+		if t == MissParent {
+			return int(RPCVerifyError), "Missing inputs"
+		}
+		return int(RPCVerifyRejected), e.Desc
+	case TxErr:
+		if t == TxErrNoPreviousOut {
+			return int(RPCVerifyError), "Missing inputs"
+		}
+		return int(RPCVerifyError), e.Desc
+	case ChainErr, ScriptErr:
+		return int(RPCVerifyError), e.Desc
+	case DiskErr:
+		return int(RPCDatabaseError), e.Desc
+	case RPCErr:
+		if t < 0 {
+			return int(t), e.Desc
+		}
+	}
+
+	return int(RPCMiscError), e.Desc
+}
@@ -1,6 +1,7 @@
 package errcode
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -30,3 +31,54 @@ func TestRPCErr_String(t *testing.T) {
 		}
 	}
 }
+
+func TestRPCErr_StringCoreCodes(t *testing.T) {
+	tests := []struct {
+		in   RPCErr
+		code int
+		want string
+	}{
+		{RPCMiscError, -1, "RPC_MISC_ERROR"},
+		{RPCInvalidAddressOrKey, -5, "RPC_INVALID_ADDRESS_OR_KEY"},
+		{RPCDeserializationError, -22, "RPC_DESERIALIZATION_ERROR"},
+		{RPCVerifyError, -25, "RPC_VERIFY_ERROR"},
+		{RPCVerifyRejected, -26, "RPC_VERIFY_REJECTED"},
+		{RPCInWarmup, -28, "RPC_IN_WARMUP"},
+	}
+
+	for i, test := range tests {
+		if int(test.in) != test.code {
+			t.Errorf("Code #%d\n got: %d want: %d", i, test.in, test.code)
+		}
+		if result := test.in.String(); result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result, test.want)
+		}
+	}
+}
+
+func TestToRPCError(t *testing.T) {
+	tests := []struct {
+		in      error
+		code    int
+		message string
+	}{
+		{NewError(RejectInsufficientFee, "min relay fee not met"), -26, "66: min relay fee not met"},
+		{New(RejectHighFee), -26, "RejectHighFee"},
+		{New(AlreadHaveTx), -26, "The transaction already in mempool"},
+		{New(MissParent), -25, "Missing inputs"},
+		{New(TxErrNoPreviousOut), -25, "Missing inputs"},
+		{New(ErrorFailedToWriteToCoinDatabase), -20, "ErrorFailedToWriteToCoinDatabase"},
+		{NewError(RPCInvalidAddressOrKey, "Block not found"), -5, "Block not found"},
+		{prefixError{"accept tx: ", New(RejectHighFee)}, -26, "RejectHighFee"},
+		{WithDetail(prefixError{"block: ", New(ErrorFailedToWriteToCoinDatabase)}, DetailInput, 0), -20, "ErrorFailedToWriteToCoinDatabase"},
+		{fmt.Errorf("unexpected"), -1, "unexpected"},
+	}
+
+	for i, test := range tests {
+		code, message := ToRPCError(test.in)
+		if code != test.code || message != test.message {
+			t.Errorf("ToRPCError #%d\n got: %d %q want: %d %q", i, code,
+				message, test.code, test.message)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/copernet/copernicus/crypto"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lindex"
+	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/logic/lreindex"
 	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
@@ -21,8 +23,13 @@ import (
 	"github.com/copernet/copernicus/persist/disk"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 )
 
+// dataDirLock is the lock of the data directory held by the node until
+// shutdown.
+var dataDirLock *conf.DataDirLock
+
 func appInitMain(args []string) {
 	conf.Cfg = conf.InitConfig(args)
 	if conf.Cfg == nil {
@@ -30,6 +37,18 @@ func appInitMain(args []string) {
 		os.Exit(0)
 	}
 
+	lock, err := conf.LockDataDir(conf.DataDir)
+	if err == conf.ErrDataDirLocked {
+		fmt.Printf("Error: %s: %v\n", conf.DataDir, err)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	dataDirLock = lock
+	debug.SetGCPercent(conf.Cfg.PProf.GCPercent)
+
 	if conf.Cfg.P2PNet.TestNet {
 		model.SetTestNetParams()
 	} else if conf.Cfg.P2PNet.RegTest {
@@ -82,6 +101,9 @@ func appInitMain(args []string) {
 
 	btd := blkdb.GetInstance()
 	persist.InitPersistGlobal(btd)
+	pruneState := disk.GetPruneState()
+	pruneState.PruneMode = conf.Cfg.Chain.Prune
+	pruneState.HavePruned = btd.ReadFlag("prunedblockfiles")
 
 	if !chain.InitGlobalChain(btd) {
 		return
@@ -121,5 +143,22 @@ func appInitMain(args []string) {
     chain's index map count: %d
     tip block index: %s
 ---------------------`, gChain.Height(), gChain.IndexMapSize(), gChain.Tip().String())
+	} else {
+		if err := lchain.ReconcileChainState(); err != nil {
+			log.Error("reconcile chain state failed: %s", err)
+			panic("chain state does not match the block index: " + err.Error())
+		}
+		if err := lchain.VerifyDB(conf.Cfg.Chain.CheckLevel, conf.Cfg.Chain.CheckBlocks); err != nil {
+			log.Error("verify chain failed: %s", err)
+			panic("corrupted block database detected, restart with --reindex: " + err.Error())
+		}
 	}
+
+	lindex.InitTxIndex()
+	lindex.InitBlockFilterIndex()
+
+	// The mempool saved on the last shutdown is loaded in the background, it
+	// is reported not loaded until then.
+	mempool.GetInstance().SetLoaded(false)
+	go lmempool.LoadMempool(filepath.Join(conf.DataDir, mempool.DumpFileName))
 }
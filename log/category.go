@@ -0,0 +1,91 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// allCategories stands for every known category in SetCategory.
+const allCategories = "all"
+
+// defaultCategories are the categories the logging RPC can always toggle,
+// whether or not the configuration enables them.
+var defaultCategories = []string{"bench", "db", "mempool", "net", "rpc", "validation"}
+
+// categories maps the known categories to a flag set while the category is
+// enabled. The map is only replaced by setModules before logging starts, the
+// flags are updated atomically while the node runs.
+var categories map[string]*int32
+
+func init() {
+	setModules(nil)
+}
+
+// setModules resets the known categories to the default ones plus modules,
+// enabling modules only.
+func setModules(modules []string) {
+	cats := make(map[string]*int32, len(defaultCategories)+len(modules))
+	for _, name := range defaultCategories {
+		cats[name] = new(int32)
+	}
+	for _, module := range modules {
+		module = strings.ToLower(module)
+		if cats[module] == nil {
+			cats[module] = new(int32)
+		}
+		*cats[module] = 1
+	}
+	categories = cats
+}
+
+func isCategory(module string) bool {
+	_, ok := categories[strings.ToLower(module)]
+	return ok
+}
+
+// HasCategory returns whether name is a known category or "all".
+func HasCategory(name string) bool {
+	return isCategory(name) || strings.ToLower(name) == allCategories
+}
+
+// SetCategory enables or disables the category name, or all of them for
+// "all". It returns false if the category is unknown.
+func SetCategory(name string, enable bool) bool {
+	name = strings.ToLower(name)
+	value := int32(0)
+	if enable {
+		value = 1
+	}
+	if name == allCategories {
+		for _, flag := range categories {
+			atomic.StoreInt32(flag, value)
+		}
+		return true
+	}
+	flag, ok := categories[name]
+	if !ok {
+		return false
+	}
+	atomic.StoreInt32(flag, value)
+	return true
+}
+
+// CategoryStates returns whether each known category is enabled.
+func CategoryStates() map[string]bool {
+	states := make(map[string]bool, len(categories))
+	for name, flag := range categories {
+		states[name] = atomic.LoadInt32(flag) != 0
+	}
+	return states
+}
+
+// CategoryNames returns the known categories in alphabetical order.
+func CategoryNames() []string {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
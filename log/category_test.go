@@ -0,0 +1,72 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/logs"
+)
+
+func TestCategory(t *testing.T) {
+	file, err := ioutil.TempFile("", "categorytest")
+	if err != nil {
+		t.Fatalf("create temp file failed: %v", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	logConf := struct {
+		FileName string `json:"filename"`
+		Level    int    `json:"level"`
+	}{
+		FileName: file.Name(),
+		Level:    GetLevel("debug"),
+	}
+	configuration, err := json.Marshal(logConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.GetBeeLogger().DelLogger(logs.AdapterFile)
+	if err := logs.SetLogger(logs.AdapterFile, string(configuration)); err != nil {
+		t.Fatalf("set logger failed: %v", err)
+	}
+	defer setModules(nil)
+
+	setModules(nil)
+	if CategoryStates()["mempool"] {
+		t.Fatalf("mempool category should start disabled")
+	}
+	if SetCategory("nosuchcategory", true) || HasCategory("nosuchcategory") {
+		t.Errorf("unknown category should be refused")
+	}
+
+	if !SetCategory("MemPool", true) || !CategoryStates()["mempool"] {
+		t.Fatalf("enable mempool category failed")
+	}
+	Print("mempool", "debug", "enabled mempool message %d", 1)
+	if !SetCategory("mempool", false) || CategoryStates()["mempool"] {
+		t.Fatalf("disable mempool category failed")
+	}
+	Print("mempool", "debug", "disabled mempool message %d", 2)
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("read log file failed: %v", err)
+	}
+	if !strings.Contains(string(content), "enabled mempool message 1") {
+		t.Errorf("enabled mempool category did not log: %s", content)
+	}
+	if strings.Contains(string(content), "disabled mempool message 2") {
+		t.Errorf("disabled mempool category still logs: %s", content)
+	}
+
+	SetCategory("all", true)
+	for name, enabled := range CategoryStates() {
+		if !enabled {
+			t.Errorf("category %s not enabled by all", name)
+		}
+	}
+}
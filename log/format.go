@@ -0,0 +1,160 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+// The formats of the log lines, chosen by conf.Cfg.Log.Format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Fields are the structured fields of a log line.
+type Fields map[string]interface{}
+
+var levelNames = [...]string{
+	logs.LevelEmergency:     "emergency",
+	logs.LevelAlert:         "alert",
+	logs.LevelCritical:      "critical",
+	logs.LevelError:         "error",
+	logs.LevelWarning:       "warning",
+	logs.LevelNotice:        "notice",
+	logs.LevelInformational: "info",
+	logs.LevelDebug:         "debug",
+}
+
+// jsonEntry is a log line in the json format.
+type jsonEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Category  string `json:"category,omitempty"`
+	Message   string `json:"message"`
+	Fields    Fields `json:"fields,omitempty"`
+}
+
+// jsonWriter writes the log lines at level or below as json objects, one per
+// line.
+type jsonWriter struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level int32
+}
+
+// jsonOutput is the writer of the json format, nil in the text format where
+// the lines go to the beego logger.
+var jsonOutput *jsonWriter
+
+func newJSONFileWriter(fileName string, level int) (*jsonWriter, error) {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w: file, level: int32(level)}, nil
+}
+
+func (jw *jsonWriter) write(when time.Time, level int, category, msg string, fields Fields) {
+	if int32(level) > atomic.LoadInt32(&jw.level) {
+		return
+	}
+	line, err := json.Marshal(&jsonEntry{
+		Timestamp: when.Format(time.RFC3339Nano),
+		Level:     levelNames[level],
+		Category:  category,
+		Message:   msg,
+		Fields:    fields,
+	})
+	if err != nil {
+		line, _ = json.Marshal(&jsonEntry{
+			Timestamp: when.Format(time.RFC3339Nano),
+			Level:     levelNames[level],
+			Category:  category,
+			Message:   fmt.Sprintf("%s (bad fields: %v)", msg, err),
+		})
+	}
+
+	jw.lock.Lock()
+	defer jw.lock.Unlock()
+	jw.w.Write(append(line, '\n'))
+}
+
+// formatMessage formats the message the way beego does: f is a format string
+// if it holds verbs, otherwise the values are appended to it.
+func formatMessage(f interface{}, v ...interface{}) string {
+	msg, ok := f.(string)
+	if !ok {
+		msg = fmt.Sprint(f)
+		if len(v) == 0 {
+			return msg
+		}
+		msg += strings.Repeat(" %v", len(v))
+	} else if len(v) == 0 {
+		return msg
+	} else if !strings.Contains(msg, "%") || strings.Contains(msg, "%%") {
+		msg += strings.Repeat(" %v", len(v))
+	}
+	return fmt.Sprintf(msg, v...)
+}
+
+// textMessage decorates the message with its category and fields for the text
+// format.
+func textMessage(level int, category, msg string, fields Fields) string {
+	if category != "" {
+		msg = fmt.Sprintf("module[%s]: level[%s] ", category, levelNames[level]) + msg
+	}
+	if len(fields) == 0 {
+		return msg
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, key := range keys {
+		fmt.Fprintf(&sb, " %s=%v", key, fields[key])
+	}
+	return sb.String()
+}
+
+// output hands a log line to the writer of the configured format. It must be
+// called straight from the exported logging functions, beego finds the
+// caller's file and line at a fixed depth.
+func output(level int, category string, fields Fields, f interface{}, v ...interface{}) {
+	msg := formatMessage(f, v...)
+	if jsonOutput != nil {
+		jsonOutput.write(time.Now(), level, category, msg, fields)
+		return
+	}
+
+	msg = textMessage(level, category, msg, fields)
+	switch level {
+	case logs.LevelEmergency:
+		logs.Emergency(msg)
+	case logs.LevelAlert:
+		logs.Alert(msg)
+	case logs.LevelCritical:
+		logs.Critical(msg)
+	case logs.LevelError:
+		logs.Error(msg)
+	case logs.LevelWarning:
+		logs.Warning(msg)
+	case logs.LevelNotice:
+		logs.Notice(msg)
+	case logs.LevelInformational:
+		logs.Informational(msg)
+	default:
+		logs.Debug(msg)
+	}
+}
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/conf"
+)
+
+func TestJSONFormat(t *testing.T) {
+	file, err := ioutil.TempFile("", "jsonlogtest")
+	if err != nil {
+		t.Fatalf("create temp file failed: %v", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	format, modules := conf.Cfg.Log.Format, conf.Cfg.Log.Module
+	conf.Cfg.Log.Format = FormatJSON
+	conf.Cfg.Log.Module = []string{"mempool"}
+	defer func() {
+		conf.Cfg.Log.Format, conf.Cfg.Log.Module = format, modules
+		jsonOutput = nil
+		setModules(nil)
+	}()
+	Init(fmt.Sprintf(`{"filename":%q,"level":%d}`, file.Name(), GetLevel("info")))
+	if jsonOutput == nil {
+		t.Fatalf("json format not set up")
+	}
+
+	before := time.Now().Add(-time.Second)
+	Info("plain message %d", 1)
+	Debug("below the configured level")
+	PrintFields("mempool", "warn", Fields{"txid": "abcd", "size": 250}, "rejected %s", "abcd")
+	Warn("100% done")
+
+	content, err := os.Open(file.Name())
+	if err != nil {
+		t.Fatalf("open log file failed: %v", err)
+	}
+	defer content.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		entry := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("log line %q is not json: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d log lines, want 3: %v", len(entries), entries)
+	}
+
+	for _, entry := range entries {
+		when, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string))
+		if err != nil || when.Before(before) {
+			t.Errorf("bad timestamp in %v", entry)
+		}
+	}
+
+	if entries[0]["level"] != "info" || entries[0]["message"] != "plain message 1" {
+		t.Errorf("unexpected first entry %v", entries[0])
+	}
+	if _, ok := entries[0]["category"]; ok {
+		t.Errorf("uncategorized entry has a category: %v", entries[0])
+	}
+
+	fields, _ := entries[1]["fields"].(map[string]interface{})
+	if entries[1]["level"] != "warning" || entries[1]["category"] != "mempool" ||
+		entries[1]["message"] != "rejected abcd" ||
+		fields["txid"] != "abcd" || fields["size"] != float64(250) {
+		t.Errorf("unexpected second entry %v", entries[1])
+	}
+
+	if entries[2]["message"] != "100% done" {
+		t.Errorf("unexpected third entry %v", entries[2])
+	}
+}
+
+func TestTextMessage(t *testing.T) {
+	msg := textMessage(GetLevel("debug"), "net", "peer connected",
+		Fields{"peer": "127.0.0.1:8333", "id": 3})
+	want := "module[net]: level[debug] peer connected id=3 peer=127.0.0.1:8333"
+	if msg != want {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"encoding/json"
 	"strings"
+	"sync/atomic"
 
 	"github.com/astaxie/beego/logs"
 )
@@ -27,3 +29,26 @@ func GetLevel(level string) int {
 	}
 	return ele
 }
+
+// SetLevel changes the level of the log lines written to the level given to
+// Init, e.g. on a reload of the configuration.
+func SetLevel(level int) error {
+	if jw := jsonOutput; jw != nil {
+		atomic.StoreInt32(&jw.level, int32(level))
+		return nil
+	}
+
+	logConf, err := json.Marshal(struct {
+		FileName string `json:"filename"`
+		Level    int    `json:"level"`
+	}{
+		FileName: filePath,
+		Level:    level,
+	})
+	if err != nil {
+		return err
+	}
+	// The file adapter only takes its level when it is set up.
+	logs.GetBeeLogger().DelLogger(logs.AdapterFile)
+	return logs.SetLogger(logs.AdapterFile, string(logConf))
+}
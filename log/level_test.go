@@ -2,7 +2,10 @@ package log
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +51,30 @@ func initLogLevel(level string) {
 	Init(string(configuration))
 	os.Remove(fileName)
 }
+
+func TestSetLevel(t *testing.T) {
+	file, err := ioutil.TempFile("", "leveltest")
+	if err != nil {
+		t.Fatalf("create temp file failed: %v", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	Init(fmt.Sprintf(`{"filename":%q,"level":%d}`, file.Name(), GetLevel("info")))
+	Debug("hidden debug line")
+	if err := SetLevel(GetLevel("debug")); err != nil {
+		t.Fatalf("set level failed: %v", err)
+	}
+	Debug("shown debug line")
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("read log file failed: %v", err)
+	}
+	if strings.Contains(string(content), "hidden debug line") {
+		t.Errorf("debug line written at info level")
+	}
+	if !strings.Contains(string(content), "shown debug line") {
+		t.Errorf("debug line not written after the level is set to debug")
+	}
+}
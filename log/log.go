@@ -1,9 +1,10 @@
 package log
 
 import (
+	"encoding/json"
 	"strings"
+	"sync/atomic"
 
-	"fmt"
 	"github.com/astaxie/beego/logs"
 	"github.com/copernet/copernicus/conf"
 )
@@ -13,117 +14,147 @@ const (
 	errModuleNotFound = "specified module not found"
 )
 
-var mapModule map[string]struct{}
+// printLevels maps the level names taken by Print to their levels.
+var printLevels = map[string]int{
+	"emergency": logs.LevelEmergency,
+	"alert":     logs.LevelAlert,
+	"critical":  logs.LevelCritical,
+	"error":     logs.LevelError,
+	"warn":      logs.LevelWarning,
+	"info":      logs.LevelInformational,
+	"debug":     logs.LevelDebug,
+	"notice":    logs.LevelNotice,
+}
 
+// Print logs the message at level if the log category module is enabled.
 func Print(module string, level string, format string, reason ...interface{}) {
-	level = strings.ToLower(level)
-	if isIncludeModule(module) {
-		format = fmt.Sprintf("module[%s]: level[%s]", module, level)
-		switch level {
-		case "emergency":
-			logs.Emergency(format, reason...)
-		case "alert":
-			logs.Alert(format, reason...)
-		case "critical":
-			logs.Critical(format, reason...)
-		case "error":
-			logs.Error(format, reason...)
-		case "warn":
-			logs.Warn(format, reason...)
-		case "info":
-			logs.Info(format, reason...)
-		case "debug":
-			logs.Debug(format, reason...)
-		case "notice":
-			logs.Notice(format, reason...)
-		}
-	} else {
-		logs.GetLogger()
-		logs.Debug("module(%s): %v", module, errModuleNotFound)
+	if !isCategory(module) {
+		output(logs.LevelDebug, "", nil, "module(%s): %v", module, errModuleNotFound)
+		return
+	}
+	if lvl, ok := printLevel(module, level); ok {
+		output(lvl, module, nil, format, reason...)
+	}
+}
+
+// PrintFields is Print with structured fields attached to the message.
+func PrintFields(module string, level string, fields Fields, format string, reason ...interface{}) {
+	if !isCategory(module) {
+		output(logs.LevelDebug, "", nil, "module(%s): %v", module, errModuleNotFound)
+		return
+	}
+	if lvl, ok := printLevel(module, level); ok {
+		output(lvl, module, fields, format, reason...)
 	}
 }
 
-func isIncludeModule(module string) bool {
-	module = strings.ToLower(module)
-	if _, ok := mapModule[module]; ok {
-		return true
+// printLevel returns the level of a message of Print, and false if the
+// category module is disabled or the level unknown.
+func printLevel(module string, level string) (int, bool) {
+	lvl, ok := printLevels[strings.ToLower(level)]
+	if !ok || atomic.LoadInt32(categories[strings.ToLower(module)]) == 0 {
+		return 0, false
 	}
-	return false
+	return lvl, true
 }
 
 // Emergency logs a message at emergency level.
 func Emergency(f interface{}, v ...interface{}) {
-	logs.Emergency(f, v...)
+	output(logs.LevelEmergency, "", nil, f, v...)
 }
 
 // Alert logs a message at alert level.
 func Alert(f interface{}, v ...interface{}) {
-	logs.Alert(f, v...)
+	output(logs.LevelAlert, "", nil, f, v...)
 }
 
 // Critical logs a message at critical level.
 func Critical(f interface{}, v ...interface{}) {
-	logs.Critical(f, v...)
+	output(logs.LevelCritical, "", nil, f, v...)
 }
 
 // Error logs a message at error level.
 func Error(f interface{}, v ...interface{}) {
-	logs.Error(f, v...)
+	output(logs.LevelError, "", nil, f, v...)
 }
 
 // Warning logs a message at warning level.
 func Warning(f interface{}, v ...interface{}) {
-	logs.Warning(f, v...)
+	output(logs.LevelWarning, "", nil, f, v...)
 }
 
 // Warn compatibility alias for Warning()
 func Warn(f interface{}, v ...interface{}) {
-	logs.Warn(f, v...)
+	output(logs.LevelWarning, "", nil, f, v...)
 }
 
 // Notice logs a message at notice level.
 func Notice(f interface{}, v ...interface{}) {
-	logs.Notice(f, v...)
+	output(logs.LevelNotice, "", nil, f, v...)
 }
 
 // Informational logs a message at info level.
 func Informational(f interface{}, v ...interface{}) {
-	logs.Informational(f, v...)
+	output(logs.LevelInformational, "", nil, f, v...)
 }
 
 // Info compatibility alias for Warning()
 func Info(f interface{}, v ...interface{}) {
-	logs.Info(f, v...)
+	output(logs.LevelInformational, "", nil, f, v...)
 }
 
 // Debug logs a message at debug level.
 func Debug(f interface{}, v ...interface{}) {
-	logs.Debug(f, v...)
+	output(logs.LevelDebug, "", nil, f, v...)
 }
 
 // Trace logs a message at trace level.
 // compatibility alias for Warning()
 func Trace(f interface{}, v ...interface{}) {
-	logs.Trace(f, v...)
+	output(logs.LevelDebug, "", nil, f, v...)
 }
 
 func GetLogger() *logs.BeeLogger {
 	return logs.GetBeeLogger()
 }
 
+// filePath is the path of the log file set up by Init.
+var filePath string
+
+// FilePath returns the path of the log file.
+func FilePath() string {
+	return filePath
+}
+
 func Init(logConf string) {
+	cfg := struct {
+		FileName string `json:"filename"`
+		Level    int    `json:"level"`
+	}{
+		Level: logs.LevelDebug,
+	}
+	if err := json.Unmarshal([]byte(logConf), &cfg); err != nil {
+		panic("log config parse failed: " + err.Error())
+	}
+	filePath = cfg.FileName
+
+	if conf.Cfg.Log.Format == FormatJSON {
+		writer, err := newJSONFileWriter(cfg.FileName, cfg.Level)
+		if err != nil {
+			panic("json log init failed: " + err.Error())
+		}
+		jsonOutput = writer
+		setModules(conf.Cfg.Log.Module)
+		return
+	}
+	jsonOutput = nil
 	logs.SetLogger(logs.AdapterFile, logConf)
 
 	// output filename and line number
 	logs.EnableFuncCallDepth(true)
-	logs.SetLogFuncCallDepth(4)
+	logs.SetLogFuncCallDepth(5)
 	// output async buffer
 	// logs.Async(1e3)
 
-	// init mapModule
-	mapModule = make(map[string]struct{})
-	for _, module := range conf.Cfg.Log.Module {
-		module = strings.ToLower(module)
-		mapModule[module] = struct{}{}
-	}
+	setModules(conf.Cfg.Log.Module)
 }
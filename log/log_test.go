@@ -50,9 +50,7 @@ func TestLog(t *testing.T) {
 
 	//run Print() if logic
 	for _, moduleStr := range module {
-		mapModule = map[string]struct{}{
-			moduleStr: {},
-		}
+		setModules([]string{moduleStr})
 		for _, levelStr := range level {
 			format := fmt.Sprintf("module[%s]: %s", moduleStr, levelStr)
 			Print(moduleStr, levelStr, format)
@@ -96,7 +94,7 @@ func TestLog(t *testing.T) {
 	}
 
 	//reset map module
-	mapModule = make(map[string]struct{})
+	setModules(nil)
 	level = append(level, "default")
 
 	path1, err := ioutil.TempDir("", "logtest1")
@@ -5,7 +5,6 @@ import (
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
-	"github.com/copernet/copernicus/logic/lmerkleroot"
 	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
@@ -55,7 +54,14 @@ func WriteBlockToDisk(bi *blockindex.BlockIndex, bl *block.Block, inDbp *block.D
 	return pos, nil
 }
 
+// CheckBlock runs the checks of pblock that are independent of context. The
+// errors carry the hash of the block.
 func CheckBlock(pblock *block.Block, checkHeader, checkMerlke bool) error {
+	err := checkBlock(pblock, checkHeader, checkMerlke)
+	return errcode.WithDetail(err, errcode.DetailBlockHash, pblock.GetHash())
+}
+
+func checkBlock(pblock *block.Block, checkHeader, checkMerlke bool) error {
 	// These are checks that are independent of context.
 	if pblock.Checked {
 		return nil
@@ -70,21 +76,20 @@ func CheckBlock(pblock *block.Block, checkHeader, checkMerlke bool) error {
 	}
 
 	// Check the merkle root.
-	mutated := false
 	if checkMerlke {
-		hashMerkleRoot2 := lmerkleroot.BlockMerkleRoot(pblock.Txs, &mutated)
+		hashMerkleRoot2 := pblock.ComputeMerkleRoot()
 		if !bh.MerkleRoot.IsEqual(&hashMerkleRoot2) {
-			log.Debug("ErrorBadTxMrklRoot")
+			log.Print("validation", "debug", "ErrorBadTxMrklRoot")
 			return errcode.NewError(errcode.RejectInvalid, "bad-txnmrklroot")
 		}
-	}
 
-	// Check for merkle tree malleability (CVE-2012-2459): repeating
-	// sequences of transactions in a lblock without affecting the merkle
-	// root of a lblock, while still invalidating it.
-	if mutated {
-		log.Debug("ErrorbadTxnsDuplicate")
-		return errcode.NewError(errcode.RejectInvalid, "bad-txns-duplicate")
+		// Check for merkle tree malleability (CVE-2012-2459): repeating
+		// sequences of transactions in a lblock without affecting the merkle
+		// root of a lblock, while still invalidating it.
+		if pblock.IsMerkleMutated() {
+			log.Print("validation", "debug", "ErrorbadTxnsDuplicate")
+			return errcode.NewError(errcode.RejectInvalid, "bad-txns-duplicate")
+		}
 	}
 
 	// First transaction must be coinbase
@@ -97,13 +102,13 @@ func CheckBlock(pblock *block.Block, checkHeader, checkMerlke bool) error {
 	// Bail early if there is no way this lblock is of reasonable size.
 	minTransactionSize := tx.NewEmptyTx().EncodeSize()
 	if uint64(len(pblock.Txs)*int(minTransactionSize)) > nMaxBlockSize {
-		log.Debug("ErrorBadBlkLength")
+		log.Print("validation", "debug", "ErrorBadBlkLength")
 		return errcode.NewError(errcode.RejectInvalid, "bad-blk-length")
 	}
 
 	currentBlockSize := pblock.EncodeSize()
 	if uint64(currentBlockSize) > nMaxBlockSize {
-		log.Debug("ErrorBadBlkTxSize")
+		log.Print("validation", "debug", "ErrorBadBlkTxSize")
 		return errcode.NewError(errcode.RejectInvalid, "bad-blk-length")
 	}
 
@@ -114,7 +119,7 @@ func CheckBlock(pblock *block.Block, checkHeader, checkMerlke bool) error {
 
 	err := ltx.CheckBlockTransactions(pblock.Txs, nMaxBlockSigOps)
 	if err != nil {
-		log.Debug("ErrorBadBlkTx: %v", err)
+		log.Print("validation", "debug", "ErrorBadBlkTx: %v", err)
 		return err
 	}
 	pblock.Checked = true
@@ -148,7 +153,7 @@ func ContextualCheckBlock(b *block.Block, indexPrev *blockindex.BlockIndex) erro
 	// Enforce rule that the coinBase starts with serialized lblock height
 	err := ltx.ContextureCheckBlockTransactions(b.Txs, height, lockTimeCutoff,
 		mediaTimePast)
-	return err
+	return errcode.WithDetail(err, errcode.DetailBlockHash, b.GetHash())
 }
 
 // ReceivedBlockTransactions Mark a lblock as having its data received and checked (up to
@@ -215,14 +220,14 @@ func AcceptBlock(pblock *block.Block, fRequested bool, inDbp *block.DiskBlockPos
 
 		hasMoreWork := tip == nil || bIndex.ChainWork.Cmp(&tip.ChainWork) == 1
 		if !hasMoreWork {
-			log.Debug("AcceptBlockHeader err:%d", 3008)
+			log.Print("validation", "debug", "AcceptBlockHeader err:%d", 3008)
 			err = errcode.ProjectError{Code: 3008}
 			return
 		}
 
 		fTooFarAhead := bIndex.Height > gChain.Height()+block.MinBlocksToKeep
 		if fTooFarAhead {
-			log.Debug("AcceptBlockHeader err:%d", 3007)
+			log.Print("validation", "debug", "AcceptBlockHeader err:%d", 3007)
 			err = errcode.ProjectError{Code: 3007}
 			return
 		}
@@ -230,8 +235,8 @@ func AcceptBlock(pblock *block.Block, fRequested bool, inDbp *block.DiskBlockPos
 		// Protect against DoS attacks from low-work chains.  If our tip is behind,
 		// a peer could try to send us low-work blocks on a fake chain that we would never
 		// request; don't process these.
-		mcw := pow.HashToBig(&model.ActiveNetParams.MinimumChainWork)
-		if bIndex.ChainWork.Cmp(mcw) == -1 {
+		mcw := pow.MiniChainWork()
+		if bIndex.ChainWork.Cmp(&mcw) == -1 {
 			return
 		}
 	}
@@ -270,7 +275,7 @@ func AcceptBlockHeader(bh *block.BlockHeader) (*blockindex.BlockIndex, error) {
 	bIndex := gChain.FindBlockIndex(bh.GetHash())
 	if bIndex != nil {
 		if bIndex.IsInvalid() {
-			log.Debug("AcceptBlockHeader Invalid index")
+			log.Print("validation", "debug", "AcceptBlockHeader Invalid index")
 			return bIndex, errcode.New(errcode.ErrorBlockHeaderNoValid)
 		}
 		return bIndex, nil
@@ -286,19 +291,19 @@ func AcceptBlockHeader(bh *block.BlockHeader) (*blockindex.BlockIndex, error) {
 	if !bIndex.IsGenesis(gChain.GetParams()) {
 		bIndex.Prev = gChain.FindBlockIndex(bh.HashPrevBlock)
 		if bIndex.Prev == nil {
-			log.Debug("Find Block in BlockIndexMap err, hash:%s", bh.HashPrevBlock)
+			log.Print("validation", "debug", "Find Block in BlockIndexMap err, hash:%s", bh.HashPrevBlock)
 			return nil, errcode.New(errcode.ErrorBlockHeaderNoParent)
 		}
 		if bIndex.Prev.IsInvalid() {
-			log.Debug("AcceptBlockHeader Invalid Pre index")
+			log.Print("validation", "debug", "AcceptBlockHeader Invalid Pre index")
 			return nil, errcode.ProjectError{Code: 3100}
 		}
 		if err := gChain.CheckIndexAgainstCheckpoint(bIndex.Prev); err != nil {
-			log.Debug("AcceptBlockHeader err:%d", 3100)
+			log.Print("validation", "debug", "AcceptBlockHeader err:%d", 3100)
 			return nil, errcode.ProjectError{Code: 3100}
 		}
 		if err = ContextualCheckBlockHeader(bh, bIndex.Prev, util.GetAdjustedTimeSec()); err != nil {
-			log.Debug("AcceptBlockHeader err:%d", 3101)
+			log.Print("validation", "debug", "AcceptBlockHeader err:%d", 3101)
 			return nil, err
 		}
 	}
@@ -309,7 +314,7 @@ func AcceptBlockHeader(bh *block.BlockHeader) (*blockindex.BlockIndex, error) {
 
 	err = gChain.AddToIndexMap(bIndex)
 	if err != nil {
-		log.Debug("AcceptBlockHeader AddToIndexMap err")
+		log.Print("validation", "debug", "AcceptBlockHeader AddToIndexMap err")
 		return nil, err
 	}
 
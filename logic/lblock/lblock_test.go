@@ -11,12 +11,14 @@ import (
 
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
 	"github.com/copernet/copernicus/model/blockindex"
 	"github.com/copernet/copernicus/model/chain"
 	"github.com/copernet/copernicus/model/consensus"
 	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/pow"
 	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/utxo"
@@ -78,6 +80,7 @@ func initTestEnv(t *testing.T, args []string) (dirpath string, err error) {
 	} else if conf.Cfg.P2PNet.RegTest {
 		model.SetRegTestParams()
 	}
+	pow.UpdateMinimumChainWork()
 
 	//init log
 	logDir := filepath.Join(conf.DataDir, log.DefaultLogDirname)
@@ -315,9 +318,14 @@ func TestCheckBlock(t *testing.T) {
 
 	blk3 := getBlock(blk3str)
 	blk3.Txs[0].UpdateInScript(0, script.NewEmptyScript())
-	if err := CheckBlock(blk3, false, true); err == nil {
+	blk3.InvalidateCache()
+	err = CheckBlock(blk3, false, true)
+	if err == nil {
 		t.Errorf("TestCheckBlock test 3 check bad merkle root failed")
 	}
+	if hash, ok := errcode.Detail(err, errcode.DetailBlockHash); !ok || hash != blk3.GetHash() {
+		t.Errorf("TestCheckBlock test 3 expect the block hash attached to the error, got %v", hash)
+	}
 
 	blk4 := getBlock(blk4str)
 	if err := CheckBlock(blk4, true, true); err != nil {
@@ -349,6 +357,20 @@ func TestCheckBlock(t *testing.T) {
 	if err := CheckBlock(genesisBlock, true, true); err != nil {
 		t.Errorf("TestCheckBlock test 7 check genesis block failed")
 	}
+
+	// with an odd number of transactions, duplicating the last one leaves
+	// the merkle root unchanged
+	blk8 := getBlock(blk4str)
+	blk8.Txs = append(blk8.Txs, tx.NewTx(1, tx.DefaultVersion), tx.NewTx(2, tx.DefaultVersion))
+	blk8.InvalidateCache()
+	blk8.Header.MerkleRoot = blk8.ComputeMerkleRoot()
+	blk8 = &block.Block{Header: blk8.Header, Txs: append(blk8.Txs, blk8.Txs[2])}
+	if blk8.ComputeMerkleRoot() != blk8.Header.MerkleRoot {
+		t.Errorf("TestCheckBlock test 8 expect merkle root unchanged by the duplicate")
+	}
+	if err := CheckBlock(blk8, false, true); err == nil {
+		t.Errorf("TestCheckBlock test 8 check duplicated transactions failed")
+	}
 }
 
 func TestGetBlockScriptFlags(t *testing.T) {
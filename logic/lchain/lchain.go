@@ -146,7 +146,7 @@ func ConnectBlock(pblock *block.Block, pindex *blockindex.BlockIndex, view *utxo
 	}
 
 	flags := lblock.GetBlockScriptFlags(pindex.Prev)
-	log.Debug("Connect Block: %s, height: %d, flags: %d", pindex.GetBlockHash().String(), pindex.Height, flags)
+	log.Print("validation", "debug", "Connect Block: %s, height: %d, flags: %d", pindex.GetBlockHash().String(), pindex.Height, flags)
 	blockSubSidy := model.GetBlockSubsidy(pindex.Height, params)
 	time2 := time.Now()
 	gPersist.GlobalTimeForks += time2.Sub(time1)
@@ -161,7 +161,7 @@ func ConnectBlock(pblock *block.Block, pindex *blockindex.BlockIndex, view *utxo
 	coinsMap, blockUndo, err := ltx.ApplyBlockTransactions(pblock.Txs, bip30Enable, flags,
 		fScriptChecks, blockSubSidy, pindex.Height, maxSigOps, uint32(lockTimeFlags), pindex)
 	if err != nil {
-		return err
+		return errcode.WithDetail(err, errcode.DetailBlockHash, blockHash)
 	}
 
 	undoPos := pindex.GetUndoPos()
@@ -195,7 +195,8 @@ func ConnectBlock(pblock *block.Block, pindex *blockindex.BlockIndex, view *utxo
 		mempool.InitMempool()
 	}
 
-	log.Debug("Connect block heigh:%d, hash:%s, txs: %d", pindex.Height, blockHash, len(pblock.Txs))
+	log.PrintFields("validation", "debug", log.Fields{"height": pindex.Height, "hash": blockHash.String(), "txs": len(pblock.Txs)},
+		"Connect block heigh:%d, hash:%s, txs: %d", pindex.Height, blockHash, len(pblock.Txs))
 	return nil
 }
 
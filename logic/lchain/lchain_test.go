@@ -1,11 +1,13 @@
 package lchain_test
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/logic/lmerkleroot"
 	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
@@ -28,6 +30,7 @@ import (
 	"github.com/copernet/copernicus/service"
 	"github.com/copernet/copernicus/service/mining"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
 	"github.com/stretchr/testify/assert"
 	"math"
 	"os"
@@ -288,3 +291,236 @@ func TestActivateBestChain(t *testing.T) {
 	height = tChain.TipHeight()
 	assert.Equal(t, int32(103), height)
 }
+
+// spendTx returns a transaction spending the output index of prev to
+// scriptPubKey, padded over the minimum transaction size.
+func spendTx(prev *tx.Tx, index uint32, value int64, scriptPubKey *script.Script) *tx.Tx {
+	txn := tx.NewTx(0, tx.DefaultVersion)
+	txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(prev.GetHash(), index), script.NewEmptyScript(), math.MaxUint32))
+	txn.AddTxOut(txout.NewTxOut(amount.Amount(value), scriptPubKey))
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	txn.AddTxOut(txout.NewTxOut(0, padding))
+	return txn
+}
+
+func TestConnectBlockChildBeforeParent(t *testing.T) {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateDummyBlocks(pubKey, 101, 1000000, 0, nil)
+	assert.Nil(t, err)
+
+	gChain := chain.GetInstance()
+	coinbase := func(height int32) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		return blk.Txs[0]
+	}
+
+	// The canonical order puts the child before its parent.
+	parent := spendTx(coinbase(1), 0, 40, pubKey)
+	parentHash := parent.GetHash()
+	var child *tx.Tx
+	for value := int64(30); ; value-- {
+		child = spendTx(parent, 0, value, pubKey)
+		childHash := child.GetHash()
+		if pow.HashToBig(&childHash).Cmp(pow.HashToBig(&parentHash)) < 0 {
+			break
+		}
+	}
+	_, err = generateDummyBlocks(pubKey, 1, 1000000, 101, []*tx.Tx{child, parent})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(102), gChain.TipHeight())
+	assert.True(t, utxo.GetUtxoCacheInstance().HaveCoin(outpoint.NewOutPoint(child.GetHash(), 0)))
+	assert.False(t, utxo.GetUtxoCacheInstance().HaveCoin(outpoint.NewOutPoint(parent.GetHash(), 0)))
+
+	// Two transactions of a block spending the same coin are still caught.
+	first := spendTx(coinbase(2), 0, 40, pubKey)
+	second := spendTx(coinbase(2), 0, 30, pubKey)
+	firstHash, secondHash := first.GetHash(), second.GetHash()
+	if pow.HashToBig(&secondHash).Cmp(pow.HashToBig(&firstHash)) < 0 {
+		first, second = second, first
+	}
+	generateDummyBlocks(pubKey, 1, 1000000, 102, []*tx.Tx{first, second})
+	assert.Equal(t, int32(102), gChain.TipHeight())
+}
+
+func TestChainTxCount(t *testing.T) {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateDummyBlocks(pubKey, 101, 1000000, 0, nil)
+	assert.Nil(t, err)
+
+	gChain := chain.GetInstance()
+	blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(1), gChain.GetParams())
+	assert.True(t, ok)
+	_, err = generateDummyBlocks(pubKey, 1, 1000000, 101, []*tx.Tx{spendTx(blk.Txs[0], 0, 40, pubKey)})
+	assert.Nil(t, err)
+
+	sum := int32(0)
+	for height := int32(0); height <= gChain.TipHeight(); height++ {
+		index := gChain.GetIndex(height)
+		sum += index.TxCount
+		assert.Equal(t, sum, index.ChainTxCount, "height %d", height)
+	}
+	assert.Equal(t, int32(103+1), sum)
+
+	// A block whose parent data is missing has no cumulative count until the
+	// parent arrives.
+	solve := func(prevHash util.Hash, height int32) *block.Block {
+		bk := createDummyBlock(pubKey, coinbaseScriptSigWithHeight(0, height), block.NewBlock(), prevHash)
+		bk.Header.MerkleRoot = lmerkleroot.BlockMerkleRoot(bk.Txs, nil)
+		for hash := bk.GetHash(); !new(pow.Pow).CheckProofOfWork(&hash, bk.Header.Bits, model.ActiveNetParams); hash = bk.GetHash() {
+			bk.Header.Nonce++
+		}
+		return bk
+	}
+	parent := solve(*gChain.Tip().GetBlockHash(), 103)
+	assert.Nil(t, service.ProcessBlockHeader([]*block.BlockHeader{&parent.Header}, nil))
+	child := solve(parent.GetHash(), 104)
+
+	fNewBlock := false
+	assert.Nil(t, service.ProcessNewBlock(child, true, &fNewBlock))
+	childIndex := gChain.FindBlockIndex(child.GetHash())
+	assert.NotNil(t, childIndex)
+	assert.Equal(t, int32(0), childIndex.ChainTxCount)
+
+	assert.Nil(t, service.ProcessNewBlock(parent, true, &fNewBlock))
+	assert.Equal(t, sum+1, gChain.FindBlockIndex(parent.GetHash()).ChainTxCount)
+	assert.Equal(t, sum+2, childIndex.ChainTxCount)
+	assert.Equal(t, int32(104), gChain.TipHeight())
+}
+
+func TestDisconnectRemovesCoinbaseSpends(t *testing.T) {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest", "--minrelaytxfee=0"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateDummyBlocks(pubKey, 201, 1000000, 0, nil)
+	assert.Nil(t, err)
+
+	gChain := chain.GetInstance()
+	coinbase := func(height int32) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		return blk.Txs[0]
+	}
+	spendCoinbase := func(height int32) *tx.Tx {
+		return spendTx(coinbase(height), 0, 40, pubKey)
+	}
+
+	// The coinbase of block 102 goes away with the reorg, the one of block
+	// 101 becomes immature, the one of block 1 stays spendable.
+	disconnected := spendCoinbase(102)
+	child := spendTx(disconnected, 0, 30, pubKey)
+	immature := spendCoinbase(101)
+	mature := spendCoinbase(1)
+	for _, txn := range []*tx.Tx{disconnected, child, immature, mature} {
+		assert.Nil(t, lmempool.AcceptTxToMemPool(txn))
+	}
+
+	for gChain.TipHeight() > 101 {
+		assert.Nil(t, lchain.DisconnectTip(false))
+	}
+	lmempool.RemoveForReorg(gChain.TipHeight()+1, int(tx.StandardLockTimeVerifyFlags))
+
+	pool := mempool.GetInstance()
+	assert.False(t, pool.IsTransactionInPool(disconnected))
+	assert.False(t, pool.IsTransactionInPool(child))
+	assert.False(t, pool.IsTransactionInPool(immature))
+	assert.True(t, pool.IsTransactionInPool(mature))
+	assert.Equal(t, 1, pool.Size())
+	assert.Equal(t, 0, pool.OrphanCount())
+	assert.Equal(t, *util.NewFeeRatePerK(0), pool.MinRelayTxFee())
+}
+
+// connectWithBadSignature connects, on top of 101 blocks, a block spending an
+// output with an invalid signature and buried under enough headers for
+// assumevalid to apply, and returns the height of the tip afterwards.
+func connectWithBadSignature(t *testing.T, assumeValid bool) int32 {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+	defer func() { chain.HashAssumeValid = util.HashZero }()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateDummyBlocks(pubKey, 101, 1000000, 0, nil)
+	assert.Nil(t, err)
+	gChain := chain.GetInstance()
+	blk1, ok := disk.ReadBlockFromDisk(gChain.GetIndex(1), gChain.GetParams())
+	assert.True(t, ok)
+
+	pubKeyBytes, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	p2pk := script.NewEmptyScript()
+	p2pk.PushSingleData(pubKeyBytes)
+	p2pk.PushOpCode(opcodes.OP_CHECKSIG)
+	funding := spendTx(blk1.Txs[0], 0, 40, p2pk)
+	badSig := script.NewEmptyScript()
+	badSig.PushSingleData(make([]byte, 71))
+	spend := tx.NewTx(0, tx.DefaultVersion)
+	spend.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(funding.GetHash(), 0), badSig, math.MaxUint32))
+	spend.AddTxOut(txout.NewTxOut(30, pubKey))
+	txs := []*tx.Tx{funding, spend}
+	fundingHash, spendHash := funding.GetHash(), spend.GetHash()
+	if pow.HashToBig(&spendHash).Cmp(pow.HashToBig(&fundingHash)) < 0 {
+		txs = []*tx.Tx{spend, funding}
+	}
+
+	tipHash := *gChain.Tip().GetBlockHash()
+	bk := createDummyBlock(pubKey, coinbaseScriptSigWithHeight(0, 102), block.NewBlock(), tipHash)
+	bk.Txs = append(bk.Txs, txs...)
+	bk.Header.MerkleRoot = lmerkleroot.BlockMerkleRoot(bk.Txs, nil)
+	solve := func(header *block.BlockHeader) {
+		for hash := header.GetHash(); !new(pow.Pow).CheckProofOfWork(&hash, header.Bits, model.ActiveNetParams); hash = header.GetHash() {
+			header.Nonce++
+			header.Hash = util.Hash{}
+		}
+	}
+	solve(&bk.Header)
+	assert.Nil(t, service.ProcessBlockHeader([]*block.BlockHeader{&bk.Header}, nil))
+
+	// Bury the block over two weeks of proof of work under headers.
+	prev := bk.Header
+	for i := 0; i < 2100; i++ {
+		header := block.NewBlockHeader()
+		header.Version = prev.Version
+		header.HashPrevBlock = prev.GetHash()
+		header.Time = prev.Time + 1
+		header.Bits = prev.Bits
+		solve(header)
+		assert.Nil(t, service.ProcessBlockHeader([]*block.BlockHeader{header}, nil))
+		prev = *header
+	}
+	if assumeValid {
+		chain.HashAssumeValid = prev.GetHash()
+	}
+
+	fNewBlock := false
+	service.ProcessNewBlock(bk, true, &fNewBlock)
+	return gChain.TipHeight()
+}
+
+func TestConnectBlockAssumeValid(t *testing.T) {
+	assert.Equal(t, int32(102), connectWithBadSignature(t, true))
+	assert.Equal(t, int32(101), connectWithBadSignature(t, false))
+}
@@ -0,0 +1,64 @@
+package lchain
+
+import (
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/persist"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/persist/disk"
+)
+
+// PruneBlockFilesManual deletes the block and undo files holding only blocks
+// at or below height, keeping the block.MinBlocksToKeep blocks below the tip.
+// The caller must hold persist.CsMain. It returns the height of the last
+// block of the active chain without data, -1 if the chain is complete.
+func PruneBlockFilesManual(height int32) (int32, error) {
+	gChain := chain.GetInstance()
+	if lastPrunable := gChain.Height() - block.MinBlocksToKeep; height > lastPrunable {
+		height = lastPrunable
+	}
+
+	gPersist := persist.GetInstance()
+	var filesToPrune []int32
+	indexes := gChain.IndexList()
+	persist.CsLastBlockFile.Lock()
+	for fileNumber := int32(0); fileNumber < gPersist.GlobalLastBlockFile; fileNumber++ {
+		info := gPersist.GlobalBlockFileInfo[fileNumber]
+		if info.Size == 0 || info.HeightLast > height {
+			continue
+		}
+		disk.PruneOneBlockFile(fileNumber, indexes)
+		filesToPrune = append(filesToPrune, fileNumber)
+	}
+	persist.CsLastBlockFile.Unlock()
+
+	if len(filesToPrune) > 0 {
+		pruneState := disk.GetPruneState()
+		if !pruneState.HavePruned {
+			if err := blkdb.GetInstance().WriteFlag("prunedblockfiles", true); err != nil {
+				return -1, err
+			}
+			pruneState.HavePruned = true
+		}
+
+		// The block index must no longer point to the files before they go.
+		pool := mempool.GetInstance()
+		mempoolSizeMax := int64(persist.DefaultMaxMemPoolSize) * 1000000
+		err := disk.FlushStateToDisk(disk.FlushStateAlways, 0, pool.GetPoolUsage(), mempoolSizeMax)
+		if err != nil {
+			return -1, err
+		}
+		for _, fileNumber := range filesToPrune {
+			disk.UnlinkPrunedFile(fileNumber)
+		}
+	}
+	log.Info("Prune (Manual): prune_height=%d removed %d blk/rev pairs", height, len(filesToPrune))
+
+	lastPruned := int32(-1)
+	for index := gChain.Genesis(); index != nil && !index.HasData(); index = gChain.Next(index) {
+		lastPruned = index.Height
+	}
+	return lastPruned, nil
+}
@@ -0,0 +1,52 @@
+package lchain
+
+import (
+	"fmt"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/persist"
+)
+
+// ReconcileChainState makes the chainstate agree with the block index at
+// startup, after an unclean shutdown may have left one flushed further than
+// the other. The chainstate best block must be in the block index. Blocks
+// the chainstate has connected but the index does not record as fully
+// validated are disconnected, then the blocks the index has past the
+// chainstate are connected again.
+func ReconcileChainState() error {
+	persist.CsMain.Lock()
+	defer persist.CsMain.Unlock()
+
+	gChain := chain.GetInstance()
+	bestHash, err := utxo.GetUtxoCacheInstance().GetBestBlock()
+	if err != nil {
+		// Nothing was ever connected to the chainstate.
+		return nil
+	}
+	if gChain.FindBlockIndex(bestHash) == nil {
+		return fmt.Errorf("chainstate best block %s is not in the block index, "+
+			"restart with --reindex", bestHash)
+	}
+
+	for tip := gChain.Tip(); tip.Prev != nil && !tip.IsValid(blockindex.BlockValidScripts); tip = gChain.Tip() {
+		log.Warn("Chainstate is ahead of the block index, disconnecting block %s at height %d",
+			tip.GetBlockHash(), tip.Height)
+		if err := DisconnectTip(true); err != nil {
+			return fmt.Errorf("roll back block %s: %v, restart with --reindex",
+				tip.GetBlockHash(), err)
+		}
+	}
+
+	start := gChain.Tip()
+	if err := ActivateBestChain(nil); err != nil {
+		return err
+	}
+	if tip := gChain.Tip(); tip != start {
+		log.Info("Chainstate was behind the block index, connected blocks from height %d to %d",
+			start.Height, tip.Height)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package lchain_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/persist"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/persist/db"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/stretchr/testify/assert"
+)
+
+// restartChain drops the chainstate cache and the block index in memory, and
+// loads them again from disk.
+func restartChain() {
+	coinsDB := utxo.GetUtxoCacheInstance().(*utxo.CoinsLruCache).GetCoinsDB()
+	coinsDB.GetDBW().Close()
+	utxo.InitUtxoLruTip(&utxo.UtxoConfig{Do: &db.DBOption{
+		FilePath:  conf.DataDir + "/chainstate",
+		CacheSize: (1 << 20) * 8,
+	}})
+	persist.InitPersistGlobal(blkdb.GetInstance())
+	cleanTestEnv()
+	chain.InitGlobalChain(blkdb.GetInstance())
+}
+
+func TestReconcileChainStateBehindIndex(t *testing.T) {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+
+	hashes, err := generateDummyBlocks(pubKey, 3, 1000000, 0, nil)
+	assert.Nil(t, err)
+	lastHash := hashes[2]
+
+	// The chainstate is one block behind the block index, which has the
+	// last block connected.
+	persist.CsMain.Lock()
+	err = lchain.DisconnectTip(true)
+	persist.CsMain.Unlock()
+	assert.Nil(t, err)
+	err = disk.FlushStateToDisk(disk.FlushStateAlways, 0, 0, 0)
+	assert.Nil(t, err)
+	restartChain()
+
+	tChain := chain.GetInstance()
+	assert.Equal(t, int32(2), tChain.TipHeight())
+	assert.NotNil(t, tChain.FindBlockIndex(lastHash))
+
+	err = lchain.ReconcileChainState()
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), tChain.TipHeight())
+	assert.Equal(t, lastHash, *tChain.Tip().GetBlockHash())
+	bestHash, err := utxo.GetUtxoCacheInstance().GetBestBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, lastHash, bestHash)
+
+	blk, ok := disk.ReadBlockFromDisk(tChain.Tip(), tChain.GetParams())
+	assert.True(t, ok)
+	coinbase := outpoint.NewOutPoint(blk.Txs[0].GetHash(), 0)
+	assert.NotNil(t, utxo.GetUtxoCacheInstance().GetCoin(coinbase))
+}
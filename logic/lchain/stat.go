@@ -3,11 +3,13 @@ package lchain
 import (
 	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/copernet/copernicus/conf"
@@ -151,6 +153,47 @@ func GetUTXOStats(cdb utxo.CoinsDB) (*UTXOStat, error) {
 	return utxoStat, nil
 }
 
+// ErrScanAborted is returned by ScanUTXOSet when the scan was aborted.
+var ErrScanAborted = errors.New("scan aborted")
+
+// ScannedCoin is an unspent output found by ScanUTXOSet.
+type ScannedCoin struct {
+	OutPoint outpoint.OutPoint
+	Coin     *utxo.Coin
+}
+
+// ScanUTXOSet walks the coins database with iter, one coin at a time, and
+// returns the number of coins searched and the coins whose serialized script
+// is a key of scripts. The percentage of the walk done is stored into
+// progress, and the scan stops with ErrScanAborted once abort is set.
+func ScanUTXOSet(iter *db.IterWrapper, scripts map[string]struct{}, progress, abort *int32) (uint64, []*ScannedCoin, error) {
+	var searched uint64
+	coins := make([]*ScannedCoin, 0)
+	iter.Seek([]byte{db.DbCoin})
+	for ; iter.Valid() && iter.GetKey()[0] == db.DbCoin; iter.Next() {
+		if atomic.LoadInt32(abort) != 0 {
+			return searched, nil, ErrScanAborted
+		}
+		outPoint := outpoint.OutPoint{}
+		if err := outPoint.Unserialize(bytes.NewBuffer(iter.GetKey()[1:])); err != nil {
+			return searched, nil, err
+		}
+		// The coins are sorted by txid, its first bytes tell the progress.
+		atomic.StoreInt32(progress, int32((0x100*int(outPoint.Hash[0])+int(outPoint.Hash[1]))*100/0x10000))
+
+		coin := utxo.NewEmptyCoin()
+		if err := coin.Unserialize(bytes.NewBuffer(iter.GetVal())); err != nil {
+			return searched, nil, err
+		}
+		searched++
+		if _, ok := scripts[string(coin.GetScriptPubKey().GetData())]; ok {
+			coins = append(coins, &ScannedCoin{OutPoint: outPoint, Coin: coin})
+		}
+	}
+	atomic.StoreInt32(progress, 100)
+	return searched, coins, nil
+}
+
 type utxoTaskArg struct {
 	iter *db.IterWrapper
 	stat *stat
@@ -0,0 +1,86 @@
+package lchain
+
+import (
+	"fmt"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lblock"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/persist"
+	"github.com/copernet/copernicus/persist/disk"
+)
+
+// MaxCheckLevel is the most thorough level of VerifyDB.
+const MaxCheckLevel = 4
+
+// VerifyDB checks the last depth blocks of the active chain, all of them when
+// depth is 0, at the given level:
+//
+//	0: the blocks can be read from disk
+//	1: the blocks are valid
+//	2: the undo data of the blocks can be read and is intact
+//	3: the undo data matches the inputs of the blocks
+//
+// Level 4 checks as level 3, the blocks are not connected again. The check
+// stops at the first block whose data was pruned.
+func VerifyDB(level, depth int32) error {
+	persist.CsMain.Lock()
+	defer persist.CsMain.Unlock()
+
+	gChain := chain.GetInstance()
+	tip := gChain.Tip()
+	if tip == nil || tip.Prev == nil {
+		return nil
+	}
+	if depth <= 0 || depth > tip.Height {
+		depth = tip.Height
+	}
+	if level > MaxCheckLevel {
+		level = MaxCheckLevel
+	}
+	log.Info("Verifying the last %d blocks at level %d", depth, level)
+
+	params := gChain.GetParams()
+	checked := 0
+	for index := tip; index.Prev != nil && index.Height > tip.Height-depth; index = index.Prev {
+		if !index.HasData() {
+			break
+		}
+		hash := index.GetBlockHash()
+		blk, ok := disk.ReadBlockFromDisk(index, params)
+		if !ok {
+			return fmt.Errorf("read block %s at height %d failed", hash, index.Height)
+		}
+		if level >= 1 {
+			if err := lblock.CheckBlock(blk, true, true); err != nil {
+				return fmt.Errorf("block %s at height %d is invalid: %v", hash, index.Height, err)
+			}
+		}
+		if level >= 2 {
+			pos := index.GetUndoPos()
+			if pos.IsNull() {
+				return fmt.Errorf("block %s at height %d has no undo data", hash, index.Height)
+			}
+			blockUndo, ok := disk.UndoReadFromDisk(&pos, *index.Prev.GetBlockHash())
+			if !ok {
+				return fmt.Errorf("undo data of block %s at height %d is corrupt", hash, index.Height)
+			}
+			if level >= 3 {
+				txUndos := blockUndo.GetTxundo()
+				if len(txUndos)+1 != len(blk.Txs) {
+					return fmt.Errorf("undo data of block %s at height %d has %d transactions, the block %d",
+						hash, index.Height, len(txUndos), len(blk.Txs)-1)
+				}
+				for i, txUndo := range txUndos {
+					if len(txUndo.GetUndoCoins()) != len(blk.Txs[i+1].GetIns()) {
+						return fmt.Errorf("undo data of transaction %s in block %s does not match its inputs",
+							blk.Txs[i+1].GetHash(), hash)
+					}
+				}
+			}
+		}
+		checked++
+	}
+	log.Info("Verified %d blocks at level %d", checked, level)
+	return nil
+}
@@ -0,0 +1,48 @@
+package lchain_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyDB(t *testing.T) {
+	model.SetRegTestParams()
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateDummyBlocks(pubKey, 3, 1000000, 0, nil)
+	assert.Nil(t, err)
+
+	for level := int32(0); level <= lchain.MaxCheckLevel; level++ {
+		assert.Nil(t, lchain.VerifyDB(level, 6), "level %d", level)
+	}
+
+	// Damage the undo data of the tip.
+	tip := chain.GetInstance().Tip()
+	pos := tip.GetUndoPos()
+	file, err := os.OpenFile(disk.GetBlockPosFilename(pos, "rev"), os.O_RDWR, 0)
+	assert.Nil(t, err)
+	data := make([]byte, 1)
+	_, err = file.ReadAt(data, int64(pos.Pos)+4)
+	assert.Nil(t, err)
+	data[0] ^= 0xff
+	_, err = file.WriteAt(data, int64(pos.Pos)+4)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	// The blocks are still fine, their undo data is not.
+	assert.Nil(t, lchain.VerifyDB(1, 6))
+	assert.NotNil(t, lchain.VerifyDB(2, 6))
+}
@@ -0,0 +1,63 @@
+package lindex
+
+import (
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/undo"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/gcs"
+)
+
+const (
+	// BasicFilterP is the Golomb-Rice parameter of the BIP158 basic filter.
+	BasicFilterP = 19
+
+	// BasicFilterM is the inverse false positive rate of the BIP158 basic
+	// filter.
+	BasicFilterM = 784931
+)
+
+// BasicFilterKey returns the SipHash key the items of the block's basic
+// filter are hashed with: the first bytes of the block hash.
+func BasicFilterKey(blockHash *util.Hash) [gcs.KeySize]byte {
+	var key [gcs.KeySize]byte
+	copy(key[:], blockHash[:gcs.KeySize])
+	return key
+}
+
+// BuildBasicFilter builds the BIP158 basic filter of the block: the scripts
+// of its outputs, except the empty and OP_RETURN ones, and the scripts of the
+// outputs it spends, as recorded in its undo data.
+func BuildBasicFilter(blk *block.Block, blockUndo *undo.BlockUndo) (*gcs.Filter, error) {
+	var items [][]byte
+	for _, transaction := range blk.Txs {
+		for i := 0; i < transaction.GetOutsCount(); i++ {
+			data := transaction.GetTxOut(i).GetScriptPubKey().GetData()
+			if len(data) == 0 || data[0] == opcodes.OP_RETURN {
+				continue
+			}
+			items = append(items, data)
+		}
+	}
+	for _, txUndo := range blockUndo.GetTxundo() {
+		for _, coin := range txUndo.GetUndoCoins() {
+			data := coin.GetScriptPubKey().GetData()
+			if len(data) == 0 {
+				continue
+			}
+			items = append(items, data)
+		}
+	}
+
+	hash := blk.GetHash()
+	return gcs.BuildGCSFilter(BasicFilterP, BasicFilterM, BasicFilterKey(&hash), items)
+}
+
+// FilterHeader chains the hash of the serialized filter to the header of the
+// previous block's filter, which is all zeros for the genesis block.
+func FilterHeader(filterHash *util.Hash, prevHeader *util.Hash) util.Hash {
+	data := make([]byte, 0, 2*util.Hash256Size)
+	data = append(data, filterHash[:]...)
+	data = append(data, prevHeader[:]...)
+	return util.DoubleSha256Hash(data)
+}
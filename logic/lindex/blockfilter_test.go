@@ -0,0 +1,150 @@
+package lindex
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/undo"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// p2pkhScript returns the pay to pubkey hash script of the address.
+func p2pkhScript(addr *script.Address) []byte {
+	data := []byte{opcodes.OP_DUP, opcodes.OP_HASH160, 20}
+	data = append(data, addr.EncodeToPubKeyHash()...)
+	return append(data, opcodes.OP_EQUALVERIFY, opcodes.OP_CHECKSIG)
+}
+
+func TestBasicFilterVector(t *testing.T) {
+	// BIP158 test vector of the testnet genesis block
+	filter, err := BuildBasicFilter(model.TestNetGenesisBlock, undo.NewBlockUndo(0))
+	assert.Nil(t, err)
+	assert.Equal(t, "019dfca8", hex.EncodeToString(filter.NBytes()))
+
+	filterHash := filter.Hash()
+	header := FilterHeader(&filterHash, &util.Hash{})
+	assert.Equal(t, "21584579b7eb08997773e5aeff3a7f932700042d0ed2a6129012b7d7ae81b750", header.String())
+}
+
+func TestBasicFilterMatchAddress(t *testing.T) {
+	addr, err := script.AddressFromHash160(bytes.Repeat([]byte{0x11}, 20), script.AddressVerPubKey())
+	assert.Nil(t, err)
+	spentAddr, err := script.AddressFromHash160(bytes.Repeat([]byte{0x22}, 20), script.AddressVerPubKey())
+	assert.Nil(t, err)
+	otherAddr, err := script.AddressFromHash160(bytes.Repeat([]byte{0x33}, 20), script.AddressVerPubKey())
+	assert.Nil(t, err)
+
+	// a regtest block paying to addr and spending a coin of spentAddr
+	blk := block.NewBlock()
+	blk.Header = model.RegTestGenesisBlock.Header
+	coinbase := tx.NewTx(0, tx.DefaultVersion)
+	coinbase.AddTxOut(txout.NewTxOut(5000, script.NewScriptRaw(p2pkhScript(addr))))
+	coinbase.AddTxOut(txout.NewTxOut(0, script.NewScriptRaw([]byte{opcodes.OP_RETURN, 0x01, 0x33})))
+	blk.Txs = append(blk.Txs, coinbase)
+
+	txUndo := undo.NewTxUndo()
+	spent := txout.NewTxOut(1000, script.NewScriptRaw(p2pkhScript(spentAddr)))
+	txUndo.SetUndoCoins([]*utxo.Coin{utxo.NewFreshCoin(spent, 1, false)})
+	blockUndo := undo.NewBlockUndo(0)
+	blockUndo.AddTxUndo(txUndo)
+
+	filter, err := BuildBasicFilter(blk, blockUndo)
+	assert.Nil(t, err)
+	// the OP_RETURN output is left out
+	assert.Equal(t, uint32(2), filter.N())
+
+	hash := blk.GetHash()
+	key := BasicFilterKey(&hash)
+	for _, a := range []*script.Address{addr, spentAddr} {
+		match, err := filter.Match(key, p2pkhScript(a))
+		assert.Nil(t, err)
+		assert.True(t, match, a.String())
+	}
+	match, err := filter.Match(key, p2pkhScript(otherAddr))
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+type memBlockFilterStore struct {
+	filters    map[util.Hash][]byte
+	headers    map[util.Hash]util.Hash
+	bestHeight *int32
+}
+
+func (s *memBlockFilterStore) ReadBlockFilter(hash *util.Hash) ([]byte, *util.Hash, error) {
+	header, ok := s.headers[*hash]
+	if !ok {
+		return nil, nil, nil
+	}
+	return s.filters[*hash], &header, nil
+}
+
+func (s *memBlockFilterStore) WriteBlockFilter(hash *util.Hash, filter []byte, header *util.Hash) error {
+	s.filters[*hash] = filter
+	s.headers[*hash] = *header
+	return nil
+}
+
+func (s *memBlockFilterStore) ReadBlockFilterBestHeight() (int32, error) {
+	if s.bestHeight == nil {
+		return 0, errors.New("not found")
+	}
+	return *s.bestHeight, nil
+}
+
+func (s *memBlockFilterStore) WriteBlockFilterBestHeight(height int32) error {
+	s.bestHeight = &height
+	return nil
+}
+
+type emptyUndoSource struct{}
+
+func (emptyUndoSource) BlockUndo(index *blockindex.BlockIndex) (*undo.BlockUndo, error) {
+	return undo.NewBlockUndo(0), nil
+}
+
+func TestBlockFilterIndexHeaderChain(t *testing.T) {
+	store := &memBlockFilterStore{
+		filters: make(map[util.Hash][]byte),
+		headers: make(map[util.Hash]util.Hash),
+	}
+	src := newGatedBlockSource(2)
+	for h := 1; h < len(src.indexes); h++ {
+		src.indexes[h].Prev = src.indexes[h-1]
+	}
+	close(src.gate)
+
+	fi := newBlockFilterIndex(store, src, emptyUndoSource{})
+	fi.Start()
+	<-fi.done
+	assert.True(t, fi.IsSynced())
+	assert.Equal(t, int32(2), fi.Info().BestBlockHeight)
+
+	prevHeader := util.Hash{}
+	for h, index := range src.indexes {
+		filter, header, err := fi.LookupFilter(index.GetBlockHash())
+		assert.Nil(t, err)
+		if !assert.NotNil(t, header, "height %d", h) {
+			continue
+		}
+
+		want, err := BuildBasicFilter(src.blocks[h], undo.NewBlockUndo(0))
+		assert.Nil(t, err)
+		assert.Equal(t, want.NBytes(), filter)
+
+		filterHash := util.DoubleSha256Hash(filter)
+		assert.Equal(t, FilterHeader(&filterHash, &prevHeader), *header)
+		prevHeader = *header
+	}
+}
@@ -0,0 +1,114 @@
+package lindex
+
+import (
+	"errors"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/undo"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/util"
+)
+
+// BlockFilterIndexName is the name the basic block filter index is reported
+// under by getindexinfo.
+const BlockFilterIndexName = "basic block filter index"
+
+// blockFilterStore is the persistent storage used by the block filter index,
+// it is satisfied by blkdb.BlockTreeDB.
+type blockFilterStore interface {
+	ReadBlockFilter(hash *util.Hash) ([]byte, *util.Hash, error)
+	WriteBlockFilter(hash *util.Hash, filter []byte, header *util.Hash) error
+	ReadBlockFilterBestHeight() (int32, error)
+	WriteBlockFilterBestHeight(height int32) error
+}
+
+// undoSource provides the coins spent by the blocks the filters are built of.
+type undoSource interface {
+	BlockUndo(index *blockindex.BlockIndex) (*undo.BlockUndo, error)
+}
+
+// BlockFilterIndex keeps the BIP158 basic filter and the filter header of
+// every block of the active chain. It is built in the background as described
+// on baseIndex.
+type BlockFilterIndex struct {
+	baseIndex
+	store blockFilterStore
+	undos undoSource
+}
+
+var blockFilterIndex *BlockFilterIndex
+
+// InitBlockFilterIndex creates the block filter index if it was enabled by
+// configuration and starts its background sync.
+func InitBlockFilterIndex() {
+	if !conf.Cfg.Chain.BlockFilterIndex {
+		return
+	}
+
+	blockFilterIndex = newBlockFilterIndex(blkdb.GetInstance(), activeChainSource{}, activeChainSource{})
+	chain.GetInstance().Subscribe(blockFilterIndex.handleBlockChainNotification)
+	blockFilterIndex.Start()
+}
+
+// StopBlockFilterIndex stops the block filter index, if it is enabled. Like
+// StopTxIndex, it must be called before the block tree database is closed.
+func StopBlockFilterIndex() {
+	if blockFilterIndex != nil {
+		blockFilterIndex.Stop()
+	}
+}
+
+// GetBlockFilterIndex returns the block filter index, or nil if it is not
+// enabled.
+func GetBlockFilterIndex() *BlockFilterIndex {
+	return blockFilterIndex
+}
+
+func newBlockFilterIndex(store blockFilterStore, source blockSource, undos undoSource) *BlockFilterIndex {
+	bestHeight, err := store.ReadBlockFilterBestHeight()
+	if err != nil {
+		bestHeight = -1
+	}
+	fi := &BlockFilterIndex{store: store, undos: undos}
+	fi.init(BlockFilterIndexName, bestHeight, fi, source)
+	return fi
+}
+
+// LookupFilter returns the serialized basic filter of the block and its filter
+// header, or nil if the index does not know the block.
+func (fi *BlockFilterIndex) LookupFilter(hash *util.Hash) ([]byte, *util.Hash, error) {
+	return fi.store.ReadBlockFilter(hash)
+}
+
+func (fi *BlockFilterIndex) writeBlock(index *blockindex.BlockIndex, blk *block.Block) error {
+	var prevHeader util.Hash
+	if index.Prev != nil {
+		_, header, err := fi.store.ReadBlockFilter(index.Prev.GetBlockHash())
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			return errors.New("filter header of the previous block not found")
+		}
+		prevHeader = *header
+	}
+
+	blockUndo, err := fi.undos.BlockUndo(index)
+	if err != nil {
+		return err
+	}
+	filter, err := BuildBasicFilter(blk, blockUndo)
+	if err != nil {
+		return err
+	}
+	filterHash := filter.Hash()
+	header := FilterHeader(&filterHash, &prevHeader)
+	return fi.store.WriteBlockFilter(index.GetBlockHash(), filter.NBytes(), &header)
+}
+
+func (fi *BlockFilterIndex) writeBestHeight(height int32) error {
+	return fi.store.WriteBlockFilterBestHeight(height)
+}
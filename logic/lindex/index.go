@@ -0,0 +1,251 @@
+package lindex
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/undo"
+	"github.com/copernet/copernicus/persist/disk"
+)
+
+// IndexInfo describes how far an optional index has been built.
+type IndexInfo struct {
+	Synced          bool
+	BestBlockHeight int32
+}
+
+// GetIndexInfo returns the sync state of every enabled index keyed by name.
+func GetIndexInfo() map[string]IndexInfo {
+	infos := make(map[string]IndexInfo)
+	if txIndex != nil {
+		infos[TxIndexName] = txIndex.Info()
+	}
+	if blockFilterIndex != nil {
+		infos[BlockFilterIndexName] = blockFilterIndex.Info()
+	}
+	return infos
+}
+
+// blockSource provides the active chain blocks the background sync walks through.
+type blockSource interface {
+	TipHeight() int32
+	BlockAt(height int32) (*blockindex.BlockIndex, *block.Block, error)
+}
+
+// indexer stores the entries of one block for an index built on baseIndex.
+type indexer interface {
+	writeBlock(index *blockindex.BlockIndex, blk *block.Block) error
+	writeBestHeight(height int32) error
+}
+
+// baseIndex builds an index block by block. When enabled on a node whose
+// chain is already synced, the missing part of the index is built by a
+// background task; until it catches up with the tip the index reports itself
+// as not synced and must not be used for lookups. Afterwards the index
+// follows the blocks connected to and disconnected from the active chain.
+type baseIndex struct {
+	name       string
+	mtx        sync.RWMutex
+	bestHeight int32
+	synced     bool
+	stopped    bool
+
+	indexer indexer
+	source  blockSource
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func (b *baseIndex) init(name string, bestHeight int32, idx indexer, source blockSource) {
+	b.name = name
+	b.bestHeight = bestHeight
+	b.indexer = idx
+	b.source = source
+	b.quit = make(chan struct{})
+	b.done = make(chan struct{})
+}
+
+// Start launches the background task that indexes all blocks between the
+// index's best height and the chain tip.
+func (b *baseIndex) Start() {
+	go b.syncHandler()
+}
+
+// Stop interrupts the background sync and waits for it to exit. The blocks
+// connected or disconnected afterwards are not indexed anymore.
+func (b *baseIndex) Stop() {
+	b.mtx.Lock()
+	if b.stopped {
+		b.mtx.Unlock()
+		return
+	}
+	b.stopped = true
+	b.mtx.Unlock()
+
+	close(b.quit)
+	<-b.done
+}
+
+// Info returns the current sync state of the index.
+func (b *baseIndex) Info() IndexInfo {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return IndexInfo{Synced: b.synced, BestBlockHeight: b.bestHeight}
+}
+
+// IsSynced reports whether the index has caught up with the chain tip.
+func (b *baseIndex) IsSynced() bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.synced
+}
+
+func (b *baseIndex) syncHandler() {
+	defer close(b.done)
+
+	for {
+		select {
+		case <-b.quit:
+			return
+		default:
+		}
+
+		b.mtx.Lock()
+		next := b.bestHeight + 1
+		if next > b.source.TipHeight() {
+			// Holding the lock while flipping the flag guarantees no
+			// connected block is missed between the tip check and the
+			// point the notification handler takes over.
+			b.synced = true
+			b.mtx.Unlock()
+			log.Info("%s is synced at height %d", b.name, next-1)
+			return
+		}
+		b.mtx.Unlock()
+
+		index, blk, err := b.source.BlockAt(next)
+		if err != nil {
+			log.Error("%s: read block at height %d failed: %v", b.name, next, err)
+			return
+		}
+
+		b.mtx.Lock()
+		if index.Height == b.bestHeight+1 {
+			err = b.indexBlock(index, blk)
+		}
+		b.mtx.Unlock()
+		if err != nil {
+			log.Error("%s: write block %s failed: %v", b.name, index.GetBlockHash(), err)
+			return
+		}
+	}
+}
+
+// blockConnected indexes a newly connected tip block once the background
+// sync has finished. Before that the sync task picks the block up itself.
+func (b *baseIndex) blockConnected(index *blockindex.BlockIndex, blk *block.Block) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.stopped || !b.synced || index.Height != b.bestHeight+1 {
+		return nil
+	}
+	return b.indexBlock(index, blk)
+}
+
+// blockDisconnected rewinds the best height, stale entries are left in place
+// and get overwritten when the block's height is connected again.
+func (b *baseIndex) blockDisconnected(index *blockindex.BlockIndex) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.stopped && index.Height <= b.bestHeight {
+		b.bestHeight = index.Height - 1
+		if err := b.indexer.writeBestHeight(b.bestHeight); err != nil {
+			log.Error("%s: write best height failed: %v", b.name, err)
+		}
+	}
+}
+
+// indexBlock writes the block and moves the best height to it. The caller
+// holds mtx.
+func (b *baseIndex) indexBlock(index *blockindex.BlockIndex, blk *block.Block) error {
+	if err := b.indexer.writeBlock(index, blk); err != nil {
+		return err
+	}
+	if err := b.indexer.writeBestHeight(index.Height); err != nil {
+		return err
+	}
+	b.bestHeight = index.Height
+	return nil
+}
+
+func (b *baseIndex) handleBlockChainNotification(notification *chain.Notification) {
+	switch notification.Type {
+	case chain.NTBlockConnected:
+		blk, ok := notification.Data.(*block.Block)
+		if !ok {
+			log.Warn("Chain connected notification is not a block.")
+			break
+		}
+		index := chain.GetInstance().FindBlockIndex(blk.GetHash())
+		if index == nil {
+			break
+		}
+		if err := b.blockConnected(index, blk); err != nil {
+			log.Error("%s: write block %s failed: %v", b.name, index.GetBlockHash(), err)
+		}
+
+	case chain.NTBlockDisconnected:
+		blk, ok := notification.Data.(*block.Block)
+		if !ok {
+			log.Warn("Chain disconnected notification is not a block.")
+			break
+		}
+		index := chain.GetInstance().FindBlockIndex(blk.GetHash())
+		if index == nil {
+			break
+		}
+		b.blockDisconnected(index)
+	}
+}
+
+type activeChainSource struct{}
+
+func (activeChainSource) TipHeight() int32 {
+	return chain.GetInstance().Height()
+}
+
+func (activeChainSource) BlockAt(height int32) (*blockindex.BlockIndex, *block.Block, error) {
+	gChain := chain.GetInstance()
+	index := gChain.GetIndex(height)
+	if index == nil {
+		return nil, nil, errors.New("block index not in active chain")
+	}
+	blk, ok := disk.ReadBlockFromDisk(index, gChain.GetParams())
+	if !ok {
+		return nil, nil, errors.New("read block from disk failed")
+	}
+	return index, blk, nil
+}
+
+// BlockUndo reads the spent coins of the block from the undo files. The
+// genesis block spends nothing and has no undo data.
+func (activeChainSource) BlockUndo(index *blockindex.BlockIndex) (*undo.BlockUndo, error) {
+	if index.Prev == nil {
+		return undo.NewBlockUndo(0), nil
+	}
+	pos := index.GetUndoPos()
+	if pos.IsNull() {
+		return nil, errors.New("no undo data available")
+	}
+	blockUndo, ok := disk.UndoReadFromDisk(&pos, *index.Prev.GetBlockHash())
+	if !ok {
+		return nil, errors.New("read undo data failed")
+	}
+	return blockUndo, nil
+}
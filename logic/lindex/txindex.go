@@ -0,0 +1,121 @@
+package lindex
+
+import (
+	"errors"
+	"io"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/copernet/copernicus/util"
+)
+
+// TxIndexName is the name the transaction index is reported under by getindexinfo.
+const TxIndexName = "txindex"
+
+// txIndexStore is the persistent storage used by the transaction index, it is
+// satisfied by blkdb.BlockTreeDB.
+type txIndexStore interface {
+	ReadTxIndex(txid *util.Hash) (*block.DiskTxPos, error)
+	WriteTxIndex(txIndexes map[util.Hash]block.DiskTxPos) error
+	ReadTxIndexBestHeight() (int32, error)
+	WriteTxIndexBestHeight(height int32) error
+}
+
+// TxIndex maps transaction ids to their position in the block files. It is
+// built in the background as described on baseIndex.
+type TxIndex struct {
+	baseIndex
+	store txIndexStore
+}
+
+var txIndex *TxIndex
+
+// InitTxIndex creates the transaction index if it was enabled by
+// configuration and starts its background sync.
+func InitTxIndex() {
+	if !conf.Cfg.Chain.TxIndex {
+		return
+	}
+
+	txIndex = newTxIndex(blkdb.GetInstance(), activeChainSource{})
+	chain.GetInstance().Subscribe(txIndex.handleBlockChainNotification)
+	txIndex.Start()
+}
+
+// StopTxIndex stops the transaction index, if it is enabled, so that nothing
+// is written to the block tree database afterwards. It is part of the node
+// shutdown and must be called before the database is closed.
+func StopTxIndex() {
+	if txIndex != nil {
+		txIndex.Stop()
+	}
+}
+
+// GetTxIndex returns the transaction index, or nil if it is not enabled.
+func GetTxIndex() *TxIndex {
+	return txIndex
+}
+
+func newTxIndex(store txIndexStore, source blockSource) *TxIndex {
+	bestHeight, err := store.ReadTxIndexBestHeight()
+	if err != nil {
+		bestHeight = -1
+	}
+	ti := &TxIndex{store: store}
+	ti.init(TxIndexName, bestHeight, ti, source)
+	return ti
+}
+
+// FindTx returns the disk position of the transaction, or nil if the index
+// does not know it.
+func (ti *TxIndex) FindTx(txid *util.Hash) (*block.DiskTxPos, error) {
+	return ti.store.ReadTxIndex(txid)
+}
+
+func (ti *TxIndex) writeBlock(index *blockindex.BlockIndex, blk *block.Block) error {
+	blockPos := index.GetBlockPos()
+	offset := util.VarIntSerializeSize(uint64(len(blk.Txs)))
+	txIndexes := make(map[util.Hash]block.DiskTxPos, len(blk.Txs))
+	for _, transaction := range blk.Txs {
+		txIndexes[transaction.GetHash()] = *block.NewDiskTxPos(&blockPos, offset)
+		offset += transaction.SerializeSize()
+	}
+	return ti.store.WriteTxIndex(txIndexes)
+}
+
+func (ti *TxIndex) writeBestHeight(height int32) error {
+	return ti.store.WriteTxIndexBestHeight(height)
+}
+
+// ReadTx loads the transaction at the given position from the block files,
+// returning it along with the hash of the block it was found in.
+func ReadTx(pos *block.DiskTxPos) (*tx.Tx, *util.Hash, error) {
+	file := disk.OpenBlockFile(pos.BlockIn, true)
+	if file == nil {
+		return nil, nil, errors.New("open block file failed")
+	}
+	defer file.Close()
+
+	// skip the block length prefix written by disk.WriteBlockToDisk
+	if _, err := file.Seek(4, io.SeekCurrent); err != nil {
+		return nil, nil, err
+	}
+	header := block.NewBlockHeader()
+	if err := header.Unserialize(file); err != nil {
+		return nil, nil, err
+	}
+	if _, err := file.Seek(int64(pos.TxOffsetIn), io.SeekCurrent); err != nil {
+		return nil, nil, err
+	}
+	transaction := tx.NewEmptyTx()
+	if err := transaction.Unserialize(file); err != nil {
+		return nil, nil, err
+	}
+	hash := header.GetHash()
+	return transaction, &hash, nil
+}
@@ -0,0 +1,225 @@
+package lindex
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/persist/db"
+	"github.com/copernet/copernicus/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type memTxIndexStore struct {
+	positions  map[util.Hash]block.DiskTxPos
+	bestHeight *int32
+}
+
+func newMemTxIndexStore() *memTxIndexStore {
+	return &memTxIndexStore{positions: make(map[util.Hash]block.DiskTxPos)}
+}
+
+func (s *memTxIndexStore) ReadTxIndex(txid *util.Hash) (*block.DiskTxPos, error) {
+	if pos, ok := s.positions[*txid]; ok {
+		return &pos, nil
+	}
+	return nil, nil
+}
+
+func (s *memTxIndexStore) WriteTxIndex(txIndexes map[util.Hash]block.DiskTxPos) error {
+	for txid, pos := range txIndexes {
+		s.positions[txid] = pos
+	}
+	return nil
+}
+
+func (s *memTxIndexStore) ReadTxIndexBestHeight() (int32, error) {
+	if s.bestHeight == nil {
+		return 0, errors.New("not found")
+	}
+	return *s.bestHeight, nil
+}
+
+func (s *memTxIndexStore) WriteTxIndexBestHeight(height int32) error {
+	s.bestHeight = &height
+	return nil
+}
+
+// gatedBlockSource serves a fixed chain of blocks, each only after the test
+// releases it through the gate channel.
+type gatedBlockSource struct {
+	indexes   []*blockindex.BlockIndex
+	blocks    []*block.Block
+	requested chan int32
+	gate      chan struct{}
+}
+
+func newGatedBlockSource(height int32) *gatedBlockSource {
+	src := &gatedBlockSource{
+		requested: make(chan int32, height+1),
+		gate:      make(chan struct{}),
+	}
+	for h := int32(0); h <= height; h++ {
+		blk := block.NewBlock()
+		blk.Header.Nonce = uint32(h)
+		blk.Txs = append(blk.Txs, tx.NewTx(uint32(h), tx.DefaultVersion))
+		index := blockindex.NewBlockIndex(&blk.Header)
+		index.Height = h
+		src.indexes = append(src.indexes, index)
+		src.blocks = append(src.blocks, blk)
+	}
+	return src
+}
+
+func (src *gatedBlockSource) TipHeight() int32 {
+	return int32(len(src.blocks)) - 1
+}
+
+func (src *gatedBlockSource) BlockAt(height int32) (*blockindex.BlockIndex, *block.Block, error) {
+	src.requested <- height
+	<-src.gate
+	return src.indexes[height], src.blocks[height], nil
+}
+
+func TestTxIndexSyncedAfterBackgroundSync(t *testing.T) {
+	store := newMemTxIndexStore()
+	src := newGatedBlockSource(2)
+	ti := newTxIndex(store, src)
+
+	info := ti.Info()
+	assert.False(t, info.Synced)
+	assert.Equal(t, int32(-1), info.BestBlockHeight)
+
+	ti.Start()
+
+	// release the genesis block only, the index must not claim to be synced
+	assert.Equal(t, int32(0), <-src.requested)
+	src.gate <- struct{}{}
+	assert.Equal(t, int32(1), <-src.requested)
+	info = ti.Info()
+	assert.False(t, info.Synced)
+	assert.Equal(t, int32(0), info.BestBlockHeight)
+
+	close(src.gate)
+	<-ti.done
+
+	info = ti.Info()
+	assert.True(t, info.Synced)
+	assert.Equal(t, int32(2), info.BestBlockHeight)
+
+	for _, blk := range src.blocks {
+		txid := blk.Txs[0].GetHash()
+		pos, err := ti.FindTx(&txid)
+		assert.Nil(t, err)
+		assert.NotNil(t, pos)
+	}
+	best, err := store.ReadTxIndexBestHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), best)
+}
+
+func TestTxIndexBlockConnected(t *testing.T) {
+	store := newMemTxIndexStore()
+	src := newGatedBlockSource(1)
+	ti := newTxIndex(store, src)
+
+	next := newGatedBlockSource(2)
+	nextIndex, nextBlock := next.indexes[2], next.blocks[2]
+
+	// a block connected while syncing is left to the sync task
+	assert.Nil(t, ti.blockConnected(nextIndex, nextBlock))
+	assert.Equal(t, int32(-1), ti.Info().BestBlockHeight)
+
+	close(src.gate)
+	ti.Start()
+	<-ti.done
+	assert.True(t, ti.IsSynced())
+
+	assert.Nil(t, ti.blockConnected(nextIndex, nextBlock))
+	assert.Equal(t, int32(2), ti.Info().BestBlockHeight)
+
+	ti.blockDisconnected(nextIndex)
+	assert.Equal(t, int32(1), ti.Info().BestBlockHeight)
+}
+
+func TestTxIndexStop(t *testing.T) {
+	store := newMemTxIndexStore()
+	src := newGatedBlockSource(1)
+	ti := newTxIndex(store, src)
+
+	close(src.gate)
+	ti.Start()
+	ti.Stop()
+	// stopping twice is harmless
+	ti.Stop()
+
+	// nothing is written to the store once stopped
+	bestHeight := ti.Info().BestBlockHeight
+	next := newGatedBlockSource(bestHeight + 1)
+	nextIndex, nextBlock := next.indexes[bestHeight+1], next.blocks[bestHeight+1]
+	assert.Nil(t, ti.blockConnected(nextIndex, nextBlock))
+	txid := nextBlock.Txs[0].GetHash()
+	pos, err := ti.FindTx(&txid)
+	assert.Nil(t, err)
+	assert.Nil(t, pos)
+
+	if bestHeight >= 0 {
+		ti.blockDisconnected(src.indexes[bestHeight])
+	}
+	assert.Equal(t, bestHeight, ti.Info().BestBlockHeight)
+}
+
+func TestTxIndexBlockTreeDBStore(t *testing.T) {
+	path, err := ioutil.TempDir("", "txindex")
+	assert.Nil(t, err)
+	defer os.RemoveAll(path)
+	blkdb.InitBlockTreeDB(&blkdb.BlockTreeDBConfig{Do: &db.DBOption{
+		FilePath:  path,
+		CacheSize: 1 << 20,
+	}})
+	store := blkdb.GetInstance()
+
+	src := newGatedBlockSource(1)
+	close(src.gate)
+	ti := newTxIndex(store, src)
+	assert.Equal(t, int32(-1), ti.Info().BestBlockHeight)
+	ti.Start()
+	<-ti.done
+	assert.True(t, ti.IsSynced())
+
+	// a restarted index resumes from the height persisted in the database
+	next := newGatedBlockSource(2)
+	close(next.gate)
+	restarted := newTxIndex(store, next)
+	assert.Equal(t, int32(1), restarted.Info().BestBlockHeight)
+	restarted.Start()
+	<-restarted.done
+	assert.Equal(t, int32(2), restarted.Info().BestBlockHeight)
+	assert.Equal(t, []int32{2}, drainRequested(next))
+
+	txid := next.blocks[2].Txs[0].GetHash()
+	pos, err := restarted.FindTx(&txid)
+	assert.Nil(t, err)
+	assert.NotNil(t, pos)
+	unknown := util.Hash{1}
+	pos, err = restarted.FindTx(&unknown)
+	assert.Nil(t, err)
+	assert.Nil(t, pos)
+}
+
+func drainRequested(src *gatedBlockSource) []int32 {
+	var heights []int32
+	for {
+		select {
+		case height := <-src.requested:
+			heights = append(heights, height)
+		default:
+			return heights
+		}
+	}
+}
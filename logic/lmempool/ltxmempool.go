@@ -16,6 +16,7 @@ import (
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
 )
 
 func AcceptTxToMemPool(txn *tx.Tx) error {
@@ -27,6 +28,30 @@ func AcceptTxToMemPool(txn *tx.Tx) error {
 	return addTxToMemPool(txEntry)
 }
 
+// AcceptTxToMemPoolWithMaxFee accepts the transaction into the mempool if its
+// absolute fee is not over maxFee, rather than -maxtxfee. A maxFee of 0 does
+// not limit the fee.
+func AcceptTxToMemPoolWithMaxFee(txn *tx.Tx, maxFee amount.Amount) error {
+	txEntry, err := ltx.CheckTxBeforeAcceptToMemPoolWithMaxFee(txn, maxFee)
+	if err != nil {
+		return err
+	}
+
+	return addTxToMemPool(txEntry)
+}
+
+// AcceptTxToMemPoolBypassFee accepts the transaction into the mempool without
+// holding it to the mempool min fee, the min relay fee still applies. It is
+// meant for the transactions relayed by whitelisted peers.
+func AcceptTxToMemPoolBypassFee(txn *tx.Tx) error {
+	txEntry, err := ltx.CheckTxBeforeAcceptToMemPoolBypassFee(txn)
+	if err != nil {
+		return err
+	}
+
+	return addTxToMemPool(txEntry)
+}
+
 func addTxToMemPool(txe *mempool.TxEntry) error {
 	pool := mempool.GetInstance()
 
@@ -49,6 +74,9 @@ func addTxToMemPool(txe *mempool.TxEntry) error {
 		log.Error("add tx failed:%s", err.Error())
 		return err
 	}
+	txid, poolSize := txe.Tx.GetHash(), pool.GetPoolAllTxSize(false)/1000
+	log.PrintFields("mempool", "debug", log.Fields{"txid": txid.String(), "poolsz": poolSize},
+		"AcceptToMemoryPool: accepted %s (poolsz %d kB)", txid, poolSize)
 
 	// TODO: simple implementation just for testing, remove this after complete wallet
 	if wallet.GetInstance().IsEnable() {
@@ -71,44 +99,77 @@ func TryAcceptOrphansTxs(transaction *tx.Tx, chainHeight int32, checkLockPoint b
 	for len(vWorkQueue) > 0 {
 		prevOut := vWorkQueue[0]
 		vWorkQueue = vWorkQueue[1:]
-		if orphans, ok := pool.OrphanTransactionsByPrev[prevOut]; ok {
-			for _, iOrphanTx := range orphans {
-				fromPeer := iOrphanTx.NodeID
-				if _, ok := setMisbehaving[fromPeer]; ok {
-					continue
-				}
+		for _, iOrphanTx := range pool.OrphansSpending(prevOut) {
+			fromPeer := iOrphanTx.NodeID
+			if _, ok := setMisbehaving[fromPeer]; ok {
+				continue
+			}
 
-				err := AcceptTxToMemPool(iOrphanTx.Tx)
-				if err == nil {
-					acceptTxs = append(acceptTxs, iOrphanTx.Tx)
-					for i := 0; i < iOrphanTx.Tx.GetOutsCount(); i++ {
-						o := outpoint.OutPoint{Hash: iOrphanTx.Tx.GetHash(), Index: uint32(i)}
-						vWorkQueue = append(vWorkQueue, o)
-					}
-					pool.EraseOrphanTx(iOrphanTx.Tx.GetHash(), false)
-					break
+			err := AcceptTxToMemPool(iOrphanTx.Tx)
+			if err == nil {
+				acceptTxs = append(acceptTxs, iOrphanTx.Tx)
+				for i := 0; i < iOrphanTx.Tx.GetOutsCount(); i++ {
+					o := outpoint.OutPoint{Hash: iOrphanTx.Tx.GetHash(), Index: uint32(i)}
+					vWorkQueue = append(vWorkQueue, o)
 				}
+				pool.EraseOrphanTx(iOrphanTx.Tx.GetHash(), false)
+				break
+			}
 
-				if !errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut) {
-					pool.EraseOrphanTx(iOrphanTx.Tx.GetHash(), true)
-					if errcode.IsErrorCode(err, errcode.RejectTx) {
-						rejectTxs = append(rejectTxs, iOrphanTx.Tx.GetHash())
-					}
-					break
+			if !errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut) {
+				pool.EraseOrphanTx(iOrphanTx.Tx.GetHash(), true)
+				if errcode.IsErrorCode(err, errcode.RejectTx) {
+					rejectTxs = append(rejectTxs, iOrphanTx.Tx.GetHash())
 				}
+				break
 			}
 		}
 	}
 	return
 }
 
+// ProcessOrphansForBlock updates the orphan pool for the transactions of a
+// connected block, without the coinbase. The orphans the block confirms or
+// conflicts with are dropped, then the orphans spending outputs of the block
+// are accepted to the mempool when all their inputs are available. It returns
+// the accepted transactions.
+func ProcessOrphansForBlock(txs []*tx.Tx, chainHeight int32) (acceptTxs []*tx.Tx) {
+	pool := mempool.GetInstance()
+	if erased := pool.EraseOrphansForBlock(txs); erased > 0 {
+		log.Debug("Erased %d orphan tx included or conflicted by block", erased)
+	}
+
+	for _, txn := range txs {
+		accepted, _ := TryAcceptOrphansTxs(txn, chainHeight, true)
+		acceptTxs = append(acceptTxs, accepted...)
+	}
+	return
+}
+
+// TrimToSize evicts the transactions of the mempool over the configured max
+// size, lowest fee rate first, and those past the configured expiry.
+func TrimToSize() {
+	pool := mempool.GetInstance()
+	pool.Lock()
+	defer pool.Unlock()
+	pool.LimitMempoolSize(conf.Cfg.MaxPoolSize(), int64(conf.Cfg.Mempool.MaxPoolExpiry)*60*60)
+}
+
 func RemoveTxSelf(txs []*tx.Tx) {
 	pool := mempool.GetInstance()
 	pool.RemoveTxSelf(txs)
 }
+
+// RemoveForReorg rebuilds the mempool after blocks were disconnected, keeping
+// the transactions still valid on top of the new tip. A transaction spending
+// a coinbase which is no longer mature is dropped, and so are its
+// descendants: they are kept as orphans while the mempool is rebuilt, which
+// are discarded at the end. The spenders of the coinbases of the disconnected
+// blocks were already removed by DisconnectTip.
 func RemoveForReorg(nMemPoolHeight int32, flag int) {
 	newPool := mempool.NewTxMempool()
 	oldPool := mempool.GetInstance()
+	newPool.SetRelayFees(oldPool.MinRelayTxFee(), oldPool.IncrementalRelayFee())
 	log.Debug("RemoveForReorg start")
 	mempool.SetInstance(newPool)
 	for _, txentry := range oldPool.GetAllTxEntry() {
@@ -245,7 +306,7 @@ func CheckMempool(bestHeight int32) {
 	spentOut := pool.GetAllSpentOutWithoutLock()
 	//bestHash, _ := view.GetBestBlock()
 	//bestHeigh := activaChain.FindBlockIndex(bestHash).Height + 1
-	log.Debug("mempool", fmt.Sprintf("checking mempool with %d transaction and %d inputs ...", len(allEntry), len(spentOut)))
+	log.Print("mempool", "debug", "checking mempool with %d transaction and %d inputs ...", len(allEntry), len(spentOut))
 	checkTotal := uint64(0)
 
 	waitingOnDependants := list.New()
@@ -415,11 +476,5 @@ func FindTxInMempool(hash util.Hash) *mempool.TxEntry {
 
 func FindOrphanTxInMemPool(hash util.Hash) *tx.Tx {
 	pool := mempool.GetInstance()
-	pool.RLock()
-	defer pool.RUnlock()
-	if orphan, ok := pool.OrphanTransactions[hash]; ok {
-		return orphan.Tx
-	}
-
-	return nil
+	return pool.FindOrphanTx(hash)
 }
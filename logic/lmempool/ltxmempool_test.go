@@ -59,27 +59,6 @@ type fakeChain struct {
 	medianTimePast time.Time
 }
 
-func (s *fakeChain) FetchUtxoView(tx *tx.Tx) (*utxo.CoinsMap, error) {
-	s.RLock()
-	defer s.RUnlock()
-
-	viewpoint := utxo.NewEmptyCoinsMap()
-	prevOut := &outpoint.OutPoint{Hash: tx.GetHash()}
-	for txOutIdx := 0; txOutIdx < tx.GetOutsCount(); txOutIdx++ {
-		prevOut.Index = uint32(txOutIdx)
-		entry := s.utxos.GetCoin(prevOut)
-		viewpoint.GetMap()[*prevOut] = entry.DeepCopy()
-	}
-
-	for txInIdx := 0; txInIdx < tx.GetInsCount(); txInIdx++ {
-		txIn := tx.GetTxIn(txInIdx)
-		entry := s.utxos.GetCoin(txIn.PreviousOutPoint)
-		viewpoint.GetMap()[*txIn.PreviousOutPoint] = entry.DeepCopy()
-	}
-
-	return viewpoint, nil
-}
-
 func (s *fakeChain) BestHeight() int32 {
 	s.RLock()
 	height := s.currentHeight
@@ -0,0 +1,39 @@
+package lmempool
+
+import (
+	"errors"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/util"
+)
+
+var errExpired = errors.New("transaction expired from the mempool")
+
+// LoadMempool accepts again the transactions a previous run saved to path,
+// skipping those that expired meanwhile, then marks the mempool loaded.
+func LoadMempool(path string) {
+	pool := mempool.GetInstance()
+	defer pool.SetLoaded(true)
+
+	expiry := util.GetTimeSec() - int64(conf.Cfg.Mempool.MaxPoolExpiry)*60*60
+	expired, failed := 0, 0
+	accepted, err := mempool.Load(path, func(txn *tx.Tx, entryTime int64) error {
+		if entryTime < expiry {
+			expired++
+			return errExpired
+		}
+		err := AcceptTxToMemPool(txn)
+		if err != nil {
+			failed++
+		}
+		return err
+	})
+	if err != nil {
+		log.Error("Failed to load mempool from %s: %v", path, err)
+	}
+	log.Info("Imported mempool transactions from disk: %d succeeded, %d failed, %d expired",
+		accepted, failed, expired)
+}
@@ -158,6 +158,51 @@ func BlockMerkleBranch(txs []*tx.Tx, position uint32) []util.Hash {
 	return ComputeMerkleBranch(leaves, position)
 }
 
+// MerkleBuilder computes a merkle root from leaves given one at a time, in
+// constant space, so that a block read as a stream does not have to keep the
+// hashes of all its transactions. The zero value is an empty tree.
+type MerkleBuilder struct {
+	count   uint32
+	inner   [32]util.Hash
+	mutated bool
+}
+
+// Add appends a leaf to the tree.
+func (b *MerkleBuilder) Add(h util.Hash) {
+	b.count++
+	level := uint(0)
+	for ; b.count&(1<<level) == 0; level++ {
+		if b.inner[level].IsEqual(&h) {
+			b.mutated = true
+		}
+		h = *HashMerkleBranches(&b.inner[level], &h)
+	}
+	b.inner[level] = h
+}
+
+// Root returns the merkle root of the leaves added so far, and whether the
+// tree is mutated, as ComputeMerkleRoot does.
+func (b *MerkleBuilder) Root() (util.Hash, bool) {
+	if b.count == 0 {
+		return util.Hash{}, false
+	}
+	count := b.count
+	level := uint(0)
+	for ; count&(1<<level) == 0; level++ {
+	}
+	h := b.inner[level]
+	for count != 1<<level {
+		// the odd node of a level is hashed with itself
+		h = *HashMerkleBranches(&h, &h)
+		count += 1 << level
+		level++
+		for ; count&(1<<level) == 0; level++ {
+			h = *HashMerkleBranches(&b.inner[level], &h)
+		}
+	}
+	return h, b.mutated
+}
+
 func HashMerkleBranches(left *util.Hash, right *util.Hash) *util.Hash {
 	var hash [util.Hash256Size * 2]byte
 	copy(hash[:util.Hash256Size], left[:])
@@ -209,3 +209,32 @@ func min(a, b int) int {
 
 	return b
 }
+
+func TestMerkleBuilder(t *testing.T) {
+	for n := 0; n <= 40; n++ {
+		leaves := make([]util.Hash, n)
+		var builder lmerkleroot.MerkleBuilder
+		for i := range leaves {
+			leaves[i] = util.DoubleSha256Hash([]byte{byte(i)})
+			builder.Add(leaves[i])
+		}
+		var mutated bool
+		expected := lmerkleroot.ComputeMerkleRoot(leaves, &mutated)
+		root, builderMutated := builder.Root()
+		if root != expected || builderMutated != mutated {
+			t.Errorf("%d leaves: builder root %s mutated %v, expected %s mutated %v",
+				n, root, builderMutated, expected, mutated)
+		}
+	}
+
+	// a repeated pair of leaves is a mutated tree
+	var builder lmerkleroot.MerkleBuilder
+	leaf := util.DoubleSha256Hash([]byte{1})
+	builder.Add(util.DoubleSha256Hash([]byte{0}))
+	builder.Add(leaf)
+	builder.Add(leaf)
+	builder.Add(leaf)
+	if _, mutated := builder.Root(); !mutated {
+		t.Errorf("expect repeated leaves to mutate the tree")
+	}
+}
@@ -0,0 +1,42 @@
+package lmerkleroot
+
+import (
+	"errors"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// ErrProofIndexOutOfRange indicates a proof was requested for a position
+// outside of the leaves.
+var ErrProofIndexOutOfRange = errors.New("merkle proof index out of range")
+
+// Proof proves that a transaction is at a position of the merkle tree of a
+// block, with the hashes of the siblings on the path from the leaf to the
+// root, lowest level first.
+type Proof struct {
+	Index  uint32
+	Branch []util.Hash
+}
+
+// BuildProof returns the proof for the leaf at index of the tree of txids,
+// built like the merkle root of a block.
+func BuildProof(txids []util.Hash, index int) (*Proof, error) {
+	if index < 0 || index >= len(txids) {
+		return nil, ErrProofIndexOutOfRange
+	}
+	return &Proof{
+		Index:  uint32(index),
+		Branch: ComputeMerkleBranch(txids, uint32(index)),
+	}, nil
+}
+
+// Verify reports whether the proof shows txid to be in the tree with the
+// root.
+func (p *Proof) Verify(txid, root util.Hash) bool {
+	// An index with bits above the height of the branch would be ignored
+	// when hashing, letting one proof pass for several positions.
+	if len(p.Branch) < 32 && p.Index>>uint(len(p.Branch)) != 0 {
+		return false
+	}
+	return ComputeMerkleRootFromBranch(&txid, p.Branch, p.Index) == root
+}
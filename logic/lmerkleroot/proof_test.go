@@ -0,0 +1,83 @@
+package lmerkleroot_test
+
+import (
+	"testing"
+
+	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/util"
+)
+
+func proofTxIDs(n int) []util.Hash {
+	txids := make([]util.Hash, n)
+	for i := range txids {
+		txids[i] = util.DoubleSha256([]byte{byte(i)})
+	}
+	return txids
+}
+
+func TestBuildProof(t *testing.T) {
+	txids := proofTxIDs(5)
+	root := lmerkleroot.ComputeMerkleRoot(txids, nil)
+
+	for i, txid := range txids {
+		proof, err := lmerkleroot.BuildProof(txids, i)
+		if err != nil {
+			t.Fatalf("proof for position %d: %v", i, err)
+		}
+		// a tree of 5 leaves is 3 levels high
+		if len(proof.Branch) != 3 {
+			t.Errorf("proof for position %d has %d hashes, expected 3", i, len(proof.Branch))
+		}
+		if !proof.Verify(txid, root) {
+			t.Errorf("proof for position %d does not verify", i)
+		}
+		if proof.Verify(txids[(i+1)%len(txids)], root) {
+			t.Errorf("proof for position %d verifies another txid", i)
+		}
+	}
+
+	single, err := lmerkleroot.BuildProof(txids[:1], 0)
+	if err != nil || len(single.Branch) != 0 || !single.Verify(txids[0], txids[0]) {
+		t.Errorf("proof in a single leaf tree should be empty and verify against the leaf")
+	}
+
+	for _, index := range []int{-1, 5} {
+		if _, err := lmerkleroot.BuildProof(txids, index); err != lmerkleroot.ErrProofIndexOutOfRange {
+			t.Errorf("proof for position %d returned %v, expected ErrProofIndexOutOfRange", index, err)
+		}
+	}
+	if _, err := lmerkleroot.BuildProof(nil, 0); err != lmerkleroot.ErrProofIndexOutOfRange {
+		t.Errorf("proof in an empty tree returned %v, expected ErrProofIndexOutOfRange", err)
+	}
+}
+
+func TestTamperedProof(t *testing.T) {
+	txids := proofTxIDs(5)
+	root := lmerkleroot.ComputeMerkleRoot(txids, nil)
+
+	proof, err := lmerkleroot.BuildProof(txids, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := &lmerkleroot.Proof{Index: proof.Index, Branch: append([]util.Hash(nil), proof.Branch...)}
+	tampered.Branch[1][0] ^= 1
+	if tampered.Verify(txids[2], root) {
+		t.Errorf("proof with a modified hash verifies")
+	}
+
+	moved := &lmerkleroot.Proof{Index: 3, Branch: proof.Branch}
+	if moved.Verify(txids[2], root) {
+		t.Errorf("proof with a modified index verifies")
+	}
+
+	// the same path with an index above the tree height
+	aliased := &lmerkleroot.Proof{Index: proof.Index + 8, Branch: proof.Branch}
+	if aliased.Verify(txids[2], root) {
+		t.Errorf("proof with an index outside the tree verifies")
+	}
+
+	if proof.Verify(txids[2], util.DoubleSha256(root[:])) {
+		t.Errorf("proof verifies against another root")
+	}
+}
@@ -2,11 +2,14 @@ package lreindex
 
 import (
 	"container/list"
+	"errors"
+	"fmt"
 	"github.com/copernet/copernicus/conf"
-	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lblock"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
 	"github.com/copernet/copernicus/model/blockindex"
 	"github.com/copernet/copernicus/model/chain"
@@ -14,11 +17,17 @@ import (
 	"github.com/copernet/copernicus/persist/blkdb"
 	"github.com/copernet/copernicus/persist/disk"
 	"github.com/copernet/copernicus/util"
-	"io"
 	"os"
 	"time"
 )
 
+// errEndOfBlocks is returned by readBlockAt past the last block of a file,
+// where the file ends or its pre-allocated zeros start.
+var errEndOfBlocks = errors.New("end of blocks in file")
+
+// Reindex rebuilds the block index and the UTXO set from the block files in
+// the blocks directory, then activates the best chain they connect to. A
+// corrupt block ends the scan of its file, the blocks before it are kept.
 func Reindex() (err error) {
 	blkFiles, err := disk.GetBlkFiles()
 	if err != nil {
@@ -32,11 +41,7 @@ func Reindex() (err error) {
 		dbp := block.NewDiskBlockPos(int32(index), uint32(0))
 		_, err := loadExternalBlockFile(filePath, dbp)
 		if err != nil {
-			if err.Error() == io.EOF.Error() {
-				log.Info("have read full data from file<%s>, next", filePath)
-			} else {
-				log.Error("When reindex, load block file <%s> failed!", filePath)
-			}
+			log.Error("When reindex, load block file <%s> failed!", filePath)
 		}
 	}
 
@@ -69,17 +74,20 @@ func loadExternalBlockFile(filePath string, dbp *block.DiskBlockPos) (nLoaded in
 	}
 
 	fileSize := uint32(fileInfo.Size())
-	for dbp.Pos < fileSize+1 {
-		log.Info("pos: %d", dbp.Pos)
+	for {
 		var blk *block.Block
-		blk, err = disk.ReadBlockFromDiskByPos(*dbp, params)
+		var size uint32
+		blk, size, err = readBlockAt(*dbp, fileSize, params)
+		if err == errEndOfBlocks {
+			log.Debug("read to the end of file")
+			err = nil
+			break
+		}
 		if err != nil {
-			if err.Error() == io.EOF.Error() {
-				log.Debug("read to the end of file")
-				break
-			}
-			log.Error("fail to read block from pos<%d, %d>", dbp.File, dbp.Pos)
-			return nLoaded, errcode.New(errcode.FailedToReadBlock)
+			log.Error("corrupt block at pos<%d, %d>: %s, skipping the rest of file %s",
+				dbp.File, dbp.Pos, err, filePath)
+			err = nil
+			break
 		}
 
 		blkHash := blk.GetHash()
@@ -96,7 +104,7 @@ func loadExternalBlockFile(filePath string, dbp *block.DiskBlockPos) (nLoaded in
 				}
 				mapBlocksUnknownParent[blkPreHash].PushBack(*dbp)
 			}
-			dbp.Pos = dbp.Pos + uint32(blk.EncodeSize()) + 4
+			dbp.Pos += size
 			continue
 
 		}
@@ -104,14 +112,15 @@ func loadExternalBlockFile(filePath string, dbp *block.DiskBlockPos) (nLoaded in
 		if blkIndex := mchain.FindBlockIndex(blkHash); blkIndex == nil || !blkIndex.HasData() {
 			persist.CsMain.Lock()
 			fNewBlock := false
-			_, _, err = lblock.AcceptBlock(blk, true, dbp, &fNewBlock)
-			if err != nil {
-				log.Error("accept block error: %s", err)
-				break
+			_, _, acceptErr := lblock.AcceptBlock(blk, true, dbp, &fNewBlock)
+			persist.CsMain.Unlock()
+			if acceptErr != nil {
+				log.Error("accept block error: %s", acceptErr)
+				dbp.Pos += size
+				continue
 			}
 
 			nLoaded++
-			persist.CsMain.Unlock()
 			log.Debug("already accept block: %s", blk.Header.Hash.String())
 			//		} else if blkIndex := mchain.FindBlockIndex(blkHash); blkHash != *params.GenesisHash && blkIndex.Height%1000 == 0 {
 		} else {
@@ -164,7 +173,7 @@ func loadExternalBlockFile(filePath string, dbp *block.DiskBlockPos) (nLoaded in
 			}
 		}
 
-		dbp.Pos = dbp.Pos + uint32(blk.EncodeSize()) + 4
+		dbp.Pos += size
 	}
 
 	log.Info("end-pos: %d", dbp.Pos)
@@ -177,6 +186,40 @@ func loadExternalBlockFile(filePath string, dbp *block.DiskBlockPos) (nLoaded in
 	return nLoaded, err
 }
 
+// readBlockAt reads the block at pos of a block file of fileSize bytes. It
+// returns the block along with the bytes it takes in the file, its length
+// prefix included. The length must fit in the file, and the block must have
+// a valid proof of work and match its merkle root.
+func readBlockAt(pos block.DiskBlockPos, fileSize uint32, params *model.BitcoinParams) (*block.Block, uint32, error) {
+	if fileSize < 4 || pos.Pos > fileSize-4 {
+		return nil, 0, errEndOfBlocks
+	}
+	length, err := disk.ReadBlockSize(pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length == 0 {
+		return nil, 0, errEndOfBlocks
+	}
+	if length > fileSize-pos.Pos-4 || length > persist.MaxBlockFileSize {
+		return nil, 0, fmt.Errorf("block length %d does not fit in the file", length)
+	}
+
+	blk, err := disk.ReadBlockFromDiskByPos(pos, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint32(blk.SerializeSize()) != length {
+		return nil, 0, fmt.Errorf("block of %d bytes stored with length %d",
+			blk.SerializeSize(), length)
+	}
+	mutated := false
+	if lmerkleroot.BlockMerkleRoot(blk.Txs, &mutated) != blk.Header.MerkleRoot || mutated {
+		return nil, 0, errors.New("block does not match its merkle root")
+	}
+	return blk, length + 4, nil
+}
+
 func UnloadBlockIndex() {
 	persist.CsMain.Lock()
 	defer persist.CsMain.Unlock()
@@ -41,11 +41,19 @@ func VerifyScript(transaction *tx.Tx, scriptSig *script.Script, scriptPubKey *sc
 		return errcode.New(errcode.ScriptErrEvalFalse)
 	}
 
+	// Additional validation for spend-to-script-hash transactions: the
+	// scriptSig pushes the serialized redeem script last, which is then run
+	// against the rest of the pushes. The redeem script is run by EvalScript
+	// alone, so a redeem script which is itself P2SH only has its hash checked
+	// and is not evaluated again.
 	if flags&script.ScriptVerifyP2SH == script.ScriptVerifyP2SH && scriptPubKey.IsPayToScriptHash() {
+		// scriptSig must be literals-only or validation fails
 		if !scriptSig.IsPushOnly() {
-			log.Debug("ScriptErrScriptSigNotPushOnly")
+			log.Debug("ScriptErrSigPushOnly")
 			return errcode.New(errcode.ScriptErrSigPushOnly)
 		}
+		// stackCopy cannot be empty here, as the HASH160 <hash> EQUAL
+		// scriptPubKey above would have failed on an empty stack.
 		util.Swap(stack, stackCopy)
 		topBytes := stack.Top(-1)
 		stack.Pop()
@@ -122,7 +130,7 @@ func EvalScript(stack *util.Stack, s *script.Script, transaction *tx.Tx, nIn int
 			fExec = false
 		}
 		if len(e.Data) > script.MaxScriptElementSize {
-			log.Debug("ScriptErrElementSize")
+			log.Debug("ScriptErrPushSize")
 			return errcode.New(errcode.ScriptErrPushSize)
 		}
 
@@ -229,7 +237,6 @@ func EvalScript(stack *util.Stack, s *script.Script, transaction *tx.Tx, nIn int
 					return errcode.New(errcode.ScriptErrInvalidStackOperation)
 				}
 				nLocktime, err := script.GetScriptNum(topBytes.([]byte), fRequireMinimal, 5)
-				//nLocktime, err := script.GetScriptNum(topBytes.([]byte), true, 5)
 				if err != nil {
 					return err
 				}
@@ -271,7 +278,6 @@ func EvalScript(stack *util.Stack, s *script.Script, transaction *tx.Tx, nIn int
 					return errcode.New(errcode.ScriptErrInvalidStackOperation)
 				}
 				nSequence, err := script.GetScriptNum(topBytes.([]byte), fRequireMinimal, 5)
-				//nSequence, err := script.GetScriptNum(topBytes.([]byte), true, 5)
 				if err != nil {
 					return err
 				}
@@ -1565,7 +1571,7 @@ func EvalScript(stack *util.Stack, s *script.Script, transaction *tx.Tx, nIn int
 				return errcode.New(errcode.ScriptErrBadOpCode)
 			}
 		}
-		if stack.Size()+stackAlt.Size() > 1000 {
+		if stack.Size()+stackAlt.Size() > script.MaxStackSize {
 			log.Debug("ScriptErrStackSize")
 			return errcode.New(errcode.ScriptErrStackSize)
 		}
@@ -6,8 +6,11 @@ import (
 	"github.com/detailyang/go-bcrypto"
 	"testing"
 
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
 	"github.com/copernet/copernicus/errcode"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/chain"
 	. "github.com/copernet/copernicus/model/opcodes"
 	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/model/script"
@@ -1275,3 +1278,93 @@ func TestScriptuint32(t *testing.T) {
 	}
 
 }
+
+func TestCheckDataSigActivation(t *testing.T) {
+	if conf.Args == nil {
+		conf.Args = new(conf.Opts)
+	}
+
+	key := crypto.NewPrivateKeyFromBytes(key0bytes[:], true)
+	message := []byte("copernicus")
+	hash := util.Sha256Hash(message)
+	sig, err := key.Sign(hash[:])
+	if err != nil {
+		t.Fatalf("sign message failed: %v", err)
+	}
+
+	scriptPubKey := NewScriptBuilder().
+		PushBytesWithOP(key.PubKey().ToBytes()).
+		PushOPCode(OP_CHECKDATASIG).Script()
+	scriptSig := NewScriptBuilder().
+		PushBytesWithOP(sig.Serialize()).
+		PushBytesWithOP(message).Script()
+
+	activation := model.ActiveNetParams.MagneticAnomalyActivationTime
+	height := model.ActiveNetParams.DAAHeight
+
+	// mempool acceptance checks against the flags of the next block
+	before := uint32(script.StandardScriptVerifyFlags) |
+		chain.GetScriptFlags(model.ActiveNetParams, height, activation-1)&uint32(script.ScriptUpgradeFlags)
+	DoTest(t, scriptPubKey, scriptSig, before,
+		"OP_CHECKDATASIG before activation", errcode.ScriptErrBadOpCode, 0)
+
+	after := uint32(script.StandardScriptVerifyFlags) |
+		chain.GetScriptFlags(model.ActiveNetParams, height, activation)&uint32(script.ScriptUpgradeFlags)
+	DoTest(t, scriptPubKey, scriptSig, after,
+		"OP_CHECKDATASIG after activation", 0, 0)
+}
+
+func TestStrictEncodingUnderMempoolFlags(t *testing.T) {
+	if conf.Args == nil {
+		conf.Args = new(conf.Opts)
+	}
+
+	// mempool acceptance checks against the flags of the next block, blocks
+	// mined between the UAHF and the DAA upgrade did not enforce low S yet.
+	mempoolFlags := uint32(script.StandardScriptVerifyFlags) |
+		chain.GetScriptFlags(model.ActiveNetParams, model.ActiveNetParams.DAAHeight, 0)&uint32(script.ScriptUpgradeFlags)
+	oldBlockFlags := chain.GetScriptFlags(model.ActiveNetParams, model.ActiveNetParams.UAHFHeight, 0)
+
+	key := crypto.NewPrivateKeyFromBytes(key0bytes[:], true)
+	hashType := uint32(crypto.SigHashAll | crypto.SigHashForkID)
+
+	sign := func(pubKey []byte) (*script.Script, []byte) {
+		scriptPubKey := NewScriptBuilder().PushBytesWithOP(pubKey).PushOPCode(OP_CHECKSIG).Script()
+		spendTx := NewSpendingTransaction(script.NewEmptyScript(), NewCreditingTransaction(scriptPubKey, 0))
+		hash, err := tx.SignatureHash(spendTx, scriptPubKey, hashType, 0, 0, mempoolFlags)
+		if err != nil {
+			t.Fatalf("signature hash failed: %v", err)
+		}
+		sig, err := key.Sign(hash[:])
+		if err != nil {
+			t.Fatalf("sign failed: %v", err)
+		}
+		return scriptPubKey, sig.Serialize()
+	}
+	scriptSig := func(sig []byte) *script.Script {
+		return NewScriptBuilder().PushBytesWithOP(append(clone(sig), byte(hashType))).Script()
+	}
+
+	scriptPubKey, sig := sign(key.PubKey().ToBytes())
+	DoTest(t, scriptPubKey, scriptSig(sig), mempoolFlags, "P2PK with canonical signature", 0, 0)
+
+	highS := negateSigantureS(sig)
+	DoTest(t, scriptPubKey, scriptSig(highS), mempoolFlags,
+		"P2PK with high S", errcode.ScriptErrSigHighs, 0)
+	err := VerifyScript(NewSpendingTransaction(scriptSig(highS), NewCreditingTransaction(scriptPubKey, 0)),
+		scriptSig(highS), scriptPubKey, 0, 0, oldBlockFlags, NewScriptRealChecker())
+	if errcode.IsErrorCode(err, errcode.ScriptErrSigHighs) {
+		t.Errorf("P2PK with high S must not be rejected for low S before the DAA upgrade")
+	}
+
+	badLength := clone(sig)
+	badLength[1]++
+	DoTest(t, scriptPubKey, scriptSig(badLength), mempoolFlags,
+		"P2PK with non-canonical DER length", errcode.ScriptErrSigDer, 0)
+
+	badPubKey := clone(key.PubKey().ToBytes())
+	badPubKey[0] = 0x05
+	scriptPubKey, sig = sign(badPubKey)
+	DoTest(t, scriptPubKey, scriptSig(sig), mempoolFlags,
+		"P2PK with bad pubkey prefix", errcode.ScriptErrPubKeyType, 0)
+}
@@ -504,6 +504,168 @@ func TestScriptCHECKMULTISIG23(t *testing.T) {
 	}
 }
 
+func payToScriptHash(redeemScript *script.Script) *script.Script {
+	s := script.NewEmptyScript()
+	s.PushOpCode(opcodes.OP_HASH160)
+	s.PushSingleData(util.Hash160(redeemScript.GetData()))
+	s.PushOpCode(opcodes.OP_EQUAL)
+	return s
+}
+
+func TestScriptP2SHMultisig(t *testing.T) {
+	var flag uint32 = script.ScriptVerifyP2SH | script.ScriptVerifyStrictEnc
+	key1 := NewPrivateKey()
+	key2 := NewPrivateKey()
+	key3 := NewPrivateKey()
+	redeemScript := script.NewEmptyScript()
+	redeemScript.PushOpCode(opcodes.OP_2)
+	redeemScript.PushSingleData(key1.PubKey().ToBytes())
+	redeemScript.PushSingleData(key2.PubKey().ToBytes())
+	redeemScript.PushSingleData(key3.PubKey().ToBytes())
+	redeemScript.PushOpCode(opcodes.OP_3)
+	redeemScript.PushOpCode(opcodes.OP_CHECKMULTISIG)
+	scriptPubKey := payToScriptHash(redeemScript)
+
+	var txFrom, txTo tx.Tx
+	txFrom.AddTxOut(txout.NewTxOut(0, scriptPubKey))
+	txTo.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(txFrom.GetHash(), 0),
+		script.NewEmptyScript(), script.SequenceFinal))
+
+	goodsig := signMultisig(redeemScript, []crypto.PrivateKey{key1, key3}, &txTo)
+	goodsig.PushSingleData(redeemScript.GetData())
+	if err := VerifyScript(&txTo, goodsig, scriptPubKey, 0, 0, flag, NewScriptRealChecker()); err != nil {
+		t.Errorf("P2SH multisig spend fail: %v", err)
+	}
+
+	badsig := signMultisig(redeemScript, []crypto.PrivateKey{key1, key1}, &txTo)
+	badsig.PushSingleData(redeemScript.GetData())
+	if err := VerifyScript(&txTo, badsig, scriptPubKey, 0, 0, flag, NewScriptRealChecker()); err == nil {
+		t.Errorf("P2SH multisig spend should fail, sk = key11, pk = key123")
+	}
+
+	// The scriptSig still leaves the redeem script on top of the stack, but
+	// is not push only.
+	notPushOnly := signMultisig(redeemScript, []crypto.PrivateKey{key1, key3}, &txTo)
+	notPushOnly.PushSingleData(redeemScript.GetData())
+	notPushOnly.PushOpCode(opcodes.OP_DUP)
+	notPushOnly.PushOpCode(opcodes.OP_DROP)
+	err := VerifyScript(&txTo, notPushOnly, scriptPubKey, 0, 0, flag, NewScriptRealChecker())
+	if !errcode.IsErrorCode(err, errcode.ScriptErrSigPushOnly) {
+		t.Errorf("expect error %v, got %v", errcode.ScriptErrSigPushOnly, err)
+	}
+	// Without P2SH only the hash of the redeem script is checked.
+	err = VerifyScript(&txTo, notPushOnly, scriptPubKey, 0, 0, script.ScriptVerifyNone, NewScriptRealChecker())
+	if err != nil {
+		t.Errorf("non P2SH spend fail: %v", err)
+	}
+}
+
+func TestScriptP2SHNotRecursive(t *testing.T) {
+	// The inner script fails if run, so the spend is only valid because the
+	// redeem script, itself P2SH, is not evaluated as P2SH again.
+	inner := script.NewScriptRaw([]byte{opcodes.OP_0})
+	redeemScript := payToScriptHash(inner)
+	scriptPubKey := payToScriptHash(redeemScript)
+
+	scriptSig := script.NewEmptyScript()
+	scriptSig.PushSingleData(inner.GetData())
+	scriptSig.PushSingleData(redeemScript.GetData())
+	if err := VerifyScript(nil, scriptSig, scriptPubKey, 0, 0, script.ScriptVerifyP2SH,
+		NewScriptRealChecker()); err != nil {
+		t.Errorf("nested P2SH spend fail: %v", err)
+	}
+}
+
+func TestScriptLimits(t *testing.T) {
+	repeat := func(op byte, n int) *script.Script {
+		return script.NewScriptRaw(bytes.Repeat([]byte{op}, n))
+	}
+	pushOf := func(n int) *script.Script {
+		s := script.NewEmptyScript()
+		s.PushSingleData(bytes.Repeat([]byte{0x01}, n))
+		return s
+	}
+	tests := []struct {
+		name string
+		s    *script.Script
+		err  errcode.ScriptErr
+	}{
+		{"520-byte push", pushOf(script.MaxScriptElementSize), 0},
+		{"521-byte push", pushOf(script.MaxScriptElementSize + 1), errcode.ScriptErrPushSize},
+		{"201 ops", repeat(opcodes.OP_NOP, script.MaxOpsPerScript), 0},
+		{"202 ops", repeat(opcodes.OP_NOP, script.MaxOpsPerScript+1), errcode.ScriptErrOpCount},
+		{"full stack", repeat(opcodes.OP_1, script.MaxStackSize), 0},
+		{"overflowing stack", repeat(opcodes.OP_1, script.MaxStackSize+1), errcode.ScriptErrStackSize},
+		{"overflowing alt stack", script.NewScriptRaw(append(bytes.Repeat([]byte{opcodes.OP_1}, script.MaxStackSize),
+			opcodes.OP_TOALTSTACK, opcodes.OP_1, opcodes.OP_1)), errcode.ScriptErrStackSize},
+		{"oversized script", repeat(opcodes.OP_1, script.MaxScriptSize+1), errcode.ScriptErrScriptSize},
+	}
+
+	transaction := tx.NewTx(0, 1)
+	for _, test := range tests {
+		err := EvalScript(util.NewStack(), test.s, transaction, 0, 0, script.ScriptVerifyNone, NewScriptRealChecker())
+		if test.err == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if !errcode.IsErrorCode(err, test.err) {
+			t.Errorf("%s: expect error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestScriptConditionals(t *testing.T) {
+	tests := []struct {
+		name         string
+		scriptSig    string
+		scriptPubKey string
+		flags        uint32
+		err          errcode.ScriptErr
+	}{
+		{"balanced nesting", "1", "IF 0 NOTIF 1 IF 1 ELSE 0 ENDIF ELSE 0 ENDIF ELSE 0 ENDIF",
+			script.ScriptVerifyNone, 0},
+		{"nested in unexecuted branch", "0", "IF 1 IF 0 ENDIF ELSE 1 ENDIF", script.ScriptVerifyNone, 0},
+		{"unbalanced IF", "1", "IF 1", script.ScriptVerifyNone, errcode.ScriptErrUnbalancedConditional},
+		{"unbalanced nested IF", "1", "IF 1 IF 1 ENDIF", script.ScriptVerifyNone,
+			errcode.ScriptErrUnbalancedConditional},
+		{"ELSE without IF", "1", "ELSE 1 ENDIF", script.ScriptVerifyNone, errcode.ScriptErrUnbalancedConditional},
+		{"ENDIF without IF", "1", "ENDIF 1", script.ScriptVerifyNone, errcode.ScriptErrUnbalancedConditional},
+		{"IF on empty stack", "", "IF 1 ENDIF", script.ScriptVerifyNone, errcode.ScriptErrUnbalancedConditional},
+		{"minimal true", "0x01 0x01", "IF 1 ENDIF 1", script.ScriptVerifyMinimalIf, 0},
+		{"minimal false", "0", "NOTIF 1 ENDIF 1", script.ScriptVerifyMinimalIf, 0},
+		{"non-minimal true", "0x01 0x02", "IF 1 ENDIF 1", script.ScriptVerifyMinimalIf, errcode.ScriptErrMinimalIf},
+		{"non-minimal false", "0x01 0x00", "NOTIF 1 ENDIF 1", script.ScriptVerifyMinimalIf,
+			errcode.ScriptErrMinimalIf},
+		{"two-byte condition", "0x02 0x0100", "IF 1 ENDIF 1", script.ScriptVerifyMinimalIf,
+			errcode.ScriptErrMinimalIf},
+		{"non-minimal without MINIMALIF", "0x01 0x02", "IF 1 ENDIF 1", script.ScriptVerifyNone, 0},
+	}
+
+	for _, test := range tests {
+		scriptSig, err := parseScriptFrom(test.scriptSig, opMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptPubKey, err := parseScriptFrom(test.scriptPubKey, opMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = VerifyScript(nil, script.NewScriptRaw(scriptSig), script.NewScriptRaw(scriptPubKey),
+			0, 0, test.flags, NewScriptRealChecker())
+		if test.err == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if !errcode.IsErrorCode(err, test.err) {
+			t.Errorf("%s: expect error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
 func TestScriptPushData(t *testing.T) {
 	direct := []byte{1, 0x5a}
 	pushdata1 := []byte{opcodes.OP_PUSHDATA1, 1, 0x5a}
@@ -559,3 +721,128 @@ func TestIsPushOnlyOnInvalidScripts(t *testing.T) {
 		t.Errorf("IsPushOnly should return false on invalid scripts")
 	}
 }
+
+func evalLockScript(lock int64, op int, lockTime uint32, sequence uint32, version int32, flags uint32) error {
+	transaction := tx.NewTx(lockTime, version)
+	transaction.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(util.Hash{}, 0), script.NewEmptyScript(), sequence))
+
+	s := script.NewEmptyScript()
+	s.PushInt64(lock)
+	s.PushOpCode(op)
+	return EvalScript(util.NewStack(), s, transaction, 0, 0, flags, NewScriptRealChecker())
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		lock     int64
+		lockTime uint32
+		sequence uint32
+		flags    uint32
+		err      errcode.ScriptErr
+	}{
+		{"height satisfied", 100, 100, 0, script.ScriptVerifyCheckLockTimeVerify, 0},
+		{"height unsatisfied", 101, 100, 0, script.ScriptVerifyCheckLockTimeVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"time satisfied", script.LockTimeThreshold, script.LockTimeThreshold + 1, 0,
+			script.ScriptVerifyCheckLockTimeVerify, 0},
+		{"time against height", script.LockTimeThreshold, 100, 0,
+			script.ScriptVerifyCheckLockTimeVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"final sequence", 100, 100, script.SequenceFinal,
+			script.ScriptVerifyCheckLockTimeVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"negative lock", -1, 100, 0, script.ScriptVerifyCheckLockTimeVerify, errcode.ScriptErrNegativeLockTime},
+		{"not enabled", 101, 100, 0, 0, 0},
+		{"not enabled discouraged", 101, 100, 0,
+			script.ScriptVerifyDiscourageUpgradableNops, errcode.ScriptErrDiscourageUpgradableNops},
+	}
+
+	for _, test := range tests {
+		err := evalLockScript(test.lock, opcodes.OP_CHECKLOCKTIMEVERIFY, test.lockTime, test.sequence, 1, test.flags)
+		if test.err == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if !errcode.IsErrorCode(err, test.err) {
+			t.Errorf("%s: expect error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestScriptNumMinimalEncoding(t *testing.T) {
+	var minimal uint32 = script.ScriptVerifyMinmalData | script.ScriptVerifyCheckLockTimeVerify
+	tests := []struct {
+		name    string
+		operand []byte
+		flags   uint32
+		err     errcode.ScriptErr
+	}{
+		{"zero as empty", []byte{}, minimal, 0},
+		{"zero as 0x00", []byte{0x00}, minimal, errcode.ScriptErrUnknownError},
+		{"one as 0x0100", []byte{0x01, 0x00}, minimal, errcode.ScriptErrUnknownError},
+		{"negative zero", []byte{0x80}, minimal, errcode.ScriptErrUnknownError},
+		{"minimal", []byte{0x11}, minimal, 0},
+		{"zero as 0x00 without MINIMALDATA", []byte{0x00}, script.ScriptVerifyCheckLockTimeVerify, 0},
+		{"one as 0x0100 without MINIMALDATA", []byte{0x01, 0x00}, script.ScriptVerifyCheckLockTimeVerify, 0},
+	}
+
+	transaction := tx.NewTx(100, 1)
+	transaction.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(util.Hash{}, 0), script.NewEmptyScript(), 0))
+	for _, test := range tests {
+		for _, op := range []int{opcodes.OP_1ADD, opcodes.OP_CHECKLOCKTIMEVERIFY} {
+			s := script.NewEmptyScript()
+			s.PushSingleData(test.operand)
+			s.PushOpCode(op)
+			err := EvalScript(util.NewStack(), s, transaction, 0, 0, test.flags, NewScriptRealChecker())
+			if test.err == 0 {
+				if err != nil {
+					t.Errorf("%s %s: unexpected error %v", test.name, opcodes.GetOpName(op), err)
+				}
+				continue
+			}
+			if !errcode.IsErrorCode(err, test.err) {
+				t.Errorf("%s %s: expect error %v, got %v", test.name, opcodes.GetOpName(op), test.err, err)
+			}
+		}
+	}
+}
+
+func TestCheckSequenceVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		lock     int64
+		sequence uint32
+		version  int32
+		flags    uint32
+		err      errcode.ScriptErr
+	}{
+		{"height satisfied", 10, 10, 2, script.ScriptVerifyCheckSequenceVerify, 0},
+		{"height unsatisfied", 11, 10, 2, script.ScriptVerifyCheckSequenceVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"time satisfied", script.SequenceLockTimeTypeFlag | 10, script.SequenceLockTimeTypeFlag | 10, 2,
+			script.ScriptVerifyCheckSequenceVerify, 0},
+		{"time against height", script.SequenceLockTimeTypeFlag | 10, 10, 2,
+			script.ScriptVerifyCheckSequenceVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"tx version 1", 10, 10, 1, script.ScriptVerifyCheckSequenceVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"operand disable bit", script.SequenceLockTimeDisableFlag | 11, 10, 1,
+			script.ScriptVerifyCheckSequenceVerify, 0},
+		{"input disable bit", 10, script.SequenceLockTimeDisableFlag | 10, 2,
+			script.ScriptVerifyCheckSequenceVerify, errcode.ScriptErrUnsatisfiedLockTime},
+		{"negative lock", -1, 10, 2, script.ScriptVerifyCheckSequenceVerify, errcode.ScriptErrNegativeLockTime},
+		{"not enabled", 11, 10, 2, 0, 0},
+		{"not enabled discouraged", 11, 10, 2,
+			script.ScriptVerifyDiscourageUpgradableNops, errcode.ScriptErrDiscourageUpgradableNops},
+	}
+
+	for _, test := range tests {
+		err := evalLockScript(test.lock, opcodes.OP_CHECKSEQUENCEVERIFY, 0, test.sequence, test.version, test.flags)
+		if test.err == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if !errcode.IsErrorCode(err, test.err) {
+			t.Errorf("%s: expect error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
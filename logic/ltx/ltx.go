@@ -82,6 +82,28 @@ func ScriptVerifyInit() {
 }
 
 func CheckTxBeforeAcceptToMemPool(txn *tx.Tx) (*mempool.TxEntry, error) {
+	return checkTxBeforeAcceptToMemPool(txn, false, maxTxFee())
+}
+
+// CheckTxBeforeAcceptToMemPoolWithMaxFee checks the transaction like
+// CheckTxBeforeAcceptToMemPool, refusing an absolute fee over maxFee rather
+// than over -maxtxfee. A maxFee of 0 does not limit the fee.
+func CheckTxBeforeAcceptToMemPoolWithMaxFee(txn *tx.Tx, maxFee amount.Amount) (*mempool.TxEntry, error) {
+	return checkTxBeforeAcceptToMemPool(txn, false, maxFee)
+}
+
+// CheckTxBeforeAcceptToMemPoolBypassFee checks the transaction like
+// CheckTxBeforeAcceptToMemPool, without holding it to the mempool min fee. It
+// must still pay the min relay fee.
+func CheckTxBeforeAcceptToMemPoolBypassFee(txn *tx.Tx) (*mempool.TxEntry, error) {
+	return checkTxBeforeAcceptToMemPool(txn, true, maxTxFee())
+}
+
+func maxTxFee() amount.Amount {
+	return amount.Amount(conf.Cfg.Mempool.MaxTxFee)
+}
+
+func checkTxBeforeAcceptToMemPool(txn *tx.Tx, bypassFee bool, maxFee amount.Amount) (*mempool.TxEntry, error) {
 	if err := txn.CheckRegularTransaction(); err != nil {
 		return nil, err
 	}
@@ -126,6 +148,12 @@ func CheckTxBeforeAcceptToMemPool(txn *tx.Tx) (*mempool.TxEntry, error) {
 		return nil, errcode.New(errcode.TxErrNoPreviousOut)
 	}
 
+	// The transaction will at the earliest be mined in the next block, so
+	// the coinbase outputs it spends must be mature by then.
+	if err := CheckInputsMoney(txn, inputCoins, chain.GetInstance().Height()+1); err != nil {
+		return nil, err
+	}
+
 	// CLTV(CheckLockTimeVerify)
 	// Only accept BIP68 sequence locked transactions that can be mined
 	// in the next block; we don't want our mempool filled up with
@@ -164,25 +192,19 @@ func CheckTxBeforeAcceptToMemPool(txn *tx.Tx) (*mempool.TxEntry, error) {
 		return nil, errcode.NewError(errcode.RejectNonstandard, "bad-txns-too-many-sigops")
 	}
 
-	txFee, err := checkFee(txn, inputCoins)
+	txFee, err := checkFee(txn, inputCoins, bypassFee, maxFee)
 	if err != nil {
 		return nil, err
 	}
 
 	//TODO: Require that free transactions have sufficient priority to be mined in the next block
 	//TODO: Continuously rate-limit free (really, very-low-fee) transactions.
-	//TODO: check absurdly-high-fee (nFees > nAbsurdFee)
 
-	var extraFlags uint32 = script.ScriptVerifyNone
+	// The transaction will at the earliest be mined in the next block, so the
+	// script features enabled by upgrades are taken from that block's flags.
 	tip := chain.GetInstance().Tip()
-
-	if model.IsReplayProtectionEnabled(tip.GetMedianTimePast()) {
-		extraFlags |= script.ScriptEnableReplayProtection
-	}
-
-	if model.IsMagneticAnomalyEnabled(tip.GetMedianTimePast()) {
-		extraFlags |= script.ScriptEnableCheckDataSig
-	}
+	nextBlockScriptVerifyFlags := chain.GetInstance().GetBlockScriptFlags(tip)
+	extraFlags := nextBlockScriptVerifyFlags & uint32(script.ScriptUpgradeFlags)
 
 	//check inputs
 	var scriptVerifyFlags = uint32(script.StandardScriptVerifyFlags)
@@ -213,10 +235,9 @@ func CheckTxBeforeAcceptToMemPool(txn *tx.Tx) (*mempool.TxEntry, error) {
 	// There is a similar check in CreateNewBlock() to prevent creating
 	// invalid blocks (using TestBlockValidity), however allowing such
 	// transactions into the mempool can be exploited as a DoS attack.
-	var currentBlockScriptVerifyFlags = chain.GetInstance().GetBlockScriptFlags(tip)
-	err = checkInputs(txn, inputCoins, currentBlockScriptVerifyFlags, txScriptVerifyResultChan)
+	err = checkInputs(txn, inputCoins, nextBlockScriptVerifyFlags, txScriptVerifyResultChan)
 	if err != nil {
-		if ((^scriptVerifyFlags) & currentBlockScriptVerifyFlags) == 0 {
+		if ((^scriptVerifyFlags) & nextBlockScriptVerifyFlags) == 0 {
 			return nil, errcode.New(errcode.ScriptCheckInputsBug)
 		}
 		err = checkInputs(txn, inputCoins, uint32(script.MandatoryScriptVerifyFlags)|extraFlags, txScriptVerifyResultChan)
@@ -234,16 +255,34 @@ func CheckTxBeforeAcceptToMemPool(txn *tx.Tx) (*mempool.TxEntry, error) {
 	return txEntry, nil
 }
 
-func checkFee(txn *tx.Tx, inputCoins *utxo.CoinsMap) (int64, error) {
+// checkFee returns the fee of the transaction. The fee must not be over maxFee,
+// unless maxFee is 0, and without bypassFee it must meet the mempool min fee
+// and -minrelaytxfee.
+func checkFee(txn *tx.Tx, inputCoins *utxo.CoinsMap, bypassFee bool, maxFee amount.Amount) (int64, error) {
 	inputValue := inputCoins.GetValueIn(txn)
 	txFee := inputValue - txn.GetValueOut()
+	if maxFee > 0 && txFee > maxFee {
+		reason := fmt.Sprintf("absurdly-high-fee, %d > %d", txFee, maxFee)
+		log.Debug("reject tx:%s, for %s", txn.GetHash(), reason)
+		return 0, errcode.NewError(errcode.RejectHighFee, reason)
+	}
 
-	txsize := int64(txn.EncodeSize())
-	minfeeRate := mempool.GetInstance().GetMinFee(conf.Cfg.Mempool.MaxPoolSize)
-	rejectFee := minfeeRate.GetFee(int(txsize))
-
-	if int64(txFee) < rejectFee {
-		reason := fmt.Sprintf("mempool min fee not met %d < %d", txFee, rejectFee)
+	txsize := int(txn.GetVirtualSize())
+	// The mempool min fee only rises while the mempool is full, whitelisted
+	// peers may still relay transactions under it.
+	if !bypassFee {
+		minfeeRate := mempool.GetInstance().GetMinFee(conf.Cfg.MaxPoolSize())
+		rejectFee := minfeeRate.GetFee(txsize)
+
+		if txFee < rejectFee {
+			reason := fmt.Sprintf("mempool min fee not met %d < %d", txFee, rejectFee)
+			log.Debug("reject tx:%s, for %s", txn.GetHash(), reason)
+			return 0, errcode.NewError(errcode.RejectInsufficientFee, reason)
+		}
+	}
+	minRelayTxFee := mempool.GetInstance().MinRelayTxFee()
+	if relayFee := minRelayTxFee.GetFee(txsize); txFee < relayFee {
+		reason := fmt.Sprintf("min relay fee not met %d < %d", txFee, relayFee)
 		log.Debug("reject tx:%s, for %s", txn.GetHash(), reason)
 		return 0, errcode.NewError(errcode.RejectInsufficientFee, reason)
 	}
@@ -305,10 +344,17 @@ func ContextureCheckBlockTransactions(txs []*tx.Tx, blockHeight int32, blockLock
 			if prevTx != nil {
 				transactionHash := transaction.GetHash()
 				prevTxHash := prevTx.GetHash()
-				if pow.HashToBig(&transactionHash).Cmp(pow.HashToBig(&prevTxHash)) < 0 {
-					return fmt.Errorf(
-						"bad-ordering: transaction order is invalid(%s <%s) in block(height %d)",
-						transaction.GetHash().String(), prevTx.GetHash().String(), blockHeight)
+				// Transactions after the coinbase are sorted by txid, the
+				// canonical transaction ordering (CTOR).
+				switch pow.HashToBig(&transactionHash).Cmp(pow.HashToBig(&prevTxHash)) {
+				case 0:
+					log.Debug("transaction %s appears twice in block(height %d)",
+						transactionHash, blockHeight)
+					return errcode.NewError(errcode.RejectInvalid, "tx-duplicate")
+				case -1:
+					log.Debug("transaction order is invalid(%s < %s) in block(height %d)",
+						transactionHash, prevTxHash, blockHeight)
+					return errcode.NewError(errcode.RejectInvalid, "tx-ordering")
 				}
 			}
 			if prevTx != nil || !transaction.IsCoinBase() {
@@ -512,15 +558,12 @@ func areOutputsAlreadExist(transaction *tx.Tx) (exist bool) {
 
 func inputCoinsOf(txn *tx.Tx) (coinMap *utxo.CoinsMap, missingInput bool, spendCoinbase bool) {
 	coinMap = utxo.NewEmptyCoinsMap()
+	view := mempool.NewMempoolCoinsView(utxo.GetUtxoCacheInstance(), mempool.GetInstance())
 
 	for _, txin := range txn.GetIns() {
 		prevout := txin.PreviousOutPoint
 
-		coin := utxo.GetUtxoCacheInstance().GetCoin(prevout)
-		if coin == nil {
-			coin = mempool.GetInstance().GetCoin(prevout)
-		}
-
+		coin := view.GetCoin(prevout)
 		if coin == nil || coin.IsSpent() {
 			return coinMap, true, spendCoinbase
 		}
@@ -735,7 +778,8 @@ func errorMandatoryFailed(j ScriptVerifyJob, innerErr error) error {
 		j.Tx.GetHash(), j.IputNum, hex.EncodeToString(j.ScriptSig.GetData()),
 		hex.EncodeToString(j.ScriptPubKey.GetData()), innerErr)
 
-	return errcode.MakeError(errcode.RejectInvalid, "mandatory-script-verify-flag-failed (%s)", innerErr)
+	err := errcode.MakeError(errcode.RejectInvalid, "mandatory-script-verify-flag-failed (%s)", innerErr)
+	return withInputDetails(j, err)
 }
 
 func errorNonMandatoryPass(j ScriptVerifyJob, innerErr error) error {
@@ -744,7 +788,14 @@ func errorNonMandatoryPass(j ScriptVerifyJob, innerErr error) error {
 		j.IputNum, hex.EncodeToString(j.ScriptSig.GetData()),
 		hex.EncodeToString(j.ScriptPubKey.GetData()), innerErr)
 
-	return errcode.MakeError(errcode.RejectNonstandard, "non-mandatory-script-verify-flag (%s)", innerErr)
+	err := errcode.MakeError(errcode.RejectNonstandard, "non-mandatory-script-verify-flag (%s)", innerErr)
+	return withInputDetails(j, err)
+}
+
+// withInputDetails attaches the transaction and the input of j to err.
+func withInputDetails(j ScriptVerifyJob, err error) error {
+	err = errcode.WithDetail(err, errcode.DetailTxID, j.Tx.GetHash())
+	return errcode.WithDetail(err, errcode.DetailInput, j.IputNum)
 }
 
 //CalculateLockPoints calculate lockpoint(all ins' max time or height at which it can be spent) of transaction
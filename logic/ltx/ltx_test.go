@@ -1349,6 +1349,8 @@ func initTestEnv() func() {
 	givenDustRelayFeeLimits(0)
 	model.ActiveNetParams.RequireStandard = false
 	conf.Cfg.Script.MaxDatacarrierBytes = 223
+	// the test transactions pay most of a coinbase as fee
+	conf.Cfg.Mempool.MaxTxFee = 0
 
 	cleanup := func() {
 		os.RemoveAll(unitTestDataDirPath)
@@ -1537,7 +1539,10 @@ func Test_normal_tx_should_be_accepted_into_mempool_____without_influence_of_pre
 	txn := makeTxWith2ErrorIns(blocks, script.NewEmptyScript())
 	_, err := ltx.CheckTxBeforeAcceptToMemPool(txn)
 	expectedErr := errcode.NewError(errcode.RejectNonstandard, "non-mandatory-script-verify-flag (Script did not clean its stack)")
-	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, expectedErr.Error(), err.Error())
+	txid, ok := errcode.Detail(err, errcode.DetailTxID)
+	assert.True(t, ok)
+	assert.Equal(t, txn.GetHash(), txid)
 
 	assert_normal_tx_should_be_accepted_into_mempool(blocks, t)
 }
@@ -1793,7 +1798,10 @@ func Test_tx_script_with_non_clean_stack_after_eval__should_not_be_accepted_into
 
 	expectedErr := errcode.NewError(errcode.RejectNonstandard,
 		"non-mandatory-script-verify-flag (Script did not clean its stack)")
-	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, expectedErr.Error(), err.Error())
+	input, ok := errcode.Detail(err, errcode.DetailInput)
+	assert.True(t, ok)
+	assert.Equal(t, 0, input)
 }
 
 //test cases for ltx.ContextureCheckBlockTransactions
@@ -163,7 +163,7 @@ func RemoveFromWallet(txn *tx.Tx) {
 	wallet.GetInstance().RemoveFromWallet(txn)
 }
 
-func SetFeeRate(feePaid int64, byteSize int64) {
+func SetFeeRate(feePaid amount.Amount, byteSize int) {
 	wallet.GetInstance().SetFeeRate(feePaid, byteSize)
 }
 func FundTransaction(fundTx *tx.Tx, setSubtractFeeFromOutputs *set.Set, options *btcjson.FundRawTxoptions) (
@@ -249,7 +249,7 @@ func CreateTransaction(recipients []*wallet.Recipient, changePosInOut *int, sign
 	txn := tx.NewTx(lockTime, tx.DefaultVersion)
 	coins := AvailableCoins(true, false)
 	feeRet := amount.Amount(0)
-	dustRelayFee := util.NewFeeRate(conf.Cfg.TxOut.DustRelayFee)
+	dustRelayFee := util.NewFeeRatePerK(amount.Amount(conf.Cfg.TxOut.DustRelayFee))
 
 	// Start with no fee and loop until there is enough fee.
 	for {
@@ -332,7 +332,7 @@ func CreateTransaction(recipients []*wallet.Recipient, changePosInOut *int, sign
 			// purpose of the all-inclusive feature. So instead we raise the
 			// change and deduct from the recipient.
 			if subtractFeeCount > 0 && newTxOut.IsDust(dustRelayFee) {
-				dust := amount.Amount(newTxOut.GetDustThreshold(dustRelayFee)) - newTxOut.GetValue()
+				dust := newTxOut.GetDustThreshold(dustRelayFee) - newTxOut.GetValue()
 				// Raise change until no more dust.
 				newTxOut.SetValue(newTxOut.GetValue() + dust)
 				// Subtract from first recipient.
@@ -402,12 +402,13 @@ func CreateTransaction(recipients []*wallet.Recipient, changePosInOut *int, sign
 			txn.UpdateInScript(i, script.NewEmptyScript())
 		}
 
-		feeNeeded := amount.Amount(wallet.GetInstance().GetMinimumFee(int(txSize)))
+		feeNeeded := wallet.GetInstance().GetMinimumFee(int(txSize))
 
 		// If we made it here and we aren't even able to meet the relay fee
 		// on the next pass, give up because we must be at the maximum
 		// allowed fee.
-		minFee := amount.Amount(util.NewFeeRate(util.DefaultMinRelayTxFeePerK).GetFee(int(txSize)))
+		minRelayTxFee := mempool.GetInstance().MinRelayTxFee()
+		minFee := minRelayTxFee.GetFee(int(txSize))
 		if feeNeeded < minFee {
 			return nil, 0, errors.New("Transaction too large for fee policy")
 		}
@@ -9,8 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/copernet/copernicus/log"
-	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"runtime"
 	"runtime/debug"
@@ -21,7 +19,6 @@ import (
 	"github.com/copernet/copernicus/net/server"
 	"github.com/copernet/copernicus/rpc"
 	"github.com/copernet/copernicus/util"
-	"net"
 )
 
 const (
@@ -37,14 +34,14 @@ func bchMain(ctx context.Context, args []string) error {
 	// Load configuration and parse command line.  This function also
 	// initializes logging and configures it accordingly.
 	appInitMain(args)
-	go func() {
-		listenAddr := net.JoinHostPort(conf.Cfg.PProf.IP, conf.Cfg.PProf.Port)
-		fmt.Printf("Profile server listening on %s\n", listenAddr)
-		profileRedirect := http.RedirectHandler("/debug/pprof", http.StatusSeeOther)
-		http.Handle("/", profileRedirect)
-		err := fmt.Errorf("%v", http.ListenAndServe(listenAddr, nil))
-		fmt.Println(err.Error())
-	}()
+	profileListener, err := startProfileServer(conf.Cfg.PProf.IP, conf.Cfg.PProf.Port)
+	if err != nil {
+		return fmt.Errorf("start profile server: %v", err)
+	}
+	if profileListener != nil {
+		fmt.Printf("Profile server listening on %s\n", profileListener.Addr())
+		defer profileListener.Close()
+	}
 	interrupt := interruptListener()
 
 	timeSource := util.GetMedianTimeSource()
@@ -70,17 +67,26 @@ func bchMain(ctx context.Context, args []string) error {
 		return nil
 	}
 	s.Start()
-	defer func() {
-		s.Stop()
-		// Shutdown the RPC server if it's not disabled.
-		if !conf.Cfg.P2PNet.DisableRPC {
-			rpcServer.Stop()
+	if conf.Cfg.Mining.GenEnabled {
+		err = rpc.StartCPUMiner(conf.Cfg.Mining.GenProcLimit, conf.Cfg.Mining.GenAddress)
+		if err != nil {
+			log.Error("Failed to start the internal miner: %v", err)
 		}
-	}()
-	go func() {
-		<-rpcServer.RequestedProcessShutdown()
-		shutdownRequestChannel <- struct{}{}
-	}()
+	}
+	go reloadListener(func() {
+		connManager := server.NewRPCConnManager(s)
+		reloadConfigFile(args, reloadHooks{
+			connectPeer: func(addr string) error {
+				return connManager.Connect(addr, true)
+			},
+			removePeer:    connManager.RemoveByAddr,
+			sendFeeFilter: s.SendFeeFilter,
+		})
+	})
+	defer shutdownNode(nodeShutdownHooks(s, rpcServer))
+	if rpcServer != nil {
+		go forwardShutdownRequest(rpcServer.RequestedProcessShutdown())
+	}
 	<-interrupt
 	return nil
 }
@@ -92,8 +98,9 @@ func main() {
 	// Block and transaction processing can cause bursty allocations.  This
 	// limits the garbage collector from excessively overallocating during
 	// bursts.  This value was arrived at with the help of profiling live
-	// usage.
-	debug.SetGCPercent(10)
+	// usage.  The gcpercent option replaces it once the configuration is
+	// loaded.
+	debug.SetGCPercent(conf.DefaultGCPercent)
 
 	// Up some limits.
 	if runtime.GOOS != "plan9" && runtime.GOOS != "windows" {
@@ -52,6 +52,7 @@ type BitcoinParams struct {
 	BitcoinNet               wire.BitcoinNet
 	DiskMagic                wire.BitcoinNet
 	DefaultPort              string
+	RPCPort                  string
 	DNSSeeds                 []DNSSeed
 	GenesisBlock             *block.Block
 	PowLimitBits             uint32
@@ -146,6 +147,7 @@ var MainNetParams = BitcoinParams{
 	Name:        "main",
 	BitcoinNet:  wire.MainNet,
 	DefaultPort: "8333",
+	RPCPort:     "8334",
 	DNSSeeds: []DNSSeed{
 		{Host: "seed.bitcoinabc.org", HasFiltering: true},                  // Pieter Wuille
 		{Host: "seed-abc.bitcoinforks.org", HasFiltering: true},            // Matt Corallo
@@ -267,6 +269,7 @@ var TestNetParams = BitcoinParams{
 	BitcoinNet:  wire.TestNet3,
 	DiskMagic:   wire.TestDiskMagic,
 	DefaultPort: "18333",
+	RPCPort:     "18334",
 	DNSSeeds: []DNSSeed{
 		{Host: "testnet-seed.bitcoinabc.org", HasFiltering: true},
 		{Host: "testnet-seed-abc.bitcoinforks.org", HasFiltering: true},
@@ -361,6 +364,7 @@ var RegressionNetParams = BitcoinParams{
 	Name:         "regtest",
 	BitcoinNet:   wire.RegTestNet,
 	DefaultPort:  "18444",
+	RPCPort:      "18334",
 	DNSSeeds:     []DNSSeed{},
 	GenesisBlock: RegTestGenesisBlock,
 
@@ -449,23 +453,41 @@ func mustRegister(bp *BitcoinParams) {
 
 //IsUAHFEnabled Check is UAHF has activated.
 func IsUAHFEnabled(height int32) bool {
-	return height >= ActiveNetParams.UAHFHeight
+	return ActiveNetParams.IsUAHFEnabled(height)
 }
 
 func IsDAAEnabled(height int32) bool {
-	return height >= ActiveNetParams.DAAHeight
+	return ActiveNetParams.IsDAAEnabled(height)
 }
 
 func IsMagneticAnomalyEnabled(mediaTimePast int64) bool {
-	activeTime := ActiveNetParams.MagneticAnomalyActivationTime
+	return ActiveNetParams.IsMagneticAnomalyEnabled(mediaTimePast)
+}
+
+func IsReplayProtectionEnabled(medianTimePast int64) bool {
+	return ActiveNetParams.IsReplayProtectionEnabled(medianTimePast)
+}
+
+// IsUAHFEnabled reports whether the UAHF is active after the block at height
+// on the network.
+func (param *BitcoinParams) IsUAHFEnabled(height int32) bool {
+	return height >= param.UAHFHeight
+}
+
+func (param *BitcoinParams) IsDAAEnabled(height int32) bool {
+	return height >= param.DAAHeight
+}
+
+func (param *BitcoinParams) IsMagneticAnomalyEnabled(mediaTimePast int64) bool {
+	activeTime := param.MagneticAnomalyActivationTime
 	if conf.Args.MagneticAnomalyTime > 0 {
 		activeTime = conf.Args.MagneticAnomalyTime
 	}
 	return mediaTimePast >= activeTime
 }
 
-func IsReplayProtectionEnabled(medianTimePast int64) bool {
-	time := ActiveNetParams.GreatWallActivationTime
+func (param *BitcoinParams) IsReplayProtectionEnabled(medianTimePast int64) bool {
+	time := param.GreatWallActivationTime
 	if conf.Args.ReplayProtectionActivationTime > 0 {
 		time = conf.Args.ReplayProtectionActivationTime
 	}
@@ -499,7 +521,7 @@ func GetBlockSubsidy(height int32, params *BitcoinParams) amount.Amount {
 	}
 
 	nSubsidy := amount.Amount(50 * util.COIN)
-	// Subsidy is cut in half every 210,000 blocks which will occur
-	// approximately every 4 years.
+	// Subsidy is cut in half every SubsidyReductionInterval blocks, 210,000
+	// on mainnet which will occur approximately every 4 years.
 	return amount.Amount(uint(nSubsidy) >> uint(halvings))
 }
@@ -140,35 +140,25 @@ func TestIsReplayProtectionEnabled(t *testing.T) {
 }
 
 func TestGetBlockSubsidy(t *testing.T) {
-	netParams := &MainNetParams
-	halfSubsidyHeight := netParams.SubsidyReductionInterval
-	nonSubsidyHeight := netParams.SubsidyReductionInterval * 64
-
-	tests := []struct {
-		name   string
-		height int32
-		expect float64
-	}{
-		{
-			name:   "genesis",
-			height: 0,
-			expect: 50,
-		},
-		{
-			name:   "first half subsidy",
-			height: halfSubsidyHeight,
-			expect: 25,
-		},
-		{
-			name:   "no subsidy",
-			height: nonSubsidyHeight,
-			expect: 0,
-		},
-	}
-	for _, test := range tests {
-		t.Logf("testing case:%s", test.name)
-		amt := GetBlockSubsidy(test.height, netParams)
-		assert.Equal(t, test.expect, amt.ToBTC())
-
+	for _, netParams := range []*BitcoinParams{&MainNetParams, &RegressionNetParams} {
+		interval := netParams.SubsidyReductionInterval
+		tests := []struct {
+			name   string
+			height int32
+			expect float64
+		}{
+			{"genesis", 0, 50},
+			{"before first halving", interval - 1, 50},
+			{"first halving", interval, 25},
+			{"second halving", 2 * interval, 12.5},
+			{"last subsidy", 33*interval - 1, 0.00000001},
+			{"no subsidy", 33 * interval, 0},
+			{"64th halving", 64 * interval, 0},
+			{"well past 64th halving", 100 * interval, 0},
+		}
+		for _, test := range tests {
+			amt := GetBlockSubsidy(test.height, netParams)
+			assert.Equal(t, test.expect, amt.ToBTC(), "%s at height %d of %s", test.name, test.height, netParams.Name)
+		}
 	}
 }
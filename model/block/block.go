@@ -1,7 +1,7 @@
 package block
 
 import (
-	"bytes"
+	"bufio"
 	"io"
 
 	"github.com/copernet/copernicus/logic/lmerkleroot"
@@ -15,10 +15,21 @@ type Block struct {
 	serializesize int
 	Checked       bool
 	encodeSize    int
+
+	// merkle root of Txs and whether the tree is mutated, valid once
+	// merkleComputed is set
+	merkleRoot     util.Hash
+	merkleMutated  bool
+	merkleComputed bool
 }
 
 const MinBlocksToKeep = int32(288)
 
+// maxTxsPrealloc bounds the transactions allocated up front when reading a
+// block, so that a bogus count cannot make us allocate before the
+// transactions themselves are read.
+const maxTxsPrealloc = 1 << 16
+
 func (bl *Block) GetBlockHeader() BlockHeader {
 	return bl.Header
 }
@@ -26,6 +37,7 @@ func (bl *Block) GetBlockHeader() BlockHeader {
 func (bl *Block) SetNull() {
 	bl.Header.SetNull()
 	bl.Txs = nil
+	bl.InvalidateCache()
 }
 
 func (bl *Block) Serialize(w io.Writer) error {
@@ -43,17 +55,56 @@ func (bl *Block) Serialize(w io.Writer) error {
 	return nil
 }
 
+// SerializeTo writes the block to w through a buffer, one transaction at a
+// time, so that a large block is never held serialized in memory.
+func (bl *Block) SerializeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := bl.Serialize(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// SerializeSize returns the size of the serialized block. The result is
+// cached, so InvalidateCache must be called whenever Txs changes.
 func (bl *Block) SerializeSize() int {
 	if bl.serializesize != 0 {
 		return bl.serializesize
 	}
 
-	buf := bytes.NewBuffer(nil)
-	bl.Serialize(buf)
-	bl.serializesize = buf.Len()
+	size := blockHeaderLength + int(util.VarIntSerializeSize(uint64(len(bl.Txs))))
+	for _, txn := range bl.Txs {
+		size += int(txn.SerializeSize())
+	}
+	bl.serializesize = size
 	return bl.serializesize
 }
 
+// ComputeMerkleRoot returns the merkle root of the block transactions, to be
+// compared with the one in the header. The result is cached, so
+// InvalidateCache must be called whenever Txs changes.
+func (bl *Block) ComputeMerkleRoot() util.Hash {
+	if !bl.merkleComputed {
+		bl.merkleRoot = lmerkleroot.BlockMerkleRoot(bl.Txs, &bl.merkleMutated)
+		bl.merkleComputed = true
+	}
+	return bl.merkleRoot
+}
+
+// InvalidateCache drops the cached size and merkle root, after Txs changed.
+func (bl *Block) InvalidateCache() {
+	bl.serializesize = 0
+	bl.merkleComputed = false
+}
+
+// IsMerkleMutated reports whether the transactions repeat a sequence that
+// leaves the merkle root unchanged (CVE-2012-2459). Such a block is invalid,
+// though a valid block with the same header exists.
+func (bl *Block) IsMerkleMutated() bool {
+	bl.ComputeMerkleRoot()
+	return bl.merkleMutated
+}
+
 func (bl *Block) Encode(w io.Writer) error {
 	return bl.Serialize(w)
 }
@@ -69,6 +120,9 @@ func (bl *Block) EncodeSize() int {
 	return bl.SerializeSize()
 }
 
+// Unserialize reads the block from r. The hashes of the transactions and the
+// merkle root are computed from the bytes as they are read, so that the
+// transactions never need to be serialized again to be hashed.
 func (bl *Block) Unserialize(r io.Reader) error {
 	if err := bl.Header.Unserialize(r); err != nil {
 		return err
@@ -80,17 +134,37 @@ func (bl *Block) Unserialize(r io.Reader) error {
 	// if ntx > consensus.MaxTxCount {
 	// 	return fmt.Errorf("recv %d transactions, but allow max %d", ntx, consensus.MaxTxCount)
 	// }
-	bl.Txs = make([]*tx.Tx, ntx)
-	for i := 0; i < int(ntx); i++ {
-		tx := tx.NewTx(0, tx.DefaultVersion)
-		if err := tx.Unserialize(r); err != nil {
+	prealloc := ntx
+	if prealloc > maxTxsPrealloc {
+		prealloc = maxTxsPrealloc
+	}
+	bl.Txs = make([]*tx.Tx, 0, prealloc)
+	bl.InvalidateCache()
+	var merkle lmerkleroot.MerkleBuilder
+	for i := uint64(0); i < ntx; i++ {
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		if err := txn.UnserializeHashed(r); err != nil {
 			return err
 		}
-		bl.Txs[i] = tx
+		bl.Txs = append(bl.Txs, txn)
+		merkle.Add(txn.GetHash())
 	}
+	bl.merkleRoot, bl.merkleMutated = merkle.Root()
+	bl.merkleComputed = true
 	return nil
 }
 
+// DeserializeFrom reads a block from r through a buffer, one transaction at a
+// time, so that the serialized block is never held in memory as a whole. It
+// may read past the end of the block, so r should be limited to the block.
+func DeserializeFrom(r io.Reader) (*Block, error) {
+	bl := NewBlock()
+	if err := bl.Unserialize(bufio.NewReader(r)); err != nil {
+		return nil, err
+	}
+	return bl, nil
+}
+
 func (bl *Block) GetHash() util.Hash {
 	bh := bl.Header
 	return bh.GetHash()
@@ -3,8 +3,16 @@ package block
 import (
 	"bytes"
 	"encoding/hex"
+	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"testing"
 )
 
@@ -176,3 +184,139 @@ func TestCreateGenesisBlock(t *testing.T) {
 		}
 	}
 }
+
+func hashPair(left, right util.Hash) util.Hash {
+	return util.DoubleSha256Hash(append(left[:], right[:]...))
+}
+
+func TestComputeMerkleRoot(t *testing.T) {
+	txs := []*tx.Tx{tx.NewTx(1, tx.DefaultVersion), tx.NewTx(2, tx.DefaultVersion), tx.NewTx(3, tx.DefaultVersion)}
+	h0, h1, h2 := txs[0].GetHash(), txs[1].GetHash(), txs[2].GetHash()
+
+	tests := []struct {
+		txs  []*tx.Tx
+		root util.Hash
+	}{
+		{txs[:1], h0},
+		{txs[:2], hashPair(h0, h1)},
+		// the odd node at the end of a level is paired with itself
+		{txs[:3], hashPair(hashPair(h0, h1), hashPair(h2, h2))},
+	}
+
+	for i, test := range tests {
+		bk := &Block{Txs: test.txs}
+		assert.Equal(t, test.root, bk.ComputeMerkleRoot(), "test %d", i)
+		assert.False(t, bk.IsMerkleMutated(), "test %d", i)
+	}
+}
+
+func TestIsMerkleMutated(t *testing.T) {
+	txs := []*tx.Tx{tx.NewTx(1, tx.DefaultVersion), tx.NewTx(2, tx.DefaultVersion), tx.NewTx(3, tx.DefaultVersion)}
+	honest := &Block{Txs: txs}
+
+	// repeating the last transaction of an odd list keeps the merkle root
+	malleated := &Block{Txs: append(txs[:3:3], txs[2])}
+	assert.Equal(t, honest.ComputeMerkleRoot(), malleated.ComputeMerkleRoot())
+	assert.True(t, malleated.IsMerkleMutated())
+	assert.False(t, honest.IsMerkleMutated())
+
+	// the mutation is found while the block is read too
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, malleated.Serialize(buf))
+	read, err := DeserializeFrom(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, honest.ComputeMerkleRoot(), read.ComputeMerkleRoot())
+	assert.True(t, read.IsMerkleMutated())
+}
+
+func TestInvalidateCache(t *testing.T) {
+	txs := []*tx.Tx{tx.NewTx(1, tx.DefaultVersion), tx.NewTx(2, tx.DefaultVersion)}
+	bk := &Block{Txs: txs[:1]}
+	assert.Equal(t, txs[0].GetHash(), bk.ComputeMerkleRoot())
+
+	bk.Txs = txs
+	bk.InvalidateCache()
+	assert.Equal(t, hashPair(txs[0].GetHash(), txs[1].GetHash()), bk.ComputeMerkleRoot())
+}
+
+// syntheticBlock returns a block of n transactions with a 1000 byte data
+// push in the output script of each.
+func syntheticBlock(n int) *Block {
+	bk := NewBlock()
+	bk.Header.Version = 1
+	for i := 0; i < n; i++ {
+		txn := tx.NewTx(uint32(i), tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(util.Hash{byte(i), byte(i >> 8)}, 0),
+			script.NewEmptyScript(), script.SequenceFinal))
+		pkScript := script.NewEmptyScript()
+		pkScript.PushSingleData(make([]byte, 1000))
+		txn.AddTxOut(txout.NewTxOut(amount.Amount(i), pkScript))
+		bk.Txs = append(bk.Txs, txn)
+	}
+	return bk
+}
+
+func TestBlockSerializeToAndDeserializeFrom(t *testing.T) {
+	rawBlockBytes, err := hex.DecodeString(rawBlock)
+	assert.Nil(t, err)
+	decoded := NewBlock()
+	assert.Nil(t, decoded.Decode(bytes.NewReader(rawBlockBytes)))
+
+	for _, bk := range []*Block{decoded, syntheticBlock(5000), NewBlock()} {
+		buffered := bytes.NewBuffer(nil)
+		assert.Nil(t, bk.Serialize(buffered))
+
+		streamed := bytes.NewBuffer(nil)
+		assert.Nil(t, bk.SerializeTo(streamed))
+		assert.Equal(t, buffered.Bytes(), streamed.Bytes())
+		assert.Equal(t, buffered.Len(), bk.SerializeSize())
+
+		read, err := DeserializeFrom(bytes.NewReader(streamed.Bytes()))
+		assert.Nil(t, err)
+		reread := bytes.NewBuffer(nil)
+		assert.Nil(t, read.Serialize(reread))
+		assert.Equal(t, buffered.Bytes(), reread.Bytes())
+
+		// the hashes computed while reading are those of the transactions
+		assert.Equal(t, len(bk.Txs), len(read.Txs))
+		for i := range bk.Txs {
+			assert.Equal(t, bk.Txs[i].GetHash(), read.Txs[i].GetHash())
+		}
+		assert.Equal(t, lmerkleroot.BlockMerkleRoot(bk.Txs, nil), read.ComputeMerkleRoot())
+	}
+	assert.Equal(t, decoded.Header.MerkleRoot, decoded.ComputeMerkleRoot())
+
+	// a block cut short is an error
+	_, err = DeserializeFrom(bytes.NewReader(rawBlockBytes[:len(rawBlockBytes)-1]))
+	assert.NotNil(t, err)
+}
+
+func BenchmarkBlockSerializeTo(b *testing.B) {
+	// about 32MB of transactions
+	bk := syntheticBlock(30000)
+	b.SetBytes(int64(bk.SerializeSize()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := bk.SerializeTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeserializeFrom(b *testing.B) {
+	bk := syntheticBlock(30000)
+	buf := bytes.NewBuffer(nil)
+	if err := bk.SerializeTo(buf); err != nil {
+		b.Fatal(err)
+	}
+	raw := buf.Bytes()
+	b.SetBytes(int64(len(raw)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DeserializeFrom(bytes.NewReader(raw)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -63,6 +63,16 @@ func (bfi *BlockFileInfo) AddBlock(nHeightIn int32, timeIn uint64) {
 	}
 }
 
+// GetTimeFirst returns the earliest time of the blocks in the file.
+func (bfi *BlockFileInfo) GetTimeFirst() uint64 {
+	return bfi.timeFirst
+}
+
+// GetTimeLast returns the latest time of the blocks in the file.
+func (bfi *BlockFileInfo) GetTimeLast() uint64 {
+	return bfi.timeLast
+}
+
 func (bfi *BlockFileInfo) String() string {
 	return fmt.Sprintf("BlockFileInfo(blocks=%d, size=%d, heights=%d...%d, time=%d...%d)",
 		bfi.Blocks, bfi.Size, bfi.HeightFirst, bfi.HeightLast,
@@ -2,6 +2,7 @@ package block
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 
@@ -35,16 +36,23 @@ func (bh *BlockHeader) GetHash() util.Hash {
 	if !bh.Hash.IsNull() {
 		return bh.Hash
 	}
-	buf := bytes.NewBuffer(make([]byte, 0, blockHeaderLength))
-	err := bh.Serialize(buf)
-	if err != nil {
-		log.Error("serialize block header failed, please check: %v", err)
-		return util.HashOne
-	}
-	bh.Hash = util.DoubleSha256Hash(buf.Bytes())
+	var buf [blockHeaderLength]byte
+	bh.serializeTo(&buf)
+	bh.Hash = util.DoubleSha256(buf[:])
 	return bh.Hash
 }
 
+// serializeTo writes the header to buf as Serialize does. The header has a
+// fixed size, so it is hashed from an array without allocating.
+func (bh *BlockHeader) serializeTo(buf *[blockHeaderLength]byte) {
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(bh.Version))
+	copy(buf[4:36], bh.HashPrevBlock[:])
+	copy(buf[36:68], bh.MerkleRoot[:])
+	binary.LittleEndian.PutUint32(buf[68:72], bh.Time)
+	binary.LittleEndian.PutUint32(buf[72:76], bh.Bits)
+	binary.LittleEndian.PutUint32(buf[76:80], bh.Nonce)
+}
+
 func (bh *BlockHeader) SetNull() {
 	*bh = BlockHeader{}
 }
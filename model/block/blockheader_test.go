@@ -122,3 +122,48 @@ func TestGetHeaderSerializeList(t *testing.T) {
 	dumplist := hdr.GetSerializeList()
 	assert.Equal(t, expects, dumplist)
 }
+
+func TestBlockHeaderGetHashVectors(t *testing.T) {
+	expected := []string{
+		"00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+		// the header named blockTenThousand is that of block 100000
+		"000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506",
+	}
+	for i, blk := range testBlocks {
+		hdr := NewBlockHeader()
+		if err := hdr.Unserialize(bytes.NewReader(blk)); err != nil {
+			t.Fatalf("test %d, Unserialize failed: %v", i, err)
+		}
+		assert.Equal(t, expected[i], hdr.GetHash().String(), "test %d", i)
+		assert.Equal(t, util.DoubleSha256Hash(blk), hdr.GetHash(), "test %d", i)
+	}
+}
+
+func BenchmarkBlockHeaderGetHash(b *testing.B) {
+	header := NewBlockHeader()
+	if err := header.Unserialize(bytes.NewReader(blockOne)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header.Hash = util.Hash{}
+		header.GetHash()
+	}
+}
+
+// BenchmarkBlockHeaderHashBuffered hashes the header the way GetHash did
+// before hashing it from a fixed size array, for comparison.
+func BenchmarkBlockHeaderHashBuffered(b *testing.B) {
+	header := NewBlockHeader()
+	if err := header.Unserialize(bytes.NewReader(blockOne)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bytes.NewBuffer(make([]byte, 0, blockHeaderLength))
+		header.Serialize(buf)
+		util.DoubleSha256Hash(buf.Bytes())
+	}
+}
@@ -17,9 +17,9 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"github.com/copernet/copernicus/model/pow"
-	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/persist"
 	"github.com/copernet/copernicus/util"
 	"gopkg.in/eapache/queue.v1"
@@ -222,9 +222,12 @@ func (c *Chain) Genesis() *blockindex.BlockIndex {
 	return nil
 }
 
+// GetReceivedID returns the sequence id of the next block received in
+// full. Ids start at 1, the blocks loaded from disk keep 0 as they were all
+// received before.
 func (c *Chain) GetReceivedID() uint64 {
 	c.receiveID++
-	return c.receiveID - 1
+	return c.receiveID
 }
 
 // FindHashInActive finds blockindex from active
@@ -303,80 +306,10 @@ func (c *Chain) GetSpendHeight(hash *util.Hash) int32 {
 	return -1
 }
 
+// GetBlockScriptFlags Returns the script flags which should be checked for the
+// block following pindex.
 func (c *Chain) GetBlockScriptFlags(pindex *blockindex.BlockIndex) uint32 {
-	// TODO: AssertLockHeld(cs_main);
-	// var sc sync.RWMutex
-	// sc.Lock()
-	// defer sc.Unlock()
-
-	// BIP16 didn't become active until Apr 1 2012
-	/** Activation time for P2SH (April 1st 2012) */
-	var flags uint32
-	P2SHActivationTime := 1333234914
-	//nBIP16SwitchTime := 1333238400
-	if pindex.GetMedianTimePast() >= int64(P2SHActivationTime) {
-		flags = script.ScriptVerifyP2SH
-	} else {
-		flags = script.ScriptVerifyNone
-	}
-	//fStrictPayToScriptHash := int(pindex.GetBlockTime()) >= nBIP16SwitchTime
-	param := c.params
-	// Start enforcing the DERSIG (BIP66) rule
-
-	if pindex.Height+1 >= param.BIP66Height {
-		flags |= script.ScriptVerifyDersig
-	}
-
-	// Start enforcing CHECKLOCKTIMEVERIFY (BIP65) rule
-	if pindex.Height+1 >= param.BIP65Height {
-		flags |= script.ScriptVerifyCheckLockTimeVerify
-	}
-
-	// Start enforcing CSV (BIP68, BIP112 and BIP113) rule.
-	if pindex.Height+1 >= param.CSVHeight {
-		flags |= script.ScriptVerifyCheckSequenceVerify
-	}
-	//// Start enforcing BIP112 (CHECKSEQUENCEVERIFY) using versionbits logic.
-	//if versionbits.VersionBitsState(pindex, param, consensus.DeploymentCSV, versionbits.VBCache) == versionbits.ThresholdActive {
-	//	flags |= script.ScriptVerifyCheckSequenceVerify
-	//}
-
-	// If the UAHF is enabled, we start accepting replay protected txns
-	if model.IsUAHFEnabled(pindex.Height) {
-		flags |= script.ScriptVerifyStrictEnc
-		flags |= script.ScriptEnableSigHashForkID
-	}
-
-	// If the Cash HF is enabled, we start rejecting transaction that use a high
-	// s in their signature. We also make sure that signature that are supposed
-	// to fail (for instance in multisig or other forms of smart contracts) are
-	// null.
-	//if pindex.IsCashHFEnabled(param) {
-	//	flags |= script.ScriptVerifyLowS
-	//	flags |= script.ScriptVerifyNullFail
-	//}
-	if model.IsDAAEnabled(pindex.Height) {
-		flags |= script.ScriptVerifyLowS
-		flags |= script.ScriptVerifyNullFail
-	}
-
-	// When the magnetic anomaly fork is enabled, we start accepting
-	// transactions using the OP_CHECKDATASIG opcode and it's verify
-	// alternative. We also start enforcing push only signatures and
-	// clean stack.
-	if model.IsMagneticAnomalyEnabled(pindex.GetMedianTimePast()) {
-		flags |= script.ScriptEnableCheckDataSig
-		flags |= script.ScriptVerifySigPushOnly
-		flags |= script.ScriptVerifyCleanStack
-	}
-
-	// We make sure this node will have replay protection during the next hard
-	// fork.
-	if model.IsReplayProtectionEnabled(pindex.GetMedianTimePast()) {
-		flags |= script.ScriptEnableReplayProtection
-	}
-
-	return flags
+	return GetScriptFlags(c.params, pindex.Height, pindex.GetMedianTimePast())
 }
 
 // GetIndex Returns the blIndex entry at a particular height in this chain, or nullptr
@@ -576,13 +509,14 @@ func (c *Chain) insertToBranch(bis *blockindex.BlockIndex) {
 			return true
 		}
 
-		// ... then by earliest time received, ...
-		//if c.branch[i].SequenceID < c.branch[j].SequenceID {
-		//	return false
-		//}
-		//if c.branch[i].SequenceID > c.branch[j].SequenceID {
-		//	return true
-		//}
+		// ... then by earliest time received, so a block arriving later
+		// with the same work does not displace the tip.
+		if c.branch[i].SequenceID < c.branch[j].SequenceID {
+			return false
+		}
+		if c.branch[i].SequenceID > c.branch[j].SequenceID {
+			return true
+		}
 
 		return false
 	})
@@ -637,6 +571,7 @@ func (c *Chain) AddToBranch(bis *blockindex.BlockIndex) error {
 			}
 
 			delete(c.orphan, *preHash)
+			delete(persist.GetInstance().GlobalMapBlocksUnlinked, pindex)
 		}
 	}
 	return nil
@@ -716,6 +651,13 @@ func (c *Chain) AddToOrphan(bi *blockindex.BlockIndex) error {
 	}
 	childList = append(childList, bi)
 	c.orphan[bh.HashPrevBlock] = childList
+
+	// Blocks with data waiting for their parent are the unlinked blocks
+	// CheckBlockIndex expects.
+	if bi.HasData() {
+		gPersist := persist.GetInstance()
+		gPersist.GlobalMapBlocksUnlinked[bi.Prev] = append(gPersist.GlobalMapBlocksUnlinked[bi.Prev], bi)
+	}
 	return nil
 }
 
@@ -737,6 +679,49 @@ func (c *Chain) IndexMapSize() int {
 	return len(c.indexMap)
 }
 
+// DynamicMemoryUsage returns an estimate of the memory used by the block
+// index, its entries and their headers.
+func (c *Chain) DynamicMemoryUsage() int64 {
+	entry := unsafe.Sizeof(util.Hash{}) + unsafe.Sizeof(&blockindex.BlockIndex{}) +
+		unsafe.Sizeof(blockindex.BlockIndex{})
+	return int64(len(c.indexMap)) * int64(entry)
+}
+
+// IndexList returns all the block indexes known to the chain.
+func (c *Chain) IndexList() []*blockindex.BlockIndex {
+	indexes := make([]*blockindex.BlockIndex, 0, len(c.indexMap))
+	for _, index := range c.indexMap {
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// FindEarliestAtLeast returns the first block of the active chain whose
+// TimeMax is at least t, or nil if there is none.
+func (c *Chain) FindEarliestAtLeast(t int64) *blockindex.BlockIndex {
+	// TimeMax never decreases along a chain.
+	height := sort.Search(len(c.active), func(i int) bool {
+		return int64(c.active[i].GetBlockTimeMax()) >= t
+	})
+	if height == len(c.active) {
+		return nil
+	}
+	return c.active[height]
+}
+
+// FindLatestMedianTimeAtMost returns the last block of the active chain whose
+// median time past is at most t, or nil if there is none.
+func (c *Chain) FindLatestMedianTimeAtMost(t int64) *blockindex.BlockIndex {
+	// The median time past never decreases along a chain.
+	height := sort.Search(len(c.active), func(i int) bool {
+		return c.active[i].GetMedianTimePast() > t
+	})
+	if height == 0 {
+		return nil
+	}
+	return c.active[height-1]
+}
+
 //BuildForwardTree Build forward-pointing map of the entire block tree.
 func (c *Chain) BuildForwardTree() (forward map[*blockindex.BlockIndex][]*blockindex.BlockIndex) {
 	forward = make(map[*blockindex.BlockIndex][]*blockindex.BlockIndex)
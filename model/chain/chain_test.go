@@ -191,6 +191,101 @@ func TestChain_Fork(t *testing.T) {
 
 }
 
+func TestChain_MostWorkTip(t *testing.T) {
+	testDir, err := initTestEnv(t, []string{""})
+	if err != nil {
+		t.Errorf("initTestEnv Error")
+	}
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	tChain := GetInstance()
+
+	tChain.indexMap = make(map[util.Hash]*blockindex.BlockIndex)
+	tChain.active = make([]*blockindex.BlockIndex, 0)
+	tChain.branch = make([]*blockindex.BlockIndex, 0)
+	initBits := model.ActiveNetParams.PowLimitBits
+	// Half the target of initBits, twice the work.
+	hardBits := pow.BigToCompact(new(big.Int).Rsh(pow.CompactToBig(initBits), 1))
+	timePerBlock := int64(model.ActiveNetParams.TargetTimePerBlock)
+
+	genesis := blockindex.NewBlockIndex(&model.ActiveNetParams.GenesisBlock.Header)
+	tChain.AddToIndexMap(genesis)
+	tChain.AddToBranch(genesis)
+	tChain.SetTip(genesis)
+
+	// Two tips at height 2, the second one with a harder block.
+	easy1 := getBlockIndexSimple(genesis, timePerBlock, initBits)
+	easy2 := getBlockIndexSimple(easy1, timePerBlock, initBits)
+	hard1 := getBlockIndexSimple(genesis, timePerBlock-1, initBits)
+	hard2 := getBlockIndexSimple(hard1, timePerBlock, hardBits)
+	for _, index := range []*blockindex.BlockIndex{hard1, hard2, easy1, easy2} {
+		// The chain work is computed when the header is accepted.
+		index.ChainWork = big.Int{}
+		tChain.AddToIndexMap(index)
+		tChain.AddToBranch(index)
+	}
+
+	wantWork := new(big.Int).Add(&genesis.ChainWork, pow.GetBlockProof(hard1))
+	wantWork.Add(wantWork, pow.GetBlockProof(hard2))
+	if hard2.ChainWork.Cmp(wantWork) != 0 {
+		t.Errorf("chain work %s, want %s", hard2.ChainWork.String(), wantWork.String())
+	}
+	if hard2.ChainWork.Cmp(&easy2.ChainWork) <= 0 {
+		t.Fatalf("tip with a harder block has %s work, other tip %s",
+			hard2.ChainWork.String(), easy2.ChainWork.String())
+	}
+	if tChain.FindMostWorkChain() != hard2 {
+		t.Errorf("FindMostWorkChain returned %v, want the tip with most work", tChain.FindMostWorkChain())
+	}
+	if tChain.GetIndexBestHeader() != hard2 {
+		t.Errorf("best header %v, want the tip with most work", tChain.GetIndexBestHeader())
+	}
+}
+
+func TestChain_EqualWorkTipsFirstReceived(t *testing.T) {
+	testDir, err := initTestEnv(t, []string{""})
+	if err != nil {
+		t.Errorf("initTestEnv Error")
+	}
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+
+	tChain := GetInstance()
+
+	tChain.indexMap = make(map[util.Hash]*blockindex.BlockIndex)
+	tChain.active = make([]*blockindex.BlockIndex, 0)
+	tChain.branch = make([]*blockindex.BlockIndex, 0)
+	initBits := model.ActiveNetParams.PowLimitBits
+	timePerBlock := int64(model.ActiveNetParams.TargetTimePerBlock)
+
+	genesis := blockindex.NewBlockIndex(&model.ActiveNetParams.GenesisBlock.Header)
+	tChain.AddToIndexMap(genesis)
+	tChain.AddToBranch(genesis)
+	tChain.SetTip(genesis)
+
+	first := getBlockIndexSimple(genesis, timePerBlock, initBits)
+	second := getBlockIndexSimple(genesis, timePerBlock+1, initBits)
+	tChain.AddToIndexMap(first)
+	tChain.AddToBranch(first)
+	if tChain.FindMostWorkChain() != first {
+		t.Fatalf("FindMostWorkChain returned %v, want the first block", tChain.FindMostWorkChain())
+	}
+	tChain.SetTip(first)
+
+	tChain.AddToIndexMap(second)
+	tChain.AddToBranch(second)
+	if first.ChainWork.Cmp(&second.ChainWork) != 0 {
+		t.Fatalf("competing blocks have %s and %s work", first.ChainWork.String(), second.ChainWork.String())
+	}
+	if first.SequenceID >= second.SequenceID {
+		t.Errorf("first block received has sequence id %d, second %d", first.SequenceID, second.SequenceID)
+	}
+	if tChain.FindMostWorkChain() != first {
+		t.Errorf("block received later with the same work displaced the tip")
+	}
+}
+
 func TestChain_InitLoad(t *testing.T) {
 	//makeTestBlockTreeDB()
 	//InitGlobalChain(blkdb.GetInstance())
@@ -276,6 +371,29 @@ func TestChain_InitLoad(t *testing.T) {
 	}
 }
 
+func TestGetScriptFlagsUsesGivenParams(t *testing.T) {
+	if conf.Args == nil {
+		conf.Args = new(conf.Opts)
+	}
+
+	// The flags follow the params passed in, not the active network.
+	params := model.MainNetParams
+	params.UAHFHeight = 10
+	params.DAAHeight = 20
+	forkID := uint32(script.ScriptEnableSigHashForkID)
+	lowS := uint32(script.ScriptVerifyLowS)
+
+	if flags := GetScriptFlags(&params, 8, 0); flags&forkID != 0 {
+		t.Errorf("SIGHASH_FORKID enabled before the UAHF height, flags: %d", flags)
+	}
+	if flags := GetScriptFlags(&params, 10, 0); flags&forkID == 0 || flags&lowS != 0 {
+		t.Errorf("wrong flags after the UAHF height: %d", flags)
+	}
+	if flags := GetScriptFlags(&params, 20, 0); flags&lowS == 0 {
+		t.Errorf("LOW_S not enabled after the DAA height, flags: %d", flags)
+	}
+}
+
 func TestChain_GetBlockScriptFlags(t *testing.T) {
 	testDir, err := initTestEnv(t, []string{"--testnet"})
 	if err != nil {
@@ -0,0 +1,70 @@
+package chain
+
+import (
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/script"
+)
+
+// P2SHActivationTime BIP16 didn't become active until Apr 1 2012
+const P2SHActivationTime = 1333234914
+
+// GetScriptFlags computes the script verification flags in force on the network
+// for the block built on top of a block at prevHeight whose median time past is
+// prevMTP.
+// Block connection uses the flags of the block being connected, and mempool
+// acceptance always uses the flags of the next block on top of the tip, so a
+// rule change takes effect at exactly the same point for both.
+func GetScriptFlags(param *model.BitcoinParams, prevHeight int32, prevMTP int64) uint32 {
+	var flags uint32 = script.ScriptVerifyNone
+	if prevMTP >= P2SHActivationTime {
+		flags |= script.ScriptVerifyP2SH
+	}
+
+	// Start enforcing the DERSIG (BIP66) rule
+	if prevHeight+1 >= param.BIP66Height {
+		flags |= script.ScriptVerifyDersig
+	}
+
+	// Start enforcing CHECKLOCKTIMEVERIFY (BIP65) rule
+	if prevHeight+1 >= param.BIP65Height {
+		flags |= script.ScriptVerifyCheckLockTimeVerify
+	}
+
+	// Start enforcing CSV (BIP68, BIP112 and BIP113) rule.
+	if prevHeight+1 >= param.CSVHeight {
+		flags |= script.ScriptVerifyCheckSequenceVerify
+	}
+
+	// If the UAHF is enabled, we start accepting replay protected txns
+	if param.IsUAHFEnabled(prevHeight) {
+		flags |= script.ScriptVerifyStrictEnc
+		flags |= script.ScriptEnableSigHashForkID
+	}
+
+	// If the Cash HF is enabled, we start rejecting transaction that use a high
+	// s in their signature. We also make sure that signature that are supposed
+	// to fail (for instance in multisig or other forms of smart contracts) are
+	// null.
+	if param.IsDAAEnabled(prevHeight) {
+		flags |= script.ScriptVerifyLowS
+		flags |= script.ScriptVerifyNullFail
+	}
+
+	// When the magnetic anomaly fork is enabled, we start accepting
+	// transactions using the OP_CHECKDATASIG opcode and it's verify
+	// alternative. We also start enforcing push only signatures and
+	// clean stack.
+	if param.IsMagneticAnomalyEnabled(prevMTP) {
+		flags |= script.ScriptEnableCheckDataSig
+		flags |= script.ScriptVerifySigPushOnly
+		flags |= script.ScriptVerifyCleanStack
+	}
+
+	// We make sure this node will have replay protection during the next hard
+	// fork.
+	if param.IsReplayProtectionEnabled(prevMTP) {
+		flags |= script.ScriptEnableReplayProtection
+	}
+
+	return flags
+}
@@ -1,6 +1,7 @@
 package chain
 
 import (
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/model/blockindex"
 	"github.com/copernet/copernicus/model/pow"
 	"github.com/copernet/copernicus/util"
@@ -19,13 +20,14 @@ func (ds *SyncingState) UpdateSyncingState() {
 
 	tip := GetInstance().Tip()
 
-	if tipInOneDay(tip) && hasEnoughWork(tip) {
+	if tipIsRecent(tip) && hasEnoughWork(tip) {
 		atomic.StoreInt32(&ds.isCurrent, 1)
 	}
 }
 
-func tipInOneDay(tip *blockindex.BlockIndex) bool {
-	return int64(tip.GetBlockTime()) > util.GetTimeSec()-24*60*60
+// tipIsRecent returns whether the tip is less than maxtipage old.
+func tipIsRecent(tip *blockindex.BlockIndex) bool {
+	return util.GetTimeSec()-int64(tip.GetBlockTime()) < conf.Cfg.Chain.MaxTipAge
 }
 
 func hasEnoughWork(tip *blockindex.BlockIndex) bool {
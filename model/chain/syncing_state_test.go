@@ -0,0 +1,39 @@
+package chain
+
+import (
+	"os"
+	"testing"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/util"
+)
+
+func TestSyncingStateMaxTipAge(t *testing.T) {
+	testDir, err := initTestEnv(t, []string{"--regtest"})
+	if err != nil {
+		t.Fatalf("initTestEnv failed: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	defer cleanTestEnv()
+	initGenesis()
+
+	tChain := GetInstance()
+	tChain.SyncingState = &SyncingState{}
+	genesis := tChain.FindBlockIndex(*model.ActiveNetParams.GenesisHash)
+	tip := getBlockIndexSimple(genesis, 0, model.ActiveNetParams.PowLimitBits)
+	tip.Header.Time = uint32(util.GetTimeSec() - 2*60*60)
+
+	// a two hours old tip is too old for a one hour maxtipage
+	conf.Cfg.Chain.MaxTipAge = 60 * 60
+	tChain.SetTip(tip)
+	if tChain.IsAlmostSynced() {
+		t.Fatal("left initial block download with a tip older than maxtipage")
+	}
+
+	conf.Cfg.Chain.MaxTipAge = 24 * 60 * 60
+	tChain.SetTip(tip)
+	if !tChain.IsAlmostSynced() {
+		t.Fatal("still in initial block download with a tip younger than maxtipage")
+	}
+}
@@ -0,0 +1,65 @@
+package mempool
+
+import (
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/utxo"
+)
+
+// MempoolCoinsView overlays the outputs of the transactions in the mempool on
+// a view of the confirmed coins, so that the inputs of a transaction spending
+// unconfirmed parents can be resolved. The outputs spent by a transaction in
+// the mempool are seen as spent.
+type MempoolCoinsView struct {
+	base utxo.CoinsView
+	pool *TxMempool
+}
+
+// NewMempoolCoinsView returns a view of the coins of base and of the
+// transactions in pool.
+func NewMempoolCoinsView(base utxo.CoinsView, pool *TxMempool) *MempoolCoinsView {
+	return &MempoolCoinsView{
+		base: base,
+		pool: pool,
+	}
+}
+
+// GetCoin returns the coin of the outpoint, which is spent when a transaction
+// in the mempool spends it, or nil when neither base nor the mempool has it.
+// The pool must not be locked by the caller.
+func (v *MempoolCoinsView) GetCoin(out *outpoint.OutPoint) *utxo.Coin {
+	v.pool.RLock()
+	defer v.pool.RUnlock()
+
+	coin := v.base.GetCoin(out)
+	if coin == nil {
+		coin = v.pool.GetCoin(out)
+	}
+	if coin == nil || coin.IsSpent() {
+		return coin
+	}
+	if _, ok := v.pool.nextTx[*out]; ok {
+		coin = coin.DeepCopy()
+		coin.Clear()
+	}
+	return coin
+}
+
+// HaveCoin returns whether the outpoint has a coin not spent yet.
+func (v *MempoolCoinsView) HaveCoin(out *outpoint.OutPoint) bool {
+	coin := v.GetCoin(out)
+	return coin != nil && !coin.IsSpent()
+}
+
+// FetchUtxoView returns the unspent coins the inputs of the transaction spend.
+// The inputs spending a missing or spent coin are left out.
+func (v *MempoolCoinsView) FetchUtxoView(txn *tx.Tx) *utxo.CoinsMap {
+	coinsMap := utxo.NewEmptyCoinsMap()
+	for _, in := range txn.GetIns() {
+		coin := v.GetCoin(in.PreviousOutPoint)
+		if coin != nil && !coin.IsSpent() {
+			coinsMap.AddCoin(in.PreviousOutPoint, coin, coin.IsCoinBase())
+		}
+	}
+	return coinsMap
+}
@@ -0,0 +1,83 @@
+package mempool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/util"
+)
+
+func TestMempoolCoinsView(t *testing.T) {
+	scriptPubKey := script.NewScriptRaw([]byte{opcodes.OP_TRUE})
+	spend := func(numOuts int, prevOuts ...*outpoint.OutPoint) *tx.Tx {
+		txn := tx.NewTx(0, tx.TxVersion)
+		for _, prevOut := range prevOuts {
+			txn.AddTxIn(txin.NewTxIn(prevOut, script.NewEmptyScript(), script.SequenceFinal))
+		}
+		for i := 0; i < numOuts; i++ {
+			txn.AddTxOut(txout.NewTxOut(1000, scriptPubKey))
+		}
+		return txn
+	}
+
+	confirmed := outpoint.NewOutPoint(util.HashOne, 0)
+	base := utxo.NewEmptyCoinsMap()
+	base.AddCoin(confirmed, utxo.NewFreshCoin(txout.NewTxOut(3000, scriptPubKey), 1, false), false)
+
+	parent := spend(2, confirmed)
+	child := spend(1, outpoint.NewOutPoint(parent.GetHash(), 0))
+	grandchild := spend(1, outpoint.NewOutPoint(child.GetHash(), 0), outpoint.NewOutPoint(parent.GetHash(), 1))
+
+	pool := NewTxMempool()
+	noLimit := uint64(math.MaxUint64)
+	for _, txn := range []*tx.Tx{parent, child} {
+		ancestors, err := pool.CalculateMemPoolAncestors(txn, noLimit, noLimit, noLimit, noLimit, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.AddTx(NewTestMemPoolEntry().FromTxToEntry(txn), ancestors); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	view := NewMempoolCoinsView(base, pool)
+
+	// The inputs of the grandchild are outputs of its two unconfirmed
+	// ancestors.
+	coins := view.FetchUtxoView(grandchild)
+	for _, in := range grandchild.GetIns() {
+		coin := coins.GetCoin(in.PreviousOutPoint)
+		if coin == nil {
+			t.Fatalf("input %v of the grandchild not resolved", in.PreviousOutPoint)
+		}
+		if !coin.IsMempoolCoin() || coin.GetAmount() != 1000 {
+			t.Fatalf("input %v resolved to %v", in.PreviousOutPoint, coin)
+		}
+	}
+
+	// The outputs spent in the mempool are spent, whether they are
+	// confirmed or not.
+	for _, out := range []*outpoint.OutPoint{confirmed, outpoint.NewOutPoint(parent.GetHash(), 0)} {
+		coin := view.GetCoin(out)
+		if coin == nil || !coin.IsSpent() || view.HaveCoin(out) {
+			t.Fatalf("coin %v spent in the mempool is not spent: %v", out, coin)
+		}
+	}
+	if base.GetCoin(confirmed).IsSpent() {
+		t.Fatal("the coin of base was spent")
+	}
+	if len(view.FetchUtxoView(child).GetMap()) != 0 {
+		t.Fatal("the input of the child, spent by itself, was resolved")
+	}
+
+	if view.GetCoin(outpoint.NewOutPoint(util.HashOne, 1)) != nil {
+		t.Fatal("got a coin for an unknown outpoint")
+	}
+}
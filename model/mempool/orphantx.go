@@ -0,0 +1,288 @@
+package mempool
+
+import (
+	"math/rand"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/util"
+)
+
+const (
+	// OrphanTxExpireTime is how long, in seconds, an orphan waits for its
+	// parents before it is dropped.
+	OrphanTxExpireTime = 20 * 60
+	// OrphanTxExpireInterval is the minimum time, in seconds, between two
+	// sweeps of the expired orphans.
+	OrphanTxExpireInterval = 5 * 60
+	// DefaultMaxOrphanTransaction is the default number of orphans kept,
+	// beyond which random ones are evicted. It is changed with
+	// -maxorphantx.
+	DefaultMaxOrphanTransaction = 100
+	// MaxOrphanTxSize is the size of the largest orphan kept. Larger
+	// transactions would not be relayed once their parents arrive anyway.
+	MaxOrphanTxSize = int(tx.MaxStandardTxSize)
+	// MaxOrphanTxTotalSize bounds the sum of the sizes of the orphans.
+	MaxOrphanTxTotalSize = 5000000
+	// OrphanTxPeerShare is the part of the orphan limit, as a divisor, a
+	// single peer may have in the pool, so that one peer can not push out
+	// the orphans of the others.
+	OrphanTxPeerShare = 4
+)
+
+// OrphanTx is a transaction spending outputs we do not know about yet.
+// NodeID is the peer it came from, 0 when it did not come from a peer.
+type OrphanTx struct {
+	Tx         *tx.Tx
+	NodeID     int64
+	Expiration int64
+	Size       int
+}
+
+// AddOrphanTx keeps the transaction until its parents arrive, and returns
+// whether it was added. Orphans from a peer over its share of the limit are
+// refused, and random orphans are evicted when the pool goes over its limits.
+func (m *TxMempool) AddOrphanTx(orphantx *tx.Tx, nodeID int64) bool {
+	hash := orphantx.GetHash()
+	size := int(orphantx.EncodeSize())
+	if size > MaxOrphanTxSize {
+		log.Debug("ignoring large orphan tx (size: %d, hash: %s)", size, hash)
+		return false
+	}
+
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+
+	if _, ok := m.orphans[hash]; ok {
+		return false
+	}
+	if nodeID != 0 && m.orphansByPeer[nodeID] >= m.maxOrphanTxPerPeer() {
+		log.Debug("ignoring orphan tx %s, peer %d has %d orphans", hash, nodeID, m.orphansByPeer[nodeID])
+		return false
+	}
+
+	o := &OrphanTx{
+		Tx:         orphantx,
+		NodeID:     nodeID,
+		Expiration: util.GetTimeSec() + OrphanTxExpireTime,
+		Size:       size,
+	}
+	m.orphans[hash] = o
+	for _, preout := range orphantx.GetAllPreviousOut() {
+		if exist, ok := m.orphansByPrev[preout]; ok {
+			exist[hash] = o
+		} else {
+			m.orphansByPrev[preout] = map[util.Hash]*OrphanTx{hash: o}
+		}
+	}
+	m.orphansByPeer[nodeID]++
+	m.orphansSize += size
+
+	evicted := m.limitOrphanTx()
+	if evicted > 0 {
+		log.Debug("Orphan transaction overflow, removed %d orphan tx", evicted)
+	}
+	_, ok := m.orphans[hash]
+	return ok
+}
+
+func (m *TxMempool) IsOrphanInPool(tx *tx.Tx) bool {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+
+	_, exists := m.orphans[tx.GetHash()]
+	return exists
+}
+
+// FindOrphanTx returns the orphan with the hash, or nil if there is none.
+func (m *TxMempool) FindOrphanTx(hash util.Hash) *tx.Tx {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+
+	if orphan, ok := m.orphans[hash]; ok {
+		return orphan.Tx
+	}
+	return nil
+}
+
+// OrphansSpending returns a copy of the orphans spending the outpoint.
+func (m *TxMempool) OrphansSpending(out outpoint.OutPoint) []OrphanTx {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+
+	orphans := make([]OrphanTx, 0, len(m.orphansByPrev[out]))
+	for _, orphan := range m.orphansByPrev[out] {
+		orphans = append(orphans, *orphan)
+	}
+	return orphans
+}
+
+// OrphanCount returns the number of orphans in the pool.
+func (m *TxMempool) OrphanCount() int {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+	return len(m.orphans)
+}
+
+// OrphanBytes returns the sum of the sizes of the orphans in the pool.
+func (m *TxMempool) OrphanBytes() int {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+	return m.orphansSize
+}
+
+// MaxOrphanTx returns the number of orphans kept.
+func (m *TxMempool) MaxOrphanTx() int {
+	m.orphanLck.RLock()
+	defer m.orphanLck.RUnlock()
+	return m.maxOrphanTx
+}
+
+// maxOrphanTxPerPeer returns the number of orphans a single peer may have,
+// its share of the orphan limit. The caller holds orphanLck.
+func (m *TxMempool) maxOrphanTxPerPeer() int {
+	if m.maxOrphanTx > 0 && m.maxOrphanTx < OrphanTxPeerShare {
+		return 1
+	}
+	return m.maxOrphanTx / OrphanTxPeerShare
+}
+
+// SetMaxOrphanTx changes the number of orphans kept, and the share of a
+// single peer with it, evicting random orphans beyond it. It returns the
+// number of orphans evicted.
+func (m *TxMempool) SetMaxOrphanTx(maxOrphanTx int) int {
+	if maxOrphanTx < 0 {
+		maxOrphanTx = 0
+	}
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+
+	m.maxOrphanTx = maxOrphanTx
+	return m.limitOrphanTx()
+}
+
+// EraseOrphanTx removes the orphan, and with removeRedeemers the orphans
+// spending its outputs too.
+func (m *TxMempool) EraseOrphanTx(txHash util.Hash, removeRedeemers bool) {
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+	m.eraseOrphanTx(txHash, removeRedeemers)
+}
+
+// eraseOrphanTx returns the number of orphans removed. The caller holds
+// orphanLck.
+func (m *TxMempool) eraseOrphanTx(txHash util.Hash, removeRedeemers bool) int {
+	orphan, ok := m.orphans[txHash]
+	if !ok {
+		return 0
+	}
+	for _, preout := range orphan.Tx.GetAllPreviousOut() {
+		if orphans, exist := m.orphansByPrev[preout]; exist {
+			delete(orphans, txHash)
+			if len(orphans) == 0 {
+				delete(m.orphansByPrev, preout)
+			}
+		}
+	}
+	delete(m.orphans, txHash)
+	m.orphansSize -= orphan.Size
+	if m.orphansByPeer[orphan.NodeID]--; m.orphansByPeer[orphan.NodeID] == 0 {
+		delete(m.orphansByPeer, orphan.NodeID)
+	}
+
+	removed := 1
+	if removeRedeemers {
+		preout := outpoint.OutPoint{Hash: txHash}
+		for i := 0; i < orphan.Tx.GetOutsCount(); i++ {
+			preout.Index = uint32(i)
+			for hash := range m.orphansByPrev[preout] {
+				removed += m.eraseOrphanTx(hash, true)
+			}
+		}
+	}
+	return removed
+}
+
+// EraseOrphansForBlock removes the orphans confirmed by the transactions of a
+// connected block, keeping the orphans spending them so that they can now be
+// accepted, and the orphans in conflict with the block along with the orphans
+// spending them. It returns the number of orphans removed.
+func (m *TxMempool) EraseOrphansForBlock(txs []*tx.Tx) int {
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+
+	numErased := 0
+	for _, txn := range txs {
+		txHash := txn.GetHash()
+		numErased += m.eraseOrphanTx(txHash, false)
+		for _, preout := range txn.GetAllPreviousOut() {
+			for hash := range m.orphansByPrev[preout] {
+				if hash != txHash {
+					numErased += m.eraseOrphanTx(hash, true)
+				}
+			}
+		}
+	}
+	return numErased
+}
+
+// limitOrphanTx drops the expired orphans, at most once every
+// OrphanTxExpireInterval, then evicts random orphans until the pool is within
+// its limits. The caller holds orphanLck.
+func (m *TxMempool) limitOrphanTx() (removeNum int) {
+	now := util.GetTimeSec()
+	if m.nextSweep <= now {
+		minExpTime := now + OrphanTxExpireTime - OrphanTxExpireInterval
+		for hash, orphan := range m.orphans {
+			if orphan.Expiration <= now {
+				removeNum += m.eraseOrphanTx(hash, true)
+			} else if minExpTime > orphan.Expiration {
+				minExpTime = orphan.Expiration
+			}
+		}
+		m.nextSweep = minExpTime + OrphanTxExpireInterval
+	}
+
+	for len(m.orphans) > m.maxOrphanTx || m.orphansSize > MaxOrphanTxTotalSize {
+		removeNum += m.eraseOrphanTx(m.randomOrphan(), true)
+	}
+	return
+}
+
+func (m *TxMempool) randomOrphan() util.Hash {
+	n := rand.Intn(len(m.orphans))
+	for hash := range m.orphans {
+		if n == 0 {
+			return hash
+		}
+		n--
+	}
+	return util.HashZero
+}
+
+// RemoveOrphansByTag removes the orphans that came from the peer, and returns
+// the number of orphans removed.
+func (m *TxMempool) RemoveOrphansByTag(nodeID int64) int {
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+
+	numEvicted := 0
+	for hash, otx := range m.orphans {
+		if otx.NodeID == nodeID {
+			numEvicted += m.eraseOrphanTx(hash, true)
+		}
+	}
+	return numEvicted
+}
+
+func (m *TxMempool) CleanOrphan() {
+	m.orphanLck.Lock()
+	defer m.orphanLck.Unlock()
+
+	m.orphans = make(map[util.Hash]*OrphanTx)
+	m.orphansByPrev = make(map[outpoint.OutPoint]map[util.Hash]*OrphanTx)
+	m.orphansByPeer = make(map[int64]int)
+	m.orphansSize = 0
+	log.Debug("mempool.CleanOrphan clean all orphan txs")
+}
@@ -0,0 +1,153 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+// newOrphanTx returns a transaction spending an output of an unknown parent
+// made from n.
+func newOrphanTx(n uint32) *tx.Tx {
+	parent := util.DoubleSha256([]byte{byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)})
+	orphan := tx.NewTx(0, tx.DefaultVersion)
+	orphan.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(parent, 0), script.NewEmptyScript(), 0))
+	orphan.AddTxOut(txout.NewTxOut(amount.Amount(1000), script.NewEmptyScript()))
+	return orphan
+}
+
+func TestOrphanTxPerPeerLimit(t *testing.T) {
+	mp := NewTxMempool()
+	perPeer := DefaultMaxOrphanTransaction / OrphanTxPeerShare
+
+	for i := 0; i < perPeer; i++ {
+		if !mp.AddOrphanTx(newOrphanTx(uint32(i)), 1) {
+			t.Fatalf("orphan %d of peer 1 refused", i)
+		}
+	}
+	overLimit := newOrphanTx(uint32(perPeer))
+	if mp.AddOrphanTx(overLimit, 1) {
+		t.Fatal("peer 1 got more orphans than its share")
+	}
+	if mp.IsOrphanInPool(overLimit) {
+		t.Fatal("refused orphan is in the pool")
+	}
+
+	// The other peers are not affected.
+	if !mp.AddOrphanTx(overLimit, 2) {
+		t.Fatal("orphan of peer 2 refused")
+	}
+
+	// Removing the orphans of a peer makes room for it again.
+	if n := mp.RemoveOrphansByTag(1); n != perPeer {
+		t.Fatalf("removed %d orphans of peer 1, want %d", n, perPeer)
+	}
+	if !mp.AddOrphanTx(newOrphanTx(0), 1) {
+		t.Fatal("orphan of peer 1 refused after its orphans were removed")
+	}
+	if n := mp.OrphanCount(); n != 2 {
+		t.Fatalf("got %d orphans, want 2", n)
+	}
+}
+
+func TestOrphanTxGlobalLimit(t *testing.T) {
+	mp := NewTxMempool()
+
+	// Orphans not from a peer are only bound by the global limit, which
+	// evicts random orphans.
+	for i := 0; i < DefaultMaxOrphanTransaction*2; i++ {
+		mp.AddOrphanTx(newOrphanTx(uint32(i)), 0)
+	}
+	if n := mp.OrphanCount(); n != DefaultMaxOrphanTransaction {
+		t.Fatalf("got %d orphans, want %d", n, DefaultMaxOrphanTransaction)
+	}
+}
+
+func TestOrphanTxExpiration(t *testing.T) {
+	now := util.GetTimeSec()
+	util.SetMockTime(now)
+	defer util.SetMockTime(0)
+
+	mp := NewTxMempool()
+	old := newOrphanTx(0)
+	mp.AddOrphanTx(old, 1)
+
+	util.SetMockTime(now + OrphanTxExpireTime - 1)
+	mp.AddOrphanTx(newOrphanTx(1), 1)
+	if !mp.IsOrphanInPool(old) {
+		t.Fatal("orphan evicted before it expired")
+	}
+
+	// The orphans are swept when the next orphan is added.
+	util.SetMockTime(now + OrphanTxExpireTime)
+	fresh := newOrphanTx(2)
+	mp.AddOrphanTx(fresh, 1)
+	if mp.IsOrphanInPool(old) {
+		t.Fatal("expired orphan still in the pool")
+	}
+	if !mp.IsOrphanInPool(fresh) {
+		t.Fatal("fresh orphan not in the pool")
+	}
+	if n := mp.OrphanCount(); n != 2 {
+		t.Fatalf("got %d orphans, want 2", n)
+	}
+}
+
+func TestSetMaxOrphanTx(t *testing.T) {
+	mp := NewTxMempool()
+	mp.SetMaxOrphanTx(2)
+
+	orphans := []*tx.Tx{newOrphanTx(0), newOrphanTx(1), newOrphanTx(2)}
+	for _, orphan := range orphans {
+		mp.AddOrphanTx(orphan, 0)
+	}
+	if n := mp.OrphanCount(); n != 2 {
+		t.Fatalf("got %d orphans, want 2", n)
+	}
+	if got, want := mp.OrphanBytes(), 2*int(orphans[0].EncodeSize()); got != want {
+		t.Fatalf("got %d orphan bytes, want %d", got, want)
+	}
+
+	// Lowering the limit evicts the orphans over it.
+	if n := mp.SetMaxOrphanTx(1); n != 1 {
+		t.Fatalf("evicted %d orphans, want 1", n)
+	}
+	if n := mp.OrphanCount(); n != 1 {
+		t.Fatalf("got %d orphans, want 1", n)
+	}
+	if n := mp.SetMaxOrphanTx(0); n != 1 || mp.OrphanCount() != 0 || mp.OrphanBytes() != 0 {
+		t.Fatalf("evicted %d orphans, left %d orphans of %d bytes, want all evicted",
+			n, mp.OrphanCount(), mp.OrphanBytes())
+	}
+}
+
+func TestSetMaxOrphanTxPerPeer(t *testing.T) {
+	mp := NewTxMempool()
+
+	// Raising the limit raises the share of a peer with it.
+	mp.SetMaxOrphanTx(4 * DefaultMaxOrphanTransaction)
+	for i := 0; i < DefaultMaxOrphanTransaction; i++ {
+		if !mp.AddOrphanTx(newOrphanTx(uint32(i)), 1) {
+			t.Fatalf("orphan %d of peer 1 refused", i)
+		}
+	}
+	if mp.AddOrphanTx(newOrphanTx(DefaultMaxOrphanTransaction), 1) {
+		t.Fatal("peer 1 got more orphans than its share")
+	}
+
+	// A peer keeps one orphan under a limit smaller than the share divisor.
+	mp.SetMaxOrphanTx(OrphanTxPeerShare - 1)
+	mp.RemoveOrphansByTag(1)
+	if !mp.AddOrphanTx(newOrphanTx(0), 1) {
+		t.Fatal("orphan of peer 1 refused under a small limit")
+	}
+	if mp.AddOrphanTx(newOrphanTx(1), 1) {
+		t.Fatal("peer 1 got more than one orphan under a small limit")
+	}
+}
@@ -0,0 +1,140 @@
+package mempool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/util"
+)
+
+// DumpFileName is the name of the file in the data directory the mempool is
+// saved to on shutdown and loaded from on startup.
+const DumpFileName = "mempool.dat"
+
+// dumpVersion is the version of the mempool.dat format.
+const dumpVersion = 1
+
+// ErrDumpInProgress is returned by Dump while another dump of the mempool is
+// being written.
+var ErrDumpInProgress = errors.New("mempool dump already in progress")
+
+// dumpEntry is a transaction of the mempool as saved by Dump.
+type dumpEntry struct {
+	tx        *tx.Tx
+	time      int64
+	ancestors int64
+}
+
+// Dump saves the transactions of the mempool to the file at path, parents
+// before their children so Load can accept them again in order. The file is
+// written aside and renamed over path, a failed dump leaves the previous one
+// intact. Only one dump runs at a time, others fail with ErrDumpInProgress.
+func (m *TxMempool) Dump(path string) error {
+	if !atomic.CompareAndSwapInt32(&m.dumping, 0, 1) {
+		return ErrDumpInProgress
+	}
+	defer atomic.StoreInt32(&m.dumping, 0)
+
+	m.RLock()
+	entries := make([]dumpEntry, 0, len(m.poolData))
+	for _, entry := range m.poolData {
+		entries = append(entries, dumpEntry{
+			tx:        entry.Tx,
+			time:      entry.time,
+			ancestors: entry.SumTxCountWithAncestors,
+		})
+	}
+	m.RUnlock()
+
+	// A transaction has more ancestors in the mempool than any of its parents.
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ancestors != entries[j].ancestors {
+			return entries[i].ancestors < entries[j].ancestors
+		}
+		return entries[i].time < entries[j].time
+	})
+
+	tmpPath := path + ".new"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = writeDump(file, entries)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func writeDump(file *os.File, entries []dumpEntry) error {
+	w := bufio.NewWriter(file)
+	err := util.WriteElements(w, uint64(dumpVersion), uint64(len(entries)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := entry.tx.Serialize(w); err != nil {
+			return err
+		}
+		if err := util.WriteElements(w, entry.time); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Load reads the transactions saved by Dump to the file at path and hands
+// them in order to accept, along with the time they entered the mempool. It
+// returns how many of them accept took, a transaction accept refuses is
+// skipped. A missing file is not an error, there is nothing to load.
+func Load(path string, accept func(txn *tx.Tx, entryTime int64) error) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var version, count uint64
+	if err := util.ReadElements(r, &version, &count); err != nil {
+		return 0, err
+	}
+	if version != dumpVersion {
+		return 0, fmt.Errorf("unknown mempool dump version %d", version)
+	}
+
+	accepted := 0
+	for i := uint64(0); i < count; i++ {
+		txn := tx.NewEmptyTx()
+		if err := txn.Unserialize(r); err != nil {
+			return accepted, err
+		}
+		var entryTime int64
+		if err := util.ReadElements(r, &entryTime); err != nil {
+			return accepted, err
+		}
+		if accept(txn, entryTime) == nil {
+			accepted++
+		}
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return accepted, fmt.Errorf("trailing data after %d transactions", count)
+	}
+	return accepted, nil
+}
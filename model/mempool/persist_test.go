@@ -0,0 +1,35 @@
+package mempool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDumpInProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempooldump")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DumpFileName)
+
+	pool := NewTxMempool()
+	pool.dumping = 1
+	if err := pool.Dump(path); err != ErrDumpInProgress {
+		t.Fatalf("dump during another one returned %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("refused dump wrote the file")
+	}
+
+	pool.dumping = 0
+	if err := pool.Dump(path); err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	loaded, err := Load(path, nil)
+	if err != nil || loaded != 0 {
+		t.Errorf("load of an empty dump returned %d, %v", loaded, err)
+	}
+}
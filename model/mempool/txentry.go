@@ -7,6 +7,7 @@ import (
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/util"
 	"github.com/copernet/copernicus/util/algorithm/mapcontainer"
+	"github.com/copernet/copernicus/util/amount"
 )
 
 type TxEntry struct {
@@ -128,7 +129,7 @@ func NewTxentry(tx *tx.Tx, txFee int64, acceptTime int64, height int32, lp LockP
 	t := new(TxEntry)
 	t.Tx = tx
 	t.time = acceptTime
-	t.TxSize = int(tx.SerializeSize())
+	t.TxSize = int(tx.GetVirtualSize())
 	t.TxFee = txFee
 	t.usageSize = t.TxSize + int(unsafe.Sizeof(*t))
 	t.spendsCoinbase = spendCoinbase
@@ -152,7 +153,7 @@ func NewTxentry(tx *tx.Tx, txFee int64, acceptTime int64, height int32, lp LockP
 }
 
 func (t *TxEntry) GetFeeRate() *util.FeeRate {
-	return util.NewFeeRateWithSize(t.TxFee, int64(t.TxSize))
+	return util.NewFeeRate(amount.Amount(t.TxFee), t.TxSize)
 }
 
 func (t *TxEntry) GetInfo() *TxMempoolInfo {
@@ -189,8 +190,8 @@ type EntryAncestorFeeRateSort TxEntry
 func (r *EntryAncestorFeeRateSort) Less(than mapcontainer.Lesser) bool {
 	t := than.(*EntryAncestorFeeRateSort)
 
-	b1 := util.NewFeeRateWithSize((r).SumTxFeeWithAncestors, r.SumTxSizeWitAncestors).SataoshisPerK
-	b2 := util.NewFeeRateWithSize(t.SumTxFeeWithAncestors, t.SumTxSizeWitAncestors).SataoshisPerK
+	b1 := util.NewFeeRate(amount.Amount(r.SumTxFeeWithAncestors), int(r.SumTxSizeWitAncestors)).SataoshisPerK
+	b2 := util.NewFeeRate(amount.Amount(t.SumTxFeeWithAncestors), int(t.SumTxSizeWitAncestors)).SataoshisPerK
 	if b1 == b2 {
 		rhash := r.Tx.GetHash()
 		thhash := t.Tx.GetHash()
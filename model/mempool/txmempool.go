@@ -1,21 +1,20 @@
 package mempool
 
 import (
-	"fmt"
 	"math"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
-	"github.com/copernet/copernicus/model/consensus"
 	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/util"
 	"github.com/copernet/copernicus/util/algorithm/mapcontainer"
 	"github.com/copernet/copernicus/util/algorithm/mapcontainer/skiplist"
+	"github.com/copernet/copernicus/util/amount"
 )
 
 const (
@@ -83,17 +82,38 @@ type TxMempool struct {
 	// sum of all mempool tx's size.
 	totalTxSize uint64
 	//transactionsUpdated mempool update transaction total number when create mempool late.
-	TransactionsUpdated      uint64
-	OrphanTransactionsByPrev map[outpoint.OutPoint]map[util.Hash]OrphanTx
-	OrphanTransactions       map[util.Hash]OrphanTx
-
-	nextSweep int
+	TransactionsUpdated uint64
+
+	// orphanLck guards the orphan pool, which is independent of the
+	// transactions in the mempool.
+	orphanLck     sync.RWMutex
+	orphans       map[util.Hash]*OrphanTx
+	orphansByPrev map[outpoint.OutPoint]map[util.Hash]*OrphanTx
+	orphansByPeer map[int64]int
+	orphansSize   int
+	nextSweep     int64
+	maxOrphanTx   int
+
+	// conflicted keeps the transactions evicted because a block spent their
+	// inputs, oldest first in conflictedOrder, so their fate can still be
+	// reported after they left the pool.
+	conflicted      map[util.Hash]*tx.Tx
+	conflictedOrder []util.Hash
 
 	//MaxMemPoolSize               int64
-	incrementalRelayFee          util.FeeRate //
+	// minRelayTxFee is the lowest fee rate of an accepted transaction, and
+	// incrementalRelayFee raises the min fee over the evicted packages.
+	minRelayTxFee                util.FeeRate
+	incrementalRelayFee          util.FeeRate
 	rollingMinimumFeeRate        int64
 	blockSinceLastRollingFeeBump bool
 	lastRollingFeeUpdate         int64
+
+	// loaded is set once the mempool holds the transactions it is started
+	// with, accessed atomically.
+	loaded int32
+	// dumping is set while Dump writes the mempool, accessed atomically.
+	dumping int32
 }
 
 func (m *TxMempool) Lock() {
@@ -109,13 +129,28 @@ func (m *TxMempool) RUnlock() {
 	m.lck.RUnlock()
 }
 
+// IsLoaded returns whether the mempool is done loading the transactions it is
+// started with. Transactions are not submitted to it before.
+func (m *TxMempool) IsLoaded() bool {
+	return atomic.LoadInt32(&m.loaded) == 1
+}
+
+// SetLoaded marks the mempool as done loading its transactions, or not.
+func (m *TxMempool) SetLoaded(loaded bool) {
+	var v int32
+	if loaded {
+		v = 1
+	}
+	atomic.StoreInt32(&m.loaded, v)
+}
+
 func (m *TxMempool) GetCheckFrequency() uint64 {
 	return conf.Cfg.Mempool.CheckFrequency
 }
 
 func (m *TxMempool) GetMinFeeRate() util.FeeRate {
 	m.RLock()
-	feeRate := m.GetMinFee(conf.Cfg.Mempool.MaxPoolSize)
+	feeRate := m.GetMinFee(conf.Cfg.MaxPoolSize())
 	m.RUnlock()
 	return feeRate
 }
@@ -149,7 +184,7 @@ func (m *TxMempool) AddTx(txEntry *TxEntry, ancestors map[*TxEntry]struct{}) err
 	if txEntry.SumTxCountWithAncestors == 1 {
 		m.rootTx[txEntry.Tx.GetHash()] = txEntry
 	}
-	m.LimitMempoolSize(conf.Cfg.Mempool.MaxPoolSize, int64(conf.Cfg.Mempool.MaxPoolExpiry)*60*60)
+	m.LimitMempoolSize(conf.Cfg.MaxPoolSize(), int64(conf.Cfg.Mempool.MaxPoolExpiry)*60*60)
 	return nil
 }
 
@@ -161,12 +196,6 @@ func (m *TxMempool) HasSpentOut(out *outpoint.OutPoint) bool {
 	return ok
 }
 
-func (m *TxMempool) CleanOrphan() {
-	m.OrphanTransactionsByPrev = make(map[outpoint.OutPoint]map[util.Hash]OrphanTx)
-	m.OrphanTransactions = make(map[util.Hash]OrphanTx)
-	log.Debug("mempool.CleanOrphan clean txn: %v", m.OrphanTransactions)
-}
-
 func (m *TxMempool) HasSPentOutWithoutLock(out *outpoint.OutPoint) *TxEntry {
 	if e, ok := m.nextTx[*out]; ok {
 		return e
@@ -331,16 +360,40 @@ func (m *TxMempool) LimitMempoolSize(sizeLimit, age int64) {
 	}
 }
 
+// MinRelayTxFee returns the lowest fee rate of a transaction accepted into
+// the mempool, whatever its size.
+func (m *TxMempool) MinRelayTxFee() util.FeeRate {
+	m.RLock()
+	defer m.RUnlock()
+	return m.minRelayTxFee
+}
+
+// IncrementalRelayFee returns the fee rate the mempool min fee is raised by
+// over the fee rate of the packages evicted from a full mempool.
+func (m *TxMempool) IncrementalRelayFee() util.FeeRate {
+	m.RLock()
+	defer m.RUnlock()
+	return m.incrementalRelayFee
+}
+
+// SetRelayFees sets the -minrelaytxfee and -incrementalrelayfee fee rates.
+func (m *TxMempool) SetRelayFees(minRelayTxFee, incrementalRelayFee util.FeeRate) {
+	m.Lock()
+	m.minRelayTxFee = minRelayTxFee
+	m.incrementalRelayFee = incrementalRelayFee
+	m.Unlock()
+}
+
 func (m *TxMempool) trackPackageRemoved(rate util.FeeRate) {
-	if rate.GetFeePerK() > m.rollingMinimumFeeRate {
-		m.rollingMinimumFeeRate = rate.GetFeePerK()
+	if int64(rate.GetFeePerK()) > m.rollingMinimumFeeRate {
+		m.rollingMinimumFeeRate = int64(rate.GetFeePerK())
 		m.blockSinceLastRollingFeeBump = false
 	}
 }
 
 func (m *TxMempool) GetMinFee(sizeLimit int64) util.FeeRate {
 	if !m.blockSinceLastRollingFeeBump || m.rollingMinimumFeeRate == 0 {
-		return *util.NewFeeRate(m.rollingMinimumFeeRate)
+		return *util.NewFeeRatePerK(amount.Amount(m.rollingMinimumFeeRate))
 	}
 
 	timeTmp := util.GetTimeSec()
@@ -353,12 +406,12 @@ func (m *TxMempool) GetMinFee(sizeLimit int64) util.FeeRate {
 		}
 		m.rollingMinimumFeeRate = m.rollingMinimumFeeRate / int64(math.Pow(2.0, float64(timeTmp-m.lastRollingFeeUpdate))/float64(halfLife))
 		m.lastRollingFeeUpdate = timeTmp
-		if m.rollingMinimumFeeRate < m.incrementalRelayFee.GetFeePerK()/2 {
+		if m.rollingMinimumFeeRate < int64(m.incrementalRelayFee.GetFeePerK()/2) {
 			m.rollingMinimumFeeRate = 0
-			return *util.NewFeeRate(0)
+			return *util.NewFeeRatePerK(0)
 		}
 	}
-	rate := util.NewFeeRate(m.rollingMinimumFeeRate)
+	rate := util.NewFeeRatePerK(amount.Amount(m.rollingMinimumFeeRate))
 	if rate.SataoshisPerK > m.incrementalRelayFee.SataoshisPerK {
 		return *rate
 	}
@@ -375,7 +428,8 @@ func (m *TxMempool) trimToSize(sizeLimit int64) []*outpoint.OutPoint {
 	maxFeeRateRemove := int64(0)
 
 	for len(m.poolData) > 0 && m.usageSize > sizeLimit {
-		less, _ := m.txByAncestorFeeRateSort.Min()
+		// The sort puts the highest fee rates first, evict from the end.
+		less, _ := m.txByAncestorFeeRateSort.Max()
 		removeIt := less.(*EntryAncestorFeeRateSort)
 
 		rmless, _ := m.txByAncestorFeeRateSort.Delete(removeIt)
@@ -383,10 +437,11 @@ func (m *TxMempool) trimToSize(sizeLimit int64) []*outpoint.OutPoint {
 		if rem.Tx.GetHash() != removeIt.Tx.GetHash() {
 			panic("the two element should have the same Txhash")
 		}
-		removed := util.NewFeeRateWithSize(rem.TxFee, int64(rem.TxSize))
-		removed.SataoshisPerK += m.incrementalRelayFee.SataoshisPerK
-
-		maxFeeRateRemove = util.NewFeeRateWithSize(removeIt.SumTxFeeWithDescendants, removeIt.SumTxSizeWithDescendants).SataoshisPerK
+		maxFeeRateRemove = util.NewFeeRate(amount.Amount(removeIt.SumTxFeeWithDescendants),
+			int(removeIt.SumTxSizeWithDescendants)).SataoshisPerK
+		// the mempool min fee is raised over the fee rate of the evicted
+		// package, so that it is not replaced by a cheaper one
+		m.trackPackageRemoved(*util.NewFeeRatePerK(amount.Amount(maxFeeRateRemove + m.incrementalRelayFee.SataoshisPerK)))
 		stage := make(map[*TxEntry]struct{})
 		m.CalculateDescendants((*TxEntry)(removeIt), stage)
 		nTxnRemoved += len(stage)
@@ -414,7 +469,8 @@ func (m *TxMempool) trimToSize(sizeLimit int64) []*outpoint.OutPoint {
 
 	}
 
-	log.Debug("mempool", fmt.Sprintf("removed %d txn, rolling minimum fee bumped : %d", nTxnRemoved, maxFeeRateRemove))
+	log.PrintFields("mempool", "debug", log.Fields{"removed": nTxnRemoved, "minfee": maxFeeRateRemove},
+		"removed %d txn, rolling minimum fee bumped : %d", nTxnRemoved, maxFeeRateRemove)
 	return ret
 }
 
@@ -635,7 +691,7 @@ func (m *TxMempool) CalculateMemPoolAncestors(tx *tx.Tx, limitAncestorCount uint
 		j++
 	}
 
-	totalSizeWithAncestors := int64(tx.EncodeSize())
+	totalSizeWithAncestors := int64(tx.GetVirtualSize())
 	ancestors = make(map[*TxEntry]struct{})
 	for len(tempParents) > 0 {
 		entry := tempParents[0]
@@ -672,6 +728,10 @@ func (m *TxMempool) CalculateMemPoolAncestors(tx *tx.Tx, limitAncestorCount uint
 func (m *TxMempool) delTxentry(removeEntry *TxEntry, reason PoolRemovalReason) {
 	// todo add signal for any subscriber
 
+	if reason == CONFLICT {
+		m.addConflictedTx(removeEntry.Tx)
+	}
+
 	for _, preout := range removeEntry.Tx.GetAllPreviousOut() {
 		delete(m.nextTx, preout)
 	}
@@ -713,55 +773,52 @@ func NewTxMempool() *TxMempool {
 		// timeSortData:            *btree.New(32),
 		txByAncestorFeeRateSort: skiplist.New(30000),
 		timeSortData:            skiplist.New(30000),
-		incrementalRelayFee:     *util.NewFeeRate(1),
+		minRelayTxFee:           *util.NewFeeRatePerK(amount.Amount(util.DefaultMinRelayTxFeePerK)),
+		incrementalRelayFee:     *util.NewFeeRatePerK(1),
 
-		OrphanTransactionsByPrev: make(map[outpoint.OutPoint]map[util.Hash]OrphanTx),
-		OrphanTransactions:       make(map[util.Hash]OrphanTx),
+		orphans:       make(map[util.Hash]*OrphanTx),
+		orphansByPrev: make(map[outpoint.OutPoint]map[util.Hash]*OrphanTx),
+		orphansByPeer: make(map[int64]int),
+		maxOrphanTx:   DefaultMaxOrphanTransaction,
+		conflicted:    make(map[util.Hash]*tx.Tx),
 	}
 }
 
+// InitMempool replaces the mempool with an empty one, marked loaded. On
+// startup the node clears the mark while it loads the saved transactions.
 func InitMempool() {
 	gpool = NewTxMempool()
+	if conf.Cfg != nil {
+		gpool.SetMaxOrphanTx(conf.Cfg.Mempool.MaxOrphanTx)
+		gpool.SetRelayFees(*util.NewFeeRatePerK(amount.Amount(conf.Cfg.MinRelayTxFee())),
+			*util.NewFeeRatePerK(amount.Amount(conf.Cfg.IncrementalRelayFee())))
+	}
+	gpool.SetLoaded(true)
 }
 
-const (
-	OrphanTxExpireTime          = 20 * 60
-	OrphanTxExpireInterval      = 5 * 60
-	DefaultMaxOrphanTransaction = 100
-)
-
-type OrphanTx struct {
-	Tx         *tx.Tx
-	NodeID     int64
-	Expiration int
-}
+// MaxConflictedTransaction bounds the number of conflicted transactions
+// remembered by the mempool.
+const MaxConflictedTransaction = 1000
 
-func (m *TxMempool) AddOrphanTx(orphantx *tx.Tx, nodeID int64) {
-	if _, ok := m.OrphanTransactions[orphantx.GetHash()]; ok {
-		return
-	}
-	sz := orphantx.EncodeSize()
-	if sz >= consensus.MaxTxSize {
+func (m *TxMempool) addConflictedTx(conflictedTx *tx.Tx) {
+	hash := conflictedTx.GetHash()
+	if _, ok := m.conflicted[hash]; ok {
 		return
 	}
-
-	o := OrphanTx{Tx: orphantx, NodeID: nodeID, Expiration: time.Now().Second() + OrphanTxExpireTime}
-
-	m.OrphanTransactions[orphantx.GetHash()] = o
-	for _, preout := range orphantx.GetAllPreviousOut() {
-		if exist, ok := m.OrphanTransactionsByPrev[preout]; ok {
-			exist[o.Tx.GetHash()] = o
-		} else {
-			mi := make(map[util.Hash]OrphanTx)
-			mi[o.Tx.GetHash()] = o
-			m.OrphanTransactionsByPrev[preout] = mi
-		}
+	if len(m.conflictedOrder) >= MaxConflictedTransaction {
+		delete(m.conflicted, m.conflictedOrder[0])
+		m.conflictedOrder = m.conflictedOrder[1:]
 	}
+	m.conflicted[hash] = conflictedTx
+	m.conflictedOrder = append(m.conflictedOrder, hash)
+}
 
-	evicted := m.limitOrphanTx()
-	if evicted > 0 {
-		log.Debug("Orphan transaction overflow, removed %d orphan tx", evicted)
-	}
+// FindConflictedTx returns the transaction if it was evicted from the mempool
+// because a block spent its inputs, or nil otherwise.
+func (m *TxMempool) FindConflictedTx(hash util.Hash) *tx.Tx {
+	m.RLock()
+	defer m.RUnlock()
+	return m.conflicted[hash]
 }
 
 func (m *TxMempool) IsTransactionInPool(tx *tx.Tx) bool {
@@ -769,78 +826,6 @@ func (m *TxMempool) IsTransactionInPool(tx *tx.Tx) bool {
 	return exists
 }
 
-func (m *TxMempool) IsOrphanInPool(tx *tx.Tx) bool {
-	_, exists := m.OrphanTransactions[tx.GetHash()]
-	return exists
-}
-
 func (m *TxMempool) HaveTransaction(tx *tx.Tx) bool {
 	return m.IsTransactionInPool(tx) || m.IsOrphanInPool(tx)
 }
-
-func (m *TxMempool) EraseOrphanTx(txHash util.Hash, removeRedeemers bool) {
-
-	if orphanTx, ok := m.OrphanTransactions[txHash]; ok {
-		for _, preout := range orphanTx.Tx.GetAllPreviousOut() {
-			if orphans, exist := m.OrphanTransactionsByPrev[preout]; exist {
-				delete(orphans, txHash)
-				if len(orphans) == 0 {
-					delete(m.OrphanTransactionsByPrev, preout)
-				}
-			}
-		}
-	}
-	if removeRedeemers {
-		preout := outpoint.OutPoint{Hash: txHash}
-		orphan := m.OrphanTransactions[txHash]
-		for i := 0; i < orphan.Tx.GetOutsCount(); i++ {
-			preout.Index = uint32(i)
-			for _, orphan := range m.OrphanTransactionsByPrev[preout] {
-				m.EraseOrphanTx(orphan.Tx.GetHash(), true)
-			}
-		}
-	}
-	delete(m.OrphanTransactions, txHash)
-}
-
-func (m *TxMempool) limitOrphanTx() (removeNum int) {
-	now := time.Now().Second()
-	if m.nextSweep <= now {
-		minExpTime := now + OrphanTxExpireTime - OrphanTxExpireInterval
-		for hash, orphan := range m.OrphanTransactions {
-			if orphan.Expiration <= now {
-				m.EraseOrphanTx(hash, true)
-				removeNum++
-			} else {
-				if minExpTime > orphan.Expiration {
-					minExpTime = orphan.Expiration
-				}
-			}
-		}
-		m.nextSweep = minExpTime + OrphanTxExpireInterval
-	}
-
-	if len(m.OrphanTransactions) <= DefaultMaxOrphanTransaction {
-		return
-	}
-
-	for hash := range m.OrphanTransactions {
-		m.EraseOrphanTx(hash, true)
-		removeNum++
-		break
-	}
-	return
-}
-
-func (m *TxMempool) RemoveOrphansByTag(nodeID int64) int {
-	numEvicted := 0
-	m.Lock()
-	for _, otx := range m.OrphanTransactions {
-		if otx.NodeID == nodeID {
-			m.EraseOrphanTx(otx.Tx.GetHash(), true)
-			numEvicted++
-		}
-	}
-	m.Unlock()
-	return numEvicted
-}
@@ -285,8 +285,7 @@ func TestMempoolOrphan(t *testing.T) {
 		mp.EraseOrphanTx(hash, ok)
 	}
 
-	tmpOrphanTx := make(map[util.Hash]OrphanTx)
-	assert.Equal(t, mp.OrphanTransactions, tmpOrphanTx)
+	assert.Equal(t, 0, mp.OrphanCount())
 
 	mp.AddOrphanTx(txParent, 0x01)
 	numEvicted := mp.RemoveOrphansByTag(0x01)
@@ -536,26 +535,26 @@ func TestTxMempool_GetMinFee(t *testing.T) {
 	mp.rollingMinimumFeeRate = 10
 	mp.blockSinceLastRollingFeeBump = false
 	res := mp.GetMinFee(1000)
-	assert.Equal(t, res, *util.NewFeeRate(mp.rollingMinimumFeeRate))
+	assert.Equal(t, res, *util.NewFeeRatePerK(amount.Amount(mp.rollingMinimumFeeRate)))
 
 	mp.blockSinceLastRollingFeeBump = true
 	mp.lastRollingFeeUpdate = 1540260957
 	res = mp.GetMinFee(1000)
-	assert.Equal(t, res, *util.NewFeeRate(1))
+	assert.Equal(t, res, *util.NewFeeRatePerK(1))
 
 	mp.usageSize = 1000
 	mp.rollingMinimumFeeRate = 10
 	mp.lastRollingFeeUpdate = 10
 	res = mp.GetMinFee(1000)
-	assert.Equal(t, res, *util.NewFeeRate(1))
+	assert.Equal(t, res, *util.NewFeeRatePerK(1))
 
 	mp1 := NewTxMempool()
 	mp1.rollingMinimumFeeRate = 100
 	mp1.blockSinceLastRollingFeeBump = true
 	mp1.usageSize = 1000
-	mp1.incrementalRelayFee = *util.NewFeeRate(1000)
+	mp1.incrementalRelayFee = *util.NewFeeRatePerK(1000)
 	res1 := mp1.GetMinFee(1000)
-	assert.Equal(t, res1, *util.NewFeeRate(0))
+	assert.Equal(t, res1, *util.NewFeeRatePerK(0))
 
 	mp2 := NewTxMempool()
 	conf.Cfg = conf.InitConfig(nil)
@@ -331,6 +331,36 @@ func TestTxMempoolTrimToSize(t *testing.T) {
 	fmt.Printf("============= end ============\n")
 }
 
+func TestTxMempoolTrimToSizeEvictsLowestFeeRate(t *testing.T) {
+	testPool := NewTxMempool()
+	noLimit := uint64(math.MaxUint64)
+
+	// Transactions of the same size, the first one pays the lowest fee.
+	var txns []*tx.Tx
+	for i := 1; i <= 3; i++ {
+		txn := tx.NewTx(0, tx.TxVersion)
+		pkScript := script.NewScriptRaw([]byte{opcodes.OP_11, opcodes.OP_EQUAL})
+		txn.AddTxOut(txout.NewTxOut(amount.Amount(int64(i)*util.COIN), pkScript))
+		entry := NewTestMemPoolEntry().SetFee(amount.Amount(i * 1000)).FromTxToEntry(txn)
+		ancestors, _ := testPool.CalculateMemPoolAncestors(txn, noLimit, noLimit, noLimit, noLimit, true)
+		if err := testPool.AddTx(entry, ancestors); err != nil {
+			t.Fatal(err.Error())
+		}
+		txns = append(txns, txn)
+	}
+
+	testPool.trimToSize(testPool.usageSize - 1)
+	if testPool.Size() != 2 {
+		t.Errorf("the pool element number is error, expect number is : %d, actual number is : %d", 2, testPool.Size())
+	}
+	if testPool.IsTransactionInPool(txns[0]) {
+		t.Errorf("the transaction paying the lowest fee rate should be evicted")
+	}
+	if !testPool.IsTransactionInPool(txns[2]) {
+		t.Errorf("the transaction paying the highest fee rate should be kept")
+	}
+}
+
 func TestTxMempool_GetCheckFrequency(t *testing.T) {
 	conf.Cfg = conf.InitConfig([]string{})
 
@@ -424,3 +454,35 @@ func TestTxMempool_GetCoin(t *testing.T) {
 	assert.Equal(t, out.GetValue(), coin3.GetAmount())
 	assert.Equal(t, out.GetScriptPubKey(), coin3.GetScriptPubKey())
 }
+
+func TestTxMempool_ConflictedTx(t *testing.T) {
+	if conf.Cfg == nil {
+		conf.Cfg = conf.InitConfig([]string{})
+	}
+	set := createTx()
+	parent, child := set[0], set[2]
+
+	mp := NewTxMempool()
+	mp.AddTx(parent, make(map[*TxEntry]struct{}))
+	mp.AddTx(child, map[*TxEntry]struct{}{parent: {}})
+
+	// a block transaction spending the same coin as the child
+	blockTx := tx.NewTx(0, tx.TxVersion)
+	blockTx.AddTxIn(txin2.NewTxIn(&outpoint.OutPoint{Hash: parent.Tx.GetHash(), Index: 0},
+		script.NewScriptRaw([]byte{opcodes.OP_TRUE}), script.SequenceFinal))
+	blockTx.AddTxOut(txout.NewTxOut(amount.Amount(util.COIN), script.NewScriptRaw([]byte{opcodes.OP_TRUE})))
+	mp.removeConflicts(blockTx)
+
+	assert.Equal(t, mp.FindTx(child.Tx.GetHash()) == nil, true)
+	assert.Equal(t, mp.FindConflictedTx(child.Tx.GetHash()), child.Tx)
+	assert.Equal(t, mp.FindTx(parent.Tx.GetHash()), parent)
+	assert.Equal(t, mp.FindConflictedTx(parent.Tx.GetHash()) == nil, true)
+
+	// the oldest record is dropped once the bound is reached
+	for i := 0; i < MaxConflictedTransaction; i++ {
+		conflictedTx := tx.NewTx(uint32(i), tx.TxVersion)
+		mp.addConflictedTx(conflictedTx)
+	}
+	assert.Equal(t, len(mp.conflicted), MaxConflictedTransaction)
+	assert.Equal(t, mp.FindConflictedTx(child.Tx.GetHash()) == nil, true)
+}
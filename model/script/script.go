@@ -35,6 +35,9 @@ const (
 	MaxScriptElementSize = 520
 	MaxScriptOpCodes     = 201
 	MaxOpsPerScript      = 201
+	// MaxStackSize bounds the number of items on the stack and the alt stack
+	// together.
+	MaxStackSize = 1000
 
 	// MaxTxInStandardScriptSigSize is
 	// Biggest 'standard' txin is a 15-of-15 P2SH multisig with compressed
@@ -204,6 +207,10 @@ const (
 	//enabled. Can be removed after OP_CHECKDATASIG is activated as the flag is
 	//made standard.
 	StandardCheckDataSigVerifyFlags = StandardScriptVerifyFlags | ScriptEnableCheckDataSig
+
+	//ScriptUpgradeFlags the flags which turn on new script features at an upgrade rather than
+	//restricting existing ones. Mempool acceptance takes them from the next block's flags.
+	ScriptUpgradeFlags uint = ScriptEnableSigHashForkID | ScriptEnableReplayProtection | ScriptEnableCheckDataSig
 )
 
 type Script struct {
@@ -647,6 +654,8 @@ func (s *Script) IsUnspendable() bool {
 	return (s.Size() > 0 && s.data[0] == opcodes.OP_RETURN) || s.Size() > MaxScriptSize
 }
 
+// IsPushOnly returns whether the script only pushes data, opcodes up to OP_16
+// included, as P2SH and standard scriptSigs must.
 func (s *Script) IsPushOnly() bool {
 	if s.badOpCode {
 		return false
@@ -657,7 +666,6 @@ func (s *Script) IsPushOnly() bool {
 		}
 	}
 	return true
-
 }
 
 func (s *Script) GetSigOpCount(flags uint32, accurate bool) int {
@@ -696,17 +704,11 @@ func (s *Script) GetPubKeyP2SHSigOpCount(flags uint32, scriptSig *Script) int {
 		return s.GetSigOpCount(flags, true)
 	}
 
-	if scriptSig.badOpCode {
-		return 0
-	}
-
 	// This is a pay-to-script-hash scriptPubKey;
 	// get the last item that the scriptSig
 	// pushes onto the stack:
-	for _, e := range scriptSig.ParsedOpCodes {
-		if e.OpValue > opcodes.OP_16 {
-			return 0
-		}
+	if !scriptSig.IsPushOnly() || len(scriptSig.ParsedOpCodes) == 0 {
+		return 0
 	}
 
 	lastOps := scriptSig.ParsedOpCodes[len(scriptSig.ParsedOpCodes)-1]
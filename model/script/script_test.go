@@ -1054,3 +1054,38 @@ func TestCheckPubKeyEncoding(t *testing.T) {
 		"Without compress's pubKey with ScriptVerifyCompressedPubkeyType check encoding error.")
 
 }
+
+func TestScript_IsPushOnly(t *testing.T) {
+	sig := NewEmptyScript()
+	sig.PushOpCode(OP_0)
+	sig.PushSingleData(bytes.Repeat([]byte{0x01}, 72))
+	sig.PushSingleData(bytes.Repeat([]byte{0x02}, 33))
+	assert.True(t, sig.IsPushOnly(), "data pushes are push only")
+
+	sig.PushOpCode(OP_DUP)
+	assert.False(t, sig.IsPushOnly(), "OP_DUP is not push only")
+
+	for op := OP_1NEGATE; op <= OP_16; op++ {
+		assert.True(t, NewScriptRaw([]byte{byte(op)}).IsPushOnly(), "opcode %d is push only", op)
+	}
+	assert.False(t, NewScriptRaw([]byte{OP_16 + 1}).IsPushOnly(), "OP_NOP is not push only")
+
+	// A push running past the end of the script is not push only.
+	assert.False(t, NewScriptRaw([]byte{OP_PUSHDATA1, 0x02, 0x01}).IsPushOnly())
+}
+
+func TestScript_GetPubKeyP2SHSigOpCount(t *testing.T) {
+	redeem := NewEmptyScript()
+	redeem.PushOpCode(OP_CHECKSIG)
+	redeem.PushOpCode(OP_CHECKSIG)
+	p2sh := NewScriptRaw(p2SHScript[:])
+
+	sig := NewEmptyScript()
+	sig.PushOpCode(OP_1)
+	sig.PushSingleData(redeem.GetData())
+	assert.Equal(t, 2, p2sh.GetPubKeyP2SHSigOpCount(ScriptVerifyP2SH, sig))
+
+	sig.PushOpCode(OP_DUP)
+	assert.Equal(t, 0, p2sh.GetPubKeyP2SHSigOpCount(ScriptVerifyP2SH, sig), "scriptSig not push only")
+	assert.Equal(t, 0, p2sh.GetPubKeyP2SHSigOpCount(ScriptVerifyP2SH, NewEmptyScript()), "empty scriptSig")
+}
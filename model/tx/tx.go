@@ -1,7 +1,6 @@
 package tx
 
 import (
-	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
@@ -169,6 +168,12 @@ func (tx *Tx) SerializeSize() uint32 {
 	return tx.EncodeSize()
 }
 
+// GetVirtualSize returns the size fee rates are computed on, which on Bitcoin
+// Cash is the serialized size.
+func (tx *Tx) GetVirtualSize() uint32 {
+	return tx.SerializeSize()
+}
+
 func (tx *Tx) Serialize(writer io.Writer) error {
 	return tx.Encode(writer)
 }
@@ -177,6 +182,26 @@ func (tx *Tx) Unserialize(reader io.Reader) error {
 	return tx.Decode(reader)
 }
 
+// UnserializeUnfunded reads a transaction which may not have inputs yet, like
+// the ones made by createrawtransaction for fundrawtransaction. Such a
+// transaction with a single output starts like a segwit transaction, so it is
+// not refused as one.
+func (tx *Tx) UnserializeUnfunded(reader io.Reader) error {
+	return tx.decode(reader, true)
+}
+
+// UnserializeHashed reads the transaction like Unserialize, and computes its
+// hash from the bytes as they are read instead of serializing it again later.
+func (tx *Tx) UnserializeHashed(reader io.Reader) error {
+	w := util.BorrowHashWriter()
+	defer util.ReturnHashWriter(w)
+	if err := tx.Decode(io.TeeReader(reader, w)); err != nil {
+		return err
+	}
+	tx.hash = w.Hash()
+	return nil
+}
+
 func (tx *Tx) EncodeSize() uint32 {
 	// Version 4 bytes + LockTime 4 bytes + Serialized varint size for the
 	// number of transaction inputs and outputs.
@@ -223,7 +248,14 @@ func (tx *Tx) Encode(writer io.Writer) error {
 	return util.BinarySerializer.PutUint32(writer, binary.LittleEndian, tx.lockTime)
 }
 
+// Decode reads a transaction in the Bitcoin Cash serialization format. The
+// segwit serialization is refused with an unsupported-format error, instead of
+// reading its marker and flag as empty inputs and a single output.
 func (tx *Tx) Decode(reader io.Reader) error {
+	return tx.decode(reader, false)
+}
+
+func (tx *Tx) decode(reader io.Reader, unfunded bool) error {
 	version, err := util.BinarySerializer.Uint32(reader, binary.LittleEndian)
 	if err != nil {
 		return err
@@ -253,6 +285,11 @@ func (tx *Tx) Decode(reader io.Reader) error {
 	if err != nil {
 		return err
 	}
+	// A segwit transaction has the 0x00 marker and 0x01 flag after its
+	// version, there is no segwit on Bitcoin Cash.
+	if len(tx.ins) == 0 && count == 1 && !unfunded {
+		return errcode.NewError(errcode.RejectMalformed, "unsupported-format")
+	}
 
 	tx.outs = make([]*txout.TxOut, count)
 	for i := uint64(0); i < count; i++ {
@@ -297,6 +334,9 @@ func (tx *Tx) GetVersion() int32 {
 	return tx.version
 }
 
+// CheckRegularTransaction runs the context-free checks of a transaction that
+// is not a coinbase: it must have inputs and outputs, output values within
+// the money range, and no null or duplicate prevouts.
 func (tx *Tx) CheckRegularTransaction() error {
 	if tx.IsCoinBase() {
 		log.Debug("tx should not be coinbase, hash: %s", tx.hash)
@@ -308,25 +348,12 @@ func (tx *Tx) CheckRegularTransaction() error {
 		return err
 	}
 
-	outPoints := make(map[outpoint.OutPoint]bool)
-	for _, in := range tx.ins {
-		if in.PreviousOutPoint.IsNull() {
-			log.Debug("tx input prevout null")
-			return errcode.NewError(errcode.RejectInvalid, "bad-txns-prevout-null")
-		}
-
-		if _, exists := outPoints[*(in.PreviousOutPoint)]; !exists {
-			outPoints[*(in.PreviousOutPoint)] = true
-		} else {
-			log.Error("bad tx: %s, duplicate inputs:[%s:%d]",
-				tx.hash, in.PreviousOutPoint.Hash, in.PreviousOutPoint.Index)
-			return errcode.NewError(errcode.RejectInvalid, "bad-txns-inputs-duplicate")
-		}
-	}
-
-	return nil
+	return tx.checkInputs(make(map[outpoint.OutPoint]bool))
 }
 
+// CheckRegularTransactionWhenNewBlock is CheckRegularTransaction for the
+// transactions of a block, with outPoints collecting the prevouts spent by the
+// transactions of the block checked so far.
 func (tx *Tx) CheckRegularTransactionWhenNewBlock(outPoints map[outpoint.OutPoint]bool) error {
 	if tx.IsCoinBase() {
 		log.Debug("tx should not be coinbase, hash: %s", tx.hash)
@@ -338,6 +365,12 @@ func (tx *Tx) CheckRegularTransactionWhenNewBlock(outPoints map[outpoint.OutPoin
 		return err
 	}
 
+	return tx.checkInputs(outPoints)
+}
+
+// checkInputs rejects null prevouts and the inputs spending an outpoint
+// already in outPoints, adding the prevouts of the inputs to outPoints.
+func (tx *Tx) checkInputs(outPoints map[outpoint.OutPoint]bool) error {
 	for _, in := range tx.ins {
 		if in.PreviousOutPoint.IsNull() {
 			log.Debug("tx input prevout null")
@@ -447,7 +480,7 @@ func (tx *Tx) IsStandard() (bool, string) {
 			nDataOut++
 		} else if pubKeyType == script.ScriptMultiSig && !conf.Cfg.Script.IsBareMultiSigStd {
 			return false, "bare-multisig"
-		} else if out.IsDust(util.NewFeeRate(conf.Cfg.TxOut.DustRelayFee)) {
+		} else if out.IsDust(util.NewFeeRatePerK(amount.Amount(conf.Cfg.TxOut.DustRelayFee))) {
 			return false, "dust"
 		}
 	}
@@ -644,12 +677,13 @@ func (tx *Tx) GetHash() util.Hash {
 }
 
 func (tx *Tx) calHash() util.Hash {
-	buf := bytes.NewBuffer(make([]byte, 0, tx.EncodeSize()))
-	err := tx.Encode(buf)
+	w := util.BorrowHashWriter()
+	defer util.ReturnHashWriter(w)
+	err := tx.Encode(w)
 	if err != nil {
 		panic("tx encode failed: " + err.Error())
 	}
-	return util.DoubleSha256Hash(buf.Bytes())
+	return w.Hash()
 }
 
 func (tx *Tx) GetIns() []*txin.TxIn {
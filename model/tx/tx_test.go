@@ -207,6 +207,34 @@ func Test_should_able_to_reject_empty_vin_txn(t *testing.T) {
 	assertError(err, errcode.RejectInvalid, "bad-txns-vin-empty", t)
 }
 
+// segwitTxHex is a transaction with one input and one output in the segwit
+// serialization, with the 0x00 marker and 0x01 flag after the version.
+const segwitTxHex = "0200000000010111111111111111111111111111111111111111111111111111111111111111110000000000ffffffff01e8030000000000001600142222222222222222222222222222222222222222012102333333333333333333333333333333333333333333333333333333333333333300000000"
+
+func Test_should_able_to_refuse_segwit_serialized_txn(t *testing.T) {
+	raw, err := hex.DecodeString(segwitTxHex)
+	assert.Nil(t, err)
+	txn := NewEmptyTx()
+
+	err = txn.Unserialize(bytes.NewReader(raw))
+
+	assertError(err, errcode.RejectMalformed, "unsupported-format", t)
+}
+
+func Test_should_able_to_unserialize_unfunded_txn(t *testing.T) {
+	txn := NewTx(0, DefaultVersion)
+	txn.AddTxOut(txout.NewTxOut(1000, script.NewEmptyScript()))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, txn.Serialize(buf))
+
+	// without inputs it starts with the bytes of the segwit marker and flag
+	assert.NotNil(t, NewEmptyTx().Unserialize(bytes.NewReader(buf.Bytes())))
+
+	unfunded := NewEmptyTx()
+	assert.Nil(t, unfunded.UnserializeUnfunded(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, txn.GetHash(), unfunded.GetHash())
+}
+
 func Test_should_able_to_reject_empty_out_txn(t *testing.T) {
 	txn := mainNetTx(t)
 	txn.outs = []*txout.TxOut{}
@@ -309,6 +337,50 @@ func Test_should_able_to_reject_tx_with_null_prevout__during_regular_tx_check(t
 	assertError(err, errcode.RejectInvalid, "bad-txns-prevout-null", t)
 }
 
+func Test_should_run_the_same_checks__during_new_block_tx_check(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(txn *Tx)
+		reason string
+	}{
+		{"valid", func(txn *Tx) {}, ""},
+		{"duplicate inputs", func(txn *Tx) { txn.ins = append(txn.ins, txn.ins[0]) }, "bad-txns-inputs-duplicate"},
+		{"empty inputs", func(txn *Tx) { txn.ins = nil }, "bad-txns-vin-empty"},
+		{"empty outputs", func(txn *Tx) { txn.outs = nil }, "bad-txns-vout-empty"},
+		{"negative output", func(txn *Tx) { txn.outs[1].SetValue(-1) }, "bad-txns-vout-negative"},
+		{"too large output", func(txn *Tx) { txn.outs[1].SetValue(amount.Amount(util.MaxMoney + 1)) }, "bad-txns-vout-toolarge"},
+		{"too large total", func(txn *Tx) {
+			txn.outs[0].SetValue(amount.Amount(util.MaxMoney))
+			txn.outs[1].SetValue(1)
+		}, "bad-txns-txouttotal-toolarge"},
+	}
+
+	for _, test := range tests {
+		txn := mainNetTx(t)
+		test.modify(txn)
+
+		errs := []error{
+			txn.CheckRegularTransaction(),
+			txn.CheckRegularTransactionWhenNewBlock(make(map[outpoint.OutPoint]bool)),
+		}
+		for _, err := range errs {
+			if test.reason == "" {
+				assert.NoError(t, err, test.name)
+			} else {
+				assertError(err, errcode.RejectInvalid, test.reason, t)
+			}
+		}
+	}
+}
+
+func Test_should_able_to_reject_inputs_spent_by_an_earlier_tx__during_new_block_tx_check(t *testing.T) {
+	outPoints := make(map[outpoint.OutPoint]bool)
+	assert.NoError(t, mainNetTx(t).CheckRegularTransactionWhenNewBlock(outPoints))
+
+	err := mainNetTx(t).CheckRegularTransactionWhenNewBlock(outPoints)
+	assertError(err, errcode.RejectInvalid, "bad-txns-inputs-duplicate", t)
+}
+
 func Test_genesis_coinbase_tx_should_be_valid_coinbase_tx(t *testing.T) {
 	err := NewGenesisCoinbaseTx().CheckCoinbaseTransaction()
 	assert.NoError(t, err)
@@ -485,7 +557,7 @@ func Test_tx_with_any_dust_outs___should_be_non_standard(t *testing.T) {
 	givenAcceptDataCarrier()
 
 	txn := mainNetTx(t)
-	minNonDustValue := amount.Amount(txn.outs[1].GetDustThreshold(util.NewFeeRate(conf.Cfg.TxOut.DustRelayFee)))
+	minNonDustValue := txn.outs[1].GetDustThreshold(util.NewFeeRatePerK(amount.Amount(conf.Cfg.TxOut.DustRelayFee)))
 	txn.outs[1].SetValue(minNonDustValue - 1)
 
 	isStandard, reason := txn.IsStandard()
@@ -780,6 +852,22 @@ func Test_basic_tx_methods(t *testing.T) {
 	assert.Equal(t, txn.EncodeSize(), txn.SerializeSize())
 }
 
+func Test_virtual_size_is_the_serialized_size(t *testing.T) {
+	testnetTx := NewEmptyTx()
+	rawTx, _ := hex.DecodeString("0200000001bebf7bab9021fd3422231e13b39744ee788584bc42b63d15d163d26860d2ea5b010000006b4830450221009439545e50e255cc03d9685c9182415fa1c31bdae1c74fb41cf55ef371e9c5ac022070367d1685deec304bc92863d291788f4355ab3e1ab755afb1189cee4403a891412102413ce16bc4975dcc6945febd4b4786e0d0006c1ac4ff49cdbf71cc2cb5734b70feffffff030000000000000000456a4362636e73000001f4676f626162793200626368746573743a71716b35396a736870386c7934793667346c35686e6565766871663334347a647271756879306e657178008d340f00000000001976a9142d42ca1709fe4a9348afe979e72cb8131ad44d1888ac22020000000000001976a9142d42ca1709fe4a9348afe979e72cb8131ad44d1888acb83d1300")
+	assert.NoError(t, testnetTx.Decode(bytes.NewReader(rawTx)))
+
+	emptyTx := NewTx(0, DefaultVersion)
+	emptyTx.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(util.HashZero, 0), script.NewEmptyScript(), script.SequenceFinal))
+	emptyTx.AddTxOut(txout.NewTxOut(0, script.NewEmptyScript()))
+
+	for _, txn := range []*Tx{mainNetTx(t), testnetTx, emptyTx} {
+		buf := bytes.NewBuffer(nil)
+		assert.NoError(t, txn.Serialize(buf))
+		assert.Equal(t, uint32(buf.Len()), txn.GetVirtualSize())
+	}
+}
+
 // The struct Var contains some variable which testing using.
 // keyMap is used to save the relation publicKeyHash and privateKey, k is publicKeyHash, v is privateKey.
 type Var struct {
@@ -983,3 +1071,54 @@ func TestInsertTxOut(t *testing.T) {
 	assert.Equal(t, 4, txn.GetOutsCount())
 	assert.Equal(t, scriptPubKey3, txn.GetTxOut(3).GetScriptPubKey())
 }
+
+func TestUnserializeHashed(t *testing.T) {
+	for i, e := range tests {
+		b, err := hex.DecodeString(e.txRaw)
+		if err != nil {
+			t.Fatalf("decode txRaw hex string :%v", err)
+		}
+		txn := NewEmptyTx()
+		if err := txn.UnserializeHashed(bytes.NewReader(b)); err != nil {
+			t.Fatalf("test %d, UnserializeHashed failed: %v", i, err)
+		}
+		assert.Equal(t, e.GetHash, txn.GetHash().String(), "test %d", i)
+		assert.Equal(t, txn.calHash(), txn.GetHash(), "test %d", i)
+	}
+}
+
+func BenchmarkTxGetHash(b *testing.B) {
+	raw, err := hex.DecodeString(tests[1].txRaw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	txn := NewEmptyTx()
+	if err := txn.Unserialize(bytes.NewReader(raw)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txn.calHash()
+	}
+}
+
+// BenchmarkTxHashBuffered hashes the transaction by serializing it to a
+// buffer first, for comparison with the pooled util.HashWriter of calHash.
+func BenchmarkTxHashBuffered(b *testing.B) {
+	raw, err := hex.DecodeString(tests[1].txRaw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	txn := NewEmptyTx()
+	if err := txn.Unserialize(bytes.NewReader(raw)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bytes.NewBuffer(make([]byte, 0, txn.EncodeSize()))
+		txn.Encode(buf)
+		util.DoubleSha256Hash(buf.Bytes())
+	}
+}
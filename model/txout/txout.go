@@ -56,10 +56,10 @@ func (txOut *TxOut) Decode(reader io.Reader) error {
 }
 
 func (txOut *TxOut) IsDust(minRelayTxFee *util.FeeRate) bool {
-	return txOut.value < amount.Amount(txOut.GetDustThreshold(minRelayTxFee))
+	return txOut.value < txOut.GetDustThreshold(minRelayTxFee)
 }
 
-func (txOut *TxOut) GetDustThreshold(minRelayTxFee *util.FeeRate) int64 {
+func (txOut *TxOut) GetDustThreshold(minRelayTxFee *util.FeeRate) amount.Amount {
 	// "Dust" is defined in terms of CTransaction::minRelayTxFee, which has
 	// units satoshis-per-kilobyte. If you'd pay more than 1/3 in fees to
 	// spend something, then we consider it dust. A typical spendable
@@ -86,7 +86,7 @@ var myscript = []byte{0x14, 0x69, 0xe1, 0x2a, 0x40, 0xd4, 0xa2, 0x21, 0x8d, 0x33
 var (
 	script1       = script.NewScriptRaw(myscript)
 	testTxout     = NewTxOut(9, script1)
-	minRelayTxFee = util.NewFeeRate(9766)
+	minRelayTxFee = util.NewFeeRatePerK(9766)
 )
 
 func TestNewTxOut(t *testing.T) {
@@ -225,7 +225,41 @@ func TestTxOut_GetDustThreshold(t *testing.T) {
 	script := script.NewScriptRaw([]byte{opcodes.OP_RETURN, 0x01, 0x01})
 	txout := NewTxOut(9, script)
 
-	assert.Equal(t, int64(0), txout.GetDustThreshold(&util.FeeRate{SataoshisPerK: 1}))
+	assert.Equal(t, amount.Amount(0), txout.GetDustThreshold(&util.FeeRate{SataoshisPerK: 1}))
+}
+
+func TestGetDustThresholdP2PKH(t *testing.T) {
+	pkScript := script.NewEmptyScript()
+	pkScript.PushOpCode(opcodes.OP_DUP)
+	pkScript.PushOpCode(opcodes.OP_HASH160)
+	pkScript.PushSingleData(make([]byte, 20))
+	pkScript.PushOpCode(opcodes.OP_EQUALVERIFY)
+	pkScript.PushOpCode(opcodes.OP_CHECKSIG)
+	out := NewTxOut(0, pkScript)
+	// 34 bytes of output and 148 bytes of input spending it
+	assert.Equal(t, uint32(34), out.SerializeSize())
+
+	tests := []struct {
+		perK      amount.Amount
+		threshold amount.Amount
+	}{
+		{1000, 546},
+		// 182 bytes at 83 per kB are 15.106 satoshis, rounded up to 16
+		{83, 48},
+		{1, 3},
+		{0, 0},
+	}
+	for _, test := range tests {
+		feeRate := util.NewFeeRatePerK(test.perK)
+		assert.Equal(t, test.threshold, out.GetDustThreshold(feeRate), "fee rate %d", test.perK)
+
+		out.SetValue(test.threshold)
+		assert.False(t, out.IsDust(feeRate), "fee rate %d", test.perK)
+		if test.threshold > 0 {
+			out.SetValue(test.threshold - 1)
+			assert.True(t, out.IsDust(feeRate), "fee rate %d", test.perK)
+		}
+	}
 }
 
 func TestTxOut_CheckValue(t *testing.T) {
@@ -1,7 +1,6 @@
 package utxo
 
 import (
-	"encoding/binary"
 	"errors"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/model/script"
@@ -9,6 +8,7 @@ import (
 	"github.com/copernet/copernicus/util"
 	"github.com/copernet/copernicus/util/amount"
 	"io"
+	"unsafe"
 )
 
 type Coin struct {
@@ -70,8 +70,14 @@ func (coin *Coin) DeepCopy() *Coin {
 	return &newCoin
 }
 
+// DynamicMemoryUsage returns the memory used by the coin, its script
+// included.
 func (coin *Coin) DynamicMemoryUsage() int64 {
-	return int64(binary.Size(coin))
+	usage := int64(unsafe.Sizeof(*coin))
+	if s := coin.txOut.GetScriptPubKey(); s != nil {
+		usage += int64(s.Size())
+	}
+	return usage
 }
 
 func (coin *Coin) Serialize(w io.Writer) error {
@@ -40,8 +40,8 @@ func TestCoin(t *testing.T) {
 		t.Error("get script pubkey is not equal script1, please check...")
 	}
 
-	if c.DynamicMemoryUsage() > 0 {
-		t.Error("DynamicMemoryUsage not need test...")
+	if c.DynamicMemoryUsage() <= int64(script1.Size()) {
+		t.Error("DynamicMemoryUsage should count the coin and its script")
 	}
 
 	if !reflect.DeepEqual(c.DeepCopy(), c) {
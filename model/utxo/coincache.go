@@ -25,6 +25,12 @@ func Close() {
 	utxoTip = nil
 }
 
+// CoinsView looks up the coins of outpoints. It is implemented by the UTXO
+// cache and by CoinsMap.
+type CoinsView interface {
+	GetCoin(outpoint *outpoint.OutPoint) *Coin
+}
+
 type CacheView interface {
 	GetCoin(outpoint *outpoint.OutPoint) *Coin
 	HaveCoin(point *outpoint.OutPoint) bool
@@ -113,6 +113,9 @@ func (coinsViewDB *CoinsDB) EstimateSize() uint64 {
 	return coinsViewDB.dbw.EstimateSize([]byte{db.DbCoin}, []byte{db.DbCoin + 1})
 }
 
+// coinsSchema is the schema of the coins database.
+var coinsSchema = &db.Schema{Name: "chainstate", Version: 1}
+
 func newCoinsDB(do *db.DBOption) *CoinsDB {
 	if do == nil {
 		return nil
@@ -123,6 +126,9 @@ func newCoinsDB(do *db.DBOption) *CoinsDB {
 	if err != nil {
 		panic("init CoinsDB failed..." + err.Error())
 	}
+	if err := dbw.Migrate(coinsSchema); err != nil {
+		panic("init CoinsDB failed..." + err.Error())
+	}
 
 	return &CoinsDB{
 		dbw: dbw,
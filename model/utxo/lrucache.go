@@ -1,8 +1,6 @@
 package utxo
 
 import (
-	"unsafe"
-
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/util"
@@ -15,6 +13,9 @@ type CoinsLruCache struct {
 	hashBlock  util.Hash
 	cacheCoins *lru.Cache
 	dirtyCoins map[outpoint.OutPoint]*Coin //write database temporary cache
+
+	// usage is the memory used by the coins of cacheCoins.
+	usage int64
 }
 
 func (coinsCache *CoinsLruCache) GetCoinsDB() CoinsDB {
@@ -29,7 +30,9 @@ func InitUtxoLruTip(uc *UtxoConfig) {
 func newCoinsLruCache(db CoinsDB) CacheView {
 	c := new(CoinsLruCache)
 	c.db = db
-	cache, err := lru.New(1000000)
+	cache, err := lru.NewWithEvict(1000000, func(key interface{}, value interface{}) {
+		c.usage -= value.(*Coin).DynamicMemoryUsage()
+	})
 	if err != nil {
 		log.Error("Error: NewCoinsLruCache err %#v", err)
 		panic("Error: NewCoinsLruCache err")
@@ -56,7 +59,7 @@ func (coinsCache *CoinsLruCache) GetCoin(outpoint *outpoint.OutPoint) *Coin {
 	if coin == nil {
 		return nil
 	}
-	coinsCache.cacheCoins.Add(*outpoint, coin)
+	coinsCache.addCoin(*outpoint, coin)
 	if coin.IsSpent() {
 		// The parent only has an empty entry for this outpoint; we can consider
 		// our version as fresh.
@@ -66,6 +69,15 @@ func (coinsCache *CoinsLruCache) GetCoin(outpoint *outpoint.OutPoint) *Coin {
 	return coin
 }
 
+// addCoin caches coin at point, in place of the coin cached there.
+func (coinsCache *CoinsLruCache) addCoin(point outpoint.OutPoint, coin *Coin) {
+	if old, ok := coinsCache.cacheCoins.Peek(point); ok {
+		coinsCache.usage -= old.(*Coin).DynamicMemoryUsage()
+	}
+	coinsCache.usage += coin.DynamicMemoryUsage()
+	coinsCache.cacheCoins.Add(point, coin)
+}
+
 func (coinsCache *CoinsLruCache) HaveCoin(point *outpoint.OutPoint) bool {
 	coin := coinsCache.GetCoin(point)
 	return coin != nil && !coin.IsSpent()
@@ -111,7 +123,7 @@ func (coinsCache *CoinsLruCache) UpdateCoins(cm *CoinsMap, hash *util.Hash) erro
 			if !ok {
 				if tempCacheCoin.fresh {
 					tempCacheCoin.dirty = true
-					coinsCache.cacheCoins.Add(point, tempCacheCoin)
+					coinsCache.addCoin(point, tempCacheCoin)
 					//ret := coinsCache.cacheCoins.Add(point, tempCacheCoin)
 					//if !ret {
 					//	log.Error("lruCache:add coin failed, please check")
@@ -137,7 +149,7 @@ func (coinsCache *CoinsLruCache) UpdateCoins(cm *CoinsMap, hash *util.Hash) erro
 					}
 				} else {
 					tempCacheCoin.dirty = true
-					coinsCache.cacheCoins.Add(point, tempCacheCoin)
+					coinsCache.addCoin(point, tempCacheCoin)
 					coinsCache.dirtyCoins[point] = tempCacheCoin
 				}
 			}
@@ -179,6 +191,7 @@ func (coinsCache *CoinsLruCache) GetCacheSize() int {
 	return coinsCache.cacheCoins.Len()
 }
 
+// DynamicMemoryUsage returns the memory used by the cached coins.
 func (coinsCache *CoinsLruCache) DynamicMemoryUsage() int64 {
-	return int64(unsafe.Sizeof(coinsCache.cacheCoins))
+	return coinsCache.usage
 }
@@ -266,3 +266,36 @@ func TestAccessByTxid(t *testing.T) {
 	rCoin = GetUtxoCacheInstance().AccessByTxID(hashUnKnown)
 	assert.Nil(t, rCoin)
 }
+
+func TestDynamicMemoryUsage(t *testing.T) {
+	conf.Cfg = conf.InitConfig([]string{})
+	testDataDir, err := conf.SetUnitTestDataDir(conf.Cfg)
+	if err != nil {
+		t.Fatalf("init test directory failed: %v", err)
+	}
+	defer os.RemoveAll(testDataDir)
+	InitUtxoLruTip(&UtxoConfig{Do: &db.DBOption{
+		FilePath:  conf.DataDir,
+		CacheSize: 1 << 20,
+	}})
+	cache := GetUtxoCacheInstance()
+	assert.Equal(t, int64(0), cache.DynamicMemoryUsage())
+
+	hash := util.HashFromString("000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6")
+	cm := NewEmptyCoinsMap()
+	var usage int64
+	for i := 0; i < 3; i++ {
+		coin := NewFreshCoin(txout.NewTxOut(3, script.NewScriptRaw(make([]byte, 10*(i+1)))), 1, false)
+		cm.AddCoin(&outpoint.OutPoint{Hash: *hash, Index: uint32(i)}, coin, false)
+		usage += coin.DynamicMemoryUsage()
+	}
+	assert.Nil(t, cache.UpdateCoins(cm, hash))
+	assert.Equal(t, usage, cache.DynamicMemoryUsage())
+
+	removed := cache.GetCoin(&outpoint.OutPoint{Hash: *hash, Index: 1})
+	cache.RemoveCoins(&outpoint.OutPoint{Hash: *hash, Index: 1})
+	assert.Equal(t, usage-removed.DynamicMemoryUsage(), cache.DynamicMemoryUsage())
+
+	cache.Flush()
+	assert.Equal(t, int64(0), cache.DynamicMemoryUsage())
+}
@@ -45,7 +45,7 @@ var globalWallet *Wallet
  * fee instead. Has no effect if not using fee estimation.
  * Override with -fallbackfee
  */
-var fallbackFee = util.NewFeeRate(20000)
+var fallbackFee = util.NewFeeRatePerK(20000)
 
 func InitWallet() {
 	defer func() {
@@ -64,7 +64,7 @@ func InitWallet() {
 		txnLock:     new(sync.RWMutex),
 		walletTxns:  make(map[util.Hash]*WalletTx),
 		lockedCoins: make(map[outpoint.OutPoint]struct{}),
-		payTxFee:    util.NewFeeRate(0),
+		payTxFee:    util.NewFeeRatePerK(0),
 	}
 
 	if err := walletInstance.Init(); err != nil {
@@ -319,11 +319,11 @@ func (w *Wallet) SetBroadcastTx(broadcastTx bool) {
 	w.broadcastTx = broadcastTx
 }
 
-func (w *Wallet) SetFeeRate(feePaid int64, byteSize int64) {
-	w.payTxFee = util.NewFeeRateWithSize(feePaid, byteSize)
+func (w *Wallet) SetFeeRate(feePaid amount.Amount, byteSize int) {
+	w.payTxFee = util.NewFeeRate(feePaid, byteSize)
 }
 
-func (w *Wallet) GetMinimumFee(byteSize int) int64 {
+func (w *Wallet) GetMinimumFee(byteSize int) amount.Amount {
 	feeNeeded := w.payTxFee.GetFee(byteSize)
 	// User didn't set tx fee
 	if feeNeeded == 0 {
@@ -338,11 +338,19 @@ func (w *Wallet) GetMinimumFee(byteSize int) int64 {
 	}
 
 	// Prevent user from paying a fee below minRelayTxFee or minTxFee.
-	cfgMinFeeRate := util.NewFeeRate(conf.Cfg.Mempool.MinFeeRate)
-	feeNeeded = util.MaxI(feeNeeded, cfgMinFeeRate.GetFee(byteSize))
+	cfgMinFeeRate := util.NewFeeRatePerK(amount.Amount(conf.Cfg.Mempool.MinFeeRate))
+	if cfgMinFee := cfgMinFeeRate.GetFee(byteSize); feeNeeded < cfgMinFee {
+		feeNeeded = cfgMinFee
+	}
+	minRelayTxFee := mempool.GetInstance().MinRelayTxFee()
+	if relayFee := minRelayTxFee.GetFee(byteSize); feeNeeded < relayFee {
+		feeNeeded = relayFee
+	}
 
 	// But always obey the maximum.
-	feeNeeded = util.MinI(feeNeeded, util.MaxFee)
+	if feeNeeded > amount.Amount(util.MaxFee) {
+		feeNeeded = amount.Amount(util.MaxFee)
+	}
 
 	return feeNeeded
 }
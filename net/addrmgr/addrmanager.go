@@ -716,6 +716,11 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 		}
 		prefix := []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
 		ip = net.IP(append(prefix, data...))
+	} else if strings.HasSuffix(host, ".onion") {
+		// Other onion addresses, like the 56 char v3 ones, do not fit in
+		// an IPv6 address. They must never be resolved, which would leak
+		// them to the DNS servers.
+		return nil, fmt.Errorf("unsupported onion address %s", host)
 	} else if ip = net.ParseIP(host); ip == nil {
 		ips, err := a.lookupFunc(host)
 		if err != nil {
@@ -1009,6 +1014,14 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	if IsCJDNS(remoteAddr) {
+		if IsCJDNS(localAddr) {
+			return Private
+		}
+
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1039,7 +1052,7 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		tunnelled = true
 	}
 
-	if !IsRoutable(localAddr) {
+	if !IsRoutable(localAddr) || IsCJDNS(localAddr) {
 		return Default
 	}
 
@@ -1086,6 +1099,11 @@ func (a *AddrManager) NewAddress(filterOut func(gKey string) bool) (string, erro
 				continue
 			}
 
+			// Never dial addresses in networks limited by onlynet.
+			if !IsReachable(addr.NetAddress()) {
+				continue
+			}
+
 			// only allow recent nodes (10mins) after we failed 30
 			// times
 			if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/net/addrmgr"
 	"github.com/copernet/copernicus/net/wire"
 )
@@ -571,6 +572,18 @@ func TestHostToNetAddress(t *testing.T) {
 	}
 }
 
+func TestHostToNetAddressOnionV3(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		t.Errorf("unexpected lookup of %s", host)
+		return nil, errors.New("no lookup")
+	}
+	amgr := addrmgr.New("testhosttonetaddress", lookup)
+	host := "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion"
+	if _, err := amgr.HostToNetAddress(host, 8333, 0); err == nil {
+		t.Errorf("expect error for v3 onion address %s", host)
+	}
+}
+
 func TestNewAddress(t *testing.T) {
 	path, err := loadAddr()
 	if err != nil {
@@ -596,3 +609,50 @@ func TestNewAddress(t *testing.T) {
 		}
 	}
 }
+
+func TestNewAddressOnlyNet(t *testing.T) {
+	conf.Cfg = conf.InitConfig(nil)
+	defer func() {
+		conf.Cfg.P2PNet.OnlyNets = nil
+	}()
+
+	n := addrmgr.New("testnewaddressonlynet", lookupFunc)
+	port := model.ActiveNetParams.DefaultPort
+	ipv4Addrs := []string{"173.194.115.66", "12.1.2.3", "45.6.7.8"}
+	ipv6Addrs := []string{"2001:4860:4860::8888", "2a00:1450:4001::1"}
+	for _, ip := range append(ipv4Addrs, ipv6Addrs...) {
+		if err := n.AddAddressByIP(net.JoinHostPort(ip, port)); err != nil {
+			t.Fatalf("Adding address %s failed: %v", ip, err)
+		}
+	}
+	noFilter := func(string) bool { return false }
+
+	conf.Cfg.P2PNet.OnlyNets = []string{addrmgr.NetIPv4}
+	for i := 0; i < 100; i++ {
+		addr, err := n.NewAddress(noFilter)
+		if err != nil {
+			t.Fatalf("NewAddress failed: %v", err)
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host).To4() == nil {
+			t.Fatalf("onlynet=ipv4 selected %s", addr)
+		}
+	}
+
+	conf.Cfg.P2PNet.OnlyNets = []string{addrmgr.NetIPv6}
+	for i := 0; i < 100; i++ {
+		addr, err := n.NewAddress(noFilter)
+		if err != nil {
+			t.Fatalf("NewAddress failed: %v", err)
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host).To4() != nil {
+			t.Fatalf("onlynet=ipv6 selected %s", addr)
+		}
+	}
+
+	conf.Cfg.P2PNet.OnlyNets = []string{addrmgr.NetOnion}
+	if addr, err := n.NewAddress(noFilter); err == nil {
+		t.Fatalf("onlynet=onion selected %s", addr)
+	}
+}
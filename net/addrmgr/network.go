@@ -8,9 +8,19 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/net/wire"
 )
 
+// Names of the networks an address can belong to, as accepted by the onlynet
+// option.
+const (
+	NetIPv4  = "ipv4"
+	NetIPv6  = "ipv6"
+	NetOnion = "onion"
+	NetCJDNS = "cjdns"
+)
+
 var (
 	// rfc1918Nets specifies the IPv4 private address blocks as defined by
 	// by RFC1918 (10.0.0.0/8, 172.16.0.0/12, and 192.168.0.0/16).
@@ -84,6 +94,12 @@ var (
 	// { magic 6 bytes, 10 bytes base32 decode of key hash }
 	onionCatNet = ipNet("fd87:d87e:eb43::", 48, 128)
 
+	// cjdnsNet defines the IPv6 address block used by CJDNS (FC00::/8). CJDNS
+	// derives an address from the hash of the node's public key and forces
+	// its first byte to 0xfc, so it is part of the RFC4193 unique local IPv6
+	// range while still being reachable through the CJDNS tunnel.
+	cjdnsNet = ipNet("FC00::", 8, 128)
+
 	// zero4Net defines the IPv4 address block for address staring with 0
 	// (0.0.0.0/8).
 	zero4Net = ipNet("0.0.0.0", 8, 32)
@@ -104,6 +120,47 @@ func IsIPv4(na *wire.NetAddress) bool {
 	return na.IP.To4() != nil
 }
 
+// NetworkName returns the name of the network the given address belongs to.
+func NetworkName(na *wire.NetAddress) string {
+	if IsIPv4(na) {
+		return NetIPv4
+	}
+	if IsOnionCatTor(na) {
+		return NetOnion
+	}
+	if IsCJDNS(na) {
+		return NetCJDNS
+	}
+	return NetIPv6
+}
+
+// IsReachableNetwork returns whether outbound connections may be made to the
+// named network. All networks are reachable unless limited by the onlynet
+// option, except for onion and cjdns which must also be enabled.
+func IsReachableNetwork(name string) bool {
+	if name == NetOnion && conf.Cfg.P2PNet.NoOnion {
+		return false
+	}
+	if name == NetCJDNS && !conf.Cfg.P2PNet.CJDNSReachable {
+		return false
+	}
+	if len(conf.Cfg.P2PNet.OnlyNets) == 0 {
+		return true
+	}
+	for _, network := range conf.Cfg.P2PNet.OnlyNets {
+		if network == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReachable returns whether the given address belongs to a network outbound
+// connections may be made to.
+func IsReachable(na *wire.NetAddress) bool {
+	return IsReachableNetwork(NetworkName(na))
+}
+
 // IsLocal returns whether or not the given address is a local address.
 func IsLocal(na *wire.NetAddress) bool {
 	return na.IP.IsLoopback() || zero4Net.Contains(na.IP)
@@ -117,6 +174,12 @@ func IsOnionCatTor(na *wire.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// IsCJDNS returns whether or not the passed address is in the IPv6 range used
+// by CJDNS (fc00::/8).
+func IsCJDNS(na *wire.NetAddress) bool {
+	return cjdnsNet.Contains(na.IP)
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -226,14 +289,15 @@ func IsRoutable(na *wire.NetAddress) bool {
 	return IsValid(na) && !(IsRFC1918(na) || IsRFC2544(na) ||
 		IsRFC3927(na) || IsRFC4862(na) || IsRFC3849(na) ||
 		IsRFC4843(na) || IsRFC5737(na) || IsRFC6598(na) ||
-		IsLocal(na) || (IsRFC4193(na) && !IsOnionCatTor(na)))
+		IsLocal(na) || (IsRFC4193(na) && !IsOnionCatTor(na) && !IsCJDNS(na)))
 }
 
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for Tor address, the string "cjdns:key" where key is the /4
+// following the constant 0xfc prefix for a CJDNS address, and the string
+// "unroutable" for an unroutable address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
 		return "local"
@@ -268,6 +332,11 @@ func GroupKey(na *wire.NetAddress) string {
 		// group is keyed off the first 4 bits of the actual onion key.
 		return fmt.Sprintf("tor:%d", na.IP[6]&((1<<4)-1))
 	}
+	if IsCJDNS(na) {
+		// the first byte is always 0xfc, so the group is keyed off the
+		// first 4 bits of the public key derived part.
+		return fmt.Sprintf("cjdns:%d", na.IP[1]>>4)
+	}
 
 	// OK, so now we know ourselves to be a IPv6 address.
 	// bitcoind uses /32 for everything, except for Hurricane Electric's
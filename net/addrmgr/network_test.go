@@ -8,6 +8,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/net/addrmgr"
 	"github.com/copernet/copernicus/net/wire"
 )
@@ -163,7 +164,7 @@ func TestGroupKey(t *testing.T) {
 		{name: "ipv4 rfc1918 192.168/16", ip: "192.168.1.2", expected: "unroutable"},
 		{name: "ipv6 rfc3849 2001:db8::/32", ip: "2001:db8::1234", expected: "unroutable"},
 		{name: "ipv4 rfc3927 169.254/16", ip: "169.254.1.2", expected: "unroutable"},
-		{name: "ipv6 rfc4193 fc00::/7", ip: "fc00::1234", expected: "unroutable"},
+		{name: "ipv6 rfc4193 fc00::/7", ip: "fd00::1234", expected: "unroutable"},
 		{name: "ipv6 rfc4843 2001:10::/28", ip: "2001:10::1234", expected: "unroutable"},
 		{name: "ipv6 rfc4862 fe80::/64", ip: "fe80::1234", expected: "unroutable"},
 
@@ -183,11 +184,18 @@ func TestGroupKey(t *testing.T) {
 		{name: "ipv6 tor onioncat 2", ip: "fd87:d87e:eb43:1245::6789", expected: "tor:2"},
 		{name: "ipv6 tor onioncat 3", ip: "fd87:d87e:eb43:1345::6789", expected: "tor:3"},
 
+		// CJDNS.
+		{name: "ipv6 cjdns", ip: "fc12:3456::1", expected: "cjdns:1"},
+		{name: "ipv6 cjdns 2", ip: "fc1f:ffff::2", expected: "cjdns:1"},
+		{name: "ipv6 cjdns 3", ip: "fc23:4567::1", expected: "cjdns:2"},
+
 		// IPv6 normal.
 		{name: "ipv6 normal", ip: "2602:100::1", expected: "2602:100::"},
 		{name: "ipv6 normal 2", ip: "2602:0100::1234", expected: "2602:100::"},
 		{name: "ipv6 hurricane electric", ip: "2001:470:1f10:a1::2", expected: "2001:470:1000::"},
 		{name: "ipv6 hurricane electric 2", ip: "2001:0470:1f10:a1::2", expected: "2001:470:1000::"},
+		{name: "ipv6 same /32", ip: "2a00:1450:4001::1", expected: "2a00:1450::"},
+		{name: "ipv6 same /32 2", ip: "2a00:1450:ffff:1::1", expected: "2a00:1450::"},
 	}
 
 	for i, test := range tests {
@@ -200,3 +208,43 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestNetworkName tests that addresses are classified into the networks the
+// onlynet option and the reachability checks work with.
+func TestNetworkName(t *testing.T) {
+	defer func() {
+		conf.Cfg.P2PNet.CJDNSReachable = false
+	}()
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "12.1.2.3", expected: addrmgr.NetIPv4},
+		{ip: "::ffff:12.1.2.3", expected: addrmgr.NetIPv4},
+		{ip: "2001:4860:4860::8888", expected: addrmgr.NetIPv6},
+		{ip: "fd00::1", expected: addrmgr.NetIPv6},
+		{ip: "fd87:d87e:eb43:1234::5678", expected: addrmgr.NetOnion},
+		{ip: "fc00::1", expected: addrmgr.NetCJDNS},
+		{ip: "fcff:1234::1", expected: addrmgr.NetCJDNS},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 8333, wire.SFNodeNetwork)
+		if name := addrmgr.NetworkName(na); name != test.expected {
+			t.Errorf("NetworkName %s: got %s, want %s", test.ip, name, test.expected)
+		}
+	}
+
+	cjdns := wire.NewNetAddressIPPort(net.ParseIP("fc12:3456::1"), 8333, wire.SFNodeNetwork)
+	if !addrmgr.IsRoutable(cjdns) {
+		t.Errorf("cjdns address %s should be routable", cjdns.IP)
+	}
+	conf.Cfg.P2PNet.CJDNSReachable = false
+	if addrmgr.IsReachable(cjdns) {
+		t.Errorf("cjdns address %s reachable without cjdnsreachable", cjdns.IP)
+	}
+	conf.Cfg.P2PNet.CJDNSReachable = true
+	if !addrmgr.IsReachable(cjdns) {
+		t.Errorf("cjdns address %s unreachable with cjdnsreachable", cjdns.IP)
+	}
+}
@@ -67,6 +67,8 @@ type ConnReq struct {
 	state      ConnState
 	stateMtx   sync.RWMutex
 	retryCount uint32
+	canceled   int32
+	retryAfter int64
 }
 
 // updateState updates the state of the connection request.
@@ -89,6 +91,23 @@ func (c *ConnReq) State() ConnState {
 	return state
 }
 
+// Cancel stops a permanent connection request from being retried. An
+// established connection is left open until it is disconnected.
+func (c *ConnReq) Cancel() {
+	atomic.StoreInt32(&c.canceled, 1)
+}
+
+// Canceled returns whether the connection request has been canceled.
+func (c *ConnReq) Canceled() bool {
+	return atomic.LoadInt32(&c.canceled) != 0
+}
+
+// PostponeRetry holds off retrying a permanent connection request for at
+// least the given duration.
+func (c *ConnReq) PostponeRetry(d time.Duration) {
+	atomic.StoreInt64(&c.retryAfter, time.Now().Add(d).UnixNano())
+}
+
 // String returns a human-readable string for the connection request.
 func (c *ConnReq) String() string {
 	if c.Addr.String() == "" {
@@ -187,12 +206,20 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
+	if c.Permanent && c.Canceled() {
+		log.Debug("Not retrying canceled connection to %v", c)
+		return
+	}
 	if c.Permanent {
 		c.retryCount++
 		d := time.Duration(c.retryCount) * cm.cfg.RetryDuration
 		if d > maxRetryDuration {
 			d = maxRetryDuration
 		}
+		hold := time.Duration(atomic.LoadInt64(&c.retryAfter) - time.Now().UnixNano())
+		if hold > d {
+			d = hold
+		}
 		log.Debug("Retrying connection to %v in %v", c, d)
 		time.AfterFunc(d, func() {
 			cm.Connect(context.TODO(), c)
@@ -228,6 +255,11 @@ out:
 
 			case handleConnected:
 				connReq := msg.c
+				if connReq.Canceled() {
+					log.Debug("Dropping connection to canceled %v", connReq)
+					msg.conn.Close()
+					continue
+				}
 				connReq.updateState(ConnEstablished)
 				connReq.conn = msg.conn
 				conns[connReq.id] = connReq
@@ -252,7 +284,9 @@ out:
 						go cm.cfg.OnDisconnection(connReq)
 					}
 
-					if int32(len(conns)) < cm.cfg.TargetOutbound && msg.retry {
+					// Permanent connections are retried regardless
+					// of the outbound target.
+					if msg.retry && (connReq.Permanent || int32(len(conns)) < cm.cfg.TargetOutbound) {
 						cm.handleFailedConn(connReq)
 					}
 				} else {
@@ -304,7 +338,7 @@ func (cm *ConnManager) NewConnReq(ctx context.Context) {
 // Connect assigns an id and dials a connection to the address of the
 // connection request.
 func (cm *ConnManager) Connect(ctx context.Context, c *ConnReq) {
-	if atomic.LoadInt32(&cm.stop) != 0 {
+	if atomic.LoadInt32(&cm.stop) != 0 || c.Canceled() {
 		return
 	}
 	if atomic.LoadUint64(&c.id) == 0 {
@@ -299,6 +299,115 @@ func TestRetryPermanent(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestRetryPermanentTargetReached tests that a permanent connection is
+// reconnected after a disconnect even when the outbound target is reached,
+// and that it is no longer retried once canceled.
+func TestRetryPermanentTargetReached(t *testing.T) {
+	connected := make(chan *ConnReq)
+	disconnected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		RetryDuration:  time.Millisecond,
+		TargetOutbound: 1,
+		Dial:           mockDialer,
+		OnConnect: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+		OnDisconnection: func(c *ConnReq) {
+			disconnected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start(context.TODO())
+
+	other := &ConnReq{
+		Addr: &net.TCPAddr{
+			IP:   net.ParseIP("127.0.0.1"),
+			Port: 18556,
+		},
+	}
+	go cmgr.Connect(context.TODO(), other)
+	<-connected
+
+	cr := &ConnReq{
+		Addr: &net.TCPAddr{
+			IP:   net.ParseIP("127.0.0.1"),
+			Port: 18555,
+		},
+		Permanent: true,
+	}
+	go cmgr.Connect(context.TODO(), cr)
+	<-connected
+
+	cmgr.Disconnect(cr.ID())
+	<-disconnected
+	select {
+	case gotConnReq := <-connected:
+		if gotConnReq.ID() != cr.ID() {
+			t.Fatalf("retry: want ID %v, got ID %v", cr.ID(), gotConnReq.ID())
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("retry: permanent connection was not reconnected")
+	}
+
+	cr.Cancel()
+	cmgr.Disconnect(cr.ID())
+	<-disconnected
+	select {
+	case <-connected:
+		t.Fatalf("cancel: canceled connection was reconnected")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cmgr.Stop()
+}
+
+// TestPostponeRetry tests that a permanent connection is not retried before
+// the postponed time.
+func TestPostponeRetry(t *testing.T) {
+	connected := make(chan *ConnReq)
+	disconnected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		RetryDuration:  time.Millisecond,
+		TargetOutbound: 1,
+		Dial:           mockDialer,
+		OnConnect: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+		OnDisconnection: func(c *ConnReq) {
+			disconnected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start(context.TODO())
+
+	cr := &ConnReq{
+		Addr: &net.TCPAddr{
+			IP:   net.ParseIP("127.0.0.1"),
+			Port: 18555,
+		},
+		Permanent: true,
+	}
+	go cmgr.Connect(context.TODO(), cr)
+	<-connected
+
+	start := time.Now()
+	cr.PostponeRetry(50 * time.Millisecond)
+	cmgr.Disconnect(cr.ID())
+	<-disconnected
+	select {
+	case <-connected:
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Fatalf("postpone: reconnected after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("postpone: permanent connection was not reconnected")
+	}
+	cmgr.Stop()
+}
+
 // TestMaxRetryDuration tests the maximum retry duration.
 //
 // We have a timed dialer which initially returns err but after RetryDuration
@@ -0,0 +1,205 @@
+package connmgr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socksVersion         = 0x05
+	socksAuthNone        = 0x00
+	socksAuthPassword    = 0x02
+	socksAuthVersion     = 0x01
+	socksCmdConnect      = 0x01
+	socksAddrTypeIPv4    = 0x01
+	socksAddrTypeDomain  = 0x03
+	socksAddrTypeIPv6    = 0x04
+	socksHandshakeWindow = time.Second * 20
+)
+
+var (
+	// ErrProxyAuthFailed indicates the proxy rejected the username and
+	// password.
+	ErrProxyAuthFailed = errors.New("proxy authentication failed")
+
+	// ErrProxyHostTooLong indicates the host name does not fit in a SOCKS5
+	// request.
+	ErrProxyHostTooLong = errors.New("proxy host name too long")
+)
+
+// Proxy dials connections through a SOCKS5 proxy such as Tor. Host names are
+// passed to the proxy as they are, so they are resolved by the proxy and never
+// looked up locally.
+type Proxy struct {
+	Addr     string
+	Username string
+	Password string
+
+	// TorIsolation makes each connection authenticate with random
+	// credentials, so that Tor puts it on a circuit of its own.
+	TorIsolation bool
+}
+
+// DialContext connects to the address through the proxy.
+func (p *Proxy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(socksHandshakeWindow)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	if err := p.handshake(conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// credentials returns the username and password to authenticate with, random
+// ones when stream isolation is wanted.
+func (p *Proxy) credentials() (string, string, error) {
+	if !p.TorIsolation {
+		return p.Username, p.Password, nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(buf[:8]), hex.EncodeToString(buf[8:]), nil
+}
+
+// handshake negotiates the authentication and the CONNECT request on the
+// connection to the proxy.
+func (p *Proxy) handshake(conn net.Conn, host string, port uint16) error {
+	username, password, err := p.credentials()
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{socksAuthNone}
+	if username != "" || password != "" {
+		methods = []byte{socksAuthPassword}
+	}
+	buf := append([]byte{socksVersion, byte(len(methods))}, methods...)
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+
+	buf = make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socksVersion {
+		return ErrTorInvalidProxyResponse
+	}
+	switch buf[1] {
+	case socksAuthNone:
+	case socksAuthPassword:
+		if err := authenticate(conn, username, password); err != nil {
+			return err
+		}
+	default:
+		return ErrTorUnrecognizedAuthMethod
+	}
+
+	buf = []byte{socksVersion, socksCmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return ErrProxyHostTooLong
+		}
+		buf = append(buf, socksAddrTypeDomain, byte(len(host)))
+		buf = append(buf, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		buf = append(buf, socksAddrTypeIPv4)
+		buf = append(buf, ip4...)
+	} else {
+		buf = append(buf, socksAddrTypeIPv6)
+		buf = append(buf, ip.To16()...)
+	}
+	buf = append(buf, byte(port>>8), byte(port))
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+
+	// The reply carries the address the proxy bound, which is of no use
+	// here but must be consumed.
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socksVersion {
+		return ErrTorInvalidProxyResponse
+	}
+	if buf[1] != torSucceeded {
+		if err, ok := torStatusErrors[buf[1]]; ok {
+			return err
+		}
+		return ErrTorInvalidProxyResponse
+	}
+
+	var addrLen int
+	switch buf[3] {
+	case socksAddrTypeIPv4:
+		addrLen = net.IPv4len
+	case socksAddrTypeIPv6:
+		addrLen = net.IPv6len
+	case socksAddrTypeDomain:
+		lenBuf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, lenBuf); err != nil {
+			return err
+		}
+		addrLen = int(lenBuf[0])
+	default:
+		return ErrTorInvalidAddressResponse
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
+
+// authenticate runs the username/password subnegotiation of RFC 1929.
+func authenticate(conn net.Conn, username, password string) error {
+	if len(username) > 255 || len(password) > 255 {
+		return ErrProxyAuthFailed
+	}
+
+	buf := []byte{socksAuthVersion, byte(len(username))}
+	buf = append(buf, username...)
+	buf = append(buf, byte(len(password)))
+	buf = append(buf, password...)
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+
+	buf = make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socksAuthVersion || buf[1] != 0 {
+		return ErrProxyAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package connmgr
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+// socksRequest is what a fakeSocksServer saw of a client handshake.
+type socksRequest struct {
+	methods  []byte
+	username string
+	password string
+	addrType byte
+	host     string
+	port     uint16
+}
+
+// fakeSocksServer accepts SOCKS5 connections, records the handshakes and
+// replies with success.
+func fakeSocksServer(t *testing.T) (string, <-chan *socksRequest) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	requests := make(chan *socksRequest, 4)
+
+	readBytes := func(conn net.Conn, n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Errorf("fake proxy read failed: %v", err)
+		}
+		return buf
+	}
+
+	go func() {
+		defer listener.Close()
+		for i := 0; i < cap(requests); i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			req := &socksRequest{}
+
+			greeting := readBytes(conn, 2)
+			req.methods = readBytes(conn, int(greeting[1]))
+			if req.methods[0] == socksAuthPassword {
+				conn.Write([]byte{socksVersion, socksAuthPassword})
+				auth := readBytes(conn, 2)
+				req.username = string(readBytes(conn, int(auth[1])))
+				req.password = string(readBytes(conn, int(readBytes(conn, 1)[0])))
+				conn.Write([]byte{socksAuthVersion, 0})
+			} else {
+				conn.Write([]byte{socksVersion, socksAuthNone})
+			}
+
+			head := readBytes(conn, 4)
+			req.addrType = head[3]
+			switch req.addrType {
+			case socksAddrTypeDomain:
+				req.host = string(readBytes(conn, int(readBytes(conn, 1)[0])))
+			case socksAddrTypeIPv4:
+				req.host = net.IP(readBytes(conn, net.IPv4len)).String()
+			case socksAddrTypeIPv6:
+				req.host = net.IP(readBytes(conn, net.IPv6len)).String()
+			}
+			port := readBytes(conn, 2)
+			req.port = uint16(port[0])<<8 | uint16(port[1])
+
+			conn.Write([]byte{socksVersion, torSucceeded, 0, socksAddrTypeIPv4, 127, 0, 0, 1, 0x1f, 0x90})
+			conn.Close()
+			requests <- req
+		}
+	}()
+
+	return listener.Addr().String(), requests
+}
+
+func TestProxyDialContext(t *testing.T) {
+	proxyAddr, requests := fakeSocksServer(t)
+	proxy := &Proxy{Addr: proxyAddr}
+
+	// the name cannot be resolved locally, so dialing only works when it is
+	// handed over to the proxy
+	conn, err := proxy.DialContext(context.TODO(), "tcp", "seed.bitcoin.invalid:8333")
+	if err != nil {
+		t.Fatalf("dial through proxy failed: %v", err)
+	}
+	conn.Close()
+	req := <-requests
+	if len(req.methods) != 1 || req.methods[0] != socksAuthNone {
+		t.Errorf("expect no authentication offered, got %v", req.methods)
+	}
+	if req.addrType != socksAddrTypeDomain || req.host != "seed.bitcoin.invalid" || req.port != 8333 {
+		t.Errorf("expect domain name passed to the proxy, got %+v", req)
+	}
+
+	conn, err = proxy.DialContext(context.TODO(), "tcp", "[2001:db8::1]:18333")
+	if err != nil {
+		t.Fatalf("dial through proxy failed: %v", err)
+	}
+	conn.Close()
+	req = <-requests
+	if req.addrType != socksAddrTypeIPv6 || req.host != "2001:db8::1" || req.port != 18333 {
+		t.Errorf("expect IPv6 address passed to the proxy, got %+v", req)
+	}
+}
+
+func TestProxyTorIsolation(t *testing.T) {
+	proxyAddr, requests := fakeSocksServer(t)
+	proxy := &Proxy{Addr: proxyAddr, TorIsolation: true}
+
+	usernames := make(map[string]struct{})
+	for i := 0; i < 2; i++ {
+		conn, err := proxy.DialContext(context.TODO(), "tcp", "12.1.2.3:8333")
+		if err != nil {
+			t.Fatalf("dial through proxy failed: %v", err)
+		}
+		conn.Close()
+
+		req := <-requests
+		if req.methods[0] != socksAuthPassword || req.username == "" || req.password == "" {
+			t.Fatalf("expect random credentials, got %+v", req)
+		}
+		if req.addrType != socksAddrTypeIPv4 || req.host != "12.1.2.3" {
+			t.Errorf("expect IPv4 address passed to the proxy, got %+v", req)
+		}
+		usernames[req.username] = struct{}{}
+	}
+	if len(usernames) != 2 {
+		t.Errorf("expect a fresh username for each connection")
+	}
+}
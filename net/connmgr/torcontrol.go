@@ -0,0 +1,164 @@
+package connmgr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	torControlOK          = 250
+	torControlDialTimeout = time.Second * 10
+)
+
+// ErrTorNoAuthMethod indicates the control port offers no authentication
+// method that can be used.
+var ErrTorNoAuthMethod = errors.New("no usable tor control authentication method")
+
+// TorControl is a connection to the control port of a Tor daemon. Ephemeral
+// onion services added through it live as long as the connection does.
+type TorControl struct {
+	conn *textproto.Conn
+}
+
+// DialTorControl connects to the Tor control port at the address.
+func DialTorControl(addr string) (*TorControl, error) {
+	conn, err := net.DialTimeout("tcp", addr, torControlDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &TorControl{conn: textproto.NewConn(conn)}, nil
+}
+
+// command sends a command and returns the lines of the reply, which must be
+// successful.
+func (c *TorControl) command(format string, args ...interface{}) ([]string, error) {
+	if err := c.conn.PrintfLine(format, args...); err != nil {
+		return nil, err
+	}
+	_, msg, err := c.conn.ReadResponse(torControlOK)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(msg, "\n"), nil
+}
+
+// Authenticate authenticates to the control port, with the password when one
+// is given and with the cookie file or no credentials otherwise.
+func (c *TorControl) Authenticate(password string) error {
+	if password != "" {
+		_, err := c.command("AUTHENTICATE %s", strconv.Quote(password))
+		return err
+	}
+
+	lines, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	var methods, cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		for _, field := range splitTorReplyLine(line[len("AUTH "):]) {
+			switch {
+			case strings.HasPrefix(field, "METHODS="):
+				methods = field[len("METHODS="):]
+			case strings.HasPrefix(field, "COOKIEFILE="):
+				cookieFile, err = strconv.Unquote(field[len("COOKIEFILE="):])
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, method := range strings.Split(methods, ",") {
+		switch method {
+		case "NULL":
+			_, err := c.command("AUTHENTICATE")
+			return err
+		case "COOKIE":
+			cookie, err := ioutil.ReadFile(cookieFile)
+			if err != nil {
+				return err
+			}
+			_, err = c.command("AUTHENTICATE %s", hex.EncodeToString(cookie))
+			return err
+		}
+	}
+	return ErrTorNoAuthMethod
+}
+
+// AddOnion creates an onion service forwarding the virtual port to the
+// target. A new v3 key is generated unless privateKey holds one returned by a
+// previous call. It returns the service ID, which is the onion address
+// without the ".onion" suffix, and the private key of the service.
+func (c *TorControl) AddOnion(privateKey string, virtPort uint16, target string) (string, string, error) {
+	key := privateKey
+	if key == "" {
+		key = "NEW:ED25519-V3"
+	}
+	lines, err := c.command("ADD_ONION %s Port=%d,%s", key, virtPort, target)
+	if err != nil {
+		return "", "", err
+	}
+
+	var serviceID string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = line[len("ServiceID="):]
+		case strings.HasPrefix(line, "PrivateKey="):
+			privateKey = line[len("PrivateKey="):]
+		}
+	}
+	if serviceID == "" {
+		return "", "", fmt.Errorf("no service ID in reply to ADD_ONION: %q", lines)
+	}
+	return serviceID, privateKey, nil
+}
+
+// DelOnion removes an onion service added by AddOnion.
+func (c *TorControl) DelOnion(serviceID string) error {
+	_, err := c.command("DEL_ONION %s", serviceID)
+	return err
+}
+
+// Close closes the connection to the control port.
+func (c *TorControl) Close() error {
+	return c.conn.Close()
+}
+
+// splitTorReplyLine splits a reply line into its space separated fields,
+// keeping quoted strings whole.
+func splitTorReplyLine(line string) []string {
+	var fields []string
+	var quoted, escaped bool
+	start := 0
+	for i, ch := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && quoted:
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+		case ch == ' ' && !quoted:
+			if i > start {
+				fields = append(fields, line[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(line) {
+		fields = append(fields, line[start:])
+	}
+	return fields
+}
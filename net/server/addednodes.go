@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/net/connmgr"
+)
+
+var (
+	errNodeAlreadyAdded = errors.New("node already added")
+	errNodeNotAdded     = errors.New("node has not been added")
+)
+
+// addedNode is a node added through the addnode RPC along with the permanent
+// connection request made for it.
+type addedNode struct {
+	addr    string
+	connReq *connmgr.ConnReq
+}
+
+// addedNodes keeps the nodes added through the addnode RPC and persists their
+// addresses to a file, so that they are connected again after a restart. It is
+// safe for concurrent access.
+type addedNodes struct {
+	mtx   sync.Mutex
+	file  string
+	nodes map[string]*connmgr.ConnReq
+}
+
+func newAddedNodes(file string) *addedNodes {
+	return &addedNodes{
+		file:  file,
+		nodes: make(map[string]*connmgr.ConnReq),
+	}
+}
+
+// Add records a node and its connection request. The address must be the one
+// provided to addnode.
+func (a *addedNodes) Add(addr string, connReq *connmgr.ConnReq) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if _, ok := a.nodes[addr]; ok {
+		return errNodeAlreadyAdded
+	}
+	a.nodes[addr] = connReq
+	a.save()
+	return nil
+}
+
+// Remove forgets a node and returns its connection request.
+func (a *addedNodes) Remove(addr string) (*connmgr.ConnReq, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	connReq, ok := a.nodes[addr]
+	if !ok {
+		return nil, errNodeNotAdded
+	}
+	delete(a.nodes, addr)
+	a.save()
+	return connReq, nil
+}
+
+// RemoveConnReq forgets the node the connection request was made for, if any.
+func (a *addedNodes) RemoveConnReq(connReq *connmgr.ConnReq) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for addr, req := range a.nodes {
+		if req == connReq {
+			delete(a.nodes, addr)
+			a.save()
+			return
+		}
+	}
+}
+
+// List returns the added nodes sorted by address.
+func (a *addedNodes) List() []addedNode {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	nodes := make([]addedNode, 0, len(a.nodes))
+	for addr, connReq := range a.nodes {
+		nodes = append(nodes, addedNode{addr: addr, connReq: connReq})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].addr < nodes[j].addr
+	})
+	return nodes
+}
+
+// save writes the added node addresses to the file. It must be called with
+// the lock held.
+func (a *addedNodes) save() {
+	addrs := make([]string, 0, len(a.nodes))
+	for addr := range a.nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	w, err := os.Create(a.file)
+	if err != nil {
+		log.Error("Error opening file %s: %v", a.file, err)
+		return
+	}
+	defer w.Close()
+
+	if err := json.NewEncoder(w).Encode(&addrs); err != nil {
+		log.Error("Failed to encode file %s: %v", a.file, err)
+	}
+}
+
+// load returns the node addresses saved to the file by a previous run.
+func (a *addedNodes) load() ([]string, error) {
+	r, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s error opening file: %v", a.file, err)
+	}
+	defer r.Close()
+
+	var addrs []string
+	if err := json.NewDecoder(r).Decode(&addrs); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", a.file, err)
+	}
+	return addrs, nil
+}
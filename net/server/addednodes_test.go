@@ -0,0 +1,51 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/copernet/copernicus/net/connmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddedNodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addednodes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "addednodes.json")
+
+	nodes := newAddedNodes(file)
+	addrs, err := nodes.load()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(addrs))
+
+	first := &connmgr.ConnReq{Permanent: true}
+	second := &connmgr.ConnReq{Permanent: true}
+	assert.Nil(t, nodes.Add("127.0.0.1:18444", first))
+	assert.Nil(t, nodes.Add("10.0.0.1:18444", second))
+	assert.Equal(t, errNodeAlreadyAdded, nodes.Add("127.0.0.1:18444", first))
+
+	list := nodes.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "10.0.0.1:18444", list[0].addr)
+	assert.Equal(t, second, list[0].connReq)
+
+	// the list survives a restart
+	addrs, err = newAddedNodes(file).load()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:18444", "127.0.0.1:18444"}, addrs)
+
+	connReq, err := nodes.Remove("127.0.0.1:18444")
+	assert.Nil(t, err)
+	assert.Equal(t, first, connReq)
+	_, err = nodes.Remove("127.0.0.1:18444")
+	assert.Equal(t, errNodeNotAdded, err)
+
+	nodes.RemoveConnReq(second)
+	assert.Equal(t, 0, len(nodes.List()))
+	addrs, err = newAddedNodes(file).load()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(addrs))
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/net/addrmgr"
+)
+
+const (
+	// evictionProtectNetGroup is the number of inbound peers protected from
+	// eviction by their network group, so that an attacker has to control
+	// peers in many network groups to partition the node.
+	evictionProtectNetGroup = 4
+
+	// evictionProtectPing is the number of inbound peers with the lowest
+	// ping times protected from eviction.
+	evictionProtectPing = 8
+
+	// evictionProtectTx is the number of inbound peers that most recently
+	// sent us a new transaction protected from eviction.
+	evictionProtectTx = 4
+
+	// evictionProtectBlock is the number of inbound peers that most recently
+	// sent us a new block protected from eviction.
+	evictionProtectBlock = 4
+)
+
+// evictionCandidate holds the stats of an inbound peer used to pick which
+// peer to disconnect to make room for a new inbound connection.
+type evictionCandidate struct {
+	id            int32
+	timeConnected time.Time
+	minPingMicros int64 // 0 when the peer has not answered a ping yet
+	lastBlockTime time.Time
+	lastTxTime    time.Time
+	netGroup      string
+	keyedNetGroup uint64 // netGroup hashed with a per-node secret key
+}
+
+// keyedNetGroup hashes the network group with key, so that peers cannot
+// predict which network groups get protected from eviction.
+func keyedNetGroup(key uint64, netGroup string) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], key)
+	h := fnv.New64a()
+	h.Write(buf[:])
+	h.Write([]byte(netGroup))
+	return h.Sum64()
+}
+
+// newEvictionCandidate collects the eviction stats of the inbound peer sp.
+func newEvictionCandidate(sp *serverPeer, key uint64) *evictionCandidate {
+	netGroup := sp.Addr()
+	if na := sp.NA(); na != nil {
+		netGroup = addrmgr.GroupKey(na)
+	}
+	return &evictionCandidate{
+		id:            sp.ID(),
+		timeConnected: sp.TimeConnected(),
+		minPingMicros: sp.MinPingMicros(),
+		lastBlockTime: sp.LastBlockTime(),
+		lastTxTime:    sp.LastTxTime(),
+		netGroup:      netGroup,
+		keyedNetGroup: keyedNetGroup(key, netGroup),
+	}
+}
+
+// protectBy sorts candidates so that the ones to protect come last, and drops
+// up to k of them.
+func protectBy(candidates []*evictionCandidate, k int, less func(a, b *evictionCandidate) bool) []*evictionCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(candidates[i], candidates[j])
+	})
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+	return candidates[:len(candidates)-k]
+}
+
+// selectPeerToEvict picks the inbound peer to disconnect among candidates.
+// Peers bringing value to the node are protected first: a few from distinct
+// network groups, the fastest ones, the ones that recently relayed new
+// transactions or blocks, and the older half of the rest. Among the
+// remaining peers, the youngest connection of the network group with the
+// most connections is evicted. It returns false when every peer is protected.
+func selectPeerToEvict(candidates []*evictionCandidate) (int32, bool) {
+	candidates = append([]*evictionCandidate(nil), candidates...)
+
+	candidates = protectBy(candidates, evictionProtectNetGroup, func(a, b *evictionCandidate) bool {
+		return a.keyedNetGroup < b.keyedNetGroup
+	})
+	candidates = protectBy(candidates, evictionProtectPing, func(a, b *evictionCandidate) bool {
+		// peers without a ping sort as the slowest
+		if a.minPingMicros == 0 || b.minPingMicros == 0 {
+			return a.minPingMicros == 0 && b.minPingMicros != 0
+		}
+		return a.minPingMicros > b.minPingMicros
+	})
+	candidates = protectBy(candidates, evictionProtectTx, func(a, b *evictionCandidate) bool {
+		return a.lastTxTime.Before(b.lastTxTime)
+	})
+	candidates = protectBy(candidates, evictionProtectBlock, func(a, b *evictionCandidate) bool {
+		return a.lastBlockTime.Before(b.lastBlockTime)
+	})
+	candidates = protectBy(candidates, len(candidates)/2, func(a, b *evictionCandidate) bool {
+		return a.timeConnected.After(b.timeConnected)
+	})
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	// group the rest by network group, keeping the youngest peer of each
+	groups := make(map[string][]*evictionCandidate)
+	youngest := make(map[string]*evictionCandidate)
+	for _, c := range candidates {
+		groups[c.netGroup] = append(groups[c.netGroup], c)
+		if y, ok := youngest[c.netGroup]; !ok || c.timeConnected.After(y.timeConnected) {
+			youngest[c.netGroup] = c
+		}
+	}
+
+	var evict *evictionCandidate
+	mostConnections := 0
+	for group, peers := range groups {
+		y := youngest[group]
+		if len(peers) > mostConnections ||
+			(len(peers) == mostConnections && y.timeConnected.After(evict.timeConnected)) {
+			mostConnections = len(peers)
+			evict = y
+		}
+	}
+	return evict.id, true
+}
+
+// evictInboundPeer disconnects an unprotected inbound peer to make room for a
+// new inbound connection. Whitelisted peers are never evicted. It returns
+// whether a peer was evicted.
+func (ps *peerState) evictInboundPeer() bool {
+	candidates := make([]*evictionCandidate, 0, len(ps.inboundPeers))
+	for _, sp := range ps.inboundPeers {
+		if sp.IsWhitelisted() {
+			continue
+		}
+		candidates = append(candidates, newEvictionCandidate(sp, ps.netGroupKey))
+	}
+
+	id, ok := selectPeerToEvict(candidates)
+	if !ok {
+		return false
+	}
+	sp := ps.inboundPeers[id]
+	log.Info("Evicting inbound peer %s to make room for a new connection", sp)
+	delete(ps.inboundPeers, id)
+	sp.Disconnect()
+	return true
+}
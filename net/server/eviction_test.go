@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func makeEvictionCandidates() []*evictionCandidate {
+	start := time.Unix(1600000000, 0)
+	candidates := make([]*evictionCandidate, 0, 40)
+	for id := int32(1); id <= 40; id++ {
+		candidates = append(candidates, &evictionCandidate{
+			id:            id,
+			timeConnected: start.Add(time.Duration(id) * time.Minute),
+			netGroup:      fmt.Sprintf("group%d", id),
+			keyedNetGroup: uint64(id),
+		})
+	}
+	for _, c := range candidates {
+		switch id := c.id; {
+		case id <= 4:
+			c.keyedNetGroup = uint64(1000 + id)
+		case id <= 12:
+			c.minPingMicros = int64(100 + id)
+		case id <= 16:
+			c.lastTxTime = start.Add(time.Hour)
+		case id <= 20:
+			c.lastBlockTime = start.Add(time.Hour)
+		case id > 30 && id <= 35:
+			// the youngest peers share a network group
+			c.netGroup = "attacker"
+		}
+	}
+	return candidates
+}
+
+func TestSelectPeerToEvict(t *testing.T) {
+	candidates := makeEvictionCandidates()
+
+	// 1-4 are protected by network group, 5-12 by ping, 13-16 by tx relay,
+	// 17-20 by block relay and 21-30 as the oldest half of the rest.
+	id, ok := selectPeerToEvict(candidates)
+	if !ok {
+		t.Fatal("expected a peer to evict")
+	}
+	if id != 35 {
+		t.Errorf("expected the youngest peer of the largest network group 35 to be evicted, got %d", id)
+	}
+
+	// further fresh connections only ever displace unprotected peers
+	protected := make(map[int32]*evictionCandidate)
+	for _, c := range candidates {
+		if c.id <= 20 {
+			protected[c.id] = c
+		}
+	}
+	remaining := candidates
+	for {
+		id, ok := selectPeerToEvict(remaining)
+		if !ok {
+			break
+		}
+		if _, ok := protected[id]; ok {
+			t.Fatalf("protected peer %d was evicted", id)
+		}
+		next := remaining[:0:0]
+		for _, c := range remaining {
+			if c.id != id {
+				next = append(next, c)
+			}
+		}
+		remaining = next
+	}
+	if len(remaining) != len(protected) {
+		t.Errorf("expected the %d protected peers to survive, got %d peers", len(protected), len(remaining))
+	}
+}
+
+func TestSelectPeerToEvictAllProtected(t *testing.T) {
+	candidates := makeEvictionCandidates()[:20]
+	if id, ok := selectPeerToEvict(candidates); ok {
+		t.Errorf("expected no peer to evict, got %d", id)
+	}
+
+	if _, ok := selectPeerToEvict(nil); ok {
+		t.Error("expected no peer to evict without candidates")
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/net/addrmgr"
 	"github.com/copernet/copernicus/net/wire"
 	"github.com/copernet/copernicus/peer"
 	"github.com/copernet/copernicus/rpc/btcjson"
@@ -155,6 +157,21 @@ out:
 					peerFrom.Cfg.Listeners.OnFeeFilter(peerFrom, data)
 				}
 				msg.Done <- struct{}{}
+			case *wire.MsgGetCFilters:
+				if peerFrom.Cfg.Listeners.OnGetCFilters != nil {
+					peerFrom.Cfg.Listeners.OnGetCFilters(peerFrom, data)
+				}
+				msg.Done <- struct{}{}
+			case *wire.MsgGetCFHeaders:
+				if peerFrom.Cfg.Listeners.OnGetCFHeaders != nil {
+					peerFrom.Cfg.Listeners.OnGetCFHeaders(peerFrom, data)
+				}
+				msg.Done <- struct{}{}
+			case *wire.MsgGetCFCheckpt:
+				if peerFrom.Cfg.Listeners.OnGetCFCheckpt != nil {
+					peerFrom.Cfg.Listeners.OnGetCFCheckpt(peerFrom, data)
+				}
+				msg.Done <- struct{}{}
 			case *wire.MsgFilterAdd:
 				if peerFrom.Cfg.Listeners.OnFilterAdd != nil {
 					peerFrom.Cfg.Listeners.OnFilterAdd(peerFrom, data)
@@ -235,6 +252,10 @@ func ProcessForRPC(message interface{}) (rsp interface{}, err error) {
 		msgHandle.BroadcastMessage(m)
 		return nil, nil
 
+	case *btcjson.PingCmd:
+		msgHandle.PingPeers()
+		return nil, nil
+
 	case *service.GetPeersInfoRequest:
 		return rpcConnMgr.ConnectedPeers(), nil
 
@@ -252,28 +273,36 @@ func ProcessForRPC(message interface{}) (rsp interface{}, err error) {
 			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "invalid subcommand for addnode")
 		}
 
-		if err != nil {
+		switch err {
+		case nil:
+			return nil, nil
+		case errNodeAlreadyAdded:
+			return nil, btcjson.NewRPCError(btcjson.RPCClientNodeAlreadyAdded, "Error: Node already added")
+		case errNodeNotAdded:
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCClientNodeNotAdded, "Error: Node has not been added.")
+		default:
 			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, err.Error())
 		}
-		return nil, nil
 
 	case *btcjson.DisconnectNodeCmd:
 		cmd := message.(*btcjson.DisconnectNodeCmd)
 
-		var addr string
-		var nodeID int32
 		var err error
 
+		// An empty address is the way to disconnect by node id with
+		// positional arguments.
+		if cmd.Address != nil && *cmd.Address == "" && cmd.NodeID != nil {
+			cmd.Address = nil
+		}
+
 		// If we have a valid uint disconnect by node id. Otherwise,
 		// attempt to disconnect by address, returning an error if a
 		// valid IP address is not supplied.
 		if cmd.Address == nil && cmd.NodeID != nil {
-			nodeID = *cmd.NodeID
-			err = rpcConnMgr.DisconnectByID(nodeID)
+			err = rpcConnMgr.DisconnectByID(*cmd.NodeID)
 		} else if cmd.Address != nil && cmd.NodeID == nil {
 			if host, port, errP := net.SplitHostPort(*cmd.Address); errP == nil {
-				addr = net.JoinHostPort(host, port)
-				err = rpcConnMgr.DisconnectByAddr(addr)
+				err = rpcConnMgr.DisconnectByAddr(net.JoinHostPort(host, port))
 			} else {
 				return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Invalid address or node ID")
 			}
@@ -282,35 +311,43 @@ func ProcessForRPC(message interface{}) (rsp interface{}, err error) {
 				"Only one of address and nodeid should be provided.")
 		}
 		if err != nil {
-			if peerExists(addr, nodeID) {
-				return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Can't disconnect a permanent peer, use remove")
-			}
 			return nil, btcjson.NewRPCError(btcjson.RPCClientNodeNotConnected, "Node not found in connected nodes")
 		}
 		return nil, nil
 
-		//case *btcjson.GetAddedNodeInfoCmd:
-		//	return msgHandle.connManager.PersistentPeers(), nil
+	case *btcjson.GetAddedNodeInfoCmd:
+		return rpcConnMgr.AddedNodeInfo(m.Node)
 
 	case *service.GetNetTotalsRequest:
 		return
 
+	case *btcjson.GetNetTotalsCmd:
+		return handleGetNetTotals()
+
 	case *btcjson.GetNetworkInfoCmd:
 		return handleGetNetworkInfo()
 
 	case *btcjson.SetBanCmd:
-		cmd := message.(*btcjson.SetBanCmd)
-		err := rpcConnMgr.SetBan(cmd)
-		return nil, err
+		if err := rpcConnMgr.SetBan(m); err != nil {
+			return nil, err
+		}
+		return nil, nil
 
 	case *btcjson.ListBannedCmd:
 		ret, err := rpcConnMgr.ListBanned()
-		return ret, err
+		if err != nil {
+			return nil, err
+		}
+		return ret, nil
 
 	case *btcjson.ClearBannedCmd:
 		rpcConnMgr.ClearBanned()
 		return nil, nil
 
+	case *btcjson.SetNetWorkActiveCmd:
+		msgHandle.SetNetworkActive(m.State)
+		return msgHandle.NetworkActive(), nil
+
 	case *wire.InvVect:
 		msgHandle.RelayInventory(m, nil)
 		return nil, nil
@@ -367,40 +404,44 @@ func handleGetNetworkInfo() (*btcjson.GetNetworkInfoResult, error) {
 		LocalRelay:       !conf.Cfg.P2PNet.BlocksOnly,
 		TimeOffset:       util.GetTimeOffsetSec(),
 		Connections:      msgHandle.ConnectedCount(),
-		NetworkActive:    true, // NOT support RPC 'setnetworkactive'
+		NetworkActive:    msgHandle.NetworkActive(),
 		Networks:         getNetworks(),
-		RelayFee:         valueFromAmount(util.NewFeeRate(util.DefaultMinRelayTxFeePerK).GetFeePerK()),
+		RelayFee:         valueFromAmount(mempool.GetInstance().MinRelayTxFee().SataoshisPerK),
 		ExcessUtxoCharge: 0,
 		LocalAddresses:   rpcLocalAddrList,
+		UploadTarget:     msgHandle.uploadTarget.Info(),
 		Warnings:         "", // TODO: network warnings
 	}
 	return chainInfo, nil
 }
 
+func handleGetNetTotals() (*btcjson.GetNetTotalsResult, error) {
+	bytesRecv, bytesSent := msgHandle.NetTotals()
+	return &btcjson.GetNetTotalsResult{
+		TotalBytesRecv: bytesRecv,
+		TotalBytesSent: bytesSent,
+		TimeMillis:     util.GetTimeMicroSec() / 1000,
+		UploadTarget:   msgHandle.uploadTarget.Info(),
+	}, nil
+}
+
 func getNetworks() []btcjson.NetworksResult {
-	networkInfos := make([]btcjson.NetworksResult, 0)
-	ipv4NetWork := btcjson.NetworksResult{
-		Name:      "ipv4",
-		Limited:   false,
-		Reachable: true,
-		//Proxy                     string `json:"proxy"`
-		//ProxyRandomizeCredentials bool   `json:"proxy_randomize_credentials"`
-	}
-	ipv6NetWork := btcjson.NetworksResult{
-		Name:      "ipv6",
-		Limited:   false,
-		Reachable: true,
-		//Proxy                     string `json:"proxy"`
-		//ProxyRandomizeCredentials bool   `json:"proxy_randomize_credentials"`
-	}
-	onionNetWork := btcjson.NetworksResult{
-		Name:      "ipv4",
-		Limited:   conf.Cfg.P2PNet.NoOnion,
-		Reachable: !conf.Cfg.P2PNet.NoOnion,
-		//Proxy                     string `json:"proxy"`
-		//ProxyRandomizeCredentials bool   `json:"proxy_randomize_credentials"`
+	names := []string{addrmgr.NetIPv4, addrmgr.NetIPv6, addrmgr.NetOnion, addrmgr.NetCJDNS}
+	networkInfos := make([]btcjson.NetworksResult, 0, len(names))
+	for _, name := range names {
+		reachable := addrmgr.IsReachableNetwork(name)
+		proxy := conf.Cfg.P2PNet.Proxy
+		if name == addrmgr.NetOnion && conf.Cfg.P2PNet.OnionProxy != "" {
+			proxy = conf.Cfg.P2PNet.OnionProxy
+		}
+		networkInfos = append(networkInfos, btcjson.NetworksResult{
+			Name:                      name,
+			Limited:                   !reachable,
+			Reachable:                 reachable,
+			Proxy:                     proxy,
+			ProxyRandomizeCredentials: proxy != "" && conf.Cfg.P2PNet.ProxyRandomize,
+		})
 	}
-	networkInfos = append(networkInfos, ipv4NetWork, ipv6NetWork, onionNetWork)
 	return networkInfos
 }
 
@@ -424,17 +465,3 @@ func valueFromAmount(sizeLimit int64) float64 {
 	}
 	return result
 }
-
-// peerExists determines if a certain peer is currently connected given
-// information about all currently connected peers. Peer existence is
-// determined using either a target address or node id.
-func peerExists(addr string, nodeID int32) bool {
-	connected := rpcConnMgr.ConnectedPeers()
-
-	for _, p := range connected {
-		if p.ToPeer().ID() == nodeID || p.ToPeer().Addr() == addr {
-			return true
-		}
-	}
-	return false
-}
@@ -69,6 +69,10 @@ func TestProcessForRPC(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, msgPingRsp)
 
+	pingRsp, err := ProcessForRPC(&btcjson.PingCmd{})
+	assert.Nil(t, err)
+	assert.Nil(t, pingRsp)
+
 	getPeersInfoReq := &service.GetPeersInfoRequest{}
 	getPeersInfoRsp, err := ProcessForRPC(getPeersInfoReq)
 	assert.Nil(t, err)
@@ -117,12 +121,28 @@ func TestProcessForRPC_Connection(t *testing.T) {
 			},
 			isErr: false,
 		},
+		{
+			name: "test addnode add twice",
+			req: &btcjson.AddNodeCmd{
+				Addr:   "127.0.0.1:18834",
+				SubCmd: "add",
+			},
+			isErr: true,
+		},
 		{
 			name: "test addnode remove",
 			req: &btcjson.AddNodeCmd{
 				Addr:   "127.0.0.1:18834",
 				SubCmd: "remove",
 			},
+			isErr: false,
+		},
+		{
+			name: "test addnode remove not added",
+			req: &btcjson.AddNodeCmd{
+				Addr:   "127.0.0.1:18834",
+				SubCmd: "remove",
+			},
 			isErr: true,
 		},
 		{
@@ -155,6 +175,28 @@ func TestProcessForRPC_Connection(t *testing.T) {
 	}
 }
 
+func TestProcessForRPC_GetAddedNodeInfo(t *testing.T) {
+	addr := "127.0.0.1:18835"
+	_, err := ProcessForRPC(&btcjson.AddNodeCmd{Addr: addr, SubCmd: "add"})
+	assert.Nil(t, err)
+	defer ProcessForRPC(&btcjson.AddNodeCmd{Addr: addr, SubCmd: "remove"})
+
+	rsp, err := ProcessForRPC(&btcjson.GetAddedNodeInfoCmd{Node: &addr})
+	assert.Nil(t, err)
+	infos := rsp.([]*btcjson.GetAddedNodeInfoResult)
+	assert.Equal(t, 1, len(infos))
+	assert.Equal(t, addr, infos[0].AddedNode)
+	assert.NotNil(t, infos[0].Connected)
+
+	rsp, err = ProcessForRPC(&btcjson.GetAddedNodeInfoCmd{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rsp.([]*btcjson.GetAddedNodeInfoResult)))
+
+	notAdded := "127.0.0.1:18836"
+	_, err = ProcessForRPC(&btcjson.GetAddedNodeInfoCmd{Node: &notAdded})
+	assert.NotNil(t, err)
+}
+
 func TestProcessForRPC_DisConnection(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -204,6 +246,119 @@ func TestProcessForRPC_DisConnection(t *testing.T) {
 	}
 }
 
+func TestProcessForRPC_DisconnectByID(t *testing.T) {
+	r, w := io.Pipe()
+	inConn := &conn{raddr: "127.0.0.1:18336", Writer: w, Reader: r}
+	sp := newServerPeer(s, false)
+	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp), false)
+	sp.AssociateConnection(inConn, s.MsgChan, func(*peer.Peer) {})
+	s.AddPeer(sp)
+
+	listed := func() bool {
+		rsp, err := ProcessForRPC(&service.GetPeersInfoRequest{})
+		assert.Nil(t, err)
+		for _, p := range rsp.([]RPCServerPeer) {
+			if p.ToPeer().ID() == sp.ID() {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 100 && !listed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, listed())
+
+	id := sp.ID()
+	rsp, err := ProcessForRPC(&btcjson.DisconnectNodeCmd{NodeID: &id})
+	assert.Nil(t, rsp)
+	assert.Nil(t, err)
+	assert.False(t, listed())
+
+	empty := ""
+	_, err = ProcessForRPC(&btcjson.DisconnectNodeCmd{Address: &empty, NodeID: &id})
+	assert.Equal(t, btcjson.RPCErrorCode(btcjson.RPCClientNodeNotConnected), err.(*btcjson.RPCError).Code)
+}
+
+func TestProcessForRPC_SetNetworkActive(t *testing.T) {
+	r, w := io.Pipe()
+	inConn := &conn{raddr: "127.0.0.1:18337", Writer: w, Reader: r}
+	sp := newServerPeer(s, false)
+	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp), false)
+	sp.AssociateConnection(inConn, s.MsgChan, func(*peer.Peer) {})
+	s.AddPeer(sp)
+
+	listed := func() bool {
+		rsp, err := ProcessForRPC(&service.GetPeersInfoRequest{})
+		assert.Nil(t, err)
+		for _, p := range rsp.([]RPCServerPeer) {
+			if p.ToPeer().ID() == sp.ID() {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 100 && !listed(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, listed())
+
+	rsp, err := ProcessForRPC(&btcjson.SetNetWorkActiveCmd{State: false})
+	defer ProcessForRPC(&btcjson.SetNetWorkActiveCmd{State: true})
+	assert.Nil(t, err)
+	assert.Equal(t, false, rsp)
+	assert.False(t, sp.Connected())
+	assert.False(t, listed())
+
+	info, err := handleGetNetworkInfo()
+	assert.Nil(t, err)
+	assert.False(t, info.NetworkActive)
+
+	// inbound connections are refused while the network is off
+	refused, remote := pipe(
+		&conn{raddr: "10.0.0.1:8333"},
+		&conn{raddr: "10.0.0.2:8333"},
+	)
+	s.inboundPeerConnected(refused)
+	_, err = remote.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	rsp, err = ProcessForRPC(&btcjson.SetNetWorkActiveCmd{State: true})
+	assert.Nil(t, err)
+	assert.Equal(t, true, rsp)
+	info, err = handleGetNetworkInfo()
+	assert.Nil(t, err)
+	assert.True(t, info.NetworkActive)
+}
+
+func TestProcessForRPC_GetNetTotals(t *testing.T) {
+	// count on a server of its own to leave the totals of the shared one alone
+	svr := &Server{uploadTarget: newUploadTarget(0)}
+	oldMsgHandle := msgHandle
+	msgHandle = &MsgHandle{Server: svr}
+	defer func() {
+		msgHandle = oldMsgHandle
+	}()
+
+	rsp, err := ProcessForRPC(&btcjson.GetNetTotalsCmd{})
+	assert.Nil(t, err)
+	before := rsp.(*btcjson.GetNetTotalsResult)
+	assert.NotNil(t, before.UploadTarget)
+
+	sp := newServerPeer(svr, false)
+	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp), false)
+	sp.OnWrite(sp.Peer, 32, wire.NewMsgPing(1), nil)
+	sp.OnRead(sp.Peer, 40, wire.NewMsgPong(1), nil)
+	assert.Equal(t, uint64(32), (*rpcPeer)(sp).BytesSentInCycle())
+
+	rsp, err = ProcessForRPC(&btcjson.GetNetTotalsCmd{})
+	assert.Nil(t, err)
+	after := rsp.(*btcjson.GetNetTotalsResult)
+	assert.Equal(t, before.TotalBytesSent+32, after.TotalBytesSent)
+	assert.Equal(t, before.TotalBytesRecv+40, after.TotalBytesRecv)
+	assert.True(t, after.TimeMillis >= before.TimeMillis)
+}
+
 func TestMsgHandle(t *testing.T) {
 	execCount := make(map[string]int)
 	peerCfg := &peer.Config{
@@ -255,6 +410,15 @@ func TestMsgHandle(t *testing.T) {
 			OnFeeFilter: func(p *peer.Peer, msg *wire.MsgFeeFilter) {
 				execCount["OnFeeFilter"]++
 			},
+			OnGetCFilters: func(p *peer.Peer, msg *wire.MsgGetCFilters) {
+				execCount["OnGetCFilters"]++
+			},
+			OnGetCFHeaders: func(p *peer.Peer, msg *wire.MsgGetCFHeaders) {
+				execCount["OnGetCFHeaders"]++
+			},
+			OnGetCFCheckpt: func(p *peer.Peer, msg *wire.MsgGetCFCheckpt) {
+				execCount["OnGetCFCheckpt"]++
+			},
 			OnFilterAdd: func(p *peer.Peer, msg *wire.MsgFilterAdd) {
 				execCount["OnFilterAdd"]++
 			},
@@ -390,6 +554,21 @@ func TestMsgHandle(t *testing.T) {
 			wire.NewMsgFeeFilter(15000),
 			true,
 		},
+		{
+			"OnGetCFilters",
+			wire.NewMsgGetCFilters(wire.GCSFilterRegular, 0, &util.Hash{}),
+			true,
+		},
+		{
+			"OnGetCFHeaders",
+			wire.NewMsgGetCFHeaders(wire.GCSFilterRegular, 0, &util.Hash{}),
+			true,
+		},
+		{
+			"OnGetCFCheckpt",
+			wire.NewMsgGetCFCheckpt(wire.GCSFilterRegular, &util.Hash{}),
+			true,
+		},
 		{
 			"OnFilterAdd",
 			wire.NewMsgFilterAdd([]byte{0x01}),
@@ -32,6 +32,10 @@ type RPCServerPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// BytesSentInCycle returns the bytes sent to the peer in the current
+	// upload target cycle.
+	BytesSentInCycle() uint64
 }
 
 // rpcPeer provides a peer for use with the RPC server and implements the
@@ -78,6 +82,16 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// BytesSentInCycle returns the bytes sent to the peer in the current upload
+// target cycle.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) BytesSentInCycle() uint64 {
+	sp := (*serverPeer)(p)
+	return sp.server.uploadTarget.PeerBytesSent(sp.ID())
+}
+
 // RPCConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type RPCConnManager struct {
@@ -129,6 +143,7 @@ func (cm *RPCConnManager) RemoveByID(id int32) error {
 func (cm *RPCConnManager) RemoveByAddr(addr string) error {
 	replyChan := make(chan error)
 	cm.server.query <- removeNodeMsg{
+		addr:  addr,
 		cmp:   func(sp *serverPeer) bool { return sp.Addr() == addr },
 		reply: replyChan,
 	}
@@ -217,6 +232,43 @@ func (cm *RPCConnManager) PersistentPeers() []RPCServerPeer {
 	return peers
 }
 
+// AddedNodeInfo returns the connection status of the nodes added through the
+// addnode RPC, or of the given node only when it is not nil.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *RPCConnManager) AddedNodeInfo(node *string) ([]*btcjson.GetAddedNodeInfoResult, error) {
+	replyChan := make(chan []addedNodeInfo)
+	cm.server.query <- getAddedNodeInfoMsg{reply: replyChan}
+	nodes := <-replyChan
+
+	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(nodes))
+	for _, node := range nodes {
+		connected := node.peerAddr != ""
+		result := &btcjson.GetAddedNodeInfoResult{
+			AddedNode: node.addr,
+			Connected: &connected,
+		}
+		if connected {
+			result.Addresses = &[]btcjson.GetAddedNodeInfoResultAddr{{
+				Address:   node.peerAddr,
+				Connected: "outbound",
+			}}
+		}
+		results = append(results, result)
+	}
+
+	if node == nil {
+		return results, nil
+	}
+	for _, result := range results {
+		if result.AddedNode == *node {
+			return []*btcjson.GetAddedNodeInfoResult{result}, nil
+		}
+	}
+	return nil, btcjson.NewRPCError(btcjson.ErrRPCClientNodeNotAdded, "Error: Node has not been added.")
+}
+
 // BroadcastMessage sends the provided message to all currently connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -235,42 +287,61 @@ func (cm *RPCConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interfa
 	cm.server.AddRebroadcastInventory(iv, data)
 }
 
-func (cm *RPCConnManager) SetBan(c *btcjson.SetBanCmd) *btcjson.RPCError {
-	if strings.Contains(c.SubNet, "/") {
-		_, _, err := net.ParseCIDR(c.SubNet)
-		if err != nil {
-			return btcjson.NewRPCError(btcjson.RPCClientInvalidIPOrSubnet, "Error: Invalid IP/Subnet")
-		}
-	} else {
-		ip := net.ParseIP(c.SubNet)
+// normalizeSubNet returns the canonical form of the IP or CIDR subnet, a
+// plain IP for a subnet of a single address, and whether it is valid.
+func normalizeSubNet(subNet string) (string, bool) {
+	if !strings.Contains(subNet, "/") {
+		ip := net.ParseIP(subNet)
 		if ip == nil {
-			return btcjson.NewRPCError(btcjson.RPCClientInvalidIPOrSubnet, "Error: Invalid IP/Subnet")
+			return "", false
 		}
+		return ip.String(), true
+	}
+	_, ipNet, err := net.ParseCIDR(subNet)
+	if err != nil {
+		return "", false
 	}
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
+		return ipNet.IP.String(), true
+	}
+	return ipNet.String(), true
+}
 
-	if c.Command == "add" {
+// SetBan adds or removes the ban of an IP or CIDR subnet. The ban lasts
+// bantime seconds, or until the unix time bantime if absolute is set, and
+// -bantime seconds when bantime is not positive.
+func (cm *RPCConnManager) SetBan(c *btcjson.SetBanCmd) *btcjson.RPCError {
+	subNet, ok := normalizeSubNet(c.SubNet)
+	if !ok {
+		return btcjson.NewRPCError(btcjson.RPCClientInvalidIPOrSubnet, "Error: Invalid IP/Subnet")
+	}
+
+	switch c.Command {
+	case "add":
 		now := util.GetTimeSec()
-		var endTime int64
-		if c.BanTime != nil {
+		endTime := now + conf.Cfg.BanDuration()
+		if c.BanTime != nil && *c.BanTime > 0 {
 			if c.Absolute != nil && *c.Absolute {
 				endTime = *c.BanTime
 			} else {
 				endTime = now + *c.BanTime
 			}
-		} else {
-			endTime = now + conf.Cfg.P2PNet.BanDuration
 		}
-		hasBanned := cm.server.BanAddr(c.SubNet, now, endTime, BanReasonManuallyAdded)
-		if hasBanned {
+		if endTime <= now {
+			return btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Error: Absolute bantime is in the past")
+		}
+		if !cm.server.BanAddr(subNet, now, endTime, BanReasonManuallyAdded) {
 			return btcjson.NewRPCError(btcjson.RPCClientNodeAlreadyAdded, "Error: IP/Subnet already banned")
 		}
 
-	} else if c.Command == "remove" {
-		hasBanned := cm.server.UnbanAddr(c.SubNet)
-		if !hasBanned {
+	case "remove":
+		if !cm.server.UnbanAddr(subNet) {
 			return btcjson.NewRPCError(btcjson.RPCClientInvalidIPOrSubnet,
 				"Error: Unban failed. Requested address/subnet was not previously banned.")
 		}
+
+	default:
+		return btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Error: command must be add or remove")
 	}
 
 	return nil
@@ -282,7 +353,11 @@ func (cm *RPCConnManager) ListBanned() (*btcjson.ListBannedResult, *btcjson.RPCE
 	for _, info := range bannedInfoList {
 		address := info.Address
 		if !strings.Contains(address, "/") {
-			address += "/32"
+			if net.ParseIP(address).To4() != nil {
+				address += "/32"
+			} else {
+				address += "/128"
+			}
 		}
 		bannedInfo := btcjson.BannedInfo{
 			Address:     address,
@@ -1,10 +1,13 @@
 package server
 
 import (
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/copernet/copernicus/net/wire"
 	"github.com/copernet/copernicus/peer"
+	"github.com/copernet/copernicus/rpc/btcjson"
 	"github.com/copernet/copernicus/util"
 )
 
@@ -64,3 +67,73 @@ func TestRpcPeer(t *testing.T) {
 		t.Errorf("rpcPeer FeeFilter should be 0")
 	}
 }
+
+func findBanned(list *btcjson.ListBannedResult, address string) *btcjson.BannedInfo {
+	for i := range *list {
+		if (*list)[i].Address == address {
+			return &(*list)[i]
+		}
+	}
+	return nil
+}
+
+func TestSetBan(t *testing.T) {
+	rcm := NewRPCConnManager(s)
+	now := util.GetTimeSec()
+	util.SetMockTime(now)
+	defer util.SetMockTime(0)
+	defer rcm.ClearBanned()
+
+	relative := int64(3600)
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "10.0.0.1", Command: "add", BanTime: &relative}); err != nil {
+		t.Fatalf("relative ban failed: %v", err)
+	}
+	absolute, isAbsolute := now+7200, true
+	err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "192.168.1.7/24", Command: "add",
+		BanTime: &absolute, Absolute: &isAbsolute})
+	if err != nil {
+		t.Fatalf("absolute ban failed: %v", err)
+	}
+
+	list, _ := rcm.ListBanned()
+	if info := findBanned(list, "10.0.0.1/32"); info == nil || info.BannedUntil != now+3600 ||
+		info.BanCreated != now || info.BanReason != "manually added" {
+		t.Errorf("unexpected relative ban %v", info)
+	}
+	if info := findBanned(list, "192.168.1.0/24"); info == nil || info.BannedUntil != now+7200 {
+		t.Errorf("unexpected absolute ban %v", info)
+	}
+
+	// banning the subnet again updates its ban
+	updated := int64(60)
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "192.168.1.0/24", Command: "add", BanTime: &updated}); err != nil {
+		t.Fatalf("update ban failed: %v", err)
+	}
+	list, _ = rcm.ListBanned()
+	if info := findBanned(list, "192.168.1.0/24"); info == nil || info.BannedUntil != now+60 ||
+		info.BanCreated != now {
+		t.Errorf("unexpected updated ban %v", info)
+	}
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "192.168.1.9", Command: "add", BanTime: &relative}); err != nil {
+		t.Errorf("ban of an IP banned shorter by its subnet failed: %v", err)
+	}
+
+	content, readErr := ioutil.ReadFile(s.banPeerFile)
+	if readErr != nil || !strings.Contains(string(content), "192.168.1.0/24") {
+		t.Errorf("ban not persisted to %s: %s %v", s.banPeerFile, content, readErr)
+	}
+
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "192.168.1.0/24", Command: "remove"}); err != nil {
+		t.Fatalf("remove ban failed: %v", err)
+	}
+	list, _ = rcm.ListBanned()
+	if findBanned(list, "192.168.1.0/24") != nil || findBanned(list, "10.0.0.1/32") == nil {
+		t.Errorf("unexpected bans after removal %v", *list)
+	}
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "192.168.1.0/24", Command: "remove"}); err == nil {
+		t.Errorf("removing a subnet not banned should fail")
+	}
+	if err := rcm.SetBan(&btcjson.SetBanCmd{SubNet: "10.0.0.300", Command: "add"}); err == nil {
+		t.Errorf("invalid IP should be refused")
+	}
+}
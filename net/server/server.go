@@ -29,6 +29,7 @@ import (
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lblock"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lindex"
 	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
@@ -87,6 +88,10 @@ var (
 	userAgentVersion = fmt.Sprintf("%d.%d.%d", conf.AppMajor, conf.AppMinor, conf.AppPatch)
 )
 
+// errNetworkDisabled is returned for the connections the connection manager
+// attempts while networking is turned off by the setnetworkactive RPC.
+var errNetworkDisabled = errors.New("network is disabled")
+
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
 var zeroHash util.Hash
 
@@ -200,6 +205,9 @@ type peerState struct {
 	bannedAddr      map[string]*BannedInfo
 	bannedIPNet     map[string]*BannedInfo
 	outboundGroups  map[string]int
+	// netGroupKey salts the network groups compared when protecting
+	// inbound peers from eviction.
+	netGroupKey uint64
 }
 
 type banScoreMsg struct {
@@ -210,11 +218,12 @@ type banScoreMsg struct {
 }
 
 type banAddressMsg struct {
-	address      string
-	startTime    int64
-	endTime      int64
-	reason       int
-	hasBannedChn chan bool
+	address   string
+	startTime int64
+	endTime   int64
+	reason    int
+	// resultChn receives whether the address got banned, or unbanned.
+	resultChn chan bool
 }
 
 type getBannedInfoMsg struct {
@@ -259,6 +268,7 @@ type Server struct {
 	started              int32
 	shutdown             int32
 	shutdownSched        int32
+	networkDisabled      int32 // Set while P2P networking is turned off.
 	startupTime          int64
 	chainParams          *model.BitcoinParams
 	addrManager          *addrmgr.AddrManager
@@ -282,12 +292,15 @@ type Server struct {
 	wg                   sync.WaitGroup
 	quit                 chan struct{}
 	nat                  upnp.NAT
+	onionTarget          string
 	timeSource           *util.MedianTime
 	services             wire.ServiceFlag
 	connectPeerChn       chan *serverPeer
 	banScoreChn          chan *banScoreMsg
 	connectedPeers       map[string]*serverPeer
 	banPeerFile          string
+	uploadTarget         *uploadTarget
+	addedNodes           *addedNodes
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
@@ -415,7 +428,7 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 		return
 	}
 	if sp.IsWhitelisted() {
-		log.Debug("Misbehaving whitelisted peer %s: %s", sp, reason)
+		log.Warn("Misbehaving whitelisted peer %s: %s -- not punishing", sp, reason)
 		return
 	}
 
@@ -489,6 +502,18 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 // After then, add the peer to syncManager.
 func (sp *serverPeer) OnVerAck(p *peer.Peer, msg *wire.MsgVerAck) {
 	sp.server.syncManager.NewPeer(p)
+	sp.pushFeeFilter()
+}
+
+// pushFeeFilter sends the min relay fee to the peer in a feefilter message,
+// so that it does not announce transactions the mempool would refuse. Peers
+// not relaying transactions to us are skipped.
+func (sp *serverPeer) pushFeeFilter() {
+	if !wire.CanSendFeeFilter(sp.ProtocolVersion()) || conf.Cfg.P2PNet.BlocksOnly {
+		return
+	}
+	minFee := mempool.GetInstance().MinRelayTxFee()
+	sp.QueueMessage(wire.NewMsgFeeFilter(int64(minFee.GetFeePerK())), nil)
 }
 
 // OnMemPool is invoked when a peer receives a mempool bitcoin message.
@@ -496,6 +521,12 @@ func (sp *serverPeer) OnVerAck(p *peer.Peer, msg *wire.MsgVerAck) {
 // pool up to the maximum inventory allowed per message.  When the peer has a
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
+	// We do not relay transactions in blocksonly mode.
+	if conf.Cfg.P2PNet.BlocksOnly {
+		log.Trace("Ignoring mempool request from %v -- blocksonly enabled", sp)
+		return
+	}
+
 	// Only allow mempool requests if the server has bloom filtering
 	// enabled.
 	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom && !sp.IsWhitelisted() {
@@ -543,9 +574,12 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx, done chan<- struct{}) {
 	txn := (*tx.Tx)(msg)
-	if conf.Cfg.P2PNet.BlocksOnly {
-		//TODO: relay tx for whitelistrelay node even BlocksOnly mode
+	// Whitelisted peers may still send us transactions in blocksonly mode
+	// when we relay them regardless.
+	if conf.Cfg.P2PNet.BlocksOnly && !(sp.IsWhitelisted() && conf.Cfg.P2PNet.WhitelistForceRelay) {
 		log.Trace("Ignoring tx %v from %v - blocksonly enabled", txn.GetHash(), sp)
+		// The peer does not read its next message until the tx is done.
+		done <- struct{}{}
 		return
 	}
 
@@ -881,6 +915,184 @@ func (sp *serverPeer) OnFeeFilter(_ *peer.Peer, msg *wire.MsgFeeFilter) {
 	atomic.StoreInt64(&sp.feeFilter, msg.MinFee)
 }
 
+// blockFilterLookup reads the filters served to peers, it is satisfied by
+// lindex.BlockFilterIndex.
+type blockFilterLookup interface {
+	LookupFilter(hash *util.Hash) ([]byte, *util.Hash, error)
+}
+
+// blockFilterStop checks a BIP157 request and returns the active chain block
+// of its stop hash, or nil if the request can not be served. Peers sending
+// requests this node does not support are disconnected.
+func (sp *serverPeer) blockFilterStop(cmd string, filterType wire.FilterType,
+	stopHash *util.Hash) *blockindex.BlockIndex {
+
+	filterIndex := lindex.GetBlockFilterIndex()
+	if sp.server.services&wire.SFNodeCompactFilters != wire.SFNodeCompactFilters ||
+		filterIndex == nil || filterType != wire.GCSFilterRegular {
+		log.Debug("%s sent an unsupported %s request -- disconnecting", sp, cmd)
+		sp.Disconnect()
+		return nil
+	}
+	if !filterIndex.IsSynced() {
+		log.Debug("Ignore %s request from %s, the block filter index is not synced", cmd, sp)
+		return nil
+	}
+
+	gChain := chain.GetInstance()
+	stop := gChain.FindBlockIndex(*stopHash)
+	if stop == nil || !gChain.Contains(stop) {
+		log.Debug("%s sent a %s request with unknown stop hash %s -- disconnecting",
+			sp, cmd, stopHash)
+		sp.Disconnect()
+		return nil
+	}
+	return stop
+}
+
+// blockFilterRange returns the active chain blocks from startHeight up to the
+// stop hash of a BIP157 request, or nil if the request can not be served.
+func (sp *serverPeer) blockFilterRange(cmd string, filterType wire.FilterType,
+	startHeight uint32, stopHash *util.Hash, maxBlocks uint32) []*blockindex.BlockIndex {
+
+	stop := sp.blockFilterStop(cmd, filterType, stopHash)
+	if stop == nil {
+		return nil
+	}
+	indexes := filterRange(stop, startHeight, maxBlocks)
+	if indexes == nil {
+		log.Debug("%s sent a %s request with invalid range %d-%d -- disconnecting",
+			sp, cmd, startHeight, stop.Height)
+		sp.Disconnect()
+	}
+	return indexes
+}
+
+// filterRange returns the ancestors of stop from startHeight up to stop, or
+// nil if the range is empty or holds more than maxBlocks blocks.
+func filterRange(stop *blockindex.BlockIndex, startHeight uint32, maxBlocks uint32) []*blockindex.BlockIndex {
+	if startHeight > uint32(stop.Height) || uint32(stop.Height)-startHeight >= maxBlocks {
+		return nil
+	}
+
+	indexes := make([]*blockindex.BlockIndex, 0, uint32(stop.Height)-startHeight+1)
+	for height := int32(startHeight); height <= stop.Height; height++ {
+		indexes = append(indexes, stop.GetAncestor(height))
+	}
+	return indexes
+}
+
+// lookupFilter returns the serialized filter and the filter header of the
+// block.
+func lookupFilter(filters blockFilterLookup, index *blockindex.BlockIndex) ([]byte, *util.Hash, error) {
+	filter, header, err := filters.LookupFilter(index.GetBlockHash())
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, fmt.Errorf("block filter of %s not found", index.GetBlockHash())
+	}
+	return filter, header, nil
+}
+
+// cfHeadersMsg builds the cfheaders reply to a getcfheaders request for the
+// blocks of indexes.
+func cfHeadersMsg(filters blockFilterLookup, msg *wire.MsgGetCFHeaders,
+	indexes []*blockindex.BlockIndex) (*wire.MsgCFHeaders, error) {
+
+	headersMsg := wire.NewMsgCFHeaders()
+	headersMsg.FilterType = msg.FilterType
+	headersMsg.StopHash = msg.StopHash
+	if prev := indexes[0].Prev; prev != nil {
+		_, prevHeader, err := lookupFilter(filters, prev)
+		if err != nil {
+			return nil, err
+		}
+		headersMsg.PrevFilterHeader = *prevHeader
+	}
+	for _, index := range indexes {
+		filter, _, err := lookupFilter(filters, index)
+		if err != nil {
+			return nil, err
+		}
+		filterHash := util.DoubleSha256Hash(filter)
+		if err := headersMsg.AddCFHash(&filterHash); err != nil {
+			return nil, err
+		}
+	}
+	return headersMsg, nil
+}
+
+// cfCheckptMsg builds the cfcheckpt reply to a getcfcheckpt request, with
+// the filter headers of every wire.CFCheckptInterval-th block up to stop.
+func cfCheckptMsg(filters blockFilterLookup, msg *wire.MsgGetCFCheckpt,
+	stop *blockindex.BlockIndex) (*wire.MsgCFCheckpt, error) {
+
+	checkptMsg := wire.NewMsgCFCheckpt()
+	checkptMsg.FilterType = msg.FilterType
+	checkptMsg.StopHash = msg.StopHash
+	for height := int32(wire.CFCheckptInterval); height <= stop.Height; height += wire.CFCheckptInterval {
+		_, header, err := lookupFilter(filters, stop.GetAncestor(height))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkptMsg.AddCFHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	return checkptMsg, nil
+}
+
+// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin message
+// and sends a cfilter message for each block of the requested range.
+func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
+	indexes := sp.blockFilterRange(msg.Command(), msg.FilterType, msg.StartHeight,
+		&msg.StopHash, wire.MaxGetCFiltersReqRange)
+	for _, index := range indexes {
+		filter, _, err := lookupFilter(lindex.GetBlockFilterIndex(), index)
+		if err != nil {
+			log.Error("Serve %s to %s failed: %v", msg.Command(), sp, err)
+			return
+		}
+		sp.QueueMessage(wire.NewMsgCFilter(msg.FilterType, index.GetBlockHash(), filter), nil)
+	}
+}
+
+// OnGetCFHeaders is invoked when a peer receives a getcfheaders bitcoin
+// message and sends the filter hashes of the requested range, preceded by the
+// filter header of the block before it.
+func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
+	indexes := sp.blockFilterRange(msg.Command(), msg.FilterType, msg.StartHeight,
+		&msg.StopHash, wire.MaxCFHeadersPerMsg)
+	if len(indexes) == 0 {
+		return
+	}
+
+	headersMsg, err := cfHeadersMsg(lindex.GetBlockFilterIndex(), msg, indexes)
+	if err != nil {
+		log.Error("Serve %s to %s failed: %v", msg.Command(), sp, err)
+		return
+	}
+	sp.QueueMessage(headersMsg, nil)
+}
+
+// OnGetCFCheckpt is invoked when a peer receives a getcfcheckpt bitcoin
+// message and sends the filter headers of every wire.CFCheckptInterval-th
+// block up to the stop hash.
+func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
+	stop := sp.blockFilterStop(msg.Command(), msg.FilterType, &msg.StopHash)
+	if stop == nil {
+		return
+	}
+
+	checkptMsg, err := cfCheckptMsg(lindex.GetBlockFilterIndex(), msg, stop)
+	if err != nil {
+		log.Error("Serve %s to %s failed: %v", msg.Command(), sp, err)
+		return
+	}
+	sp.QueueMessage(checkptMsg, nil)
+}
+
 func (sp *serverPeer) OnReject(p *peer.Peer, msg *wire.MsgReject) {
 	log.Error("reject: %+v, from: %+v", msg, p)
 }
@@ -960,7 +1172,7 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 	// Do not accept getaddr requests from outbound peers.  This reduces
 	// fingerprinting attacks.
 	if !sp.Inbound() {
-		log.Debug("Ignoring getaddr request from outbound peer ",
+		log.Print("net", "debug", "Ignoring getaddr request from outbound peer ",
 			"%v", sp)
 		return
 	}
@@ -968,7 +1180,7 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 	// Only allow one getaddr request per connection to discourage
 	// address stamping of inv announcements.
 	if sp.sentAddrs {
-		log.Debug("Ignoring repeated getaddr request from peer ",
+		log.Print("net", "debug", "Ignoring repeated getaddr request from peer ",
 			"%v", sp)
 		return
 	}
@@ -1032,15 +1244,22 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
-// the bytes received by the server.
+// the bytes received by the server.  A message whose header can't be trusted
+// also increases the ban score of the peer, which is disconnected by the read
+// error regardless.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+
+	if msgErr, ok := err.(*wire.MessageError); ok && msgErr.BadHeader {
+		sp.addBanScore(20, 0, msgErr.Description)
+	}
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
-// the bytes sent by the server.
+// the bytes sent by the server and to the peer.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	sp.server.uploadTarget.AddBytesSent(sp.ID(), uint64(bytesWritten))
 }
 
 // randomUint16Number returns a random uint16 in a specified input range.  Note
@@ -1152,6 +1371,17 @@ func (s *Server) pushBlockMsg(sp *serverPeer, hash *util.Hash, doneChan chan<- s
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
 	blkIndex, send := findBlockIndex(hash)
+	if send && !s.uploadTarget.ShouldServeBlock(int64(blkIndex.GetBlockTime()),
+		int64(chain.GetInstance().GetIndexBestHeader().GetBlockTime()), sp.IsWhitelisted()) {
+		// Don't keep asking the peer for the rest of the historical blocks
+		// it is downloading, it will find them elsewhere.
+		log.Info("historical block serving limit reached, disconnect peer %s", sp)
+		sp.Disconnect()
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errors.New("max upload target reached")
+	}
 	if send && blkIndex.HasData() {
 		// Fetch the raw block bytes from the database.
 		bl, err := lblock.GetBlockByIndex(blkIndex, s.chainParams)
@@ -1337,7 +1567,7 @@ func (s *Server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	}
 	if banEnd, ok := state.bannedAddr[host]; ok {
 		if now < banEnd.BanUntil {
-			log.Debug("Peer %s is banned for another %v - disconnecting",
+			log.Print("net", "debug", "Peer %s is banned for another %v - disconnecting",
 				host, banEnd.BanUntil-now)
 			sp.Disconnect()
 			return false
@@ -1354,7 +1584,7 @@ func (s *Server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 				continue
 			}
 			if now < banEnd.BanUntil {
-				log.Debug("IP Net %s that contains host %s is banned for another %v - disconnecting",
+				log.Print("net", "debug", "IP Net %s that contains host %s is banned for another %v - disconnecting",
 					ipNet.String(), host, banEnd.BanUntil-now)
 				sp.Disconnect()
 				return false
@@ -1368,8 +1598,10 @@ func (s *Server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= conf.Cfg.P2PNet.MaxPeers {
+	// Limit max number of total peers. A new inbound peer may still take the
+	// place of an inbound peer that is not protected from eviction.
+	if state.Count() >= conf.Cfg.P2PNet.MaxPeers &&
+		(!sp.Inbound() || !state.evictInboundPeer()) {
 		log.Info("Max peers reached [%d] - disconnecting peer %s",
 			conf.Cfg.P2PNet.MaxPeers, sp)
 		sp.Disconnect()
@@ -1379,7 +1611,7 @@ func (s *Server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	}
 
 	// Add the new peer and start it.
-	log.Debug("Add new peer %s", sp)
+	log.Print("net", "debug", "Add new peer %s", sp)
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
@@ -1397,6 +1629,8 @@ func (s *Server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *Server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
+	s.uploadTarget.RemovePeer(sp.ID())
+
 	var list map[int32]*serverPeer
 	if sp.persistent {
 		list = state.persistentPeers
@@ -1413,7 +1647,7 @@ func (s *Server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 			s.connManager.Disconnect(sp.connReq.ID())
 		}
 		delete(list, sp.ID())
-		log.Debug("Removed peer %s", sp)
+		log.Print("net", "debug", "Removed peer %s", sp)
 		return
 	}
 
@@ -1442,11 +1676,11 @@ func (s *Server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		return
 	}
 	log.Info("Banned peer %s (inBound:%v) for %v", host, sp.Inbound(),
-		conf.Cfg.P2PNet.BanDuration)
+		conf.Cfg.BanDuration())
 	now := util.GetTimeSec()
 	state.bannedAddr[host] = &BannedInfo{
 		Address:    host,
-		BanUntil:   now + conf.Cfg.P2PNet.BanDuration,
+		BanUntil:   now + conf.Cfg.BanDuration(),
 		CreateTime: now,
 		Reason:     BanReasonNodeMisbehaving,
 	}
@@ -1456,77 +1690,65 @@ func (s *Server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 	s.saveBannedInfo(state)
 }
 
+// handleBanAddressMsg bans the address, a single IP or a CIDR subnet, and
+// disconnects the peers it covers. Banning a banned address again updates
+// the end of its ban. An IP covered by a banned subnet is not banned again,
+// which is replied false.
 func (s *Server) handleBanAddressMsg(state *peerState, bmsg *banAddressMsg) {
+	var covers func(ip net.IP) bool
+	bannedList := state.bannedAddr
 	if strings.Contains(bmsg.address, "/") {
-		_, ok := state.bannedIPNet[bmsg.address]
-		if ok {
-			bmsg.hasBannedChn <- true
-			return
-		}
-		bmsg.hasBannedChn <- false
-
 		_, bannedNet, err := net.ParseCIDR(bmsg.address)
 		if err != nil {
 			log.Debug("can't parse ban ip net %s. error:%s", bmsg.address, err.Error())
+			bmsg.resultChn <- false
 			return
 		}
-		log.Info("Ban ip net %s until %d", bmsg.address, bmsg.endTime)
-		state.bannedIPNet[bmsg.address] = &BannedInfo{
-			Address:    bmsg.address,
-			BanUntil:   bmsg.endTime,
-			CreateTime: bmsg.startTime,
-			Reason:     bmsg.reason,
-		}
-
-		state.forAllPeers(func(sp *serverPeer) {
-			ip := net.ParseIP(sp.Addr())
-			if ip != nil && bannedNet.Contains(ip) {
-				log.Info("Ban peer %s (is inbound:%v) until %d", sp.Addr(), sp.Inbound(), bmsg.endTime)
-				sp.Disconnect()
-				delete(s.connectedPeers, sp.Addr())
-			}
-		})
-
+		covers = bannedNet.Contains
+		bannedList = state.bannedIPNet
 	} else {
-		_, ok := state.bannedAddr[bmsg.address]
-		if ok {
-			bmsg.hasBannedChn <- true
-			return
-		}
-		ip := net.ParseIP(bmsg.address)
-		if ip == nil {
+		bannedIP := net.ParseIP(bmsg.address)
+		if bannedIP == nil {
 			log.Error("Ban address %s is invalid", bmsg.address)
+			bmsg.resultChn <- false
 			return
 		}
-		for bannedCIDR := range state.bannedIPNet {
+		for bannedCIDR, info := range state.bannedIPNet {
 			_, bannedNet, err := net.ParseCIDR(bannedCIDR)
-			if err != nil {
-				continue
-			}
-			if bannedNet.Contains(ip) {
-				bmsg.hasBannedChn <- true
+			if err == nil && bannedNet.Contains(bannedIP) && info.BanUntil > bmsg.endTime {
+				bmsg.resultChn <- false
 				return
 			}
 		}
-		bmsg.hasBannedChn <- false
+		covers = bannedIP.Equal
+	}
+	bmsg.resultChn <- true
 
-		log.Info("Ban peer %s until %d", bmsg.address, bmsg.endTime)
-		state.bannedAddr[bmsg.address] = &BannedInfo{
+	if info, ok := bannedList[bmsg.address]; ok {
+		log.Info("Ban of %s updated until %d", bmsg.address, bmsg.endTime)
+		info.BanUntil = bmsg.endTime
+		info.Reason = bmsg.reason
+	} else {
+		log.Info("Ban %s until %d", bmsg.address, bmsg.endTime)
+		bannedList[bmsg.address] = &BannedInfo{
 			Address:    bmsg.address,
 			BanUntil:   bmsg.endTime,
 			CreateTime: bmsg.startTime,
 			Reason:     bmsg.reason,
 		}
-
-		state.forAllPeers(func(sp *serverPeer) {
-			host, _, err := net.SplitHostPort(sp.Addr())
-			if err == nil && host == bmsg.address {
-				log.Info("Ban peer %s (is inbound:%v) until %d", sp.Addr(), sp.Inbound(), bmsg.endTime)
-				sp.Disconnect()
-				delete(s.connectedPeers, sp.Addr())
-			}
-		})
 	}
+
+	state.forAllPeers(func(sp *serverPeer) {
+		host, _, err := net.SplitHostPort(sp.Addr())
+		if err != nil {
+			return
+		}
+		if ip := net.ParseIP(host); ip != nil && covers(ip) {
+			log.Info("Ban peer %s (is inbound:%v) until %d", sp.Addr(), sp.Inbound(), bmsg.endTime)
+			sp.Disconnect()
+			delete(s.connectedPeers, sp.Addr())
+		}
+	})
 	s.saveBannedInfo(state)
 }
 
@@ -1534,10 +1756,10 @@ func (s *Server) handleUnbanAddressMsg(state *peerState, bmsg *banAddressMsg) {
 	if strings.Contains(bmsg.address, "/") {
 		_, ok := state.bannedIPNet[bmsg.address]
 		if !ok {
-			bmsg.hasBannedChn <- false
+			bmsg.resultChn <- false
 			return
 		}
-		bmsg.hasBannedChn <- true
+		bmsg.resultChn <- true
 
 		log.Info("Unban ip net %s", bmsg.address)
 		delete(state.bannedIPNet, bmsg.address)
@@ -1545,10 +1767,10 @@ func (s *Server) handleUnbanAddressMsg(state *peerState, bmsg *banAddressMsg) {
 	} else {
 		_, ok := state.bannedAddr[bmsg.address]
 		if !ok {
-			bmsg.hasBannedChn <- false
+			bmsg.resultChn <- false
 			return
 		}
-		bmsg.hasBannedChn <- true
+		bmsg.resultChn <- true
 
 		log.Info("Unban peer %s", bmsg.address)
 		delete(state.bannedAddr, bmsg.address)
@@ -1695,7 +1917,7 @@ func (s *Server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			// Don't relay the transaction if the transaction fee-per-kb
 			// is less than the peer's feefilter.
 			feeFilter := atomic.LoadInt64(&sp.feeFilter)
-			feePerKB := util.NewFeeRateWithSize(txD.TxFee, int64(txD.TxSize))
+			feePerKB := util.NewFeeRate(amount.Amount(txD.TxFee), txD.TxSize)
 			if feeFilter > 0 && feePerKB.SataoshisPerK < feeFilter {
 				return
 			}
@@ -1756,22 +1978,68 @@ type getAddedNodesMsg struct {
 	reply chan []*serverPeer
 }
 
+type pingPeersMsg struct{}
+
+type sendFeeFilterMsg struct{}
+
+type getAddedNodeInfoMsg struct {
+	reply chan []addedNodeInfo
+}
+
+// addedNodeInfo is the connection status of an added node. The peer address
+// is empty while the node is not connected.
+type addedNodeInfo struct {
+	addr     string
+	peerAddr string
+}
+
 type disconnectNodeMsg struct {
 	cmp   func(*serverPeer) bool
 	reply chan error
 }
 
+type setNetworkActiveMsg struct {
+	active bool
+	reply  chan struct{}
+}
+
 type connectNodeMsg struct {
 	addr      string
 	permanent bool
 	reply     chan error
 }
 
+// addedNodeReconnectDelay is the time an added node disconnected through the
+// disconnectnode RPC is left alone before being reconnected.
+const addedNodeReconnectDelay = time.Minute * 2
+
 type removeNodeMsg struct {
+	addr  string
 	cmp   func(*serverPeer) bool
 	reply chan error
 }
 
+// connectNode makes a connection request to the address. A permanent request
+// is recorded as an added node and retried whenever the connection is lost.
+func (s *Server) connectNode(addr string, permanent bool) error {
+	netAddr, err := addrStringToNetAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	connReq := &connmgr.ConnReq{
+		Addr:      netAddr,
+		Permanent: permanent,
+	}
+	if permanent {
+		if err := s.addedNodes.Add(addr, connReq); err != nil {
+			return err
+		}
+	}
+	go s.connManager.Connect(context.TODO(), connReq)
+	return nil
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *Server) handleQuery(state *peerState, querymsg interface{}) {
@@ -1796,18 +2064,19 @@ func (s *Server) handleQuery(state *peerState, querymsg interface{}) {
 		msg.reply <- peers
 
 	case connectNodeMsg:
+		// Added nodes are kept in the list whatever the number of peers,
+		// they get connected once a slot is free.
+		if msg.permanent {
+			msg.reply <- s.connectNode(msg.addr, true)
+			return
+		}
+
 		// TODO: duplicate oneshots?
 		// Limit max number of total peers.
 		if state.Count() >= conf.Cfg.P2PNet.MaxPeers {
 			msg.reply <- errors.New("max peers reached")
 			return
 		}
-		for _, peer := range state.persistentPeers {
-			if peer.Addr() == msg.addr {
-				msg.reply <- errors.New("node already added")
-				return
-			}
-		}
 		// It is possible that we already have a connection to the IP/port
 		// pszDest resolved to. In that case, drop the connection that was
 		// just created, and return the existing CNode instead.
@@ -1818,29 +2087,32 @@ func (s *Server) handleQuery(state *peerState, querymsg interface{}) {
 			}
 		}
 
-		netAddr, err := addrStringToNetAddr(msg.addr)
-		if err != nil {
-			msg.reply <- err
-			return
-		}
-
 		// TODO: if too many, nuke a non-perm peer. fix yongxin
-		go s.connManager.Connect(context.TODO(), &connmgr.ConnReq{
-			Addr:      netAddr,
-			Permanent: msg.permanent,
-		})
-		msg.reply <- nil
+		msg.reply <- s.connectNode(msg.addr, false)
 	case removeNodeMsg:
+		// Cancel the connection request before disconnecting the peer,
+		// so that the connection manager does not retry it.
+		removed := false
+		if msg.addr != "" {
+			if connReq, err := s.addedNodes.Remove(msg.addr); err == nil {
+				connReq.Cancel()
+				removed = true
+			}
+		}
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
+			if sp.connReq != nil {
+				s.addedNodes.RemoveConnReq(sp.connReq)
+				sp.connReq.Cancel()
+			}
 			// Keep group counts ok since we remove from
 			// the list now.
 			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
 		})
 
-		if found {
+		if found || removed {
 			msg.reply <- nil
 		} else {
-			msg.reply <- errors.New("peer not found")
+			msg.reply <- errNodeNotAdded
 		}
 	case getOutboundGroup:
 		count, ok := state.outboundGroups[msg.key]
@@ -1857,6 +2129,53 @@ func (s *Server) handleQuery(state *peerState, querymsg interface{}) {
 			peers = append(peers, sp)
 		}
 		msg.reply <- peers
+	case pingPeersMsg:
+		peers := make([]messageQueuer, 0, state.Count())
+		state.forAllPeers(func(sp *serverPeer) {
+			if sp.Connected() {
+				peers = append(peers, sp)
+			}
+		})
+		queuePings(peers)
+	case sendFeeFilterMsg:
+		state.forAllPeers(func(sp *serverPeer) {
+			if sp.Connected() {
+				sp.pushFeeFilter()
+			}
+		})
+	case getAddedNodeInfoMsg:
+		connected := make(map[*connmgr.ConnReq]*serverPeer)
+		for _, sp := range state.persistentPeers {
+			if sp.connReq != nil && sp.Connected() {
+				connected[sp.connReq] = sp
+			}
+		}
+
+		nodes := s.addedNodes.List()
+		infos := make([]addedNodeInfo, 0, len(nodes))
+		for _, node := range nodes {
+			info := addedNodeInfo{addr: node.addr}
+			if sp, ok := connected[node.connReq]; ok {
+				info.peerAddr = sp.Addr()
+			}
+			infos = append(infos, info)
+		}
+		msg.reply <- infos
+	case setNetworkActiveMsg:
+		if !msg.active {
+			all := func(*serverPeer) bool { return true }
+			for disconnectPeer(state.inboundPeers, all, nil) {
+			}
+			outboundDone := func(sp *serverPeer) {
+				state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			}
+			for disconnectPeer(state.outboundPeers, all, outboundDone) {
+			}
+			for disconnectPeer(state.persistentPeers, all, outboundDone) {
+			}
+		}
+		msg.reply <- struct{}{}
+
 	case disconnectNodeMsg:
 		// Check inbound peers. We pass a nil callback since we don't
 		// require any additional actions on disconnect for inbound peers.
@@ -1885,6 +2204,20 @@ func (s *Server) handleQuery(state *peerState, querymsg interface{}) {
 			return
 		}
 
+		// Check added nodes. They stay in the added list, but their
+		// connection is not retried before a grace period, otherwise
+		// they would be reconnected right away.
+		found = disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
+			if sp.connReq != nil {
+				sp.connReq.PostponeRetry(addedNodeReconnectDelay)
+			}
+			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+		})
+		if found {
+			msg.reply <- nil
+			return
+		}
+
 		msg.reply <- errors.New("peer not found")
 	}
 }
@@ -1927,18 +2260,21 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 func newPeerConfig(sp *serverPeer) *peer.Config {
 	return &peer.Config{
 		Listeners: peer.MessageListeners{
-			OnVersion:    sp.OnVersion,
-			OnVerAck:     sp.OnVerAck,
-			OnMemPool:    sp.OnMemPool,
-			OnTx:         sp.OnTx,
-			OnBlock:      sp.OnBlock,
-			OnInv:        sp.OnInv,
-			OnHeaders:    sp.OnHeaders,
-			OnGetData:    sp.OnGetData,
-			OnGetBlocks:  sp.OnGetBlocks,
-			OnGetHeaders: sp.OnGetHeaders,
-			OnFeeFilter:  sp.OnFeeFilter,
-			OnReject:     sp.OnReject,
+			OnVersion:      sp.OnVersion,
+			OnVerAck:       sp.OnVerAck,
+			OnMemPool:      sp.OnMemPool,
+			OnTx:           sp.OnTx,
+			OnBlock:        sp.OnBlock,
+			OnInv:          sp.OnInv,
+			OnHeaders:      sp.OnHeaders,
+			OnGetData:      sp.OnGetData,
+			OnGetBlocks:    sp.OnGetBlocks,
+			OnGetHeaders:   sp.OnGetHeaders,
+			OnFeeFilter:    sp.OnFeeFilter,
+			OnGetCFilters:  sp.OnGetCFilters,
+			OnGetCFHeaders: sp.OnGetCFHeaders,
+			OnGetCFCheckpt: sp.OnGetCFCheckpt,
+			OnReject:       sp.OnReject,
 			//OnFilterAdd:   sp.OnFilterAdd,
 			//OnFilterClear: sp.OnFilterClear,
 			//OnFilterLoad:  sp.OnFilterLoad,
@@ -1964,6 +2300,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		Services:          sp.server.services,
 		DisableRelayTx:    conf.Cfg.P2PNet.BlocksOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
+		PeerTimeout:       time.Duration(conf.Cfg.P2PNet.PeerTimeout) * time.Second,
 	}
 }
 
@@ -1972,8 +2309,15 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *Server) inboundPeerConnected(conn net.Conn) {
+	if !s.NetworkActive() {
+		log.Debug("Refusing inbound connection from %s: network is disabled",
+			conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
 	sp := newServerPeer(s, false)
-	isWhitelisted := isWhitelisted(conn.RemoteAddr())
+	isWhitelisted := isWhitelisted(conn.RemoteAddr()) || isWhiteBound(conn.LocalAddr())
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp), isWhitelisted)
 	sp.AssociateConnection(conn, s.MsgChan, func(peer *peer.Peer) {
 	})
@@ -2001,7 +2345,7 @@ func (s *Server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	isWhitelisted := isWhitelisted(conn.RemoteAddr())
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String(), isWhitelisted)
 	if err != nil {
-		log.Debug("Cannot create outbound peer %s: %v", c.Addr, err)
+		log.Print("net", "debug", "Cannot create outbound peer %s: %v", c.Addr, err)
 		s.connManager.Disconnect(c.ID())
 	}
 	sp.Peer = p
@@ -2046,7 +2390,7 @@ func (s *Server) peerDoneHandler(sp *serverPeer) {
 		pool := mempool.GetInstance()
 		numEvicted := pool.RemoveOrphansByTag(int64(sp.ID()))
 		if numEvicted > 0 {
-			log.Debug("Evicted %d from peer %v (id %d)", numEvicted, sp, sp.ID())
+			log.Print("net", "debug", "Evicted %d from peer %v (id %d)", numEvicted, sp, sp.ID())
 		}
 	}
 
@@ -2073,11 +2417,15 @@ func (s *Server) cycle() {
 		bannedIPNet:     make(map[string]*BannedInfo),
 		outboundGroups:  make(map[string]int),
 	}
+	var key [8]byte
+	if _, err := rand.Read(key[:]); err == nil {
+		state.netGroupKey = binary.LittleEndian.Uint64(key[:])
+	}
 
 	if !conf.Cfg.P2PNet.DisableDNSSeed {
 		// Add peers discovered through DNS to the address manager.
 		connmgr.SeedFromDNS(s.chainParams, defaultRequiredServices,
-			net.LookupIP, func(addrs []*wire.NetAddress) {
+			lookupIP, func(addrs []*wire.NetAddress) {
 				// Bitcoind uses a lookup of the dns seeder here. This
 				// is rather strange since the values looked up by the
 				// DNS seed lookups will vary quite a lot.
@@ -2189,27 +2537,32 @@ func (s *Server) BanPeer(sp *serverPeer) {
 	s.banPeers <- sp
 }
 
+// BanAddr bans the IP or CIDR subnet addr from startTime until endTime, or
+// updates the end of its ban. It returns false if addr is invalid or an IP
+// already banned longer by a subnet.
 func (s *Server) BanAddr(addr string, startTime int64, endTime int64, reason int) bool {
-	hasBannedChn := make(chan bool)
+	resultChn := make(chan bool)
 	bmsg := &banAddressMsg{
-		address:      addr,
-		startTime:    startTime,
-		endTime:      endTime,
-		reason:       reason,
-		hasBannedChn: hasBannedChn,
+		address:   addr,
+		startTime: startTime,
+		endTime:   endTime,
+		reason:    reason,
+		resultChn: resultChn,
 	}
 	s.banAddress <- bmsg
-	return <-hasBannedChn
+	return <-resultChn
 }
 
+// UnbanAddr lifts the ban of the IP or CIDR subnet addr. It returns false if
+// addr was not banned.
 func (s *Server) UnbanAddr(addr string) bool {
-	hasBannedChn := make(chan bool)
+	resultChn := make(chan bool)
 	bmsg := &banAddressMsg{
-		address:      addr,
-		hasBannedChn: hasBannedChn,
+		address:   addr,
+		resultChn: resultChn,
 	}
 	s.unbanAddress <- bmsg
-	return <-hasBannedChn
+	return <-resultChn
 }
 
 func (s *Server) GetBannedInfo() []*BannedInfo {
@@ -2278,6 +2631,36 @@ func (s *Server) BroadcastMessage(msg wire.Message, exclPeers ...*serverPeer) {
 	s.broadcast <- bmsg
 }
 
+// PingPeers queues a ping to all connected peers, so that their ping time is
+// measured again. It returns without waiting for the pongs.
+func (s *Server) PingPeers() {
+	s.query <- pingPeersMsg{}
+}
+
+// SendFeeFilter sends the min relay fee to all connected peers again, after
+// it changed.
+func (s *Server) SendFeeFilter() {
+	s.query <- sendFeeFilterMsg{}
+}
+
+// messageQueuer queues messages to be sent to a peer.
+type messageQueuer interface {
+	QueueMessage(msg wire.Message, doneChan chan<- struct{})
+}
+
+// queuePings queues a ping with a fresh nonce to each of the peers. The round
+// trip time is recorded by the peer once the matching pong is received.
+func queuePings(peers []messageQueuer) {
+	for _, p := range peers {
+		nonce, err := util.RandomUint64()
+		if err != nil {
+			log.Error("Not sending ping to %v: %v", p, err)
+			continue
+		}
+		p.QueueMessage(wire.NewMsgPing(nonce), nil)
+	}
+}
+
 // ConnectedCount returns the number of currently connected peers.
 func (s *Server) ConnectedCount() int32 {
 	replyChan := make(chan int32)
@@ -2287,6 +2670,28 @@ func (s *Server) ConnectedCount() int32 {
 	return <-replyChan
 }
 
+// SetNetworkActive turns all P2P networking on or off. While it is off every
+// peer is disconnected, inbound connections are refused and the connection
+// manager dials nothing, but the RPC server and block validation keep running.
+// Turning it back on lets the connection manager dial again on its next retry.
+func (s *Server) SetNetworkActive(active bool) {
+	if active {
+		atomic.StoreInt32(&s.networkDisabled, 0)
+	} else {
+		atomic.StoreInt32(&s.networkDisabled, 1)
+	}
+	log.Info("SetNetworkActive: %v", active)
+
+	replyChan := make(chan struct{})
+	s.query <- setNetworkActiveMsg{active: active, reply: replyChan}
+	<-replyChan
+}
+
+// NetworkActive returns whether P2P networking is turned on.
+func (s *Server) NetworkActive() bool {
+	return atomic.LoadInt32(&s.networkDisabled) == 0
+}
+
 // OutboundGroupCount returns the number of peers connected to the given
 // outbound group key.
 func (s *Server) OutboundGroupCount(key string) int {
@@ -2407,6 +2812,11 @@ func (s *Server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	if conf.Cfg.P2PNet.TorControl != "" {
+		s.wg.Add(1)
+		go s.torControlThread()
+	}
+
 	if err := s.loadBannedInfo(); err != nil {
 		log.Error("loadBannedInfo error:%s", err.Error())
 	}
@@ -2593,17 +3003,49 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 	if cfg.Protocol.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
 	}
+	// A pruned node can't serve the full chain.
+	if cfg.Chain.Prune {
+		services &^= wire.SFNodeNetwork
+	}
+	if cfg.Protocol.PeerBlockFilters {
+		if cfg.Chain.BlockFilterIndex {
+			services |= wire.SFNodeCompactFilters
+		} else {
+			log.Warn("peerblockfilters requires blockfilterindex, compact block filters are not served")
+		}
+	}
 
-	amgr := addrmgr.New(conf.DataDir, net.LookupIP)
+	amgr := addrmgr.New(conf.DataDir, lookupIP)
 
 	var listeners []net.Listener
 	var nat upnp.NAT
 
-	listeners, nat, err := initListeners(amgr, cfg.P2PNet.ListenAddrs, services)
+	listenAddrs, onionAddrs, err := conf.ResolveBinds(cfg.P2PNet.Binds, cfg.P2PNet.ListenAddrs,
+		cfg.P2PNet.Port, chainParams.DefaultPort)
 	if err != nil {
 		return nil, err
 	}
-	if len(cfg.P2PNet.ListenAddrs) != 0 && len(listeners) == 0 {
+
+	// Peers connecting to a whitebind address are whitelisted, so those
+	// addresses are listened on along with the regular ones.
+	for _, bind := range cfg.P2PNet.WhiteBinds {
+		listenAddrs = append(listenAddrs, bind.String())
+	}
+
+	listeners, nat, err = initListeners(amgr, listenAddrs, services)
+	if err != nil {
+		return nil, err
+	}
+	onionListeners, err := listenOnion(onionAddrs)
+	if err != nil {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return nil, err
+	}
+	target := onionTarget(listeners, onionListeners, chainParams.DefaultPort)
+	listeners = append(listeners, onionListeners...)
+	if len(listenAddrs)+len(onionAddrs) != 0 && len(listeners) == 0 {
 		return nil, errors.New("no valid listen address")
 	}
 
@@ -2630,6 +3072,7 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
 		services:             services,
 		nat:                  nat,
+		onionTarget:          target,
 		timeSource:           ts,
 		MsgChan:              msgChan,
 		connectPeerChn:       make(chan *serverPeer),
@@ -2637,6 +3080,8 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 		connectedPeers:       make(map[string]*serverPeer),
 		banPeerFile:          filepath.Join(conf.DataDir, "banpeers.json"),
 		txRelayer:            NewTxRelayer(),
+		uploadTarget:         newUploadTarget(cfg.P2PNet.MaxUploadTarget),
+		addedNodes:           newAddedNodes(filepath.Join(conf.DataDir, "addednodes.json")),
 	}
 
 	if cfg.P2PNet.TargetOutbound < 0 {
@@ -2658,13 +3103,18 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 		RetryDuration:  connectionRetryInterval,
 		TargetOutbound: int32(cfg.P2PNet.TargetOutbound),
 
-		Dial: func(ctx context.Context, netaddr net.Addr) (net.Conn, error) {
-			var d net.Dialer
-			return d.DialContext(ctx, netaddr.Network(), netaddr.String())
+		Dial: func(ctx context.Context, addr net.Addr) (net.Conn, error) {
+			if !s.NetworkActive() {
+				return nil, errNetworkDisabled
+			}
+			return dialAddr(ctx, addr)
 		},
 		OnAccept:  s.inboundPeerConnected,
 		OnConnect: s.outboundPeerConnected,
 		GetNewAddress: func() (net.Addr, error) {
+			if !s.NetworkActive() {
+				return nil, errNetworkDisabled
+			}
 			addr, err := amgr.NewAddress(func(groupKey string) bool {
 				return s.OutboundGroupCount(groupKey) != 0
 			})
@@ -2689,6 +3139,17 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 	}
 	s.connManager = cmgr
 
+	// Reconnect the nodes added through the addnode RPC by a previous run.
+	addrs, err := s.addedNodes.load()
+	if err != nil {
+		log.Warn("Failed to load added nodes: %v", err)
+	}
+	for _, addr := range addrs {
+		if err := s.connectNode(addr, true); err != nil {
+			log.Warn("Failed to connect added node %s: %v", addr, err)
+		}
+	}
+
 	s.syncManager, err = syncmanager.New(&syncmanager.Config{
 		PeerNotifier: s,
 		ChainParams:  s.chainParams,
@@ -2700,7 +3161,7 @@ func NewServer(chainParams *model.BitcoinParams, ts *util.MedianTime, interrupt
 	}
 	s.syncManager.ProcessBlockCallBack = service.ProcessBlock
 	s.syncManager.ProcessBlockHeadCallBack = service.ProcessBlockHeader
-	s.syncManager.ProcessTransactionCallBack = service.ProcessTransaction
+	s.syncManager.ProcessTransactionCallBack = service.ProcessPeerTransaction
 	s.syncManager.AddBanScoreCallBack = s.AddBanScore
 
 	return s, nil
@@ -2787,6 +3248,30 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 	return listeners, nat, nil
 }
 
+// listenOnion listens on the onion service target addresses. Unlike the
+// addresses of initListeners they are not advertised, as peers reach them
+// through Tor only.
+func listenOnion(onionAddrs []string) ([]net.Listener, error) {
+	netAddrs, err := parseListeners(onionAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			log.Error("Can't listen on %s: %v", addr, err)
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
 // addrStringToNetAddr takes an address in the form of 'host:port' and returns
 // a net.Addr which maps to the original address with any host names resolved
 // to IP addresses.  It also handles tor addresses properly by returning a
@@ -2820,6 +3305,11 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		return &onionAddr{addr: addr}, nil
 	}
 
+	// Leave the name to the proxy, so that it is never resolved locally.
+	if conf.Cfg.P2PNet.Proxy != "" {
+		return simpleAddr{net: "tcp", addr: addr}, nil
+	}
+
 	// Attempt to look up an IP address associated with the parsed host.
 	ips, err := net.LookupIP(host)
 	if err != nil {
@@ -2835,6 +3325,39 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 	}, nil
 }
 
+// dialAddr connects to the address, through the configured SOCKS5 proxy if
+// any. Onion addresses go through the onion proxy when one is set.
+func dialAddr(ctx context.Context, addr net.Addr) (net.Conn, error) {
+	proxyAddr := conf.Cfg.P2PNet.Proxy
+	if addr.Network() == "onion" {
+		if conf.Cfg.P2PNet.OnionProxy != "" {
+			proxyAddr = conf.Cfg.P2PNet.OnionProxy
+		}
+		if proxyAddr == "" {
+			return nil, errors.New("no proxy to reach onion address " + addr.String())
+		}
+	}
+
+	if proxyAddr == "" {
+		var d net.Dialer
+		return d.DialContext(ctx, addr.Network(), addr.String())
+	}
+	proxy := &connmgr.Proxy{
+		Addr:         proxyAddr,
+		TorIsolation: conf.Cfg.P2PNet.ProxyRandomize,
+	}
+	return proxy.DialContext(ctx, "tcp", addr.String())
+}
+
+// lookupIP resolves the host through the proxy when one is configured, so that
+// no DNS request leaks out of it.
+func lookupIP(host string) ([]net.IP, error) {
+	if conf.Cfg.P2PNet.Proxy != "" {
+		return connmgr.TorLookupIP(host, conf.Cfg.P2PNet.Proxy)
+	}
+	return net.LookupIP(host)
+}
+
 // addLocalAddress adds an address that this node is listening on to the
 // address manager so that it may be relayed to peers.
 func addLocalAddress(addrMgr *addrmgr.AddrManager, addr string, services wire.ServiceFlag) error {
@@ -2929,6 +3452,33 @@ func isWhitelisted(addr net.Addr) bool {
 	return false
 }
 
+// isWhiteBound returns whether the local address of an inbound connection is
+// one of the whitebind addresses, whose peers are all whitelisted.
+func isWhiteBound(addr net.Addr) bool {
+	if len(conf.Cfg.P2PNet.WhiteBinds) == 0 || addr == nil {
+		return false
+	}
+
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	port, err := strconv.Atoi(portStr)
+	if ip == nil || err != nil {
+		return false
+	}
+
+	// The whitebind addresses are resolved when the configuration is read,
+	// an unspecified IP is bound to every interface.
+	for _, bind := range conf.Cfg.P2PNet.WhiteBinds {
+		if bind.Port == port && (bind.IP == nil || bind.IP.IsUnspecified() || bind.IP.Equal(ip)) {
+			return true
+		}
+	}
+	return false
+}
+
 // checkpointSorter implements sort.Interface to allow a slice of checkpoints to
 // be sorted.
 type checkpointSorter []model.Checkpoint
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -18,6 +19,7 @@ import (
 	"github.com/copernet/copernicus/model/pow"
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/net/addrmgr"
 	"github.com/copernet/copernicus/net/connmgr"
 	"github.com/copernet/copernicus/net/upnp"
 	"github.com/copernet/copernicus/net/wire"
@@ -135,7 +137,8 @@ func makeTestServer() (*Server, string, chan struct{}, error) {
 	if err != nil {
 		return nil, "", nil, err
 	}
-	appInitMain([]string{"--datadir", dir, "--regtest"})
+	// keep the regtest genesis too old to leave initial block download
+	appInitMain([]string{"--datadir", dir, "--regtest", "--maxtipage=86400"})
 	c := make(chan struct{})
 	conf.Cfg.P2PNet.ListenAddrs = []string{"127.0.0.1:0"}
 	conf.Cfg.P2PNet.DisableBanning = false
@@ -787,6 +790,111 @@ func TestOnInv_versionok(t *testing.T) {
 	sp.OnInv(in, msgInv)
 }
 
+func TestBlocksOnlyVersion(t *testing.T) {
+	conf.Cfg.P2PNet.BlocksOnly = true
+	defer func() { conf.Cfg.P2PNet.BlocksOnly = false }()
+
+	outConn, remote := pipe(
+		&conn{raddr: "10.0.0.1:8333"},
+		&conn{raddr: "10.0.0.2:8333"},
+	)
+	sp := newServerPeer(s, false)
+	p, err := peer.NewOutboundPeer(newPeerConfig(sp), "10.0.0.1:8333", false)
+	assert.Nil(t, err)
+	sp.Peer = p
+	p.AssociateConnection(outConn, s.MsgChan, func(*peer.Peer) {})
+	defer p.Disconnect()
+
+	// we tell the remote peer not to relay transactions to us
+	msg, _, err := wire.ReadMessage(remote, peer.MaxProtocolVersion, model.ActiveNetParams.BitcoinNet)
+	assert.Nil(t, err)
+	version, ok := msg.(*wire.MsgVersion)
+	if !ok {
+		t.Fatalf("got %T, want the version message", msg)
+	}
+	assert.True(t, version.DisableRelayTx)
+}
+
+func TestBlocksOnlyMemPool(t *testing.T) {
+	conf.Cfg.P2PNet.BlocksOnly = true
+	defer func() { conf.Cfg.P2PNet.BlocksOnly = false }()
+
+	config := peer.Config{}
+	in := peer.NewInboundPeer(&config, false)
+	sp := newServerPeer(s, false)
+	sp.Peer = in
+
+	// the request is ignored rather than answered or punished
+	sp.OnMemPool(in, wire.NewMsgMemPool())
+	assert.Equal(t, uint32(0), sp.banScore.Int())
+}
+
+func TestBlocksOnlyTx(t *testing.T) {
+	conf.Cfg.P2PNet.BlocksOnly = true
+	defer func() { conf.Cfg.P2PNet.BlocksOnly = false }()
+	SetMsgHandle(context.TODO(), s.MsgChan, s)
+
+	outConn, remote := pipe(
+		&conn{raddr: "10.0.0.3:8333"},
+		&conn{raddr: "10.0.0.4:8333"},
+	)
+	sp := newServerPeer(s, false)
+	p, err := peer.NewOutboundPeer(newPeerConfig(sp), "10.0.0.3:8333", false)
+	assert.Nil(t, err)
+	sp.Peer = p
+	p.AssociateConnection(outConn, s.MsgChan, func(*peer.Peer) {})
+	defer p.Disconnect()
+
+	bitcoinNet := model.ActiveNetParams.BitcoinNet
+	received := make(chan wire.Message, 100)
+	go func() {
+		defer close(received)
+		for {
+			msg, _, err := wire.ReadMessage(remote, peer.MaxProtocolVersion, bitcoinNet)
+			if err != nil {
+				if _, ok := err.(*wire.MessageError); ok {
+					continue
+				}
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	// the writes block while the peer does not read, keep them off the
+	// test goroutine so that a stuck peer fails the test below
+	go func() {
+		na := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.4"), 8333, 0)
+		msgs := []wire.Message{
+			wire.NewMsgVersion(na, p.NA(), 0x1234, 0),
+			wire.NewMsgVerAck(),
+			(*wire.MsgTx)(tx.NewTx(0, tx.TxVersion)),
+			wire.NewMsgPing(42),
+		}
+		for _, msg := range msgs {
+			if err := wire.WriteMessage(remote, msg, peer.MaxProtocolVersion, bitcoinNet); err != nil {
+				return
+			}
+		}
+	}()
+
+	// the ping sent after the tx is still answered
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg, ok := <-received:
+			if !ok {
+				t.Fatalf("the connection was closed before the pong")
+			}
+			if pong, ok := msg.(*wire.MsgPong); ok && pong.Nonce == 42 {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("the message after the tx was not processed")
+		}
+	}
+}
+
 func TestOnHeaders(t *testing.T) {
 	msgHeaders := wire.NewMsgHeaders()
 	config := peer.Config{}
@@ -1245,6 +1353,60 @@ func TestInitListeners(t *testing.T) {
 		}*/
 }
 
+func TestBindListeners(t *testing.T) {
+	configUpnp := conf.Cfg.P2PNet.Upnp
+	configExternalIPs := conf.Cfg.P2PNet.ExternalIPs
+	defer func() {
+		conf.Cfg.P2PNet.Upnp = configUpnp
+		conf.Cfg.P2PNet.ExternalIPs = configExternalIPs
+	}()
+	conf.Cfg.P2PNet.Upnp = false
+	conf.Cfg.P2PNet.ExternalIPs = nil
+
+	listenAddrs, onionAddrs, err := conf.ResolveBinds([]string{"127.0.0.1:0", "127.0.0.1:0=onion"},
+		[]string{"127.0.0.1:18333"}, "", model.ActiveNetParams.DefaultPort)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:0"}, listenAddrs)
+
+	amgr := addrmgr.New(dir, nil)
+	listeners, _, err := initListeners(amgr, listenAddrs, defaultServices)
+	assert.Nil(t, err)
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+	onionListeners, err := listenOnion(onionAddrs)
+	assert.Nil(t, err)
+	defer func() {
+		for _, listener := range onionListeners {
+			listener.Close()
+		}
+	}()
+	assert.Equal(t, 1, len(listeners))
+	assert.Equal(t, 1, len(onionListeners))
+
+	// the listener is bound to the requested interface only
+	host, port, err := net.SplitHostPort(listeners[0].Addr().String())
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.NotEqual(t, "0", port)
+
+	// the onion service forwards to the onion bind
+	assert.Equal(t, onionListeners[0].Addr().String(),
+		onionTarget(listeners, onionListeners, model.ActiveNetParams.DefaultPort))
+
+	// loopback binds are not routable and so not advertised, a routable
+	// bound address is advertised with the bound port
+	assert.Equal(t, 0, len(amgr.GetAllLocalAddress()))
+	err = addLocalAddress(amgr, net.JoinHostPort("8.8.8.8", port), defaultServices)
+	assert.Nil(t, err)
+	local := amgr.GetAllLocalAddress()
+	if assert.Equal(t, 1, len(local)) {
+		assert.Equal(t, net.JoinHostPort("8.8.8.8", port), addrmgr.NetAddressKey(local[0].Na))
+	}
+}
+
 func getBlock(blockstr string) *block.Block {
 	blk := block.NewBlock()
 	blkBuf, _ := hex.DecodeString(blockstr)
@@ -1377,6 +1539,17 @@ func TestServer_Stop(t *testing.T) {
 	}
 }
 
+func TestNewServerPruned(t *testing.T) {
+	conf.Cfg.Chain.Prune = true
+	defer func() { conf.Cfg.Chain.Prune = false }()
+
+	chn := make(chan struct{})
+	svr, err := NewServer(model.ActiveNetParams, nil, chn)
+	assert.Nil(t, err)
+	assert.Equal(t, wire.ServiceFlag(0), svr.services&wire.SFNodeNetwork)
+	assert.Equal(t, wire.SFNodeCash, svr.services&wire.SFNodeCash)
+}
+
 func TestServer_ScheduleShutdown(t *testing.T) {
 	chn := make(chan struct{})
 	svr, err := NewServer(model.ActiveNetParams, nil, chn)
@@ -1604,6 +1777,78 @@ func TestServer_addBanScore(t *testing.T) {
 	assert.Equal(t, uint32(222), sp.banScore.Int())
 }
 
+func TestOnReadBadHeader(t *testing.T) {
+	disableBanning := conf.Cfg.P2PNet.DisableBanning
+	defer func() {
+		conf.Cfg.P2PNet.DisableBanning = disableBanning
+	}()
+	conf.Cfg.P2PNet.DisableBanning = false
+
+	svr := &Server{banPeers: make(chan *serverPeer, 1)}
+	sp := newServerPeer(svr, false)
+	sp.Peer = &peer.Peer{}
+
+	// Errors decoding an otherwise well-formed message are not scored.
+	sp.OnRead(nil, 24, nil, &wire.MessageError{Description: "bad varint"})
+	sp.OnRead(nil, 0, nil, io.EOF)
+	assert.Equal(t, uint32(0), sp.banScore.Int())
+
+	sp.OnRead(nil, 24, nil, &wire.MessageError{
+		Description: "message from other network", BadHeader: true})
+	assert.Equal(t, uint32(20), sp.banScore.Int())
+}
+
+func TestServer_addBanScoreWhitelisted(t *testing.T) {
+	disableBanning := conf.Cfg.P2PNet.DisableBanning
+	defer func() {
+		conf.Cfg.P2PNet.DisableBanning = disableBanning
+	}()
+	conf.Cfg.P2PNet.DisableBanning = false
+
+	svr := &Server{banPeers: make(chan *serverPeer, 1)}
+	sp := newServerPeer(svr, false)
+	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp), true)
+
+	for i := 0; i < 10; i++ {
+		sp.addBanScore(conf.Cfg.P2PNet.BanThreshold, 0, "testban")
+	}
+	assert.Equal(t, uint32(0), sp.banScore.Int())
+	assert.Equal(t, 0, len(svr.banPeers), "whitelisted peer should never be banned")
+}
+
+func TestIsWhiteBound(t *testing.T) {
+	whiteBinds := conf.Cfg.P2PNet.WhiteBinds
+	defer func() {
+		conf.Cfg.P2PNet.WhiteBinds = whiteBinds
+	}()
+
+	tests := []struct {
+		binds   []string
+		addr    string
+		isWhite bool
+	}{
+		{nil, "127.0.0.1:18444", false},
+		{[]string{"127.0.0.1:18444"}, "127.0.0.1:18444", true},
+		{[]string{"127.0.0.1:18444"}, "127.0.0.1:18333", false},
+		{[]string{"127.0.0.1:18444"}, "10.0.0.1:18444", false},
+		{[]string{"0.0.0.0:18444"}, "10.0.0.1:18444", true},
+		{[]string{":18444"}, "10.0.0.1:18444", true},
+		{[]string{"127.0.0.1:18444"}, "127.0.0.1", false},
+		{[]string{"localhost:18444"}, "127.0.0.1:18444", true},
+	}
+
+	for _, test := range tests {
+		conf.Cfg.P2PNet.WhiteBinds = nil
+		for _, bind := range test.binds {
+			tcpAddr, err := net.ResolveTCPAddr("tcp", bind)
+			assert.Nil(t, err)
+			conf.Cfg.P2PNet.WhiteBinds = append(conf.Cfg.P2PNet.WhiteBinds, tcpAddr)
+		}
+		addr := simpleAddr{"tcp", test.addr}
+		assert.Equal(t, test.isWhite, isWhiteBound(addr), "binds %v, local addr %s", test.binds, test.addr)
+	}
+}
+
 func TestServer_transferPing(t *testing.T) {
 	p := &peer.Peer{}
 	sp := newServerPeer(s, false)
@@ -1646,3 +1891,217 @@ func TestServer_findBlockIndex(t *testing.T) {
 	_, send := findBlockIndex(idxbest.Prev.GetBlockHash())
 	assert.True(t, send)
 }
+
+func TestPingPeersRoundTrip(t *testing.T) {
+	SetMsgHandle(context.TODO(), s.MsgChan, s)
+
+	inConn, remote := pipe(
+		&conn{raddr: "10.0.0.3:18444"},
+		&conn{raddr: "10.0.0.4:18444"},
+	)
+
+	pongs := make(chan *wire.MsgPong, 1)
+	sp := newServerPeer(s, false)
+	spCfg := newPeerConfig(sp)
+	spCfg.Listeners.OnPong = func(p *peer.Peer, msg *wire.MsgPong) {
+		pongs <- msg
+	}
+	sp.Peer = peer.NewInboundPeer(spCfg, false)
+	sp.AssociateConnection(inConn, s.MsgChan, func(*peer.Peer) {})
+	defer sp.Disconnect()
+
+	// The remote end of the connection is driven by hand, a second peer in
+	// this process would be refused as a connection to self.
+	pver := wire.ProtocolVersion
+	btcnet := model.ActiveNetParams.BitcoinNet
+	received := make(chan wire.Message, 16)
+	go func() {
+		for {
+			msg, _, err := wire.ReadMessage(remote, pver, btcnet)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- msg
+		}
+	}()
+	waitFor := func(cmd string) wire.Message {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg, ok := <-received:
+				if !ok {
+					t.Fatalf("connection closed waiting for %s", cmd)
+				}
+				if msg.Command() == cmd {
+					return msg
+				}
+			case <-timeout:
+				t.Fatalf("timeout waiting for %s", cmd)
+			}
+		}
+	}
+
+	me := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.4"), 18444, wire.SFNodeNetwork)
+	you := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.3"), 18444, wire.SFNodeNetwork)
+	version := wire.NewMsgVersion(me, you, 0x1234567890, 0)
+	version.Services = wire.SFNodeNetwork
+	assert.Nil(t, wire.WriteMessage(remote, version, pver, btcnet))
+	waitFor(wire.CmdVersion)
+	waitFor(wire.CmdVerAck)
+	assert.Nil(t, wire.WriteMessage(remote, wire.NewMsgVerAck(), pver, btcnet))
+	for deadline := time.Now().Add(5 * time.Second); !sp.VerAckReceived(); {
+		if time.Now().After(deadline) {
+			t.Fatal("handshake not completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ps := peerState{
+		inboundPeers:    map[int32]*serverPeer{sp.ID(): sp},
+		outboundPeers:   make(map[int32]*serverPeer),
+		persistentPeers: make(map[int32]*serverPeer),
+		bannedAddr:      make(map[string]*BannedInfo),
+		bannedIPNet:     make(map[string]*BannedInfo),
+		outboundGroups:  make(map[string]int),
+	}
+	s.handleQuery(&ps, pingPeersMsg{})
+
+	ping := waitFor(wire.CmdPing).(*wire.MsgPing)
+	assert.NotEqual(t, uint64(0), ping.Nonce)
+	assert.Equal(t, ping.Nonce, sp.LastPingNonce())
+	assert.Nil(t, wire.WriteMessage(remote, wire.NewMsgPong(ping.Nonce), pver, btcnet))
+
+	select {
+	case pong := <-pongs:
+		assert.Equal(t, ping.Nonce, pong.Nonce)
+		// the pong answered the ping, which is no longer pending
+		assert.Equal(t, uint64(0), sp.LastPingNonce())
+	case <-time.After(5 * time.Second):
+		t.Fatal("pong not handled")
+	}
+}
+
+type fakeMessageQueuer struct {
+	msgs []wire.Message
+}
+
+func (q *fakeMessageQueuer) QueueMessage(msg wire.Message, doneChan chan<- struct{}) {
+	q.msgs = append(q.msgs, msg)
+}
+
+func TestQueuePings(t *testing.T) {
+	fakes := []*fakeMessageQueuer{{}, {}, {}}
+	peers := make([]messageQueuer, 0, len(fakes))
+	for _, fake := range fakes {
+		peers = append(peers, fake)
+	}
+
+	queuePings(peers)
+	queuePings(peers)
+
+	nonces := make(map[uint64]struct{})
+	for _, fake := range fakes {
+		assert.Equal(t, 2, len(fake.msgs))
+		for _, msg := range fake.msgs {
+			ping, ok := msg.(*wire.MsgPing)
+			if !assert.True(t, ok, "expect a ping message, got %T", msg) {
+				continue
+			}
+			_, seen := nonces[ping.Nonce]
+			assert.False(t, seen, "ping nonce %d is reused", ping.Nonce)
+			nonces[ping.Nonce] = struct{}{}
+		}
+	}
+}
+
+type memFilterLookup map[util.Hash]util.Hash
+
+func (m memFilterLookup) LookupFilter(hash *util.Hash) ([]byte, *util.Hash, error) {
+	header, ok := m[*hash]
+	if !ok {
+		return nil, nil, nil
+	}
+	return hash[:4], &header, nil
+}
+
+// filterTestChain returns the indexes of a chain of n blocks and their filter
+// headers, each filter being the first bytes of the block hash.
+func filterTestChain(n int) ([]*blockindex.BlockIndex, memFilterLookup) {
+	indexes := make([]*blockindex.BlockIndex, n)
+	filters := make(memFilterLookup)
+	for h := range indexes {
+		header := block.NewBlockHeader()
+		header.Nonce = uint32(h)
+		index := blockindex.NewBlockIndex(header)
+		index.Height = int32(h)
+		if h > 0 {
+			index.Prev = indexes[h-1]
+		}
+		indexes[h] = index
+		filters[*index.GetBlockHash()] = util.Hash{byte(h), byte(h >> 8)}
+	}
+	return indexes, filters
+}
+
+func TestFilterRange(t *testing.T) {
+	indexes, _ := filterTestChain(2001)
+	stop := indexes[2000]
+
+	assert.Nil(t, filterRange(stop, 0, wire.MaxCFHeadersPerMsg))
+	assert.Nil(t, filterRange(stop, 2001, wire.MaxCFHeadersPerMsg))
+	assert.Nil(t, filterRange(stop, 1000, wire.MaxGetCFiltersReqRange))
+
+	headersRange := filterRange(stop, 1, wire.MaxCFHeadersPerMsg)
+	assert.Equal(t, wire.MaxCFHeadersPerMsg, len(headersRange))
+	assert.Equal(t, indexes[1], headersRange[0])
+	assert.Equal(t, stop, headersRange[len(headersRange)-1])
+
+	filtersRange := filterRange(stop, 1001, wire.MaxGetCFiltersReqRange)
+	assert.Equal(t, wire.MaxGetCFiltersReqRange, len(filtersRange))
+	assert.Equal(t, indexes[1001], filtersRange[0])
+}
+
+func TestCFHeadersMsg(t *testing.T) {
+	indexes, filters := filterTestChain(5)
+	stop := indexes[4]
+	req := wire.NewMsgGetCFHeaders(wire.GCSFilterRegular, 2, stop.GetBlockHash())
+
+	reply, err := cfHeadersMsg(filters, req, filterRange(stop, req.StartHeight, wire.MaxCFHeadersPerMsg))
+	assert.Nil(t, err)
+	assert.Equal(t, wire.GCSFilterRegular, reply.FilterType)
+	assert.Equal(t, *stop.GetBlockHash(), reply.StopHash)
+	assert.Equal(t, filters[*indexes[1].GetBlockHash()], reply.PrevFilterHeader)
+	assert.Equal(t, 3, len(reply.FilterHashes))
+	for i, index := range indexes[2:] {
+		filter, _, _ := filters.LookupFilter(index.GetBlockHash())
+		assert.Equal(t, util.DoubleSha256Hash(filter), *reply.FilterHashes[i])
+	}
+
+	// the genesis block has no previous filter header
+	req.StartHeight = 0
+	reply, err = cfHeadersMsg(filters, req, filterRange(stop, req.StartHeight, wire.MaxCFHeadersPerMsg))
+	assert.Nil(t, err)
+	assert.Equal(t, util.Hash{}, reply.PrevFilterHeader)
+	assert.Equal(t, 5, len(reply.FilterHashes))
+
+	delete(filters, *indexes[3].GetBlockHash())
+	_, err = cfHeadersMsg(filters, req, filterRange(stop, req.StartHeight, wire.MaxCFHeadersPerMsg))
+	assert.NotNil(t, err)
+}
+
+func TestCFCheckptMsg(t *testing.T) {
+	indexes, filters := filterTestChain(2500)
+	stop := indexes[2499]
+	req := wire.NewMsgGetCFCheckpt(wire.GCSFilterRegular, stop.GetBlockHash())
+
+	reply, err := cfCheckptMsg(filters, req, stop)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reply.FilterHeaders))
+	assert.Equal(t, filters[*indexes[1000].GetBlockHash()], *reply.FilterHeaders[0])
+	assert.Equal(t, filters[*indexes[2000].GetBlockHash()], *reply.FilterHeaders[1])
+
+	reply, err = cfCheckptMsg(filters, req, indexes[999])
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(reply.FilterHeaders))
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/net/addrmgr"
+	"github.com/copernet/copernicus/net/connmgr"
+	"github.com/copernet/copernicus/net/wire"
+)
+
+// onionV2IDLen is the length of the service ID of a v2 onion address, the
+// only one which can be advertised in the addr message.
+const onionV2IDLen = 16
+
+// onionService is an ephemeral onion service created through the Tor control
+// port, which forwards to our P2P listen port.
+type onionService struct {
+	control   *connmgr.TorControl
+	serviceID string
+}
+
+// addOnionService connects to the Tor control port, creates an onion service
+// mapping the port to the target and advertises its address as a local one.
+// The private key is kept in keyFile so that the address survives restarts.
+func addOnionService(controlAddr, password, keyFile string, port uint16, target string,
+	amgr *addrmgr.AddrManager, services wire.ServiceFlag) (*onionService, error) {
+
+	control, err := connmgr.DialTorControl(controlAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := control.Authenticate(password); err != nil {
+		control.Close()
+		return nil, err
+	}
+
+	var privateKey string
+	if data, err := ioutil.ReadFile(keyFile); err == nil {
+		privateKey = strings.TrimSpace(string(data))
+	} else if !os.IsNotExist(err) {
+		log.Warn("Can not read onion service key %s: %v", keyFile, err)
+	}
+
+	serviceID, newKey, err := control.AddOnion(privateKey, port, target)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	if newKey != privateKey {
+		if err := ioutil.WriteFile(keyFile, []byte(newKey), 0600); err != nil {
+			log.Warn("Can not save onion service key %s: %v", keyFile, err)
+		}
+	}
+
+	onion := serviceID + ".onion"
+	log.Info("Got tor onion service address %s", onion)
+
+	// TODO: advertise v3 addresses as local addresses once addrv2 is
+	// supported, they do not fit in the legacy addr message.
+	if len(serviceID) != onionV2IDLen {
+		log.Info("Not advertising onion address %s, addrv2 is not supported", onion)
+	} else if na, err := amgr.HostToNetAddress(onion, port, services); err != nil {
+		log.Warn("Not advertising onion address %s: %v", onion, err)
+	} else if err := amgr.AddLocalAddress(na, addrmgr.ManualPrio); err != nil {
+		log.Warn("Skipping onion address %s: %v", onion, err)
+	}
+
+	return &onionService{control: control, serviceID: serviceID}, nil
+}
+
+// Remove removes the onion service and closes the control connection.
+func (o *onionService) Remove() {
+	if err := o.control.DelOnion(o.serviceID); err != nil {
+		log.Warn("Can not remove onion service %s: %v", o.serviceID, err)
+	}
+	o.control.Close()
+}
+
+// onionTarget returns the local address the onion service forwards to: the
+// first address bound with =onion, or else the loopback address on the port
+// of the first listener.
+func onionTarget(listeners, onionListeners []net.Listener, defaultPort string) string {
+	if len(onionListeners) > 0 {
+		return onionListeners[0].Addr().String()
+	}
+
+	port := defaultPort
+	if len(listeners) > 0 {
+		if _, p, err := net.SplitHostPort(listeners[0].Addr().String()); err == nil {
+			port = p
+		}
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// torControlThread keeps an onion service for the lifetime of the server when
+// a Tor control port is configured. Failing to reach Tor is not fatal, the
+// node simply goes without an onion address.
+func (s *Server) torControlThread() {
+	defer s.wg.Done()
+
+	controlAddr := conf.Cfg.P2PNet.TorControl
+	keyFile := filepath.Join(conf.DataDir, "onion_v3_private_key")
+
+	port, err := strconv.ParseUint(s.chainParams.DefaultPort, 10, 16)
+	if err != nil {
+		log.Error("Can not parse default port %s: %v", s.chainParams.DefaultPort, err)
+		return
+	}
+	service, err := addOnionService(controlAddr, conf.Cfg.P2PNet.TorPassword, keyFile,
+		uint16(port), s.onionTarget, s.addrManager, s.services)
+	if err != nil {
+		log.Warn("Can not create tor onion service through %s: %v", controlAddr, err)
+		return
+	}
+
+	<-s.quit
+	service.Remove()
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/copernet/copernicus/net/addrmgr"
+	"github.com/copernet/copernicus/net/wire"
+)
+
+// fakeTorControl serves a single control connection, answering PROTOCOLINFO,
+// AUTHENTICATE, ADD_ONION and DEL_ONION, and sends every command it gets on
+// the returned channel.
+func fakeTorControl(t *testing.T, serviceID string) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	commands := make(chan string, 8)
+
+	go func() {
+		defer listener.Close()
+		defer close(commands)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			commands <- line
+
+			switch {
+			case strings.HasPrefix(line, "PROTOCOLINFO"):
+				conn.Write([]byte("250-PROTOCOLINFO 1\r\n" +
+					"250-AUTH METHODS=NULL\r\n" +
+					"250-VERSION Tor=\"0.4.8.9\"\r\n" +
+					"250 OK\r\n"))
+			case strings.HasPrefix(line, "ADD_ONION"):
+				conn.Write([]byte("250-ServiceID=" + serviceID + "\r\n" +
+					"250-PrivateKey=ED25519-V3:c2VjcmV0\r\n" +
+					"250 OK\r\n"))
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	return listener.Addr().String(), commands
+}
+
+func TestAddOnionService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	serviceID := "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd"
+	controlAddr, commands := fakeTorControl(t, serviceID)
+	keyFile := filepath.Join(dir, "onion_v3_private_key")
+	lookup := func(host string) ([]net.IP, error) {
+		t.Errorf("unexpected lookup of %s", host)
+		return nil, errors.New("no lookup")
+	}
+	amgr := addrmgr.New(dir, lookup)
+
+	service, err := addOnionService(controlAddr, "", keyFile, 8333, "127.0.0.1:18333",
+		amgr, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("addOnionService failed: %v", err)
+	}
+
+	expected := []string{
+		"PROTOCOLINFO 1",
+		"AUTHENTICATE",
+		"ADD_ONION NEW:ED25519-V3 Port=8333,127.0.0.1:18333",
+	}
+	for _, want := range expected {
+		if got := <-commands; got != want {
+			t.Errorf("expect command %q, got %q", want, got)
+		}
+	}
+
+	service.Remove()
+	if got := <-commands; got != "DEL_ONION "+serviceID {
+		t.Errorf("expect onion service removed, got %q", got)
+	}
+
+	// the key is reused on the next start, keeping the same address
+	controlAddr, commands = fakeTorControl(t, serviceID)
+	service, err = addOnionService(controlAddr, "", keyFile, 8333, "127.0.0.1:18333",
+		amgr, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("addOnionService failed: %v", err)
+	}
+	<-commands
+	<-commands
+	if got := <-commands; got != "ADD_ONION ED25519-V3:c2VjcmV0 Port=8333,127.0.0.1:18333" {
+		t.Errorf("expect saved key to be used, got %q", got)
+	}
+	service.Remove()
+}
+
+func TestAddOnionServiceUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = addOnionService(addr, "", "", 8333, "127.0.0.1:18333",
+		addrmgr.New("", nil), wire.SFNodeNetwork)
+	if err == nil {
+		t.Errorf("expect error when the control port is unreachable")
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/copernet/copernicus/rpc/btcjson"
+	"github.com/copernet/copernicus/util"
+)
+
+const (
+	// uploadTargetTimeframe is the length in seconds of the cycle the upload
+	// target applies to.
+	uploadTargetTimeframe = 24 * 60 * 60
+
+	// historicalBlockAge is the age in seconds, relative to the best header,
+	// past which a block counts as historical and stops being served once
+	// the upload target is reached.
+	historicalBlockAge = 7 * 24 * 60 * 60
+)
+
+// uploadTarget accounts the bytes sent to each peer within the current cycle
+// against the maxuploadtarget limit. It is safe for concurrent access.
+type uploadTarget struct {
+	mtx         sync.Mutex
+	limit       uint64 // bytes per cycle, 0 means no limit
+	cycleStart  int64
+	sentInCycle uint64
+	sentToPeer  map[int32]uint64
+	now         func() int64
+}
+
+func newUploadTarget(limitMiB uint64) *uploadTarget {
+	return &uploadTarget{
+		limit:      limitMiB * 1024 * 1024,
+		sentToPeer: make(map[int32]uint64),
+		now:        util.GetTimeSec,
+	}
+}
+
+// rollCycle starts a new cycle once the current one is over. It must be called
+// with the lock held.
+func (u *uploadTarget) rollCycle() {
+	now := u.now()
+	if now-u.cycleStart >= uploadTargetTimeframe {
+		u.cycleStart = now
+		u.sentInCycle = 0
+		u.sentToPeer = make(map[int32]uint64)
+	}
+}
+
+// AddBytesSent accounts the bytes sent to a peer.
+func (u *uploadTarget) AddBytesSent(peerID int32, bytesSent uint64) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.rollCycle()
+	u.sentInCycle += bytesSent
+	u.sentToPeer[peerID] += bytesSent
+}
+
+// PeerBytesSent returns the bytes sent to a peer in the current cycle.
+func (u *uploadTarget) PeerBytesSent(peerID int32) uint64 {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.rollCycle()
+	return u.sentToPeer[peerID]
+}
+
+// RemovePeer forgets the bytes sent to a disconnected peer. They still count
+// against the target of the cycle.
+func (u *uploadTarget) RemovePeer(peerID int32) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	delete(u.sentToPeer, peerID)
+}
+
+// TargetReached reports whether the bytes sent in the current cycle reached
+// the limit.
+func (u *uploadTarget) TargetReached() bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.rollCycle()
+	return u.limit > 0 && u.sentInCycle >= u.limit
+}
+
+// ShouldServeBlock reports whether a block with the given time may be served
+// to a peer. Recent blocks are always served, historical ones only while the
+// target is not reached, or to whitelisted peers.
+func (u *uploadTarget) ShouldServeBlock(blockTime, bestHeaderTime int64, whitelisted bool) bool {
+	if whitelisted || bestHeaderTime-blockTime <= historicalBlockAge {
+		return true
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.rollCycle()
+	if u.limit == 0 {
+		return true
+	}
+	return u.sentInCycle < u.limit
+}
+
+// Info returns the state of the upload target for the RPC server.
+func (u *uploadTarget) Info() *btcjson.UploadTargetResult {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.rollCycle()
+	info := &btcjson.UploadTargetResult{
+		Timeframe:             uploadTargetTimeframe,
+		Target:                u.limit,
+		TargetReached:         u.limit > 0 && u.sentInCycle >= u.limit,
+		ServeHistoricalBlocks: u.limit == 0 || u.sentInCycle < u.limit,
+	}
+	if u.limit > 0 {
+		if u.sentInCycle < u.limit {
+			info.BytesLeftInCycle = u.limit - u.sentInCycle
+		}
+		info.TimeLeftInCycle = u.cycleStart + uploadTargetTimeframe - u.now()
+	}
+	return info
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestUploadTargetHistoricalBlocks(t *testing.T) {
+	now := int64(1600000000)
+	target := newUploadTarget(1)
+	target.now = func() int64 { return now }
+
+	bestHeaderTime := now
+	oldBlockTime := bestHeaderTime - historicalBlockAge - 1
+	recentBlockTime := bestHeaderTime - 60*60
+
+	target.AddBytesSent(1, 1024*1024/2)
+	target.AddBytesSent(2, 1024*1024/2-1)
+	if !target.ShouldServeBlock(oldBlockTime, bestHeaderTime, false) {
+		t.Errorf("old block should be served before the target is reached")
+	}
+
+	target.AddBytesSent(2, 1)
+	if !target.TargetReached() {
+		t.Fatalf("target should be reached")
+	}
+	if target.ShouldServeBlock(oldBlockTime, bestHeaderTime, false) {
+		t.Errorf("old block should be refused once the target is reached")
+	}
+	if !target.ShouldServeBlock(recentBlockTime, bestHeaderTime, false) {
+		t.Errorf("recent block should still be served once the target is reached")
+	}
+	if !target.ShouldServeBlock(oldBlockTime, bestHeaderTime, true) {
+		t.Errorf("whitelisted peer should bypass the target")
+	}
+
+	info := target.Info()
+	if !info.TargetReached || info.ServeHistoricalBlocks || info.BytesLeftInCycle != 0 {
+		t.Errorf("unexpected upload target info: %+v", info)
+	}
+	if info.TimeLeftInCycle != uploadTargetTimeframe {
+		t.Errorf("expect %d seconds left in cycle, got %d", uploadTargetTimeframe, info.TimeLeftInCycle)
+	}
+
+	// a new cycle starts once the timeframe is over
+	now += uploadTargetTimeframe
+	if !target.ShouldServeBlock(oldBlockTime, bestHeaderTime, false) {
+		t.Errorf("old block should be served again in a new cycle")
+	}
+	if sent := target.PeerBytesSent(1); sent != 0 {
+		t.Errorf("expect the bytes sent to the peer to be reset in a new cycle, got %d", sent)
+	}
+}
+
+func TestUploadTargetSinglePeer(t *testing.T) {
+	now := int64(1600000000)
+	target := newUploadTarget(1)
+	target.now = func() int64 { return now }
+
+	oldBlockTime := now - historicalBlockAge - 1
+
+	// a single syncing peer is served until the target of the cycle is reached
+	target.AddBytesSent(1, 1024*1024-1)
+	if sent := target.PeerBytesSent(1); sent != 1024*1024-1 {
+		t.Errorf("expect %d bytes sent to the peer, got %d", 1024*1024-1, sent)
+	}
+	if !target.ShouldServeBlock(oldBlockTime, now, false) {
+		t.Errorf("old block should be served below the target")
+	}
+
+	// a disconnected peer is forgotten, its bytes still count
+	target.RemovePeer(1)
+	if sent := target.PeerBytesSent(1); sent != 0 {
+		t.Errorf("expect no bytes sent to a removed peer, got %d", sent)
+	}
+	if left := target.Info().BytesLeftInCycle; left != 1 {
+		t.Errorf("expect 1 byte left in cycle, got %d", left)
+	}
+}
+
+func TestUploadTargetUnlimited(t *testing.T) {
+	target := newUploadTarget(0)
+	target.AddBytesSent(1, 1<<40)
+
+	if target.TargetReached() {
+		t.Errorf("no target should never be reached")
+	}
+	if !target.ShouldServeBlock(0, historicalBlockAge+1, false) {
+		t.Errorf("old block should be served without a target")
+	}
+}
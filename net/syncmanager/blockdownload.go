@@ -0,0 +1,155 @@
+package syncmanager
+
+import (
+	"sort"
+	"time"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lblock"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/peer"
+	"github.com/copernet/copernicus/util"
+)
+
+const (
+	// defaultWindowFrontTimeout is how long the block at the front of the
+	// download window may stay in flight from the same peer before the peer
+	// is considered stalling the download. The timeout doubles up to
+	// maxWindowFrontTimeout with each stalling peer, in case our own link is
+	// the slow one, and shrinks back as blocks arrive.
+	defaultWindowFrontTimeout = time.Minute
+	maxWindowFrontTimeout     = 16 * time.Minute
+
+	// stallCheckInterval is the interval at which the front of the download
+	// window is checked for stalling.
+	stallCheckInterval = time.Second
+)
+
+// windowFront records since when the block following our tip has been in
+// flight from a peer.
+type windowFront struct {
+	hash  util.Hash
+	peer  *peer.Peer
+	since time.Time
+}
+
+// requestBlock records that the block hash is requested from peer.
+func (sm *SyncManager) requestBlock(peer *peer.Peer, state *peerSyncState, hash util.Hash) {
+	if len(state.requestedBlocks) == 0 {
+		state.downloadingSince = time.Now()
+	}
+	sm.requestedBlocks[hash] = peer
+	state.requestedBlocks[hash] = struct{}{}
+}
+
+// recordBlockDownload updates the average time the peer takes to deliver a
+// requested block with a block received at now.
+func (pss *peerSyncState) recordBlockDownload(now time.Time) {
+	sample := now.Sub(pss.downloadingSince)
+	if pss.blockDownloadTime == 0 {
+		pss.blockDownloadTime = sample
+	} else {
+		pss.blockDownloadTime += (sample - pss.blockDownloadTime) / 4
+	}
+	pss.downloadingSince = now
+}
+
+// blockDelivered shrinks the window front timeout back towards its default
+// once a requested block arrived.
+func (sm *SyncManager) blockDelivered() {
+	if sm.windowFrontTimeout > defaultWindowFrontTimeout {
+		sm.windowFrontTimeout = sm.windowFrontTimeout * 85 / 100
+		if sm.windowFrontTimeout < defaultWindowFrontTimeout {
+			sm.windowFrontTimeout = defaultWindowFrontTimeout
+		}
+	}
+}
+
+// blockDownloadPeers returns the peers blocks may be downloaded from, the
+// fastest first. Peers which did not deliver any block yet come last.
+func (sm *SyncManager) blockDownloadPeers() []*peer.Peer {
+	peers := make([]*peer.Peer, 0, len(sm.peerStates))
+	for p, state := range sm.peerStates {
+		if state.syncCandidate && !state.stalling {
+			peers = append(peers, p)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		ti := sm.peerStates[peers[i]].blockDownloadTime
+		tj := sm.peerStates[peers[j]].blockDownloadTime
+		if ti == 0 || tj == 0 {
+			if ti != tj {
+				return tj == 0
+			}
+			return peers[i].ID() < peers[j].ID()
+		}
+		return ti < tj
+	})
+	return peers
+}
+
+// checkWindowStall disconnects the peer the block following our tip is
+// requested from when it did not deliver it within the window front timeout
+// at now. The whole download waits for that block during initial block
+// download, so a single slow peer would stall it otherwise. Out of initial
+// block download, or without another peer to fetch the block from, the peer
+// is kept.
+func (sm *SyncManager) checkWindowStall(now time.Time) {
+	gChain := chain.GetInstance()
+	tip := gChain.Tip()
+	front := gChain.GetIndexBestHeader().GetAncestor(tip.Height + 1)
+	if front == nil || front.Prev != tip {
+		sm.windowFront = windowFront{}
+		return
+	}
+	hash := *front.GetBlockHash()
+	p, inFlight := sm.requestedBlocks[hash]
+	if !inFlight {
+		sm.windowFront = windowFront{}
+		return
+	}
+	if sm.windowFront.hash != hash || sm.windowFront.peer != p {
+		sm.windowFront = windowFront{hash: hash, peer: p, since: now}
+		return
+	}
+	if now.Sub(sm.windowFront.since) < sm.windowFrontTimeout {
+		return
+	}
+	if !lblock.IsInitialBlockDownload() || len(sm.blockDownloadPeers()) < 2 {
+		return
+	}
+
+	log.Info("Peer(%d)%s did not deliver block %s at height %d in %v",
+		p.ID(), p.Addr(), hash, front.Height, sm.windowFrontTimeout)
+	sm.windowFront = windowFront{}
+	sm.windowFrontTimeout *= 2
+	if sm.windowFrontTimeout > maxWindowFrontTimeout {
+		sm.windowFrontTimeout = maxWindowFrontTimeout
+	}
+	sm.stallPeer(p)
+}
+
+// stallPeer disconnects a peer stalling the block download and requests the
+// blocks in flight from it from the other peers, the fastest first.
+func (sm *SyncManager) stallPeer(p *peer.Peer) {
+	state, exists := sm.peerStates[p]
+	if !exists {
+		return
+	}
+	log.Info("Peer(%d)%s is stalling block download, disconnecting",
+		p.ID(), p.Addr())
+	p.Disconnect()
+	p.SetStallingSince(0)
+
+	// The peer state is only removed once the peer is done, so keep the
+	// peer from being assigned blocks until then.
+	state.stalling = true
+	for blockHash := range state.requestedBlocks {
+		delete(sm.requestedBlocks, blockHash)
+	}
+	state.requestedBlocks = make(map[util.Hash]struct{})
+
+	for _, other := range sm.blockDownloadPeers() {
+		sm.fetchHeaderBlocks(other)
+	}
+}
@@ -0,0 +1,269 @@
+package syncmanager
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/pow"
+	"github.com/copernet/copernicus/util"
+)
+
+const (
+	// headersCommitmentPeriod is the number of headers between two of the
+	// commitments kept while presyncing a headers chain.
+	headersCommitmentPeriod = 600
+
+	// headersRedownloadBufferSize is the number of redownloaded headers kept
+	// in memory before they are committed to the block index. The buffer
+	// spans enough commitments to make a peer feeding a different chain
+	// than the presynced one fail the checks before it is committed.
+	headersRedownloadBufferSize = 24 * headersCommitmentPeriod
+
+	// maxHeadersPerSecond bounds the rate at which a valid headers chain
+	// can grow, given the median time past rule. It caps the number of
+	// commitments a peer can make us keep.
+	maxHeadersPerSecond = 6
+
+	// maxFutureHeaderTime is how far in the future a header time may be.
+	maxFutureHeaderTime = 2 * 60 * 60
+
+	// headersSyncTipMargin is the number of blocks of work below our tip a
+	// headers chain may have without being presynced, so that reorgs and
+	// new blocks are accepted right away.
+	headersSyncTipMargin = 144
+)
+
+var (
+	errHeadersLowWork           = errors.New("headers chain does not have enough work")
+	errHeadersNonContinuous     = errors.New("non-continuous headers")
+	errHeadersInvalidPoW        = errors.New("header with invalid proof of work")
+	errHeadersTooMany           = errors.New("too many headers")
+	errHeadersCommitmentFailure = errors.New("redownloaded headers do not match the presynced ones")
+)
+
+// headersSyncPhase is the phase of the download of a low work headers chain.
+type headersSyncPhase int
+
+const (
+	// headersPresync downloads the chain once to learn its work.
+	headersPresync headersSyncPhase = iota
+
+	// headersRedownload downloads the chain again to commit it to the
+	// block index.
+	headersRedownload
+
+	// headersSyncDone means the chain reached the required work, and the
+	// remaining headers are processed as usual.
+	headersSyncDone
+)
+
+// headersSyncState follows the download of a headers chain which does not
+// have enough work to be committed to the block index right away, so that a
+// peer cannot exhaust our memory with a long chain of cheap headers.
+//
+// The chain is first presynced: its headers are checked to connect and to
+// carry valid proof of work, and only their cumulative work and one salted
+// hash bit every headersCommitmentPeriod headers are kept. Once the chain has
+// enough work, it is downloaded again from the start. The redownloaded
+// headers are checked against the commitments and released to the block
+// index once headersRedownloadBufferSize headers follow them, or once the
+// chain has enough work.
+type headersSyncState struct {
+	phase       headersSyncPhase
+	chainStart  *blockindex.BlockIndex
+	minimumWork big.Int
+
+	// the commitments are salted so that a peer cannot find a chain
+	// matching them
+	k0, k1         uint64
+	commitOffset   int32
+	maxCommitments int
+
+	// presync state
+	commitments []bool
+	lastHash    util.Hash
+	height      int32
+	chainWork   big.Int
+
+	// redownload state
+	buffer           []*block.BlockHeader
+	redownloadHash   util.Hash
+	redownloadHeight int32
+	redownloadWork   big.Int
+}
+
+// antiDoSWorkThreshold returns the work a headers chain needs to be committed
+// to the block index without being presynced.
+func antiDoSWorkThreshold() *big.Int {
+	threshold := pow.MiniChainWork()
+	tip := chain.GetInstance().Tip()
+	margin := new(big.Int).Mul(pow.GetBlockProof(tip), big.NewInt(headersSyncTipMargin))
+	tipWork := new(big.Int).Sub(&tip.ChainWork, margin)
+	if tipWork.Cmp(&threshold) > 0 {
+		return tipWork
+	}
+	return &threshold
+}
+
+// headerProof returns the work of header.
+func headerProof(header *block.BlockHeader) *big.Int {
+	return pow.GetBlockProof(&blockindex.BlockIndex{Header: *header})
+}
+
+// headersChainWork returns the chain work of the headers chain starting after
+// chainStart.
+func headersChainWork(chainStart *blockindex.BlockIndex, headers []*block.BlockHeader) *big.Int {
+	work := new(big.Int).Set(&chainStart.ChainWork)
+	for _, header := range headers {
+		work.Add(work, headerProof(header))
+	}
+	return work
+}
+
+// newHeadersSyncState returns the state of the presync of a headers chain
+// starting after chainStart, which needs minimumWork. now is the adjusted
+// time in seconds.
+func newHeadersSyncState(chainStart *blockindex.BlockIndex, minimumWork *big.Int, now int64) *headersSyncState {
+	var salt [20]byte
+	rand.Read(salt[:])
+
+	maxHeaders := (now + maxFutureHeaderTime - chainStart.GetMedianTimePast()) * maxHeadersPerSecond
+	s := &headersSyncState{
+		phase:          headersPresync,
+		chainStart:     chainStart,
+		k0:             binary.LittleEndian.Uint64(salt[0:8]),
+		k1:             binary.LittleEndian.Uint64(salt[8:16]),
+		commitOffset:   int32(binary.LittleEndian.Uint32(salt[16:20]) % headersCommitmentPeriod),
+		maxCommitments: int(maxHeaders / headersCommitmentPeriod),
+		lastHash:       *chainStart.GetBlockHash(),
+		height:         chainStart.Height,
+	}
+	s.minimumWork.Set(minimumWork)
+	s.chainWork.Set(&chainStart.ChainWork)
+	return s
+}
+
+// commitment returns the salted hash bit committing to the header hash.
+func (s *headersSyncState) commitment(hash *util.Hash) bool {
+	return util.SipHash(s.k0, s.k1, hash[:])&1 == 1
+}
+
+// checkHeader checks that header follows prevHash and has valid proof of work.
+func checkHeader(header *block.BlockHeader, prevHash *util.Hash) (util.Hash, error) {
+	if header.HashPrevBlock != *prevHash {
+		return util.Hash{}, errHeadersNonContinuous
+	}
+	hash := header.GetHash()
+	if !new(pow.Pow).CheckProofOfWork(&hash, header.Bits, model.ActiveNetParams) {
+		return util.Hash{}, errHeadersInvalidPoW
+	}
+	return hash, nil
+}
+
+// processHeaders processes the next headers received from the peer, full
+// telling whether the message held as many headers as allowed, so that more
+// of them follow. It returns the headers to commit to the block index, in
+// order. An error aborts the headers sync.
+func (s *headersSyncState) processHeaders(headers []*block.BlockHeader, full bool) ([]*block.BlockHeader, error) {
+	switch s.phase {
+	case headersPresync:
+		return nil, s.presync(headers, full)
+	case headersRedownload:
+		return s.redownload(headers, full)
+	}
+	return headers, nil
+}
+
+func (s *headersSyncState) presync(headers []*block.BlockHeader, full bool) error {
+	for _, header := range headers {
+		hash, err := checkHeader(header, &s.lastHash)
+		if err != nil {
+			return err
+		}
+		s.height++
+		if s.height%headersCommitmentPeriod == s.commitOffset {
+			if len(s.commitments) >= s.maxCommitments {
+				return errHeadersTooMany
+			}
+			s.commitments = append(s.commitments, s.commitment(&hash))
+		}
+		s.lastHash = hash
+		s.chainWork.Add(&s.chainWork, headerProof(header))
+
+		if s.chainWork.Cmp(&s.minimumWork) >= 0 {
+			// download the chain again from its start
+			s.phase = headersRedownload
+			s.redownloadHash = *s.chainStart.GetBlockHash()
+			s.redownloadHeight = s.chainStart.Height
+			s.redownloadWork.Set(&s.chainStart.ChainWork)
+			return nil
+		}
+	}
+	if !full {
+		return errHeadersLowWork
+	}
+	return nil
+}
+
+func (s *headersSyncState) redownload(headers []*block.BlockHeader, full bool) ([]*block.BlockHeader, error) {
+	for i, header := range headers {
+		hash, err := checkHeader(header, &s.redownloadHash)
+		if err != nil {
+			return nil, err
+		}
+		s.redownloadHeight++
+		if s.redownloadHeight%headersCommitmentPeriod == s.commitOffset {
+			if len(s.commitments) == 0 {
+				return nil, errHeadersTooMany
+			}
+			expected := s.commitments[0]
+			s.commitments = s.commitments[1:]
+			if s.commitment(&hash) != expected {
+				return nil, errHeadersCommitmentFailure
+			}
+		}
+		s.buffer = append(s.buffer, header)
+		s.redownloadHash = hash
+		s.redownloadWork.Add(&s.redownloadWork, headerProof(header))
+
+		if s.redownloadWork.Cmp(&s.minimumWork) >= 0 {
+			// the rest of the chain is processed as usual
+			s.phase = headersSyncDone
+			released := append(s.buffer, headers[i+1:]...)
+			s.buffer = nil
+			s.commitments = nil
+			return released, nil
+		}
+	}
+	if !full {
+		return nil, errHeadersLowWork
+	}
+
+	if len(s.buffer) <= headersRedownloadBufferSize {
+		return nil, nil
+	}
+	n := len(s.buffer) - headersRedownloadBufferSize
+	released := s.buffer[:n:n]
+	s.buffer = append([]*block.BlockHeader(nil), s.buffer[n:]...)
+	return released, nil
+}
+
+// locator returns the locator to request the next headers of the chain from
+// the peer.
+func (s *headersSyncState) locator() *chain.BlockLocator {
+	last := s.lastHash
+	if s.phase == headersRedownload {
+		last = s.redownloadHash
+	}
+	hashes := chain.GetInstance().GetLocator(s.chainStart).GetBlockHashList()
+	if last != *s.chainStart.GetBlockHash() {
+		hashes = append([]util.Hash{last}, hashes...)
+	}
+	return chain.NewBlockLocator(hashes)
+}
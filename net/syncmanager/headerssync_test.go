@@ -0,0 +1,83 @@
+package syncmanager
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/pow"
+	"github.com/copernet/copernicus/util"
+)
+
+// makeHeadersChain mines a chain of n headers on top of prev.
+func makeHeadersChain(prev *block.BlockHeader, n int) []*block.BlockHeader {
+	headers := make([]*block.BlockHeader, 0, n)
+	prevHash := prev.GetHash()
+	for i := 0; i < n; i++ {
+		header := &block.BlockHeader{
+			Version:       0x20000000,
+			HashPrevBlock: prevHash,
+			Time:          prev.Time + uint32(i) + 1,
+			Bits:          prev.Bits,
+		}
+		for {
+			prevHash = header.GetHash()
+			if new(pow.Pow).CheckProofOfWork(&prevHash, header.Bits, model.ActiveNetParams) {
+				break
+			}
+			header.Nonce++
+			header.Hash = util.Hash{}
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func TestHeadersSyncState(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	genesis := chain.GetInstance().Tip()
+	headers := makeHeadersChain(genesis.GetBlockHeader(), 3*headersCommitmentPeriod)
+	minimumWork := headersChainWork(genesis, headers)
+	now := util.GetAdjustedTimeSec()
+
+	// a chain without enough work is abandoned when the peer has no more
+	s := newHeadersSyncState(genesis, new(big.Int).Add(minimumWork, big.NewInt(1)), now)
+	if _, err := s.processHeaders(headers, false); err != errHeadersLowWork {
+		t.Errorf("expected a low work error, got %v", err)
+	}
+
+	// headers must connect
+	s = newHeadersSyncState(genesis, minimumWork, now)
+	if _, err := s.processHeaders(headers[1:], true); err != errHeadersNonContinuous {
+		t.Errorf("expected a non-continuous error, got %v", err)
+	}
+
+	// a redownloaded chain must match the presynced one
+	s = newHeadersSyncState(genesis, minimumWork, now)
+	if _, err := s.processHeaders(headers, true); err != nil || s.phase != headersRedownload {
+		t.Fatalf("presync did not complete: %v", err)
+	}
+	if len(s.commitments) != 3 {
+		t.Fatalf("expected 3 commitments, got %d", len(s.commitments))
+	}
+	s.commitments[0] = !s.commitments[0]
+	if _, err := s.processHeaders(headers, true); err != errHeadersCommitmentFailure {
+		t.Errorf("expected a commitment failure, got %v", err)
+	}
+
+	s = newHeadersSyncState(genesis, minimumWork, now)
+	s.processHeaders(headers, true)
+	released, err := s.processHeaders(headers, true)
+	if err != nil || s.phase != headersSyncDone {
+		t.Fatalf("redownload did not complete: %v", err)
+	}
+	if len(released) != len(headers) {
+		t.Errorf("expected %d released headers, got %d", len(headers), len(released))
+	}
+}
@@ -0,0 +1,88 @@
+package syncmanager
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// rollingHashSet is a set of hashes limited to a maximum number of entries
+// with eviction of the oldest entry when the limit is exceeded. It is used to
+// remember recently rejected and recently confirmed transactions.
+//
+// Its methods are safe for concurrent access.
+type rollingHashSet struct {
+	mtx   sync.Mutex
+	txns  map[util.Hash]struct{}
+	order *list.List // hashes in insertion order, oldest first
+	limit int
+}
+
+// newRollingHashSet returns an empty set holding up to limit hashes.
+func newRollingHashSet(limit int) *rollingHashSet {
+	return &rollingHashSet{
+		txns:  make(map[util.Hash]struct{}),
+		order: list.New(),
+		limit: limit,
+	}
+}
+
+// Exists returns whether hash is in the set.
+func (r *rollingHashSet) Exists(hash *util.Hash) bool {
+	r.mtx.Lock()
+	_, exists := r.txns[*hash]
+	r.mtx.Unlock()
+	return exists
+}
+
+// Filter returns the hashes of hashes which are in the set, in a new map the
+// caller may use without the lock of the set.
+func (r *rollingHashSet) Filter(hashes []util.Hash) map[util.Hash]struct{} {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	found := make(map[util.Hash]struct{})
+	for _, hash := range hashes {
+		if _, exists := r.txns[hash]; exists {
+			found[hash] = struct{}{}
+		}
+	}
+	return found
+}
+
+// Add adds hash to the set, evicting the oldest hash if the set is full.
+// Adding an existing hash has no effect.
+func (r *rollingHashSet) Add(hash util.Hash) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, exists := r.txns[hash]; exists {
+		return
+	}
+	if r.limit <= 0 {
+		return
+	}
+	if len(r.txns) >= r.limit {
+		oldest := r.order.Front()
+		r.order.Remove(oldest)
+		delete(r.txns, oldest.Value.(util.Hash))
+	}
+	r.txns[hash] = struct{}{}
+	r.order.PushBack(hash)
+}
+
+// Len returns the number of hashes in the set.
+func (r *rollingHashSet) Len() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.txns)
+}
+
+// Reset empties the set.
+func (r *rollingHashSet) Reset() {
+	r.mtx.Lock()
+	r.txns = make(map[util.Hash]struct{})
+	r.order.Init()
+	r.mtx.Unlock()
+}
@@ -14,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lmempool"
@@ -33,16 +34,21 @@ import (
 const (
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
-	maxRejectedTxns = 1000
+	maxRejectedTxns = 120000
+
+	// rejectedTxBanScore is the ban score added to a peer sending us a
+	// transaction we recently rejected. It is kept low, as the peer may not
+	// have seen the new block that made the transaction invalid yet.
+	rejectedTxBanScore = 1
+
+	// maxRecentConfirmedTxns is the maximum number of hashes of transactions
+	// confirmed by the latest blocks to store in memory.
+	maxRecentConfirmedTxns = 48000
 
 	// maxRequestedBlocks is the maximum number of requested block
 	// hashes to store in memory.
 	maxRequestedBlocks = wire.MaxInvPerMsg
 
-	// maxRequestedTxns is the maximum number of requested transactions
-	// hashes to store in memory.
-	maxRequestedTxns = wire.MaxInvPerMsg
-
 	blockRequestTimeoutTime = 20 * time.Minute
 
 	//MAX_BLOCKS_IN_TRANSIT_PER_PEER is Number of blocks that can be requested at any given time from a single peer
@@ -195,13 +201,23 @@ type headerNode struct {
 type peerSyncState struct {
 	syncCandidate       bool
 	requestQueue        []*wire.InvVect
-	requestedTxns       map[util.Hash]struct{}
 	requestedBlocks     map[util.Hash]struct{}
 	unconnectingHeaders int
 	headersSyncTimeout  int64
 	// syncStarted indicate whether we have send a GetHeaders msg from the peer
 	// when the pindexBestHeader is 24h near to now, to fetch all possible header
 	syncStarted bool
+	// headersSync follows the presync of a low work headers chain announced
+	// by the peer, nil when there is none
+	headersSync *headersSyncState
+	// downloadingSince is when the peer started working on the next block
+	// we wait for, and blockDownloadTime the average time it took to deliver
+	// the previous ones
+	downloadingSince  time.Time
+	blockDownloadTime time.Duration
+	// stalling is set once the peer is disconnected for stalling the block
+	// download
+	stalling bool
 }
 
 func (pss *peerSyncState) onStartSync(syncPeer *peer.Peer) {
@@ -270,15 +286,25 @@ type SyncManager struct {
 	wg                  sync.WaitGroup
 	quit                chan struct{}
 
+	// recentConfirmedTxns holds the transactions confirmed by the latest
+	// blocks, which peers may keep announcing while they are not in our
+	// mempool anymore. It is updated from chain notifications.
+	recentConfirmedTxns *rollingHashSet
+
 	// These fields should only be accessed from the messagesHandler
-	rejectedTxns    map[util.Hash]struct{}
-	requestedTxns   map[util.Hash]struct{}
+	rejectedTxns    *rollingHashSet
+	txRequests      *txRequestTracker
 	requestedBlocks map[util.Hash]*peer.Peer
+	windowFront     windowFront
 	syncPeer        *peer.Peer
 	peerStates      map[*peer.Peer]*peerSyncState
 
+	// windowFrontTimeout is how long the front of the download window may
+	// be in flight from a peer, see checkWindowStall.
+	windowFrontTimeout time.Duration
+
 	// callback for transaction And block process
-	ProcessTransactionCallBack func(*tx.Tx, map[util.Hash]struct{}, int64) ([]*tx.Tx, []util.Hash, []util.Hash, error)
+	ProcessTransactionCallBack func(*tx.Tx, map[util.Hash]struct{}, int64, bool) ([]*tx.Tx, []util.Hash, []util.Hash, error)
 	ProcessBlockCallBack       func(*block.Block, bool) (bool, error)
 	ProcessBlockHeadCallBack   func([]*block.BlockHeader, *blockindex.BlockIndex) error
 	AddBanScoreCallBack        func(string, uint32, uint32, string)
@@ -431,7 +457,6 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peer.Peer) {
 	isSyncCandidate := sm.isSyncCandidate(peer)
 	sm.peerStates[peer] = &peerSyncState{
 		syncCandidate:   isSyncCandidate,
-		requestedTxns:   make(map[util.Hash]struct{}),
 		requestedBlocks: make(map[util.Hash]struct{}),
 	}
 
@@ -472,11 +497,10 @@ func (sm *SyncManager) clearSyncPeerState(peer *peer.Peer) {
 
 	log.Info("Lost peer %s", peer.Addr())
 
-	// Remove requested transactions from the global map so that they will
-	// be fetched from elsewhere next time we get an inv.
-	for txHash := range state.requestedTxns {
-		delete(sm.requestedTxns, txHash)
-	}
+	// Request the transactions in flight from the other peers which
+	// announced them.
+	sm.txRequests.removePeer(peer)
+	sm.requestTxns(time.Now())
 
 	// Remove requested blocks from the global map so that they will be
 	// fetched from elsewhere next time we get an inv.
@@ -507,7 +531,7 @@ func (sm *SyncManager) alreadyHave(txHash *util.Hash) bool {
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
 	// rejected, the transaction was unsolicited.
-	if _, exists := sm.rejectedTxns[*txHash]; exists {
+	if sm.rejectedTxns.Exists(txHash) {
 		log.Debug("Ignoring unsolicited previously rejected transaction %v", txHash)
 		return true
 	}
@@ -518,30 +542,50 @@ func (sm *SyncManager) alreadyHave(txHash *util.Hash) bool {
 // handleTxMsg handles transaction messages from all peers.
 func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	peer := tmsg.peer
-	state, exists := sm.peerStates[peer]
-	if !exists {
+	if _, exists := sm.peerStates[peer]; !exists {
 		log.Warn("Received tx message from unknown peer %s", peer.Addr())
 		return
 	}
 
+	// The transactions are neither validated nor relayed during initial
+	// block download, the chain they spend from is not known yet.
+	if lblock.IsInitialBlockDownload() {
+		log.Trace("Ignore tx from %s during initial block download", peer.Addr())
+		return
+	}
+
 	txHash := tmsg.tx.GetHash()
+	// Whitelisted peers expect their transactions to be relayed even when we
+	// already have them, e.g. to rebroadcast a wallet transaction through
+	// this node, or when they pay less than our mempool min fee.
+	forceRelay := peer.IsWhitelisted() && conf.Cfg.P2PNet.WhitelistForceRelay
 
+	if sm.rejectedTxns.Exists(&txHash) && !peer.IsWhitelisted() {
+		sm.misbehaving(peer.Addr(), rejectedTxBanScore, "resent-rejected-tx")
+	}
 	if sm.alreadyHave(&txHash) {
-		//TODO: relay tx for whitelistrelay node
+		if forceRelay {
+			if txentry := lmempool.FindTxInMempool(txHash); txentry != nil {
+				log.Debug("Force relaying tx %s from whitelisted peer %s", txHash, peer.Addr())
+				sm.peerNotifier.AnnounceNewTransactions([]*mempool.TxEntry{txentry})
+			}
+		}
 		log.Trace("Ignore already processed tx from %s", peer.Addr())
 		return
 	}
 
 	// Process the transaction to include validation, insertion in the memory pool, orphan handling, etc.
-	acceptTxs, missTxs, rejectTxs, err := sm.ProcessTransactionCallBack(tmsg.tx, sm.rejectedTxns, int64(peer.ID()))
+	// Only whether its parents were rejected matters, they are copied out of
+	// the set under its lock.
+	rejectedParents := sm.rejectedTxns.Filter(tmsg.tx.PrevoutHashs())
+	acceptTxs, missTxs, rejectTxs, err := sm.ProcessTransactionCallBack(tmsg.tx, rejectedParents, int64(peer.ID()), forceRelay)
 
-	sm.updateTxRequestState(state, txHash, rejectTxs)
+	sm.updateTxRequestState(txHash, rejectTxs)
 
 	sm.fetchMissingTx(missTxs, peer)
 
 	if err != nil {
-		if rejectCode, reason, ok := errcode.IsRejectCode(err); ok {
-			peer.PushRejectMsg(wire.CmdTx, rejectCode, reason, &txHash, false)
+		if peer.PushRejectMsgFromError(wire.CmdTx, err, false) {
 			log.Debug("Reject tx %s from %s: %v", txHash, peer.Addr(), err)
 			return
 		}
@@ -550,6 +594,10 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		return
 	}
 
+	if len(acceptTxs) > 0 {
+		peer.UpdateLastTxTime(time.Now())
+	}
+
 	txentrys := make([]*mempool.TxEntry, 0, len(acceptTxs))
 	for _, tx := range acceptTxs {
 		if entry := lmempool.FindTxInMempool(tx.GetHash()); entry != nil {
@@ -562,18 +610,16 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	sm.peerNotifier.AnnounceNewTransactions(txentrys)
 }
 
-func (sm *SyncManager) updateTxRequestState(state *peerSyncState, txHash util.Hash, rejectTxs []util.Hash) {
-	// Remove transaction from request maps. Either the mempool/chain already knows about it
+func (sm *SyncManager) updateTxRequestState(txHash util.Hash, rejectTxs []util.Hash) {
+	// Forget the announcements of the transaction. Either the mempool/chain already knows about it
 	// and as such we shouldn't have any more instances of trying to fetch it, or we failed to
 	// insert and thus we'll retry next time we get an inv.
-	delete(state.requestedTxns, txHash)
-	delete(sm.requestedTxns, txHash)
+	sm.txRequests.forgetTx(txHash)
 
 	// Do not request these transactions again until a new block has been processed.
 	for _, rejectTx := range rejectTxs {
-		sm.rejectedTxns[rejectTx] = struct{}{}
+		sm.rejectedTxns.Add(rejectTx)
 	}
-	limitMap(sm.rejectedTxns, maxRejectedTxns)
 }
 
 func (sm *SyncManager) fetchMissingTx(missTxs []util.Hash, peer peer.MsgSender) {
@@ -645,13 +691,17 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
+	if _, exists = state.requestedBlocks[blockHash]; exists {
+		state.recordBlockDownload(time.Now())
+		sm.blockDelivered()
+	}
 	delete(state.requestedBlocks, blockHash)
 	delete(sm.requestedBlocks, blockHash)
 	peer.SetStallingSince(0)
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	_, err := sm.ProcessBlockCallBack(bmsg.block, requested || fromWhitelist)
+	isNewBlock, err := sm.ProcessBlockCallBack(bmsg.block, requested || fromWhitelist)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -670,6 +720,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		return
 	}
 
+	if isNewBlock {
+		peer.UpdateLastBlockTime(time.Now())
+	}
+
 	// Meta-data about the new block this peer is reporting. We use this
 	// below to update this peer's lastest block height and the heights of
 	// other peers based on their last announced block hash. This allows us
@@ -693,7 +747,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	blkHashUpdate = best.GetBlockHash()
 
 	// Clear the rejected transactions.
-	sm.rejectedTxns = make(map[util.Hash]struct{})
+	sm.rejectedTxns.Reset()
 
 	// Update the block height for this peer. But only send a message to
 	// the server for updating peer heights if this is an orphan or our
@@ -786,7 +840,7 @@ func (sm *SyncManager) fetchHeaderBlocks(peer *peer.Peer) {
 		return
 	}
 
-	if len(peerState.requestedBlocks) == MAX_BLOCKS_IN_TRANSIT_PER_PEER {
+	if peerState.stalling || len(peerState.requestedBlocks) == MAX_BLOCKS_IN_TRANSIT_PER_PEER {
 		return
 	}
 
@@ -872,8 +926,7 @@ out:
 				break out
 			}
 			iv := wire.NewInvVect(wire.InvTypeBlock, pindex.GetBlockHash())
-			sm.requestedBlocks[*pindex.GetBlockHash()] = peer
-			peerState.requestedBlocks[*pindex.GetBlockHash()] = struct{}{}
+			sm.requestBlock(peer, peerState, *pindex.GetBlockHash())
 			gdmsg.AddInvVect(iv)
 			if len(peerState.requestedBlocks) == MAX_BLOCKS_IN_TRANSIT_PER_PEER {
 				break out
@@ -935,9 +988,13 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
-	if canNotConnect := gChain.FindBlockIndex(headers[0].HashPrevBlock) == nil; canNotConnect {
-		sm.fetchHeadersToConnect(peer, state)
-		return
+	// Headers of a chain being presynced follow headers which are not in
+	// the block index.
+	if state.headersSync == nil {
+		if canNotConnect := gChain.FindBlockIndex(headers[0].HashPrevBlock) == nil; canNotConnect {
+			sm.fetchHeadersToConnect(peer, state)
+			return
+		}
 	}
 
 	if isContinuousHeaders := hmsg.headers.IsContinuousHeaders(); !isContinuousHeaders {
@@ -946,6 +1003,32 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
+	// A headers chain without enough work is presynced before being
+	// committed to the block index, so that a peer cannot fill it with
+	// cheap headers.
+	hasMore := len(headers) == wire.MaxBlockHeadersPerMsg
+	if state.headersSync == nil {
+		chainStart := gChain.FindBlockIndex(headers[0].HashPrevBlock)
+		minimumWork := antiDoSWorkThreshold()
+		if headersChainWork(chainStart, headers).Cmp(minimumWork) < 0 {
+			if !hasMore {
+				log.Info("Ignoring low-work chain of %d headers from peer %s", len(headers), peer.Addr())
+				return
+			}
+			log.Info("Starting headers presync with peer %s from height %d", peer.Addr(), chainStart.Height)
+			state.headersSync = newHeadersSyncState(chainStart, minimumWork, util.GetAdjustedTimeSec())
+		}
+	}
+	if state.headersSync != nil {
+		headers = sm.processHeadersSync(peer, state, headers, hasMore)
+		if len(headers) == 0 {
+			return
+		}
+		// the headers sync requests the next headers itself until it
+		// is done
+		hasMore = hasMore && state.headersSync == nil
+	}
+
 	peerTip := sm.updatePeerState(headers, peer, gChain)
 
 	var pindexLast blockindex.BlockIndex
@@ -960,7 +1043,6 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		state.unconnectingHeaders = 0
 	}
 
-	hasMore := len(headers) == wire.MaxBlockHeadersPerMsg
 	if hasMore && peer == sm.syncPeer {
 		blkIndex := gChain.FindBlockIndex(peerTip)
 		peer.PushGetHeadersMsg(*gChain.GetLocator(blkIndex), &zeroHash)
@@ -998,6 +1080,33 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	}
 }
 
+// processHeadersSync feeds headers to the headers sync of the peer, and
+// returns the headers which can be committed to the block index.
+func (sm *SyncManager) processHeadersSync(peer *peer.Peer, state *peerSyncState,
+	headers []*block.BlockHeader, hasMore bool) []*block.BlockHeader {
+
+	headersSync := state.headersSync
+	wasPresync := headersSync.phase == headersPresync
+	released, err := headersSync.processHeaders(headers, hasMore)
+	if err != nil {
+		log.Info("Aborting headers presync with peer %s: %v", peer.Addr(), err)
+		state.headersSync = nil
+		return nil
+	}
+
+	if headersSync.phase == headersSyncDone {
+		log.Info("Headers presync with peer %s done at height %d", peer.Addr(), headersSync.redownloadHeight)
+		state.headersSync = nil
+		return released
+	}
+	if wasPresync && headersSync.phase == headersRedownload {
+		log.Info("Headers presync with peer %s reached the required work at height %d, redownloading",
+			peer.Addr(), headersSync.height)
+	}
+	peer.PushGetHeadersMsg(*headersSync.locator(), &zeroHash)
+	return released
+}
+
 func (sm *SyncManager) updatePeerState(headers []*block.BlockHeader, peer *peer.Peer, gChain *chain.Chain) util.Hash {
 	for _, header := range headers {
 		peer.AddKnownInventory(&wire.InvVect{Type: wire.InvTypeBlock, Hash: header.GetHash()})
@@ -1025,8 +1134,7 @@ func (sm *SyncManager) fetchBlocks(vToFetch *list.List, state *peerSyncState, pe
 		iv := wire.NewInvVect(wire.InvTypeBlock, &hash)
 		gdmsg.AddInvVect(iv)
 
-		sm.requestedBlocks[hash] = peer
-		state.requestedBlocks[hash] = struct{}{}
+		sm.requestBlock(peer, state, hash)
 		log.Debug("Requesting block %s from peer=%d", hash.String(), peer.ID())
 	}
 
@@ -1072,6 +1180,11 @@ func (sm *SyncManager) haveInventory(invVect *wire.InvVect) bool {
 		return blkIndex != nil && blkIndex.HasData()
 
 	case wire.InvTypeTx:
+		// Transactions confirmed by the latest blocks are known even when
+		// their outputs are already spent.
+		if sm.recentConfirmedTxns.Exists(&invVect.Hash) {
+			return true
+		}
 		// Ask the transaction memory pool if the transaction is known
 		// to it in any form (main pool or orphan).
 		if lmempool.FindTxInMempool(invVect.Hash) != nil {
@@ -1136,6 +1249,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	}
 
 	var invBlkCnt int
+	now := time.Now()
 	// Request the advertised inventory if we don't already have it.  Also,
 	// request parent blocks of orphans if we receive one we already have.
 	// Finally, attempt to detect potential stalls due to long side chains
@@ -1154,14 +1268,21 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		peer.AddKnownInventory(iv)
 
 		if iv.Type == wire.InvTypeTx {
-			if lblock.IsInitialBlockDownload() {
+			if lblock.IsInitialBlockDownload() || conf.Cfg.P2PNet.BlocksOnly {
 				continue
 			}
 
 			// Skip the transaction if it has already been rejected.
-			if _, exists := sm.rejectedTxns[iv.Hash]; exists {
+			if sm.rejectedTxns.Exists(&iv.Hash) {
 				continue
 			}
+
+			// Schedule the transaction download if we don't already
+			// have it.
+			if !sm.haveInventory(iv) {
+				sm.txRequests.announce(peer, iv.Hash, isPreferredTxPeer(peer), now)
+			}
+			continue
 		}
 
 		// Request the inventory if we don't already have it.
@@ -1170,14 +1291,15 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		}
 	}
 
+	// Request the announced transactions which are due, from preferred
+	// peers right away.
+	sm.requestTxns(now)
+
 	log.Debug(
 		"invBlkCnt=%d len(invVects)=%d peer=%p(%s) sm.syncPeer=%p",
 		invBlkCnt, len(invVects), peer, peer.Addr(), sm.syncPeer)
 
-	// Request as much as possible at once.  Anything that won't fit into
-	// the request will be requested on the next inv message.
-	numRequested := 0
-	gdmsg := wire.NewMsgGetData()
+	// Request the headers leading to the announced blocks.
 	requestQueue := state.requestQueue
 	for len(requestQueue) != 0 {
 		iv := requestQueue[0]
@@ -1196,48 +1318,21 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 
 				peer.PushGetHeadersMsg(*locator, &iv.Hash)
 			}
-
-		case wire.InvTypeTx:
-			// Request the transaction if there is not already a
-			// pending request.
-			if _, exists := sm.requestedTxns[iv.Hash]; !exists {
-				limitMap(sm.requestedTxns, maxRequestedTxns)
-				sm.requestedTxns[iv.Hash] = struct{}{}
-
-				limitMap(state.requestedTxns, maxRequestedTxns)
-				state.requestedTxns[iv.Hash] = struct{}{}
-
-				gdmsg.AddInvVect(iv)
-				numRequested++
-			}
-		}
-
-		if numRequested >= wire.MaxInvPerMsg {
-			break
 		}
 	}
 
 	state.requestQueue = requestQueue
-	if len(gdmsg.InvList) > 0 {
-		peer.QueueMessage(gdmsg, nil)
-	}
 }
 
-// limitMap is a helper function for maps that require a maximum limit by
-// evicting a random transaction if adding a new value would cause it to
-// overflow the maximum allowed.
-func limitMap(m map[util.Hash]struct{}, limit int) {
-	if len(m)+1 > limit {
-		// Remove a random entry from the map.  For most compilers, Go's
-		// range statement iterates starting at a random item although
-		// that is not 100% guaranteed by the spec.  The iteration order
-		// is not important here because an adversary would have to be
-		// able to pull off preimage attacks on the hashing function in
-		// order to target eviction of specific entries anyways.
-		for txHash := range m {
-			delete(m, txHash)
-			return
+// requestTxns sends a getdata for the announced transactions which are due at
+// now, each to the single peer selected by the transaction request tracker.
+func (sm *SyncManager) requestTxns(now time.Time) {
+	for p, hashes := range sm.txRequests.requestable(now, sm.alreadyHave) {
+		gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+		for i := range hashes {
+			gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &hashes[i]))
 		}
+		p.QueueMessage(gdmsg, nil)
 	}
 }
 
@@ -1267,10 +1362,8 @@ func (sm *SyncManager) checkSyncHeaderOnce(p *peer.Peer, state *peerSyncState) {
 }
 
 func (sm *SyncManager) scanToFetchHeaderBlocks() {
-	for peer, state := range sm.peerStates {
-		if !state.syncCandidate {
-			continue
-		}
+	for _, peer := range sm.blockDownloadPeers() {
+		state := sm.peerStates[peer]
 
 		sm.checkSyncHeaderOnce(peer, state)
 
@@ -1282,9 +1375,7 @@ func (sm *SyncManager) scanToFetchHeaderBlocks() {
 			// During normal steady state, the download window should be much larger
 			// than the to-be-downloaded set of blocks, so disconnection should only
 			// happen during initial block download.
-			log.Info("Peer(%d)%s is stalling block download, disconnecting",
-				peer.ID(), peer.Addr())
-			peer.Disconnect()
+			sm.stallPeer(peer)
 			continue
 		}
 
@@ -1304,6 +1395,10 @@ func (sm *SyncManager) scanToFetchHeaderBlocks() {
 func (sm *SyncManager) messagesHandler() {
 	fetchTicker := time.NewTicker(fetchInterval)
 	defer fetchTicker.Stop()
+	txRequestTicker := time.NewTicker(txRequestInterval)
+	defer txRequestTicker.Stop()
+	stallTicker := time.NewTicker(stallCheckInterval)
+	defer stallTicker.Stop()
 out:
 	for {
 		select {
@@ -1312,6 +1407,12 @@ out:
 			sm.checkIBDHeadersSync()
 			sm.scanToFetchHeaderBlocks()
 
+		case now := <-txRequestTicker.C:
+			sm.requestTxns(now)
+
+		case now := <-stallTicker.C:
+			sm.checkWindowStall(now)
+
 		//business msg
 		case m := <-sm.processBusinessChan:
 			switch msg := m.(type) {
@@ -1388,6 +1489,11 @@ func (sm *SyncManager) handleBlockchainNotification(notification *chain.Notifica
 			panic("TipUpdatedEvent: malformed event payload")
 		}
 
+		// We are catching up during initial block download, our peers
+		// know the blocks we connect better than we do.
+		if event.IsInitialDownload {
+			break
+		}
 		sm.peerNotifier.RelayUpdatedTipBlocks(event)
 
 	// A block has been accepted into the block chain.  Relay it to other peers.
@@ -1397,6 +1503,9 @@ func (sm *SyncManager) handleBlockchainNotification(notification *chain.Notifica
 			log.Warn("Chain accepted notification is not a block.")
 			break
 		}
+		if lblock.IsInitialBlockDownload() {
+			break
+		}
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, &block.Header.Hash)
@@ -1414,15 +1523,28 @@ func (sm *SyncManager) handleBlockchainNotification(notification *chain.Notifica
 		// connected block from the transaction pool.  Secondly, remove any
 		// transactions which are now double spends as a result of these
 		// new transactions.  Finally, remove any transaction that is
-		// no longer an orphan. Transactions which depend on a confirmed
+		// no longer an orphan, and accept the orphans whose parents the
+		// block confirmed. Transactions which depend on a confirmed
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		lmempool.RemoveTxSelf(block.Txs[1:])
 		for _, tx := range block.Txs[1:] {
-			// TODO: add it back when rcp command @SendRawTransaction is ready for broadcasting tx
-			// sm.peerNotifier.TransactionConfirmed(tx)
-
-			lmempool.TryAcceptOrphansTxs(tx, chain.GetInstance().Height(), true)
+			sm.recentConfirmedTxns.Add(tx.GetHash())
+		}
+		// TODO: add it back when rcp command @SendRawTransaction is ready for broadcasting tx
+		// for _, tx := range block.Txs[1:] {
+		// 	sm.peerNotifier.TransactionConfirmed(tx)
+		// }
+
+		acceptTxs := lmempool.ProcessOrphansForBlock(block.Txs[1:], chain.GetInstance().Height())
+		txentrys := make([]*mempool.TxEntry, 0, len(acceptTxs))
+		for _, tx := range acceptTxs {
+			if entry := lmempool.FindTxInMempool(tx.GetHash()); entry != nil {
+				txentrys = append(txentrys, entry)
+			}
+		}
+		if len(txentrys) > 0 && !lblock.IsInitialBlockDownload() {
+			sm.peerNotifier.AnnounceNewTransactions(txentrys)
 		}
 
 		// Register block with the fee estimator, if it exists.
@@ -1447,6 +1569,9 @@ func (sm *SyncManager) handleBlockchainNotification(notification *chain.Notifica
 			break
 		}
 
+		// The transactions of the disconnected block are unconfirmed again.
+		sm.recentConfirmedTxns.Reset()
+
 		// Rollback previous block recorded by the fee estimator.
 		//if sm.feeEstimator != nil {
 		//	sm.feeEstimator.Rollback(&block.Header.Hash)
@@ -1619,9 +1744,11 @@ func New(config *Config) (*SyncManager, error) {
 	sm := SyncManager{
 		peerNotifier:        config.PeerNotifier,
 		chainParams:         config.ChainParams,
-		rejectedTxns:        make(map[util.Hash]struct{}),
-		requestedTxns:       make(map[util.Hash]struct{}),
+		rejectedTxns:        newRollingHashSet(maxRejectedTxns),
+		recentConfirmedTxns: newRollingHashSet(maxRecentConfirmedTxns),
+		txRequests:          newTxRequestTracker(),
 		requestedBlocks:     make(map[util.Hash]*peer.Peer),
+		windowFrontTimeout:  defaultWindowFrontTimeout,
 		peerStates:          make(map[*peer.Peer]*peerSyncState),
 		progressLogger:      newBlockProgressLogger("Processed", log.GetLogger()),
 		processBusinessChan: make(chan interface{}, config.MaxPeers*3),
@@ -4,20 +4,27 @@ import (
 	"bytes"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
 	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lblock"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
 	"github.com/copernet/copernicus/model/blockindex"
 	"github.com/copernet/copernicus/model/chain"
 	"github.com/copernet/copernicus/model/mempool"
 	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/model/pow"
 	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
 	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/net/wire"
 	"github.com/copernet/copernicus/peer"
@@ -27,8 +34,10 @@ import (
 	"github.com/copernet/copernicus/service"
 	"github.com/copernet/copernicus/service/mining"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
@@ -52,6 +61,31 @@ func (m *mockPeerNotifier) RelayInventory(invVect *wire.InvVect, data interface{
 func (m *mockPeerNotifier) RelayUpdatedTipBlocks(event *chain.TipUpdatedEvent)     {}
 func (m *mockPeerNotifier) TransactionConfirmed(tx *tx.Tx)                         {}
 
+// relayRecorder records the blocks and transactions announced to peers.
+type relayRecorder struct {
+	mockPeerNotifier
+	blocks []util.Hash
+	txns   []util.Hash
+}
+
+func (r *relayRecorder) AnnounceNewTransactions(newTxs []*mempool.TxEntry) {
+	for _, entry := range newTxs {
+		r.txns = append(r.txns, entry.Tx.GetHash())
+	}
+}
+
+func (r *relayRecorder) RelayInventory(invVect *wire.InvVect, data interface{}) {
+	if invVect.Type == wire.InvTypeBlock {
+		r.blocks = append(r.blocks, invVect.Hash)
+	}
+}
+
+func (r *relayRecorder) RelayUpdatedTipBlocks(event *chain.TipUpdatedEvent) {
+	for index := event.TipIndex; index != event.ForkIndex; index = index.Prev {
+		r.blocks = append(r.blocks, *index.GetBlockHash())
+	}
+}
+
 //func appInitMain(args []string) {
 //	conf.Cfg = conf.InitConfig(args)
 //	if conf.Cfg == nil {
@@ -111,6 +145,7 @@ func (m *mockPeerNotifier) TransactionConfirmed(tx *tx.Tx)
 
 var initLock sync.Mutex
 var once sync.Once
+var scriptVerifyOnce sync.Once
 
 func makeSyncManager() (*SyncManager, error) {
 	mp := mockPeerNotifier{}
@@ -163,7 +198,8 @@ func TestSyncPeerID(t *testing.T) {
 }
 
 func TestIsCurrent(t *testing.T) {
-	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	// the regtest genesis is only too old for the default maxtipage
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--maxtipage=86400"})
 	defer os.RemoveAll(testDir)
 	defer cleanup()
 
@@ -391,28 +427,6 @@ func TestSyncManager_handleBlockchainNotification(t *testing.T) {
 	sm.Stop()
 }
 
-func TestSyncManager_limitMap(t *testing.T) {
-	testDir, _ := initTestEnv(t, []string{"--regtest"})
-	defer os.RemoveAll(testDir)
-	defer cleanup()
-
-	sm, err := makeSyncManager()
-	if err != nil {
-		t.Fatalf("construct syncmanager failed :%v\n", err)
-	}
-	sm.Start()
-
-	hash1 := util.HashFromString("00000000000001bcd6b635a1249dfbe76c0d001592a7219a36cd9bbd002c7238")
-	hash2 := util.HashFromString("00000000000001bcd6b635a1249dfbe76c0d001592a7219a36cd9bbd002c7239")
-	mp := make(map[util.Hash]struct{})
-	mp[*hash1] = struct{}{}
-	mp[*hash2] = struct{}{}
-	assert.Equal(t, len(mp), 2)
-	limitMap(mp, 2)
-	assert.Equal(t, len(mp), 1)
-	sm.Stop()
-}
-
 func TestSyncManager_findNextHeaderCheckpoint(t *testing.T) {
 	testDir, _ := initTestEnv(t, []string{"--regtest"})
 	defer os.RemoveAll(testDir)
@@ -549,9 +563,6 @@ func getpeerState() *peerSyncState {
 	msgInv := wire.NewMsgInv()
 	msgInv.AddInvVect(invVect1)
 
-	requestedTxns := make(map[util.Hash]struct{})
-	requestedTxns[*hash1] = struct{}{}
-
 	requestedBlocks := make(map[util.Hash]struct{})
 	requestedBlocks[*hash1] = struct{}{}
 
@@ -559,7 +570,6 @@ func getpeerState() *peerSyncState {
 	syncState := &peerSyncState{
 		syncCandidate:   true,
 		requestQueue:    msgInv.InvList,
-		requestedTxns:   requestedTxns,
 		requestedBlocks: requestedBlocks,
 	}
 	return syncState
@@ -638,9 +648,7 @@ func TestSyncManager_alreadyHave(t *testing.T) {
 	ret := sm.alreadyHave(hash1)
 	assert.Equal(t, ret, false)
 
-	rejectedTxns := make(map[util.Hash]struct{})
-	rejectedTxns[*hash1] = struct{}{}
-	sm.rejectedTxns = rejectedTxns
+	sm.rejectedTxns.Add(*hash1)
 	ret = sm.alreadyHave(hash1)
 	assert.Equal(t, ret, true)
 	sm.Stop()
@@ -798,7 +806,6 @@ func TestSyncManager_fetchHeaderBlocks(t *testing.T) {
 	msgInv := wire.NewMsgInv()
 	msgInv.AddInvVect(invVect1)
 
-	requestedTxns := make(map[util.Hash]struct{})
 	requestedBlocks := make(map[util.Hash]struct{})
 	for i := 0; i < MAX_BLOCKS_IN_TRANSIT_PER_PEER; i++ {
 		requestedBlocks[*util.HashFromString(strconv.Itoa(i))] = struct{}{}
@@ -807,7 +814,6 @@ func TestSyncManager_fetchHeaderBlocks(t *testing.T) {
 	syncState := &peerSyncState{
 		syncCandidate:   true,
 		requestQueue:    msgInv.InvList,
-		requestedTxns:   requestedTxns,
 		requestedBlocks: requestedBlocks,
 	}
 
@@ -836,6 +842,227 @@ func TestSyncManager_fetchHeaderBlocks(t *testing.T) {
 	sm.Stop()
 }
 
+func fetchWithMinChainWork(t *testing.T, minChainWork *big.Int) int {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	conf.Args.MinimumChainWork = fmt.Sprintf("%064x", minChainWork)
+	pow.UpdateMinimumChainWork()
+	defer func() {
+		conf.Args.MinimumChainWork = ""
+		pow.UpdateMinimumChainWork()
+	}()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	sm.chainParams = &model.RegressionNetParams
+
+	initBlkIdx()
+	gChain := chain.GetInstance()
+	gChain.SetTip(gChain.FindBlockIndex(*gChain.GetParams().GenesisHash))
+	best := gChain.GetIndexBestHeader()
+
+	p, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	p.SetAckReceived(true)
+	p.UpdateLastAnnouncedBlock(best.GetBlockHash())
+	sm.peerStates[p] = &peerSyncState{
+		syncCandidate:   true,
+		requestedBlocks: make(map[util.Hash]struct{}),
+	}
+	sm.requestedBlocks = make(map[util.Hash]*peer.Peer)
+
+	sm.fetchHeaderBlocks(p)
+	return len(sm.peerStates[p].requestedBlocks)
+}
+
+func TestSyncManager_fetchHeaderBlocksMinChainWork(t *testing.T) {
+	// The regtest header chain built by initBlkIdx carries a few units of
+	// work, far below this threshold.
+	threshold := new(big.Int).Lsh(big.NewInt(1), 64)
+	assert.Equal(t, 0, fetchWithMinChainWork(t, threshold),
+		"headers below minimum chain work must not trigger block download")
+
+	assert.NotEqual(t, 0, fetchWithMinChainWork(t, big.NewInt(1)),
+		"headers above minimum chain work must trigger block download")
+}
+
+func TestSyncManager_blockDownloadStalling(t *testing.T) {
+	// the regtest blocks are only too old for the default maxtipage
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--maxtipage=86400"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	sm.chainParams = &model.RegressionNetParams
+
+	initBlkIdx()
+	gChain := chain.GetInstance()
+	best := gChain.GetIndexBestHeader()
+
+	// the front of the window is the lowest block we miss
+	var front util.Hash
+	for index := best; index.Prev != nil; index = index.Prev {
+		if !index.HasData() {
+			front = *index.GetBlockHash()
+			gChain.SetTip(index.Prev)
+		}
+	}
+
+	staller, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	other, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:124", false)
+	for _, p := range []*peer.Peer{staller, other} {
+		p.SetAckReceived(true)
+		p.UpdateLastAnnouncedBlock(best.GetBlockHash())
+		sm.peerStates[p] = &peerSyncState{
+			syncCandidate:   true,
+			requestedBlocks: make(map[util.Hash]struct{}),
+		}
+	}
+	sm.requestedBlocks = make(map[util.Hash]*peer.Peer)
+
+	// the whole window is assigned to the first peer
+	sm.fetchHeaderBlocks(staller)
+	sm.fetchHeaderBlocks(other)
+	if p := sm.requestedBlocks[front]; p != staller {
+		t.Fatalf("front block requested from %v, want the stalling peer", p)
+	}
+	assert.Equal(t, 0, len(sm.peerStates[other].requestedBlocks))
+
+	if !lblock.IsInitialBlockDownload() {
+		t.Fatal("not in initial block download")
+	}
+	now := time.Now()
+	sm.checkWindowStall(now)
+	sm.checkWindowStall(now.Add(defaultWindowFrontTimeout / 2))
+	if p := sm.requestedBlocks[front]; p != staller {
+		t.Fatalf("front block reassigned to %v before the timeout", p)
+	}
+
+	// the staller is disconnected and its blocks requested from the other peer
+	sm.checkWindowStall(now.Add(defaultWindowFrontTimeout))
+	if p := sm.requestedBlocks[front]; p != other {
+		t.Errorf("front block requested from %v, want the other peer", p)
+	}
+	assert.Equal(t, 0, len(sm.peerStates[staller].requestedBlocks))
+	assert.True(t, sm.peerStates[staller].stalling)
+
+	// the next peer gets twice as long, in case our link is the slow one
+	assert.Equal(t, 2*defaultWindowFrontTimeout, sm.windowFrontTimeout)
+	for i := 0; i < 10; i++ {
+		sm.blockDelivered()
+	}
+	assert.Equal(t, defaultWindowFrontTimeout, sm.windowFrontTimeout)
+
+	disconnected := make(chan struct{})
+	go func() {
+		staller.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Error("stalling peer not disconnected")
+	}
+
+	// the staller is not assigned blocks anymore
+	sm.fetchHeaderBlocks(staller)
+	assert.Equal(t, 0, len(sm.peerStates[staller].requestedBlocks))
+}
+
+func TestSyncManager_blockDownloadStallingSinglePeer(t *testing.T) {
+	// the regtest blocks are only too old for the default maxtipage
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--maxtipage=86400"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	sm.chainParams = &model.RegressionNetParams
+
+	initBlkIdx()
+	gChain := chain.GetInstance()
+	best := gChain.GetIndexBestHeader()
+	var front util.Hash
+	for index := best; index.Prev != nil; index = index.Prev {
+		if !index.HasData() {
+			front = *index.GetBlockHash()
+			gChain.SetTip(index.Prev)
+		}
+	}
+
+	only, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	only.SetAckReceived(true)
+	only.UpdateLastAnnouncedBlock(best.GetBlockHash())
+	sm.peerStates[only] = &peerSyncState{
+		syncCandidate:   true,
+		requestedBlocks: make(map[util.Hash]struct{}),
+	}
+	sm.requestedBlocks = make(map[util.Hash]*peer.Peer)
+	sm.fetchHeaderBlocks(only)
+	if p := sm.requestedBlocks[front]; p != only {
+		t.Fatalf("front block requested from %v, want the only peer", p)
+	}
+
+	// a slow peer is kept when no other peer could serve the block
+	now := time.Now()
+	sm.checkWindowStall(now)
+	sm.checkWindowStall(now.Add(2 * defaultWindowFrontTimeout))
+	if p := sm.requestedBlocks[front]; p != only {
+		t.Errorf("front block requested from %v, want the only peer", p)
+	}
+	assert.False(t, sm.peerStates[only].stalling)
+	assert.Equal(t, defaultWindowFrontTimeout, sm.windowFrontTimeout)
+}
+
+func TestSyncManager_blockDownloadPeers(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+
+	slow, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	fast, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:124", false)
+	unknown, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:125", false)
+	stalling, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:126", false)
+	for _, p := range []*peer.Peer{slow, fast, unknown, stalling} {
+		sm.peerStates[p] = &peerSyncState{
+			syncCandidate:   true,
+			requestedBlocks: make(map[util.Hash]struct{}),
+		}
+	}
+	sm.peerStates[stalling].stalling = true
+
+	// a peer delivering blocks faster is preferred
+	now := time.Now()
+	for _, p := range []*peer.Peer{slow, fast} {
+		sm.peerStates[p].downloadingSince = now
+	}
+	sm.peerStates[slow].recordBlockDownload(now.Add(4 * time.Second))
+	sm.peerStates[fast].recordBlockDownload(now.Add(time.Second))
+	assert.Equal(t, []*peer.Peer{fast, slow, unknown}, sm.blockDownloadPeers())
+
+	// the average follows the latest deliveries
+	sm.peerStates[fast].recordBlockDownload(now.Add(22 * time.Second))
+	assert.Equal(t, 6*time.Second, sm.peerStates[fast].blockDownloadTime)
+	assert.Equal(t, []*peer.Peer{slow, fast, unknown}, sm.blockDownloadPeers())
+}
+
 func TestSyncManager_updateTxRequestState(t *testing.T) {
 	testDir, _ := initTestEnv(t, []string{"--regtest"})
 	defer os.RemoveAll(testDir)
@@ -851,12 +1078,12 @@ func TestSyncManager_updateTxRequestState(t *testing.T) {
 	sm.peerStates[inpeer] = syncState
 	hash1 := util.HashFromString("00000000000001bcd6b635a1249dfbe76c0d001592a7219a36cd9bbd002c7238")
 
-	sm.rejectedTxns[*hash1] = struct{}{}
+	sm.rejectedTxns.Add(*hash1)
 
 	rejectedTxns := make([]util.Hash, 0)
 	rejectedTxns = append(rejectedTxns, *hash1)
 
-	sm.updateTxRequestState(syncState, *hash1, rejectedTxns)
+	sm.updateTxRequestState(*hash1, rejectedTxns)
 	sm.Stop()
 }
 
@@ -918,7 +1145,7 @@ func TestSyncManager_handleBlockMsg(t *testing.T) {
 
 	sm.ProcessBlockCallBack = service.ProcessBlock
 	sm.ProcessBlockHeadCallBack = service.ProcessBlockHeader
-	sm.ProcessTransactionCallBack = service.ProcessTransaction
+	sm.ProcessTransactionCallBack = service.ProcessPeerTransaction
 
 	// blk1 is rejected block
 	blk1 := getBlock(blk1str)
@@ -960,12 +1187,12 @@ func TestSyncManager_handleBlockMsg(t *testing.T) {
 	sm.Stop()
 }
 
-func ProcessTxAcceptAll(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
+func ProcessTxAcceptAll(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64, bypassFee bool) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
 	acceptedTxs := []*tx.Tx{txn}
 	return acceptedTxs, nil, nil, nil
 }
 
-func ProcessTxReturnErr(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
+func ProcessTxReturnErr(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64, bypassFee bool) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
 	return nil, nil, nil, errors.New("test error")
 }
 
@@ -980,7 +1207,7 @@ func TestSyncManager_handleTxMsg(t *testing.T) {
 	}
 	sm.ProcessBlockCallBack = service.ProcessBlock
 	sm.ProcessBlockHeadCallBack = service.ProcessBlockHeader
-	sm.ProcessTransactionCallBack = service.ProcessTransaction
+	sm.ProcessTransactionCallBack = service.ProcessPeerTransaction
 
 	tmpTX := tx.NewTx(0x01, 0x02)
 	inpeer := peer.NewInboundPeer(peer1Cfg, false)
@@ -1002,11 +1229,337 @@ func TestSyncManager_handleTxMsg(t *testing.T) {
 	assert.NotPanics(t, func() { sm.handleTxMsg(tmsg) })
 
 	mempool.GetInstance().RemoveOrphansByTag(int64(inpeer.ID()))
-	sm.ProcessTransactionCallBack = service.ProcessTransaction
+	sm.ProcessTransactionCallBack = service.ProcessPeerTransaction
 	assert.NotPanics(t, func() { sm.handleTxMsg(tmsg) })
 	sm.Stop()
 }
 
+func TestSyncManager_rejectedTxNotRerequested(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	sm.ProcessBlockCallBack = service.ProcessBlock
+	sm.ProcessBlockHeadCallBack = service.ProcessBlockHeader
+	var banScore uint32
+	sm.AddBanScoreCallBack = func(addr string, persistent, transient uint32, reason string) {
+		banScore += persistent + transient
+	}
+
+	// transactions are only requested once out of initial block download
+	if _, err := generateBlocks(t, 1, 10000, true); err != nil {
+		t.Fatalf("generate block failed: %v", err)
+	}
+	if lblock.IsInitialBlockDownload() {
+		t.Fatal("still in initial block download")
+	}
+
+	inpeer := peer.NewInboundPeer(peer1Cfg, false)
+	sm.peerStates[inpeer] = getpeerState()
+	sm.syncPeer = inpeer
+
+	txn := tx.NewTx(0x01, 0x02)
+	txHash := txn.GetHash()
+	sm.ProcessTransactionCallBack = func(*tx.Tx, map[util.Hash]struct{}, int64, bool) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
+		return nil, nil, []util.Hash{txHash}, errors.New("test reject")
+	}
+	sm.handleTxMsg(&txMsg{tx: txn, peer: inpeer})
+	if !sm.rejectedTxns.Exists(&txHash) {
+		t.Fatal("rejected transaction was not recorded")
+	}
+
+	announce := func() bool {
+		msgInv := wire.NewMsgInv()
+		msgInv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &txHash))
+		sm.handleInvMsg(&invMsg{inv: msgInv, peer: inpeer})
+		_, scheduled := sm.txRequests.requests[txHash]
+		return scheduled
+	}
+	if announce() {
+		t.Error("recently rejected transaction was requested again")
+	}
+
+	// resending the rejected transaction is only scored lightly
+	sm.handleTxMsg(&txMsg{tx: txn, peer: inpeer})
+	if banScore != rejectedTxBanScore {
+		t.Errorf("resending a rejected transaction scored %d, want %d", banScore, rejectedTxBanScore)
+	}
+
+	// a new block clears the rejected transactions
+	blks, err := generateBlocks(t, 1, 10000, false)
+	if err != nil || len(blks) != 1 {
+		t.Fatalf("generate block failed: %v", err)
+	}
+	headerMsg := wire.NewMsgHeaders()
+	if err := headerMsg.AddBlockHeader(&blks[0].Header); err != nil {
+		t.Fatal(err)
+	}
+	sm.handleHeadersMsg(&headersMsg{headers: headerMsg, peer: inpeer})
+	sm.handleBlockMsg(&blockMsg{block: blks[0], buf: make([]byte, 10), peer: inpeer})
+	if *chain.GetInstance().Tip().GetBlockHash() != blks[0].GetHash() {
+		t.Fatal("new block was not connected")
+	}
+	if sm.rejectedTxns.Len() != 0 {
+		t.Errorf("%d rejected transactions survived a new block", sm.rejectedTxns.Len())
+	}
+	if !announce() {
+		t.Error("transaction was not requested after a new block")
+	}
+}
+
+func TestSyncManager_confirmedTxNotRerequested(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	scriptVerifyOnce.Do(ltx.ScriptVerifyInit)
+
+	// mature the coinbase of the first block
+	blks, err := generateBlocks(t, int(model.ActiveNetParams.CoinbaseMaturity)+1, 10000, true)
+	if err != nil {
+		t.Fatalf("generate blocks failed: %v", err)
+	}
+	if lblock.IsInitialBlockDownload() {
+		t.Fatal("still in initial block download")
+	}
+
+	// the transaction is spent in the same block, so it leaves no coin in
+	// the UTXO set once confirmed
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	spend := func(prevOut *outpoint.OutPoint, value amount.Amount) *tx.Tx {
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(prevOut, script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(value, opTrue))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		if _, _, _, err := service.ProcessTransaction(txn, make(map[util.Hash]struct{}), 0); err != nil {
+			t.Fatalf("transaction not accepted: %v", err)
+		}
+		return txn
+	}
+	coinbase := blks[0].Txs[0]
+	txn := spend(outpoint.NewOutPoint(coinbase.GetHash(), 0), coinbase.GetTxOut(0).GetValue()-10000)
+	spend(outpoint.NewOutPoint(txn.GetHash(), 0), coinbase.GetTxOut(0).GetValue()-20000)
+	txHash := txn.GetHash()
+
+	confirmed, err := generateBlocks(t, 1, 10000, true)
+	if err != nil || len(confirmed) != 1 || len(confirmed[0].Txs) != 3 {
+		t.Fatalf("transaction not confirmed: %v", err)
+	}
+	if lmempool.FindTxInMempool(txHash) != nil {
+		t.Fatal("confirmed transaction is still in the mempool")
+	}
+
+	inpeer := peer.NewInboundPeer(peer1Cfg, false)
+	sm.peerStates[inpeer] = getpeerState()
+	msgInv := wire.NewMsgInv()
+	msgInv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &txHash))
+	sm.handleInvMsg(&invMsg{inv: msgInv, peer: inpeer})
+	if _, scheduled := sm.txRequests.requests[txHash]; scheduled {
+		t.Error("recently confirmed transaction was requested")
+	}
+
+	// once the block is disconnected the transaction is not known anymore
+	sm.handleBlockchainNotification(&chain.Notification{Type: chain.NTBlockDisconnected, Data: confirmed[0]})
+	sm.handleInvMsg(&invMsg{inv: msgInv, peer: inpeer})
+	if _, scheduled := sm.txRequests.requests[txHash]; !scheduled {
+		t.Error("transaction of a disconnected block was not requested")
+	}
+}
+
+func TestSyncManager_txRequestPreferredPeer(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+
+	// transactions are only requested once out of initial block download
+	if _, err := generateBlocks(t, 1, 10000, true); err != nil {
+		t.Fatalf("generate block failed: %v", err)
+	}
+
+	inpeer := peer.NewInboundPeer(peer1Cfg, false)
+	sm.peerStates[inpeer] = getpeerState()
+	outpeer, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	sm.peerStates[outpeer] = getpeerState()
+
+	txHash := tx.NewTx(0x01, 0x02).GetHash()
+	msgInv := wire.NewMsgInv()
+	msgInv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &txHash))
+
+	// the inbound peer announces first, but is only asked after a delay
+	sm.handleInvMsg(&invMsg{inv: msgInv, peer: inpeer})
+	if p := sm.txRequests.requestedFrom(txHash); p != nil {
+		t.Fatalf("transaction requested from inbound peer %v without delay", p)
+	}
+	sm.handleInvMsg(&invMsg{inv: msgInv, peer: outpeer})
+	if p := sm.txRequests.requestedFrom(txHash); p != outpeer {
+		t.Fatalf("transaction requested from %v, want the outbound peer", p)
+	}
+
+	// a single request is in flight once the inbound delay elapsed
+	sm.requestTxns(time.Now().Add(nonPreferredPeerTxDelay))
+	assert.Equal(t, 1, sm.txRequests.inFlight[outpeer])
+	assert.Equal(t, 0, sm.txRequests.inFlight[inpeer])
+
+	// the inbound peer is asked once the outbound peer is gone
+	sm.clearSyncPeerState(outpeer)
+	sm.requestTxns(time.Now().Add(nonPreferredPeerTxDelay))
+	if p := sm.txRequests.requestedFrom(txHash); p != inpeer {
+		t.Errorf("transaction requested from %v, want the inbound peer", p)
+	}
+}
+
+func TestSyncManager_blocksOnlyTxInv(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--blocksonly"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+
+	if _, err := generateBlocks(t, 1, 10000, true); err != nil {
+		t.Fatalf("generate block failed: %v", err)
+	}
+
+	outpeer, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	sm.peerStates[outpeer] = getpeerState()
+
+	txHash := tx.NewTx(0x01, 0x02).GetHash()
+	msgInv := wire.NewMsgInv()
+	msgInv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &txHash))
+	sm.handleInvMsg(&invMsg{inv: msgInv, peer: outpeer})
+	sm.requestTxns(time.Now().Add(nonPreferredPeerTxDelay))
+	if _, scheduled := sm.txRequests.requests[txHash]; scheduled {
+		t.Error("transaction requested in blocksonly mode")
+	}
+	assert.Equal(t, 0, sm.txRequests.inFlight[outpeer])
+}
+
+func TestSyncManager_headersPresync(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	sm, err := makeSyncManager()
+	if err != nil {
+		t.Fatalf("construct syncmanager failed :%v\n", err)
+	}
+	defer sm.Stop()
+	sm.ProcessBlockHeadCallBack = service.ProcessBlockHeader
+
+	gChain := chain.GetInstance()
+	genesis := gChain.Tip()
+	const chainLength = 5 * wire.MaxBlockHeadersPerMsg
+	headers := makeHeadersChain(genesis.GetBlockHeader(), chainLength)
+
+	// only the whole chain has enough work
+	conf.Args.MinimumChainWork = fmt.Sprintf("%064x", headersChainWork(genesis, headers))
+	pow.UpdateMinimumChainWork()
+	defer func() {
+		conf.Args.MinimumChainWork = ""
+		pow.UpdateMinimumChainWork()
+	}()
+
+	p, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
+	sm.peerStates[p] = getpeerState()
+	sm.syncPeer = p
+	indexSize := gChain.IndexMapSize()
+
+	send := func(i int) {
+		msg := wire.NewMsgHeaders()
+		for _, header := range headers[i : i+wire.MaxBlockHeadersPerMsg] {
+			msg.AddBlockHeader(header)
+		}
+		sm.handleHeadersMsg(&headersMsg{headers: msg, peer: p})
+	}
+
+	for i := 0; i < chainLength; i += wire.MaxBlockHeadersPerMsg {
+		send(i)
+		if size := gChain.IndexMapSize(); size != indexSize {
+			t.Fatalf("block index grew to %d entries during presync", size)
+		}
+	}
+	headersSync := sm.peerStates[p].headersSync
+	if headersSync == nil || headersSync.phase != headersRedownload {
+		t.Fatal("presync did not reach the required work")
+	}
+
+	// the chain is committed once redownloaded
+	for i := 0; i < chainLength-wire.MaxBlockHeadersPerMsg; i += wire.MaxBlockHeadersPerMsg {
+		send(i)
+		if size := gChain.IndexMapSize(); size != indexSize {
+			t.Fatalf("block index grew to %d entries before the redownload completed", size)
+		}
+	}
+	send(chainLength - wire.MaxBlockHeadersPerMsg)
+	if sm.peerStates[p].headersSync != nil {
+		t.Error("headers sync still in progress")
+	}
+	if size := gChain.IndexMapSize(); size != indexSize+chainLength {
+		t.Errorf("expected %d block index entries, got %d", indexSize+chainLength, size)
+	}
+	if best := gChain.GetIndexBestHeader(); *best.GetBlockHash() != headers[chainLength-1].GetHash() {
+		t.Errorf("best header is at height %d", best.Height)
+	}
+}
+
+func TestRollingHashSet(t *testing.T) {
+	set := newRollingHashSet(2)
+	hashes := []util.Hash{{1}, {2}, {3}}
+
+	set.Add(hashes[0])
+	set.Add(hashes[1])
+	set.Add(hashes[0])
+	if set.Len() != 2 {
+		t.Fatalf("expected 2 hashes, got %d", set.Len())
+	}
+
+	// the oldest hash is evicted first
+	set.Add(hashes[2])
+	if set.Exists(&hashes[0]) || !set.Exists(&hashes[1]) || !set.Exists(&hashes[2]) {
+		t.Errorf("expected %v to be evicted", hashes[0])
+	}
+
+	filtered := set.Filter(hashes)
+	if len(filtered) != 2 {
+		t.Errorf("expected 2 hashes filtered, got %d", len(filtered))
+	}
+	if _, ok := filtered[hashes[0]]; ok {
+		t.Errorf("expected %v not to be filtered", hashes[0])
+	}
+
+	set.Reset()
+	if set.Len() != 0 || set.Exists(&hashes[1]) {
+		t.Error("expected no hashes after a reset")
+	}
+	set.Add(hashes[0])
+	if !set.Exists(&hashes[0]) {
+		t.Error("expected a hash to be added after a reset")
+	}
+}
+
 func generateBlocks(t *testing.T, generate int, maxTries uint64, verify bool) ([]*block.Block, error) {
 	const nInnerLoopCount = 0x100000
 	scriptPubKey := script.NewEmptyScript()
@@ -1141,6 +1694,62 @@ func cleanup() {
 	}
 }
 
+func TestSyncManager_noRelayDuringIBD(t *testing.T) {
+	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	defer os.RemoveAll(testDir)
+	defer cleanup()
+
+	// the tip lacks the minimum chain work, so the node stays in initial
+	// block download while it connects blocks
+	conf.Args.MinimumChainWork = fmt.Sprintf("%064x", new(big.Int).Lsh(big.NewInt(1), 64))
+	pow.UpdateMinimumChainWork()
+	defer func() {
+		conf.Args.MinimumChainWork = ""
+		pow.UpdateMinimumChainWork()
+	}()
+	chain.GetInstance().SyncingState = &chain.SyncingState{}
+
+	recorder := &relayRecorder{}
+	sm, err := New(&Config{
+		PeerNotifier: recorder,
+		ChainParams:  model.ActiveNetParams,
+		MaxPeers:     8,
+	})
+	assert.Nil(t, err)
+	defer sm.Stop()
+	sm.ProcessTransactionCallBack = func(*tx.Tx, map[util.Hash]struct{}, int64, bool) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
+		t.Error("transaction processed during initial block download")
+		return nil, nil, nil, nil
+	}
+
+	blks, err := generateBlocks(t, 2, 10000, true)
+	if err != nil {
+		t.Fatalf("generate blocks failed: %v", err)
+	}
+	if !lblock.IsInitialBlockDownload() {
+		t.Fatal("left initial block download without the minimum chain work")
+	}
+	assert.Empty(t, recorder.blocks, "blocks announced during initial block download")
+
+	// transactions received during initial block download are dropped
+	p := peer.NewInboundPeer(peer1Cfg, false)
+	sm.peerStates[p] = getpeerState()
+	sm.handleTxMsg(&txMsg{tx: tx.NewTx(0x01, 0x02), peer: p})
+	assert.Empty(t, recorder.txns, "transactions relayed during initial block download")
+
+	// blocks are announced once out of initial block download
+	conf.Args.MinimumChainWork = ""
+	pow.UpdateMinimumChainWork()
+	blks, err = generateBlocks(t, 1, 10000, true)
+	if err != nil {
+		t.Fatalf("generate blocks failed: %v", err)
+	}
+	if lblock.IsInitialBlockDownload() {
+		t.Fatal("still in initial block download")
+	}
+	assert.Equal(t, []util.Hash{blks[0].GetHash()}, recorder.blocks)
+}
+
 func TestSyncManager_NewPeer(t *testing.T) {
 	testDir, _ := initTestEnv(t, []string{"--regtest"})
 	defer os.RemoveAll(testDir)
@@ -1218,7 +1827,8 @@ func TestSyncManager_NewPeer(t *testing.T) {
 }
 
 func TestSyncManager_Current(t *testing.T) {
-	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	// the regtest genesis is only too old for the default maxtipage
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--maxtipage=86400"})
 	defer os.RemoveAll(testDir)
 	defer cleanup()
 
@@ -1391,7 +2001,8 @@ func initBlkIdx() {
 }
 
 func TestSyncManager_lastAccouncedBlock(t *testing.T) {
-	testDir, _ := initTestEnv(t, []string{"--regtest"})
+	// the regtest genesis is only too old for the default maxtipage
+	testDir, _ := initTestEnv(t, []string{"--regtest", "--maxtipage=86400"})
 	defer os.RemoveAll(testDir)
 	defer cleanup()
 
@@ -1462,12 +2073,10 @@ func TestSyncManager_fetchBlocks(t *testing.T) {
 	hash1 := util.HashFromString("00000000000001bcd6b635a1249dfbe76c0d001592a7219a36cd9bbd002c7238")
 	p, _ := peer.NewOutboundPeer(peer1Cfg, "127.0.0.1:123", false)
 
-	requestedTxns := make(map[util.Hash]struct{})
 	requestedBlocks := make(map[util.Hash]struct{})
 	for i := 2; i < MAX_BLOCKS_IN_TRANSIT_PER_PEER; i++ {
 		requestedBlocks[*util.HashFromString(strconv.Itoa(i))] = struct{}{}
 	}
-	requestedTxns[*util.HashFromString(strconv.Itoa(0))] = struct{}{}
 
 	invVect1 := wire.NewInvVect(wire.InvTypeTx, hash1)
 	msgInv := wire.NewMsgInv()
@@ -1476,7 +2085,6 @@ func TestSyncManager_fetchBlocks(t *testing.T) {
 	syncState := &peerSyncState{
 		syncCandidate:   true,
 		requestQueue:    msgInv.InvList,
-		requestedTxns:   requestedTxns,
 		requestedBlocks: requestedBlocks,
 	}
 
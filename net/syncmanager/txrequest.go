@@ -0,0 +1,223 @@
+package syncmanager
+
+import (
+	"time"
+
+	"github.com/copernet/copernicus/peer"
+	"github.com/copernet/copernicus/util"
+)
+
+const (
+	// nonPreferredPeerTxDelay is how long a transaction announced by a
+	// non-preferred peer waits before being requested, giving preferred
+	// peers the chance to announce it too. It also makes it harder for
+	// inbound peers to learn the origin of our transactions by timing.
+	nonPreferredPeerTxDelay = 2 * time.Second
+
+	// txRequestTimeout is how long a peer has to send a requested
+	// transaction before it is requested from another peer.
+	txRequestTimeout = time.Minute
+
+	// maxPeerTxAnnouncements is the maximum number of announced
+	// transactions tracked for a single peer.
+	maxPeerTxAnnouncements = 5000
+
+	// maxPeerTxInFlight is the maximum number of transactions requested
+	// from a single peer at once.
+	maxPeerTxInFlight = 100
+
+	// txRequestInterval is the interval at which announced transactions
+	// that became due are requested.
+	txRequestInterval = 500 * time.Millisecond
+)
+
+// txAnnouncement records a peer announcing a transaction we do not have.
+type txAnnouncement struct {
+	peer      *peer.Peer
+	preferred bool
+	reqTime   time.Time // earliest time the transaction may be requested
+}
+
+// txRequest holds the peers which announced a transaction and the request
+// in flight, if any.
+type txRequest struct {
+	announcements []*txAnnouncement
+	peer          *peer.Peer // peer the transaction is requested from
+	expiry        time.Time
+}
+
+// txRequestTracker schedules the download of announced transactions, so that
+// each transaction is requested from a single peer at a time. Preferred peers
+// are asked right away, other peers only after nonPreferredPeerTxDelay, and
+// another announcing peer is asked when a request times out.
+//
+// It is not safe for concurrent access, and is only used from the
+// messagesHandler.
+type txRequestTracker struct {
+	requests map[util.Hash]*txRequest
+	peerTxns map[*peer.Peer]map[util.Hash]struct{}
+	inFlight map[*peer.Peer]int
+}
+
+// newTxRequestTracker returns a tracker without any announcement.
+func newTxRequestTracker() *txRequestTracker {
+	return &txRequestTracker{
+		requests: make(map[util.Hash]*txRequest),
+		peerTxns: make(map[*peer.Peer]map[util.Hash]struct{}),
+		inFlight: make(map[*peer.Peer]int),
+	}
+}
+
+// isPreferredTxPeer returns whether transactions are requested from p
+// without delay, which is the case of outbound and whitelisted peers.
+func isPreferredTxPeer(p *peer.Peer) bool {
+	return !p.Inbound() || p.IsWhitelisted()
+}
+
+// announce records that p announced the transaction hash at now. It returns
+// false when the announcement is ignored, because p already announced it or
+// announced too many transactions.
+func (t *txRequestTracker) announce(p *peer.Peer, hash util.Hash, preferred bool, now time.Time) bool {
+	txns := t.peerTxns[p]
+	if _, exists := txns[hash]; exists {
+		return false
+	}
+	if len(txns) >= maxPeerTxAnnouncements {
+		return false
+	}
+	if txns == nil {
+		txns = make(map[util.Hash]struct{})
+		t.peerTxns[p] = txns
+	}
+	txns[hash] = struct{}{}
+
+	ann := &txAnnouncement{peer: p, preferred: preferred, reqTime: now}
+	if !preferred {
+		ann.reqTime = now.Add(nonPreferredPeerTxDelay)
+	}
+	req, exists := t.requests[hash]
+	if !exists {
+		req = &txRequest{}
+		t.requests[hash] = req
+	}
+	req.announcements = append(req.announcements, ann)
+	return true
+}
+
+// requestedFrom returns the peer the transaction hash is requested from, or
+// nil when it is not requested.
+func (t *txRequestTracker) requestedFrom(hash util.Hash) *peer.Peer {
+	if req, exists := t.requests[hash]; exists {
+		return req.peer
+	}
+	return nil
+}
+
+// forgetTx drops the announcements of the transaction hash, once it was
+// received or is not needed anymore.
+func (t *txRequestTracker) forgetTx(hash util.Hash) {
+	req, exists := t.requests[hash]
+	if !exists {
+		return
+	}
+	if req.peer != nil {
+		t.inFlight[req.peer]--
+	}
+	for _, ann := range req.announcements {
+		t.dropPeerTx(ann.peer, hash)
+	}
+	delete(t.requests, hash)
+}
+
+// removePeer drops the announcements of p. The transactions requested from
+// p become available to other announcing peers right away.
+func (t *txRequestTracker) removePeer(p *peer.Peer) {
+	for hash := range t.peerTxns[p] {
+		req := t.requests[hash]
+		req.removeAnnouncement(p)
+		if req.peer == p {
+			req.peer = nil
+		}
+		if len(req.announcements) == 0 {
+			delete(t.requests, hash)
+		}
+	}
+	delete(t.peerTxns, p)
+	delete(t.inFlight, p)
+}
+
+// dropPeerTx removes hash from the transactions announced by p.
+func (t *txRequestTracker) dropPeerTx(p *peer.Peer, hash util.Hash) {
+	txns := t.peerTxns[p]
+	delete(txns, hash)
+	if len(txns) == 0 {
+		delete(t.peerTxns, p)
+	}
+}
+
+// removeAnnouncement removes the announcement of p.
+func (req *txRequest) removeAnnouncement(p *peer.Peer) {
+	for i, ann := range req.announcements {
+		if ann.peer == p {
+			req.announcements = append(req.announcements[:i], req.announcements[i+1:]...)
+			return
+		}
+	}
+}
+
+// selectPeer returns the announcement to request the transaction from at
+// now: a preferred peer first, then the earliest announcement. Peers with
+// too many requests in flight are skipped. It returns nil when no
+// announcement is due yet.
+func (t *txRequestTracker) selectPeer(req *txRequest, now time.Time) *txAnnouncement {
+	var best *txAnnouncement
+	for _, ann := range req.announcements {
+		if ann.reqTime.After(now) || t.inFlight[ann.peer] >= maxPeerTxInFlight {
+			continue
+		}
+		if best == nil || (ann.preferred && !best.preferred) ||
+			(ann.preferred == best.preferred && ann.reqTime.Before(best.reqTime)) {
+			best = ann
+		}
+	}
+	return best
+}
+
+// requestable expires the requests which timed out at now and returns the
+// transactions to request, grouped by peer. The returned transactions are
+// marked as requested. have is called before a transaction is requested,
+// and a transaction it reports as known is forgotten instead.
+func (t *txRequestTracker) requestable(now time.Time, have func(*util.Hash) bool) map[*peer.Peer][]util.Hash {
+	toRequest := make(map[*peer.Peer][]util.Hash)
+	for hash, req := range t.requests {
+		if req.peer != nil {
+			if now.Before(req.expiry) {
+				continue
+			}
+			// the peer did not deliver in time, try another one
+			t.inFlight[req.peer]--
+			req.removeAnnouncement(req.peer)
+			t.dropPeerTx(req.peer, hash)
+			req.peer = nil
+			if len(req.announcements) == 0 {
+				delete(t.requests, hash)
+				continue
+			}
+		}
+
+		ann := t.selectPeer(req, now)
+		if ann == nil {
+			continue
+		}
+		hash := hash
+		if have(&hash) {
+			t.forgetTx(hash)
+			continue
+		}
+		req.peer = ann.peer
+		req.expiry = now.Add(txRequestTimeout)
+		t.inFlight[ann.peer]++
+		toRequest[ann.peer] = append(toRequest[ann.peer], hash)
+	}
+	return toRequest
+}
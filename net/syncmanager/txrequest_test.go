@@ -0,0 +1,95 @@
+package syncmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/peer"
+	"github.com/copernet/copernicus/util"
+)
+
+func haveNone(*util.Hash) bool { return false }
+
+func TestTxRequestTrackerTimeout(t *testing.T) {
+	tracker := newTxRequestTracker()
+	p1 := peer.NewInboundPeer(peer1Cfg, false)
+	p2 := peer.NewInboundPeer(peer1Cfg, false)
+	hash := util.Hash{1}
+	now := time.Unix(1600000000, 0)
+
+	if !tracker.announce(p1, hash, false, now) {
+		t.Fatal("announcement was ignored")
+	}
+	if tracker.announce(p1, hash, false, now) {
+		t.Error("duplicate announcement was accepted")
+	}
+	tracker.announce(p2, hash, false, now.Add(time.Second))
+
+	if reqs := tracker.requestable(now, haveNone); len(reqs) != 0 {
+		t.Fatalf("transaction requested before the delay: %v", reqs)
+	}
+	now = now.Add(nonPreferredPeerTxDelay)
+	reqs := tracker.requestable(now, haveNone)
+	if len(reqs) != 1 || len(reqs[p1]) != 1 {
+		t.Fatalf("expected a single request to the first announcing peer, got %v", reqs)
+	}
+
+	// nothing is requested again while the request is in flight
+	now = now.Add(time.Second)
+	if reqs := tracker.requestable(now, haveNone); len(reqs) != 0 {
+		t.Fatalf("transaction requested twice: %v", reqs)
+	}
+
+	// the other peer is asked once the request timed out
+	now = now.Add(txRequestTimeout)
+	reqs = tracker.requestable(now, haveNone)
+	if len(reqs) != 1 || len(reqs[p2]) != 1 {
+		t.Fatalf("expected a request to the second peer, got %v", reqs)
+	}
+	if _, exists := tracker.peerTxns[p1]; exists {
+		t.Error("announcement of the timed out peer was kept")
+	}
+
+	tracker.forgetTx(hash)
+	if len(tracker.requests) != 0 || len(tracker.peerTxns) != 0 || tracker.inFlight[p2] != 0 {
+		t.Error("received transaction was not forgotten")
+	}
+}
+
+func TestTxRequestTrackerAlreadyHave(t *testing.T) {
+	tracker := newTxRequestTracker()
+	p := peer.NewInboundPeer(peer1Cfg, true)
+	hash := util.Hash{1}
+	now := time.Unix(1600000000, 0)
+
+	tracker.announce(p, hash, isPreferredTxPeer(p), now)
+	reqs := tracker.requestable(now, func(*util.Hash) bool { return true })
+	if len(reqs) != 0 {
+		t.Errorf("known transaction was requested: %v", reqs)
+	}
+	if len(tracker.requests) != 0 {
+		t.Error("known transaction was not forgotten")
+	}
+}
+
+func TestTxRequestTrackerAnnouncementLimit(t *testing.T) {
+	tracker := newTxRequestTracker()
+	p := peer.NewInboundPeer(peer1Cfg, false)
+	now := time.Unix(1600000000, 0)
+
+	for i := 0; i < maxPeerTxAnnouncements; i++ {
+		var hash util.Hash
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		if !tracker.announce(p, hash, false, now) {
+			t.Fatalf("announcement %d was ignored", i)
+		}
+	}
+	if tracker.announce(p, util.Hash{0xff, 0xff}, false, now) {
+		t.Error("announcement over the limit was accepted")
+	}
+
+	tracker.removePeer(p)
+	if len(tracker.requests) != 0 || len(tracker.peerTxns) != 0 {
+		t.Error("announcements of the removed peer were kept")
+	}
+}
@@ -18,6 +18,7 @@ import (
 type MessageError struct {
 	Func        string // Function name
 	Description string // Human readable description of the issue
+	BadHeader   bool   // The header can't be trusted, see headerError
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -32,3 +33,11 @@ func (e *MessageError) Error() string {
 func messageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
 }
+
+// headerError creates an error for a message whose header can't be trusted: it
+// carries a foreign network magic, claims a payload over the allowed maximum
+// or doesn't match the checksum of its payload.  Peers sending such messages
+// are treated as misbehaving rather than just buggy.
+func headerError(f string, desc string) *MessageError {
+	return &MessageError{Func: f, Description: desc, BadHeader: true}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/copernet/copernicus/conf"
 	"net"
 	"os"
+	"runtime"
 	"testing"
 	"time"
 
@@ -175,3 +176,65 @@ func TestMessage(t *testing.T) {
 		}
 	}
 }
+
+// TestReadMessageBadHeader ensures headers that can't be trusted are rejected
+// as such before anything is allocated for the length they claim.
+func TestReadMessageBadHeader(t *testing.T) {
+	pver := ProtocolVersion
+	btcnet := MainNet
+
+	tests := []struct {
+		name  string
+		buf   []byte
+		bytes int // Expected number of bytes read
+	}{
+		{
+			"bad magic",
+			makeHeader(TestNet3, "inv", 0xffffffff, 0),
+			24,
+		},
+		{
+			"over the global cap",
+			makeHeader(btcnet, "inv", MaxProtocolMessageLength+1, 0),
+			24,
+		},
+		{
+			"block over the global cap",
+			makeHeader(btcnet, "block", 0xffffffff, 0),
+			24,
+		},
+		{
+			"over the message type limit",
+			makeHeader(btcnet, "verack", 1024*1024, 0),
+			24,
+		},
+		{
+			"checksum mismatch",
+			makeHeader(btcnet, "verack", 0, 0),
+			24,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		n, msg, _, err := ReadMessageN(bytes.NewReader(test.buf), pver, btcnet)
+		runtime.ReadMemStats(&after)
+
+		msgErr, ok := err.(*MessageError)
+		if !ok || !msgErr.BadHeader {
+			t.Errorf("ReadMessage %s: got %v (%T) msg %v, want a bad "+
+				"header error", test.name, err, err, msg)
+			continue
+		}
+		if n != test.bytes {
+			t.Errorf("ReadMessage %s: read %d bytes, want %d",
+				test.name, n, test.bytes)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64*1024 {
+			t.Errorf("ReadMessage %s: allocated %d bytes", test.name,
+				allocated)
+		}
+	}
+}
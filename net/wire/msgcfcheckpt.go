@@ -0,0 +1,125 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// CFCheckptInterval is the block interval of the filter headers sent in a
+// cfcheckpt message.
+const CFCheckptInterval = 1000
+
+// MsgCFCheckpt implements the Message interface and represents a bitcoin
+// cfcheckpt message. It is sent in response to a getcfcheckpt message
+// (MsgGetCFCheckpt) and carries the filter headers of every
+// CFCheckptInterval-th block up to the stop hash.
+type MsgCFCheckpt struct {
+	FilterType    FilterType
+	StopHash      util.Hash
+	FilterHeaders []*util.Hash
+}
+
+// AddCFHeader adds a new filter header to the message.
+func (msg *MsgCFCheckpt) AddCFHeader(hash *util.Hash) error {
+	if len(msg.FilterHeaders)+1 > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter headers for message [max %v]",
+			MaxCFHeadersPerMsg)
+		return messageError("MsgCFCheckpt.AddCFHeader", str)
+	}
+
+	msg.FilterHeaders = append(msg.FilterHeaders, hash)
+	return nil
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.StopHash)
+	if err != nil {
+		return err
+	}
+	msg.FilterType = FilterType(filterType)
+
+	// Read num filter headers and limit to max.
+	count, err := util.ReadVarInt(r)
+	if err != nil {
+		return err
+	}
+	if count > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter headers for message "+
+			"[count %v, max %v]", count, MaxCFHeadersPerMsg)
+		return messageError("MsgCFCheckpt.Decode", str)
+	}
+
+	// Create a contiguous slice of hashes to deserialize into in order to
+	// reduce the number of allocations.
+	hashes := make([]util.Hash, count)
+	msg.FilterHeaders = make([]*util.Hash, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hash := &hashes[i]
+		err := util.ReadElements(r, hash)
+		if err != nil {
+			return err
+		}
+		msg.AddCFHeader(hash)
+	}
+
+	return nil
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	// Limit to max filter headers per message.
+	count := len(msg.FilterHeaders)
+	if count > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter headers for message "+
+			"[count %v, max %v]", count, MaxCFHeadersPerMsg)
+		return messageError("MsgCFCheckpt.Encode", str)
+	}
+
+	err := util.WriteElements(w, uint8(msg.FilterType), &msg.StopHash)
+	if err != nil {
+		return err
+	}
+
+	err = util.WriteVarInt(w, uint64(count))
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range msg.FilterHeaders {
+		err := util.WriteElements(w, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCFCheckpt) Command() string {
+	return CmdCFCheckpt
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + stop hash + num filter headers
+	// (varInt) + max allowed filter headers.
+	return 1 + util.Hash256Size + MaxVarIntPayload +
+		(MaxCFHeadersPerMsg * util.Hash256Size)
+}
+
+// NewMsgCFCheckpt returns a new bitcoin cfcheckpt message that conforms to
+// the Message interface.  See MsgCFCheckpt for details.
+func NewMsgCFCheckpt() *MsgCFCheckpt {
+	return &MsgCFCheckpt{
+		FilterHeaders: make([]*util.Hash, 0),
+	}
+}
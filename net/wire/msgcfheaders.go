@@ -0,0 +1,127 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// MaxCFHeadersPerMsg is the maximum number of filter hashes allowed in a
+// cfheaders message.
+const MaxCFHeadersPerMsg = 2000
+
+// MsgCFHeaders implements the Message interface and represents a bitcoin
+// cfheaders message. It is sent in response to a getcfheaders message
+// (MsgGetCFHeaders) and carries the filter header of the block preceding the
+// requested range followed by the filter hashes of the range, from which the
+// peer rebuilds the filter headers.
+type MsgCFHeaders struct {
+	FilterType       FilterType
+	StopHash         util.Hash
+	PrevFilterHeader util.Hash
+	FilterHashes     []*util.Hash
+}
+
+// AddCFHash adds a new filter hash to the message.
+func (msg *MsgCFHeaders) AddCFHash(hash *util.Hash) error {
+	if len(msg.FilterHashes)+1 > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter hashes for message [max %v]",
+			MaxCFHeadersPerMsg)
+		return messageError("MsgCFHeaders.AddCFHash", str)
+	}
+
+	msg.FilterHashes = append(msg.FilterHashes, hash)
+	return nil
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCFHeaders) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.StopHash, &msg.PrevFilterHeader)
+	if err != nil {
+		return err
+	}
+	msg.FilterType = FilterType(filterType)
+
+	// Read num filter hashes and limit to max.
+	count, err := util.ReadVarInt(r)
+	if err != nil {
+		return err
+	}
+	if count > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter hashes for message "+
+			"[count %v, max %v]", count, MaxCFHeadersPerMsg)
+		return messageError("MsgCFHeaders.Decode", str)
+	}
+
+	// Create a contiguous slice of hashes to deserialize into in order to
+	// reduce the number of allocations.
+	hashes := make([]util.Hash, count)
+	msg.FilterHashes = make([]*util.Hash, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hash := &hashes[i]
+		err := util.ReadElements(r, hash)
+		if err != nil {
+			return err
+		}
+		msg.AddCFHash(hash)
+	}
+
+	return nil
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCFHeaders) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	// Limit to max filter hashes per message.
+	count := len(msg.FilterHashes)
+	if count > MaxCFHeadersPerMsg {
+		str := fmt.Sprintf("too many filter hashes for message "+
+			"[count %v, max %v]", count, MaxCFHeadersPerMsg)
+		return messageError("MsgCFHeaders.Encode", str)
+	}
+
+	err := util.WriteElements(w, uint8(msg.FilterType), &msg.StopHash, &msg.PrevFilterHeader)
+	if err != nil {
+		return err
+	}
+
+	err = util.WriteVarInt(w, uint64(count))
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range msg.FilterHashes {
+		err := util.WriteElements(w, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCFHeaders) Command() string {
+	return CmdCFHeaders
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCFHeaders) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + stop hash + prev filter header + num filter hashes
+	// (varInt) + max allowed filter hashes.
+	return 1 + util.Hash256Size + util.Hash256Size + MaxVarIntPayload +
+		(MaxCFHeadersPerMsg * util.Hash256Size)
+}
+
+// NewMsgCFHeaders returns a new bitcoin cfheaders message that conforms to
+// the Message interface.  See MsgCFHeaders for details.
+func NewMsgCFHeaders() *MsgCFHeaders {
+	return &MsgCFHeaders{
+		FilterHashes: make([]*util.Hash, 0, MaxCFHeadersPerMsg),
+	}
+}
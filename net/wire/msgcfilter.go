@@ -0,0 +1,74 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// MaxCFilterDataSize is the maximum byte size of a serialized compact filter.
+const MaxCFilterDataSize = 256 * 1024
+
+// MsgCFilter implements the Message interface and represents a bitcoin
+// cfilter message. It is sent in response to a getcfilters message
+// (MsgGetCFilters) and carries the serialized filter of one block.
+type MsgCFilter struct {
+	FilterType FilterType
+	BlockHash  util.Hash
+	Data       []byte
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCFilter) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	msg.FilterType = FilterType(filterType)
+
+	msg.Data, err = util.ReadVarBytes(r, MaxCFilterDataSize, "cfilter data")
+	return err
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCFilter) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	size := len(msg.Data)
+	if size > MaxCFilterDataSize {
+		str := fmt.Sprintf("cfilter size too large for message "+
+			"[size %v, max %v]", size, MaxCFilterDataSize)
+		return messageError("MsgCFilter.Encode", str)
+	}
+
+	err := util.WriteElements(w, uint8(msg.FilterType), &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+	return util.WriteVarBytes(w, msg.Data)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCFilter) Command() string {
+	return CmdCFilter
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCFilter) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + block hash + filter size (varInt) + max filter size.
+	return 1 + util.Hash256Size + MaxVarIntPayload + MaxCFilterDataSize
+}
+
+// NewMsgCFilter returns a new bitcoin cfilter message that conforms to the
+// Message interface.  See MsgCFilter for details.
+func NewMsgCFilter(filterType FilterType, blockHash *util.Hash, data []byte) *MsgCFilter {
+	return &MsgCFilter{
+		FilterType: filterType,
+		BlockHash:  *blockHash,
+		Data:       data,
+	}
+}
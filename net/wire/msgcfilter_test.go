@@ -0,0 +1,96 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/copernet/copernicus/util"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFilterMessagesWire tests the wire encode and decode of the BIP157
+// compact filter messages.
+func TestCFilterMessagesWire(t *testing.T) {
+	stopHash := util.Hash{0x01, 0x02, 0x03}
+	prevHeader := util.Hash{0x04, 0x05}
+
+	cfheaders := NewMsgCFHeaders()
+	cfheaders.StopHash = stopHash
+	cfheaders.PrevFilterHeader = prevHeader
+	cfheaders.AddCFHash(&util.Hash{0x06})
+	cfheaders.AddCFHash(&util.Hash{0x07})
+
+	cfcheckpt := NewMsgCFCheckpt()
+	cfcheckpt.StopHash = stopHash
+	cfcheckpt.AddCFHeader(&util.Hash{0x08})
+
+	tests := []struct {
+		in  Message
+		out Message
+		cmd string
+	}{
+		{NewMsgGetCFilters(GCSFilterRegular, 100, &stopHash), &MsgGetCFilters{}, "getcfilters"},
+		{NewMsgCFilter(GCSFilterRegular, &stopHash, []byte{0x01, 0x9d, 0xfc, 0xa8}), &MsgCFilter{}, "cfilter"},
+		{NewMsgGetCFHeaders(GCSFilterRegular, 100, &stopHash), &MsgGetCFHeaders{}, "getcfheaders"},
+		{cfheaders, &MsgCFHeaders{}, "cfheaders"},
+		{NewMsgGetCFCheckpt(GCSFilterRegular, &stopHash), &MsgGetCFCheckpt{}, "getcfcheckpt"},
+		{cfcheckpt, &MsgCFCheckpt{}, "cfcheckpt"},
+	}
+
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: wrong command - got %v want %v", i, cmd, test.cmd)
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.Encode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+			t.Errorf("Encode #%d error %v", i, err)
+			continue
+		}
+		if uint64(buf.Len()) > test.in.MaxPayloadLength(ProtocolVersion) {
+			t.Errorf("Encode #%d: payload of %d bytes exceeds the maximum", i, buf.Len())
+			continue
+		}
+
+		msg, err := makeEmptyMessage(test.cmd)
+		if err != nil {
+			t.Errorf("makeEmptyMessage #%d error %v", i, err)
+			continue
+		}
+		if reflect.TypeOf(msg) != reflect.TypeOf(test.out) {
+			t.Errorf("makeEmptyMessage #%d: got %T want %T", i, msg, test.out)
+			continue
+		}
+		if err := msg.Decode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+			t.Errorf("Decode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.in) {
+			t.Errorf("Decode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.in))
+		}
+	}
+}
+
+// TestCFHeadersTooMany ensures cfheaders messages are limited to
+// MaxCFHeadersPerMsg filter hashes.
+func TestCFHeadersTooMany(t *testing.T) {
+	msg := NewMsgCFHeaders()
+	hash := util.Hash{}
+	for i := 0; i < MaxCFHeadersPerMsg; i++ {
+		if err := msg.AddCFHash(&hash); err != nil {
+			t.Fatalf("AddCFHash #%d error %v", i, err)
+		}
+	}
+	if err := msg.AddCFHash(&hash); err == nil {
+		t.Error("AddCFHash: expected an error past the maximum")
+	}
+
+	msg.FilterHashes = append(msg.FilterHashes, &hash)
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf, ProtocolVersion, BaseEncoding); err == nil {
+		t.Error("Encode: expected an error past the maximum")
+	}
+}
@@ -0,0 +1,53 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// MsgGetCFCheckpt implements the Message interface and represents a bitcoin
+// getcfcheckpt message. It is used to request the filter headers of every
+// CFCheckptInterval-th block up to StopHash, which are returned in a
+// cfcheckpt message (MsgCFCheckpt).
+type MsgGetCFCheckpt struct {
+	FilterType FilterType
+	StopHash   util.Hash
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFCheckpt) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.StopHash)
+	msg.FilterType = FilterType(filterType)
+	return err
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFCheckpt) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return util.WriteElements(w, uint8(msg.FilterType), &msg.StopHash)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetCFCheckpt) Command() string {
+	return CmdGetCFCheckpt
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetCFCheckpt) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + stop hash.
+	return 1 + util.Hash256Size
+}
+
+// NewMsgGetCFCheckpt returns a new bitcoin getcfcheckpt message that
+// conforms to the Message interface.  See MsgGetCFCheckpt for details.
+func NewMsgGetCFCheckpt(filterType FilterType, stopHash *util.Hash) *MsgGetCFCheckpt {
+	return &MsgGetCFCheckpt{
+		FilterType: filterType,
+		StopHash:   *stopHash,
+	}
+}
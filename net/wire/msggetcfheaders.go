@@ -0,0 +1,55 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// MsgGetCFHeaders implements the Message interface and represents a bitcoin
+// getcfheaders message. It is used to request the filter headers of the
+// blocks from StartHeight up to StopHash, which are returned in a cfheaders
+// message (MsgCFHeaders).
+type MsgGetCFHeaders struct {
+	FilterType  FilterType
+	StartHeight uint32
+	StopHash    util.Hash
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFHeaders) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.StartHeight, &msg.StopHash)
+	msg.FilterType = FilterType(filterType)
+	return err
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFHeaders) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return util.WriteElements(w, uint8(msg.FilterType), msg.StartHeight, &msg.StopHash)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetCFHeaders) Command() string {
+	return CmdGetCFHeaders
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetCFHeaders) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + start height + stop hash.
+	return 1 + 4 + util.Hash256Size
+}
+
+// NewMsgGetCFHeaders returns a new bitcoin getcfheaders message that conforms
+// to the Message interface.  See MsgGetCFHeaders for details.
+func NewMsgGetCFHeaders(filterType FilterType, startHeight uint32, stopHash *util.Hash) *MsgGetCFHeaders {
+	return &MsgGetCFHeaders{
+		FilterType:  filterType,
+		StartHeight: startHeight,
+		StopHash:    *stopHash,
+	}
+}
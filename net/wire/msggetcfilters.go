@@ -0,0 +1,68 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// MaxGetCFiltersReqRange is the maximum number of filters that may be
+// requested in a getcfilters message.
+const MaxGetCFiltersReqRange = 1000
+
+// FilterType is the type of the compact block filters requested and served
+// by the BIP157 messages.
+type FilterType uint8
+
+const (
+	// GCSFilterRegular is the BIP158 basic filter.
+	GCSFilterRegular FilterType = iota
+)
+
+// MsgGetCFilters implements the Message interface and represents a bitcoin
+// getcfilters message. It is used to request the compact filters of the
+// blocks from StartHeight up to StopHash, each one is returned in a cfilter
+// message (MsgCFilter).
+type MsgGetCFilters struct {
+	FilterType  FilterType
+	StartHeight uint32
+	StopHash    util.Hash
+}
+
+// Decode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFilters) Decode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var filterType uint8
+	err := util.ReadElements(r, &filterType, &msg.StartHeight, &msg.StopHash)
+	msg.FilterType = FilterType(filterType)
+	return err
+}
+
+// Encode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCFilters) Encode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return util.WriteElements(w, uint8(msg.FilterType), msg.StartHeight, &msg.StopHash)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetCFilters) Command() string {
+	return CmdGetCFilters
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetCFilters) MaxPayloadLength(pver uint32) uint64 {
+	// Filter type + start height + stop hash.
+	return 1 + 4 + util.Hash256Size
+}
+
+// NewMsgGetCFilters returns a new bitcoin getcfilters message that conforms
+// to the Message interface.  See MsgGetCFilters for details.
+func NewMsgGetCFilters(filterType FilterType, startHeight uint32, stopHash *util.Hash) *MsgGetCFilters {
+	return &MsgGetCFilters{
+		FilterType:  filterType,
+		StartHeight: startHeight,
+		StopHash:    *stopHash,
+	}
+}
@@ -148,3 +148,25 @@ func NewMsgReject(command string, code errcode.RejectCode, reason string) *MsgRe
 		Reason: reason,
 	}
 }
+
+// NewMsgRejectFromError returns the reject message for command rejected with
+// err, and false if err does not have a BIP61 reject code. The hash of the
+// message is the txid or block hash attached to err by validation.
+func NewMsgRejectFromError(command string, err error) (*MsgReject, bool) {
+	code, reason, ok := errcode.IsRejectCode(err)
+	if !ok {
+		return nil, false
+	}
+
+	msg := NewMsgReject(command, code, reason)
+	key := errcode.DetailTxID
+	if command == CmdBlock {
+		key = errcode.DetailBlockHash
+	}
+	if hash, ok := errcode.Detail(err, key); ok {
+		if hash, ok := hash.(util.Hash); ok {
+			msg.Hash = hash
+		}
+	}
+	return msg, true
+}
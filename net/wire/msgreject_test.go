@@ -11,6 +11,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/copernet/copernicus/util"
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -388,3 +389,29 @@ func TestRejectWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestNewMsgRejectFromError tests the reject message built for an error takes
+// the txid attached to the error.
+func TestNewMsgRejectFromError(t *testing.T) {
+	txid := util.Hash{0x01, 0x02}
+	err := NewError(RejectNonstandard, "non-BIP68-final")
+	err = WithDetail(err, DetailTxID, txid)
+
+	msg, ok := NewMsgRejectFromError(CmdTx, err)
+	if !ok {
+		t.Fatalf("NewMsgRejectFromError: no reject message for %v", err)
+	}
+	want := &MsgReject{
+		Cmd:    CmdTx,
+		Code:   RejectNonstandard,
+		Reason: "non-BIP68-final",
+		Hash:   txid,
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("NewMsgRejectFromError: got %v, want %v", msg, want)
+	}
+
+	if _, ok := NewMsgRejectFromError(CmdTx, New(ErrorOutOfDiskSpace)); ok {
+		t.Error("NewMsgRejectFromError: reject message for an error without a reject code")
+	}
+}
@@ -200,6 +200,77 @@ func TestNetAddressWire(t *testing.T) {
 	}
 }
 
+// TestNetAddressIPv6Wire tests that IPv6 addresses, including IPv4-mapped
+// ones, round trip through the 16 byte address field.
+func TestNetAddressIPv6Wire(t *testing.T) {
+	tests := []struct {
+		ip  string
+		buf []byte // Wire encoding of the address field
+	}{
+		{
+			"2001:db8:85a3::8a2e:370:7334",
+			[]byte{
+				0x20, 0x01, 0x0d, 0xb8, 0x85, 0xa3, 0x00, 0x00,
+				0x00, 0x00, 0x8a, 0x2e, 0x03, 0x70, 0x73, 0x34,
+			},
+		},
+		{
+			"127.0.0.1",
+			[]byte{
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0xff, 0xff, 0x7f, 0x00, 0x00, 0x01,
+			},
+		},
+		{
+			"fc12:3456::1",
+			[]byte{
+				0xfc, 0x12, 0x34, 0x56, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		ip := net.ParseIP(test.ip)
+		na := NetAddress{Services: SFNodeNetwork, IP: ip, Port: 8333}
+
+		var buf bytes.Buffer
+		err := writeNetAddress(&buf, ProtocolVersion, &na, false)
+		if err != nil {
+			t.Errorf("writeNetAddress #%d error %v", i, err)
+			continue
+		}
+		// The address field follows the 8 byte services field.
+		if got := buf.Bytes()[8:24]; !bytes.Equal(got, test.buf) {
+			t.Errorf("writeNetAddress #%d\n got: %x want: %x", i,
+				got, test.buf)
+			continue
+		}
+
+		var decoded NetAddress
+		rbuf := bytes.NewReader(buf.Bytes())
+		err = readNetAddress(rbuf, ProtocolVersion, &decoded, false)
+		if err != nil {
+			t.Errorf("readNetAddress #%d error %v", i, err)
+			continue
+		}
+		if len(decoded.IP) != net.IPv6len || !decoded.IP.Equal(ip) {
+			t.Errorf("readNetAddress #%d\n got: %v want: %v", i,
+				decoded.IP, ip)
+			continue
+		}
+		if (ip.To4() != nil) != (decoded.IP.To4() != nil) {
+			t.Errorf("readNetAddress #%d: IPv4-mapped form of %v "+
+				"not preserved", i, ip)
+		}
+		if decoded.Port != na.Port || decoded.Services != na.Services {
+			t.Errorf("readNetAddress #%d\n got: %v want: %v", i,
+				spew.Sdump(decoded), spew.Sdump(na))
+		}
+	}
+}
+
 // TestNetAddressWireErrors performs negative tests against wire encode and
 // decode NetAddress to confirm error paths work correctly.
 func TestNetAddressWireErrors(t *testing.T) {
@@ -61,6 +61,48 @@ const (
 	InvalidCBNoBanVersion uint32 = 70015
 )
 
+// NegotiatedVersion returns the protocol version two peers use once they have
+// exchanged version messages, which is the lower of the two.  The feature
+// predicates below all expect a version negotiated this way.
+func NegotiatedVersion(localVersion, peerVersion uint32) uint32 {
+	if peerVersion < localVersion {
+		return peerVersion
+	}
+	return localVersion
+}
+
+// CanSendFeeFilter returns whether a feefilter message may be sent at the
+// passed protocol version.
+func CanSendFeeFilter(pver uint32) bool {
+	return pver >= FeeFilterVersion
+}
+
+// CanSendHeaders returns whether a sendheaders message may be sent at the
+// passed protocol version to ask for new blocks to be announced by headers.
+func CanSendHeaders(pver uint32) bool {
+	return pver >= SendHeadersVersion
+}
+
+// SupportsCompactBlocks returns whether the sendcmpct, cmpctblock, getblocktxn
+// and blocktxn messages may be used at the passed protocol version.
+func SupportsCompactBlocks(pver uint32) bool {
+	return pver >= ShortIdsBlocksVersion
+}
+
+// SupportsBloomFilter returns whether a peer with the passed services serves
+// bloom filtering and the mempool message at the passed protocol version.
+// Before BIP0111 there was no service flag for it and every peer speaking
+// BIP0037 was assumed to support it.
+func SupportsBloomFilter(services ServiceFlag, pver uint32) bool {
+	if pver < BIP0037Version {
+		return false
+	}
+	if pver < BIP0111Version {
+		return true
+	}
+	return services&SFNodeBloom == SFNodeBloom
+}
+
 // ServiceFlag identifies services supported by a bitcoin peer.
 type ServiceFlag uint64
 
@@ -88,6 +130,10 @@ const (
 	// needed.
 	SFNodeCash
 
+	// SFNodeCompactFilters means the node serves the BIP157 compact block
+	// filters with the getcfilters, getcfheaders and getcfcheckpt commands.
+	SFNodeCompactFilters ServiceFlag = 1 << 6
+
 	// Bits 24-31 are reserved for temporary experiments. Just pick a bit that
 	// isn't getting used, or one not being used much, and notify the
 	// bitcoin-development mailing list. Remember that service bits are just
@@ -104,6 +150,8 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeBloom:   "SFNodeBloom",
 	SFNodeXthin:   "SFNodeXthin",
 	SFNodeCash:    "SFNodeCash",
+
+	SFNodeCompactFilters: "SFNodeCompactFilters",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -114,6 +162,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeBloom,
 	SFNodeXthin,
 	SFNodeCash,
+	SFNodeCompactFilters,
 }
 
 // String returns the ServiceFlag in human-readable form.
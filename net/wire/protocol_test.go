@@ -18,7 +18,8 @@ func TestServiceFlagStringer(t *testing.T) {
 		{SFNodeBloom, "SFNodeBloom"},
 		{SFNodeXthin, "SFNodeXthin"},
 		{SFNodeCash, "SFNodeCash"},
-		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeXthin|SFNodeCash|0xffffffe0"},
+		{SFNodeCompactFilters, "SFNodeCompactFilters"},
+		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeXthin|SFNodeCash|SFNodeCompactFilters|0xffffffa0"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -58,3 +59,64 @@ func TestBitcoinNetStringer(t *testing.T) {
 		}
 	}
 }
+
+// TestFeatureGating tests the features enabled for various combinations of
+// local and remote protocol versions.
+func TestFeatureGating(t *testing.T) {
+	tests := []struct {
+		local, remote uint32
+		services      ServiceFlag
+		pver          uint32 // Expected negotiated version
+		feeFilter     bool
+		sendHeaders   bool
+		compactBlocks bool
+		bloomFilter   bool
+	}{
+		{ProtocolVersion, 209, SFNodeNetwork, 209,
+			false, false, false, false},
+		{ProtocolVersion, BIP0037Version, SFNodeNetwork, BIP0037Version,
+			false, false, false, true},
+		{ProtocolVersion, BIP0111Version, SFNodeNetwork, BIP0111Version,
+			false, false, false, false},
+		{ProtocolVersion, BIP0111Version, SFNodeNetwork | SFNodeBloom,
+			BIP0111Version, false, false, false, true},
+		{ProtocolVersion, SendHeadersVersion, SFNodeNetwork,
+			SendHeadersVersion, false, true, false, false},
+		{ProtocolVersion, FeeFilterVersion, SFNodeBloom, FeeFilterVersion,
+			true, true, false, true},
+		{ProtocolVersion, InvalidCBNoBanVersion, SFNodeNetwork,
+			ProtocolVersion, true, true, false, false},
+		{SendHeadersVersion, InvalidCBNoBanVersion, SFNodeNetwork,
+			SendHeadersVersion, false, true, false, false},
+		{InvalidCBNoBanVersion, ShortIdsBlocksVersion, SFNodeNetwork,
+			ShortIdsBlocksVersion, true, true, true, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		pver := NegotiatedVersion(test.local, test.remote)
+		if pver != test.pver {
+			t.Errorf("NegotiatedVersion #%d\n got: %d want: %d", i,
+				pver, test.pver)
+			continue
+		}
+		if got := CanSendFeeFilter(pver); got != test.feeFilter {
+			t.Errorf("CanSendFeeFilter #%d (%d)\n got: %v want: %v",
+				i, pver, got, test.feeFilter)
+		}
+		if got := CanSendHeaders(pver); got != test.sendHeaders {
+			t.Errorf("CanSendHeaders #%d (%d)\n got: %v want: %v",
+				i, pver, got, test.sendHeaders)
+		}
+		if got := SupportsCompactBlocks(pver); got != test.compactBlocks {
+			t.Errorf("SupportsCompactBlocks #%d (%d)\n got: %v want: %v",
+				i, pver, got, test.compactBlocks)
+		}
+		got := SupportsBloomFilter(test.services, pver)
+		if got != test.bloomFilter {
+			t.Errorf("SupportsBloomFilter #%d (%d, %v)\n got: %v "+
+				"want: %v", i, pver, test.services, got,
+				test.bloomFilter)
+		}
+	}
+}
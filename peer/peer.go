@@ -52,9 +52,9 @@ const (
 	// messages.
 	pingInterval = 2 * time.Minute
 
-	// negotiateTimeout is the duration of inactivity before we timeout a
-	// peer that hasn't completed the initial version negotiation.
-	negotiateTimeout = 30 * time.Second
+	// DefaultPeerTimeout is the default time a peer is given to complete the
+	// version/verack handshake after the connection is made.
+	DefaultPeerTimeout = 60 * time.Second
 
 	// idleTimeout is the duration of inactivity before we time out a peer.
 	idleTimeout = 5 * time.Minute
@@ -180,6 +180,18 @@ type MessageListeners struct {
 	// OnFeeFilter is invoked when a peer receives a feefilter bitcoin message.
 	OnFeeFilter func(p *Peer, msg *wire.MsgFeeFilter)
 
+	// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin
+	// message.
+	OnGetCFilters func(p *Peer, msg *wire.MsgGetCFilters)
+
+	// OnGetCFHeaders is invoked when a peer receives a getcfheaders bitcoin
+	// message.
+	OnGetCFHeaders func(p *Peer, msg *wire.MsgGetCFHeaders)
+
+	// OnGetCFCheckpt is invoked when a peer receives a getcfcheckpt bitcoin
+	// message.
+	OnGetCFCheckpt func(p *Peer, msg *wire.MsgGetCFCheckpt)
+
 	// OnFilterAdd is invoked when a peer receives a filteradd bitcoin message.
 	OnFilterAdd func(p *Peer, msg *wire.MsgFilterAdd)
 
@@ -277,20 +289,16 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// PeerTimeout specifies how long the peer may take to complete the
+	// version/verack handshake before it is disconnected.  This field can
+	// be omitted in which case DefaultPeerTimeout will be used.
+	PeerTimeout time.Duration
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
 }
 
-// minUint32 is a helper function to return the minimum of two uint32s.
-// This avoids a math import and the need to cast to floats.
-func minUint32(a, b uint32) uint32 {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // newNetAddress attempts to extract the IP address and port from the passed
 // net.Addr interface and create a bitcoin NetAddress structure using that
 // information.
@@ -490,6 +498,9 @@ type Peer struct {
 	lastPingNonce        uint64    // Set to nonce if we have a pending ping.
 	lastPingTime         time.Time // Time we sent last ping.
 	lastPingMicros       int64     // Time for last ping to return.
+	minPingMicros        int64     // Lowest time for a ping to return.
+	lastBlockTime        time.Time // Time the peer last sent us a new block.
+	lastTxTime           time.Time // Time the peer last sent us a new tx.
 
 	stallControl      chan stallControlMsg
 	outputQueue       chan outMsg
@@ -521,7 +532,7 @@ func (p *Peer) SetStallingSince(since int64) {
 
 func (p *Peer) RequestMemPool() {
 	p.reqMempoolOnce.Do(func() {
-		if p.services&wire.SFNodeBloom == wire.SFNodeBloom {
+		if wire.SupportsBloomFilter(p.Services(), p.ProtocolVersion()) {
 			p.QueueMessage(wire.NewMsgMemPool(), nil)
 		}
 	})
@@ -770,6 +781,62 @@ func (p *Peer) ProtocolVersion() uint32 {
 	return protocolVersion
 }
 
+// MinPingMicros returns the lowest ping time of the peer, or 0 if it has not
+// answered a ping yet.
+//
+// This function is safe for concurrent access.
+func (p *Peer) MinPingMicros() int64 {
+	p.statsMtx.RLock()
+	minPingMicros := p.minPingMicros
+	p.statsMtx.RUnlock()
+
+	return minPingMicros
+}
+
+// LastBlockTime returns the time the peer last sent us a block that was new
+// to us.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastBlockTime() time.Time {
+	p.statsMtx.RLock()
+	lastBlockTime := p.lastBlockTime
+	p.statsMtx.RUnlock()
+
+	return lastBlockTime
+}
+
+// UpdateLastBlockTime records that the peer sent us a new block at the given
+// time.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastBlockTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastBlockTime = t
+	p.statsMtx.Unlock()
+}
+
+// LastTxTime returns the time the peer last sent us a transaction that was
+// accepted to the mempool.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastTxTime() time.Time {
+	p.statsMtx.RLock()
+	lastTxTime := p.lastTxTime
+	p.statsMtx.RUnlock()
+
+	return lastTxTime
+}
+
+// UpdateLastTxTime records that the peer sent us a new transaction at the
+// given time.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastTxTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastTxTime = t
+	p.statsMtx.Unlock()
+}
+
 // LastBlock returns the last block of the peer.
 //
 // This function is safe for concurrent access.
@@ -1032,6 +1099,9 @@ func (p *Peer) PushGetHeadersMsg(locator chain.BlockLocator, stopHash *util.Hash
 
 // PushSendHeadersMsg sends a sendheaders msg to indicate that i prefer headers instead of inv
 func (p *Peer) PushSendHeadersMsg() {
+	if !wire.CanSendHeaders(p.ProtocolVersion()) {
+		return
+	}
 	msg := wire.NewMsgSendHeaders()
 	p.QueueMessage(msg, nil)
 }
@@ -1072,6 +1142,25 @@ func (p *Peer) PushRejectMsg(command string, code errcode.RejectCode, reason str
 	<-doneChan
 }
 
+// PushRejectMsgFromError sends the reject message for command rejected with
+// err, built by wire.NewMsgRejectFromError. It returns false and sends
+// nothing if err does not have a reject code.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushRejectMsgFromError(command string, err error, wait bool) bool {
+	msg, ok := wire.NewMsgRejectFromError(command, err)
+	if !ok {
+		return false
+	}
+
+	var hash *util.Hash
+	if msg.Hash != zeroHash {
+		hash = &msg.Hash
+	}
+	p.PushRejectMsg(command, msg.Code, msg.Reason, hash, wait)
+	return true
+}
+
 // handleRemoteVersionMsg is invoked when a version bitcoin message is received
 // from the remote peer.  It will return an error if the remote peer's version
 // is not compatible with ours.
@@ -1108,7 +1197,7 @@ func (p *Peer) handleRemoteVersionMsg(msg *wire.MsgVersion) error {
 	// Negotiate the protocol version.
 	p.flagsMtx.Lock()
 	p.advertisedProtoVer = uint32(msg.ProtocolVersion)
-	p.protocolVersion = minUint32(p.protocolVersion, p.advertisedProtoVer)
+	p.protocolVersion = wire.NegotiatedVersion(p.protocolVersion, p.advertisedProtoVer)
 	p.versionKnown = true
 	log.Debug("Negotiated protocol version %d for peer %s",
 		p.protocolVersion, p)
@@ -1183,6 +1272,9 @@ func (p *Peer) HandlePongMsg(msg *wire.MsgPong) {
 		if p.lastPingNonce != 0 && msg.Nonce == p.lastPingNonce {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
+			if p.minPingMicros == 0 || p.lastPingMicros < p.minPingMicros {
+				p.minPingMicros = p.lastPingMicros
+			}
 			p.lastPingNonce = 0
 		}
 		p.statsMtx.Unlock()
@@ -1238,15 +1330,19 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 
 // isAllowedReadError returns whether or not the passed error is allowed without
 // disconnecting the peer.  In particular, regression tests need to be allowed
-// to send malformed messages without the peer being disconnected.
+// to send malformed messages without the peer being disconnected.  Only errors
+// in the payload of a message with a valid header are allowed, after a bad
+// header the stream can't be read any further.
 func (p *Peer) isAllowedReadError(err error) bool {
 	// Only allow read errors in regression test mode.
 	if p.Cfg.ChainParams.BitcoinNet != wire.RegTestNet {
 		return false
 	}
 
-	// Don't allow the error if it's not specifically a malformed message error.
-	if _, ok := err.(*wire.MessageError); !ok {
+	// Don't allow the error if it's not specifically a malformed message error
+	// or if the header of the message can't be trusted.
+	msgErr, ok := err.(*wire.MessageError)
+	if !ok || msgErr.BadHeader {
 		return false
 	}
 
@@ -1287,6 +1383,13 @@ func (p *Peer) shouldHandleReadError(err error) bool {
 	return true
 }
 
+// isTimeout returns whether the passed error is a network timeout such as an
+// expired read deadline.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
 // maybeAddDeadline potentially adds a deadline for the appropriate expected
 // response for the passed wire protocol command to the pending responses map.
 func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msg wire.Message) {
@@ -1462,28 +1565,24 @@ cleanup:
 // inHandler handles all incoming messages for the peer.  It must be run as a
 // goroutine.
 func (p *Peer) inHandler(phCh chan<- *PeerMessage) {
-	// The timer is stopped when a new message is received and reset after it
-	// is processed.
-	idleTimer := time.AfterFunc(idleTimeout, func() {
-		log.Warn("Peer %s no answer for %s -- disconnecting", p, idleTimeout)
-		p.Disconnect()
-	})
-
 out:
 	for atomic.LoadInt32(&p.disconnect) == 0 {
-		// Read a message and stop the idle timer as soon as the read
-		// is done.  The timer is reset below for the next iteration if
-		// needed.
+		// The read deadline is pushed back before every read so only a
+		// peer that stays silent for the whole idle timeout is dropped.
+		p.conn.SetReadDeadline(time.Now().Add(idleTimeout))
 		rmsg, buf, err := p.readMessage(p.wireEncoding)
-		idleTimer.Stop()
 		if err != nil {
 			log.Debug("Read Message error from %s, %v", p, err)
+			if isTimeout(err) {
+				log.Warn("Peer %s no answer for %s -- disconnecting", p, idleTimeout)
+				break out
+			}
+
 			// In order to allow regression tests with malformed messages, don't
 			// disconnect the peer when we're in regression test mode and the
 			// error is one of the allowed errors.
 			if p.isAllowedReadError(err) {
 				log.Error("Allowed test error from %s: %v", p, err)
-				idleTimer.Reset(idleTimeout)
 				continue
 			}
 
@@ -1518,14 +1617,8 @@ out:
 		phCh <- NewPeerMessage(p, rmsg, buf, done)
 		<-done
 		p.stallControl <- stallControlMsg{sccHandlerDone, rmsg}
-
-		// A message was received so reset the idle timer.
-		idleTimer.Reset(idleTimeout)
 	}
 
-	// Ensure the idle timer is stopped to avoid leaking the resource.
-	idleTimer.Stop()
-
 	// Ensure connection is closed.
 	p.Disconnect()
 
@@ -1541,6 +1634,12 @@ func (p *Peer) queueHandler() {
 	pendingMsgs := list.New()
 	invSendQueue := list.New()
 
+	txInvInterval := outboundTxInvInterval
+	if p.inbound {
+		txInvInterval = inboundTxInvInterval
+	}
+	txInvs := newTxInvQueue(txInvInterval, rand.New(rand.NewSource(time.Now().UnixNano())))
+
 	trickleTicker := time.NewTicker(trickleTimeout)
 	defer trickleTicker.Stop()
 
@@ -1586,16 +1685,32 @@ out:
 			p.sendQueue <- val.(outMsg)
 
 		case iv := <-p.outputInvChan:
-			if p.VersionKnown() {
+			if !p.VersionKnown() {
+				continue
+			}
+			// Transactions wait for the announcement timer of
+			// the peer.
+			if iv.Type == wire.InvTypeTx {
+				txInvs.Push(iv)
+			} else {
 				invSendQueue.PushBack(iv)
 			}
 
-		case <-trickleTicker.C:
-			// Don't send anything if we're disconnecting or there
-			// is no queued inventory.
-			// version is known if send queue has any entries.
-			if atomic.LoadInt32(&p.disconnect) != 0 ||
-				invSendQueue.Len() == 0 {
+		case now := <-trickleTicker.C:
+			// Don't send anything if we're disconnecting.
+			if atomic.LoadInt32(&p.disconnect) != 0 {
+				continue
+			}
+
+			// The queued transactions are released in random
+			// order when the announcement timer expires.
+			for _, iv := range txInvs.Pop(now) {
+				invSendQueue.PushBack(iv)
+			}
+
+			// Don't send anything if there is no queued
+			// inventory.
+			if invSendQueue.Len() == 0 {
 				continue
 			}
 
@@ -2031,6 +2146,16 @@ func (p *Peer) Disconnect() {
 func (p *Peer) start(phCh chan<- *PeerMessage, newPeerCallback func(*Peer)) error {
 	log.Trace("Starting peer %s", p)
 
+	// The whole version/verack handshake must complete within the peer
+	// timeout of the connection being made.  The read deadline unblocks the
+	// negotiation below should the remote peer never send its version.
+	timeout := p.Cfg.PeerTimeout
+	if timeout == 0 {
+		timeout = DefaultPeerTimeout
+	}
+	deadline := p.timeConnected.Add(timeout)
+	p.conn.SetReadDeadline(deadline)
+
 	negotiateErr := make(chan error, 1)
 	go func() {
 		if p.inbound {
@@ -2042,7 +2167,7 @@ func (p *Peer) start(phCh chan<- *PeerMessage, newPeerCallback func(*Peer)) erro
 	}()
 
 	missVersion := false
-	// Negotiate the protocol within the specified negotiateTimeout.
+	// Negotiate the protocol before the handshake deadline.
 	select {
 	case err := <-negotiateErr:
 		if err != nil {
@@ -2053,7 +2178,7 @@ func (p *Peer) start(phCh chan<- *PeerMessage, newPeerCallback func(*Peer)) erro
 				return err
 			}
 		}
-	case <-time.After(negotiateTimeout):
+	case <-time.After(time.Until(deadline)):
 		return errors.New("protocol negotiation timeout")
 	}
 	log.Debug("Connected to %s", p.Addr())
@@ -2076,10 +2201,28 @@ func (p *Peer) start(phCh chan<- *PeerMessage, newPeerCallback func(*Peer)) erro
 	go p.queueHandler()
 	go p.outHandler()
 	go p.pingHandler()
+	go p.handshakeHandler(deadline)
 
 	return nil
 }
 
+// handshakeHandler disconnects the peer if it has not acknowledged our version
+// by the passed deadline.  It must be run as a goroutine.
+func (p *Peer) handshakeHandler(deadline time.Time) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		if !p.VerAckReceived() {
+			log.Warn("Peer %s did not complete the handshake within "+
+				"%s -- disconnecting", p, deadline.Sub(p.timeConnected))
+			p.Disconnect()
+		}
+	case <-p.quit:
+	}
+}
+
 // WaitForDisconnect waits until the peer has completely disconnected and all
 // resources are cleaned up.  This will happen if either the local or remote
 // side has been disconnected or the peer is forcibly disconnected via
@@ -6,6 +6,7 @@ package peer_test
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"github.com/copernet/copernicus/conf"
@@ -1006,6 +1007,144 @@ func TestUnsupportedVersionPeer(t *testing.T) {
 	}
 }
 
+// connectVersionedPeer connects an outbound peer to a fake remote peer which
+// sends its version and then nothing but what the caller writes to the
+// returned connection.  Everything the peer sends is drained.
+func connectVersionedPeer(t *testing.T, peerCfg *peer.Config, addr string) (*peer.Peer, *conn) {
+	msgChan := make(chan *peer.PeerMessage)
+	server.SetMsgHandle(context.TODO(), msgChan, myserver)
+
+	localNA := wire.NewNetAddressIPPort(
+		net.ParseIP("10.0.0.1"),
+		uint16(8333),
+		wire.SFNodeNetwork,
+	)
+	remoteNA := wire.NewNetAddressIPPort(
+		net.ParseIP("10.0.0.2"),
+		uint16(8333),
+		wire.SFNodeNetwork,
+	)
+	localConn, remoteConn := pipe(
+		&conn{laddr: "10.0.0.1:8333", raddr: "10.0.0.2:8333"},
+		&conn{laddr: "10.0.0.2:8333", raddr: "10.0.0.1:8333"},
+	)
+
+	p, err := peer.NewOutboundPeer(peerCfg, addr, false)
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err - %v\n", err)
+	}
+	p.AssociateConnection(localConn, msgChan, func(*peer.Peer) {})
+
+	go func() {
+		for {
+			_, _, _, err := wire.ReadMessageN(remoteConn,
+				p.ProtocolVersion(), peerCfg.ChainParams.BitcoinNet)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	versionMsg := wire.NewMsgVersion(remoteNA, localNA, 0, 0)
+	_, err = wire.WriteMessageN(remoteConn.Writer, versionMsg,
+		peer.MaxProtocolVersion, peerCfg.ChainParams.BitcoinNet)
+	if err != nil {
+		t.Fatalf("wire.WriteMessageN: unexpected err - %v\n", err)
+	}
+	return p, remoteConn
+}
+
+// Tests that the node disconnects from a peer that sends its version but
+// stalls without acknowledging ours.
+func TestStalledHandshakePeer(t *testing.T) {
+	peerTimeout := 500 * time.Millisecond
+	peerCfg := &peer.Config{
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &model.MainNetParams,
+		Services:         0,
+		PeerTimeout:      peerTimeout,
+	}
+	start := time.Now()
+	p, _ := connectVersionedPeer(t, peerCfg, "10.0.0.1:8333")
+
+	disconnected := make(chan struct{})
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+
+	select {
+	case <-disconnected:
+		t.Fatalf("Peer disconnected after %v, before the handshake "+
+			"deadline", time.Since(start))
+	case <-time.After(peerTimeout / 2):
+	}
+	if !p.VersionKnown() || p.VerAckReceived() {
+		t.Fatalf("Expected a known version without verack, got "+
+			"version known %v verack %v", p.VersionKnown(),
+			p.VerAckReceived())
+	}
+
+	select {
+	case <-disconnected:
+		if elapsed := time.Since(start); elapsed < peerTimeout {
+			t.Fatalf("Peer disconnected after %v, before the "+
+				"handshake deadline of %v", elapsed, peerTimeout)
+		}
+	case <-time.After(peerTimeout + time.Second):
+		t.Fatal("Peer was not disconnected at the handshake deadline")
+	}
+}
+
+// Tests that the node disconnects from a peer sending a message header that
+// can't be trusted, without waiting for the payload it claims.  This holds for
+// regression test peers on localhost too, which may send malformed payloads.
+func TestBadHeaderPeer(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  *model.BitcoinParams
+		addr    string
+		magic   wire.BitcoinNet
+		command string
+		length  uint32
+	}{
+		{"bad magic", &model.MainNetParams, "10.0.0.1:8333", wire.TestNet3, "inv", 100},
+		{"oversized length", &model.MainNetParams, "10.0.0.1:8333", wire.MainNet, "inv", 0xffffffff},
+		{"regtest bad magic", &model.RegressionNetParams, "127.0.0.1:18444", wire.MainNet, "inv", 100},
+		{"regtest oversized length", &model.RegressionNetParams, "127.0.0.1:18444", wire.RegTestNet, "inv", 0xffffffff},
+	}
+
+	for _, test := range tests {
+		peerCfg := &peer.Config{
+			UserAgentName:    "peer",
+			UserAgentVersion: "1.0",
+			ChainParams:      test.params,
+			Services:         0,
+		}
+		p, remoteConn := connectVersionedPeer(t, peerCfg, test.addr)
+
+		header := make([]byte, wire.MessageHeaderSize)
+		binary.LittleEndian.PutUint32(header, uint32(test.magic))
+		copy(header[4:], test.command)
+		binary.LittleEndian.PutUint32(header[16:], test.length)
+		if _, err := remoteConn.Write(header); err != nil {
+			t.Fatalf("%s: write header: %v", test.name, err)
+		}
+
+		disconnected := make(chan struct{})
+		go func() {
+			p.WaitForDisconnect()
+			close(disconnected)
+		}()
+		select {
+		case <-disconnected:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: peer was not disconnected", test.name)
+		}
+	}
+}
+
 func init() {
 	// Allow self connection when running the tests.
 	peer.TstAllowSelfConns()
@@ -0,0 +1,70 @@
+package peer
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/copernet/copernicus/net/wire"
+)
+
+const (
+	// inboundTxInvInterval is the average interval between two
+	// transaction inventory announcements to an inbound peer.
+	inboundTxInvInterval = 5 * time.Second
+
+	// outboundTxInvInterval is the average interval between two
+	// transaction inventory announcements to an outbound peer. Outbound
+	// peers are chosen by us, so they get transactions faster.
+	outboundTxInvInterval = 2 * time.Second
+)
+
+// txInvQueue batches the transaction inventory announced to a peer and only
+// releases it at random times following a Poisson process, shuffled. This
+// keeps a spy connected to many nodes from learning which node a transaction
+// originates from by the timing or the order of the announcements, including
+// for the transactions submitted to this node.
+//
+// It is not safe for concurrent access, and is only used from the
+// queueHandler.
+type txInvQueue struct {
+	invs     []*wire.InvVect
+	interval time.Duration
+	rand     *rand.Rand
+	nextSend time.Time
+}
+
+// newTxInvQueue returns an empty queue announcing every interval on average,
+// drawing its timers and shuffles from r.
+func newTxInvQueue(interval time.Duration, r *rand.Rand) *txInvQueue {
+	return &txInvQueue{
+		interval: interval,
+		rand:     r,
+	}
+}
+
+// Push queues iv until the next announcement.
+func (q *txInvQueue) Push(iv *wire.InvVect) {
+	q.invs = append(q.invs, iv)
+}
+
+// Len returns the number of queued inventory vectors.
+func (q *txInvQueue) Len() int {
+	return len(q.invs)
+}
+
+// Pop returns the queued inventory vectors in random order if the
+// announcement timer expired at now, and schedules the next announcement.
+// It returns nil otherwise.
+func (q *txInvQueue) Pop(now time.Time) []*wire.InvVect {
+	if now.Before(q.nextSend) {
+		return nil
+	}
+	q.nextSend = now.Add(time.Duration(q.rand.ExpFloat64() * float64(q.interval)))
+
+	invs := q.invs
+	q.invs = nil
+	q.rand.Shuffle(len(invs), func(i, j int) {
+		invs[i], invs[j] = invs[j], invs[i]
+	})
+	return invs
+}
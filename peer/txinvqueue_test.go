@@ -0,0 +1,78 @@
+package peer
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/net/wire"
+	"github.com/copernet/copernicus/util"
+)
+
+func TestTxInvQueue(t *testing.T) {
+	q := newTxInvQueue(outboundTxInvInterval, rand.New(rand.NewSource(1)))
+	now := time.Unix(1600000000, 0)
+
+	// the first announcement schedules the timer
+	if invs := q.Pop(now); len(invs) != 0 {
+		t.Fatalf("expected an empty queue, got %v", invs)
+	}
+
+	queued := make([]*wire.InvVect, 20)
+	for i := range queued {
+		queued[i] = wire.NewInvVect(wire.InvTypeTx, &util.Hash{byte(i)})
+		q.Push(queued[i])
+	}
+
+	// nothing is announced before the timer expires
+	if invs := q.Pop(now); invs != nil {
+		t.Fatalf("inventory announced before the timer expired: %v", invs)
+	}
+	if q.Len() != len(queued) {
+		t.Fatalf("expected %d queued inventory vectors, got %d", len(queued), q.Len())
+	}
+
+	var invs []*wire.InvVect
+	for i := 0; invs == nil; i++ {
+		if i > 1000 {
+			t.Fatal("the announcement timer never expired")
+		}
+		now = now.Add(100 * time.Millisecond)
+		invs = q.Pop(now)
+	}
+	if len(invs) != len(queued) || q.Len() != 0 {
+		t.Fatalf("expected the %d queued inventory vectors, got %d", len(queued), len(invs))
+	}
+
+	seen := make(map[wire.InvVect]bool)
+	inOrder := true
+	for i, iv := range invs {
+		seen[*iv] = true
+		inOrder = inOrder && iv == queued[i]
+	}
+	if len(seen) != len(queued) {
+		t.Errorf("expected %d distinct inventory vectors, got %d", len(queued), len(seen))
+	}
+	if inOrder {
+		t.Error("inventory announced in arrival order")
+	}
+}
+
+func TestTxInvQueueInterval(t *testing.T) {
+	// the intervals between announcements average to the configured one
+	q := newTxInvQueue(inboundTxInvInterval, rand.New(rand.NewSource(1)))
+	now := time.Unix(1600000000, 0)
+	start := now
+
+	const announcements = 1000
+	for n := 0; n < announcements; now = now.Add(10 * time.Millisecond) {
+		q.Push(wire.NewInvVect(wire.InvTypeTx, &util.Hash{}))
+		if q.Pop(now) != nil {
+			n++
+		}
+	}
+	mean := now.Sub(start) / announcements
+	if mean < inboundTxInvInterval*8/10 || mean > inboundTxInvInterval*12/10 {
+		t.Errorf("expected announcements every %v on average, got %v", inboundTxInvInterval, mean)
+	}
+}
@@ -2,6 +2,7 @@ package blkdb
 
 import (
 	"bytes"
+	"errors"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/model/blockindex"
 	"github.com/copernet/copernicus/persist/db"
@@ -20,6 +21,14 @@ type BlockTreeDB struct {
 
 var blockTreeDb *BlockTreeDB
 
+// The schemas of the block tree database. The indexes kept along the block
+// index are versioned apart, their format evolves on its own.
+var blockTreeSchemas = []*db.Schema{
+	{Name: "blockindex", Version: 1},
+	{Name: "txindex", Version: 1},
+	{Name: "blockfilterindex", Version: 1},
+}
+
 type BlockTreeDBConfig struct {
 	Do *db.DBOption
 }
@@ -43,6 +52,11 @@ func newBlockTreeDB(do *db.DBOption) *BlockTreeDB {
 	if err != nil {
 		panic("init DBWrapper failed..." + err.Error())
 	}
+	for _, schema := range blockTreeSchemas {
+		if err := dbw.Migrate(schema); err != nil {
+			panic("init DBWrapper failed..." + err.Error())
+		}
+	}
 	return &BlockTreeDB{
 		dbw: dbw,
 	}
@@ -123,7 +137,7 @@ func (blockTreeDB *BlockTreeDB) WriteBatchSync(fileInfoList map[int32]*block.Blo
 			return err
 		}
 		batch.Write(keyBuf.Bytes(), valueBuf.Bytes())
-		log.Debug("blkDB: write block file info: %d, key: %s, %v", fileNum, hex.EncodeToString(keyBuf.Bytes()), v)
+		log.Print("db", "debug", "blkDB: write block file info: %d, key: %s, %v", fileNum, hex.EncodeToString(keyBuf.Bytes()), v)
 	}
 	valueBuf.Reset()
 	err := util.WriteElements(valueBuf, uint64(lastFile))
@@ -132,7 +146,7 @@ func (blockTreeDB *BlockTreeDB) WriteBatchSync(fileInfoList map[int32]*block.Blo
 		return err
 	}
 	batch.Write([]byte{db.DbLastBlock}, valueBuf.Bytes())
-	log.Debug("blkDB: write lastFile: %d, key: %s", lastFile, hex.EncodeToString([]byte{db.DbLastBlock}))
+	log.Print("db", "debug", "blkDB: write lastFile: %d, key: %s", lastFile, hex.EncodeToString([]byte{db.DbLastBlock}))
 
 	for _, v := range blockIndexes {
 		keyBuf.Reset()
@@ -166,6 +180,9 @@ func (blockTreeDB *BlockTreeDB) ReadTxIndex(txid *util.Hash) (*block.DiskTxPos,
 	tmp = append(tmp, db.DbTxIndex)
 	tmp = append(tmp, txid[:]...)
 	vdata, err := blockTreeDB.dbw.Read(tmp)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
 	if err != nil {
 		log.Error("Error: ReadTxIndex======%#v", err)
 		panic("Error: ReadTxIndex======")
@@ -205,6 +222,71 @@ func (blockTreeDB *BlockTreeDB) WriteTxIndex(txIndexes map[util.Hash]block.DiskT
 	return blockTreeDB.dbw.WriteBatch(batch, false)
 }
 
+func (blockTreeDB *BlockTreeDB) ReadTxIndexBestHeight() (int32, error) {
+	data, err := blockTreeDB.dbw.Read([]byte{db.DbTxIndexBest})
+	if err != nil {
+		return 0, err
+	}
+	var height int32
+	err = util.ReadElements(bytes.NewBuffer(data), &height)
+	return height, err
+}
+
+func (blockTreeDB *BlockTreeDB) WriteTxIndexBestHeight(height int32) error {
+	valueBuf := bytes.NewBuffer(nil)
+	if err := util.WriteElements(valueBuf, height); err != nil {
+		return err
+	}
+	return blockTreeDB.dbw.Write([]byte{db.DbTxIndexBest}, valueBuf.Bytes(), false)
+}
+
+// ReadBlockFilter returns the serialized basic filter of the block and its
+// filter header, or nil if the block filter index does not know the block.
+func (blockTreeDB *BlockTreeDB) ReadBlockFilter(hash *util.Hash) ([]byte, *util.Hash, error) {
+	key := append([]byte{db.DbBlockFilter}, hash[:]...)
+	vdata, err := blockTreeDB.dbw.Read(key)
+	if err == leveldb.ErrNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(vdata) < util.Hash256Size {
+		return nil, nil, errors.New("blkDB: block filter entry too short")
+	}
+	var header util.Hash
+	copy(header[:], vdata[:util.Hash256Size])
+	return vdata[util.Hash256Size:], &header, nil
+}
+
+// WriteBlockFilter stores the serialized basic filter of the block along with
+// its filter header.
+func (blockTreeDB *BlockTreeDB) WriteBlockFilter(hash *util.Hash, filter []byte, header *util.Hash) error {
+	key := append([]byte{db.DbBlockFilter}, hash[:]...)
+	value := make([]byte, 0, util.Hash256Size+len(filter))
+	value = append(value, header[:]...)
+	value = append(value, filter...)
+	return blockTreeDB.dbw.Write(key, value, false)
+}
+
+func (blockTreeDB *BlockTreeDB) ReadBlockFilterBestHeight() (int32, error) {
+	data, err := blockTreeDB.dbw.Read([]byte{db.DbBlockFilterBest})
+	if err != nil {
+		return 0, err
+	}
+	var height int32
+	err = util.ReadElements(bytes.NewBuffer(data), &height)
+	return height, err
+}
+
+func (blockTreeDB *BlockTreeDB) WriteBlockFilterBestHeight(height int32) error {
+	valueBuf := bytes.NewBuffer(nil)
+	if err := util.WriteElements(valueBuf, height); err != nil {
+		return err
+	}
+	return blockTreeDB.dbw.Write([]byte{db.DbBlockFilterBest}, valueBuf.Bytes(), false)
+}
+
 func (blockTreeDB *BlockTreeDB) WriteFlag(name string, value bool) error {
 	tmp := make([]byte, 0, 100)
 	tmp = append(tmp, db.DbFlag)
@@ -221,7 +303,7 @@ func (blockTreeDB *BlockTreeDB) ReadFlag(name string) bool {
 	tmp = append(tmp, name...)
 	b, err := blockTreeDB.dbw.Read(tmp)
 
-	if b[0] == '1' && err == nil {
+	if err == nil && len(b) > 0 && b[0] == '1' {
 		return true
 	}
 	return false
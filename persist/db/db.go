@@ -29,11 +29,17 @@ const (
 	DbFlag        byte = 'F'
 	DbReindexFlag byte = 'R'
 	DbLastBlock   byte = 'l'
+	DbTxIndexBest byte = 'T'
+
+	DbBlockFilter     byte = 'g'
+	DbBlockFilterBest byte = 'G'
 
 	DbWalletKey      byte = 'W'
 	DbWalletScript   byte = 'S'
 	DbWalletAddrBook byte = 'A'
 	DbWalletTx       byte = 'X'
+
+	DbSchemaVersion byte = 'v'
 )
 
 const (
@@ -56,6 +62,9 @@ type DBWrapper struct {
 	mdb          *memdb.DB
 	name         string
 	obfuscateKey []byte
+
+	// created is set when the database held nothing when it was opened.
+	created bool
 }
 
 func genObfuscateKey() []byte {
@@ -135,7 +144,8 @@ func NewDBWrapper(do *DBOption) (*DBWrapper, error) {
 	if do.UseMemStore {
 		mdb := memdb.New(comparer.DefaultComparer, do.CacheSize)
 		dbw := &DBWrapper{
-			mdb: mdb,
+			mdb:     mdb,
+			created: true,
 		}
 		if err := writeObfuscateKey(do, dbw); err != nil {
 			return nil, err
@@ -188,6 +198,7 @@ func NewDBWrapper(do *DBOption) (*DBWrapper, error) {
 		name:        filepath.Base(do.FilePath),
 		//obfuscateKey: make([]byte, 8),
 	}
+	dbw.created = dbw.IsEmpty()
 	if err := writeObfuscateKey(do, dbw); err != nil {
 		return nil, err
 	}
@@ -220,7 +231,7 @@ func (dbw *DBWrapper) Read(key []byte) ([]byte, error) {
 		value, err = dbw.db.Get(key, &dbw.readOption)
 	}
 	if err != nil {
-		log.Debug("Read DB key: %s err: %v", hex.EncodeToString(key), err)
+		log.Print("db", "debug", "Read DB key: %s err: %v", hex.EncodeToString(key), err)
 		return nil, err
 	}
 	xor(value, dbw.obfuscateKey)
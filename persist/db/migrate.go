@@ -0,0 +1,117 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/copernet/copernicus/log"
+	lvldb "github.com/syndtr/goleveldb/leveldb"
+)
+
+// SchemaError is returned by Migrate when the database was written by a newer
+// version of the node, with a schema version it does not know.
+type SchemaError struct {
+	Name      string
+	Version   uint32
+	Supported uint32
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s version %d, the node supports up to %d: database schema is newer than supported",
+		e.Name, e.Version, e.Supported)
+}
+
+// Migration upgrades the data of a schema from the version before Version to
+// Version.
+type Migration struct {
+	Version     uint32
+	Description string
+	Migrate     func(dbw *DBWrapper) error
+}
+
+// Schema is a layout of data in a database, versioned on its own, so several
+// of them can share a database. Version is the version the node reads and
+// writes, Migrations upgrade the older versions to it, in increasing version
+// order.
+//
+// A database written before it recorded the version of a schema has version
+// 1 of the schema.
+type Schema struct {
+	Name       string
+	Version    uint32
+	Migrations []Migration
+}
+
+func schemaVersionKey(name string) []byte {
+	return append([]byte{DbSchemaVersion}, name...)
+}
+
+// SchemaVersion returns the version of the schema name recorded in the
+// database, and false if there is none.
+func (dbw *DBWrapper) SchemaVersion(name string) (uint32, bool, error) {
+	data, err := dbw.Read(schemaVersionKey(name))
+	if err == lvldb.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if len(data) != 4 {
+		return 0, false, fmt.Errorf("invalid %s schema version %x", name, data)
+	}
+	return binary.LittleEndian.Uint32(data), true, nil
+}
+
+// WriteSchemaVersion records version as the version of the schema name.
+func (dbw *DBWrapper) WriteSchemaVersion(name string, version uint32) error {
+	var data [4]byte
+	binary.LittleEndian.PutUint32(data[:], version)
+	return dbw.Write(schemaVersionKey(name), data[:], true)
+}
+
+// Migrate brings the data of schema up to schema.Version, applying in order
+// the migrations from the version recorded in the database. The version is
+// recorded after each migration, a migration interrupted by a crash is run
+// again on the next start. A new database gets schema.Version as is, and a
+// database of a newer version fails with a *SchemaError.
+func (dbw *DBWrapper) Migrate(schema *Schema) error {
+	version, found, err := dbw.SchemaVersion(schema.Name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if dbw.created {
+			return dbw.WriteSchemaVersion(schema.Name, schema.Version)
+		}
+		version = 1
+	}
+	if version > schema.Version {
+		return &SchemaError{Name: schema.Name, Version: version, Supported: schema.Version}
+	}
+
+	for _, migration := range schema.Migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if migration.Version != version+1 || migration.Version > schema.Version {
+			return fmt.Errorf("%s has no migration from version %d to %d",
+				schema.Name, version, schema.Version)
+		}
+		log.Info("Migrating %s to version %d: %s", schema.Name, migration.Version, migration.Description)
+		if err := migration.Migrate(dbw); err != nil {
+			return fmt.Errorf("migrate %s to version %d: %v", schema.Name, migration.Version, err)
+		}
+		if err := dbw.WriteSchemaVersion(schema.Name, migration.Version); err != nil {
+			return err
+		}
+		version = migration.Version
+	}
+	if version != schema.Version {
+		return fmt.Errorf("%s has no migration from version %d to %d",
+			schema.Name, version, schema.Version)
+	}
+	if !found {
+		return dbw.WriteSchemaVersion(schema.Name, version)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string) *DBWrapper {
+	dbw, err := NewDBWrapper(&DBOption{FilePath: path, CacheSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("NewDBWrapper failed: %s\n", err)
+	}
+	return dbw
+}
+
+func TestMigrate(t *testing.T) {
+	path, err := ioutil.TempDir("", "dbwtest")
+	if err != nil {
+		t.Fatalf("generate temp db path failed: %s\n", err)
+	}
+	defer os.RemoveAll(path)
+
+	var applied []uint32
+	migration := func(version uint32) Migration {
+		return Migration{
+			Version: version,
+			Migrate: func(dbw *DBWrapper) error {
+				applied = append(applied, version)
+				return dbw.Write([]byte{'m', byte(version)}, []byte{1}, false)
+			},
+		}
+	}
+	schema := &Schema{Name: "test", Version: 1}
+
+	// A new database starts at the current version.
+	dbw := openTestDB(t, path)
+	if err := dbw.Migrate(schema); err != nil {
+		t.Fatalf("migrate new db failed: %v", err)
+	}
+	if version, found, _ := dbw.SchemaVersion("test"); !found || version != 1 {
+		t.Fatalf("new db version %d, %v, want 1", version, found)
+	}
+	dbw.Close()
+
+	// An older database runs the migrations in order.
+	schema = &Schema{Name: "test", Version: 3, Migrations: []Migration{migration(2), migration(3)}}
+	dbw = openTestDB(t, path)
+	if err := dbw.Migrate(schema); err != nil {
+		t.Fatalf("migrate db failed: %v", err)
+	}
+	if !reflect.DeepEqual(applied, []uint32{2, 3}) {
+		t.Errorf("applied migrations %v, want [2 3]", applied)
+	}
+	if version, _, _ := dbw.SchemaVersion("test"); version != 3 {
+		t.Errorf("migrated db version %d, want 3", version)
+	}
+	if !dbw.Exists([]byte{'m', 3}) {
+		t.Error("data of the last migration is missing")
+	}
+
+	// Other schemas of the database are versioned apart.
+	if _, found, _ := dbw.SchemaVersion("other"); found {
+		t.Error("other schema has a version")
+	}
+
+	// A migrated database does not migrate again.
+	applied = nil
+	if err := dbw.Migrate(schema); err != nil || len(applied) != 0 {
+		t.Errorf("migrate up to date db applied %v, %v", applied, err)
+	}
+	dbw.Close()
+
+	// A database of a newer version is refused.
+	dbw = openTestDB(t, path)
+	defer dbw.Close()
+	err = dbw.Migrate(&Schema{Name: "test", Version: 2})
+	if e, ok := err.(*SchemaError); !ok || e.Version != 3 || e.Supported != 2 {
+		t.Errorf("migrate newer db returned %v, want a schema error", err)
+	}
+}
+
+func TestMigrateUnversioned(t *testing.T) {
+	path, err := ioutil.TempDir("", "dbwtest")
+	if err != nil {
+		t.Fatalf("generate temp db path failed: %s\n", err)
+	}
+	defer os.RemoveAll(path)
+
+	dbw := openTestDB(t, path)
+	if err := dbw.Write([]byte{'k'}, []byte{1}, true); err != nil {
+		t.Fatalf("dbw.Write(): %s", err)
+	}
+	dbw.Close()
+
+	// A database written before versioning has version 1.
+	migrated := false
+	dbw = openTestDB(t, path)
+	defer dbw.Close()
+	err = dbw.Migrate(&Schema{Name: "test", Version: 2, Migrations: []Migration{{
+		Version: 2,
+		Migrate: func(dbw *DBWrapper) error {
+			migrated = true
+			return nil
+		},
+	}}})
+	if err != nil || !migrated {
+		t.Fatalf("migrate unversioned db returned %v, migrated %v", err, migrated)
+	}
+	if version, _, _ := dbw.SchemaVersion("test"); version != 2 {
+		t.Errorf("migrated db version %d, want 2", version)
+	}
+}
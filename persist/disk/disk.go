@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -187,7 +188,7 @@ func UndoReadFromDisk(pos *block.DiskBlockPos, hashblock util.Hash) (*undo.Block
 		return nil, false
 	}
 
-	log.Debug("UndoReadFromDisk: read undo file size: %d and num: %d.", size, num)
+	log.Print("db", "debug", "UndoReadFromDisk: read undo file size: %d and num: %d.", size, num)
 	if uint32(num) < size {
 		log.Error("UndoReadFromDisk: read undo num(%d) < size(%d)", num, size)
 		return nil, false
@@ -234,17 +235,9 @@ func ReadBlockFromDiskByPos(pos block.DiskBlockPos, param *model.BitcoinParams)
 		log.Error("ReadBlockFromDisk: read block file len failed for %s", pos.String())
 		return nil, err
 	}
-	//read block data to tmp buff
-	tmp := make([]byte, size)
-	n, err := file.Read(tmp)
-	if err != nil || n != int(size) {
-		log.Error("ReadBlockFromDisk: read block file len != size failed for %s, %s", pos.String(), err)
-		return nil, err
-	}
-	buf := bytes.NewBuffer(tmp)
 	// Read block
-	blk = block.NewBlock()
-	if err := blk.Unserialize(buf); err != nil {
+	blk, err = block.DeserializeFrom(io.LimitReader(file, int64(size)))
+	if err != nil {
 		log.Error("ReadBlockFromDiskByPos: Unserialize or I/O error - %s at %s", err.Error(), pos.String())
 		return nil, err
 	}
@@ -259,6 +252,31 @@ func ReadBlockFromDiskByPos(pos block.DiskBlockPos, param *model.BitcoinParams)
 	return blk, err
 }
 
+// ReadBlockSize returns the length of the block at pos, as stored before it.
+func ReadBlockSize(pos block.DiskBlockPos) (uint32, error) {
+	file := OpenBlockFile(&pos, true)
+	if file == nil {
+		log.Error("ReadBlockSize: OpenBlockFile failed for %s", pos.String())
+		return 0, errors.New("ErrOpenBlockFile")
+	}
+	defer file.Close()
+	return util.BinarySerializer.Uint32(file, binary.LittleEndian)
+}
+
+// GetBlockFileInfo returns a copy of the information on the block file
+// fileNumber, nil when there is no such file.
+func GetBlockFileInfo(fileNumber int32) *block.BlockFileInfo {
+	persist.CsLastBlockFile.Lock()
+	defer persist.CsLastBlockFile.Unlock()
+
+	infos := persist.GetInstance().GlobalBlockFileInfo
+	if fileNumber < 0 || int(fileNumber) >= len(infos) {
+		return nil
+	}
+	info := *infos[fileNumber]
+	return &info
+}
+
 func ReadBlockFromDisk(pindex *blockindex.BlockIndex, param *model.BitcoinParams) (*block.Block, bool) {
 	blk, err := ReadBlockFromDiskByPos(pindex.GetBlockPos(), param)
 	if err != nil {
@@ -284,20 +302,14 @@ func WriteBlockToDisk(block *block.Block, pos *block.DiskBlockPos) bool {
 	}
 	defer file.Close()
 
-	buf := bytes.NewBuffer(nil)
-	buf.Write(make([]byte, 4)) //reserve 4 bytes for block data length
-	err := block.Serialize(buf)
+	// the block data is preceded by its length
+	err := util.BinarySerializer.PutUint32(file, binary.LittleEndian, uint32(block.SerializeSize()))
 	if err != nil {
-		log.Error("Serialize buf error: %v", err)
+		log.Error("WriteBlockToDisk: write block length error: %v", err)
 		return false
 	}
-
-	//fill actual block data length in leading 4 bytes
-	binary.LittleEndian.PutUint32(buf.Bytes(), uint32(buf.Len()-4))
-
-	_, err = file.Write(buf.Bytes())
-	if err != nil {
-		log.Error("WriteBlockToDisk: write buf.Bytes() error: %v", err)
+	if err := block.SerializeTo(file); err != nil {
+		log.Error("WriteBlockToDisk: write block error: %v", err)
 		return false
 	}
 	return true
@@ -678,39 +690,31 @@ func CalculateCurrentUsage() uint64 {
 //	log.Info("Prune (Manual): prune_height=%d removed %d blk/rev pairs\n", lastBlockWeCanPrune, count)
 //}
 
-// PruneOneBlockFile prune a block file (modify associated database entries)
-func PruneOneBlockFile(fileNumber int32) {
-	bm := make(map[util.Hash]*blockindex.BlockIndex)
+// PruneOneBlockFile forgets the data of the blocks among indexes stored in
+// the block file fileNumber, and empties the file info. The files themselves
+// are deleted by UnlinkPrunedFiles once the block index is written.
+func PruneOneBlockFile(fileNumber int32, indexes []*blockindex.BlockIndex) {
 	gPersist := persist.GetInstance()
-	for _, value := range bm {
-		pindex := value
-		if pindex.File == fileNumber {
-			pindex.Status &= ^blockindex.BlockHaveData
-			pindex.Status &= ^blockindex.BlockHaveUndo
-			pindex.File = 0
-			pindex.DataPos = 0
-			pindex.UndoPos = 0
-			gPersist.AddDirtyBlockIndex(pindex)
-
-			// Prune from mapBlocksUnlinked -- any block we prune would have
-			// to be downloaded again in order to consider its chain, at which
-			// point it would be considered as a candidate for
-			// mapBlocksUnlinked or setBlockIndexCandidates.
-			ranges := gPersist.GlobalMapBlocksUnlinked[pindex.Prev]
-			tmpRange := make([]*blockindex.BlockIndex, len(ranges))
-			copy(tmpRange, ranges)
-			for len(tmpRange) > 0 {
-				v := tmpRange[0]
-				tmpRange = tmpRange[1:]
-				if v == pindex {
-					tmp := make([]*blockindex.BlockIndex, len(ranges)-1)
-					for _, val := range tmpRange {
-						if val != v {
-							tmp = append(tmp, val)
-						}
-					}
-					gPersist.GlobalMapBlocksUnlinked[pindex.Prev] = tmp
-				}
+	for _, pindex := range indexes {
+		if pindex.File != fileNumber || pindex.Status&(blockindex.BlockHaveData|blockindex.BlockHaveUndo) == 0 {
+			continue
+		}
+		pindex.SubStatus(blockindex.BlockHaveData)
+		pindex.SubStatus(blockindex.BlockHaveUndo)
+		pindex.File = 0
+		pindex.DataPos = 0
+		pindex.UndoPos = 0
+		gPersist.AddDirtyBlockIndex(pindex)
+
+		// Prune from mapBlocksUnlinked -- any block we prune would have
+		// to be downloaded again in order to consider its chain, at which
+		// point it would be considered as a candidate for
+		// mapBlocksUnlinked or setBlockIndexCandidates.
+		unlinked := gPersist.GlobalMapBlocksUnlinked[pindex.Prev]
+		for i, v := range unlinked {
+			if v == pindex {
+				gPersist.GlobalMapBlocksUnlinked[pindex.Prev] = append(unlinked[:i:i], unlinked[i+1:]...)
+				break
 			}
 		}
 	}
@@ -720,17 +724,20 @@ func PruneOneBlockFile(fileNumber int32) {
 }
 
 func UnlinkPrunedFiles(setFilesToPrune *set.Set) {
-	lists := setFilesToPrune.List()
-	for key, value := range lists {
-		v := value.(int32)
-		pos := &block.DiskBlockPos{
-			File: v,
-			Pos:  0,
-		}
-		os.Remove(GetBlockPosFilename(*pos, "blk"))
-		os.Remove(GetBlockPosFilename(*pos, "rev"))
-		log.Info("Prune: %s deleted blk/rev (%05u)\n", key)
+	for _, value := range setFilesToPrune.List() {
+		UnlinkPrunedFile(value.(int32))
+	}
+}
+
+// UnlinkPrunedFile deletes the block and undo files fileNumber.
+func UnlinkPrunedFile(fileNumber int32) {
+	pos := block.DiskBlockPos{
+		File: fileNumber,
+		Pos:  0,
 	}
+	os.Remove(GetBlockPosFilename(pos, "blk"))
+	os.Remove(GetBlockPosFilename(pos, "rev"))
+	log.Info("Prune: deleted blk/rev (%05d)", fileNumber)
 }
 
 func GetPruneState() *persist.PruneState {
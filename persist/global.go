@@ -12,13 +12,18 @@ import (
 )
 
 const (
+	DefaultMaxMemPoolSize = 300
+)
+
+// The sizes of the block and undo files are variables so that tests can
+// spread a short chain over several files.
+var (
 	// MaxBlockFileSize is the maximum size of a blk?????.dat file (since 0.8) */
 	MaxBlockFileSize = uint32(0x8000000)
 	// BlockFileChunkSize is the pre-allocation chunk size for blk?????.dat files (since 0.8)
-	BlockFileChunkSize = 0x1000000
+	BlockFileChunkSize = uint32(0x1000000)
 	// UndoFileChunkSize is the pre-allocation chunk size for rev?????.dat files (since 0.8) */
-	UndoFileChunkSize     = 0x100000
-	DefaultMaxMemPoolSize = 300
+	UndoFileChunkSize = uint32(0x100000)
 )
 
 var (
@@ -78,7 +83,7 @@ func (pg *PersistGlobal) LoadBlockFileInfo(btd *blkdb.BlockTreeDB) {
 	globalLastBlockFile, err := btd.ReadLastBlockFile()
 	globalBlockFileInfo := make([]*block.BlockFileInfo, 0, globalLastBlockFile+1)
 	if err != nil {
-		log.Debug("ReadLastBlockFile() from DB err:%#v", err)
+		log.Print("db", "debug", "ReadLastBlockFile() from DB err:%#v", err)
 	} else {
 		var nFile int32
 		for ; nFile <= globalLastBlockFile; nFile++ {
@@ -92,7 +97,7 @@ func (pg *PersistGlobal) LoadBlockFileInfo(btd *blkdb.BlockTreeDB) {
 		for nFile = globalLastBlockFile + 1; true; nFile++ {
 			bfi, err = btd.ReadBlockFileInfo(nFile)
 			if bfi != nil && err == nil {
-				log.Debug("LoadBlockIndexDB: the last block file info: %d is less than real block file info: %d",
+				log.Print("db", "debug", "LoadBlockIndexDB: the last block file info: %d is less than real block file info: %d",
 					globalLastBlockFile, nFile)
 				globalBlockFileInfo = append(globalBlockFileInfo, bfi)
 				globalLastBlockFile = nFile
@@ -103,7 +108,7 @@ func (pg *PersistGlobal) LoadBlockFileInfo(btd *blkdb.BlockTreeDB) {
 	}
 	pg.GlobalBlockFileInfo = globalBlockFileInfo
 	pg.GlobalLastBlockFile = globalLastBlockFile
-	log.Debug("LoadBlockIndexDB: Read last block file info: %d, block file info len:%d",
+	log.Print("db", "debug", "LoadBlockIndexDB: Read last block file info: %d, block file info len:%d",
 		globalLastBlockFile, len(globalBlockFileInfo))
 
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/copernet/copernicus/log"
+)
+
+// startProfileServer serves the net/http/pprof profiles on ip:port and
+// returns its listener, or nil when port is empty and profiling is disabled.
+// The profiles expose the internals of the node, ip should be a loopback
+// address.
+func startProfileServer(ip, port string) (net.Listener, error) {
+	if port == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return nil, err
+	}
+	if host := net.ParseIP(ip); ip != "localhost" && (host == nil || !host.IsLoopback()) {
+		log.Warn("Profile server listening on %s, which is not a loopback address", listener.Addr())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", http.RedirectHandler("/debug/pprof/", http.StatusSeeOther))
+	go func() {
+		err := http.Serve(listener, mux)
+		log.Info("Profile server on %s stopped: %v", listener.Addr(), err)
+	}()
+	return listener, nil
+}
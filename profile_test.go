@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProfileServer(t *testing.T) {
+	listener, err := startProfileServer("localhost", "0")
+	if err != nil {
+		t.Fatalf("start profile server failed: %v", err)
+	}
+	defer listener.Close()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/debug/pprof/")
+	if err != nil {
+		t.Fatalf("get pprof index failed: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine") {
+		t.Errorf("pprof index returned %d: %s", resp.StatusCode, body)
+	}
+	if ip := listener.Addr().String(); !strings.HasPrefix(ip, "127.0.0.1:") && !strings.HasPrefix(ip, "[::1]:") {
+		t.Errorf("profile server listens on %s, not on loopback", ip)
+	}
+}
+
+func TestProfileServerDisabled(t *testing.T) {
+	listener, err := startProfileServer("localhost", "")
+	if listener != nil || err != nil {
+		t.Fatalf("disabled profile server returned %v, %v", listener, err)
+	}
+}
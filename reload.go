@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lmempool"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+// reloadHooks are the changes to the running node a reload makes besides
+// updating conf.Cfg. A nil hook is skipped.
+type reloadHooks struct {
+	// connectPeer connects to a peer added to ConnectPeersOnStart.
+	connectPeer func(addr string) error
+	// removePeer disconnects a peer removed from ConnectPeersOnStart.
+	removePeer func(addr string) error
+	// sendFeeFilter announces the min relay fee to the peers again.
+	sendFeeFilter func()
+}
+
+// reloadConfig applies the settings of newCfg that can change while the node
+// runs, see conf.Configuration.Reload, and logs the changed ones that need a
+// restart.
+func reloadConfig(newCfg *conf.Configuration, hooks reloadHooks) {
+	oldPeers := conf.Cfg.ConnectPeersOnStart()
+	applied, ignored := conf.Cfg.Reload(newCfg)
+	for _, name := range ignored {
+		log.Warn("Setting %s changed, it takes effect after a restart", name)
+	}
+
+	relayFeesChanged := false
+	for _, name := range applied {
+		log.Info("Reloaded setting %s", name)
+		switch name {
+		case "Log.Level":
+			if err := log.SetLevel(log.GetLevel(conf.Cfg.LogLevel())); err != nil {
+				log.Error("Failed to set the log level: %v", err)
+			}
+		case "Mempool.MinRelayTxFee", "Mempool.IncrementalRelayFee":
+			relayFeesChanged = true
+		case "Mempool.MaxPoolSize":
+			lmempool.TrimToSize()
+		case "P2PNet.ConnectPeersOnStart":
+			updateAddedPeers(oldPeers, conf.Cfg.ConnectPeersOnStart(), hooks)
+		}
+	}
+
+	if relayFeesChanged {
+		mempool.GetInstance().SetRelayFees(*util.NewFeeRatePerK(amount.Amount(conf.Cfg.MinRelayTxFee())),
+			*util.NewFeeRatePerK(amount.Amount(conf.Cfg.IncrementalRelayFee())))
+		if hooks.sendFeeFilter != nil {
+			hooks.sendFeeFilter()
+		}
+	}
+}
+
+// updateAddedPeers connects to the peers of peers that are not in oldPeers,
+// and disconnects the peers of oldPeers that are no longer in peers.
+func updateAddedPeers(oldPeers, peers []string, hooks reloadHooks) {
+	known := make(map[string]struct{}, len(oldPeers))
+	for _, addr := range oldPeers {
+		known[addr] = struct{}{}
+	}
+	for _, addr := range peers {
+		if _, ok := known[addr]; ok {
+			delete(known, addr)
+			continue
+		}
+		if hooks.connectPeer == nil {
+			continue
+		}
+		if err := hooks.connectPeer(addr); err != nil {
+			log.Error("Failed to connect to added peer %s: %v", addr, err)
+		}
+	}
+	for _, addr := range oldPeers {
+		if _, ok := known[addr]; !ok || hooks.removePeer == nil {
+			continue
+		}
+		if err := hooks.removePeer(addr); err != nil {
+			log.Error("Failed to disconnect from removed peer %s: %v", addr, err)
+		}
+	}
+}
+
+// reloadConfigFile reads the configuration again with the command line args
+// and applies it with reloadConfig. A configuration that fails to load is
+// logged and leaves the running one alone.
+func reloadConfigFile(args []string, hooks reloadHooks) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Failed to reload the configuration: %v", r)
+		}
+	}()
+
+	newCfg, err := conf.LoadConfig(args)
+	if err != nil {
+		log.Error("Failed to reload the configuration, keeping the running one: %v", err)
+		return
+	}
+	reloadConfig(newCfg, hooks)
+}
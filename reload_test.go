@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/persist/db"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+func TestReloadTrimsMempool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reloadtest")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	utxo.InitUtxoLruTip(&utxo.UtxoConfig{Do: &db.DBOption{FilePath: dir, CacheSize: 1 << 20}})
+
+	oldCfg := conf.Cfg
+	defer func() { conf.Cfg = oldCfg }()
+	conf.Cfg = &conf.Configuration{}
+	conf.Cfg.Mempool.MaxPoolSize = 300000000
+	conf.Cfg.Mempool.MaxPoolExpiry = 336
+
+	pool := mempool.NewTxMempool()
+	oldPool := mempool.GetInstance()
+	mempool.SetInstance(pool)
+	defer mempool.SetInstance(oldPool)
+
+	noLimit := uint64(math.MaxUint64)
+	for i := 1; i <= 3; i++ {
+		txn := tx.NewTx(0, tx.TxVersion)
+		pkScript := script.NewScriptRaw([]byte{opcodes.OP_11, opcodes.OP_EQUAL})
+		txn.AddTxOut(txout.NewTxOut(amount.Amount(int64(i)*util.COIN), pkScript))
+		entry := mempool.NewTxentry(txn, int64(i)*1000, util.GetTimeSec(), 1, mempool.LockPoints{}, 1, false)
+		ancestors, err := pool.CalculateMemPoolAncestors(txn, noLimit, noLimit, noLimit, noLimit, true)
+		if err != nil {
+			t.Fatalf("calculate ancestors failed: %v", err)
+		}
+		if err := pool.AddTx(entry, ancestors); err != nil {
+			t.Fatalf("add tx failed: %v", err)
+		}
+	}
+	usage := pool.GetPoolUsage()
+
+	newCfg := &conf.Configuration{}
+	*newCfg = *conf.Cfg
+	newCfg.Mempool.MaxPoolSize = usage - 1
+	reloadConfig(newCfg, reloadHooks{})
+
+	if conf.Cfg.MaxPoolSize() != usage-1 {
+		t.Errorf("max mempool size %d not reloaded", conf.Cfg.MaxPoolSize())
+	}
+	if pool.Size() != 2 || pool.GetPoolUsage() > usage-1 {
+		t.Errorf("mempool of %d transactions using %d bytes not trimmed to %d bytes",
+			pool.Size(), pool.GetPoolUsage(), usage-1)
+	}
+}
+
+func TestReloadRelayFees(t *testing.T) {
+	oldCfg := conf.Cfg
+	defer func() { conf.Cfg = oldCfg }()
+	conf.Cfg = &conf.Configuration{}
+	conf.Cfg.Mempool.MinRelayTxFee = 1000
+	conf.Cfg.Mempool.IncrementalRelayFee = 1000
+
+	pool := mempool.NewTxMempool()
+	oldPool := mempool.GetInstance()
+	mempool.SetInstance(pool)
+	defer mempool.SetInstance(oldPool)
+
+	newCfg := &conf.Configuration{}
+	*newCfg = *conf.Cfg
+	newCfg.Mempool.MinRelayTxFee = 2000
+	newCfg.Mempool.IncrementalRelayFee = 3000
+	feeFilterSent := false
+	reloadConfig(newCfg, reloadHooks{sendFeeFilter: func() { feeFilterSent = true }})
+
+	if fee := pool.MinRelayTxFee(); fee.GetFeePerK() != 2000 {
+		t.Errorf("min relay fee %d not reloaded", fee.GetFeePerK())
+	}
+	if fee := pool.IncrementalRelayFee(); fee.GetFeePerK() != 3000 {
+		t.Errorf("incremental relay fee %d not reloaded", fee.GetFeePerK())
+	}
+	if !feeFilterSent {
+		t.Errorf("the new min relay fee was not sent to the peers")
+	}
+}
+
+func TestUpdateAddedPeers(t *testing.T) {
+	var connected, removed []string
+	updateAddedPeers([]string{"127.0.0.1:8333", "127.0.0.2:8333"}, []string{"127.0.0.2:8333", "127.0.0.3:8333"},
+		reloadHooks{
+			connectPeer: func(addr string) error {
+				connected = append(connected, addr)
+				return nil
+			},
+			removePeer: func(addr string) error {
+				removed = append(removed, addr)
+				return nil
+			},
+		})
+
+	if !reflect.DeepEqual(connected, []string{"127.0.0.3:8333"}) {
+		t.Errorf("connected to %v, want the added peer only", connected)
+	}
+	if !reflect.DeepEqual(removed, []string{"127.0.0.1:8333"}) {
+		t.Errorf("disconnected from %v, want the removed peer only", removed)
+	}
+}
@@ -98,6 +98,19 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// AnalyzeScriptCmd defines the analyzescript JSON-RPC command.
+type AnalyzeScriptCmd struct {
+	HexScript string `json:"hexstring"`
+}
+
+// NewAnalyzeScriptCmd returns a new instance which can be used to issue an
+// analyzescript JSON-RPC command.
+func NewAnalyzeScriptCmd(hexScript string) *AnalyzeScriptCmd {
+	return &AnalyzeScriptCmd{
+		HexScript: hexScript,
+	}
+}
+
 // EchoCmd defines the echo JSON-RPC command.
 type EchoCmd struct {
 	Arg0 *string
@@ -208,6 +221,24 @@ func NewGetBlockHeaderCmd(hash string, verbose *bool) *GetBlockHeaderCmd {
 	}
 }
 
+// GetBlockFilterCmd defines the getblockfilter JSON-RPC command.
+type GetBlockFilterCmd struct {
+	BlockHash  string
+	FilterType *string `jsonrpcdefault:"\"basic\""`
+}
+
+// NewGetBlockFilterCmd returns a new instance which can be used to issue a
+// getblockfilter JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockFilterCmd(blockHash string, filterType *string) *GetBlockFilterCmd {
+	return &GetBlockFilterCmd{
+		BlockHash:  blockHash,
+		FilterType: filterType,
+	}
+}
+
 // GetChainTxStatsCmd defines the getchaintxstats JSON-RPC command.
 type GetChainTxStatsCmd struct {
 	Blocks    *int32
@@ -384,6 +415,19 @@ func NewGetMempoolEntryCmd(txHash string) *GetMempoolEntryCmd {
 	}
 }
 
+// GetTransactionStatusCmd defines the gettransactionstatus JSON-RPC command.
+type GetTransactionStatusCmd struct {
+	TxID string
+}
+
+// NewGetTransactionStatusCmd returns a new instance which can be used to issue
+// a gettransactionstatus JSON-RPC command.
+func NewGetTransactionStatusCmd(txHash string) *GetTransactionStatusCmd {
+	return &GetTransactionStatusCmd{
+		TxID: txHash,
+	}
+}
+
 // GetMempoolInfoCmd defines the getmempoolinfo JSON-RPC command.
 type GetMempoolInfoCmd struct{}
 
@@ -393,6 +437,28 @@ func NewGetMempoolInfoCmd() *GetMempoolInfoCmd {
 	return &GetMempoolInfoCmd{}
 }
 
+// SetMempoolLimitCmd defines the setmempoollimit JSON-RPC command.
+type SetMempoolLimitCmd struct {
+	MaxOrphanTx int `json:"maxorphantx"`
+}
+
+// NewSetMempoolLimitCmd returns a new instance which can be used to issue a
+// setmempoollimit JSON-RPC command.
+func NewSetMempoolLimitCmd(maxOrphanTx int) *SetMempoolLimitCmd {
+	return &SetMempoolLimitCmd{
+		MaxOrphanTx: maxOrphanTx,
+	}
+}
+
+// SaveMempoolCmd defines the savemempool JSON-RPC command.
+type SaveMempoolCmd struct{}
+
+// NewSaveMempoolCmd returns a new instance which can be used to issue a
+// savemempool JSON-RPC command.
+func NewSaveMempoolCmd() *SaveMempoolCmd {
+	return &SaveMempoolCmd{}
+}
+
 // GetMiningInfoCmd defines the getmininginfo JSON-RPC command.
 type GetMiningInfoCmd struct{}
 
@@ -698,6 +764,80 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct {
+	IndexName *string
+}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetIndexInfoCmd(indexName *string) *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{
+		IndexName: indexName,
+	}
+}
+
+// GetNodeFeeInfoCmd defines the getnodefeeinfo JSON-RPC command.
+type GetNodeFeeInfoCmd struct{}
+
+// NewGetNodeFeeInfoCmd returns a new instance which can be used to issue a
+// getnodefeeinfo JSON-RPC command.
+func NewGetNodeFeeInfoCmd() *GetNodeFeeInfoCmd {
+	return &GetNodeFeeInfoCmd{}
+}
+
+// LoggingCmd defines the logging JSON-RPC command.
+type LoggingCmd struct {
+	Include *[]string
+	Exclude *[]string
+}
+
+// NewLoggingCmd returns a new instance which can be used to issue a logging
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewLoggingCmd(include, exclude *[]string) *LoggingCmd {
+	return &LoggingCmd{
+		Include: include,
+		Exclude: exclude,
+	}
+}
+
+// GetRPCInfoCmd defines the getrpcinfo JSON-RPC command.
+type GetRPCInfoCmd struct{}
+
+// NewGetRPCInfoCmd returns a new instance which can be used to issue a
+// getrpcinfo JSON-RPC command.
+func NewGetRPCInfoCmd() *GetRPCInfoCmd {
+	return &GetRPCInfoCmd{}
+}
+
+// GetMemoryInfoCmd defines the getmemoryinfo JSON-RPC command.
+type GetMemoryInfoCmd struct{}
+
+// NewGetMemoryInfoCmd returns a new instance which can be used to issue a
+// getmemoryinfo JSON-RPC command.
+func NewGetMemoryInfoCmd() *GetMemoryInfoCmd {
+	return &GetMemoryInfoCmd{}
+}
+
+// SetGCCmd defines the setgc JSON-RPC command.
+type SetGCCmd struct {
+	Percent int
+}
+
+// NewSetGCCmd returns a new instance which can be used to issue a setgc
+// JSON-RPC command.
+func NewSetGCCmd(percent int) *SetGCCmd {
+	return &SetGCCmd{
+		Percent: percent,
+	}
+}
+
 // UptimeCmd defines the uptime JSON-RPC command.
 type UptimeCmd struct{}
 
@@ -734,6 +874,34 @@ func NewValidateAddressCmd(address string) *ValidateAddressCmd {
 	}
 }
 
+// GetDescriptorInfoCmd defines the getdescriptorinfo JSON-RPC command.
+type GetDescriptorInfoCmd struct {
+	Descriptor string
+}
+
+// NewGetDescriptorInfoCmd returns a new instance which can be used to issue a
+// getdescriptorinfo JSON-RPC command.
+func NewGetDescriptorInfoCmd(descriptor string) *GetDescriptorInfoCmd {
+	return &GetDescriptorInfoCmd{
+		Descriptor: descriptor,
+	}
+}
+
+// DeriveAddressesCmd defines the deriveaddresses JSON-RPC command.
+type DeriveAddressesCmd struct {
+	Descriptor string
+	Range      *[]int `jsonrpcusage:"[begin,end]"`
+}
+
+// NewDeriveAddressesCmd returns a new instance which can be used to issue a
+// deriveaddresses JSON-RPC command.
+func NewDeriveAddressesCmd(descriptor string, derivationRange *[]int) *DeriveAddressesCmd {
+	return &DeriveAddressesCmd{
+		Descriptor: descriptor,
+		Range:      derivationRange,
+	}
+}
+
 // VerifyChainCmd defines the verifychain JSON-RPC command.
 type VerifyChainCmd struct {
 	CheckLevel *int32 `jsonrpcdefault:"3"`
@@ -795,6 +963,60 @@ func NewPruneBlockChainCmd(height int) *PruneBlockChainCmd {
 	}
 }
 
+// GetBlockLocationCmd defines the getblocklocation JSON-RPC command.
+type GetBlockLocationCmd struct {
+	Hash string `json:"blockhash"`
+}
+
+// NewGetBlockLocationCmd returns a new instance which can be used to issue a
+// getblocklocation JSON-RPC command.
+func NewGetBlockLocationCmd(hash string) *GetBlockLocationCmd {
+	return &GetBlockLocationCmd{
+		Hash: hash,
+	}
+}
+
+// GetBlockHashByTimeCmd defines the getblockhashbytime JSON-RPC command.
+type GetBlockHashByTimeCmd struct {
+	Timestamp int64
+}
+
+// NewGetBlockHashByTimeCmd returns a new instance which can be used to issue a
+// getblockhashbytime JSON-RPC command.
+func NewGetBlockHashByTimeCmd(timestamp int64) *GetBlockHashByTimeCmd {
+	return &GetBlockHashByTimeCmd{
+		Timestamp: timestamp,
+	}
+}
+
+// ScanTxOutSetCmd defines the scantxoutset JSON-RPC command.
+type ScanTxOutSetCmd struct {
+	Action      string
+	ScanObjects *[]string
+}
+
+// NewScanTxOutSetCmd returns a new instance which can be used to issue a
+// scantxoutset JSON-RPC command.
+func NewScanTxOutSetCmd(action string, scanObjects *[]string) *ScanTxOutSetCmd {
+	return &ScanTxOutSetCmd{
+		Action:      action,
+		ScanObjects: scanObjects,
+	}
+}
+
+// DumpBlockFileCmd defines the dumpblockfile JSON-RPC command.
+type DumpBlockFileCmd struct {
+	File int32
+}
+
+// NewDumpBlockFileCmd returns a new instance which can be used to issue a
+// dumpblockfile JSON-RPC command.
+func NewDumpBlockFileCmd(file int32) *DumpBlockFileCmd {
+	return &DumpBlockFileCmd{
+		File: file,
+	}
+}
+
 type GetMempoolAncestorsCmd struct {
 	TxID    string `json:"txid"`
 	Verbose *bool  `json:"verbose" jsonrpcdefault:"false"`
@@ -1035,6 +1257,7 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("analyzescript", (*AnalyzeScriptCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
@@ -1042,6 +1265,7 @@ func init() {
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
+	MustRegisterCmd("getblockfilter", (*GetBlockFilterCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
@@ -1052,6 +1276,8 @@ func init() {
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
+	MustRegisterCmd("getnodefeeinfo", (*GetNodeFeeInfoCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
 	MustRegisterCmd("getmempoolinfo", (*GetMempoolInfoCmd)(nil), flags)
@@ -1059,16 +1285,20 @@ func init() {
 	MustRegisterCmd("getnetworkinfo", (*GetNetworkInfoCmd)(nil), flags)
 	MustRegisterCmd("getnettotals", (*GetNetTotalsCmd)(nil), flags)
 	MustRegisterCmd("getnetworkhashps", (*GetNetworkHashPSCmd)(nil), flags)
+	MustRegisterCmd("getmemoryinfo", (*GetMemoryInfoCmd)(nil), flags)
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getrpcinfo", (*GetRPCInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
+	MustRegisterCmd("gettransactionstatus", (*GetTransactionStatusCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("logging", (*LoggingCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
@@ -1079,12 +1309,15 @@ func init() {
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
+	MustRegisterCmd("getdescriptorinfo", (*GetDescriptorInfoCmd)(nil), flags)
+	MustRegisterCmd("deriveaddresses", (*DeriveAddressesCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
 	MustRegisterCmd("getmempoolancestors", (*GetMempoolAncestorsCmd)(nil), flags)
 	MustRegisterCmd("getmempooldescendants", (*GetMempoolDescendantsCmd)(nil), flags)
 	MustRegisterCmd("signrawtransaction", (*SignRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("verifytxoutproof", (*VerifyTxOutProofCmd)(nil), flags)
+	MustRegisterCmd("setgc", (*SetGCCmd)(nil), flags)
 	MustRegisterCmd("setmocktime", (*SetMocktimeCmd)(nil), flags)
 
 	MustRegisterCmd("disconnectnode", (*DisconnectNodeCmd)(nil), flags)
@@ -1096,6 +1329,12 @@ func init() {
 	MustRegisterCmd("setexcessiveblock", (*SetExcessiveBlockCmd)(nil), flags)
 	MustRegisterCmd("getexcessiveblock", (*GetExcessiveBlockCmd)(nil), flags)
 	MustRegisterCmd("pruneblockchain", (*PruneBlockChainCmd)(nil), flags)
+	MustRegisterCmd("setmempoollimit", (*SetMempoolLimitCmd)(nil), flags)
+	MustRegisterCmd("savemempool", (*SaveMempoolCmd)(nil), flags)
+	MustRegisterCmd("getblocklocation", (*GetBlockLocationCmd)(nil), flags)
+	MustRegisterCmd("getblockhashbytime", (*GetBlockHashByTimeCmd)(nil), flags)
+	MustRegisterCmd("scantxoutset", (*ScanTxOutSetCmd)(nil), flags)
+	MustRegisterCmd("dumpblockfile", (*DumpBlockFileCmd)(nil), flags)
 	MustRegisterCmd("createmultisig", (*CreateMultiSigCmd)(nil), flags)
 	MustRegisterCmd("estimatefee", (*EstimateFeeCmd)(nil), flags)
 
@@ -100,6 +100,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "analyzescript",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("analyzescript", "00")
+			},
+			staticCmd: func() interface{} {
+				return NewAnalyzeScriptCmd("00")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"analyzescript","params":["00"],"id":1}`,
+			unmarshalled: &AnalyzeScriptCmd{HexScript: "00"},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -231,6 +242,20 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: Bool(true),
 			},
 		},
+		{
+			name: "getblockfilter",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getblockfilter", "123")
+			},
+			staticCmd: func() interface{} {
+				return NewGetBlockFilterCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockfilter","params":["123"],"id":1}`,
+			unmarshalled: &GetBlockFilterCmd{
+				BlockHash:  "123",
+				FilterType: String("basic"),
+			},
+		},
 		{
 			name: "getblocktemplate",
 			newCmd: func() (interface{}, error) {
@@ -394,6 +419,19 @@ func TestChainSvrCmds(t *testing.T) {
 				TxID: "txhash",
 			},
 		},
+		{
+			name: "gettransactionstatus",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("gettransactionstatus", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return NewGetTransactionStatusCmd("txhash")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettransactionstatus","params":["txhash"],"id":1}`,
+			unmarshalled: &GetTransactionStatusCmd{
+				TxID: "txhash",
+			},
+		},
 		{
 			name: "getmempoolinfo",
 			newCmd: func() (interface{}, error) {
@@ -959,6 +997,99 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return NewGetIndexInfoCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &GetIndexInfoCmd{},
+		},
+		{
+			name: "getnodefeeinfo",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getnodefeeinfo")
+			},
+			staticCmd: func() interface{} {
+				return NewGetNodeFeeInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getnodefeeinfo","params":[],"id":1}`,
+			unmarshalled: &GetNodeFeeInfoCmd{},
+		},
+		{
+			name: "getindexinfo optional",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getindexinfo", "txindex")
+			},
+			staticCmd: func() interface{} {
+				return NewGetIndexInfoCmd(String("txindex"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["txindex"],"id":1}`,
+			unmarshalled: &GetIndexInfoCmd{
+				IndexName: String("txindex"),
+			},
+		},
+		{
+			name: "logging",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("logging")
+			},
+			staticCmd: func() interface{} {
+				return NewLoggingCmd(nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"logging","params":[],"id":1}`,
+			unmarshalled: &LoggingCmd{},
+		},
+		{
+			name: "logging optional",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("logging", []string{"mempool"}, []string{"net", "rpc"})
+			},
+			staticCmd: func() interface{} {
+				return NewLoggingCmd(&[]string{"mempool"}, &[]string{"net", "rpc"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"logging","params":[["mempool"],["net","rpc"]],"id":1}`,
+			unmarshalled: &LoggingCmd{
+				Include: &[]string{"mempool"},
+				Exclude: &[]string{"net", "rpc"},
+			},
+		},
+		{
+			name: "getmemoryinfo",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getmemoryinfo")
+			},
+			staticCmd: func() interface{} {
+				return NewGetMemoryInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getmemoryinfo","params":[],"id":1}`,
+			unmarshalled: &GetMemoryInfoCmd{},
+		},
+		{
+			name: "setgc",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("setgc", 50)
+			},
+			staticCmd: func() interface{} {
+				return NewSetGCCmd(50)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"setgc","params":[50],"id":1}`,
+			unmarshalled: &SetGCCmd{Percent: 50},
+		},
+		{
+			name: "getrpcinfo",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getrpcinfo")
+			},
+			staticCmd: func() interface{} {
+				return NewGetRPCInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrpcinfo","params":[],"id":1}`,
+			unmarshalled: &GetRPCInfoCmd{},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -983,6 +1114,46 @@ func TestChainSvrCmds(t *testing.T) {
 				Address: "1Address",
 			},
 		},
+		{
+			name: "getdescriptorinfo",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getdescriptorinfo", "raw(deadbeef)")
+			},
+			staticCmd: func() interface{} {
+				return NewGetDescriptorInfoCmd("raw(deadbeef)")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getdescriptorinfo","params":["raw(deadbeef)"],"id":1}`,
+			unmarshalled: &GetDescriptorInfoCmd{
+				Descriptor: "raw(deadbeef)",
+			},
+		},
+		{
+			name: "deriveaddresses",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("deriveaddresses", "raw(deadbeef)#89f8spxm")
+			},
+			staticCmd: func() interface{} {
+				return NewDeriveAddressesCmd("raw(deadbeef)#89f8spxm", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"deriveaddresses","params":["raw(deadbeef)#89f8spxm"],"id":1}`,
+			unmarshalled: &DeriveAddressesCmd{
+				Descriptor: "raw(deadbeef)#89f8spxm",
+			},
+		},
+		{
+			name: "deriveaddresses optional",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("deriveaddresses", "raw(deadbeef)#89f8spxm", []int{0, 2})
+			},
+			staticCmd: func() interface{} {
+				return NewDeriveAddressesCmd("raw(deadbeef)#89f8spxm", &[]int{0, 2})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"deriveaddresses","params":["raw(deadbeef)#89f8spxm",[0,2]],"id":1}`,
+			unmarshalled: &DeriveAddressesCmd{
+				Descriptor: "raw(deadbeef)#89f8spxm",
+				Range:      &[]int{0, 2},
+			},
+		},
 		{
 			name: "verifychain",
 			newCmd: func() (interface{}, error) {
@@ -1094,6 +1265,96 @@ func TestChainSvrCmds(t *testing.T) {
 				Height: 123,
 			},
 		},
+		{
+			name: "setmempoollimit",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("setmempoollimit", 2)
+			},
+			staticCmd: func() interface{} {
+				return NewSetMempoolLimitCmd(2)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setmempoollimit","params":[2],"id":1}`,
+			unmarshalled: &SetMempoolLimitCmd{
+				MaxOrphanTx: 2,
+			},
+		},
+		{
+			name: "savemempool",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("savemempool")
+			},
+			staticCmd: func() interface{} {
+				return NewSaveMempoolCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"savemempool","params":[],"id":1}`,
+			unmarshalled: &SaveMempoolCmd{},
+		},
+		{
+			name: "getblocklocation",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getblocklocation", "123")
+			},
+			staticCmd: func() interface{} {
+				return NewGetBlockLocationCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocklocation","params":["123"],"id":1}`,
+			unmarshalled: &GetBlockLocationCmd{
+				Hash: "123",
+			},
+		},
+		{
+			name: "getblockhashbytime",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("getblockhashbytime", 1600000000)
+			},
+			staticCmd: func() interface{} {
+				return NewGetBlockHashByTimeCmd(1600000000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashbytime","params":[1600000000],"id":1}`,
+			unmarshalled: &GetBlockHashByTimeCmd{
+				Timestamp: 1600000000,
+			},
+		},
+		{
+			name: "scantxoutset",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("scantxoutset", "start", []string{"raw(51)"})
+			},
+			staticCmd: func() interface{} {
+				return NewScanTxOutSetCmd("start", &[]string{"raw(51)"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["start",["raw(51)"]],"id":1}`,
+			unmarshalled: &ScanTxOutSetCmd{
+				Action:      "start",
+				ScanObjects: &[]string{"raw(51)"},
+			},
+		},
+		{
+			name: "scantxoutset status",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("scantxoutset", "status")
+			},
+			staticCmd: func() interface{} {
+				return NewScanTxOutSetCmd("status", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["status"],"id":1}`,
+			unmarshalled: &ScanTxOutSetCmd{
+				Action: "status",
+			},
+		},
+		{
+			name: "dumpblockfile",
+			newCmd: func() (interface{}, error) {
+				return NewCmd("dumpblockfile", 2)
+			},
+			staticCmd: func() interface{} {
+				return NewDumpBlockFileCmd(2)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dumpblockfile","params":[2],"id":1}`,
+			unmarshalled: &DumpBlockFileCmd{
+				File: 2,
+			},
+		},
 		{
 			name: "echo",
 			newCmd: func() (interface{}, error) {
@@ -52,12 +52,121 @@ type GetBlockVerboseResult struct {
 
 // GetChainTxStatsResult models the data from the getchaintxstats command.
 type GetChainTxStatsResult struct {
-	FinalTime      uint32  `json:"time"`
-	TxCount        int32   `json:"txcount"`
-	BlockCount     int32   `json:"window_block_count"`
-	WindowTxCount  int32   `json:"window_tx_count,omitempty"`
-	WindowInterval int64   `json:"window_interval,omitempty"`
-	TxRate         float64 `json:"txrate,omitempty"`
+	FinalTime        uint32  `json:"time"`
+	TxCount          int32   `json:"txcount"`
+	FinalBlockHash   string  `json:"window_final_block_hash"`
+	FinalBlockHeight int32   `json:"window_final_block_height"`
+	BlockCount       int32   `json:"window_block_count"`
+	WindowTxCount    int32   `json:"window_tx_count,omitempty"`
+	WindowInterval   int64   `json:"window_interval,omitempty"`
+	TxRate           float64 `json:"txrate,omitempty"`
+}
+
+// GetBlockFilterResult models the data from the getblockfilter command.
+type GetBlockFilterResult struct {
+	Filter string `json:"filter"`
+	Header string `json:"header"`
+}
+
+// GetBlockLocationResult models the data from the getblocklocation command.
+type GetBlockLocationResult struct {
+	File   int32  `json:"file"`
+	Offset uint32 `json:"offset"`
+	Length uint32 `json:"length"`
+}
+
+// GetBlockHashByTimeResult models the data from the getblockhashbytime
+// command.
+type GetBlockHashByTimeResult struct {
+	Hash   string `json:"hash"`
+	Height int32  `json:"height"`
+}
+
+// ScanTxOutSetUnspent models an unspent output found by the scantxoutset
+// command.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Amount       float64 `json:"amount"`
+	Height       int32   `json:"height"`
+}
+
+// ScanTxOutSetResult models the data from the scantxoutset start command.
+type ScanTxOutSetResult struct {
+	Success       bool                  `json:"success"`
+	SearchedItems uint64                `json:"searched_items"`
+	Unspents      []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount   float64               `json:"total_amount"`
+}
+
+// ScanTxOutSetStatusResult models the data from the scantxoutset status
+// command.
+type ScanTxOutSetStatusResult struct {
+	Progress int32 `json:"progress"`
+}
+
+// DumpBlockFileResult models the data from the dumpblockfile command.
+type DumpBlockFileResult struct {
+	Blocks      uint32 `json:"blocks"`
+	Size        uint32 `json:"size"`
+	UndoSize    uint32 `json:"undosize"`
+	HeightFirst int32  `json:"heightfirst"`
+	HeightLast  int32  `json:"heightlast"`
+	TimeFirst   uint64 `json:"timefirst"`
+	TimeLast    uint64 `json:"timelast"`
+}
+
+// GetIndexInfoResult models the per index data from the getindexinfo command.
+type GetIndexInfoResult struct {
+	Synced          bool  `json:"synced"`
+	BestBlockHeight int32 `json:"best_block_height"`
+}
+
+// FeeEstimateResult models a fee estimate in the getnodefeeinfo command.
+type FeeEstimateResult struct {
+	Blocks  uint32  `json:"blocks"`
+	FeeRate float64 `json:"feerate"`
+}
+
+// GetNodeFeeInfoResult models the data returned from the getnodefeeinfo
+// command, the fee rates in BCH/kB.
+type GetNodeFeeInfoResult struct {
+	MinRelayTxFee       float64             `json:"minrelaytxfee"`
+	IncrementalRelayFee float64             `json:"incrementalrelayfee"`
+	MempoolMinFee       float64             `json:"mempoolminfee"`
+	Estimates           []FeeEstimateResult `json:"estimates"`
+}
+
+// RPCActiveCommand models an RPC command being executed in the getrpcinfo
+// command.
+type RPCActiveCommand struct {
+	Method   string `json:"method"`
+	Duration int64  `json:"duration"`
+}
+
+// GetRPCInfoResult models the data returned from the getrpcinfo command.
+type GetRPCInfoResult struct {
+	ActiveCommands []RPCActiveCommand `json:"active_commands"`
+	LogPath        string             `json:"logpath"`
+}
+
+// RuntimeMemoryInfo models the Go runtime memory statistics in the
+// getmemoryinfo command.
+type RuntimeMemoryInfo struct {
+	HeapAlloc uint64 `json:"heapalloc"`
+	Sys       uint64 `json:"sys"`
+	NumGC     uint32 `json:"numgc"`
+}
+
+// GetMemoryInfoResult models the data returned from the getmemoryinfo
+// command, the memory used by the caches in bytes.
+type GetMemoryInfoResult struct {
+	CoinsCache int64             `json:"coinscache"`
+	Mempool    int64             `json:"mempool"`
+	Orphans    int64             `json:"orphans"`
+	BlockIndex int64             `json:"blockindex"`
+	Runtime    RuntimeMemoryInfo `json:"runtime"`
 }
 
 // CreateMultiSigResult models the data returned from the createmultisig
@@ -67,6 +176,16 @@ type CreateMultiSigResult struct {
 	RedeemScript string `json:"redeemScript"`
 }
 
+// GetDescriptorInfoResult models the data returned from the getdescriptorinfo
+// command.
+type GetDescriptorInfoResult struct {
+	Descriptor     string `json:"descriptor"`
+	Checksum       string `json:"checksum"`
+	IsRange        bool   `json:"isrange"`
+	IsSolvable     bool   `json:"issolvable"`
+	HasPrivateKeys bool   `json:"hasprivatekeys"`
+}
+
 // DecodeScriptResult models the data returned from the decodescript command.
 type DecodeScriptResult struct {
 	Asm       string   `json:"asm"`
@@ -76,6 +195,19 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh,omitempty"`
 }
 
+// AnalyzeScriptResult models the data returned from the analyzescript command.
+type AnalyzeScriptResult struct {
+	Type           string   `json:"type"`
+	Size           int      `json:"size"`
+	OpCount        int      `json:"opcount"`
+	SigOpCount     int      `json:"sigopcount"`
+	ReqSigs        int32    `json:"reqSigs,omitempty"`
+	Keys           int      `json:"keys,omitempty"`
+	MaxElementSize int      `json:"maxelementsize"`
+	PushOnly       bool     `json:"pushonly"`
+	Violations     []string `json:"violations"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -211,11 +343,23 @@ type GetMempoolEntryResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
+	Loaded        bool    `json:"loaded"`
 	Size          int     `json:"size"`
 	Bytes         uint64  `json:"bytes"`
 	Usage         int64   `json:"usage"`
 	MaxMempool    int     `json:"maxmempool"`
 	MempoolMinFee float64 `json:"mempoolminfee"`
+	Orphans       int     `json:"orphans"`
+	OrphanBytes   int     `json:"orphanbytes"`
+	MaxOrphanTx   int     `json:"maxorphantx"`
+}
+
+// GetTransactionStatusResult models the data returned from the
+// gettransactionstatus command.
+type GetTransactionStatusResult struct {
+	Status        string `json:"status"`
+	Height        int32  `json:"height,omitempty"`
+	Confirmations int32  `json:"confirmations,omitempty"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -250,9 +394,21 @@ type GetNetworkInfoResult struct {
 	RelayFee         float64                `json:"relayfee"`
 	ExcessUtxoCharge float64                `json:"excessutxocharge"`
 	LocalAddresses   []LocalAddressesResult `json:"localaddresses"`
+	UploadTarget     *UploadTargetResult    `json:"uploadtarget"`
 	Warnings         string                 `json:"warnings"`
 }
 
+// UploadTargetResult models the state of the outbound traffic limit reported
+// by the getnetworkinfo and getnettotals commands.
+type UploadTargetResult struct {
+	Timeframe             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+}
+
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
 	ID              int32             `json:"id"`
@@ -264,6 +420,7 @@ type GetPeerInfoResult struct {
 	LastRecv        int64             `json:"lastrecv"`
 	BytesSent       uint64            `json:"bytessent"`
 	BytesRecv       uint64            `json:"bytesrecv"`
+	BytesSentCycle  uint64            `json:"bytessent_in_cycle"`
 	ConnTime        int64             `json:"conntime"`
 	TimeOffset      int64             `json:"timeoffset"`
 	PingTime        float64           `json:"pingtime,omitempty"`
@@ -332,19 +489,10 @@ type GetTxOutSetInfoResult struct {
 
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64       `json:"totalbytesrecv"`
-	TotalBytesSent uint64       `json:"totalbytessent"`
-	TimeMillis     int64        `json:"timemillis"`
-	Uploadtarget   Uploadtarget `json:"pploadtarget"`
-}
-
-type Uploadtarget struct {
-	TimeFrame             uint64 `json:"timeframe"`
-	Target                uint64 `json:"target"`
-	TargetReached         bool   `json:"target_reached"`
-	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
-	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
-	TimeLeftInCycle       uint64 `json:"time_left_in_cycle"`
+	TotalBytesRecv uint64              `json:"totalbytesrecv"`
+	TotalBytesSent uint64              `json:"totalbytessent"`
+	TimeMillis     int64               `json:"timemillis"`
+	UploadTarget   *UploadTargetResult `json:"uploadtarget"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -363,9 +511,17 @@ type Vin struct {
 	Txid      string     `json:"txid"`
 	Vout      uint32     `json:"vout"`
 	ScriptSig *ScriptSig `json:"scriptSig"`
+	PrevOut   *VinOut    `json:"prevout,omitempty"`
 	Sequence  uint32     `json:"sequence"`
 }
 
+// VinOut models the output spent by an input, it is only filled in when the
+// spent coin could be resolved.
+type VinOut struct {
+	Value        float64            `json:"value"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
 // IsCoinBase returns a bool to show if a Vin is a Coinbase one or not.
 func (v *Vin) IsCoinBase() bool {
 	return len(v.Coinbase) > 0
@@ -388,11 +544,13 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 		Txid      string     `json:"txid"`
 		Vout      uint32     `json:"vout"`
 		ScriptSig *ScriptSig `json:"scriptSig"`
+		PrevOut   *VinOut    `json:"prevout,omitempty"`
 		Sequence  uint32     `json:"sequence"`
 	}{
 		Txid:      v.Txid,
 		Vout:      v.Vout,
 		ScriptSig: v.ScriptSig,
+		PrevOut:   v.PrevOut,
 		Sequence:  v.Sequence,
 	}
 	return json.Marshal(txStruct)
@@ -493,18 +651,19 @@ type InfoChainResult struct {
 
 // TxRawResult models the data from the getrawtransaction command.
 type TxRawResult struct {
-	Hex           string `json:"hex"`
-	TxID          string `json:"txid"`
-	Hash          string `json:"hash"`
-	Size          int    `json:"size"`
-	Version       int32  `json:"version"`
-	LockTime      uint32 `json:"locktime"`
-	Vin           []Vin  `json:"vin"`
-	Vout          []Vout `json:"vout"`
-	BlockHash     string `json:"blockhash"`
-	Confirmations int32  `json:"confirmations"`
-	Time          uint32 `json:"time"`
-	Blocktime     uint32 `json:"blocktime"`
+	Hex           string   `json:"hex"`
+	TxID          string   `json:"txid"`
+	Hash          string   `json:"hash"`
+	Size          int      `json:"size"`
+	Version       int32    `json:"version"`
+	LockTime      uint32   `json:"locktime"`
+	Vin           []Vin    `json:"vin"`
+	Vout          []Vout   `json:"vout"`
+	Fee           *float64 `json:"fee,omitempty"`
+	BlockHash     string   `json:"blockhash"`
+	Confirmations int32    `json:"confirmations"`
+	Time          uint32   `json:"time"`
+	Blocktime     uint32   `json:"blocktime"`
 }
 
 // SearchRawTransactionsResult models the data from the searchrawtransaction
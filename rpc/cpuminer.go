@@ -0,0 +1,235 @@
+package rpc
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lblock"
+	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/logic/lwallet"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/pow"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/net/server"
+	"github.com/copernet/copernicus/service/mining"
+)
+
+const (
+	// minerIdleInterval is how long the internal miner sleeps before looking
+	// again at the chain while it can't mine.
+	minerIdleInterval = time.Second
+
+	// minerQuitCheckInterval is the number of nonces tried between checks
+	// for the miner being stopped.
+	minerQuitCheckInterval = 0x1000
+)
+
+// cpuMiner assembles blocks paying to scriptPubKey and solves them on its own
+// goroutines, which is only practical on regtest. Solved blocks are handed to
+// submit.
+type cpuMiner struct {
+	scriptPubKey *script.Script
+	submit       func(*block.Block) error
+	quit         chan struct{}
+	wg           sync.WaitGroup
+}
+
+var (
+	cpuMinerLock sync.Mutex
+	activeMiner  *cpuMiner
+)
+
+// StartCPUMiner starts the internal miner on procs goroutines, or one per CPU
+// if procs is negative, mining to address or to the wallet mining address if
+// address is empty. Solved blocks go through the same path as the ones
+// submitted by RPC. It does nothing if the miner is already running.
+func StartCPUMiner(procs int, address string) error {
+	cpuMinerLock.Lock()
+	defer cpuMinerLock.Unlock()
+
+	if activeMiner != nil {
+		return nil
+	}
+	if address == "" {
+		if !lwallet.IsWalletEnable() {
+			return errors.New("the internal miner needs a mining address or the wallet")
+		}
+		var err error
+		if address, err = lwallet.GetMiningAddress(); err != nil {
+			return err
+		}
+	}
+	scriptPubKey, rpcErr := getStandardScriptPubKey(address, nil)
+	if rpcErr != nil {
+		return errors.New(rpcErr.Message)
+	}
+	if procs < 0 {
+		procs = runtime.NumCPU()
+	}
+
+	activeMiner = newCPUMiner(scriptPubKey, func(blk *block.Block) error {
+		_, err := server.ProcessForRPC(blk)
+		return err
+	})
+	activeMiner.start(procs)
+	log.Info("Internal miner started with %d goroutines, mining to %s", procs, address)
+	return nil
+}
+
+// StopCPUMiner stops the internal miner and waits for its goroutines to exit.
+func StopCPUMiner() {
+	cpuMinerLock.Lock()
+	defer cpuMinerLock.Unlock()
+
+	if activeMiner == nil {
+		return
+	}
+	activeMiner.stop()
+	activeMiner = nil
+	log.Info("Internal miner stopped")
+}
+
+// CPUMinerRunning returns whether the internal miner is running.
+func CPUMinerRunning() bool {
+	cpuMinerLock.Lock()
+	defer cpuMinerLock.Unlock()
+
+	return activeMiner != nil
+}
+
+func newCPUMiner(scriptPubKey *script.Script, submit func(*block.Block) error) *cpuMiner {
+	return &cpuMiner{
+		scriptPubKey: scriptPubKey,
+		submit:       submit,
+		quit:         make(chan struct{}),
+	}
+}
+
+func (m *cpuMiner) start(procs int) {
+	for i := 0; i < procs; i++ {
+		m.wg.Add(1)
+		go m.mine(uint(i))
+	}
+}
+
+func (m *cpuMiner) stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// mine is the loop of one mining goroutine. The goroutines use extra nonces
+// from different offsets, so they never work on the same coinbase. Once a
+// template is mined or its nonces are used up, the goroutine waits for the tip
+// or the mempool to change before building the next one.
+func (m *cpuMiner) mine(offset uint) {
+	defer m.wg.Done()
+
+	params := model.ActiveNetParams
+	var (
+		tip         *blockindex.BlockIndex
+		updatedLast uint64
+	)
+	for {
+		select {
+		case <-m.quit:
+			return
+		default:
+		}
+
+		// Blocks mined on a chain still catching up would be wasted, but
+		// there is no network to catch up with on regtest.
+		if !params.MineBlocksOnDemands && lblock.IsInitialBlockDownload() {
+			select {
+			case <-m.quit:
+				return
+			case <-time.After(minerIdleInterval):
+			}
+			continue
+		}
+
+		if tip == chain.GetInstance().Tip() && updatedLast == mempool.GetInstance().TransactionsUpdated {
+			if !m.waitForChange(tip, updatedLast) {
+				return
+			}
+			continue
+		}
+		tip = chain.GetInstance().Tip()
+		updatedLast = mempool.GetInstance().TransactionsUpdated
+
+		ba := mining.NewBlockAssembler(params)
+		bt := createBlockForCPUMining(ba, m.scriptPubKey, offset)
+		if bt == nil {
+			log.Error("Internal miner could not create a new block")
+			// try again after a while, even if nothing changed
+			tip = nil
+			select {
+			case <-m.quit:
+				return
+			case <-time.After(minerIdleInterval):
+			}
+			continue
+		}
+
+		blk := bt.Block
+		if !m.solve(blk) {
+			continue
+		}
+		hash := blk.GetHash()
+		if err := m.submit(blk); err != nil {
+			log.Error("Internal miner block %s not accepted: %v", hash, err)
+			continue
+		}
+		log.Info("Internal miner found block %s", hash)
+	}
+}
+
+// waitForChange blocks until the tip is no longer tip or the mempool changed
+// since updatedLast. A new tip wakes it at once, the mempool is looked at
+// every minerIdleInterval. It returns false if the miner was stopped first.
+func (m *cpuMiner) waitForChange(tip *blockindex.BlockIndex, updatedLast uint64) bool {
+	for {
+		// Take the channel before looking at the tip, so that a change in
+		// between is not missed.
+		changed := blockTemplateNotifier.Changed()
+		if tip != chain.GetInstance().Tip() || updatedLast != mempool.GetInstance().TransactionsUpdated {
+			return true
+		}
+		select {
+		case <-m.quit:
+			return false
+		case <-changed:
+		case <-time.After(minerIdleInterval):
+		}
+	}
+}
+
+// solve grinds the nonce of the block until it meets its target. It returns
+// false if the nonce range was used up or the miner was stopped first.
+func (m *cpuMiner) solve(blk *block.Block) bool {
+	params := model.ActiveNetParams
+	blk.Header.MerkleRoot = lmerkleroot.BlockMerkleRoot(blk.Txs, nil)
+
+	powCheck := pow.Pow{}
+	for nonce := uint32(0); nonce < nInnerLoopCount; nonce++ {
+		if nonce%minerQuitCheckInterval == 0 {
+			select {
+			case <-m.quit:
+				return false
+			default:
+			}
+		}
+		blk.Header.Nonce = nonce
+		hash := blk.GetHash()
+		if powCheck.CheckProofOfWork(&hash, blk.Header.Bits, params) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/copernet/copernicus/service"
+)
+
+func TestCPUMiner(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	miner := newCPUMiner(opTrue, func(blk *block.Block) error {
+		fNewBlock := false
+		return service.ProcessNewBlock(blk, true, &fNewBlock)
+	})
+
+	gChain := chain.GetInstance()
+	const target = 5
+	miner.start(1)
+	deadline := time.Now().Add(30 * time.Second)
+	for gChain.Height() < target {
+		if time.Now().After(deadline) {
+			miner.stop()
+			t.Fatalf("the miner only reached height %d", gChain.Height())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	miner.stop()
+
+	stopped := gChain.Height()
+	time.Sleep(200 * time.Millisecond)
+	if height := gChain.Height(); height != stopped {
+		t.Errorf("the tip moved from %d to %d after the miner stopped", stopped, height)
+	}
+
+	blk, ok := disk.ReadBlockFromDisk(gChain.Tip(), model.ActiveNetParams)
+	if !ok {
+		t.Fatalf("read tip block failed")
+	}
+	if !blk.Txs[0].GetTxOut(0).GetScriptPubKey().IsEqual(opTrue) {
+		t.Errorf("the mined coinbase does not pay to the configured script")
+	}
+}
+
+func TestCPUMinerWaitsForChange(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	submitted := make(chan struct{}, 10)
+	miner := newCPUMiner(opTrue, func(blk *block.Block) error {
+		submitted <- struct{}{}
+		return errors.New("not accepted")
+	})
+	miner.start(1)
+	defer miner.stop()
+
+	select {
+	case <-submitted:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("the miner did not submit a block")
+	}
+
+	// The tip and the mempool did not change, the same template is not
+	// mined again.
+	select {
+	case <-submitted:
+		t.Fatalf("the miner mined again while nothing changed")
+	case <-time.After(2 * minerIdleInterval):
+	}
+
+	mempool.GetInstance().TransactionsUpdated++
+	select {
+	case <-submitted:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("the miner did not mine again after the mempool changed")
+	}
+}
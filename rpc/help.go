@@ -40,7 +40,11 @@ var allMethodHelp = map[string]helpDescInfo{
 	"getblockcount":         {BlockChainCmd, getblockcountDesc},
 	"getblock":              {BlockChainCmd, getblockDesc},
 	"getblockhash":          {BlockChainCmd, getblockhashDesc},
+	"getblockhashbytime":    {BlockChainCmd, getblockhashbytimeDesc},
+	"scantxoutset":          {BlockChainCmd, scantxoutsetDesc},
 	"getblockheader":        {BlockChainCmd, getblockheader},
+	"getblockfilter":        {BlockChainCmd, getblockfilterDesc},
+	"getblocklocation":      {BlockChainCmd, getblocklocationDesc},
 	"getchaintips":          {BlockChainCmd, getchaintipsDesc},
 	"getchaintxstats":       {BlockChainCmd, getchaintxstatsDesc},
 	"getdifficulty":         {BlockChainCmd, getdifficultyDesc},
@@ -49,9 +53,13 @@ var allMethodHelp = map[string]helpDescInfo{
 	"getmempoolentry":       {BlockChainCmd, getmempoolentryDesc},
 	"getmempoolinfo":        {BlockChainCmd, getmempoolinfoDesc},
 	"getrawmempool":         {BlockChainCmd, getrawmempoolDesc},
+	"gettransactionstatus":  {BlockChainCmd, gettransactionstatusDesc},
 	"gettxout":              {BlockChainCmd, gettxoutDesc},
 	"gettxoutsetinfo":       {BlockChainCmd, gettxoutsetinfoDesc},
+	"dumpblockfile":         {BlockChainCmd, dumpblockfileDesc},
 	"pruneblockchain":       {BlockChainCmd, pruneblockchainDesc},
+	"setmempoollimit":       {BlockChainCmd, setmempoollimitDesc},
+	"savemempool":           {BlockChainCmd, savemempoolDesc},
 	"verifychain":           {BlockChainCmd, verifychainDesc},
 	"preciousblock":         {BlockChainCmd, preciousblockDesc},
 	"gettxoutproof":         {BlockChainCmd, gettxoutproofDesc},
@@ -82,16 +90,26 @@ var allMethodHelp = map[string]helpDescInfo{
 	"createrawtransaction": {RawTransactionsCmd, createrawtransactionDesc},
 	"decoderawtransaction": {RawTransactionsCmd, decoderawtransactionDesc},
 	"decodescript":         {RawTransactionsCmd, decodescriptDesc},
+	"analyzescript":        {RawTransactionsCmd, analyzescriptDesc},
 	"sendrawtransaction":   {RawTransactionsCmd, sendrawtransactionDesc},
 	"signrawtransaction":   {RawTransactionsCmd, signrawtransactionDesc},
 
-	"getinfo": {ControlCmd, getinfoDesc},
-	"help":    {ControlCmd, helpDesc},
-	"stop":    {ControlCmd, stopDesc},
-	"uptime":  {ControlCmd, uptimeDesc},
+	"getinfo":       {ControlCmd, getinfoDesc},
+	"help":          {ControlCmd, helpDesc},
+	"stop":          {ControlCmd, stopDesc},
+	"uptime":        {ControlCmd, uptimeDesc},
+	"logging":       {ControlCmd, loggingDesc},
+	"getrpcinfo":    {ControlCmd, getrpcinfoDesc},
+	"getmemoryinfo": {ControlCmd, getmemoryinfoDesc},
+	"setgc":         {ControlCmd, setgcDesc},
 
 	"validateaddress": {UtilCmd, validateaddressDesc},
 	"createmultisig":  {UtilCmd, createmultisigDesc},
+	"getindexinfo":    {UtilCmd, getindexinfoDesc},
+	"getnodefeeinfo":  {UtilCmd, getnodefeeinfoDesc},
+
+	"getdescriptorinfo": {UtilCmd, getdescriptorinfoDesc},
+	"deriveaddresses":   {UtilCmd, deriveaddressesDesc},
 
 	"getexcessiveblock":  {DebugCmd, getexcessiveblockDesc},
 	"setexcessiveblock":  {DebugCmd, setexcessiveblockDesc},
@@ -220,6 +220,92 @@ var (
 		HelpExampleCli("getblockheader", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"") +
 		HelpExampleRPC("getblockheader", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"")
 
+	getblockfilterDesc = "getblockfilter \"blockhash\" ( \"filtertype\" )\n" +
+		"\nRetrieve a BIP 157 content filter for a particular block.\n" +
+		"\nArguments:\n" +
+		"1. \"blockhash\"     (string, required) The hash of the block\n" +
+		"2. \"filtertype\"    (string, optional, default=basic) The type " +
+		"name of the filter\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"filter\" : \"hex\",  (string) the hex-encoded filter data\n" +
+		"  \"header\" : \"hex\"   (string) the hex-encoded filter header\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getblockfilter", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"", "\"basic\"") +
+		HelpExampleRPC("getblockfilter", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"", "\"basic\"")
+
+	getblockhashbytimeDesc = "getblockhashbytime timestamp\n" +
+		"\nReturns the last block of the active chain whose median time " +
+		"past is at or before the timestamp. The genesis block is returned " +
+		"for a timestamp before it.\n" +
+		"\nArguments:\n" +
+		"1. timestamp     (numeric, required) The UNIX timestamp\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"hash\" : \"hash\",  (string) the block hash\n" +
+		"  \"height\" : n      (numeric) the block height\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getblockhashbytime", "1600000000") +
+		HelpExampleRPC("getblockhashbytime", "1600000000")
+
+	scantxoutsetDesc = "scantxoutset \"action\" ( [scanobjects,...] )\n" +
+		"\nScans the unspent transaction output set for outputs paying to " +
+		"the scan objects.\n" +
+		"\nArguments:\n" +
+		"1. \"action\"        (string, required) \"start\" to scan, " +
+		"\"status\" for the progress of the running scan, \"abort\" to " +
+		"stop it\n" +
+		"2. \"scanobjects\"   (array, required for \"start\") The scan " +
+		"objects, each either an output descriptor such as " +
+		"\"addr(<address>)\", \"raw(<hex script>)\", \"pkh(<pubkey>)\" " +
+		"or \"sh(multi(<k>,<pubkey>,...))\", or a plain address\n" +
+		"\nResult for \"start\":\n" +
+		"{\n" +
+		"  \"success\": true|false,  (boolean) false when the scan was aborted\n" +
+		"  \"searched_items\": n,    (numeric) the number of unspent outputs " +
+		"scanned\n" +
+		"  \"unspents\": [\n" +
+		"    {\n" +
+		"      \"txid\": \"hash\",         (string) the transaction id\n" +
+		"      \"vout\": n,              (numeric) the output index\n" +
+		"      \"scriptPubKey\": \"hex\",  (string) the script of the output\n" +
+		"      \"amount\": x.xxx,        (numeric) the value in " + util.CurrencyUnit + "\n" +
+		"      \"height\": n             (numeric) the height of the block " +
+		"holding the output\n" +
+		"    }\n" +
+		"    ,...\n" +
+		"  ],\n" +
+		"  \"total_amount\": x.xxx   (numeric) the total value of the " +
+		"unspents in " + util.CurrencyUnit + "\n" +
+		"}\n" +
+		"\nResult for \"status\":\n" +
+		"{\n" +
+		"  \"progress\": n   (numeric) the percentage of the scan done\n" +
+		"}\n" +
+		"or null when no scan is running\n" +
+		"\nResult for \"abort\":\n" +
+		"true|false   (boolean) whether a running scan was aborted\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("scantxoutset", "\"start\"", "'[\"raw(76a91411b366edfc0a8b66feebae5c2e25a7b6a5d1cf3188ac)\"]'") +
+		HelpExampleRPC("scantxoutset", "\"start\"", "[\"raw(76a91411b366edfc0a8b66feebae5c2e25a7b6a5d1cf3188ac)\"]")
+
+	getblocklocationDesc = "getblocklocation \"blockhash\"\n" +
+		"\nReturns where a block is stored in the block files.\n" +
+		"\nArguments:\n" +
+		"1. \"blockhash\"     (string, required) The hash of the block\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"file\" : n,      (numeric) the number of the blk?????.dat file\n" +
+		"  \"offset\" : n,    (numeric) the byte offset of the block in the " +
+		"file, its length is stored in the 4 bytes before it\n" +
+		"  \"length\" : n     (numeric) the size of the block in bytes\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getblocklocation", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"") +
+		HelpExampleRPC("getblocklocation", "\"00000000c937983704a73af28acdec37b049d214adbda81d7e2a3dd146f6ed09\"")
+
 	getchaintipsDesc = "getchaintips\n" +
 		"Return information about all known tips in the block tree," +
 		" including the main chain as well as orphaned branches.\n" +
@@ -270,6 +356,10 @@ var (
 		"final block in the window in UNIX format.\n" +
 		"  \"txcount\": xxxxx,             (numeric) The total number of " +
 		"transactions in the chain up to that point.\n" +
+		"  \"window_final_block_hash\": \"...\", (string) The hash of the " +
+		"final block in the window.\n" +
+		"  \"window_final_block_height\": xxxxx, (numeric) The height of " +
+		"the final block in the window.\n" +
 		"  \"window_block_count\": xxxxx,  (numeric) Size of the window in " +
 		"number of blocks.\n" +
 		"  \"window_tx_count\": xxxxx,     (numeric) The number of " +
@@ -440,18 +530,43 @@ var (
 		HelpExampleCli("getmempoolentry", "\"mytxid\"") +
 		HelpExampleRPC("getmempoolentry", "\"mytxid\"")
 
+	gettransactionstatusDesc = "gettransactionstatus txid\n" +
+		"\nReturns the state of a transaction without needing a wallet, " +
+		"so a broadcast transaction can be tracked.\n" +
+		"\nArguments:\n" +
+		"1. \"txid\"                   (string, required) The transaction id\n" +
+		"\nResult:\n" +
+		"{                           (json object)\n" +
+		"    \"status\" : \"str\",       (string) \"mempool\", \"confirmed\", " +
+		"\"conflicted\" if a different transaction spent its inputs, " +
+		"or \"unknown\"\n" +
+		"    \"height\" : n,           (numeric) height of the block " +
+		"containing the transaction, only if confirmed\n" +
+		"    \"confirmations\" : n,    (numeric) the confirmations, only " +
+		"if confirmed\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("gettransactionstatus", "\"mytxid\"") +
+		HelpExampleRPC("gettransactionstatus", "\"mytxid\"")
+
 	getmempoolinfoDesc = "getmempoolinfo\n" +
 		"\nReturns details on the active state of the TX memory pool.\n" +
 		"\nResult:\n" +
 		"{\n" +
+		"  \"loaded\": true|false,        (boolean) True if the mempool is " +
+		"fully loaded\n" +
 		"  \"size\": xxxxx,               (numeric) Current tx count\n" +
 		"  \"bytes\": xxxxx,              (numeric) Transaction size.\n" +
 		"  \"usage\": xxxxx,              (numeric) Total memory usage for " +
 		"the mempool\n" +
 		"  \"maxmempool\": xxxxx,         (numeric) Maximum memory usage " +
 		"for the mempool\n" +
-		"  \"mempoolminfee\": xxxxx       (numeric) Minimum fee for tx to " +
+		"  \"mempoolminfee\": xxxxx,      (numeric) Minimum fee for tx to " +
 		"be accepted\n" +
+		"  \"orphans\": xxxxx,            (numeric) Current orphan tx count\n" +
+		"  \"orphanbytes\": xxxxx,        (numeric) Sum of the orphan tx sizes\n" +
+		"  \"maxorphantx\": xxxxx         (numeric) Maximum number of orphan " +
+		"tx kept\n" +
 		"}\n" +
 		"\nExamples:\n" +
 		HelpExampleCli("getmempoolinfo") +
@@ -565,18 +680,57 @@ var (
 		HelpExampleCli("gettxoutsetinfo") +
 		HelpExampleRPC("gettxoutsetinfo")
 
-	pruneblockchainDesc = "pruneblockchain\n" +
+	dumpblockfileDesc = "dumpblockfile n\n" +
+		"\nReturns a summary of a block file from the block index.\n" +
+		"\nArguments:\n" +
+		"1. n    (numeric, required) The number of the blk?????.dat file\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"blocks\" : n,        (numeric) the number of blocks in the file\n" +
+		"  \"size\" : n,          (numeric) the bytes used in the block file\n" +
+		"  \"undosize\" : n,      (numeric) the bytes used in the undo file\n" +
+		"  \"heightfirst\" : n,   (numeric) the lowest height of the blocks\n" +
+		"  \"heightlast\" : n,    (numeric) the highest height of the blocks\n" +
+		"  \"timefirst\" : n,     (numeric) the earliest block time\n" +
+		"  \"timelast\" : n       (numeric) the latest block time\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("dumpblockfile", "0") +
+		HelpExampleRPC("dumpblockfile", "0")
+
+	pruneblockchainDesc = "pruneblockchain height\n" +
+		"\nDeletes the block and undo files up to a height, keeping the " +
+		"last 288 blocks. Requires -prune.\n" +
 		"\nArguments:\n" +
 		"1. \"height\"       (numeric, required) The block height to prune " +
 		"up to. May be set to a discrete height, or a unix timestamp\n" +
 		"                  to prune blocks whose block time is at least 2 " +
 		"hours older than the provided timestamp.\n" +
 		"\nResult:\n" +
-		"n    (numeric) Height of the last block pruned.\n" +
+		"n    (numeric) Height of the last block pruned, -1 if none is.\n" +
 		"\nExamples:\n" +
 		HelpExampleCli("pruneblockchain", "1000") +
 		HelpExampleRPC("pruneblockchain", "1000")
 
+	setmempoollimitDesc = "setmempoollimit maxorphantx\n" +
+		"\nSets the number of orphan transactions kept, evicting random " +
+		"orphans over it.\n" +
+		"\nArguments:\n" +
+		"1. maxorphantx    (numeric, required) The maximum number of orphan " +
+		"transactions\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("setmempoollimit", "50") +
+		HelpExampleRPC("setmempoollimit", "50")
+
+	savemempoolDesc = "savemempool\n" +
+		"\nDumps the mempool to disk. It fails while a previous dump is " +
+		"being written.\n" +
+		"\nResult:\n" +
+		"\"path\"    (string) The path of the mempool file\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("savemempool") +
+		HelpExampleRPC("savemempool")
+
 	verifychainDesc = "verifychain ( checklevel nblocks )\n" +
 		"\nVerifies blockchain database.\n" +
 		"\nArguments:\n" +
@@ -724,6 +878,8 @@ var (
 		"     {\n" +
 		"       \"mode\":\"template\"    (string, optional) This must be " +
 		"set to \"template\", \"proposal\" (see BIP 23), or omitted\n" +
+		"       \"data\":\"xxxx\"        (string, optional) The hex-encoded " +
+		"block to validate in proposal mode\n" +
 		"       \"capabilities\":[     (array, optional) A list of " +
 		"strings\n" +
 		"           \"support\"          (string) client side supported " +
@@ -736,7 +892,10 @@ var (
 		"           \"support\"          (string) client side supported " +
 		"softfork deployment\n" +
 		"           ,...\n" +
-		"       ]\n" +
+		"       ],\n" +
+		"       \"longpollid\":\"xxxx\"  (string, optional) The longpollid " +
+		"of a previous template, to wait until the tip or the mempool " +
+		"changes before answering\n" +
 		"     }\n" +
 		"\n" +
 		"\nResult:\n" +
@@ -918,6 +1077,8 @@ var (
 		"    \"bytessent\": n,            (numeric) The total bytes sent\n" +
 		"    \"bytesrecv\": n,            (numeric) The total bytes " +
 		"received\n" +
+		"    \"bytessent_in_cycle\": n,   (numeric) The bytes sent in " +
+		"the current maxuploadtarget cycle\n" +
 		"    \"conntime\": ttt,           (numeric) The connection time in " +
 		"seconds since epoch (Jan 1 1970 GMT)\n" +
 		"    \"timeoffset\": ttt,         (numeric) The time offset in " +
@@ -1111,6 +1272,21 @@ var (
 		"  }\n" +
 		"  ,...\n" +
 		"  ]\n" +
+		"  \"uploadtarget\": {                    " +
+		"(json object) outbound traffic limit set by -maxuploadtarget\n" +
+		"    \"timeframe\": n,                    " +
+		"(numeric) length of the measuring timeframe in seconds\n" +
+		"    \"target\": n,                       " +
+		"(numeric) target in bytes, 0 if there is no limit\n" +
+		"    \"target_reached\": true|false,      " +
+		"(boolean) true if target is reached\n" +
+		"    \"serve_historical_blocks\": true|false, " +
+		"(boolean) true if serving historical blocks\n" +
+		"    \"bytes_left_in_cycle\": n,          " +
+		"(numeric) bytes left in current time cycle\n" +
+		"    \"time_left_in_cycle\": n            " +
+		"(numeric) seconds left in current time cycle\n" +
+		"  }\n" +
 		"  \"warnings\": \"...\"                    " +
 		"(string) any network warnings\n" +
 		"}\n" +
@@ -1119,7 +1295,8 @@ var (
 		HelpExampleRPC("getnetworkinfo")
 
 	setbanDesc = "setban \"subnet\" \"add|remove\" (bantime) (absolute)\n" +
-		"\nAttempts add or remove a IP/Subnet from the banned list.\n" +
+		"\nAttempts add or remove a IP/Subnet from the banned list. Adding " +
+		"a banned IP/Subnet again updates the end of its ban.\n" +
 		"\nArguments:\n" +
 		"1. \"subnet\"       (string, required) The IP/Subnet (see " +
 		"getpeerinfo for nodes ip) with a optional netmask (default is /32 " +
@@ -1139,6 +1316,18 @@ var (
 
 	listbannedDesc = "listbanned\n" +
 		"\nList all banned IPs/Subnets.\n" +
+		"\nResult:\n" +
+		"[\n" +
+		"  {\n" +
+		"    \"address\": \"xxx\",      (string) The banned IP/Subnet\n" +
+		"    \"banned_until\": xxx,   (numeric) The unix time the ban ends\n" +
+		"    \"ban_created\": xxx,    (numeric) The unix time the ban was " +
+		"created\n" +
+		"    \"ban_reason\": \"xxx\"    (string) Why the IP/Subnet is " +
+		"banned\n" +
+		"  }\n" +
+		"  ,...\n" +
+		"]\n" +
 		"\nExamples:\n" +
 		HelpExampleCli("listbanned") +
 		HelpExampleRPC("listbanned")
@@ -1153,7 +1342,12 @@ var (
 		"\nDisable/enable all p2p network activity.\n" +
 		"\nArguments:\n" +
 		"1. \"state\"        (boolean, required) true to " +
-		"enable networking, false to disable\n"
+		"enable networking, false to disable\n" +
+		"\nResult:\n" +
+		"true|false    (boolean) Whether the network is active afterwards\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("setnetworkactive", "false") +
+		HelpExampleRPC("setnetworkactive", "false")
 )
 
 // rawtransaction
@@ -1196,6 +1390,11 @@ var (
 		"         \"asm\": \"asm\",  (string) asm\n" +
 		"         \"hex\": \"hex\"   (string) hex\n" +
 		"       },\n" +
+		"       \"prevout\": {       (json object) The spent output, only " +
+		"present if it could be resolved\n" +
+		"         \"value\" : x.xxx, (numeric) The value in BCH\n" +
+		"         \"scriptPubKey\" : {...} (json object) The spent script\n" +
+		"       },\n" +
 		"       \"sequence\": n      (numeric) The script sequence number\n" +
 		"     }\n" +
 		"     ,...\n" +
@@ -1218,6 +1417,8 @@ var (
 		"     }\n" +
 		"     ,...\n" +
 		"  ],\n" +
+		"  \"fee\" : x.xxx,          (numeric) The fee in BCH, only present " +
+		"if all prevouts could be resolved\n" +
 		"  \"blockhash\" : \"hash\",   (string) the block hash\n" +
 		"  \"confirmations\" : n,      (numeric) The confirmations\n" +
 		"  \"time\" : ttt,             (numeric) The transaction time in " +
@@ -1346,6 +1547,30 @@ var (
 		HelpExampleCli("decodescript", "\"hexstring\"") +
 		HelpExampleRPC("decodescript", "\"hexstring\"")
 
+	analyzescriptDesc = "analyzescript \"hexstring\"\n" +
+		"\nStatically analyze a hex-encoded script against the limits of the " +
+		"current consensus flags, without executing it.\n" +
+		"\nArguments:\n" +
+		"1. \"hexstring\"     (string) the hex encoded script\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"type\":\"type\",         (string) The output type\n" +
+		"  \"size\": n,             (numeric) The script size in bytes\n" +
+		"  \"opcount\": n,          (numeric) The non-push opcodes counting towards the opcode limit\n" +
+		"  \"sigopcount\": n,       (numeric) The accurate signature operation count\n" +
+		"  \"reqSigs\": n,          (numeric) The required signatures\n" +
+		"  \"keys\": n,             (numeric) The keys or addresses of the script\n" +
+		"  \"maxelementsize\": n,   (numeric) The size of the largest pushed element\n" +
+		"  \"pushonly\": true|false, (boolean) Whether the script only pushes data\n" +
+		"  \"violations\": [        (json array of string) The limits the script exceeds\n" +
+		"     \"error\"             (string) the script error it would fail with\n" +
+		"     ,...\n" +
+		"  ]\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("analyzescript", "\"hexstring\"") +
+		HelpExampleRPC("analyzescript", "\"hexstring\"")
+
 	sendrawtransactionDesc = "sendrawtransaction \"hexstring\" ( allowhighfees )\n" +
 		"\nSubmits raw transaction (serialized, hex-encoded) to local node " +
 		"and network.\n" +
@@ -1353,8 +1578,8 @@ var (
 		"\nArguments:\n" +
 		"1. \"hexstring\"    (string, required) The hex string of the raw " +
 		"transaction)\n" +
-		"2. allowhighfees    (boolean, optional, default=false) Allow high " +
-		"fees\n" +
+		"2. allowhighfees    (boolean, optional, default=false) Allow a " +
+		"fee over -maxtxfee\n" +
 		"\nResult:\n" +
 		"\"hex\"             (string) The transaction hash in hex\n" +
 		"\nExamples:\n" +
@@ -1600,6 +1825,96 @@ var (
 		HelpExampleRPC("createmultisig", "2",
 			"\"[\\\"16sSauSf5pF2UkUwvKGq4qjNRzBZYqgEL5\\\",\\\"171sgjn4YtPu27adkKGrdDwzRTxnRkBfKV\\\"]\"")
 
+	getdescriptorinfoDesc = "getdescriptorinfo \"descriptor\"\n" +
+		"\nAnalyses a descriptor.\n" +
+		"\nArguments:\n" +
+		"1. \"descriptor\"    (string, required) The descriptor\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"descriptor\" : \"desc\",       (string) The descriptor in " +
+		"canonical form, without private keys\n" +
+		"  \"checksum\" : \"chksum\",       (string) The checksum for the " +
+		"input descriptor\n" +
+		"  \"isrange\" : true|false,      (boolean) Whether the descriptor " +
+		"is ranged\n" +
+		"  \"issolvable\" : true|false,   (boolean) Whether the descriptor " +
+		"is solvable\n" +
+		"  \"hasprivatekeys\" : true|false, (boolean) Whether the input " +
+		"descriptor contained at least one private key\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		"\nAnalyse a descriptor\n" +
+		HelpExampleCli("getdescriptorinfo",
+			"\"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)\"") +
+		HelpExampleRPC("getdescriptorinfo",
+			"\"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)\"")
+
+	deriveaddressesDesc = "deriveaddresses \"descriptor\" ( [begin,end] )\n" +
+		"\nDerives one or more addresses corresponding to an output " +
+		"descriptor.\n" +
+		"The descriptor must carry its checksum, see getdescriptorinfo.\n" +
+		"\nArguments:\n" +
+		"1. \"descriptor\"    (string, required) The descriptor\n" +
+		"2. [begin,end]     (array, optional) The range to derive for a " +
+		"ranged descriptor\n" +
+		"\nResult:\n" +
+		"[\n" +
+		"  \"address\"       (string) the derived addresses\n" +
+		"  ,...\n" +
+		"]\n" +
+		"\nExamples:\n" +
+		"\nDerive the address of a descriptor\n" +
+		HelpExampleCli("deriveaddresses",
+			"\"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)#8fhd9pwu\"") +
+		HelpExampleRPC("deriveaddresses",
+			"\"pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)#8fhd9pwu\"")
+
+	getindexinfoDesc = "getindexinfo ( \"index_name\" )\n" +
+		"\nReturns the status of one or all available indices currently " +
+		"running in the node.\n" +
+		"\nArguments:\n" +
+		"1. \"index_name\"  (string, optional) Filter results for an " +
+		"index with a specific name.\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"name\" : {                   (json object) The name of the index\n" +
+		"    \"synced\" : true|false,      (boolean) Whether the index is " +
+		"synced or not\n" +
+		"    \"best_block_height\" : n     (numeric) The block height to " +
+		"which the index is synced\n" +
+		"  }\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getindexinfo") +
+		HelpExampleRPC("getindexinfo") +
+		HelpExampleCli("getindexinfo", "txindex") +
+		HelpExampleRPC("getindexinfo", "\"txindex\"")
+
+	getnodefeeinfoDesc = "getnodefeeinfo\n" +
+		"\nReturns the fee rates the node holds transactions to, and its " +
+		"fee estimates, in BCH/kB.\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"minrelaytxfee\" : x.x,        (numeric) Lowest fee rate of a " +
+		"transaction accepted into the mempool\n" +
+		"  \"incrementalrelayfee\" : x.x,  (numeric) Fee rate the mempool " +
+		"min fee is raised by over the evicted transactions\n" +
+		"  \"mempoolminfee\" : x.x,        (numeric) Current minimum fee " +
+		"rate of the mempool\n" +
+		"  \"estimates\" : [               (json array) The fee estimates\n" +
+		"    {\n" +
+		"      \"blocks\" : n,             (numeric) The confirmation " +
+		"target in blocks\n" +
+		"      \"feerate\" : x.x           (numeric) The estimated fee " +
+		"rate, -1 when there is no estimate\n" +
+		"    }\n" +
+		"    ,...\n" +
+		"  ]\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getnodefeeinfo") +
+		HelpExampleRPC("getnodefeeinfo")
+
 	echoDesc = "echo \"message\" ...\n" +
 		"\nSimply echo back the input arguments. This command is for testing."
 
@@ -1611,6 +1926,84 @@ var (
 		"\nExamples:\n" +
 		HelpExampleCli("uptime") +
 		HelpExampleRPC("uptime")
+
+	getrpcinfoDesc = "getrpcinfo\n" +
+		"\nReturns details of the RPC server.\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"active_commands\" : [       (json array) All active commands\n" +
+		"    {                          (json object) Information about an " +
+		"active command\n" +
+		"      \"method\" : \"string\",    (string) The name of the RPC " +
+		"command\n" +
+		"      \"duration\" : n          (numeric) The running time in " +
+		"microseconds\n" +
+		"    },...\n" +
+		"  ],\n" +
+		"  \"logpath\" : \"string\"      (string) The complete file path " +
+		"to the debug log\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getrpcinfo") +
+		HelpExampleRPC("getrpcinfo")
+
+	getmemoryinfoDesc = "getmemoryinfo\n" +
+		"\nReturns the memory used by the caches of the node, in bytes.\n" +
+		"\nResult:\n" +
+		"{\n" +
+		"  \"coinscache\" : n,      (numeric) The coins cache\n" +
+		"  \"mempool\" : n,         (numeric) The transactions of the mempool\n" +
+		"  \"orphans\" : n,         (numeric) The orphan transactions\n" +
+		"  \"blockindex\" : n,      (numeric) The block index\n" +
+		"  \"runtime\" : {          (json object) The Go runtime memory statistics\n" +
+		"    \"heapalloc\" : n,     (numeric) The allocated heap objects\n" +
+		"    \"sys\" : n,           (numeric) The memory obtained from the OS\n" +
+		"    \"numgc\" : n          (numeric) The number of completed GC cycles\n" +
+		"  }\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("getmemoryinfo") +
+		HelpExampleRPC("getmemoryinfo")
+
+	setgcDesc = "setgc percent\n" +
+		"\nSets the garbage collection target percentage, the growth of the " +
+		"heap over the live data that triggers a collection.\n" +
+		"\nArguments:\n" +
+		"1. percent    (numeric, required) The new percentage, negative " +
+		"to disable the collector\n" +
+		"\nResult:\n" +
+		"n             (numeric) The previous percentage\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("setgc", "50") +
+		HelpExampleRPC("setgc", "50")
+
+	loggingDesc = "logging ( [\"include_category\",...] [\"exclude_category\",...] )\n" +
+		"\nGets and sets the logging configuration.\n" +
+		"When called without an argument, returns the list of categories " +
+		"with status that are currently being debug logged or not.\n" +
+		"When called with arguments, adds or removes categories from debug " +
+		"logging and returns the lists above.\n" +
+		"The arguments are evaluated in order \"include\", \"exclude\".\n" +
+		"If an item is both included and excluded, it will thus end up " +
+		"being excluded.\n" +
+		"The valid logging categories are: bench, db, mempool, net, rpc, " +
+		"validation and the modules enabled in the configuration.\n" +
+		"The special category \"all\" stands for all of them.\n" +
+		"\nArguments:\n" +
+		"1. \"include\"        (array of strings, optional) A json array " +
+		"of categories to add debug logging\n" +
+		"2. \"exclude\"        (array of strings, optional) A json array " +
+		"of categories to remove debug logging\n" +
+		"\nResult:\n" +
+		"{                   (json object where keys are the logging " +
+		"categories, and values indicates its status\n" +
+		"  \"category\": true|false,  (boolean) if being debug logged " +
+		"or not\n" +
+		"  ...\n" +
+		"}\n" +
+		"\nExamples:\n" +
+		HelpExampleCli("logging", "\"[\\\"all\\\"]\"", "\"[\\\"net\\\"]\"") +
+		HelpExampleRPC("logging", "[\"all\"]", "[\"net\"]")
 )
 
 // wallet
@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/rpc/btcjson"
+	"github.com/copernet/copernicus/util"
+)
+
+// longPollMempoolInterval is how long a longpolling getblocktemplate waits for
+// a new tip before a mempool update is enough to return a new template.
+const longPollMempoolInterval = time.Minute
+
+// longPollSource provides the state a longpoll id is made of.
+type longPollSource interface {
+	TipHash() util.Hash
+	// TransactionsUpdated is a counter bumped on every mempool change.
+	TransactionsUpdated() uint64
+}
+
+type nodeLongPollSource struct{}
+
+func (nodeLongPollSource) TipHash() util.Hash {
+	return *chain.GetInstance().Tip().GetBlockHash()
+}
+
+func (nodeLongPollSource) TransactionsUpdated() uint64 {
+	return mempool.GetInstance().TransactionsUpdated
+}
+
+// tipNotifier wakes the longpolling getblocktemplate calls when a block is
+// connected. It is safe for concurrent access.
+type tipNotifier struct {
+	mtx     sync.Mutex
+	changed chan struct{}
+}
+
+var blockTemplateNotifier = newTipNotifier()
+
+func newTipNotifier() *tipNotifier {
+	return &tipNotifier{changed: make(chan struct{})}
+}
+
+// Changed returns a channel closed on the next tip change.
+func (n *tipNotifier) Changed() <-chan struct{} {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.changed
+}
+
+// Notify wakes everyone waiting on the channel returned by Changed.
+func (n *tipNotifier) Notify() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+func (n *tipNotifier) handleBlockchainNotification(notification *chain.Notification) {
+	switch notification.Type {
+	case chain.NTBlockConnected, chain.NTBlockDisconnected:
+		n.Notify()
+	}
+}
+
+// makeLongPollID returns the longpoll id of a template built on the tip after
+// the given number of mempool updates.
+func makeLongPollID(tipHash util.Hash, transactionsUpdated uint64) string {
+	return tipHash.String() + strconv.FormatUint(transactionsUpdated, 10)
+}
+
+// parseLongPollID splits a longpoll id into the tip hash and the mempool update
+// counter it was made of.
+func parseLongPollID(id string) (util.Hash, uint64, error) {
+	if len(id) <= util.MaxHashStringSize {
+		return util.Hash{}, 0, rpcInvalidLongPollID(id)
+	}
+	hash, err := util.ParseHash(id[:util.MaxHashStringSize])
+	if err != nil {
+		return util.Hash{}, 0, rpcInvalidLongPollID(id)
+	}
+	transactionsUpdated, err := strconv.ParseUint(id[util.MaxHashStringSize:], 10, 64)
+	if err != nil {
+		return util.Hash{}, 0, rpcInvalidLongPollID(id)
+	}
+	return hash, transactionsUpdated, nil
+}
+
+func rpcInvalidLongPollID(id string) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Invalid longpollid " + id,
+	}
+}
+
+// waitForLongPoll blocks until the tip is no longer the one the longpoll id
+// was made for, or until the mempool changed and longPollMempoolInterval
+// passed. It returns an error when the client goes away first.
+func waitForLongPoll(id string, src longPollSource, notifier *tipNotifier,
+	closeChan <-chan struct{}) error {
+
+	hashWatched, updatedWatched, err := parseLongPollID(id)
+	if err != nil {
+		return err
+	}
+
+	checkTxTime := time.Now().Add(longPollMempoolInterval)
+	for {
+		// Take the channel before looking at the tip, so that a change in
+		// between is not missed.
+		changed := notifier.Changed()
+		if src.TipHash() != hashWatched {
+			return nil
+		}
+		if !time.Now().Before(checkTxTime) {
+			if src.TransactionsUpdated() != updatedWatched {
+				return nil
+			}
+			checkTxTime = time.Now().Add(longPollMempoolInterval)
+		}
+
+		timer := time.NewTimer(time.Until(checkTxTime))
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-closeChan:
+			timer.Stop()
+			return &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNotConnected,
+				Message: "Client disconnected while waiting for a new block template",
+			}
+		}
+		timer.Stop()
+	}
+}
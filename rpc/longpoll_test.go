@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/logic/lmempool"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/util"
+)
+
+type fakeLongPollSource struct {
+	mtx                 sync.Mutex
+	tipHash             util.Hash
+	transactionsUpdated uint64
+}
+
+func (src *fakeLongPollSource) TipHash() util.Hash {
+	src.mtx.Lock()
+	defer src.mtx.Unlock()
+	return src.tipHash
+}
+
+func (src *fakeLongPollSource) TransactionsUpdated() uint64 {
+	src.mtx.Lock()
+	defer src.mtx.Unlock()
+	return src.transactionsUpdated
+}
+
+func (src *fakeLongPollSource) connectBlock(hash util.Hash) {
+	src.mtx.Lock()
+	src.tipHash = hash
+	src.mtx.Unlock()
+}
+
+func TestParseLongPollID(t *testing.T) {
+	id := makeLongPollID(util.Hash{1, 2, 3}, 42)
+	hash, transactionsUpdated, err := parseLongPollID(id)
+	if err != nil {
+		t.Fatalf("parse %s failed: %v", id, err)
+	}
+	if hash != (util.Hash{1, 2, 3}) || transactionsUpdated != 42 {
+		t.Errorf("expect hash and counter back, got %s and %d", hash, transactionsUpdated)
+	}
+
+	for _, id := range []string{"", util.Hash{}.String(), util.Hash{}.String() + "x", "zz" + id[2:]} {
+		if _, _, err := parseLongPollID(id); err == nil {
+			t.Errorf("expect error for longpoll id %q", id)
+		}
+	}
+}
+
+func TestWaitForLongPoll(t *testing.T) {
+	src := &fakeLongPollSource{tipHash: util.Hash{1}, transactionsUpdated: 7}
+	notifier := newTipNotifier()
+	id := makeLongPollID(src.TipHash(), src.TransactionsUpdated())
+
+	released := make(chan error)
+	go func() {
+		released <- waitForLongPoll(id, src, notifier, nil)
+	}()
+
+	select {
+	case err := <-released:
+		t.Fatalf("longpoll returned before the tip changed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	src.connectBlock(util.Hash{2})
+	notifier.handleBlockchainNotification(&chain.Notification{Type: chain.NTBlockConnected})
+
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatalf("longpoll failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("longpoll not released by the block connection")
+	}
+
+	newID := makeLongPollID(src.TipHash(), src.TransactionsUpdated())
+	if newID == id {
+		t.Errorf("expect a new longpoll id after the tip changed")
+	}
+
+	// a longpoll on a stale id returns at once
+	if err := waitForLongPoll(id, src, notifier, nil); err != nil {
+		t.Errorf("longpoll on stale id failed: %v", err)
+	}
+}
+
+func TestWaitForLongPollClientGone(t *testing.T) {
+	src := &fakeLongPollSource{tipHash: util.Hash{1}}
+	closeChan := make(chan struct{})
+	close(closeChan)
+
+	err := waitForLongPoll(makeLongPollID(src.TipHash(), 0), src, newTipNotifier(), closeChan)
+	if err == nil {
+		t.Errorf("expect error when the client went away")
+	}
+}
+
+func TestWaitForLongPollThroughNode(t *testing.T) {
+	defer initTestChain(t)()
+
+	notifier := newTipNotifier()
+	chain.GetInstance().Subscribe(notifier.handleBlockchainNotification)
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 101)
+
+	src := nodeLongPollSource{}
+	id := makeLongPollID(src.TipHash(), src.TransactionsUpdated())
+
+	released := make(chan error)
+	go func() {
+		released <- waitForLongPoll(id, src, notifier, nil)
+	}()
+
+	// a mempool change alone does not release the longpoll before
+	// longPollMempoolInterval
+	if err := lmempool.AcceptTxToMemPool(newSpendingTx(10000, coinbaseOut(t, 1))); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	if src.TransactionsUpdated() == 0 {
+		t.Fatalf("expect the mempool update counter to be bumped")
+	}
+	select {
+	case err := <-released:
+		t.Fatalf("longpoll returned before the tip changed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mineBlocks(t, opTrue, 1)
+
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatalf("longpoll failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("longpoll not released by the block connection")
+	}
+}
@@ -163,14 +163,14 @@ func handleGetBlockTemplateRequest(s *Server, request *btcjson.TemplateRequest,
 		}
 	}
 
-	//if request != nil && request.LongPollID != "" {
 	// Wait to respond until either the best block changes, OR a minute has
 	// passed and there are more transactions
-	//var hashWatchedChain utils.Hash
-	//checktxtime := time.Now()
-	//transactionsUpdatedLastLP := 0
-	// todo complete
-	//}
+	if request != nil && request.LongPollID != "" {
+		err := waitForLongPoll(request.LongPollID, nodeLongPollSource{}, blockTemplateNotifier, closeChan)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	persist.CsMain.Lock() //lock chain tip for CreateNewBlock
 	defer persist.CsMain.Unlock()
@@ -272,7 +272,7 @@ func blockTemplateResult(bt *mining.BlockTemplate, s *set.Set, maxVersionVb uint
 		Transactions:  transactions,
 		CoinbaseAux:   &btcjson.GetBlockTemplateResultAux{Flags: mining.CoinbaseFlag},
 		CoinbaseValue: (*int64)(&coinbaseValue),
-		LongPollID:    chain.GetInstance().Tip().GetBlockHash().String() + fmt.Sprintf("%d", transactionsUpdatedLast),
+		LongPollID:    makeLongPollID(*indexPrev.GetBlockHash(), transactionsUpdatedLast),
 		Target:        fmt.Sprintf("%064x", &target),
 		MinTime:       indexPrev.GetMedianTimePast() + 1,
 		Mutable:       mutable,
@@ -331,39 +331,23 @@ func handleGetBlockTemplateProposal(request *btcjson.TemplateRequest) (interface
 		}
 	}
 
+	persist.CsMain.Lock()
+	defer persist.CsMain.Unlock()
+
 	hash := bk.Header.GetHash()
 	bindex := chain.GetInstance().FindBlockIndex(hash)
 	if bindex != nil {
 		if bindex.IsValid(blockindex.BlockValidScripts) {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrUnDefined,
-				Message: "duplicate",
-			}
+			return "duplicate", nil
 		}
-
 		if bindex.IsInvalid() {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrUnDefined,
-				Message: "duplicate-invalid",
-			}
+			return "duplicate-invalid", nil
 		}
-
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrUnDefined,
-			Message: "duplicate-inconclusive",
-		}
-	}
-
-	indexPrev := chain.GetInstance().Tip()
-	// TestBlockValidity only supports blocks built on the current Tip
-	if bk.Header.HashPrevBlock != *indexPrev.GetBlockHash() {
-		return "inconclusive-not-best-prevblk", nil
+		return "duplicate-inconclusive", nil
 	}
 
-	err = mining.TestBlockValidity(&bk, indexPrev, false, true)
-	err = errcode.GetBip22Result(err)
-	//err = lblock.CheckBlock(&bk, true, true)
-	return BIP22ValidationResult(err)
+	err = mining.CheckProposal(&bk)
+	return BIP22ValidationResult(errcode.GetBip22Result(err))
 }
 
 func BIP22ValidationResult(err error) (interface{}, error) {
@@ -6,14 +6,22 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
 
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lindex"
 	"github.com/copernet/copernicus/logic/lwallet"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/net/server"
 	"github.com/copernet/copernicus/net/wire"
 	"github.com/copernet/copernicus/rpc/btcjson"
@@ -21,6 +29,7 @@ import (
 	"github.com/copernet/copernicus/util"
 	"github.com/copernet/copernicus/util/base58"
 	"github.com/copernet/copernicus/util/cashaddr"
+	"github.com/copernet/copernicus/util/descriptor"
 )
 
 // API version constants
@@ -43,6 +52,42 @@ var miscHandlers = map[string]commandHandler{
 	"stop":                   handleStop,
 	"version":                handleVersion,
 	"uptime":                 handleUptime,
+	"logging":                handleLogging,
+	"getrpcinfo":             handleGetRPCInfo,
+	"getmemoryinfo":          handleGetMemoryInfo,
+	"setgc":                  handleSetGC,
+	"getindexinfo":           handleGetIndexInfo,
+	"getnodefeeinfo":         handleGetNodeFeeInfo,
+	"getdescriptorinfo":      handleGetDescriptorInfo,
+	"deriveaddresses":        handleDeriveAddresses,
+}
+
+// nodeFeeInfoTargets are the confirmation targets, in blocks, of the fee
+// estimates of getnodefeeinfo.
+var nodeFeeInfoTargets = []uint32{2, 6, 25}
+
+// handleGetNodeFeeInfo implements the getnodefeeinfo command.
+func handleGetNodeFeeInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	pool := mempool.GetInstance()
+	minRelayTxFee := pool.MinRelayTxFee()
+	incrementalRelayFee := pool.IncrementalRelayFee()
+	mempoolMinFee := pool.GetMinFeeRate()
+	ret := &btcjson.GetNodeFeeInfoResult{
+		MinRelayTxFee:       valueFromAmount(minRelayTxFee.SataoshisPerK),
+		IncrementalRelayFee: valueFromAmount(incrementalRelayFee.SataoshisPerK),
+		MempoolMinFee:       valueFromAmount(mempoolMinFee.SataoshisPerK),
+		Estimates:           make([]btcjson.FeeEstimateResult, 0, len(nodeFeeInfoTargets)),
+	}
+	for _, blocks := range nodeFeeInfoTargets {
+		feeRate := -1.0
+		if s.cfg.FeeEstimator != nil {
+			if rate, err := s.cfg.FeeEstimator.EstimateFee(blocks); err == nil {
+				feeRate = valueFromAmount(rate.SataoshisPerK)
+			}
+		}
+		ret.Estimates = append(ret.Estimates, btcjson.FeeEstimateResult{Blocks: blocks, FeeRate: feeRate})
+	}
+	return ret, nil
 }
 
 // handleUptime implements the uptime command.
@@ -50,6 +95,88 @@ func handleUptime(s *Server, cmd interface{}, closeChan <-chan struct{}) (interf
 	return util.GetTimeSec() - s.cfg.StartupTime, nil
 }
 
+// handleGetRPCInfo implements the getrpcinfo command.
+func handleGetRPCInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return &btcjson.GetRPCInfoResult{
+		ActiveCommands: s.activeCommands.list(),
+		LogPath:        log.FilePath(),
+	}, nil
+}
+
+// handleGetMemoryInfo implements the getmemoryinfo command.
+func handleGetMemoryInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	pool := mempool.GetInstance()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return &btcjson.GetMemoryInfoResult{
+		CoinsCache: utxo.GetUtxoCacheInstance().DynamicMemoryUsage(),
+		Mempool:    pool.GetPoolUsage(),
+		Orphans:    int64(pool.OrphanBytes()),
+		BlockIndex: chain.GetInstance().DynamicMemoryUsage(),
+		Runtime: btcjson.RuntimeMemoryInfo{
+			HeapAlloc: stats.HeapAlloc,
+			Sys:       stats.Sys,
+			NumGC:     stats.NumGC,
+		},
+	}, nil
+}
+
+// handleSetGC implements the setgc command.
+func handleSetGC(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetGCCmd)
+	previous := debug.SetGCPercent(c.Percent)
+	if conf.Cfg != nil {
+		conf.Cfg.PProf.GCPercent = c.Percent
+	}
+	log.Info("Garbage collection target percentage set to %d", c.Percent)
+	return previous, nil
+}
+
+// handleLogging implements the logging command.
+func handleLogging(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.LoggingCmd)
+
+	var include, exclude []string
+	if c.Include != nil {
+		include = *c.Include
+	}
+	if c.Exclude != nil {
+		exclude = *c.Exclude
+	}
+	for _, name := range append(include, exclude...) {
+		if !log.HasCategory(name) {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+				fmt.Sprintf("unknown logging category %s, known ones are: all, %s",
+					name, strings.Join(log.CategoryNames(), ", ")))
+		}
+	}
+	for _, name := range include {
+		log.SetCategory(name, true)
+	}
+	for _, name := range exclude {
+		log.SetCategory(name, false)
+	}
+
+	return log.CategoryStates(), nil
+}
+
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetIndexInfoCmd)
+
+	result := make(map[string]*btcjson.GetIndexInfoResult)
+	for name, info := range lindex.GetIndexInfo() {
+		if c.IndexName != nil && *c.IndexName != name {
+			continue
+		}
+		result[name] = &btcjson.GetIndexInfoResult{
+			Synced:          info.Synced,
+			BestBlockHeight: info.BestBlockHeight,
+		}
+	}
+	return result, nil
+}
+
 func handleGetInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := chain.GetInstance().Tip()
 	var height int32
@@ -82,7 +209,7 @@ func handleGetInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (inter
 		Proxy:           conf.Cfg.P2PNet.Proxy,
 		Difficulty:      getDifficulty(chain.GetInstance().Tip()),
 		TestNet:         model.ActiveNetParams.BitcoinNet == wire.TestNet3,
-		RelayFee:        0, // todo define DefaultMinRelayTxFee
+		RelayFee:        valueFromAmount(mempool.GetInstance().MinRelayTxFee().SataoshisPerK),
 	}
 
 	return ret, nil
@@ -128,6 +255,54 @@ func handleCreatemultisig(s *Server, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleGetDescriptorInfo implements the getdescriptorinfo command.
+func handleGetDescriptorInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetDescriptorInfoCmd)
+
+	desc, err := descriptor.Parse(c.Descriptor, false)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, err.Error())
+	}
+	checksum, err := descriptor.Checksum(strings.SplitN(c.Descriptor, "#", 2)[0])
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, err.Error())
+	}
+
+	return &btcjson.GetDescriptorInfoResult{
+		Descriptor:     desc.String(),
+		Checksum:       checksum,
+		IsRange:        desc.IsRange(),
+		IsSolvable:     desc.IsSolvable(),
+		HasPrivateKeys: desc.HasPrivateKeys(),
+	}, nil
+}
+
+// handleDeriveAddresses implements the deriveaddresses command. Only
+// descriptors carrying their checksum are accepted.
+func handleDeriveAddresses(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DeriveAddressesCmd)
+
+	desc, err := descriptor.Parse(c.Descriptor, true)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, err.Error())
+	}
+	if c.Range != nil && !desc.IsRange() {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			"Range should not be specified for an un-ranged descriptor")
+	}
+
+	addresses := make([]string, 0, 1)
+	for _, scriptPubKey := range desc.ScriptPubKeys() {
+		sType, dests, _, err := scriptPubKey.ExtractDestinations()
+		if err != nil || (sType != script.ScriptPubkeyHash && sType != script.ScriptHash) {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey,
+				"Descriptor does not have a corresponding address")
+		}
+		addresses = append(addresses, dests[0].String())
+	}
+	return addresses, nil
+}
+
 func handleVerifyMessage(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	/*	c := cmd.(*btcjson.VerifyMessageCmd)
 
@@ -0,0 +1,257 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/rpc/btcjson"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+func TestUptime(t *testing.T) {
+	start := util.GetTimeSec()
+	defer util.SetMockTime(0)
+	s := &Server{cfg: ServerConfig{StartupTime: start}}
+
+	util.SetMockTime(start)
+	first, err := handleUptime(s, &btcjson.UptimeCmd{}, nil)
+	if err != nil || first.(int64) < 0 {
+		t.Fatalf("uptime returned %v, %v", first, err)
+	}
+
+	util.SetMockTime(start + 10)
+	second, err := handleUptime(s, &btcjson.UptimeCmd{}, nil)
+	if err != nil || second.(int64) != first.(int64)+10 {
+		t.Errorf("uptime went from %v to %v after 10 seconds", first, second)
+	}
+}
+
+func TestGetRPCInfo(t *testing.T) {
+	s := &Server{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	rpcHandlers["testslowcommand"] = func(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}
+	defer delete(rpcHandlers, "testslowcommand")
+
+	done := make(chan struct{})
+	go func() {
+		s.standardCmdResult(&parsedRPCCmd{method: "testslowcommand"}, nil)
+		close(done)
+	}()
+	<-started
+	time.Sleep(10 * time.Millisecond)
+
+	ret, err := handleGetRPCInfo(s, &btcjson.GetRPCInfoCmd{}, nil)
+	if err != nil {
+		t.Fatalf("getrpcinfo failed: %v", err)
+	}
+	active := ret.(*btcjson.GetRPCInfoResult).ActiveCommands
+	if len(active) != 1 || active[0].Method != "testslowcommand" {
+		t.Fatalf("unexpected active commands %v", active)
+	}
+	if active[0].Duration < int64(10*time.Millisecond/time.Microsecond) {
+		t.Errorf("duration %dus is shorter than the time the command ran",
+			active[0].Duration)
+	}
+
+	close(release)
+	<-done
+	ret, err = handleGetRPCInfo(s, &btcjson.GetRPCInfoCmd{}, nil)
+	if err != nil {
+		t.Fatalf("getrpcinfo failed: %v", err)
+	}
+	if active := ret.(*btcjson.GetRPCInfoResult).ActiveCommands; len(active) != 0 {
+		t.Errorf("finished command still active: %v", active)
+	}
+}
+
+func TestStop(t *testing.T) {
+	s := &Server{requestProcessShutdown: make(chan struct{}, 1)}
+	ret, err := handleStop(s, &btcjson.StopCmd{}, nil)
+	if err != nil || ret != "Copernicus server stopping" {
+		t.Fatalf("stop returned %v, %v", ret, err)
+	}
+	select {
+	case <-s.RequestedProcessShutdown():
+	default:
+		t.Fatal("stop did not request the shutdown")
+	}
+}
+
+func TestGetMemoryInfo(t *testing.T) {
+	defer initTestChain(t)()
+
+	cache := utxo.GetUtxoCacheInstance()
+	bestHash, err := cache.GetBestBlock()
+	if err != nil {
+		t.Fatalf("get best block failed: %v", err)
+	}
+	cm := utxo.NewEmptyCoinsMap()
+	for i := 0; i < 10; i++ {
+		coin := utxo.NewFreshCoin(txout.NewTxOut(1, script.NewScriptRaw(make([]byte, 25))), 1, false)
+		cm.AddCoin(outpoint.NewOutPoint(util.Hash{1}, uint32(i)), coin, false)
+	}
+	if err := cache.UpdateCoins(cm, &bestHash); err != nil {
+		t.Fatalf("update coins failed: %v", err)
+	}
+
+	ret, err := handleGetMemoryInfo(&Server{}, &btcjson.GetMemoryInfoCmd{}, nil)
+	if err != nil {
+		t.Fatalf("getmemoryinfo failed: %v", err)
+	}
+	info := ret.(*btcjson.GetMemoryInfoResult)
+	if info.CoinsCache == 0 || info.CoinsCache != cache.DynamicMemoryUsage() {
+		t.Errorf("coins cache usage %d, the cache accounts %d", info.CoinsCache, cache.DynamicMemoryUsage())
+	}
+	if info.BlockIndex == 0 {
+		t.Error("the block index of the genesis block uses no memory")
+	}
+	if info.Runtime.HeapAlloc == 0 || info.Runtime.Sys == 0 {
+		t.Errorf("missing runtime memory statistics %+v", info.Runtime)
+	}
+}
+
+func TestSetGC(t *testing.T) {
+	original := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(original)
+
+	ret, err := handleSetGC(&Server{}, &btcjson.SetGCCmd{Percent: 50}, nil)
+	if err != nil || ret != 100 {
+		t.Fatalf("setgc returned %v, %v, want the previous percentage 100", ret, err)
+	}
+	if previous := debug.SetGCPercent(100); previous != 50 {
+		t.Errorf("garbage collection target percentage %d, want 50", previous)
+	}
+}
+
+// testFeeEstimator estimates a fee rate of 1000 satoshis per kB per block
+// below 25 blocks, and has no estimate beyond.
+type testFeeEstimator struct{}
+
+func (testFeeEstimator) EstimateFee(numBlocks uint32) (util.FeeRate, error) {
+	if numBlocks >= 25 {
+		return util.FeeRate{}, errors.New("insufficient data")
+	}
+	return *util.NewFeeRatePerK(amount.Amount(1000 * (25 - numBlocks))), nil
+}
+
+func TestGetNodeFeeInfo(t *testing.T) {
+	defer initTestChain(t)()
+	conf.Cfg.Mempool.MinRelayTxFee = 2000
+	conf.Cfg.Mempool.IncrementalRelayFee = 3000
+	mempool.InitMempool()
+
+	ret, err := handleGetNodeFeeInfo(&Server{}, &btcjson.GetNodeFeeInfoCmd{}, nil)
+	if err != nil {
+		t.Fatalf("getnodefeeinfo failed: %v", err)
+	}
+	want := &btcjson.GetNodeFeeInfoResult{
+		MinRelayTxFee:       0.00002,
+		IncrementalRelayFee: 0.00003,
+		MempoolMinFee:       0,
+		Estimates: []btcjson.FeeEstimateResult{
+			{Blocks: 2, FeeRate: -1},
+			{Blocks: 6, FeeRate: -1},
+			{Blocks: 25, FeeRate: -1},
+		},
+	}
+	if !reflect.DeepEqual(ret, want) {
+		t.Errorf("getnodefeeinfo without an estimator returned %+v, want %+v", ret, want)
+	}
+
+	s := &Server{cfg: ServerConfig{FeeEstimator: testFeeEstimator{}}}
+	ret, err = handleGetNodeFeeInfo(s, &btcjson.GetNodeFeeInfoCmd{}, nil)
+	if err != nil {
+		t.Fatalf("getnodefeeinfo failed: %v", err)
+	}
+	want.Estimates = []btcjson.FeeEstimateResult{
+		{Blocks: 2, FeeRate: 0.00023},
+		{Blocks: 6, FeeRate: 0.00019},
+		{Blocks: 25, FeeRate: -1},
+	}
+	if !reflect.DeepEqual(ret, want) {
+		t.Errorf("getnodefeeinfo returned %+v, want %+v", ret, want)
+	}
+}
+
+func TestGetDescriptorInfo(t *testing.T) {
+	crypto.InitSecp256()
+
+	pubKey := "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	desc := "pkh(" + pubKey + ")"
+	ret, err := handleGetDescriptorInfo(nil, btcjson.NewGetDescriptorInfoCmd(desc), nil)
+	if err != nil {
+		t.Fatalf("getdescriptorinfo %s failed: %v", desc, err)
+	}
+	want := &btcjson.GetDescriptorInfoResult{
+		Descriptor: desc + "#8fhd9pwu",
+		Checksum:   "8fhd9pwu",
+		IsSolvable: true,
+	}
+	if !reflect.DeepEqual(ret, want) {
+		t.Errorf("getdescriptorinfo %s returned %+v, want %+v", desc, ret, want)
+	}
+
+	// the checksummed descriptor round trips
+	ret, err = handleGetDescriptorInfo(nil, btcjson.NewGetDescriptorInfoCmd(want.Descriptor), nil)
+	if err != nil || !reflect.DeepEqual(ret, want) {
+		t.Errorf("getdescriptorinfo %s returned %+v, %v", want.Descriptor, ret, err)
+	}
+
+	for _, invalid := range []string{desc + "#00000000", "pkh(00)", "raw(zz)"} {
+		if _, err := handleGetDescriptorInfo(nil, btcjson.NewGetDescriptorInfoCmd(invalid), nil); err == nil {
+			t.Errorf("getdescriptorinfo accepted %s", invalid)
+		}
+	}
+}
+
+func TestDeriveAddresses(t *testing.T) {
+	crypto.InitSecp256()
+
+	pubKey, _ := hex.DecodeString("02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5")
+	addr, err := script.AddressFromHash160(util.Hash160(pubKey), script.AddressVerPubKey())
+	if err != nil {
+		t.Fatalf("make address failed: %v", err)
+	}
+
+	desc := "pkh(" + hex.EncodeToString(pubKey) + ")"
+	if _, err := handleDeriveAddresses(nil, btcjson.NewDeriveAddressesCmd(desc, nil), nil); err == nil {
+		t.Error("deriveaddresses accepted a descriptor without a checksum")
+	}
+
+	info, err := handleGetDescriptorInfo(nil, btcjson.NewGetDescriptorInfoCmd(desc), nil)
+	if err != nil {
+		t.Fatalf("getdescriptorinfo %s failed: %v", desc, err)
+	}
+	ret, err := handleDeriveAddresses(nil, btcjson.NewDeriveAddressesCmd(desc+"#"+info.(*btcjson.GetDescriptorInfoResult).Checksum, nil), nil)
+	if err != nil {
+		t.Fatalf("deriveaddresses %s failed: %v", desc, err)
+	}
+	if !reflect.DeepEqual(ret, []string{addr.String()}) {
+		t.Errorf("deriveaddresses %s returned %v, want [%s]", desc, ret, addr)
+	}
+
+	if _, err := handleDeriveAddresses(nil, btcjson.NewDeriveAddressesCmd(desc+"#8fhd9pwu", &[]int{0, 2}), nil); err == nil {
+		t.Error("deriveaddresses accepted a range for an un-ranged descriptor")
+	}
+	if _, err := handleDeriveAddresses(nil, btcjson.NewDeriveAddressesCmd("raw(deadbeef)#89f8spxm", nil), nil); err == nil {
+		t.Error("deriveaddresses derived an address for a non-standard script")
+	}
+}
@@ -2,14 +2,11 @@ package rpc
 
 import (
 	"fmt"
-	"math"
 	"time"
 
 	"github.com/copernet/copernicus/net/server"
-	"github.com/copernet/copernicus/net/wire"
 	"github.com/copernet/copernicus/rpc/btcjson"
 	"github.com/copernet/copernicus/service"
-	"github.com/copernet/copernicus/util"
 )
 
 var netHandlers = map[string]commandHandler{
@@ -48,17 +45,7 @@ func handleGetConnectionCount(s *Server, cmd interface{}, closeChan <-chan struc
 }
 
 func handlePing(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	nonce := util.GetRand(math.MaxInt64)
-	pingCmd := wire.NewMsgPing(nonce)
-	_, err := server.ProcessForRPC(pingCmd)
-	if err != nil {
-		return nil, btcjson.RPCError{
-			Code:    btcjson.RPCInternalError,
-			Message: "Can not acquire connection count",
-		}
-	}
-
-	return nil, nil
+	return server.ProcessForRPC(cmd)
 }
 
 func handleGetPeerInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -80,6 +67,7 @@ func handleGetPeerInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (i
 			LastRecv:        statsSnap.LastRecv.Unix(),
 			BytesSent:       statsSnap.BytesSent,
 			BytesRecv:       statsSnap.BytesRecv,
+			BytesSentCycle:  item.BytesSentInCycle(),
 			ConnTime:        statsSnap.ConnTime.Unix(),
 			TimeOffset:      statsSnap.TimeOffset,
 			PingTime:        float64(statsSnap.LastPingMicros),
@@ -9,10 +9,13 @@ import (
 	"gopkg.in/fatih/set.v0"
 	"math"
 	"strconv"
+	"strings"
 
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
 	"github.com/copernet/copernicus/errcode"
 	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lindex"
 	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/logic/lmerkleblock"
 	"github.com/copernet/copernicus/logic/ltx"
@@ -41,6 +44,7 @@ var rawTransactionHandlers = map[string]commandHandler{
 	"createrawtransaction": handleCreateRawTransaction, // complete
 	"decoderawtransaction": handleDecodeRawTransaction, // complete
 	"decodescript":         handleDecodeScript,         // complete
+	"analyzescript":        handleAnalyzeScript,        // complete
 	"sendrawtransaction":   handleSendRawTransaction,   // complete
 	"signrawtransaction":   handleSignRawTransaction,   // partial complete
 	"gettxoutproof":        handleGetTxoutProof,        // complete
@@ -51,7 +55,7 @@ func handleGetRawTransaction(s *Server, cmd interface{}, closeChan <-chan struct
 	c := cmd.(*btcjson.GetRawTransactionCmd)
 
 	// Convert the provided transaction hash hex to a Hash.
-	txHash, err := util.GetHashFromStr(c.Txid)
+	txHash, err := util.ParseHash(c.Txid)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.Txid)
 	}
@@ -61,8 +65,12 @@ func handleGetRawTransaction(s *Server, cmd interface{}, closeChan <-chan struct
 		verbose = *c.Verbose
 	}
 
-	tx, hashBlock, ok := GetTransaction(txHash, true)
+	tx, hashBlock, ok := GetTransaction(&txHash, true)
 	if !ok {
+		if txIndex := lindex.GetTxIndex(); txIndex != nil && !txIndex.IsSynced() {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey,
+				"No such mempool transaction. Blockchain transactions are still in the process of being indexed.")
+		}
 		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey,
 			"No such mempool or blockchain transaction. Use gettransaction for wallet transactions.")
 	}
@@ -81,9 +89,58 @@ func handleGetRawTransaction(s *Server, cmd interface{}, closeChan <-chan struct
 	if rpcErr != nil {
 		return nil, rpcErr
 	}
+	addPrevOutInfo(rawTxn, tx, fetchPrevOut)
 	return rawTxn, nil
 }
 
+// prevOutFetcher resolves the output spent by an input, it returns nil if the
+// spent coin can not be found.
+type prevOutFetcher func(out *outpoint.OutPoint) *txout.TxOut
+
+// fetchPrevOut looks the spent output up in the UTXO set first, then in the
+// mempool and the transaction index through GetTransaction.
+func fetchPrevOut(out *outpoint.OutPoint) *txout.TxOut {
+	if coin := utxo.GetUtxoCacheInstance().GetCoin(out); coin != nil && !coin.IsSpent() {
+		txOut := coin.GetTxOut()
+		return &txOut
+	}
+
+	prevTx, _, ok := GetTransaction(&out.Hash, false)
+	if !ok {
+		return nil
+	}
+	return prevTx.GetTxOut(int(out.Index))
+}
+
+// addPrevOutInfo fills in the outputs spent by the inputs of the transaction
+// and the fee it pays. Inputs whose prevout can not be resolved are left
+// without one, and the fee is only reported if all of them were resolved.
+func addPrevOutInfo(txReply *btcjson.TxRawResult, transaction *tx.Tx, fetch prevOutFetcher) {
+	if transaction.IsCoinBase() {
+		return
+	}
+
+	var valueIn amount.Amount
+	resolved := true
+	for i, in := range transaction.GetIns() {
+		prevOut := fetch(in.PreviousOutPoint)
+		if prevOut == nil {
+			resolved = false
+			continue
+		}
+		valueIn += prevOut.GetValue()
+		txReply.Vin[i].PrevOut = &btcjson.VinOut{
+			Value:        valueFromAmount(int64(prevOut.GetValue())),
+			ScriptPubKey: *ScriptPubKeyToJSON(prevOut.GetScriptPubKey(), true),
+		}
+	}
+
+	if resolved {
+		fee := valueFromAmount(int64(valueIn - transaction.GetValueOut()))
+		txReply.Fee = &fee
+	}
+}
+
 // getTxRawResult converts the passed transaction and associated parameters
 // to a raw transaction JSON object.
 func getTxRawResult(tx *tx.Tx, hashBlock *util.Hash, strHex string) (*btcjson.TxRawResult, *btcjson.RPCError) {
@@ -264,10 +321,19 @@ func GetTransaction(hash *util.Hash, allowSlow bool) (*tx.Tx, *util.Hash, bool)
 		return entry.Tx, nil, true
 	}
 
-	/* TODO: NOT support txindex yet
-	if chain.GTxIndex {
-		chain.GBlockTree.ReadTxIndex(hash)
-	}*/
+	if txIndex := lindex.GetTxIndex(); txIndex != nil && txIndex.IsSynced() {
+		pos, err := txIndex.FindTx(hash)
+		if err == nil && pos != nil {
+			txn, hashBlock, err := lindex.ReadTx(pos)
+			if err != nil {
+				log.Error("GetTransaction: read tx %s from txindex failed: %v", hash, err)
+			} else if txn.GetHash() != *hash {
+				log.Error("GetTransaction: txindex entry of %s points to tx %s", hash, txn.GetHash())
+			} else {
+				return txn, hashBlock, true
+			}
+		}
+	}
 
 	if !allowSlow {
 		return nil, nil, false
@@ -329,7 +395,7 @@ func handleCreateRawTransaction(s *Server, cmd interface{}, closeChan <-chan str
 }
 
 func createRawTxInput(input *btcjson.TransactionInput, lockTime uint32) (*txin.TxIn, *btcjson.RPCError) {
-	hash, err := util.GetHashFromStr(input.Txid)
+	hash, err := util.ParseHash(input.Txid)
 	if err != nil {
 		return nil, rpcDecodeHexError(input.Txid)
 	}
@@ -350,7 +416,7 @@ func createRawTxInput(input *btcjson.TransactionInput, lockTime uint32) (*txin.T
 		sequence = math.MaxUint32 - 1
 	}
 
-	txIn := txin.NewTxIn(outpoint.NewOutPoint(*hash, input.Vout), script.NewEmptyScript(), sequence)
+	txIn := txin.NewTxIn(outpoint.NewOutPoint(hash, input.Vout), script.NewEmptyScript(), sequence)
 	return txIn, nil
 }
 
@@ -384,22 +450,29 @@ func createRawTxOutput(address string, cost btcjson.AmountType) (*txout.TxOut, *
 }
 
 func amountFromValue(amountParam btcjson.AmountType) (amount.Amount, *btcjson.RPCError) {
-	amountVal, ok := amountParam.(float64)
-	if !ok {
-		amountValStr, ok := amountParam.(string)
-		if !ok {
-			return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Amount is not a number or string")
-		}
-		var err error
-		if amountVal, err = strconv.ParseFloat(amountValStr, 64); err != nil {
-			return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Invalid amount")
-		}
+	var amountStr string
+	switch v := amountParam.(type) {
+	case float64:
+		// The shortest representation of the number, without an exponent,
+		// holds exactly the digits of the JSON number.
+		amountStr = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		amountStr = v
+	default:
+		return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Amount is not a number or string")
 	}
-	amt, err := amount.NewAmount(amountVal)
-	if err != nil || !amount.MoneyRange(amt) {
+	if strings.HasPrefix(amountStr, "-") {
 		return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Amount out of range")
 	}
-	return amt, nil
+	amt, err := amount.ParseAmount(amountStr)
+	switch err {
+	case nil:
+		return amt, nil
+	case amount.ErrAmountOutOfRange:
+		return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Amount out of range")
+	default:
+		return 0, btcjson.NewRPCError(btcjson.ErrRPCType, "Invalid amount")
+	}
 }
 
 func decodeAddress(address string) (cashaddr.AddressType, []byte, *btcjson.RPCError) {
@@ -488,10 +561,11 @@ func handleDecodeRawTransaction(s *Server, cmd interface{}, closeChan <-chan str
 
 	transaction := tx.NewEmptyTx()
 
-	// Unserialize the transaction.
+	// Unserialize the transaction. It may come from createrawtransaction
+	// without inputs yet.
 	serializedTx, err := hex.DecodeString(c.HexTx)
 	if err == nil {
-		err = transaction.Unserialize(bytes.NewReader(serializedTx))
+		err = transaction.UnserializeUnfunded(bytes.NewReader(serializedTx))
 	}
 	if err != nil || int(transaction.SerializeSize()) != len(serializedTx) {
 		return nil, btcjson.NewRPCError(btcjson.ErrRPCDeserialization, "TX decode failed")
@@ -537,8 +611,84 @@ func handleDecodeScript(s *Server, cmd interface{}, closeChan <-chan struct{}) (
 	return ret, nil
 }
 
+// handleAnalyzeScript reports the type, counts and limit violations of a
+// script without executing it. Limits which depend on execution, like the
+// stack size, are not reported.
+func handleAnalyzeScript(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AnalyzeScriptCmd)
+
+	scriptByte, err := hex.DecodeString(c.HexScript)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.HexScript)
+	}
+	st := script.NewScriptRaw(scriptByte)
+
+	// The script could at the earliest be spent in the next block, so the
+	// flags are the ones of the block on top of the tip, as in mempool
+	// acceptance. GetBlockScriptFlags(tip) computes them from the height and
+	// median time past of the tip.
+	flags := uint32(script.ScriptVerifyNone)
+	if tip := chain.GetInstance().Tip(); tip != nil {
+		flags = chain.GetInstance().GetBlockScriptFlags(tip)
+	}
+
+	ret := &btcjson.AnalyzeScriptResult{
+		Size:       st.Size(),
+		SigOpCount: st.GetSigOpCount(flags, true),
+		PushOnly:   st.IsPushOnly(),
+		Violations: make([]string, 0),
+	}
+	t, addresses, required, err := st.ExtractDestinations()
+	ret.Type = GetTxnOutputType(t)
+	if err == nil {
+		ret.ReqSigs = int32(required)
+		ret.Keys = len(addresses)
+	}
+
+	violations := make(map[errcode.ScriptErr]bool)
+	if st.GetBadOpCode() {
+		violations[errcode.ScriptErrBadOpCode] = true
+	}
+	if st.Size() > script.MaxScriptSize {
+		violations[errcode.ScriptErrScriptSize] = true
+	}
+	for _, e := range st.ParsedOpCodes {
+		if len(e.Data) > ret.MaxElementSize {
+			ret.MaxElementSize = len(e.Data)
+		}
+		// Note how OP_RESERVED does not count towards the opcode limit.
+		if e.OpValue > opcodes.OP_16 {
+			ret.OpCount++
+		}
+		if script.IsOpCodeDisabled(e.OpValue, flags) {
+			violations[errcode.ScriptErrDisabledOpCode] = true
+		}
+	}
+	if ret.MaxElementSize > script.MaxScriptElementSize {
+		violations[errcode.ScriptErrPushSize] = true
+	}
+	if ret.OpCount > script.MaxOpsPerScript {
+		violations[errcode.ScriptErrOpCount] = true
+	}
+	for _, e := range []errcode.ScriptErr{errcode.ScriptErrBadOpCode, errcode.ScriptErrScriptSize,
+		errcode.ScriptErrPushSize, errcode.ScriptErrOpCount, errcode.ScriptErrDisabledOpCode} {
+		if violations[e] {
+			ret.Violations = append(ret.Violations, e.String())
+		}
+	}
+
+	return ret, nil
+}
+
+// mempoolNotLoadedRPCError is returned by the RPCs submitting transactions
+// until the mempool is loaded.
+var mempoolNotLoadedRPCError = btcjson.NewRPCError(btcjson.RPCMiscError, "mempool not fully loaded")
+
 func handleSendRawTransaction(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SendRawTransactionCmd)
+	if !mempool.GetInstance().IsLoaded() {
+		return nil, mempoolNotLoadedRPCError
+	}
 
 	b, _ := hex.DecodeString(c.HexTx)
 	buf := bytes.NewBuffer(b)
@@ -546,16 +696,19 @@ func handleSendRawTransaction(s *Server, cmd interface{}, closeChan <-chan struc
 	txn := tx.Tx{}
 	err := txn.Unserialize(buf)
 	if err != nil {
+		// Tell why well formed hex, like a segwit transaction, is refused.
+		if _, reason, ok := errcode.IsRejectCode(err); ok {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCDeserialization, "TX decode failed: "+reason)
+		}
 		return nil, rpcDecodeHexError(c.HexTx)
 	}
 
 	hash := txn.GetHash()
 
-	// NOT support high fee limit yet
-	//maxRawTxFee := mining.MaxTxFee
-	//if c.AllowHighFees != nil && *c.AllowHighFees {
-	//	maxRawTxFee = 0
-	//}
+	maxRawTxFee := amount.Amount(conf.Cfg.Mempool.MaxTxFee)
+	if c.AllowHighFees != nil && *c.AllowHighFees {
+		maxRawTxFee = 0
+	}
 
 	view := utxo.GetUtxoCacheInstance()
 	var inChain bool
@@ -567,7 +720,7 @@ func handleSendRawTransaction(s *Server, cmd interface{}, closeChan <-chan struc
 	entry := mempool.GetInstance().FindTx(hash)
 
 	if entry == nil && !inChain {
-		err = lmempool.AcceptTxToMemPool(&txn)
+		err = lmempool.AcceptTxToMemPoolWithMaxFee(&txn, maxRawTxFee)
 		if err != nil {
 			return nil, rpcErrorOfAcceptTx(err)
 		}
@@ -587,18 +740,8 @@ func handleSendRawTransaction(s *Server, cmd interface{}, closeChan <-chan struc
 }
 
 func rpcErrorOfAcceptTx(err error) *btcjson.RPCError {
-	missingInputs := errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut)
-	if missingInputs {
-		return btcjson.NewRPCError(btcjson.RPCTransactionError, "Missing inputs")
-	}
-
-	_, _, isReject := errcode.IsRejectCode(err)
-	if isReject {
-		return btcjson.NewRPCError(btcjson.RPCTransactionRejected, err.Error())
-
-	}
-
-	return btcjson.NewRPCError(btcjson.ErrUnDefined, err.Error())
+	code, message := errcode.ToRPCError(err)
+	return btcjson.NewRPCError(btcjson.RPCErrorCode(code), message)
 }
 
 var mapSigHashValues = map[string]int{
@@ -709,14 +852,14 @@ func getCoins(txIns []*txin.TxIn, prevTxs *[]btcjson.RawTxInput) (*utxo.CoinsMap
 	}
 	redeemScripts := make(map[outpoint.OutPoint]*script.Script)
 	for _, prevTx := range *prevTxs {
-		hash, err := util.GetHashFromStr(prevTx.Txid)
+		hash, err := util.ParseHash(prevTx.Txid)
 		if err != nil {
 			return nil, nil, rpcDecodeHexError(prevTx.Txid)
 		}
 		if prevTx.Vout < 0 {
 			return nil, nil, btcjson.NewRPCError(btcjson.RPCDeserializationError, "vout must be positive")
 		}
-		out := outpoint.NewOutPoint(*hash, prevTx.Vout)
+		out := outpoint.NewOutPoint(hash, prevTx.Vout)
 
 		scriptPubKeyBuf, err := hex.DecodeString(prevTx.ScriptPubKey)
 		if err != nil {
@@ -823,27 +966,27 @@ func handleGetTxoutProof(s *Server, cmd interface{}, closeChan <-chan struct{})
 	txIds := c.TxIDs
 
 	for _, txID := range txIds {
-		hash, err := util.GetHashFromStr(txID)
-		if len(txID) != 64 || err != nil {
+		hash, err := util.ParseHash(txID)
+		if err != nil {
 			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
 				"Invalid txid "+txID)
 		}
-		if setTxIds.Has(*hash) {
+		if setTxIds.Has(hash) {
 			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
 				"Invalid parameter, duplicated txid: "+txID)
 		}
-		setTxIds.Add(*hash)
-		oneTxID = *hash
+		setTxIds.Add(hash)
+		oneTxID = hash
 	}
 
 	var bindex *blockindex.BlockIndex
 	var hashBlock *util.Hash
 	if c.BlockHash != nil {
-		var err error
-		hashBlock, err = util.GetHashFromStr(*c.BlockHash)
+		blockHash, err := util.ParseHash(*c.BlockHash)
 		if err != nil {
 			return nil, rpcDecodeHexError(*c.BlockHash)
 		}
+		hashBlock = &blockHash
 
 		bindex = chain.GetInstance().FindBlockIndex(*hashBlock)
 		if bindex == nil {
@@ -0,0 +1,304 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/errcode"
+	"github.com/copernet/copernicus/logic/lindex"
+	"github.com/copernet/copernicus/logic/lmempool"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/rpc/btcjson"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+func TestAddPrevOutInfo(t *testing.T) {
+	prevTx := tx.NewTx(0, tx.DefaultVersion)
+	prevTx.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(util.Hash{1}, 0), script.NewEmptyScript(), script.SequenceFinal))
+	prevTx.AddTxOut(txout.NewTxOut(amount.Amount(5*util.COIN), script.NewScriptRaw([]byte{0x51})))
+	prevTx.AddTxOut(txout.NewTxOut(amount.Amount(3*util.COIN), script.NewScriptRaw([]byte{0x52})))
+	prevHash := prevTx.GetHash()
+
+	transaction := tx.NewTx(0, tx.DefaultVersion)
+	transaction.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(prevHash, 0), script.NewEmptyScript(), script.SequenceFinal))
+	transaction.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(prevHash, 1), script.NewEmptyScript(), script.SequenceFinal))
+	transaction.AddTxOut(txout.NewTxOut(amount.Amount(7*util.COIN+util.COIN/2), script.NewScriptRaw([]byte{0x53})))
+
+	// the confirmed parent is served the way the transaction index would
+	confirmed := func(out *outpoint.OutPoint) *txout.TxOut {
+		if out.Hash != prevHash {
+			return nil
+		}
+		return prevTx.GetTxOut(int(out.Index))
+	}
+
+	txReply := &btcjson.TxRawResult{Vin: getVinList(transaction)}
+	addPrevOutInfo(txReply, transaction, confirmed)
+
+	if txReply.Fee == nil {
+		t.Fatalf("fee should be reported when all prevouts are resolved")
+	}
+	if *txReply.Fee != 0.5 {
+		t.Errorf("fee should equal inputs minus outputs, got %v", *txReply.Fee)
+	}
+	if txReply.Vin[0].PrevOut == nil || txReply.Vin[0].PrevOut.Value != 5 {
+		t.Errorf("unexpected prevout of first input: %+v", txReply.Vin[0].PrevOut)
+	}
+	if txReply.Vin[1].PrevOut == nil || txReply.Vin[1].PrevOut.ScriptPubKey.Hex != "52" {
+		t.Errorf("unexpected prevout of second input: %+v", txReply.Vin[1].PrevOut)
+	}
+
+	// an unresolved prevout is left out along with the fee
+	partial := func(out *outpoint.OutPoint) *txout.TxOut {
+		if out.Index == 1 {
+			return nil
+		}
+		return confirmed(out)
+	}
+	txReply = &btcjson.TxRawResult{Vin: getVinList(transaction)}
+	addPrevOutInfo(txReply, transaction, partial)
+
+	if txReply.Fee != nil {
+		t.Errorf("fee should be omitted when a prevout is not resolved")
+	}
+	if txReply.Vin[0].PrevOut == nil {
+		t.Errorf("resolved prevout should be reported")
+	}
+	if txReply.Vin[1].PrevOut != nil {
+		t.Errorf("unresolved prevout should be absent")
+	}
+}
+
+func TestAddPrevOutInfoThroughTxIndex(t *testing.T) {
+	defer initTestChain(t)()
+	conf.Cfg.Chain.TxIndex = true
+	lindex.InitTxIndex()
+	defer lindex.StopTxIndex()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 101)
+
+	// parent is confirmed and its only spendable output is spent, it is
+	// neither in the UTXO set nor in the mempool anymore
+	parent := newSpendingTx(10000, coinbaseOut(t, 1))
+	if err := lmempool.AcceptTxToMemPool(parent); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	child := newSpendingTx(20000, outpoint.NewOutPoint(parent.GetHash(), 0))
+	if err := lmempool.AcceptTxToMemPool(child); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	mineBlocks(t, opTrue, 1)
+
+	txIndex := lindex.GetTxIndex()
+	for deadline := time.Now().Add(10 * time.Second); !txIndex.IsSynced(); {
+		if time.Now().After(deadline) {
+			t.Fatal("txindex not synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	prevOut := fetchPrevOut(outpoint.NewOutPoint(parent.GetHash(), 0))
+	if prevOut == nil || prevOut.GetValue() != parent.GetTxOut(0).GetValue() {
+		t.Fatalf("unexpected prevout from the txindex: %+v", prevOut)
+	}
+	if utxo.GetUtxoCacheInstance().GetCoin(outpoint.NewOutPoint(parent.GetHash(), 0)) != nil {
+		t.Fatalf("spent prevout should not be in the UTXO set")
+	}
+	if fetchPrevOut(outpoint.NewOutPoint(parent.GetHash(), 2)) != nil {
+		t.Errorf("out of range prevout should not be resolved")
+	}
+
+	txReply := &btcjson.TxRawResult{Vin: getVinList(child)}
+	addPrevOutInfo(txReply, child, fetchPrevOut)
+	if txReply.Fee == nil || *txReply.Fee != valueFromAmount(20000) {
+		t.Errorf("fee should be resolved through the txindex, got %v", txReply.Fee)
+	}
+	if txReply.Vin[0].PrevOut == nil || txReply.Vin[0].PrevOut.ScriptPubKey.Hex != hex.EncodeToString(parent.GetTxOut(0).GetScriptPubKey().GetData()) {
+		t.Errorf("unexpected prevout of the input: %+v", txReply.Vin[0].PrevOut)
+	}
+}
+
+func TestAmountFromValue(t *testing.T) {
+	tests := []struct {
+		value    btcjson.AmountType
+		expected amount.Amount
+		errMsg   string
+	}{
+		{0.00000001, 1, ""},
+		{0.1, 10000000, ""},
+		{21000000.0, amount.Amount(util.MaxMoney), ""},
+		{"0.5", 50000000, ""},
+		{21000000.00000001, 0, "Amount out of range"},
+		{-1.0, 0, "Amount out of range"},
+		{0.000000001, 0, "Invalid amount"},
+		{"1.234e5", 0, "Invalid amount"},
+		{true, 0, "Amount is not a number or string"},
+	}
+
+	for _, test := range tests {
+		amt, rpcErr := amountFromValue(test.value)
+		if test.errMsg != "" {
+			if rpcErr == nil || rpcErr.Message != test.errMsg {
+				t.Errorf("amountFromValue(%v) error %v, expected %q", test.value, rpcErr, test.errMsg)
+			}
+			continue
+		}
+		if rpcErr != nil {
+			t.Errorf("amountFromValue(%v) unexpected error %v", test.value, rpcErr)
+		} else if amt != test.expected {
+			t.Errorf("amountFromValue(%v) = %d, expected %d", test.value, int64(amt), int64(test.expected))
+		}
+	}
+}
+
+func TestDecodeRawTransactionUnfunded(t *testing.T) {
+	outputs := map[string]btcjson.AmountType{"data": "00ff"}
+	created, err := handleCreateRawTransaction(nil, btcjson.NewCreateRawTransactionCmd(nil, outputs, nil), nil)
+	if err != nil {
+		t.Fatalf("createrawtransaction failed: %v", err)
+	}
+
+	// the transaction without inputs and its single output reads like the
+	// segwit marker and flag
+	decoded, err := handleDecodeRawTransaction(nil, &btcjson.DecodeRawTransactionCmd{HexTx: created.(string)}, nil)
+	if err != nil {
+		t.Fatalf("decoderawtransaction failed: %v", err)
+	}
+	result := decoded.(*btcjson.TxRawDecodeResult)
+	if len(result.Vin) != 0 || len(result.Vout) != 1 {
+		t.Errorf("decoded %d inputs and %d outputs, want 0 and 1", len(result.Vin), len(result.Vout))
+	}
+}
+
+func TestSendRawTransactionSegwit(t *testing.T) {
+	defer initTestChain(t)()
+
+	// a transaction in the segwit serialization, with the 0x00 marker and
+	// 0x01 flag after the version
+	segwitTx := "0200000000010111111111111111111111111111111111111111111111111111111111111111110000000000ffffffff01e8030000000000001600142222222222222222222222222222222222222222012102333333333333333333333333333333333333333333333333333333333333333300000000"
+	cmd := btcjson.NewSendRawTransactionCmd(segwitTx, nil)
+	_, err := handleSendRawTransaction(nil, cmd, nil)
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		t.Fatalf("sendrawtransaction returned %v, want an RPC error", err)
+	}
+	if rpcErr.Code != btcjson.ErrRPCDeserialization || rpcErr.Message != "TX decode failed: unsupported-format" {
+		t.Errorf("sendrawtransaction returned %v, want the unsupported-format decode error", rpcErr)
+	}
+	if mempool.GetInstance().Size() != 0 {
+		t.Error("segwit transaction added to the mempool")
+	}
+}
+
+func TestSendRawTransactionBeforeMempoolLoaded(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 101)
+
+	pool := mempool.GetInstance()
+	if !pool.IsLoaded() {
+		t.Fatal("mempool not loaded after init")
+	}
+
+	// The mempool is being loaded.
+	pool.SetLoaded(false)
+	defer pool.SetLoaded(true)
+
+	info, err := handleGetMempoolInfo(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("getmempoolinfo failed: %v", err)
+	}
+	if info.(*btcjson.GetMempoolInfoResult).Loaded {
+		t.Error("getmempoolinfo reports the mempool loaded")
+	}
+
+	txn := newSpendingTx(10000, coinbaseOut(t, 1))
+	buf := bytes.NewBuffer(nil)
+	if err := txn.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	cmd := btcjson.NewSendRawTransactionCmd(hex.EncodeToString(buf.Bytes()), nil)
+	if _, err := handleSendRawTransaction(nil, cmd, nil); err != mempoolNotLoadedRPCError {
+		t.Errorf("sendrawtransaction returned %v, want %v", err, mempoolNotLoadedRPCError)
+	}
+	if pool.IsTransactionInPool(txn) {
+		t.Error("transaction submitted before the mempool was loaded")
+	}
+
+	pool.SetLoaded(true)
+	info, err = handleGetMempoolInfo(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("getmempoolinfo failed: %v", err)
+	}
+	if !info.(*btcjson.GetMempoolInfoResult).Loaded {
+		t.Error("getmempoolinfo reports the mempool not loaded")
+	}
+}
+
+func TestAnalyzeScript(t *testing.T) {
+	defer initTestChain(t)()
+
+	redeemScript := script.NewEmptyScript()
+	redeemScript.PushOpCode(opcodes.OP_2)
+	for i := 1; i <= 3; i++ {
+		key := crypto.PrivateKeyFromBytes(bytes.Repeat([]byte{byte(i)}, 32))
+		redeemScript.PushSingleData(key.PubKey().ToBytes())
+	}
+	redeemScript.PushOpCode(opcodes.OP_3)
+	redeemScript.PushOpCode(opcodes.OP_CHECKMULTISIG)
+
+	cmd := btcjson.NewAnalyzeScriptCmd(hex.EncodeToString(redeemScript.GetData()))
+	result, err := handleAnalyzeScript(nil, cmd, nil)
+	if err != nil {
+		t.Fatalf("analyzescript failed: %v", err)
+	}
+	ret := result.(*btcjson.AnalyzeScriptResult)
+	if ret.Type != "multisig" || ret.SigOpCount != 3 || ret.Keys != 3 || ret.ReqSigs != 2 {
+		t.Errorf("got type %s, %d sigops, %d keys, %d required sigs, want multisig, 3 sigops, 3 keys, 2 required sigs",
+			ret.Type, ret.SigOpCount, ret.Keys, ret.ReqSigs)
+	}
+	if ret.OpCount != 1 || ret.MaxElementSize != 65 || ret.PushOnly || len(ret.Violations) != 0 {
+		t.Errorf("got %d ops, max element %d, push only %v, violations %v", ret.OpCount, ret.MaxElementSize,
+			ret.PushOnly, ret.Violations)
+	}
+
+	// A script over the push size, the opcode count and using a disabled
+	// opcode is reported, not executed.
+	s := script.NewEmptyScript()
+	s.PushSingleData(bytes.Repeat([]byte{0x01}, script.MaxScriptElementSize+1))
+	for i := 0; i < script.MaxOpsPerScript; i++ {
+		s.PushOpCode(opcodes.OP_NOP)
+	}
+	s.PushOpCode(opcodes.OP_MUL)
+	result, err = handleAnalyzeScript(nil, btcjson.NewAnalyzeScriptCmd(hex.EncodeToString(s.GetData())), nil)
+	if err != nil {
+		t.Fatalf("analyzescript failed: %v", err)
+	}
+	ret = result.(*btcjson.AnalyzeScriptResult)
+	want := []string{errcode.ScriptErrPushSize.String(), errcode.ScriptErrOpCount.String(),
+		errcode.ScriptErrDisabledOpCode.String()}
+	if !reflect.DeepEqual(ret.Violations, want) {
+		t.Errorf("got violations %v, want %v", ret.Violations, want)
+	}
+
+	if _, err := handleAnalyzeScript(nil, btcjson.NewAnalyzeScriptCmd("zz"), nil); err == nil {
+		t.Error("analyzescript accepted invalid hex")
+	}
+}
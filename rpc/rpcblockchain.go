@@ -5,14 +5,17 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lindex"
 	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
@@ -21,6 +24,7 @@ import (
 	"github.com/copernet/copernicus/model/consensus"
 	"github.com/copernet/copernicus/model/mempool"
 	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/model/versionbits"
@@ -28,6 +32,7 @@ import (
 	"github.com/copernet/copernicus/persist/disk"
 	"github.com/copernet/copernicus/rpc/btcjson"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/descriptor"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -38,6 +43,7 @@ var blockchainHandlers = map[string]commandHandler{
 	"getblock":              handleGetBlock,              // complete
 	"getblockhash":          handleGetBlockHash,          // complete
 	"getblockheader":        handleGetBlockHeader,        // complete
+	"getblockfilter":        handleGetBlockFilter,        // complete
 	"getchaintips":          handleGetChainTips,          // partial complete
 	"getdifficulty":         handleGetDifficulty,         //complete
 	"getchaintxstats":       handleGetChainTxStats,       // complete
@@ -46,9 +52,16 @@ var blockchainHandlers = map[string]commandHandler{
 	"getmempoolentry":       handleGetMempoolEntry,       // complete
 	"getmempoolinfo":        handleGetMempoolInfo,        // complete
 	"getrawmempool":         handleGetRawMempool,         // complete
+	"gettransactionstatus":  handleGetTransactionStatus,  // complete
 	"gettxout":              handleGetTxOut,              // complete
 	"gettxoutsetinfo":       handleGetTxoutSetInfo,
+	"getblocklocation":      handleGetBlockLocation,
+	"getblockhashbytime":    handleGetBlockHashByTime,
+	"scantxoutset":          handleScanTxOutSet,
+	"dumpblockfile":         handleDumpBlockFile,
 	"pruneblockchain":       handlePruneBlockChain, //complete
+	"savemempool":           handleSaveMempool,
+	"setmempoollimit":       handleSetMempoolLimit, //complete
 	"verifychain":           handleVerifyChain,     //complete
 	"preciousblock":         handlePreciousblock,   //complete
 
@@ -191,12 +204,12 @@ func handleGetBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (inte
 	c := cmd.(*btcjson.GetBlockCmd)
 
 	// Load the raw block bytes from the database.
-	hash, err := util.GetHashFromStr(c.Hash)
+	hash, err := util.ParseHash(c.Hash)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.Hash)
 	}
 
-	blockIndex := chain.GetInstance().FindBlockIndex(*hash)
+	blockIndex := chain.GetInstance().FindBlockIndex(hash)
 	if blockIndex == nil {
 		return false, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCInvalidAddressOrKey,
@@ -232,6 +245,63 @@ func handleGetBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (inte
 	return blockReply, nil
 }
 
+// handleGetBlockLocation implements the getblocklocation command.
+func handleGetBlockLocation(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockLocationCmd)
+
+	hash, err := util.ParseHash(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	blockIndex := chain.GetInstance().FindBlockIndex(hash)
+	if blockIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Block not found",
+		}
+	}
+	if !blockIndex.HasData() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block not available (no data)",
+		}
+	}
+
+	// The block is stored behind its length.
+	pos := blockIndex.GetBlockPos()
+	length, err := disk.ReadBlockSize(pos)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block not found on disk",
+		}
+	}
+	return &btcjson.GetBlockLocationResult{
+		File:   pos.File,
+		Offset: pos.Pos + 4,
+		Length: length,
+	}, nil
+}
+
+// handleDumpBlockFile implements the dumpblockfile command.
+func handleDumpBlockFile(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DumpBlockFileCmd)
+
+	info := disk.GetBlockFileInfo(c.File)
+	if info == nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Block file not found")
+	}
+	return &btcjson.DumpBlockFileResult{
+		Blocks:      info.Blocks,
+		Size:        info.Size,
+		UndoSize:    info.UndoSize,
+		HeightFirst: info.HeightFirst,
+		HeightLast:  info.HeightLast,
+		TimeFirst:   info.GetTimeFirst(),
+		TimeLast:    info.GetTimeLast(),
+	}, nil
+}
+
 func blockToJSON(blk *block.Block, blockIndex *blockindex.BlockIndex) *btcjson.GetBlockVerboseResult {
 	confirmations := int32(-1)
 	// Only report confirmations if the block is on the main chain
@@ -290,18 +360,36 @@ func handleGetBlockHash(s *Server, cmd interface{}, closeChan <-chan struct{}) (
 	return blockIndex.GetBlockHash().String(), nil
 }
 
+// handleGetBlockHashByTime implements the getblockhashbytime command. It
+// returns the last block of the active chain whose median time past is at
+// most the timestamp, as the median time past never decreases.
+func handleGetBlockHashByTime(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHashByTimeCmd)
+
+	gChain := chain.GetInstance()
+	blockIndex := gChain.FindLatestMedianTimeAtMost(c.Timestamp)
+	if blockIndex == nil {
+		// the timestamp is before the genesis block
+		blockIndex = gChain.Genesis()
+	}
+	return &btcjson.GetBlockHashByTimeResult{
+		Hash:   blockIndex.GetBlockHash().String(),
+		Height: blockIndex.Height,
+	}, nil
+}
+
 func handleGetBlockHeader(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHeaderCmd)
 
 	// Fetch the header from chain.
-	hash, err := util.GetHashFromStr(c.Hash)
+	hash, err := util.ParseHash(c.Hash)
 	if err != nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
 			Message: "Block not found",
 		}
 	}
-	blockIndex := chain.GetInstance().FindBlockIndex(*hash)
+	blockIndex := chain.GetInstance().FindBlockIndex(hash)
 
 	if blockIndex == nil {
 		return nil, &btcjson.RPCError{
@@ -357,6 +445,43 @@ func handleGetBlockHeader(s *Server, cmd interface{}, closeChan <-chan struct{})
 	return blockHeaderReply, nil
 }
 
+func handleGetBlockFilter(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockFilterCmd)
+
+	if c.FilterType != nil && *c.FilterType != "basic" {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "Unknown filtertype")
+	}
+	filterIndex := lindex.GetBlockFilterIndex()
+	if filterIndex == nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Index is not enabled for filtertype basic")
+	}
+
+	hash, err := util.ParseHash(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if chain.GetInstance().FindBlockIndex(hash) == nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "Block not found")
+	}
+
+	filter, header, err := filterIndex.LookupFilter(&hash)
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to read the block filter")
+	}
+	if header == nil {
+		if !filterIndex.IsSynced() {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc,
+				"Filter not found. Block filters are still in the process of being indexed.")
+		}
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Filter not found.")
+	}
+
+	return &btcjson.GetBlockFilterResult{
+		Filter: hex.EncodeToString(filter),
+		Header: header.String(),
+	}, nil
+}
+
 func handleGetChainTips(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Idea:  the set of chain tips is chainActive.tip, plus orphan blocks which
 	// do not have another orphan building off of them.
@@ -464,11 +589,11 @@ func handleGetChainTxStats(s *Server, cmd interface{}, closeChan <-chan struct{}
 	gChan := chain.GetInstance()
 	blockIndex := gChan.Tip()
 	if c.BlockHash != nil {
-		blockHash, err := util.GetHashFromStr(*c.BlockHash)
+		blockHash, err := util.ParseHash(*c.BlockHash)
 		if err != nil {
 			return nil, rpcDecodeHexError(*c.BlockHash)
 		}
-		blockIndex = gChan.FindBlockIndex(*blockHash)
+		blockIndex = gChan.FindBlockIndex(blockHash)
 		if blockIndex == nil {
 			return false, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCBlockNotFound,
@@ -500,9 +625,11 @@ func handleGetChainTxStats(s *Server, cmd interface{}, closeChan <-chan struct{}
 	}
 
 	chainTxStatsReply := &btcjson.GetChainTxStatsResult{
-		FinalTime:  blockIndex.GetBlockTime(),
-		TxCount:    blockIndex.ChainTxCount,
-		BlockCount: blockCount,
+		FinalTime:        blockIndex.GetBlockTime(),
+		TxCount:          blockIndex.ChainTxCount,
+		FinalBlockHash:   blockIndex.GetBlockHash().String(),
+		FinalBlockHeight: blockIndex.Height,
+		BlockCount:       blockCount,
 	}
 	if blockCount > 0 {
 		indexPast := blockIndex.GetAncestor(blockIndex.Height - blockCount)
@@ -520,14 +647,14 @@ func handleGetChainTxStats(s *Server, cmd interface{}, closeChan <-chan struct{}
 
 func handleGetMempoolAncestors(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
-	hash, err := util.GetHashFromStr(c.TxID)
+	hash, err := util.ParseHash(c.TxID)
 	if err != nil {
 		return nil, btcjson.RPCError{
 			Code:    btcjson.ErrRPCInvalidParameter,
 			Message: "the string " + c.TxID + " is not a standard hash",
 		}
 	}
-	entry := mempool.GetInstance().FindTx(*hash)
+	entry := mempool.GetInstance().FindTx(hash)
 	if entry == nil {
 		return nil, btcjson.RPCError{
 			Code:    btcjson.RPCInvalidAddressOrKey,
@@ -588,12 +715,12 @@ func entryToJSON(entry *mempool.TxEntry) *btcjson.GetMempoolEntryRelativeInfoVer
 func handleGetMempoolDescendants(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
 
-	hash, err := util.GetHashFromStr(c.TxID)
+	hash, err := util.ParseHash(c.TxID)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.TxID)
 	}
 
-	entry := mempool.GetInstance().FindTx(*hash)
+	entry := mempool.GetInstance().FindTx(hash)
 	if entry == nil {
 		return nil, btcjson.RPCError{
 			Code:    btcjson.RPCInvalidAddressOrKey,
@@ -601,7 +728,7 @@ func handleGetMempoolDescendants(s *Server, cmd interface{}, closeChan <-chan st
 		}
 	}
 
-	descendants := mempool.GetInstance().CalculateDescendantsWithLock(hash)
+	descendants := mempool.GetInstance().CalculateDescendantsWithLock(&hash)
 	// CTxMemPool::CalculateDescendants will include the given tx
 	delete(descendants, entry)
 
@@ -625,12 +752,12 @@ func handleGetMempoolDescendants(s *Server, cmd interface{}, closeChan <-chan st
 func handleGetMempoolEntry(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetMempoolEntryCmd)
 
-	hash, err := util.GetHashFromStr(c.TxID)
+	hash, err := util.ParseHash(c.TxID)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.TxID)
 	}
 
-	entry := mempool.GetInstance().FindTx(*hash)
+	entry := mempool.GetInstance().FindTx(hash)
 	if entry == nil {
 		return nil, btcjson.RPCError{
 			Code:    btcjson.ErrRPCInvalidAddressOrKey,
@@ -641,18 +768,154 @@ func handleGetMempoolEntry(s *Server, cmd interface{}, closeChan <-chan struct{}
 	return entryToJSON(entry), nil
 }
 
+// Transaction states reported by gettransactionstatus.
+const (
+	txStatusMempool    = "mempool"
+	txStatusConfirmed  = "confirmed"
+	txStatusConflicted = "conflicted"
+	txStatusUnknown    = "unknown"
+)
+
+// txStatusSource provides the lookups the state of a transaction is resolved from.
+type txStatusSource interface {
+	InMempool(hash *util.Hash) bool
+	// ConfirmedIn returns the active chain block containing the transaction.
+	ConfirmedIn(hash *util.Hash) *blockindex.BlockIndex
+	TipHeight() int32
+	// FindConflicted returns the transaction if it was evicted from the
+	// mempool for conflicting with a block.
+	FindConflicted(hash *util.Hash) *tx.Tx
+	// SpentByOther reports whether the outpoint is spent by a transaction
+	// other than spender, either in the mempool or in the chain.
+	SpentByOther(out *outpoint.OutPoint, spender *util.Hash) bool
+}
+
+func handleGetTransactionStatus(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTransactionStatusCmd)
+
+	hash, err := util.ParseHash(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	return getTransactionStatus(&hash, nodeTxStatusSource{}), nil
+}
+
+func getTransactionStatus(hash *util.Hash, src txStatusSource) *btcjson.GetTransactionStatusResult {
+	if src.InMempool(hash) {
+		return &btcjson.GetTransactionStatusResult{Status: txStatusMempool}
+	}
+
+	if index := src.ConfirmedIn(hash); index != nil {
+		return &btcjson.GetTransactionStatusResult{
+			Status:        txStatusConfirmed,
+			Height:        index.Height,
+			Confirmations: src.TipHeight() - index.Height + 1,
+		}
+	}
+
+	// A transaction evicted for a conflict may have become spendable again
+	// after a reorg, so only report it while one of its inputs is still
+	// spent by another transaction.
+	if conflictedTx := src.FindConflicted(hash); conflictedTx != nil {
+		for _, in := range conflictedTx.GetIns() {
+			if src.SpentByOther(in.PreviousOutPoint, hash) {
+				return &btcjson.GetTransactionStatusResult{Status: txStatusConflicted}
+			}
+		}
+	}
+
+	return &btcjson.GetTransactionStatusResult{Status: txStatusUnknown}
+}
+
+type nodeTxStatusSource struct{}
+
+func (nodeTxStatusSource) InMempool(hash *util.Hash) bool {
+	return mempool.GetInstance().FindTx(*hash) != nil
+}
+
+func (nodeTxStatusSource) ConfirmedIn(hash *util.Hash) *blockindex.BlockIndex {
+	_, hashBlock, ok := GetTransaction(hash, true)
+	if !ok || hashBlock == nil {
+		return nil
+	}
+	index := chain.GetInstance().FindBlockIndex(*hashBlock)
+	if index == nil || !chain.GetInstance().Contains(index) {
+		return nil
+	}
+	return index
+}
+
+func (nodeTxStatusSource) TipHeight() int32 {
+	return chain.GetInstance().Height()
+}
+
+func (nodeTxStatusSource) FindConflicted(hash *util.Hash) *tx.Tx {
+	return mempool.GetInstance().FindConflictedTx(*hash)
+}
+
+func (nodeTxStatusSource) SpentByOther(out *outpoint.OutPoint, spender *util.Hash) bool {
+	pool := mempool.GetInstance()
+	pool.RLock()
+	entry := pool.HasSPentOutWithoutLock(out)
+	inPool := pool.GetCoin(out) != nil
+	pool.RUnlock()
+	if entry != nil {
+		return entry.Tx.GetHash() != *spender
+	}
+	if inPool {
+		return false
+	}
+
+	coin := utxo.GetUtxoCacheInstance().GetCoin(out)
+	return coin == nil || coin.IsSpent()
+}
+
 func handleGetMempoolInfo(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	pool := mempool.GetInstance()
+	minFeeRate := pool.GetMinFeeRate()
 	ret := &btcjson.GetMempoolInfoResult{
 		Size:          pool.Size(),
 		Bytes:         pool.GetPoolAllTxSize(true),
 		Usage:         pool.GetPoolUsage(),
-		MaxMempool:    int(conf.Cfg.Mempool.MaxPoolSize),
-		MempoolMinFee: valueFromAmount(pool.GetMinFeeRate().SataoshisPerK),
+		MaxMempool:    int(conf.Cfg.MaxPoolSize()),
+		MempoolMinFee: valueFromAmount(int64(minFeeRate.GetFeePerK())),
+		Loaded:        pool.IsLoaded(),
+		Orphans:       pool.OrphanCount(),
+		OrphanBytes:   pool.OrphanBytes(),
+		MaxOrphanTx:   pool.MaxOrphanTx(),
 	}
 	return ret, nil
 }
 
+// handleSetMempoolLimit changes the number of orphan transactions kept,
+// evicting the orphans over the new limit.
+func handleSetMempoolLimit(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetMempoolLimitCmd)
+	if c.MaxOrphanTx < 0 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "maxorphantx must not be negative")
+	}
+	evicted := mempool.GetInstance().SetMaxOrphanTx(c.MaxOrphanTx)
+	if evicted > 0 {
+		log.Debug("setmempoollimit: removed %d orphan tx", evicted)
+	}
+	return nil, nil
+}
+
+// handleSaveMempool writes the mempool to mempool.dat in the data directory
+// and returns the path of the file.
+func handleSaveMempool(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	pool := mempool.GetInstance()
+	if !pool.IsLoaded() {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "The mempool was not loaded yet")
+	}
+	path := filepath.Join(conf.DataDir, mempool.DumpFileName)
+	if err := pool.Dump(path); err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Unable to dump mempool to disk: "+err.Error())
+	}
+	return path, nil
+}
+
 func valueFromAmount(sizeLimit int64) float64 {
 	var nAbs int64
 	var strFormat string
@@ -703,12 +966,12 @@ func handleGetTxOut(s *Server, cmd interface{}, closeChan <-chan struct{}) (inte
 	c := cmd.(*btcjson.GetTxOutCmd)
 
 	// Convert the provided transaction hash hex to a Hash.
-	hash, err := util.GetHashFromStr(c.Txid)
+	hash, err := util.ParseHash(c.Txid)
 	if err != nil {
 		return nil, rpcDecodeHexError(c.Txid)
 	}
 
-	outPoint := outpoint.NewOutPoint(*hash, c.Vout)
+	outPoint := outpoint.NewOutPoint(hash, c.Vout)
 	coinView := utxo.GetUtxoCacheInstance()
 
 	coin := coinView.GetCoin(outPoint)
@@ -782,85 +1045,191 @@ func handleGetTxoutSetInfo(s *Server, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
-func getPrunMode() (bool, error) {
-	/*	pruneArg := util.GetArg("-prune", 0)
-		if pruneArg < 0 {
-			return false, errors.New("Prune cannot be configured with a negative value")
-		}*/ // todo open
-	return true, nil
+// txOutSetScan is the state of the running scantxoutset, only one scan runs
+// at a time.
+var txOutSetScan struct {
+	running  int32
+	progress int32
+	abort    int32
 }
 
-func handlePruneBlockChain(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	/*	pruneMode, err := getPrunMode()
-
-		if err != nil && !pruneMode {
-			return false, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCType,
-				Message: fmt.Sprintf("Cannot prune blocks because node is not in prune mode."),
-			}
+// scanObjectScripts returns the scripts a scantxoutset scan object stands
+// for: an output descriptor or a plain address.
+func scanObjectScripts(object string) ([]*script.Script, *btcjson.RPCError) {
+	if !strings.Contains(object, "(") {
+		scriptPubKey, rpcErr := getStandardScriptPubKey(object, nil)
+		if rpcErr != nil {
+			return nil, rpcErr
 		}
+		return []*script.Script{scriptPubKey}, nil
+	}
+	desc, err := descriptor.Parse(object, false)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, err.Error())
+	}
+	return desc.ScriptPubKeys(), nil
+}
 
-		c := cmd.(*btcjson.PruneBlockChainCmd)
-		height := c.Height
-		if *height < 0 {
-			return false, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCType,
-				Message: fmt.Sprintf("Negative block height."),
-			}
+// handleScanTxOutSet implements the scantxoutset command. The start action
+// scans the UTXO set for the outputs paying to the scan objects, status
+// reports the progress of the running scan and abort stops it.
+func handleScanTxOutSet(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+
+	switch c.Action {
+	case "status":
+		if atomic.LoadInt32(&txOutSetScan.running) == 0 {
+			return nil, nil
 		}
+		return &btcjson.ScanTxOutSetStatusResult{Progress: atomic.LoadInt32(&txOutSetScan.progress)}, nil
+	case "abort":
+		if atomic.LoadInt32(&txOutSetScan.running) == 0 {
+			return false, nil
+		}
+		atomic.StoreInt32(&txOutSetScan.abort, 1)
+		return true, nil
+	case "start":
+	default:
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Invalid command")
+	}
 
-		if *height > 1000000000 {
-			index := chain.GetInstance().FindEarliestAtLeast(int64(*height - 72000))
-			if index != nil {
-				return false, &btcjson.RPCError{
-					Code:    btcjson.ErrRPCType,
-					Message: fmt.Sprintf("Could not find block with at least the specified timestamp."),
-				}
-			}
-			height = &index.Height
+	if c.ScanObjects == nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "scanobjects argument is required for the start action")
+	}
+	scripts := make(map[string]struct{})
+	for _, object := range *c.ScanObjects {
+		scriptPubKeys, rpcErr := scanObjectScripts(object)
+		if rpcErr != nil {
+			return nil, rpcErr
 		}
+		for _, scriptPubKey := range scriptPubKeys {
+			scripts[string(scriptPubKey.GetData())] = struct{}{}
+		}
+	}
 
-		h := *height
-		chainHeight := chain.GetInstance().Height()
-		if chainHeight < consensus.ActiveNetParams.PruneAfterHeight {
-			return false, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCMisc,
-				Message: fmt.Sprintf("Blockchain is too short for pruning."),
-			}
-		} else if h > chainHeight {
-			return false, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCInvalidParameter,
-				Message: fmt.Sprintf("Blockchain is shorter than the attempted prune height."),
-			}
-		} /*else if h > chainHeight - MIN_BLOCKS_TO_KEEP {
-			h = chainHeight - MIN_BLOCKS_TO_KEEP
+	if !atomic.CompareAndSwapInt32(&txOutSetScan.running, 0, 1) {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			"Scan already in progress, use action \"abort\" or \"status\"")
+	}
+	defer atomic.StoreInt32(&txOutSetScan.running, 0)
+	atomic.StoreInt32(&txOutSetScan.progress, 0)
+	atomic.StoreInt32(&txOutSetScan.abort, 0)
+
+	// Flush the coins and open the iterator under the lock, the scan then
+	// walks a snapshot of the coins database without holding it.
+	persist.CsMain.Lock()
+	mempoolUsage := mempool.GetInstance().GetPoolUsage()
+	mempoolSizeMax := int64(persist.DefaultMaxMemPoolSize) * 1000000
+	if err := disk.FlushStateToDisk(disk.FlushStateAlways, 0, mempoolUsage, mempoolSizeMax); err != nil {
+		persist.CsMain.Unlock()
+		return nil, err
+	}
+	cdb := utxo.GetUtxoCacheInstance().(*utxo.CoinsLruCache).GetCoinsDB()
+	iter := cdb.GetDBW().Iterator(nil)
+	persist.CsMain.Unlock()
+	defer iter.Close()
+
+	searched, coins, err := lchain.ScanUTXOSet(iter, scripts, &txOutSetScan.progress, &txOutSetScan.abort)
+	if err == lchain.ErrScanAborted {
+		return &btcjson.ScanTxOutSetResult{SearchedItems: searched, Unspents: []btcjson.ScanTxOutSetUnspent{}}, nil
+	}
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCDatabase, err.Error())
+	}
+
+	var total int64
+	unspents := make([]btcjson.ScanTxOutSetUnspent, 0, len(coins))
+	for _, coin := range coins {
+		value := int64(coin.Coin.GetAmount())
+		total += value
+		unspents = append(unspents, btcjson.ScanTxOutSetUnspent{
+			TxID:         coin.OutPoint.Hash.String(),
+			Vout:         coin.OutPoint.Index,
+			ScriptPubKey: hex.EncodeToString(coin.Coin.GetScriptPubKey().GetData()),
+			Amount:       valueFromAmount(value),
+			Height:       coin.Coin.GetHeight(),
+		})
+	}
+	return &btcjson.ScanTxOutSetResult{
+		Success:       true,
+		SearchedItems: searched,
+		Unspents:      unspents,
+		TotalAmount:   valueFromAmount(total),
+	}, nil
+}
+
+// timestampWindow is how much earlier than a block's time its timestamp may
+// be, pruneblockchain keeps the blocks this close to the requested time.
+const timestampWindow = 2 * 60 * 60
+
+// handlePruneBlockChain deletes the block files up to a height, or up to the
+// blocks older than a unix time, and returns the height of the last block
+// pruned.
+func handlePruneBlockChain(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if !disk.GetPruneState().PruneMode {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Cannot prune blocks because node is not in prune mode.")
+	}
+
+	c := cmd.(*btcjson.PruneBlockChainCmd)
+	if c.Height < 0 {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter, "Negative block height.")
+	}
+
+	persist.CsMain.Lock()
+	defer persist.CsMain.Unlock()
+
+	gChain := chain.GetInstance()
+	height := int32(c.Height)
+	// Heights above a billion are unix times, the blocks before the first
+	// one not older than the time are pruned.
+	if c.Height > 1000000000 {
+		index := gChain.FindEarliestAtLeast(int64(c.Height) - timestampWindow)
+		if index == nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCType,
+				"Could not find block with at least the specified timestamp.")
 		}
+		height = index.Height - 1
+	}
 
-		chain.PruneBlockFilesManual(*height)
-		return uint64(*height), nil*/ // todo realise
+	chainHeight := gChain.Height()
+	if int(chainHeight) < gChain.GetParams().PruneAfterHeight {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc, "Blockchain is too short for pruning.")
+	}
+	if height > chainHeight {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			"Blockchain is shorter than the attempted prune height.")
+	}
 
-	return nil, nil
+	lastPruned, err := lchain.PruneBlockFilesManual(height)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCDatabase, err.Error())
+	}
+	return lastPruned, nil
 }
 
 // handleVerifyChain implements the verifychain command.
 func handleVerifyChain(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	/*	c := cmd.(*btcjson.VerifyChainCmd)
+	c := cmd.(*btcjson.VerifyChainCmd)
 
-		var checkLevel, checkDepth int32
-		if c.CheckLevel != nil {
-			checkLevel = *c.CheckLevel
-		}
-		if c.CheckDepth != nil {
-			checkDepth = *c.CheckDepth
-		}
+	checkLevel := int32(3)
+	if c.CheckLevel != nil {
+		checkLevel = *c.CheckLevel
+	}
+	checkDepth := int32(6)
+	if c.CheckDepth != nil {
+		checkDepth = *c.CheckDepth
+	}
 
-		return VerifyDB(consensus.ActiveNetParams, utxo.GetUtxoCacheInstance(), checkLevel, checkDepth), nil*/ // todo open
-	return nil, nil
+	if err := lchain.VerifyDB(checkLevel, checkDepth); err != nil {
+		log.Error("verifychain: %v", err)
+		return false, nil
+	}
+	return true, nil
 }
 
 func handlePreciousblock(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	/*	c := cmd.(*btcjson.PreciousBlockCmd)
-		hash, err := util.GetHashFromStr(c.BlockHash)
+		hash, err := util.ParseHash(c.BlockHash)
 		if err != nil {
 			return nil, err
 		}
@@ -881,7 +1250,7 @@ func handlePreciousblock(s *Server, cmd interface{}, closeChan <-chan struct{})
 
 func handleInvalidateBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c, ok := cmd.(*btcjson.InvalidateBlockCmd)
-	bkHash, err := util.GetHashFromStr(c.BlockHash)
+	bkHash, err := util.ParseHash(c.BlockHash)
 	if !ok || err != nil {
 		return nil, btcjson.NewRPCError(btcjson.RPCInvalidParameter, "malformed request")
 	}
@@ -889,20 +1258,20 @@ func handleInvalidateBlock(s *Server, cmd interface{}, closeChan <-chan struct{}
 	persist.CsMain.Lock()
 	defer persist.CsMain.Unlock()
 
-	log.Debug("InvalidateBlock start: " + chainStatus(bkHash))
+	log.Debug("InvalidateBlock start: " + chainStatus(&bkHash))
 
 	gchain := chain.GetInstance()
-	bi := gchain.FindBlockIndex(*bkHash)
+	bi := gchain.FindBlockIndex(bkHash)
 	if bi == nil {
-		log.Error("InvalidateBlock failed, target block not found. " + chainStatus(bkHash))
+		log.Error("InvalidateBlock failed, target block not found. " + chainStatus(&bkHash))
 		return nil, btcjson.NewRPCError(btcjson.RPCInvalidAddressOrKey, "Block not found")
 	}
 
-	for gchain.FindHashInActive(*bkHash) != nil {
+	for gchain.FindHashInActive(bkHash) != nil {
 		lchain.InvalidBlockParentFound(gchain.Tip())
 
 		if err = lchain.DisconnectTip(false); err != nil {
-			log.Error("InvalidateBlock failed during DisconnectTip, " + chainStatus(bkHash))
+			log.Error("InvalidateBlock failed during DisconnectTip, " + chainStatus(&bkHash))
 			return nil, btcjson.NewRPCError(btcjson.RPCDatabaseError, "disconnect failed")
 		}
 	}
@@ -910,17 +1279,17 @@ func handleInvalidateBlock(s *Server, cmd interface{}, closeChan <-chan struct{}
 	lchain.InvalidBlockFound(bi)
 
 	if err = lchain.ActivateBestChain(nil); err != nil {
-		log.Error("InvalidateBlock failed during ActivateBestChain, " + chainStatus(bkHash))
+		log.Error("InvalidateBlock failed during ActivateBestChain, " + chainStatus(&bkHash))
 		return nil, btcjson.NewRPCError(btcjson.RPCDatabaseError, "failed with err:"+err.Error())
 	}
 	lmempool.RemoveForReorg(chain.GetInstance().Tip().Height+1, int(tx.StandardLockTimeVerifyFlags))
-	log.Debug("InvalidateBlock end: " + chainStatus(bkHash))
+	log.Debug("InvalidateBlock end: " + chainStatus(&bkHash))
 	return nil, nil
 }
 
 func handleReconsiderBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c, ok := cmd.(*btcjson.ReconsiderBlockCmd)
-	bkHash, err := util.GetHashFromStr(c.BlockHash)
+	bkHash, err := util.ParseHash(c.BlockHash)
 	if !ok || err != nil {
 		return nil, btcjson.NewRPCError(btcjson.RPCInvalidParameter, "malformed request")
 	}
@@ -929,22 +1298,22 @@ func handleReconsiderBlock(s *Server, cmd interface{}, closeChan <-chan struct{}
 	defer persist.CsMain.Unlock()
 
 	gchain := chain.GetInstance()
-	targetBI := gchain.FindBlockIndex(*bkHash)
+	targetBI := gchain.FindBlockIndex(bkHash)
 	if targetBI == nil {
-		log.Error("ReconsiderBlock failed, target block not found. " + chainStatus(bkHash))
+		log.Error("ReconsiderBlock failed, target block not found. " + chainStatus(&bkHash))
 		return nil, btcjson.NewRPCError(btcjson.RPCInvalidAddressOrKey, "Block not found")
 	}
 
-	log.Debug("ReconsiderBlock start: " + chainStatus(bkHash))
+	log.Debug("ReconsiderBlock start: " + chainStatus(&bkHash))
 	targetBI.SubStatus(blockindex.BlockInvalidMask)
 	gchain.ResetBlockFailureFlags(targetBI)
 
 	if err = lchain.ActivateBestChain(nil); err != nil {
-		log.Error("ReconsiderBlock failed, " + chainStatus(bkHash))
+		log.Error("ReconsiderBlock failed, " + chainStatus(&bkHash))
 		return nil, btcjson.NewRPCError(btcjson.RPCDatabaseError, "failed with err:"+err.Error())
 	}
 
-	log.Debug("ReconsiderBlock end: " + chainStatus(bkHash))
+	log.Debug("ReconsiderBlock end: " + chainStatus(&bkHash))
 	return nil, nil
 }
 
@@ -959,7 +1328,7 @@ func handleWaitForNewBlock(s *Server, cmd interface{}, closeChan <-chan struct{}
 
 func handleWaitForBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c, ok := cmd.(*btcjson.WaitForBlockCmd)
-	bkHash, err := util.GetHashFromStr(*c.BlockHash)
+	bkHash, err := util.ParseHash(*c.BlockHash)
 	if !ok || err != nil {
 		return nil, btcjson.NewRPCError(btcjson.RPCInvalidParameter, "malformed request")
 	}
@@ -983,7 +1352,7 @@ func handleWaitForBlock(s *Server, cmd interface{}, closeChan <-chan struct{}) (
 
 		chainTip := gchain.Tip()
 		tipHash := chainTip.GetBlockHash()
-		if tipHash == bkHash {
+		if *tipHash == bkHash {
 			ret.Hash = *c.BlockHash
 			ret.Height = chainTip.Height
 			return ret, nil
@@ -0,0 +1,748 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lindex"
+	"github.com/copernet/copernicus/logic/lmempool"
+	"github.com/copernet/copernicus/logic/lmerkleroot"
+	"github.com/copernet/copernicus/logic/ltx"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
+	"github.com/copernet/copernicus/model/blockindex"
+	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/pow"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/model/utxo"
+	"github.com/copernet/copernicus/persist"
+	"github.com/copernet/copernicus/persist/blkdb"
+	"github.com/copernet/copernicus/persist/db"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/copernet/copernicus/rpc/btcjson"
+	"github.com/copernet/copernicus/service"
+	"github.com/copernet/copernicus/service/mining"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+	"github.com/copernet/copernicus/util/descriptor"
+	"github.com/copernet/copernicus/util/gcs"
+)
+
+var scriptVerifyOnce sync.Once
+
+// initTestChain sets up a regtest node with an empty chain in a temporary
+// directory, and returns the function removing it.
+func initTestChain(t *testing.T) func() {
+	chain.Close()
+	conf.Cfg = conf.InitConfig([]string{"--regtest"})
+	dataDir, err := conf.SetUnitTestDataDir(conf.Cfg)
+	if err != nil {
+		t.Fatalf("init test data dir failed: %v", err)
+	}
+	model.SetRegTestParams()
+
+	utxo.InitUtxoLruTip(&utxo.UtxoConfig{Do: &db.DBOption{
+		FilePath:  conf.DataDir + "/chainstate",
+		CacheSize: (1 << 20) * 8,
+	}})
+	blkdb.InitBlockTreeDB(&blkdb.BlockTreeDBConfig{Do: &db.DBOption{
+		FilePath:  conf.DataDir + "/blocks/index",
+		CacheSize: (1 << 20) * 8,
+	}})
+	chain.InitGlobalChain(blkdb.GetInstance())
+	persist.InitPersistGlobal(blkdb.GetInstance())
+	if err := lchain.InitGenesisChain(); err != nil {
+		t.Fatalf("init genesis chain failed: %v", err)
+	}
+	mempool.InitMempool()
+	crypto.InitSecp256()
+	scriptVerifyOnce.Do(ltx.ScriptVerifyInit)
+
+	return func() {
+		os.RemoveAll(dataDir)
+	}
+}
+
+// mineTemplate solves the block of the template and connects it to the chain.
+func mineTemplate(t *testing.T, bt *mining.BlockTemplate) *block.Block {
+	params := model.ActiveNetParams
+	blk := bt.Block
+	blk.Header.MerkleRoot = lmerkleroot.BlockMerkleRoot(blk.Txs, nil)
+	for {
+		hash := blk.GetHash()
+		if new(pow.Pow).CheckProofOfWork(&hash, blk.Header.Bits, params) {
+			break
+		}
+		blk.Header.Nonce++
+	}
+
+	fNewBlock := false
+	if err := service.ProcessNewBlock(blk, true, &fNewBlock); err != nil {
+		t.Fatalf("block not accepted: %v", err)
+	}
+	return blk
+}
+
+// mineBlocks connects n blocks including the mempool transactions, and paying
+// their coinbase to scriptPubKey.
+func mineBlocks(t *testing.T, scriptPubKey *script.Script, n int) []*block.Block {
+	blocks := make([]*block.Block, 0, n)
+	for i := 0; i < n; i++ {
+		ba := mining.NewBlockAssembler(model.ActiveNetParams)
+		blocks = append(blocks, mineTemplate(t, ba.CreateNewBlock(scriptPubKey, mining.CoinbaseScriptSig(0))))
+	}
+	return blocks
+}
+
+// newSpendingTx returns a transaction spending the outputs to an OP_TRUE
+// output, leaving fee for the miner.
+func newSpendingTx(fee amount.Amount, prevOuts ...*outpoint.OutPoint) *tx.Tx {
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	// pads the transaction over the minimum transaction size
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+
+	var value amount.Amount
+	transaction := tx.NewTx(0, tx.DefaultVersion)
+	for _, prevOut := range prevOuts {
+		coin := utxo.GetUtxoCacheInstance().GetCoin(prevOut)
+		if coin == nil {
+			coin = mempool.GetInstance().GetCoin(prevOut)
+		}
+		value += coin.GetAmount()
+		transaction.AddTxIn(txin.NewTxIn(prevOut, script.NewEmptyScript(), math.MaxUint32-1))
+	}
+	transaction.AddTxOut(txout.NewTxOut(value-fee, opTrue))
+	transaction.AddTxOut(txout.NewTxOut(0, padding))
+	return transaction
+}
+
+// coinbaseOut returns the coinbase output of the block at the height.
+func coinbaseOut(t *testing.T, height int32) *outpoint.OutPoint {
+	gChain := chain.GetInstance()
+	blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+	if !ok {
+		t.Fatalf("read block %d failed", height)
+	}
+	return outpoint.NewOutPoint(blk.Txs[0].GetHash(), 0)
+}
+
+type fakeTxStatusSource struct {
+	mempool    map[util.Hash]bool
+	confirmed  map[util.Hash]*blockindex.BlockIndex
+	tipHeight  int32
+	conflicted map[util.Hash]*tx.Tx
+	spentBy    map[outpoint.OutPoint]util.Hash
+}
+
+func newFakeTxStatusSource() *fakeTxStatusSource {
+	return &fakeTxStatusSource{
+		mempool:    make(map[util.Hash]bool),
+		confirmed:  make(map[util.Hash]*blockindex.BlockIndex),
+		conflicted: make(map[util.Hash]*tx.Tx),
+		spentBy:    make(map[outpoint.OutPoint]util.Hash),
+	}
+}
+
+func (src *fakeTxStatusSource) InMempool(hash *util.Hash) bool {
+	return src.mempool[*hash]
+}
+
+func (src *fakeTxStatusSource) ConfirmedIn(hash *util.Hash) *blockindex.BlockIndex {
+	return src.confirmed[*hash]
+}
+
+func (src *fakeTxStatusSource) TipHeight() int32 {
+	return src.tipHeight
+}
+
+func (src *fakeTxStatusSource) FindConflicted(hash *util.Hash) *tx.Tx {
+	return src.conflicted[*hash]
+}
+
+func (src *fakeTxStatusSource) SpentByOther(out *outpoint.OutPoint, spender *util.Hash) bool {
+	by, ok := src.spentBy[*out]
+	return ok && by != *spender
+}
+
+func newStatusTestTx(prevHash util.Hash, lockTime uint32) *tx.Tx {
+	transaction := tx.NewTx(lockTime, tx.DefaultVersion)
+	transaction.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(prevHash, 0), script.NewEmptyScript(), script.SequenceFinal))
+	transaction.AddTxOut(txout.NewTxOut(1000, script.NewScriptRaw([]byte{0x51})))
+	return transaction
+}
+
+func TestGetTransactionStatus(t *testing.T) {
+	src := newFakeTxStatusSource()
+	src.tipHeight = 110
+
+	mempoolTx := newStatusTestTx(util.Hash{1}, 0)
+	mempoolHash := mempoolTx.GetHash()
+	src.mempool[mempoolHash] = true
+
+	confirmedTx := newStatusTestTx(util.Hash{2}, 0)
+	confirmedHash := confirmedTx.GetHash()
+	index := blockindex.NewBlockIndex(block.NewBlockHeader())
+	index.Height = 101
+	src.confirmed[confirmedHash] = index
+
+	// the coin spent by conflictedTx got spent by a transaction in a block
+	conflictedTx := newStatusTestTx(util.Hash{3}, 0)
+	conflictedHash := conflictedTx.GetHash()
+	src.conflicted[conflictedHash] = conflictedTx
+	src.spentBy[*outpoint.NewOutPoint(util.Hash{3}, 0)] = newStatusTestTx(util.Hash{3}, 1).GetHash()
+
+	// evicted for a conflict, but the conflicting block was reorganized away
+	reorgedTx := newStatusTestTx(util.Hash{4}, 0)
+	reorgedHash := reorgedTx.GetHash()
+	src.conflicted[reorgedHash] = reorgedTx
+
+	unknownHash := newStatusTestTx(util.Hash{5}, 0).GetHash()
+
+	tests := []struct {
+		name          string
+		hash          util.Hash
+		status        string
+		height        int32
+		confirmations int32
+	}{
+		{"mempool", mempoolHash, txStatusMempool, 0, 0},
+		{"confirmed", confirmedHash, txStatusConfirmed, 101, 10},
+		{"conflicted", conflictedHash, txStatusConflicted, 0, 0},
+		{"conflict reorganized away", reorgedHash, txStatusUnknown, 0, 0},
+		{"unknown", unknownHash, txStatusUnknown, 0, 0},
+	}
+
+	for _, test := range tests {
+		result := getTransactionStatus(&test.hash, src)
+		if result.Status != test.status {
+			t.Errorf("%s: expect status %s, got %s", test.name, test.status, result.Status)
+		}
+		if result.Height != test.height || result.Confirmations != test.confirmations {
+			t.Errorf("%s: expect height %d and %d confirmations, got %d and %d", test.name,
+				test.height, test.confirmations, result.Height, result.Confirmations)
+		}
+	}
+}
+
+func TestGetTransactionStatusThroughNode(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 102)
+
+	src := nodeTxStatusSource{}
+	status := func(transaction *tx.Tx) *btcjson.GetTransactionStatusResult {
+		hash := transaction.GetHash()
+		return getTransactionStatus(&hash, src)
+	}
+
+	confirmed := newSpendingTx(10000, coinbaseOut(t, 1))
+	if err := lmempool.AcceptTxToMemPool(confirmed); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	if result := status(confirmed); result.Status != txStatusMempool {
+		t.Errorf("expect status %s, got %s", txStatusMempool, result.Status)
+	}
+
+	mineBlocks(t, opTrue, 2)
+	result := status(confirmed)
+	if result.Status != txStatusConfirmed || result.Height != 103 || result.Confirmations != 2 {
+		t.Errorf("expect confirmed at 103 with 2 confirmations, got %s at %d with %d",
+			result.Status, result.Height, result.Confirmations)
+	}
+
+	// winner is mined while loser, spending the same coin, is in the mempool
+	winner := newSpendingTx(10000, coinbaseOut(t, 2))
+	loser := newSpendingTx(20000, coinbaseOut(t, 2))
+	if err := lmempool.AcceptTxToMemPool(winner); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	bt := mining.NewBlockAssembler(model.ActiveNetParams).CreateNewBlock(opTrue, mining.CoinbaseScriptSig(0))
+	mempool.GetInstance().RemoveTxSelf([]*tx.Tx{winner})
+	if err := lmempool.AcceptTxToMemPool(loser); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	mineTemplate(t, bt)
+
+	if result := status(loser); result.Status != txStatusConflicted {
+		t.Errorf("expect status %s, got %s", txStatusConflicted, result.Status)
+	}
+	if result := status(winner); result.Status != txStatusConfirmed || result.Confirmations != 1 {
+		t.Errorf("expect confirmed with 1 confirmation, got %s with %d", result.Status, result.Confirmations)
+	}
+
+	unknown := newSpendingTx(10000, coinbaseOut(t, 3))
+	if result := status(unknown); result.Status != txStatusUnknown {
+		t.Errorf("expect status %s, got %s", txStatusUnknown, result.Status)
+	}
+}
+
+func TestGetBlockFilter(t *testing.T) {
+	defer initTestChain(t)()
+
+	cmd := btcjson.NewGetBlockFilterCmd(chain.GetInstance().Tip().GetBlockHash().String(), nil)
+	if _, err := handleGetBlockFilter(nil, cmd, nil); err == nil {
+		t.Fatalf("getblockfilter should fail when the index is not enabled")
+	}
+
+	conf.Cfg.Chain.BlockFilterIndex = true
+	lindex.InitBlockFilterIndex()
+	defer lindex.StopBlockFilterIndex()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 101)
+	spend := newSpendingTx(10000, coinbaseOut(t, 1))
+	if err := lmempool.AcceptTxToMemPool(spend); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	blk := mineBlocks(t, opTrue, 1)[0]
+
+	filterIndex := lindex.GetBlockFilterIndex()
+	for deadline := time.Now().Add(10 * time.Second); !filterIndex.IsSynced(); {
+		if time.Now().After(deadline) {
+			t.Fatal("block filter index not synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	getFilter := func(hash *util.Hash) *btcjson.GetBlockFilterResult {
+		result, err := handleGetBlockFilter(nil, btcjson.NewGetBlockFilterCmd(hash.String(), nil), nil)
+		if err != nil {
+			t.Fatalf("getblockfilter %s failed: %v", hash, err)
+		}
+		return result.(*btcjson.GetBlockFilterResult)
+	}
+	blkHash := blk.GetHash()
+	result := getFilter(&blkHash)
+	prevResult := getFilter(&blk.Header.HashPrevBlock)
+
+	filterBytes, err := hex.DecodeString(result.Filter)
+	if err != nil {
+		t.Fatalf("decode filter failed: %v", err)
+	}
+	filter, err := gcs.FromNBytes(lindex.BasicFilterP, lindex.BasicFilterM, filterBytes)
+	if err != nil {
+		t.Fatalf("parse filter failed: %v", err)
+	}
+	// the block pays to OP_TRUE and spends an OP_TRUE coinbase output
+	if match, err := filter.Match(lindex.BasicFilterKey(&blkHash), opTrue.GetData()); err != nil || !match {
+		t.Errorf("filter should match the OP_TRUE script, got %v %v", match, err)
+	}
+
+	prevHeader, err := util.GetHashFromStr(prevResult.Header)
+	if err != nil {
+		t.Fatalf("decode header failed: %v", err)
+	}
+	filterHash := util.DoubleSha256Hash(filterBytes)
+	if header := lindex.FilterHeader(&filterHash, prevHeader); header.String() != result.Header {
+		t.Errorf("filter header should chain to the previous one, got %s want %s",
+			result.Header, header.String())
+	}
+
+	basic := "basic"
+	unknown := "extended"
+	if _, err := handleGetBlockFilter(nil, btcjson.NewGetBlockFilterCmd(blkHash.String(), &basic), nil); err != nil {
+		t.Errorf("getblockfilter with the basic type failed: %v", err)
+	}
+	if _, err := handleGetBlockFilter(nil, btcjson.NewGetBlockFilterCmd(blkHash.String(), &unknown), nil); err == nil {
+		t.Errorf("getblockfilter should reject an unknown filter type")
+	}
+	if _, err := handleGetBlockFilter(nil, btcjson.NewGetBlockFilterCmd(util.HashOne.String(), nil), nil); err == nil {
+		t.Errorf("getblockfilter should reject an unknown block")
+	}
+}
+
+func TestGetBlockHashArgument(t *testing.T) {
+	defer initTestChain(t)()
+
+	verbose := false
+	hash := chain.GetInstance().Genesis().GetBlockHash().String()
+	if _, err := handleGetBlock(nil, &btcjson.GetBlockCmd{Hash: hash, Verbose: &verbose}, nil); err != nil {
+		t.Fatalf("getblock %s failed: %v", hash, err)
+	}
+
+	// a hash missing its leading zero digit is not padded back to the genesis hash
+	if hash[0] != '0' {
+		t.Fatalf("genesis hash %s has no leading zero digit", hash)
+	}
+	_, err := handleGetBlock(nil, &btcjson.GetBlockCmd{Hash: hash[1:], Verbose: &verbose}, nil)
+	if rpcErr, ok := err.(*btcjson.RPCError); !ok || rpcErr.Code != btcjson.ErrRPCDecodeHexString {
+		t.Errorf("getblock of a 63 digit hash returned %v", err)
+	}
+}
+
+func TestGetBlockLocation(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	blocks := mineBlocks(t, opTrue, 5)
+
+	for _, blk := range blocks {
+		hash := blk.GetHash().String()
+		ret, err := handleGetBlockLocation(nil, &btcjson.GetBlockLocationCmd{Hash: hash}, nil)
+		if err != nil {
+			t.Fatalf("getblocklocation %s failed: %v", hash, err)
+		}
+		location := ret.(*btcjson.GetBlockLocationResult)
+
+		file, err := os.Open(disk.GetBlockPosFilename(block.DiskBlockPos{File: location.File}, "blk"))
+		if err != nil {
+			t.Fatalf("open block file %d: %v", location.File, err)
+		}
+		data := make([]byte, location.Length)
+		_, err = file.ReadAt(data, int64(location.Offset))
+		file.Close()
+		if err != nil {
+			t.Fatalf("read block %s at %+v: %v", hash, location, err)
+		}
+
+		verbose := false
+		raw, err := handleGetBlock(nil, &btcjson.GetBlockCmd{Hash: hash, Verbose: &verbose}, nil)
+		if err != nil {
+			t.Fatalf("getblock %s failed: %v", hash, err)
+		}
+		if hex.EncodeToString(data) != raw.(string) {
+			t.Errorf("block %s at %+v differs from getblock", hash, location)
+		}
+	}
+
+	ret, err := handleDumpBlockFile(nil, &btcjson.DumpBlockFileCmd{File: 0}, nil)
+	if err != nil {
+		t.Fatalf("dumpblockfile failed: %v", err)
+	}
+	summary := ret.(*btcjson.DumpBlockFileResult)
+	if summary.Blocks != 6 || summary.HeightFirst != 0 || summary.HeightLast != 5 {
+		t.Errorf("unexpected block file summary %+v", summary)
+	}
+	tip := blocks[len(blocks)-1]
+	if summary.TimeLast != uint64(tip.Header.Time) {
+		t.Errorf("block file last time %d, want %d", summary.TimeLast, tip.Header.Time)
+	}
+	if _, err := handleDumpBlockFile(nil, &btcjson.DumpBlockFileCmd{File: 1}, nil); err == nil {
+		t.Error("dumpblockfile of a missing file succeeded")
+	}
+}
+
+func TestGetChainTxStats(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 102)
+	for height := int32(1); height <= 2; height++ {
+		if err := lmempool.AcceptTxToMemPool(newSpendingTx(10000, coinbaseOut(t, height))); err != nil {
+			t.Fatalf("accept tx failed: %v", err)
+		}
+	}
+	// the window holds a block with the two spends and two empty blocks
+	mineBlocks(t, opTrue, 3)
+
+	tip := chain.GetInstance().Tip()
+	nblocks := int32(3)
+	ret, err := handleGetChainTxStats(nil, btcjson.NewGetChainTxStatsCmd(&nblocks, nil), nil)
+	if err != nil {
+		t.Fatalf("getchaintxstats failed: %v", err)
+	}
+	stats := ret.(*btcjson.GetChainTxStatsResult)
+	if stats.WindowTxCount != 5 {
+		t.Errorf("window_tx_count %d, want 5", stats.WindowTxCount)
+	}
+	if stats.TxCount != tip.Height+1+2 {
+		t.Errorf("txcount %d, want %d", stats.TxCount, tip.Height+1+2)
+	}
+	if stats.FinalBlockHash != tip.GetBlockHash().String() || stats.FinalBlockHeight != tip.Height {
+		t.Errorf("window ends at %s %d, want the tip %s %d",
+			stats.FinalBlockHash, stats.FinalBlockHeight, tip.GetBlockHash(), tip.Height)
+	}
+	if stats.BlockCount != nblocks {
+		t.Errorf("window_block_count %d, want %d", stats.BlockCount, nblocks)
+	}
+
+	// a window ending before the spends only holds coinbases
+	prevHash := tip.Prev.Prev.Prev.GetBlockHash().String()
+	ret, err = handleGetChainTxStats(nil, btcjson.NewGetChainTxStatsCmd(&nblocks, &prevHash), nil)
+	if err != nil {
+		t.Fatalf("getchaintxstats at %s failed: %v", prevHash, err)
+	}
+	if stats = ret.(*btcjson.GetChainTxStatsResult); stats.WindowTxCount != 3 {
+		t.Errorf("window_tx_count %d, want 3", stats.WindowTxCount)
+	}
+
+	tooMany := tip.Height
+	if _, err := handleGetChainTxStats(nil, btcjson.NewGetChainTxStatsCmd(&tooMany, nil), nil); err == nil {
+		t.Error("getchaintxstats should reject a window reaching past genesis")
+	}
+}
+
+func TestGetBlockHashByTime(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	gChain := chain.GetInstance()
+	genesisTime := int64(gChain.Genesis().GetBlockTime())
+	for i := int64(1); i <= 20; i++ {
+		ba := mining.NewBlockAssembler(model.ActiveNetParams)
+		bt := ba.CreateNewBlock(opTrue, mining.CoinbaseScriptSig(0))
+		bt.Block.Header.Time = uint32(genesisTime + 600*i)
+		mineTemplate(t, bt)
+	}
+
+	lookup := func(timestamp int64) *btcjson.GetBlockHashByTimeResult {
+		ret, err := handleGetBlockHashByTime(nil, btcjson.NewGetBlockHashByTimeCmd(timestamp), nil)
+		if err != nil {
+			t.Fatalf("getblockhashbytime %d failed: %v", timestamp, err)
+		}
+		return ret.(*btcjson.GetBlockHashByTimeResult)
+	}
+
+	// between the median times past of blocks 12 and 13
+	block12 := gChain.GetIndex(12)
+	block13 := gChain.GetIndex(13)
+	between := block12.GetMedianTimePast() + 1
+	if between >= block13.GetMedianTimePast() {
+		t.Fatalf("blocks 12 and 13 have no time between them: %d %d",
+			block12.GetMedianTimePast(), block13.GetMedianTimePast())
+	}
+	if result := lookup(between); result.Height != 12 || result.Hash != block12.GetBlockHash().String() {
+		t.Errorf("got block %d %s, want block 12 %s", result.Height, result.Hash, block12.GetBlockHash())
+	}
+	if result := lookup(block13.GetMedianTimePast()); result.Height != 13 {
+		t.Errorf("got block %d at the median time past of block 13", result.Height)
+	}
+
+	if result := lookup(genesisTime - 1); result.Height != 0 ||
+		result.Hash != gChain.Genesis().GetBlockHash().String() {
+		t.Errorf("got block %d before genesis, want genesis", result.Height)
+	}
+	if result := lookup(genesisTime + 1000000); result.Height != gChain.Height() {
+		t.Errorf("got block %d after the tip, want the tip %d", result.Height, gChain.Height())
+	}
+}
+
+func TestScanTxOutSet(t *testing.T) {
+	defer initTestChain(t)()
+
+	hash160 := make([]byte, 20)
+	hash160[0] = 0x11
+	addr, err := script.AddressFromHash160(hash160, script.AddressVerPubKey())
+	if err != nil {
+		t.Fatalf("make address failed: %v", err)
+	}
+	p2pkh, rpcErr := getStandardScriptPubKey(addr.String(), nil)
+	if rpcErr != nil {
+		t.Fatalf("make script failed: %v", rpcErr)
+	}
+	blocks := mineBlocks(t, p2pkh, 2)
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 1)
+
+	var total amount.Amount
+	coinbases := make(map[string]bool)
+	for _, blk := range blocks {
+		coinbases[blk.Txs[0].GetHash().String()] = true
+		total += blk.Txs[0].GetTxOut(0).GetValue()
+	}
+
+	scan := func(objects ...string) *btcjson.ScanTxOutSetResult {
+		ret, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("start", &objects), nil)
+		if err != nil {
+			t.Fatalf("scantxoutset %v failed: %v", objects, err)
+		}
+		return ret.(*btcjson.ScanTxOutSetResult)
+	}
+	rawDesc := "raw(" + hex.EncodeToString(p2pkh.GetData()) + ")"
+	rawSum, err := descriptor.Checksum(rawDesc)
+	if err != nil {
+		t.Fatalf("checksum %s failed: %v", rawDesc, err)
+	}
+	for _, object := range []string{
+		addr.String(),
+		"addr(" + addr.String() + ")",
+		rawDesc,
+		rawDesc + "#" + rawSum,
+	} {
+		result := scan(object)
+		if !result.Success || len(result.Unspents) != len(blocks) {
+			t.Fatalf("scan for %s found %d unspents, want %d", object, len(result.Unspents), len(blocks))
+		}
+		for _, unspent := range result.Unspents {
+			if !coinbases[unspent.TxID] || unspent.Vout != 0 {
+				t.Errorf("scan for %s found %s:%d, not a coinbase output", object, unspent.TxID, unspent.Vout)
+			}
+		}
+		if result.TotalAmount != valueFromAmount(int64(total)) {
+			t.Errorf("scan for %s total %v, want %v", object, result.TotalAmount, valueFromAmount(int64(total)))
+		}
+		// the genesis coinbase is not in the UTXO set
+		if result.SearchedItems != 3 {
+			t.Errorf("scan for %s searched %d items, want 3", object, result.SearchedItems)
+		}
+	}
+
+	if ret, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("status", nil), nil); err != nil || ret != nil {
+		t.Errorf("status without a running scan returned %v %v", ret, err)
+	}
+	if ret, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("abort", nil), nil); err != nil || ret != false {
+		t.Errorf("abort without a running scan returned %v %v", ret, err)
+	}
+	if _, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("start", nil), nil); err == nil {
+		t.Error("start without scan objects succeeded")
+	}
+	if _, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("rescan", nil), nil); err == nil {
+		t.Error("an unknown action succeeded")
+	}
+	for _, object := range []string{"raw(zz)", rawDesc + "#00000000"} {
+		invalid := []string{object}
+		if _, err := handleScanTxOutSet(nil, btcjson.NewScanTxOutSetCmd("start", &invalid), nil); err == nil {
+			t.Errorf("the invalid scan object %s was accepted", object)
+		}
+	}
+}
+
+func TestSaveMempool(t *testing.T) {
+	defer initTestChain(t)()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 102)
+
+	parent := newSpendingTx(10000, coinbaseOut(t, 1))
+	if err := lmempool.AcceptTxToMemPool(parent); err != nil {
+		t.Fatalf("accept tx failed: %v", err)
+	}
+	child := newSpendingTx(10000, outpoint.NewOutPoint(parent.GetHash(), 0))
+	other := newSpendingTx(20000, coinbaseOut(t, 2))
+	for _, transaction := range []*tx.Tx{child, other} {
+		if err := lmempool.AcceptTxToMemPool(transaction); err != nil {
+			t.Fatalf("accept tx failed: %v", err)
+		}
+	}
+	saved := mempool.GetInstance().GetAllTxEntry()
+
+	ret, err := handleSaveMempool(nil, &btcjson.SaveMempoolCmd{}, nil)
+	if err != nil {
+		t.Fatalf("savemempool failed: %v", err)
+	}
+	path := ret.(string)
+	if path != filepath.Join(conf.DataDir, mempool.DumpFileName) {
+		t.Errorf("unexpected mempool file %s", path)
+	}
+
+	mempool.InitMempool()
+	loaded, err := mempool.Load(path, func(txn *tx.Tx, entryTime int64) error {
+		if entry, ok := saved[txn.GetHash()]; !ok || entry.GetTime() != entryTime {
+			t.Errorf("tx %s not saved with its time", txn.GetHash())
+		}
+		return lmempool.AcceptTxToMemPool(txn)
+	})
+	if err != nil || loaded != len(saved) {
+		t.Fatalf("loaded %d of %d txs: %v", loaded, len(saved), err)
+	}
+	pool := mempool.GetInstance()
+	for hash, entry := range saved {
+		found := pool.FindTx(hash)
+		if found == nil || found.TxFee != entry.TxFee {
+			t.Errorf("tx %s not loaded back", hash)
+		}
+	}
+	if pool.Size() != len(saved) {
+		t.Errorf("loaded pool holds %d txs, want %d", pool.Size(), len(saved))
+	}
+}
+
+func TestPruneBlockChain(t *testing.T) {
+	defer initTestChain(t)()
+	maxFileSize, chunkSize, undoChunkSize := persist.MaxBlockFileSize, persist.BlockFileChunkSize, persist.UndoFileChunkSize
+	persist.MaxBlockFileSize, persist.BlockFileChunkSize, persist.UndoFileChunkSize = 4000, 1000, 1000
+	defer func() {
+		persist.MaxBlockFileSize, persist.BlockFileChunkSize, persist.UndoFileChunkSize = maxFileSize, chunkSize, undoChunkSize
+	}()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	mineBlocks(t, opTrue, 400)
+
+	prune := func(height int) (int32, error) {
+		ret, err := handlePruneBlockChain(nil, &btcjson.PruneBlockChainCmd{Height: height}, nil)
+		if err != nil {
+			return 0, err
+		}
+		return ret.(int32), nil
+	}
+
+	pruneState := disk.GetPruneState()
+	defer func() {
+		pruneState.PruneMode, pruneState.HavePruned = false, false
+	}()
+	if _, err := prune(50); err == nil {
+		t.Fatalf("pruning should be refused out of prune mode")
+	}
+	pruneState.PruneMode = true
+
+	// a time older than the first block prunes nothing
+	if lastPruned, err := prune(1000000001); err != nil || lastPruned != -1 {
+		t.Fatalf("pruning before the first block pruned up to %d: %v", lastPruned, err)
+	}
+
+	gChain := chain.GetInstance()
+	lastPruned, err := prune(50)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if lastPruned < 0 || lastPruned > 50 {
+		t.Fatalf("pruned up to %d, want up to 50", lastPruned)
+	}
+	for height := int32(0); height <= lastPruned; height++ {
+		index := gChain.GetIndex(height)
+		if index.HasData() || index.HasUndo() {
+			t.Fatalf("block %d still has data after pruning", height)
+		}
+	}
+	kept := gChain.GetIndex(lastPruned + 1)
+	if !kept.HasData() || !kept.HasUndo() {
+		t.Fatalf("block %d lost its data", kept.Height)
+	}
+	if _, err := os.Stat(disk.GetBlockPosFilename(block.DiskBlockPos{File: 0}, "blk")); !os.IsNotExist(err) {
+		t.Errorf("first block file not deleted: %v", err)
+	}
+	if _, err := os.Stat(disk.GetBlockPosFilename(kept.GetBlockPos(), "blk")); err != nil {
+		t.Errorf("block file of block %d deleted: %v", kept.Height, err)
+	}
+	if _, ok := disk.ReadBlockFromDisk(kept, gChain.GetParams()); !ok {
+		t.Errorf("read kept block %d failed", kept.Height)
+	}
+
+	// the blocks close to the tip are kept
+	lastPruned, err = prune(400)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if lastPruned > 400-block.MinBlocksToKeep || !gChain.GetIndex(400-block.MinBlocksToKeep+1).HasData() {
+		t.Errorf("pruned up to %d, into the last %d blocks", lastPruned, block.MinBlocksToKeep)
+	}
+	if lastPruned <= 50 {
+		t.Errorf("second prune did not go further than %d", lastPruned)
+	}
+}
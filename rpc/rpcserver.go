@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,6 +23,8 @@ import (
 
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/chain"
 	"github.com/copernet/copernicus/net/server"
 	"github.com/copernet/copernicus/rpc/btcjson"
 	"github.com/copernet/copernicus/util"
@@ -68,6 +71,61 @@ type Server struct {
 	requestProcessShutdown chan struct{}
 	quit                   chan int
 	timeSource             *util.MedianTime
+	activeCommands         activeCommands
+}
+
+// activeCommand is an RPC command being executed.
+type activeCommand struct {
+	method string
+	start  time.Time
+}
+
+// activeCommands keeps track of the RPC commands being executed for the
+// getrpcinfo command.
+type activeCommands struct {
+	lock     sync.Mutex
+	commands map[*activeCommand]struct{}
+}
+
+func (ac *activeCommands) add(method string) *activeCommand {
+	cmd := &activeCommand{method: method, start: time.Now()}
+
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	if ac.commands == nil {
+		ac.commands = make(map[*activeCommand]struct{})
+	}
+	ac.commands[cmd] = struct{}{}
+	return cmd
+}
+
+func (ac *activeCommands) remove(cmd *activeCommand) {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	delete(ac.commands, cmd)
+}
+
+// list returns the commands being executed, the oldest first.
+func (ac *activeCommands) list() []btcjson.RPCActiveCommand {
+	ac.lock.Lock()
+	cmds := make([]*activeCommand, 0, len(ac.commands))
+	for cmd := range ac.commands {
+		cmds = append(cmds, cmd)
+	}
+	ac.lock.Unlock()
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].start.Before(cmds[j].start)
+	})
+	now := time.Now()
+	result := make([]btcjson.RPCActiveCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		result = append(result, btcjson.RPCActiveCommand{
+			Method:   cmd.method,
+			Duration: int64(now.Sub(cmd.start) / time.Microsecond),
+		})
+	}
+	return result
 }
 
 func (s *Server) httpStatusLine(req *http.Request, code int) string {
@@ -214,6 +272,7 @@ type parsedRPCCmd struct {
 func (s *Server) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct{}) (interface{}, error) {
 	handler, ok := rpcHandlers[cmd.method]
 	if ok {
+		defer s.activeCommands.remove(s.activeCommands.add(cmd.method))
 		return handler(s, cmd.cmd, closeChan)
 	}
 	return nil, btcjson.ErrRPCMethodNotFound
@@ -374,6 +433,7 @@ func (s *Server) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin boo
 			if parsedCmd.err != nil {
 				jsonErr = parsedCmd.err
 			} else {
+				log.Print("rpc", "debug", "ThreadRPCServer method=%s", parsedCmd.method)
 				log.Trace(">rpc:: %s", parsedCmd.method)
 				result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
 				log.Trace("<rpc:: %s", parsedCmd.method)
@@ -488,8 +548,14 @@ type ServerConfig struct {
 	StartupTime int64
 	ConnMgr     server.RPCConnManager
 	// The fee estimator keeps track of how long transactions are left in
-	// the mempool before they are mined into blocks.
-	//FeeEstimator *mempool.FeeEstimator
+	// the mempool before they are mined into blocks, nil for no estimates.
+	FeeEstimator FeeEstimator
+}
+
+// FeeEstimator estimates the fee rate of a transaction mined within
+// numBlocks blocks.
+type FeeEstimator interface {
+	EstimateFee(numBlocks uint32) (util.FeeRate, error)
 }
 
 // SetupRPCListeners returns a slice of listeners that are configured for use
@@ -523,7 +589,15 @@ func SetupRPCListeners() ([]net.Listener, error) {
 		}
 	}
 
-	netAddrs, err := parseListeners(conf.Cfg.RPC.RPCListeners)
+	listenAddrs, onionAddrs, err := conf.ResolveBinds(conf.Cfg.RPC.RPCBinds, conf.Cfg.RPC.RPCListeners,
+		conf.Cfg.RPC.RPCPort, model.ActiveNetParams.RPCPort)
+	if err != nil {
+		return nil, err
+	}
+	if len(onionAddrs) != 0 {
+		return nil, errors.New("rpcbind does not support =onion addresses")
+	}
+	netAddrs, err := parseListeners(listenAddrs)
 	if err != nil {
 		return nil, err
 	}
@@ -629,6 +703,7 @@ func NewServer(config *ServerConfig, ts *util.MedianTime) (*Server, error) {
 		rpc.limitauthsha = sha256.Sum256([]byte(auth))
 	}
 	//rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)  // todo open
+	chain.GetInstance().Subscribe(blockTemplateNotifier.handleBlockchainNotification)
 
 	return &rpc, nil
 }
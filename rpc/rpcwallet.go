@@ -7,6 +7,7 @@ import (
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lwallet"
 	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/mempool"
 	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/model/tx"
 	"github.com/copernet/copernicus/model/wallet"
@@ -143,7 +144,7 @@ func handleSetTxFee(s *Server, cmd interface{}, closeChan <-chan struct{}) (inte
 		return false, rpcErr
 	}
 
-	lwallet.SetFeeRate(int64(feePaid), 1000)
+	lwallet.SetFeeRate(feePaid, 1000)
 
 	return true, nil
 }
@@ -154,6 +155,9 @@ func handleSendToAddress(s *Server, cmd interface{}, closeChan <-chan struct{})
 	}
 
 	c := cmd.(*btcjson.SendToAddressCmd)
+	if !mempool.GetInstance().IsLoaded() {
+		return nil, mempoolNotLoadedRPCError
+	}
 
 	scriptPubKey, rpcErr := getStandardScriptPubKey(c.Address, nil)
 	if rpcErr != nil {
@@ -200,11 +204,11 @@ func handleGetTransaction(s *Server, cmd interface{}, closeChan <-chan struct{})
 	}
 	c := cmd.(*btcjson.GetTransactionCmd)
 	pwallet := wallet.GetInstance()
-	txHash, err := util.GetHashFromStr(c.Txid)
+	txHash, err := util.ParseHash(c.Txid)
 	if err != nil {
 		return nil, errors.New("Tx Hash is err")
 	}
-	wtx := pwallet.GetWalletTx(*txHash)
+	wtx := pwallet.GetWalletTx(txHash)
 	if wtx == nil {
 		return nil, errors.New("Invalid or non-wallet transaction id")
 	}
@@ -253,7 +257,7 @@ func handleFundRawTransaction(s *Server, cmd interface{}, closeChan <-chan struc
 	b, _ := hex.DecodeString(c.HexTx)
 	ubuf := bytes.NewBuffer(b)
 	txn := tx.Tx{}
-	if err := txn.Unserialize(ubuf); err != nil {
+	if err := txn.UnserializeUnfunded(ubuf); err != nil {
 		return nil, rpcDecodeHexError(c.HexTx)
 	}
 
@@ -355,6 +359,9 @@ func handleSendMany(s *Server, cmd interface{}, closeChan <-chan struct{}) (inte
 	}
 
 	c := cmd.(*btcjson.SendManyCmd)
+	if !mempool.GetInstance().IsLoaded() {
+		return nil, mempoolNotLoadedRPCError
+	}
 
 	// TODO: check Peer-to-peer connection
 
@@ -0,0 +1,76 @@
+package mining
+
+import (
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/consensus"
+	"github.com/copernet/copernicus/model/outpoint"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/model/tx"
+	"github.com/copernet/copernicus/model/txin"
+	"github.com/copernet/copernicus/model/txout"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+// maxCoinbaseScriptSigSize is the consensus limit on the size of the
+// scriptSig of a coinbase.
+const maxCoinbaseScriptSigSize = 100
+
+// CoinbaseMessage returns the message put in the coinbase scriptSig by
+// default, the excessive block size followed by CoinbaseFlag.
+func CoinbaseMessage() []byte {
+	return append(getExcessiveBlockSizeSig(), []byte(CoinbaseFlag)...)
+}
+
+// CoinbaseScript returns the scriptSig of a coinbase at the height: the BIP34
+// height push, the extra nonce and the message. The message is cut to keep
+// the scriptSig within the size limit.
+func CoinbaseScript(height int32, extraNonce uint64, message []byte) *script.Script {
+	scriptSig := script.NewEmptyScript()
+	scriptSig.PushScriptNum(script.NewScriptNum(int64(height)))
+	scriptSig.PushScriptNum(script.NewScriptNum(int64(extraNonce)))
+
+	// The message is pushed as raw bytes after the pushes, so it takes
+	// exactly its length.
+	if room := maxCoinbaseScriptSigSize - scriptSig.Size(); len(message) > room {
+		message = message[:room]
+	}
+	scriptSig.PushData(message)
+	return scriptSig
+}
+
+// CreateCoinbase builds the coinbase of a block at the height, paying the
+// block subsidy plus the fees to payScript, with the default coinbase
+// message.
+func CreateCoinbase(height int32, payScript []byte, extraNonce uint64, fees amount.Amount,
+	params *model.BitcoinParams) *tx.Tx {
+
+	return CreateCoinbaseWithMessage(height, payScript, extraNonce, fees, params, CoinbaseMessage())
+}
+
+// CreateCoinbaseWithMessage is like CreateCoinbase, with arbitrary data as
+// the coinbase message.
+func CreateCoinbaseWithMessage(height int32, payScript []byte, extraNonce uint64, fees amount.Amount,
+	params *model.BitcoinParams, message []byte) *tx.Tx {
+
+	value := fees + model.GetBlockSubsidy(height, params)
+	return newCoinbaseTx(CoinbaseScript(height, extraNonce, message), script.NewScriptRaw(payScript), value)
+}
+
+// newCoinbaseTx returns a coinbase with the scriptSig paying value to
+// scriptPubKey. The scriptSig is padded when the coinbase without its output
+// would be smaller than the minimum transaction size.
+func newCoinbaseTx(scriptSig, scriptPubKey *script.Script, value amount.Amount) *tx.Tx {
+	coinbaseTx := tx.NewTx(0, tx.DefaultVersion)
+
+	outPoint := outpoint.OutPoint{Hash: util.HashZero, Index: 0xffffffff}
+	coinbaseTx.AddTxIn(txin.NewTxIn(&outPoint, scriptSig, 0xffffffff))
+	coinbaseSerializeSize := coinbaseTx.SerializeSize()
+	if coinbaseSerializeSize < consensus.MinTxSize {
+		byteLen := consensus.MinTxSize - coinbaseSerializeSize - 1
+		scriptSig.PushData(make([]byte, byteLen))
+	}
+
+	coinbaseTx.AddTxOut(txout.NewTxOut(value, scriptPubKey))
+	return coinbaseTx
+}
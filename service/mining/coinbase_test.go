@@ -0,0 +1,92 @@
+package mining
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/consensus"
+	"github.com/copernet/copernicus/util/amount"
+)
+
+func TestCreateCoinbaseValue(t *testing.T) {
+	payScript := []byte{0x51}
+	fees := amount.Amount(12345)
+
+	tests := []struct {
+		height   int32
+		subsidy  amount.Amount
+		expected amount.Amount
+	}{
+		{1, 5000000000, 5000000000 + fees},
+		{209999, 5000000000, 5000000000 + fees},
+		{210000, 2500000000, 2500000000 + fees},
+		{420000, 1250000000, 1250000000 + fees},
+		{630000, 625000000, 625000000 + fees},
+		{64 * 210000, 0, fees},
+	}
+
+	for _, test := range tests {
+		coinbase := CreateCoinbaseWithMessage(test.height, payScript, 0, fees, &model.MainNetParams, nil)
+		if !coinbase.IsCoinBase() {
+			t.Errorf("height %d: expect a coinbase", test.height)
+		}
+		if subsidy := model.GetBlockSubsidy(test.height, &model.MainNetParams); subsidy != test.subsidy {
+			t.Errorf("height %d: expect subsidy %d, got %d", test.height, test.subsidy, subsidy)
+		}
+		if value := coinbase.GetValueOut(); value != test.expected {
+			t.Errorf("height %d: expect value %d, got %d", test.height, test.expected, value)
+		}
+		if !bytes.Equal(coinbase.GetTxOut(0).GetScriptPubKey().GetData(), payScript) {
+			t.Errorf("height %d: expect coinbase to pay to the given script", test.height)
+		}
+		if coinbase.SerializeSize() < consensus.MinTxSize {
+			t.Errorf("height %d: expect coinbase padded to %d bytes, got %d", test.height,
+				consensus.MinTxSize, coinbase.SerializeSize())
+		}
+	}
+}
+
+func TestCoinbaseScriptHeight(t *testing.T) {
+	tests := []struct {
+		height int32
+		prefix []byte
+	}{
+		{1, []byte{0x01, 0x01}},
+		{16, []byte{0x01, 0x10}},
+		{127, []byte{0x01, 0x7f}},
+		{128, []byte{0x02, 0x80, 0x00}},
+		{255, []byte{0x02, 0xff, 0x00}},
+		{256, []byte{0x02, 0x00, 0x01}},
+		{32768, []byte{0x03, 0x00, 0x80, 0x00}},
+		{556767, []byte{0x03, 0xdf, 0x7e, 0x08}},
+	}
+
+	for _, test := range tests {
+		scriptSig := CoinbaseScript(test.height, 0, nil)
+		if !bytes.HasPrefix(scriptSig.GetData(), test.prefix) {
+			t.Errorf("height %d: expect scriptSig to start with %x, got %x", test.height,
+				test.prefix, scriptSig.GetData())
+		}
+	}
+}
+
+func TestCoinbaseScriptMessage(t *testing.T) {
+	scriptSig := CoinbaseScript(556767, 7, []byte("/pool/"))
+	expected := []byte{0x03, 0xdf, 0x7e, 0x08, 0x01, 0x07, '/', 'p', 'o', 'o', 'l', '/'}
+	if !bytes.Equal(scriptSig.GetData(), expected) {
+		t.Errorf("expect scriptSig %x, got %x", expected, scriptSig.GetData())
+	}
+
+	// a long message is cut to keep the scriptSig within the limit
+	scriptSig = CoinbaseScript(556767, 7, bytes.Repeat([]byte{'x'}, 200))
+	if scriptSig.Size() != maxCoinbaseScriptSigSize {
+		t.Errorf("expect scriptSig of %d bytes, got %d", maxCoinbaseScriptSigSize, scriptSig.Size())
+	}
+
+	coinbase := CreateCoinbaseWithMessage(556767, []byte{0x51}, 7, 0, &model.MainNetParams,
+		bytes.Repeat([]byte{'x'}, 200))
+	if err := coinbase.CheckCoinbaseTransaction(); err != nil {
+		t.Errorf("expect valid coinbase, got %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/copernet/copernicus/log"
 	"github.com/copernet/copernicus/logic/lblock"
 	"github.com/copernet/copernicus/logic/lchain"
+	"github.com/copernet/copernicus/logic/lmerkleroot"
 	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
@@ -16,12 +17,9 @@ import (
 	"github.com/copernet/copernicus/model/chain"
 	"github.com/copernet/copernicus/model/consensus"
 	"github.com/copernet/copernicus/model/mempool"
-	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/model/pow"
 	"github.com/copernet/copernicus/model/script"
 	"github.com/copernet/copernicus/model/tx"
-	"github.com/copernet/copernicus/model/txin"
-	"github.com/copernet/copernicus/model/txout"
 	"github.com/copernet/copernicus/model/utxo"
 	"github.com/copernet/copernicus/model/versionbits"
 	"github.com/copernet/copernicus/util"
@@ -78,6 +76,7 @@ type BlockAssembler struct {
 	height                int32
 	lockTimeCutoff        int64
 	chainParams           *model.BitcoinParams
+	extraNonce            uint64
 }
 
 func NewBlockAssembler(params *model.BitcoinParams) *BlockAssembler {
@@ -85,7 +84,7 @@ func NewBlockAssembler(params *model.BitcoinParams) *BlockAssembler {
 	ba.bt = newBlockTemplate()
 	ba.chainParams = params
 	v := conf.Cfg.Mining.BlockMinTxFee
-	ba.blockMinFeeRate = *util.NewFeeRate(v) // todo confirm
+	ba.blockMinFeeRate = *util.NewFeeRatePerK(amount.Amount(v)) // todo confirm
 	ba.maxGeneratedBlockSize = computeMaxGeneratedBlockSize()
 	return ba
 }
@@ -130,15 +129,16 @@ func (ba *BlockAssembler) addToBlock(te *mempool.TxEntry) {
 
 func computeMaxGeneratedBlockSize() uint64 {
 	// Block resource limits
-	// If -blockmaxsize is not given, limit to DEFAULT_MAX_GENERATED_BLOCK_SIZE
-	// If only one is given, only restrict the specified resource.
-	// If both are given, restrict both.
+	// If -blockmaxsize is not given, limit to DefaultMaxGeneratedBlockSize
 	maxGeneratedBlockSize := conf.Cfg.Mining.BlockMaxSize
+	if maxGeneratedBlockSize == 0 {
+		maxGeneratedBlockSize = DefaultMaxGeneratedBlockSize
+	}
 
-	// Limit size to between 1K and MaxBlockSize-1K for sanity:
-	csize := consensus.DefaultMaxBlockSize - 1000
-	if uint64(csize) < maxGeneratedBlockSize {
-		maxGeneratedBlockSize = uint64(csize)
+	// Limit size to between 1K and the excessive block size - 1K for sanity:
+	csize := conf.Cfg.Excessiveblocksize - 1000
+	if csize < maxGeneratedBlockSize {
+		maxGeneratedBlockSize = csize
 	}
 	if 1000 > maxGeneratedBlockSize {
 		maxGeneratedBlockSize = 1000
@@ -236,11 +236,11 @@ func (ba *BlockAssembler) addPackageTxs(sortRecord map[util.Hash]int) int {
 		case sortByFee:
 			// if the current fee lower than the specified min fee rate, stop loop directly.
 			// because the following after this item must be lower than this
-			if packageFee < ba.blockMinFeeRate.GetFee(int(packageSize)) {
+			if amount.Amount(packageFee) < ba.blockMinFeeRate.GetFee(int(packageSize)) {
 				isEnd = true
 			}
 		case sortByFeeRate:
-			currentFeeRate := util.NewFeeRateWithSize(packageFee, packageSize)
+			currentFeeRate := util.NewFeeRate(amount.Amount(packageFee), int(packageSize))
 			if currentFeeRate.Less(ba.blockMinFeeRate) {
 				isEnd = true
 			}
@@ -362,23 +362,12 @@ func (ba *BlockAssembler) CreateNewBlock(scriptPubKey, scriptSig *script.Script)
 	lastBlockTx = ba.blockTx
 	lastBlockSize = ba.blockSize
 
-	// Create coinbase transaction
-	coinbaseTx := tx.NewTx(0, tx.DefaultVersion)
-
-	outPoint := outpoint.OutPoint{Hash: util.HashZero, Index: 0xffffffff}
-
-	coinbaseTx.AddTxIn(txin.NewTxIn(&outPoint, scriptSig, 0xffffffff))
-	coinbaseSerializeSize := coinbaseTx.SerializeSize()
-	if coinbaseSerializeSize < consensus.MinTxSize {
-		byteLen := consensus.MinTxSize - coinbaseSerializeSize - 1
-		scriptSig.PushData(make([]byte, byteLen))
-	}
-
-	// value represents total reward(fee and block generate reward)
-
+	// Create coinbase transaction, value represents total reward(fee and
+	// block generate reward)
 	value := ba.fees + model.GetBlockSubsidy(ba.height, ba.chainParams)
-	coinbaseTx.AddTxOut(txout.NewTxOut(value, scriptPubKey))
+	coinbaseTx := newCoinbaseTx(scriptSig, scriptPubKey, value)
 	ba.bt.Block.Txs[0] = coinbaseTx
+	ba.bt.Block.InvalidateCache()
 	ba.bt.TxFees[0] = -1 * ba.fees // coinbase's fee item is equal to tx fee sum for negative value
 
 	serializeSize := ba.bt.Block.SerializeSize()
@@ -412,6 +401,53 @@ func (ba *BlockAssembler) CreateNewBlock(scriptPubKey, scriptSig *script.Script)
 	return ba.bt
 }
 
+// NewBlock assembles a block on top of the tip paying to payScript, with the
+// merkle root filled in so that it is ready for SolveBlock.
+func (ba *BlockAssembler) NewBlock(payScript []byte) *BlockTemplate {
+	ba.extraNonce = 0
+	height := chain.GetInstance().Tip().Height + 1
+	scriptSig := CoinbaseScript(height, ba.extraNonce, CoinbaseMessage())
+	bt := ba.CreateNewBlock(script.NewScriptRaw(payScript), scriptSig)
+	if bt == nil {
+		return nil
+	}
+	bt.Block.Header.MerkleRoot = lmerkleroot.BlockMerkleRoot(bt.Block.Txs, nil)
+	return bt
+}
+
+// SolveBlock searches a header nonce giving a hash within the target of the
+// block bits, trying at most maxTries hashes. Once the nonces are exhausted
+// the coinbase extra nonce is incremented and the search starts over. The
+// block must come from NewBlock on the same assembler.
+func (ba *BlockAssembler) SolveBlock(bk *block.Block, maxTries uint64) bool {
+	powCheck := pow.Pow{}
+	for tries := uint64(0); tries < maxTries; tries++ {
+		hash := bk.GetHash()
+		if powCheck.CheckProofOfWork(&hash, bk.Header.Bits, ba.chainParams) {
+			return true
+		}
+
+		if bk.Header.Nonce == math.MaxUint32 {
+			ba.incrementExtraNonce(bk)
+			bk.Header.Nonce = 0
+		} else {
+			bk.Header.Nonce++
+		}
+	}
+	return false
+}
+
+// incrementExtraNonce replaces the coinbase with one carrying the next extra
+// nonce, which gives the header a new merkle root to search nonces with.
+func (ba *BlockAssembler) incrementExtraNonce(bk *block.Block) {
+	ba.extraNonce++
+	out := bk.Txs[0].GetTxOut(0)
+	scriptSig := CoinbaseScript(ba.height, ba.extraNonce, CoinbaseMessage())
+	bk.Txs[0] = newCoinbaseTx(scriptSig, out.GetScriptPubKey(), out.GetValue())
+	bk.InvalidateCache()
+	bk.Header.MerkleRoot = bk.ComputeMerkleRoot()
+}
+
 func (ba *BlockAssembler) onlyUnconfirmed(entryList []*mempool.TxEntry) []*mempool.TxEntry {
 	result := make([]*mempool.TxEntry, 0)
 	for _, entry := range entryList {
@@ -483,18 +519,7 @@ func (ba *BlockAssembler) updatePackagesForAdded(txSet mapcontainer.MapContainer
 }
 
 func CoinbaseScriptSig(extraNonce uint) *script.Script {
-	scriptSig := script.NewEmptyScript()
-
-	height := uint64(chain.GetInstance().Tip().Height + 1)
-	heightNum := script.NewScriptNum(int64(height))
-	scriptSig.PushScriptNum(heightNum)
-
-	extraNonceNum := script.NewScriptNum(int64(extraNonce))
-	scriptSig.PushScriptNum(extraNonceNum)
-
-	scriptSig.PushData(append(getExcessiveBlockSizeSig(), []byte(CoinbaseFlag)...))
-
-	return scriptSig
+	return CoinbaseScript(chain.GetInstance().Tip().Height+1, uint64(extraNonce), CoinbaseMessage())
 }
 
 func getExcessiveBlockSizeSig() []byte {
@@ -525,6 +550,24 @@ func UpdateTime(bk *block.Block, indexPrev *blockindex.BlockIndex) int64 {
 	return newTime - oldTime
 }
 
+// CheckProposal validates a block proposed through getblocktemplate (BIP 23)
+// as if it were connected to the tip, without connecting it. It must be
+// called with the chain locked.
+func CheckProposal(bk *block.Block) error {
+	gChain := chain.GetInstance()
+	indexPrev := gChain.FindBlockIndex(bk.Header.HashPrevBlock)
+	if indexPrev == nil || indexPrev.IsInvalid() {
+		return errcode.NewError(errcode.RejectInvalid, "bad-prevblk")
+	}
+
+	// TestBlockValidity only supports blocks built on the current Tip
+	if indexPrev != gChain.Tip() {
+		return errcode.NewError(errcode.RejectInvalid, "inconclusive-not-best-prevblk")
+	}
+
+	return TestBlockValidity(bk, indexPrev, false, true)
+}
+
 func TestBlockValidity(block *block.Block, indexPrev *blockindex.BlockIndex, checkHeader bool, checkMerlke bool) (err error) {
 	if !(indexPrev != nil && indexPrev == chain.GetInstance().Tip()) {
 		log.Error("TestBlockValidity(): indexPrev:%v, chain tip:%v.", indexPrev, chain.GetInstance().Tip())
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/errcode"
+	"github.com/copernet/copernicus/logic/lblock"
 	"github.com/copernet/copernicus/logic/lchain"
 	"github.com/copernet/copernicus/logic/lmerkleroot"
 	"github.com/copernet/copernicus/logic/ltx"
 	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/block"
 	"github.com/copernet/copernicus/model/chain"
 	"github.com/copernet/copernicus/model/mempool"
 	"github.com/copernet/copernicus/model/opcodes"
@@ -471,3 +474,208 @@ func TestCreateNewBlockByFeeRate(t *testing.T) {
 		t.Error("some transactions are inserted to block error")
 	}
 }
+
+func TestSolveBlock(t *testing.T) {
+	chain.Close()
+	tempDir, err := initTestEnv(t, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	mempool.InitMempool()
+
+	ba := NewBlockAssembler(model.ActiveNetParams)
+	bt := ba.NewBlock([]byte{opcodes.OP_TRUE})
+	if bt == nil {
+		t.Fatal("create new block failed")
+	}
+	bk := bt.Block
+	assert.Equal(t, lmerkleroot.BlockMerkleRoot(bk.Txs, nil), bk.Header.MerkleRoot)
+
+	// the regtest target is met by about every other hash
+	if !ba.SolveBlock(bk, 100) {
+		t.Fatal("block not solved within 100 tries")
+	}
+	hash := bk.GetHash()
+	powCheck := pow.Pow{}
+	assert.True(t, powCheck.CheckProofOfWork(&hash, bk.Header.Bits, model.ActiveNetParams))
+
+	fNewBlock := false
+	assert.Nil(t, service.ProcessNewBlock(bk, true, &fNewBlock))
+	assert.Equal(t, int32(1), chain.GetInstance().Height())
+}
+
+func TestSolveBlockRollsExtraNonce(t *testing.T) {
+	chain.Close()
+	tempDir, err := initTestEnv(t, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	mempool.InitMempool()
+
+	ba := NewBlockAssembler(model.ActiveNetParams)
+	bt := ba.NewBlock([]byte{opcodes.OP_TRUE})
+	if bt == nil {
+		t.Fatal("create new block failed")
+	}
+	bk := bt.Block
+	coinbaseHash := bk.Txs[0].GetHash()
+	value := bk.Txs[0].GetValueOut()
+	assert.Equal(t, bk.Header.MerkleRoot, bk.ComputeMerkleRoot())
+
+	ba.incrementExtraNonce(bk)
+	assert.NotEqual(t, coinbaseHash, bk.Txs[0].GetHash())
+	assert.Equal(t, value, bk.Txs[0].GetValueOut())
+	assert.Equal(t, lmerkleroot.BlockMerkleRoot(bk.Txs, nil), bk.Header.MerkleRoot)
+	assert.Equal(t, bk.Header.MerkleRoot, bk.ComputeMerkleRoot())
+	expected := CoinbaseScript(1, 1, CoinbaseMessage()).GetData()
+	assert.Equal(t, expected, bk.Txs[0].GetIns()[0].GetScriptSig().GetData()[:len(expected)])
+
+	// the last nonce rolls over to the next extra nonce
+	bk.Header.Nonce = math.MaxUint32
+	if !ba.SolveBlock(bk, 100) {
+		t.Fatal("block not solved within 100 tries")
+	}
+	if bk.Header.Nonce != math.MaxUint32 {
+		assert.Equal(t, uint64(2), ba.extraNonce)
+	}
+
+	fNewBlock := false
+	assert.Nil(t, service.ProcessNewBlock(bk, true, &fNewBlock))
+	assert.Equal(t, int32(1), chain.GetInstance().Height())
+}
+
+func TestCheckProposal(t *testing.T) {
+	chain.Close()
+	tempDir, err := initTestEnv(t, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	mempool.InitMempool()
+
+	genesisHash := *chain.GetInstance().Tip().GetBlockHash()
+
+	ba := NewBlockAssembler(model.ActiveNetParams)
+	bt := ba.NewBlock([]byte{opcodes.OP_TRUE})
+	if bt == nil {
+		t.Fatal("create new block failed")
+	}
+	assert.Nil(t, CheckProposal(bt.Block))
+
+	// the proposal is checked without connecting the block
+	assert.Equal(t, int32(0), chain.GetInstance().Height())
+
+	badPrev := &block.Block{Header: bt.Block.Header, Txs: bt.Block.Txs}
+	badPrev.Header.HashPrevBlock = util.Hash{1}
+	err = CheckProposal(badPrev)
+	assert.Equal(t, "bad-prevblk", errcode.GetBip22Result(err).(errcode.ProjectError).Desc)
+
+	// once the block is connected, a proposal on genesis is no longer on the tip
+	if !ba.SolveBlock(bt.Block, 100) {
+		t.Fatal("block not solved within 100 tries")
+	}
+	fNewBlock := false
+	assert.Nil(t, service.ProcessNewBlock(bt.Block, true, &fNewBlock))
+
+	stale := &block.Block{Header: badPrev.Header, Txs: bt.Block.Txs}
+	stale.Header.HashPrevBlock = genesisHash
+	err = CheckProposal(stale)
+	assert.Equal(t, "inconclusive-not-best-prevblk", errcode.GetBip22Result(err).(errcode.ProjectError).Desc)
+}
+
+func TestBlockMinTxFee(t *testing.T) {
+	chain.Close()
+	tempDir, err := initTestEnv(t, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	mempool.InitMempool()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(pubKey, 102, 1000000)
+	assert.Nil(t, err)
+	pool := mempool.GetInstance()
+
+	spendCoinbase := func(height int32, fee amount.Amount) *tx.Tx {
+		bk, ok := disk.ReadBlockFromDisk(chain.GetInstance().GetIndex(height), chain.GetInstance().GetParams())
+		assert.True(t, ok)
+		coinbase := bk.Txs[0]
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetValueOut()-fee, pubKey))
+		// pad the tx over the minimum tx size
+		padding := script.NewEmptyScript()
+		padding.PushOpCode(opcodes.OP_RETURN)
+		padding.PushSingleData(make([]byte, 40))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		entry := NewTestMemPoolEntry().SetTime(util.GetTimeSec()).SetFee(fee).SetSpendCoinbase(true).FromTxToEntry(txn)
+		assert.Nil(t, pool.AddTx(entry, entry.ParentTx))
+		return txn
+	}
+	// the txs of about 115 bytes pay about 9 and 8700 satoshis per kB
+	lowFeeTx := spendCoinbase(1, 1)
+	highFeeTx := spendCoinbase(2, 1000)
+
+	defer func(fee int64) { conf.Cfg.Mining.BlockMinTxFee = fee }(conf.Cfg.Mining.BlockMinTxFee)
+	conf.Cfg.Mining.BlockMinTxFee = 1000
+
+	ba := NewBlockAssembler(model.ActiveNetParams)
+	bt := ba.NewBlock([]byte{opcodes.OP_TRUE})
+	if bt == nil {
+		t.Fatal("create new block failed")
+	}
+	hashes := make(map[util.Hash]bool)
+	for _, txn := range bt.Block.Txs {
+		hashes[txn.GetHash()] = true
+	}
+	assert.True(t, hashes[highFeeTx.GetHash()], "tx above blockmintxfee left out")
+	assert.False(t, hashes[lowFeeTx.GetHash()], "tx below blockmintxfee included")
+	assert.Equal(t, 2, len(bt.Block.Txs))
+}
+
+func TestNewBlockCTOR(t *testing.T) {
+	chain.Close()
+	tempDir, err := initTestEnv(t, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	mempool.InitMempool()
+
+	pubKey := script.NewEmptyScript()
+	pubKey.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(pubKey, 106, 1000000)
+	assert.Nil(t, err)
+	pool := mempool.GetInstance()
+
+	gChain := chain.GetInstance()
+	for height := int32(1); height <= 6; height++ {
+		bk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		coinbase := bk.Txs[0]
+		fee := amount.Amount(1000 * int64(height))
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetValueOut()-fee, pubKey))
+		padding := script.NewEmptyScript()
+		padding.PushOpCode(opcodes.OP_RETURN)
+		padding.PushSingleData(make([]byte, 40))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		entry := NewTestMemPoolEntry().SetTime(util.GetTimeSec()).SetFee(fee).SetSpendCoinbase(true).FromTxToEntry(txn)
+		assert.Nil(t, pool.AddTx(entry, entry.ParentTx))
+	}
+
+	ba := NewBlockAssembler(model.ActiveNetParams)
+	bt := ba.NewBlock([]byte{opcodes.OP_TRUE})
+	if bt == nil {
+		t.Fatal("create new block failed")
+	}
+	txs := bt.Block.Txs
+	assert.Equal(t, 7, len(txs))
+	for i := 2; i < len(txs); i++ {
+		prev, cur := txs[i-1].GetHash(), txs[i].GetHash()
+		if pow.HashToBig(&prev).Cmp(pow.HashToBig(&cur)) >= 0 {
+			t.Errorf("transaction %s is after %s in the block", cur, prev)
+		}
+	}
+
+	// The same block out of order is rejected.
+	txs[1], txs[2] = txs[2], txs[1]
+	err = lblock.ContextualCheckBlock(bt.Block, gChain.Tip())
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectInvalid))
+	assert.Contains(t, err.Error(), "tx-ordering")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/copernet/copernicus/util"
 	"github.com/copernet/copernicus/util/algorithm/mapcontainer"
 	"github.com/copernet/copernicus/util/algorithm/mapcontainer/skiplist"
+	"github.com/copernet/copernicus/util/amount"
 )
 
 type sortType int
@@ -54,8 +55,8 @@ type EntryAncestorFeeRateSort mempool.TxEntry
 
 func (r EntryAncestorFeeRateSort) Less(than mapcontainer.Lesser) bool {
 	t := than.(EntryAncestorFeeRateSort)
-	b1 := util.NewFeeRateWithSize((r).SumTxFeeWithAncestors, r.SumTxSizeWitAncestors).SataoshisPerK
-	b2 := util.NewFeeRateWithSize(t.SumTxFeeWithAncestors, t.SumTxSizeWitAncestors).SataoshisPerK
+	b1 := util.NewFeeRate(amount.Amount(r.SumTxFeeWithAncestors), int(r.SumTxSizeWitAncestors)).SataoshisPerK
+	b2 := util.NewFeeRate(amount.Amount(t.SumTxFeeWithAncestors), int(t.SumTxSizeWitAncestors)).SataoshisPerK
 	if b1 == b2 {
 		rHash := r.Tx.GetHash()
 		tHash := t.Tx.GetHash()
@@ -20,12 +20,31 @@ func HandleRejectedTx(txn *tx.Tx, err error, nodeID int64, recentRejects map[uti
 		return
 	}
 
+	if input, ok := errcode.Detail(err, errcode.DetailInput); ok {
+		log.Debug("Rejected tx %s at input %v: %v", txn.GetHash(), input, err)
+	} else {
+		log.Debug("Rejected tx %s: %v", txn.GetHash(), err)
+	}
 	rejectTxs = append(rejectTxs, txn.GetHash())
 	return
 }
 
 func ProcessTransaction(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
-	err := lmempool.AcceptTxToMemPool(txn)
+	return ProcessPeerTransaction(txn, recentRejects, nodeID, false)
+}
+
+// ProcessPeerTransaction is ProcessTransaction for a transaction relayed by a
+// peer. With bypassFee, the transaction is not held to the mempool min fee,
+// only to the min relay fee.
+func ProcessPeerTransaction(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID int64,
+	bypassFee bool) ([]*tx.Tx, []util.Hash, []util.Hash, error) {
+
+	var err error
+	if bypassFee {
+		err = lmempool.AcceptTxToMemPoolBypassFee(txn)
+	} else {
+		err = lmempool.AcceptTxToMemPool(txn)
+	}
 	if err == nil {
 		lmempool.CheckMempool(chain.GetInstance().Height())
 		acceptedOrphans, rejectTxs := lmempool.TryAcceptOrphansTxs(txn, chain.GetInstance().Height(), true)
@@ -39,6 +58,7 @@ func ProcessTransaction(txn *tx.Tx, recentRejects map[util.Hash]struct{}, nodeID
 		return acceptedTxs, nil, rejectTxs, nil
 	}
 
+	err = errcode.WithDetail(err, errcode.DetailTxID, txn.GetHash())
 	missTxs, rejectTxs := HandleRejectedTx(txn, err, nodeID, recentRejects)
 	return nil, missTxs, rejectTxs, err
 }
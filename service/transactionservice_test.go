@@ -3,11 +3,16 @@ package service
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
+	"github.com/copernet/copernicus/conf"
 	"github.com/copernet/copernicus/errcode"
+	"github.com/copernet/copernicus/logic/lmempool"
 	"github.com/copernet/copernicus/logic/lmerkleroot"
 	"github.com/copernet/copernicus/model"
 	"github.com/copernet/copernicus/model/block"
 	"github.com/copernet/copernicus/model/chain"
+	"github.com/copernet/copernicus/model/consensus"
+	"github.com/copernet/copernicus/model/mempool"
 	"github.com/copernet/copernicus/model/opcodes"
 	"github.com/copernet/copernicus/model/outpoint"
 	"github.com/copernet/copernicus/model/pow"
@@ -18,6 +23,7 @@ import (
 	"github.com/copernet/copernicus/persist/disk"
 	"github.com/copernet/copernicus/service/mining"
 	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/amount"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"math"
@@ -140,6 +146,11 @@ func TestProcessTransactionNormal(t *testing.T) {
 	transaction.AddTxOut(txOut)
 	transaction.AddTxOut(txOut)
 
+	// The transaction pays almost all of the coinbase as fee.
+	maxTxFee := conf.Cfg.Mempool.MaxTxFee
+	defer func() { conf.Cfg.Mempool.MaxTxFee = maxTxFee }()
+	conf.Cfg.Mempool.MaxTxFee = 0
+
 	nodeID := int64(0)
 	recentRejects := make(map[util.Hash]struct{})
 	acceptedTxs, missTxHash, rejectTxHash, err := ProcessTransaction(transaction, recentRejects, nodeID)
@@ -163,10 +174,301 @@ func TestProcessTransactionNormal(t *testing.T) {
 	tscaOrphan.AddTxOut(txOut)
 
 	acceptedTxs, missTxHash, rejectTxHash, err = ProcessTransaction(tscaOrphan, recentRejects, nodeID)
-	assert.Equal(t, errcode.New(errcode.TxErrNoPreviousOut), err)
+	assert.True(t, errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut))
+	assert.Equal(t, errcode.New(errcode.TxErrNoPreviousOut).Error(), err.Error())
+	txid, ok := errcode.Detail(err, errcode.DetailTxID)
+	assert.True(t, ok)
+	assert.Equal(t, tscaOrphan.GetHash(), txid)
 	assert.Equal(t, 1, len(missTxHash))
 	assert.Empty(t, acceptedTxs)
 	assert.Empty(t, rejectTxHash)
 
 	defer os.RemoveAll(testDir)
 }
+
+func TestOrphanAcceptedWhenParentConfirmed(t *testing.T) {
+	chain.Close()
+	testDir, err := initTestEnv(t, []string{"--regtest"}, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	gChain := chain.GetInstance()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(opTrue, 101, 1000000)
+	assert.Nil(t, err)
+
+	block1, ok := disk.ReadBlockFromDisk(gChain.GetIndex(1), gChain.GetParams())
+	assert.True(t, ok)
+	coinbase := block1.Txs[0]
+
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	// spend returns a transaction spending the outputs, paying a fee of
+	// 100000 per input out of the coinbase value.
+	spend := func(fee int, prevOuts ...*outpoint.OutPoint) *tx.Tx {
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		for _, prevOut := range prevOuts {
+			txn.AddTxIn(txin.NewTxIn(prevOut, script.NewEmptyScript(), math.MaxUint32-1))
+		}
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetTxOut(0).GetValue()-100000*amount.Amount(fee), opTrue))
+		// Pad the transaction over the minimum transaction size.
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		return txn
+	}
+	parent := spend(1, outpoint.NewOutPoint(coinbase.GetHash(), 0))
+	child := spend(2, outpoint.NewOutPoint(parent.GetHash(), 0))
+	grandchild := spend(3, outpoint.NewOutPoint(child.GetHash(), 0))
+	// conflicting spends the coinbase output spent by parent, and an output
+	// that does not exist.
+	conflicting := spend(2, outpoint.NewOutPoint(coinbase.GetHash(), 0), outpoint.NewOutPoint(util.Hash{1}, 0))
+
+	recentRejects := make(map[util.Hash]struct{})
+	_, missTxs, _, err := ProcessTransaction(child, recentRejects, 1)
+	assert.True(t, errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut))
+	assert.Equal(t, []util.Hash{parent.GetHash()}, missTxs)
+	_, missTxs, _, err = ProcessTransaction(grandchild, recentRejects, 1)
+	assert.True(t, errcode.IsErrorCode(err, errcode.TxErrNoPreviousOut))
+	assert.Equal(t, []util.Hash{child.GetHash()}, missTxs)
+	mempool.GetInstance().AddOrphanTx(conflicting, 1)
+
+	// The parent is confirmed without being relayed to us.
+	assert.Nil(t, lmempool.AcceptTxToMemPool(parent))
+	_, err = generateBlocks(opTrue, 1, 1000000)
+	assert.Nil(t, err)
+	tip, ok := disk.ReadBlockFromDisk(gChain.Tip(), gChain.GetParams())
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(tip.Txs))
+	assert.Equal(t, parent.GetHash(), tip.Txs[1].GetHash())
+
+	accepted := lmempool.ProcessOrphansForBlock(tip.Txs[1:], gChain.Height())
+	assert.Equal(t, []*tx.Tx{child, grandchild}, accepted)
+
+	pool := mempool.GetInstance()
+	assert.True(t, pool.IsTransactionInPool(child))
+	assert.True(t, pool.IsTransactionInPool(grandchild))
+	assert.False(t, pool.IsOrphanInPool(conflicting))
+	assert.Equal(t, 0, pool.OrphanCount())
+
+	// The child is confirmed in turn, without going through the mempool of
+	// the miner first. The grandchild is accepted from the orphan pool
+	// rather than dropped with the confirmed orphan.
+	pool.RemoveTxSelf([]*tx.Tx{child, grandchild})
+	pool.AddOrphanTx(child, 1)
+	pool.AddOrphanTx(grandchild, 1)
+	assert.Nil(t, lmempool.AcceptTxToMemPool(child))
+	_, err = generateBlocks(opTrue, 1, 1000000)
+	assert.Nil(t, err)
+	tip, ok = disk.ReadBlockFromDisk(gChain.Tip(), gChain.GetParams())
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(tip.Txs))
+	assert.Equal(t, child.GetHash(), tip.Txs[1].GetHash())
+
+	accepted = lmempool.ProcessOrphansForBlock(tip.Txs[1:], gChain.Height())
+	assert.Equal(t, []*tx.Tx{grandchild}, accepted)
+	assert.True(t, pool.IsTransactionInPool(grandchild))
+	assert.False(t, pool.IsOrphanInPool(child))
+	assert.Equal(t, 0, pool.OrphanCount())
+}
+
+func TestProcessPeerTransactionBypassFee(t *testing.T) {
+	chain.Close()
+	testDir, err := initTestEnv(t, []string{"--regtest"}, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	gChain := chain.GetInstance()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(opTrue, 102, 1000000)
+	assert.Nil(t, err)
+
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	spendCoinbase := func(height int32, fee amount.Amount) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		coinbase := blk.Txs[0]
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetTxOut(0).GetValue()-fee, opTrue))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		return txn
+	}
+
+	// Trimming a well paying transaction out of a full mempool raises the
+	// mempool min fee over the fee of lowFee.
+	pool := mempool.GetInstance()
+	maxPoolSize := conf.Cfg.Mempool.MaxPoolSize
+	conf.Cfg.Mempool.MaxPoolSize = 1
+	lmempool.AcceptTxToMemPool(spendCoinbase(1, 1000000))
+	conf.Cfg.Mempool.MaxPoolSize = maxPoolSize
+	assert.Equal(t, 0, pool.Size())
+
+	lowFee := spendCoinbase(2, 1000)
+	recentRejects := make(map[util.Hash]struct{})
+	_, _, _, err = ProcessPeerTransaction(lowFee, recentRejects, 1, false)
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectInsufficientFee))
+	assert.False(t, pool.IsTransactionInPool(lowFee))
+
+	// The same transaction relayed by a whitelisted peer is accepted.
+	accepted, _, _, err := ProcessPeerTransaction(lowFee, recentRejects, 1, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*tx.Tx{lowFee}, accepted)
+	assert.True(t, pool.IsTransactionInPool(lowFee))
+
+	// Whitelisted peers still have to pay the min relay fee.
+	noFee := spendCoinbase(3, 0)
+	_, _, _, err = ProcessPeerTransaction(noFee, recentRejects, 1, true)
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectInsufficientFee))
+	assert.False(t, pool.IsTransactionInPool(noFee))
+}
+
+func TestAcceptTxToMemPoolCoinbaseMaturity(t *testing.T) {
+	chain.Close()
+	testDir, err := initTestEnv(t, []string{"--regtest"}, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	gChain := chain.GetInstance()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(opTrue, consensus.CoinbaseMaturity, 1000000)
+	assert.Nil(t, err)
+
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	spendCoinbase := func(height int32) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		coinbase := blk.Txs[0]
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetTxOut(0).GetValue()-100000, opTrue))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		return txn
+	}
+
+	// The coinbase of block 2 has 99 confirmations, and would have 100 in
+	// the next block.
+	immature := spendCoinbase(2)
+	err = lmempool.AcceptTxToMemPool(immature)
+	assert.Equal(t, errcode.NewError(errcode.RejectInvalid, "bad-txns-premature-spend-of-coinbase"), err)
+	assert.False(t, mempool.GetInstance().IsTransactionInPool(immature))
+
+	// The coinbase of block 1 matures in the next block.
+	assert.Nil(t, lmempool.AcceptTxToMemPool(spendCoinbase(1)))
+
+	_, err = generateBlocks(opTrue, 1, 1000000)
+	assert.Nil(t, err)
+	assert.Nil(t, lmempool.AcceptTxToMemPool(immature))
+	assert.True(t, mempool.GetInstance().IsTransactionInPool(immature))
+}
+
+func TestAcceptTxToMemPoolMaxTxFee(t *testing.T) {
+	chain.Close()
+	testDir, err := initTestEnv(t, []string{"--regtest"}, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	gChain := chain.GetInstance()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(opTrue, 104, 1000000)
+	assert.Nil(t, err)
+
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	spendCoinbase := func(height int32, fee amount.Amount) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		coinbase := blk.Txs[0]
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetTxOut(0).GetValue()-fee, opTrue))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		return txn
+	}
+
+	maxTxFee := conf.Cfg.Mempool.MaxTxFee
+	defer func() { conf.Cfg.Mempool.MaxTxFee = maxTxFee }()
+	conf.Cfg.Mempool.MaxTxFee = 1000000
+
+	assert.Nil(t, lmempool.AcceptTxToMemPool(spendCoinbase(1, 1000000)))
+
+	overMax := spendCoinbase(2, 1000001)
+	err = lmempool.AcceptTxToMemPool(overMax)
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectHighFee))
+	assert.Equal(t, errcode.NewError(errcode.RejectHighFee, "absurdly-high-fee, 1000001 > 1000000"), err)
+	assert.False(t, mempool.GetInstance().IsTransactionInPool(overMax))
+
+	// The limit is not lifted for whitelisted peers.
+	err = lmempool.AcceptTxToMemPoolBypassFee(overMax)
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectHighFee))
+
+	// sendrawtransaction with allowhighfees does not limit the fee.
+	assert.Nil(t, lmempool.AcceptTxToMemPoolWithMaxFee(overMax, 0))
+
+	// Nor does a -maxtxfee of 0.
+	conf.Cfg.Mempool.MaxTxFee = 0
+	assert.Nil(t, lmempool.AcceptTxToMemPool(spendCoinbase(3, 100*1000000)))
+}
+
+func TestAcceptTxToMemPoolRelayFees(t *testing.T) {
+	chain.Close()
+	testDir, err := initTestEnv(t, []string{"--regtest", "--minrelaytxfee=0.00002", "--incrementalrelayfee=0.00005"}, false)
+	assert.Nil(t, err)
+	defer os.RemoveAll(testDir)
+	gChain := chain.GetInstance()
+
+	opTrue := script.NewEmptyScript()
+	opTrue.PushOpCode(opcodes.OP_TRUE)
+	_, err = generateBlocks(opTrue, 104, 1000000)
+	assert.Nil(t, err)
+
+	padding := script.NewEmptyScript()
+	padding.PushOpCode(opcodes.OP_RETURN)
+	padding.PushSingleData(make([]byte, 40))
+	spendCoinbase := func(height int32, fee amount.Amount) *tx.Tx {
+		blk, ok := disk.ReadBlockFromDisk(gChain.GetIndex(height), gChain.GetParams())
+		assert.True(t, ok)
+		coinbase := blk.Txs[0]
+		txn := tx.NewTx(0, tx.DefaultVersion)
+		txn.AddTxIn(txin.NewTxIn(outpoint.NewOutPoint(coinbase.GetHash(), 0), script.NewEmptyScript(), math.MaxUint32-1))
+		txn.AddTxOut(txout.NewTxOut(coinbase.GetTxOut(0).GetValue()-fee, opTrue))
+		txn.AddTxOut(txout.NewTxOut(0, padding))
+		return txn
+	}
+	size := int(spendCoinbase(1, 0).GetVirtualSize())
+
+	pool := mempool.GetInstance()
+	assert.Equal(t, *util.NewFeeRatePerK(2000), pool.MinRelayTxFee())
+	assert.Equal(t, *util.NewFeeRatePerK(5000), pool.IncrementalRelayFee())
+
+	// A transaction paying exactly -minrelaytxfee is accepted, one satoshi
+	// less is not.
+	relayFee := util.NewFeeRatePerK(2000).GetFee(size)
+	err = lmempool.AcceptTxToMemPool(spendCoinbase(1, relayFee-1))
+	assert.Equal(t, errcode.NewError(errcode.RejectInsufficientFee,
+		fmt.Sprintf("min relay fee not met %d < %d", relayFee-1, relayFee)), err)
+	assert.Nil(t, lmempool.AcceptTxToMemPool(spendCoinbase(1, relayFee)))
+
+	// Evicting a package from a full mempool raises the mempool min fee
+	// -incrementalrelayfee over its fee rate, which the next transaction
+	// must pay.
+	maxPoolSize := conf.Cfg.Mempool.MaxPoolSize
+	conf.Cfg.Mempool.MaxPoolSize = 1
+	lmempool.AcceptTxToMemPool(spendCoinbase(2, 100000))
+	conf.Cfg.Mempool.MaxPoolSize = maxPoolSize
+	assert.Equal(t, 0, pool.Size())
+
+	minFee := util.NewFeeRatePerK(amount.Amount(util.NewFeeRate(100000, size).SataoshisPerK + 5000))
+	assert.Equal(t, *minFee, pool.GetMinFeeRate())
+	err = lmempool.AcceptTxToMemPool(spendCoinbase(3, minFee.GetFee(size)-1))
+	assert.True(t, errcode.IsErrorCode(err, errcode.RejectInsufficientFee))
+	assert.Nil(t, lmempool.AcceptTxToMemPool(spendCoinbase(3, minFee.GetFee(size))))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/copernet/copernicus/conf"
+	"github.com/copernet/copernicus/log"
+	"github.com/copernet/copernicus/logic/lindex"
+	"github.com/copernet/copernicus/model/mempool"
+	"github.com/copernet/copernicus/net/server"
+	"github.com/copernet/copernicus/persist"
+	"github.com/copernet/copernicus/persist/disk"
+	"github.com/copernet/copernicus/rpc"
+)
+
+// shutdownHooks are the steps of a clean shutdown, run in order by
+// shutdownNode. A nil hook is skipped.
+type shutdownHooks struct {
+	// stopMiner stops the internal miner, it must not build on the chain
+	// state being flushed.
+	stopMiner func()
+
+	// stopRPC stops accepting RPC connections and requests.
+	stopRPC func()
+
+	// stopP2P disconnects the peers, stops accepting new ones and saves the
+	// address manager once the peer handlers are done.
+	stopP2P func()
+
+	// stopIndexes stops the optional indexes.
+	stopIndexes func()
+
+	// flushChainState writes the UTXO cache and the block index to disk.
+	flushChainState func() error
+
+	// saveMempool writes the mempool to mempool.dat.
+	saveMempool func() error
+
+	// releaseDataDir releases the lock of the data directory, last, once
+	// nothing writes to it anymore.
+	releaseDataDir func()
+}
+
+// shutdownNode runs the shutdown hooks in order. The chain state is flushed
+// while holding cs_main, so a block being validated is connected or dropped
+// as a whole before it is written out.
+func shutdownNode(hooks *shutdownHooks) {
+	log.Info("Shutdown in progress...")
+	for _, stop := range []func(){hooks.stopMiner, hooks.stopRPC, hooks.stopP2P, hooks.stopIndexes} {
+		if stop != nil {
+			stop()
+		}
+	}
+
+	if hooks.flushChainState != nil {
+		persist.CsMain.Lock()
+		err := hooks.flushChainState()
+		persist.CsMain.Unlock()
+		if err != nil {
+			log.Error("Failed to flush the chain state: %v", err)
+		}
+	}
+
+	if hooks.saveMempool != nil {
+		if err := hooks.saveMempool(); err != nil {
+			log.Error("Failed to save the mempool: %v", err)
+		}
+	}
+
+	if hooks.releaseDataDir != nil {
+		hooks.releaseDataDir()
+	}
+	log.Info("Shutdown done")
+}
+
+// nodeShutdownHooks returns the shutdown hooks of the node made of the P2P
+// server s and the RPC server rpcServer, nil when RPC is disabled.
+func nodeShutdownHooks(s *server.Server, rpcServer *rpc.Server) *shutdownHooks {
+	hooks := &shutdownHooks{
+		stopMiner: rpc.StopCPUMiner,
+		stopP2P: func() {
+			s.Stop()
+			s.WaitForShutdown()
+		},
+		stopIndexes: func() {
+			lindex.StopTxIndex()
+			lindex.StopBlockFilterIndex()
+		},
+		flushChainState: func() error {
+			pool := mempool.GetInstance()
+			mempoolSizeMax := int64(persist.DefaultMaxMemPoolSize) * 1000000
+			return disk.FlushStateToDisk(disk.FlushStateAlways, 0, pool.GetPoolUsage(), mempoolSizeMax)
+		},
+		saveMempool: func() error {
+			pool := mempool.GetInstance()
+			// A mempool still loading would overwrite the saved one with
+			// part of it.
+			if !pool.IsLoaded() {
+				return nil
+			}
+			return pool.Dump(filepath.Join(conf.DataDir, mempool.DumpFileName))
+		},
+	}
+	if dataDirLock != nil {
+		hooks.releaseDataDir = func() {
+			dataDirLock.Release()
+		}
+	}
+	if rpcServer != nil {
+		hooks.stopRPC = func() {
+			rpcServer.Stop()
+		}
+	}
+	return hooks
+}
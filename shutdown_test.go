@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/copernet/copernicus/persist"
+)
+
+func TestStopShutsDownInOrder(t *testing.T) {
+	var lock sync.Mutex
+	var steps []string
+	record := func(step string) {
+		lock.Lock()
+		steps = append(steps, step)
+		lock.Unlock()
+	}
+	hooks := &shutdownHooks{
+		stopMiner:   func() { record("miner") },
+		stopRPC:     func() { record("rpc") },
+		stopP2P:     func() { record("p2p") },
+		stopIndexes: func() { record("indexes") },
+		flushChainState: func() error {
+			record("flush")
+			return nil
+		},
+		saveMempool: func() error {
+			record("mempool")
+			return nil
+		},
+		releaseDataDir: func() { record("datadir") },
+	}
+
+	// The stop RPC sends on the RPC server's shutdown request channel.
+	interrupt := interruptListener()
+	stopRequests := make(chan struct{}, 1)
+	go forwardShutdownRequest(stopRequests)
+
+	// A block is being validated when stop comes in.
+	persist.CsMain.Lock()
+	validated := make(chan struct{})
+	go func() {
+		<-interrupt
+		time.Sleep(50 * time.Millisecond)
+		record("validation")
+		persist.CsMain.Unlock()
+		close(validated)
+	}()
+
+	stopRequests <- struct{}{}
+	select {
+	case <-interrupt:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop did not interrupt the node")
+	}
+	shutdownNode(hooks)
+	<-validated
+
+	want := []string{"miner", "rpc", "p2p", "indexes", "validation", "flush", "mempool", "datadir"}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("shutdown steps %v, want %v", steps, want)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 )
 
 // shutdownRequestChannel is used to initiate shutdown from one of the
@@ -19,6 +20,9 @@ var shutdownRequestChannel = make(chan struct{})
 // shutdown.  This may be modified during init depending on the platform.
 var interruptSignals = []os.Signal{os.Interrupt}
 
+// reloadSignals are the signals that reload the configuration.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
 // interruptListener listens for OS Signals such as SIGINT (Ctrl+C) and shutdown
 // requests from shutdownRequestChannel.  It returns a channel that is closed
 // when either signal is received.
@@ -49,6 +53,13 @@ func interruptListener() <-chan struct{} {
 	return c
 }
 
+// forwardShutdownRequest waits for a shutdown request from requests, such as
+// the one sent by the stop RPC, and passes it on to shutdownRequestChannel.
+func forwardShutdownRequest(requests <-chan struct{}) {
+	<-requests
+	shutdownRequestChannel <- struct{}{}
+}
+
 // interruptRequested returns true when the channel returned by
 // interruptListener was closed.  This simplifies early shutdown slightly since
 // the caller can just use an if statement instead of a select.
@@ -61,3 +72,14 @@ func interruptRequested(interrupted <-chan struct{}) bool {
 
 	return false
 }
+
+// reloadListener calls reload each time one of reloadSignals is received.
+func reloadListener(reload func()) {
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, reloadSignals...)
+
+	for sig := range reloadChannel {
+		log.Info("Received signal (%s).  reloading the configuration...", sig)
+		reload()
+	}
+}
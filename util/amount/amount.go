@@ -8,8 +8,7 @@ import (
 	"errors"
 	"math"
 	"strconv"
-
-	"github.com/copernet/copernicus/util"
+	"strings"
 )
 
 // Unit describes a method of converting an Amount to something
@@ -29,6 +28,12 @@ const (
 	AmountSatoshi  Unit = -8
 
 	CENT int64 = 1000000
+
+	// SatoshiPerBitcoin is the number of satoshi in one bitcoin (1 BTC).
+	SatoshiPerBitcoin = 1e8
+
+	// MaxSatoshi is the maximum transaction amount allowed in satoshi.
+	MaxSatoshi = 21e6 * SatoshiPerBitcoin
 )
 
 // String returns the unit as a string.  For recognized units, the SI
@@ -53,6 +58,19 @@ func (u Unit) String() string {
 	}
 }
 
+var (
+	// ErrInvalidAmount describes a string that is not a decimal amount of
+	// bitcoin with at most 8 decimal places.
+	ErrInvalidAmount = errors.New("invalid bitcoin amount")
+
+	// ErrAmountOutOfRange describes an amount that is negative or above the
+	// total amount of bitcoin.
+	ErrAmountOutOfRange = errors.New("bitcoin amount out of range")
+)
+
+// decimals is the number of decimal places of an amount of bitcoin.
+const decimals = 8
+
 // Amount represents the base bitcoin monetary unit (colloquially referred
 // to as a `Satoshi').  A single Amount is equal to 1e-8 of a bitcoin.
 type Amount int64
@@ -89,7 +107,7 @@ func NewAmount(f float64) (Amount, error) {
 		return 0, errors.New("invalid bitcoin amount")
 	}
 
-	return round(f * util.SatoshiPerBitcoin), nil
+	return round(f * SatoshiPerBitcoin), nil
 }
 
 // ToUnit converts a monetary amount counted in bitcoin base units to a
@@ -112,9 +130,66 @@ func (a Amount) Format(u Unit) string {
 	return strconv.FormatFloat(a.ToUnit(u), 'f', -int(u+8), 64) + units
 }
 
-// String is the equivalent of calling Format with AmountBTC.
+// String returns the amount in bitcoin with exactly 8 decimal places, such
+// as "0.00000001", which ParseAmount reads back.
 func (a Amount) String() string {
-	return a.Format(AmountBTC)
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+	}
+	quotient := v / SatoshiPerBitcoin
+	remainder := v % SatoshiPerBitcoin
+	if remainder < 0 {
+		quotient, remainder = -quotient, -remainder
+	}
+	frac := strconv.FormatInt(remainder, 10)
+	return sign + strconv.FormatUint(uint64(quotient), 10) + "." +
+		strings.Repeat("0", decimals-len(frac)) + frac
+}
+
+// ParseAmount parses a decimal amount of bitcoin, such as "0.00000001" or
+// "21000000", into an Amount. The amount can have at most 8 decimal places
+// and must be within 0 and the total amount of bitcoin. Signs, exponents and
+// anything but digits and a single decimal point are rejected.
+func ParseAmount(s string) (Amount, error) {
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+		if fracPart == "" {
+			return 0, ErrInvalidAmount
+		}
+	}
+	if intPart == "" || len(fracPart) > decimals || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidAmount
+	}
+
+	// Anything longer than the 8 digits of the total amount of bitcoin is
+	// out of range, and cutting it here keeps the arithmetic from overflowing.
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > decimals {
+		return 0, ErrAmountOutOfRange
+	}
+	var whole int64
+	if intPart != "" {
+		whole, _ = strconv.ParseInt(intPart, 10, 64)
+	}
+	frac, _ := strconv.ParseInt(fracPart+strings.Repeat("0", decimals-len(fracPart)), 10, 64)
+
+	a := Amount(whole*SatoshiPerBitcoin + frac)
+	if !MoneyRange(a) {
+		return 0, ErrAmountOutOfRange
+	}
+	return a, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // MulF64 multiplies an Amount by a floating point value.  While this is not
@@ -126,5 +201,5 @@ func (a Amount) MulF64(f float64) Amount {
 }
 
 func MoneyRange(value Amount) bool {
-	return value >= 0 && value <= Amount(util.MaxMoney)
+	return value >= 0 && value <= Amount(MaxSatoshi)
 }
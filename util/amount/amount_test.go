@@ -7,8 +7,6 @@ package amount
 import (
 	"math"
 	"testing"
-
-	. "github.com/copernet/copernicus/util"
 )
 
 func TestAmountCreation(t *testing.T) {
@@ -191,12 +189,67 @@ func TestAmountUnitConversions(t *testing.T) {
 		if f1 != f2 {
 			t.Errorf("%v: ToBTC does not match ToUnit(AmountBTC): %v != %v", test.name, f1, f2)
 		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		s      string
+	}{
+		{0, "0.00000000"},
+		{1, "0.00000001"},
+		{100000000, "1.00000000"},
+		{44433322211100, "444333.22211100"},
+		{MaxSatoshi, "21000000.00000000"},
+		{-1, "-0.00000001"},
+		{-150000000, "-1.50000000"},
+	}
+
+	for _, test := range tests {
+		if s := test.amount.String(); s != test.s {
+			t.Errorf("String of %d is %q, expected %q", int64(test.amount), s, test.s)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Amount
+		err      error
+	}{
+		{"0.00000001", 1, nil},
+		{"21000000", MaxSatoshi, nil},
+		{"21000000.00000000", MaxSatoshi, nil},
+		{"0", 0, nil},
+		{"1.5", 150000000, nil},
+		{"0001.10", 110000000, nil},
+		{"21000000.00000001", 0, ErrAmountOutOfRange},
+		{"100000000", 0, ErrAmountOutOfRange},
+		{"99999999999999999999", 0, ErrAmountOutOfRange},
+		{"1.234e5", 0, ErrInvalidAmount},
+		{"0.000000001", 0, ErrInvalidAmount},
+		{"-1", 0, ErrInvalidAmount},
+		{"+1", 0, ErrInvalidAmount},
+		{"", 0, ErrInvalidAmount},
+		{".5", 0, ErrInvalidAmount},
+		{"1.", 0, ErrInvalidAmount},
+		{"1.2.3", 0, ErrInvalidAmount},
+		{" 1", 0, ErrInvalidAmount},
+	}
 
-		// Verify that Amount.String works as advertised.
-		s1 := test.amount.Format(AmountBTC)
-		s2 := test.amount.String()
-		if s1 != s2 {
-			t.Errorf("%v: String does not match Format(AmountBitcoin): %v != %v", test.name, s1, s2)
+	for _, test := range tests {
+		a, err := ParseAmount(test.s)
+		if err != test.err {
+			t.Errorf("ParseAmount(%q) error %v, expected %v", test.s, err, test.err)
+			continue
+		}
+		if a != test.expected {
+			t.Errorf("ParseAmount(%q) = %d, expected %d", test.s, int64(a), int64(test.expected))
+		}
+		if b, _ := ParseAmount(a.String()); b != a {
+			t.Errorf("%s does not parse back to %d", a.String(), int64(a))
 		}
 	}
 }
@@ -4,15 +4,17 @@
 
 package util
 
+import "github.com/copernet/copernicus/util/amount"
+
 const (
 	// SatoshiPerBitcent is the number of satoshi in one bitcoin cent.
 	SatoshiPerBitcent = 1e6
 
 	// SatoshiPerBitcoin is the number of satoshi in one bitcoin (1 BTC).
-	SatoshiPerBitcoin = 1e8
+	SatoshiPerBitcoin = amount.SatoshiPerBitcoin
 
 	// MaxSatoshi is the maximum transaction amount allowed in satoshi.
-	MaxSatoshi = 21e6 * SatoshiPerBitcoin
+	MaxSatoshi = amount.MaxSatoshi
 
 	/*OneMegaByte 1MB */
 	OneMegaByte uint64 = 1000000
@@ -0,0 +1,78 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inputCharset lists the characters a descriptor may contain. Their position
+// feeds the checksum in groups of 32, so that case errors and other common
+// typos only change a single symbol.
+const inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+// checksumCharset is the bech32 character set the checksum is written in.
+const checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// polyMod computes the BCH code of the checksum over GF(32), one symbol c at
+// a time.
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// Checksum returns the eight character checksum of the descriptor desc,
+// which must not include a checksum itself.
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos < 0 {
+			return "", fmt.Errorf("invalid character '%c' in descriptor", desc[i])
+		}
+		// emit a symbol for the position inside the group, for every character
+		c = polyMod(c, pos&31)
+		// accumulate the group numbers
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			// emit an extra symbol representing the group numbers, for every
+			// 3 characters
+			c = polyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+	// shift further to determine the checksum
+	for j := 0; j < checksumLength; j++ {
+		c = polyMod(c, 0)
+	}
+	// prevent appending zeroes from not affecting the checksum
+	c ^= 1
+
+	var sum [checksumLength]byte
+	for j := 0; j < checksumLength; j++ {
+		sum[j] = checksumCharset[(c>>(5*uint(7-j)))&31]
+	}
+	return string(sum[:]), nil
+}
@@ -0,0 +1,339 @@
+// Package descriptor parses output script descriptors, the language
+// describing the scriptPubKeys a wallet or a UTXO scan is interested in.
+//
+// The supported forms are pkh(KEY), multi(k,KEY,...), sh(pkh(KEY)),
+// sh(multi(k,KEY,...)), addr(ADDRESS) and raw(HEX). A KEY is a hex encoded
+// public key or a WIF encoded private key. A descriptor may carry an eight
+// character checksum after a '#', which is verified when present.
+package descriptor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/model"
+	"github.com/copernet/copernicus/model/opcodes"
+	"github.com/copernet/copernicus/model/script"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/cashaddr"
+	"github.com/copernet/copernicus/util/wif"
+)
+
+const (
+	// maxMultiSigKeys is the number of keys a multi() descriptor may hold,
+	// bounded by the largest small integer opcode.
+	maxMultiSigKeys = 16
+
+	// checksumLength is the number of characters of a descriptor checksum.
+	checksumLength = 8
+)
+
+var (
+	// ErrChecksumMismatch is returned when the checksum of a descriptor does
+	// not match its content.
+	ErrChecksumMismatch = errors.New("descriptor checksum mismatch")
+
+	// ErrMissingChecksum is returned when a checksum is required but the
+	// descriptor has none.
+	ErrMissingChecksum = errors.New("missing checksum")
+)
+
+// context tells where in a descriptor an expression appears, as some forms
+// are only valid at the top level.
+type context int
+
+const (
+	contextTop context = iota
+	contextP2SH
+)
+
+// Descriptor is a parsed output script descriptor.
+type Descriptor struct {
+	desc           string // canonical form, with public keys only
+	scriptPubKey   *script.Script
+	solvable       bool
+	hasPrivateKeys bool
+}
+
+// expression is the result of parsing a descriptor expression.
+type expression struct {
+	script         []byte
+	desc           string
+	hasPrivateKeys bool
+}
+
+// Parse parses the descriptor desc and verifies its checksum if it has one.
+// When requireChecksum is set, a descriptor without a checksum is rejected.
+// Addresses and WIF keys must belong to the active network.
+func Parse(desc string, requireChecksum bool) (*Descriptor, error) {
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		sum := desc[i+1:]
+		desc = desc[:i]
+		if len(sum) != checksumLength {
+			return nil, fmt.Errorf("expected %d character checksum, not %d characters", checksumLength, len(sum))
+		}
+		expected, err := Checksum(desc)
+		if err != nil {
+			return nil, err
+		}
+		if sum != expected {
+			return nil, ErrChecksumMismatch
+		}
+	} else if requireChecksum {
+		return nil, ErrMissingChecksum
+	} else if _, err := Checksum(desc); err != nil {
+		return nil, err
+	}
+
+	expr, err := parseScript(desc, contextTop)
+	if err != nil {
+		return nil, err
+	}
+	name, _, _ := splitFunc(desc)
+	return &Descriptor{
+		desc:           expr.desc,
+		scriptPubKey:   script.NewScriptRaw(expr.script),
+		solvable:       name != "addr" && name != "raw",
+		hasPrivateKeys: expr.hasPrivateKeys,
+	}, nil
+}
+
+// ScriptPubKeys returns the scriptPubKeys the descriptor stands for.
+func (d *Descriptor) ScriptPubKeys() []*script.Script {
+	return []*script.Script{d.scriptPubKey}
+}
+
+// IsRange returns whether the descriptor expands to a range of scripts.
+// Ranged extended key descriptors are not supported yet, so this is always
+// false.
+func (d *Descriptor) IsRange() bool {
+	return false
+}
+
+// IsSolvable returns whether the descriptor holds everything needed to
+// spend its outputs but the private keys, which addr() and raw() do not.
+func (d *Descriptor) IsSolvable() bool {
+	return d.solvable
+}
+
+// HasPrivateKeys returns whether the descriptor was given private keys.
+func (d *Descriptor) HasPrivateKeys() bool {
+	return d.hasPrivateKeys
+}
+
+// String returns the canonical form of the descriptor, with public keys in
+// place of private keys, and its checksum appended.
+func (d *Descriptor) String() string {
+	sum, _ := Checksum(d.desc)
+	return d.desc + "#" + sum
+}
+
+// splitFunc splits expr of the form name(args) into name and args.
+func splitFunc(expr string) (string, string, bool) {
+	open := strings.IndexByte(expr, '(')
+	if open <= 0 || !strings.HasSuffix(expr, ")") {
+		return "", "", false
+	}
+	return expr[:open], expr[open+1 : len(expr)-1], true
+}
+
+// parseScript parses the expression expr appearing in ctx.
+func parseScript(expr string, ctx context) (*expression, error) {
+	name, args, ok := splitFunc(expr)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a valid descriptor function", expr)
+	}
+
+	switch name {
+	case "pkh":
+		pubKey, key, isPrivate, err := parseKey(args)
+		if err != nil {
+			return nil, err
+		}
+		data, err := payToPubKeyHash(util.Hash160(pubKey))
+		if err != nil {
+			return nil, err
+		}
+		return &expression{script: data, desc: "pkh(" + key + ")", hasPrivateKeys: isPrivate}, nil
+
+	case "multi":
+		return parseMulti(args, ctx)
+
+	case "sh":
+		if ctx != contextTop {
+			return nil, errors.New("sh() is only allowed at the top level")
+		}
+		redeem, err := parseScript(args, contextP2SH)
+		if err != nil {
+			return nil, err
+		}
+		data, err := payToScriptHash(util.Hash160(redeem.script))
+		if err != nil {
+			return nil, err
+		}
+		return &expression{script: data, desc: "sh(" + redeem.desc + ")", hasPrivateKeys: redeem.hasPrivateKeys}, nil
+
+	case "addr":
+		if ctx != contextTop {
+			return nil, errors.New("addr() is only allowed at the top level")
+		}
+		data, err := parseAddress(args)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{script: data, desc: expr}, nil
+
+	case "raw":
+		if ctx != contextTop {
+			return nil, errors.New("raw() is only allowed at the top level")
+		}
+		data, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, fmt.Errorf("raw script '%s' is not hex", args)
+		}
+		return &expression{script: data, desc: "raw(" + hex.EncodeToString(data) + ")"}, nil
+	}
+	return nil, fmt.Errorf("'%s' is not a valid descriptor function", name)
+}
+
+// parseMulti parses the bare multisig expression multi(args).
+func parseMulti(args string, ctx context) (*expression, error) {
+	fields := strings.Split(args, ",")
+	threshold, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("multi threshold '%s' is not valid", fields[0])
+	}
+	keys := fields[1:]
+	if len(keys) < 1 || len(keys) > maxMultiSigKeys {
+		return nil, fmt.Errorf("cannot have %d keys in multisig, must have between 1 and %d keys",
+			len(keys), maxMultiSigKeys)
+	}
+	if threshold < 1 || threshold > len(keys) {
+		return nil, fmt.Errorf("multisig threshold %d is not within 1 and %d", threshold, len(keys))
+	}
+
+	expr := &expression{desc: "multi(" + strconv.Itoa(threshold)}
+	sc := script.NewEmptyScript()
+	if err := sc.PushInt64(int64(threshold)); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		pubKey, pubKeyStr, isPrivate, err := parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := sc.PushSingleData(pubKey); err != nil {
+			return nil, err
+		}
+		expr.desc += "," + pubKeyStr
+		expr.hasPrivateKeys = expr.hasPrivateKeys || isPrivate
+	}
+	if err := sc.PushInt64(int64(len(keys))); err != nil {
+		return nil, err
+	}
+	if err := sc.PushOpCode(opcodes.OP_CHECKMULTISIG); err != nil {
+		return nil, err
+	}
+	expr.desc += ")"
+
+	expr.script = sc.GetData()
+	if ctx == contextP2SH && len(expr.script) > script.MaxScriptElementSize {
+		return nil, fmt.Errorf("P2SH script is too large, %d bytes is larger than %d bytes",
+			len(expr.script), script.MaxScriptElementSize)
+	}
+	return expr, nil
+}
+
+// parseKey parses a hex public key or a WIF private key. It returns the
+// serialized public key, its hex encoding and whether key is a private key.
+func parseKey(key string) ([]byte, string, bool, error) {
+	if data, err := hex.DecodeString(key); err == nil {
+		if len(data) != 33 && len(data) != 65 {
+			return nil, "", false, fmt.Errorf("pubkey '%s' is invalid", key)
+		}
+		if _, err := crypto.ParsePubKey(data); err != nil {
+			return nil, "", false, fmt.Errorf("pubkey '%s' is invalid", key)
+		}
+		return data, hex.EncodeToString(data), false, nil
+	}
+
+	w, err := wif.DecodeWIF(key)
+	if err != nil {
+		if strings.HasPrefix(key, "xpub") || strings.HasPrefix(key, "tpub") ||
+			strings.HasPrefix(key, "xprv") || strings.HasPrefix(key, "tprv") {
+			return nil, "", false, fmt.Errorf("extended key '%s' is not supported", key)
+		}
+		return nil, "", false, fmt.Errorf("key '%s' is not valid", key)
+	}
+	if !w.IsForNet(model.ActiveNetParams) {
+		return nil, "", false, fmt.Errorf("private key '%s' is not for the %s network", key, model.ActiveNetParams.Name)
+	}
+	pubKey := w.SerializePubKey()
+	return pubKey, hex.EncodeToString(pubKey), true, nil
+}
+
+// parseAddress returns the scriptPubKey paying to a legacy or cash address.
+func parseAddress(address string) ([]byte, error) {
+	if legacyAddr, err := script.AddressFromString(address); err == nil {
+		switch legacyAddr.GetVersion() {
+		case script.AddressVerPubKey():
+			return payToPubKeyHash(legacyAddr.EncodeToPubKeyHash())
+		case script.AddressVerScript():
+			return payToScriptHash(legacyAddr.EncodeToPubKeyHash())
+		}
+		return nil, fmt.Errorf("address '%s' is not for the %s network", address, model.ActiveNetParams.Name)
+	}
+
+	prefix := cashaddr.Prefixes[model.ActiveNetParams.Name]
+	if !strings.Contains(address, ":") {
+		address = prefix + ":" + address
+	}
+	hash, addrPrefix, addrType, err := cashaddr.CheckDecodeCashAddress(address)
+	if err != nil || addrPrefix != prefix {
+		return nil, fmt.Errorf("address '%s' is not valid", address)
+	}
+	if addrType == cashaddr.P2SH {
+		return payToScriptHash(hash)
+	}
+	return payToPubKeyHash(hash)
+}
+
+// payToPubKeyHash returns the P2PKH script paying to hash.
+func payToPubKeyHash(hash []byte) ([]byte, error) {
+	sc := script.NewEmptyScript()
+	if err := sc.PushOpCode(opcodes.OP_DUP); err != nil {
+		return nil, err
+	}
+	if err := sc.PushOpCode(opcodes.OP_HASH160); err != nil {
+		return nil, err
+	}
+	if err := sc.PushSingleData(hash); err != nil {
+		return nil, err
+	}
+	if err := sc.PushOpCode(opcodes.OP_EQUALVERIFY); err != nil {
+		return nil, err
+	}
+	if err := sc.PushOpCode(opcodes.OP_CHECKSIG); err != nil {
+		return nil, err
+	}
+	return sc.GetData(), nil
+}
+
+// payToScriptHash returns the P2SH script paying to hash.
+func payToScriptHash(hash []byte) ([]byte, error) {
+	sc := script.NewEmptyScript()
+	if err := sc.PushOpCode(opcodes.OP_HASH160); err != nil {
+		return nil, err
+	}
+	if err := sc.PushSingleData(hash); err != nil {
+		return nil, err
+	}
+	if err := sc.PushOpCode(opcodes.OP_EQUAL); err != nil {
+		return nil, err
+	}
+	return sc.GetData(), nil
+}
@@ -0,0 +1,177 @@
+package descriptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/copernet/copernicus/crypto"
+	"github.com/copernet/copernicus/util"
+	"github.com/copernet/copernicus/util/cashaddr"
+)
+
+const (
+	// pubKey is the compressed public key of the private key 1
+	pubKey     = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	pubKeyHash = "751e76e8199196d454941c45d1b3a323f1433bd6"
+	pubKeyWIF  = "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+	pubKeyAddr = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+
+	// pubKey2 is the compressed public key of the private key 2
+	pubKey2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+)
+
+func init() {
+	crypto.InitSecp256()
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestChecksum(t *testing.T) {
+	sum, err := Checksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "89f8spxm" {
+		t.Errorf("expected checksum 89f8spxm, got %s", sum)
+	}
+
+	if _, err := Checksum("raw(deadbeef)\n"); err == nil {
+		t.Error("expected an invalid character to be rejected")
+	}
+}
+
+func TestParse(t *testing.T) {
+	p2pkh := "76a914" + pubKeyHash + "88ac"
+	multi := "51" + "21" + pubKey + "21" + pubKey2 + "52ae"
+	p2shMulti := "a914" + hex.EncodeToString(util.Hash160(mustDecodeHex(t, multi))) + "87"
+	cashAddr := cashaddr.CheckEncodeCashAddress(mustDecodeHex(t, pubKeyHash), "bitcoincash", cashaddr.P2PKH)
+
+	tests := []struct {
+		desc   string
+		script string
+	}{
+		{"pkh(" + pubKey + ")", p2pkh},
+		{"pkh(" + pubKeyWIF + ")", p2pkh},
+		{"multi(1," + pubKey + "," + pubKey2 + ")", multi},
+		{"sh(multi(1," + pubKey + "," + pubKey2 + "))", p2shMulti},
+		{"sh(multi(1," + pubKeyWIF + "," + pubKey2 + "))", p2shMulti},
+		{"sh(pkh(" + pubKey + "))", "a914" + hex.EncodeToString(util.Hash160(mustDecodeHex(t, p2pkh))) + "87"},
+		{"addr(" + pubKeyAddr + ")", p2pkh},
+		{"addr(" + cashAddr + ")", p2pkh},
+		{"addr(" + cashAddr[len("bitcoincash:"):] + ")", p2pkh},
+		{"raw(deadbeef)#89f8spxm", "deadbeef"},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc, false)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		scripts := d.ScriptPubKeys()
+		if len(scripts) != 1 {
+			t.Errorf("%s: expected one script, got %d", test.desc, len(scripts))
+			continue
+		}
+		if got := hex.EncodeToString(scripts[0].GetData()); got != test.script {
+			t.Errorf("%s: expected script %s, got %s", test.desc, test.script, got)
+		}
+
+		// the descriptor round trips with its checksum
+		again, err := Parse(d.String(), true)
+		if err != nil {
+			t.Errorf("%s: parsing %s: %v", test.desc, d.String(), err)
+			continue
+		}
+		if !bytes.Equal(again.ScriptPubKeys()[0].GetData(), scripts[0].GetData()) {
+			t.Errorf("%s: %s stands for a different script", test.desc, d.String())
+		}
+	}
+}
+
+func TestParseChecksumMismatch(t *testing.T) {
+	if _, err := Parse("raw(deadbeef)#89f8spxn", false); err != ErrChecksumMismatch {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := Parse("raw(deadbeee)#89f8spxm", false); err != ErrChecksumMismatch {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := Parse("raw(deadbeef)#89f8sp", false); err == nil {
+		t.Error("expected a truncated checksum to be rejected")
+	}
+}
+
+func TestParseRequireChecksum(t *testing.T) {
+	if _, err := Parse("raw(deadbeef)", true); err != ErrMissingChecksum {
+		t.Errorf("expected a missing checksum, got %v", err)
+	}
+	if _, err := Parse("raw(deadbeef)#89f8spxm", true); err != nil {
+		t.Errorf("expected a checksummed descriptor to parse, got %v", err)
+	}
+}
+
+func TestDescriptorInfo(t *testing.T) {
+	tests := []struct {
+		desc      string
+		canonical string
+		solvable  bool
+		private   bool
+	}{
+		{"pkh(" + pubKey + ")", "pkh(" + pubKey + ")", true, false},
+		{"pkh(" + pubKeyWIF + ")", "pkh(" + pubKey + ")", true, true},
+		{"sh(multi(1," + pubKeyWIF + "," + pubKey2 + "))", "sh(multi(1," + pubKey + "," + pubKey2 + "))", true, true},
+		{"addr(" + pubKeyAddr + ")", "addr(" + pubKeyAddr + ")", false, false},
+		{"raw(DEADBEEF)", "raw(deadbeef)", false, false},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc, false)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		sum, _ := Checksum(test.canonical)
+		if d.String() != test.canonical+"#"+sum {
+			t.Errorf("%s: expected %s#%s, got %s", test.desc, test.canonical, sum, d.String())
+		}
+		if d.IsRange() {
+			t.Errorf("%s: expected no range", test.desc)
+		}
+		if d.IsSolvable() != test.solvable {
+			t.Errorf("%s: expected solvable %v", test.desc, test.solvable)
+		}
+		if d.HasPrivateKeys() != test.private {
+			t.Errorf("%s: expected private keys %v", test.desc, test.private)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"pkh()",
+		"pkh(" + pubKey[:64] + ")",
+		"pkh(" + pubKeyAddr + ")",
+		"pkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8)",
+		"multi(0," + pubKey + ")",
+		"multi(2," + pubKey + ")",
+		"multi(x," + pubKey + ")",
+		"multi(1)",
+		"sh(sh(pkh(" + pubKey + ")))",
+		"sh(raw(deadbeef))",
+		"sh(addr(" + pubKeyAddr + "))",
+		"addr(" + pubKeyHash + ")",
+		"raw(xyz)",
+		"wpkh(" + pubKey + ")",
+	}
+	for _, desc := range tests {
+		if _, err := Parse(desc, false); err == nil {
+			t.Errorf("expected %q to be rejected", desc)
+		}
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"math"
+
+	"github.com/copernet/copernicus/util/amount"
 )
 
 const (
@@ -46,34 +47,34 @@ type FeeRate struct {
 	SataoshisPerK int64
 }
 
-// GetFee : Return the fee in satoshis for the given size in bytes.
-func (feeRate *FeeRate) GetFee(bytes int) int64 {
-	if bytes > math.MaxInt64 {
-		panic("bytes is  greater than MaxInt64")
-	}
-	size := int64(bytes)
-	fee := feeRate.SataoshisPerK * size / 1000
-	if fee == 0 && size != 0 {
-		if feeRate.SataoshisPerK > 0 {
-			fee = 1
-		}
-		if feeRate.SataoshisPerK < 0 {
-			fee = -1
-		}
+// GetFee : Return the fee in satoshis for the given size in bytes. A fee that
+// is not a whole number of satoshis is rounded away from zero, so that a
+// positive fee rate never charges less than it says.
+func (feeRate *FeeRate) GetFee(sizeBytes int) amount.Amount {
+	fee := feeRate.SataoshisPerK * int64(sizeBytes)
+	switch {
+	case fee > 0:
+		return amount.Amount((fee + 999) / 1000)
+	case fee < 0:
+		return amount.Amount((fee - 999) / 1000)
 	}
-	return fee
+	return 0
 }
 
 // GetFeePerK : Return the fee in satoshis for a size of 1000 bytes
-func (feeRate *FeeRate) GetFeePerK() int64 {
+func (feeRate *FeeRate) GetFeePerK() amount.Amount {
 	return feeRate.GetFee(1000)
 }
 
+// String returns the fee rate in coins per kB, such as "0.00001000 BCH/kB".
 func (feeRate *FeeRate) String() string {
-	return fmt.Sprintf("%d.%08d %s/kb",
-		feeRate.SataoshisPerK/COIN,
-		feeRate.SataoshisPerK%COIN,
-		CurrencyUnit)
+	sign := ""
+	perK := feeRate.SataoshisPerK
+	if perK < 0 {
+		sign = "-"
+		perK = -perK
+	}
+	return fmt.Sprintf("%s%d.%08d %s/kB", sign, perK/COIN, perK%COIN, CurrencyUnit)
 }
 
 func (feeRate *FeeRate) SerializeSize() int {
@@ -99,17 +100,16 @@ func (feeRate *FeeRate) Less(b FeeRate) bool {
 	return feeRate.SataoshisPerK < b.SataoshisPerK
 }
 
-func NewFeeRate(amount int64) *FeeRate {
-	feeRate := &FeeRate{SataoshisPerK: amount}
-	return feeRate
+// NewFeeRate returns the fee rate of a transaction of sizeBytes bytes paying
+// feePaid.
+func NewFeeRate(feePaid amount.Amount, sizeBytes int) *FeeRate {
+	if sizeBytes > 0 {
+		return NewFeeRatePerK(feePaid * 1000 / amount.Amount(sizeBytes))
+	}
+	return NewFeeRatePerK(0)
 }
 
-func NewFeeRateWithSize(feePaid int64, bytes int64) *FeeRate {
-	if bytes > math.MaxInt64 {
-		panic("bytes is  greater than MaxInt64")
-	}
-	if bytes > 0 {
-		return NewFeeRate(feePaid * 1000 / bytes)
-	}
-	return NewFeeRate(0)
+// NewFeeRatePerK returns the fee rate of perK satoshis per 1000 bytes.
+func NewFeeRatePerK(perK amount.Amount) *FeeRate {
+	return &FeeRate{SataoshisPerK: int64(perK)}
 }
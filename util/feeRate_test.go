@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"testing"
+
+	"github.com/copernet/copernicus/util/amount"
 )
 
 func TestFeeRate(t *testing.T) {
 	amountValue := int64(1000)
-	feeR := NewFeeRate(amountValue)
+	feeR := NewFeeRatePerK(amount.Amount(amountValue))
 	if feeR.SataoshisPerK != amountValue {
 		t.Errorf("the SataoshisPerK:%d should equal amountValue:%d", feeR.SataoshisPerK, amountValue)
 	}
@@ -47,20 +49,20 @@ func TestFeeRate(t *testing.T) {
 		t.Errorf("GetFeePerK failed, value is:%d", value)
 	}
 
-	tmpFeeR := NewFeeRateWithSize(10, 1000)
+	tmpFeeR := NewFeeRate(10, 1000)
 
 	if ok := feeR.Less(*tmpFeeR); ok {
 		t.Errorf("the feeR.SataoshisPerK:%d < tmpFeeR.SataoshisPerK:%d", feeR.SataoshisPerK, tmpFeeR.SataoshisPerK)
 	}
 
-	feeRateSize := NewFeeRateWithSize(10, 0)
+	feeRateSize := NewFeeRate(10, 0)
 	if feeRateSize.SataoshisPerK != 0 {
 		t.Errorf("SataoshisPerK value:%d should equal 0", feeRateSize.SataoshisPerK)
 	}
 }
 
 func TestSerialize(t *testing.T) {
-	feeR := NewFeeRate(10)
+	feeR := NewFeeRatePerK(10)
 	buf := bytes.NewBuffer(nil)
 	err := feeR.Serialize(buf)
 	if err != nil {
@@ -75,3 +77,85 @@ func TestSerialize(t *testing.T) {
 		t.Errorf("the fee.SataoshisPerK:%d should equal 10", fee.SataoshisPerK)
 	}
 }
+
+func TestFeeRateGetFeeRounding(t *testing.T) {
+	tests := []struct {
+		perK     amount.Amount
+		bytes    int
+		expected amount.Amount
+	}{
+		{1000, 1000, 1000},
+		{1000, 999, 999},
+		{1000, 1001, 1001},
+		{1, 1000, 1},
+		{1, 999, 1},
+		{1, 1001, 2},
+		{1500, 1, 2},
+		{123, 0, 0},
+		{0, 1000, 0},
+		{-1, 1001, -2},
+		{-1000, 999, -999},
+	}
+
+	for _, test := range tests {
+		feeRate := NewFeeRatePerK(test.perK)
+		if fee := feeRate.GetFee(test.bytes); fee != test.expected {
+			t.Errorf("fee of %d bytes at %d per kB is %d, expected %d", test.bytes, test.perK, fee, test.expected)
+		}
+	}
+	if perK := NewFeeRatePerK(1234).GetFeePerK(); perK != 1234 {
+		t.Errorf("GetFeePerK returned %d, expected 1234", perK)
+	}
+}
+
+func TestFeeRateString(t *testing.T) {
+	tests := []struct {
+		perK     amount.Amount
+		expected string
+	}{
+		{0, "0.00000000 BCH/kB"},
+		{1000, "0.00001000 BCH/kB"},
+		{amount.Amount(COIN + 1), "1.00000001 BCH/kB"},
+		{-1000, "-0.00001000 BCH/kB"},
+	}
+
+	for _, test := range tests {
+		if s := NewFeeRatePerK(test.perK).String(); s != test.expected {
+			t.Errorf("fee rate %d per kB is %q, expected %q", test.perK, s, test.expected)
+		}
+	}
+}
+
+func TestNewFeeRate(t *testing.T) {
+	tests := []struct {
+		feePaid  amount.Amount
+		bytes    int
+		expected int64
+	}{
+		{1000, 1000, 1000},
+		{1000, 250, 4000},
+		{1, 1000, 1},
+		// the fee rate is rounded down, below one satoshi per 1000 bytes
+		// down to 0
+		{1, 1001, 0},
+		{999, 1001, 998},
+		{1000, 0, 0},
+	}
+
+	for _, test := range tests {
+		feeRate := NewFeeRate(test.feePaid, test.bytes)
+		if feeRate.SataoshisPerK != test.expected {
+			t.Errorf("fee rate of %d paid for %d bytes is %d per kB, expected %d",
+				test.feePaid, test.bytes, feeRate.SataoshisPerK, test.expected)
+		}
+	}
+
+	// the fee of the size paid for is the fee paid, at the 1000 byte boundary
+	feeRate := NewFeeRate(1500, 1000)
+	if fee := feeRate.GetFee(1000); fee != 1500 {
+		t.Errorf("fee of 1000 bytes is %d, expected 1500", fee)
+	}
+	if fee := feeRate.GetFee(1001); fee != 1502 {
+		t.Errorf("fee of 1001 bytes is %d, expected 1502", fee)
+	}
+}
@@ -0,0 +1,89 @@
+package gcs
+
+import (
+	"io"
+)
+
+// bitWriter appends bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	data []byte
+	// free is the number of unused low bits of the last byte.
+	free uint8
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.free == 0 {
+		w.data = append(w.data, 0)
+		w.free = 8
+	}
+	w.free--
+	if bit {
+		w.data[len(w.data)-1] |= 1 << w.free
+	}
+}
+
+// writeBits writes the n low bits of value.
+func (w *bitWriter) writeBits(value uint64, n uint8) {
+	for ; n > 0; n-- {
+		w.writeBit(value>>(n-1)&1 == 1)
+	}
+}
+
+// writeGolombRice writes value as its quotient by 2^p in unary, followed by
+// its p low bits.
+func (w *bitWriter) writeGolombRice(value uint64, p uint8) {
+	for q := value >> p; q > 0; q-- {
+		w.writeBit(true)
+	}
+	w.writeBit(false)
+	w.writeBits(value, p)
+}
+
+// bitReader reads the bits written by a bitWriter.
+type bitReader struct {
+	data []byte
+	pos  uint64
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= uint64(len(r.data))*8 {
+		return false, io.ErrUnexpectedEOF
+	}
+	bit := r.data[r.pos/8]>>(7-r.pos%8)&1 == 1
+	r.pos++
+	return bit, nil
+}
+
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	var value uint64
+	for ; n > 0; n-- {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value <<= 1
+		if bit {
+			value |= 1
+		}
+	}
+	return value, nil
+}
+
+func (r *bitReader) readGolombRice(p uint8) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		q++
+	}
+	remainder, err := r.readBits(p)
+	if err != nil {
+		return 0, err
+	}
+	return q<<p | remainder, nil
+}
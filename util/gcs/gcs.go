@@ -0,0 +1,174 @@
+// Package gcs implements the Golomb-coded sets of BIP158, a compact
+// probabilistic structure to test whether items belong to a set.
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/copernet/copernicus/util"
+)
+
+// KeySize is the size of the SipHash key the items are hashed with.
+const KeySize = 16
+
+var (
+	// ErrNTooBig is returned when a filter is built from more items than
+	// its count can hold.
+	ErrNTooBig = errors.New("gcs: too many items for a filter")
+
+	// ErrPTooBig is returned when the Golomb-Rice parameter is over 32.
+	ErrPTooBig = errors.New("gcs: P is larger than 32")
+)
+
+// Filter is a Golomb-coded set: the sorted hashes of its items, mapped to
+// [0, N*M), are stored as Golomb-Rice coded deltas with parameter P.
+type Filter struct {
+	n         uint32
+	p         uint8
+	modulusNM uint64
+	data      []byte
+}
+
+// BuildGCSFilter builds a filter of the distinct items, hashed with the key.
+// The false positive rate of a match is 1/m.
+func BuildGCSFilter(p uint8, m uint64, key [KeySize]byte, items [][]byte) (*Filter, error) {
+	if p > 32 {
+		return nil, ErrPTooBig
+	}
+
+	distinct := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		distinct[string(item)] = struct{}{}
+	}
+	if uint64(len(distinct)) > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+
+	f := &Filter{
+		n:         uint32(len(distinct)),
+		p:         p,
+		modulusNM: uint64(len(distinct)) * m,
+	}
+	values := make([]uint64, 0, len(distinct))
+	for item := range distinct {
+		values = append(values, f.hashToRange(key, []byte(item)))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var w bitWriter
+	var last uint64
+	for _, value := range values {
+		w.writeGolombRice(value-last, p)
+		last = value
+	}
+	f.data = w.data
+	return f, nil
+}
+
+// FromNBytes returns the filter serialized by NBytes.
+func FromNBytes(p uint8, m uint64, b []byte) (*Filter, error) {
+	if p > 32 {
+		return nil, ErrPTooBig
+	}
+	r := bytes.NewReader(b)
+	n, err := util.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+	return &Filter{
+		n:         uint32(n),
+		p:         p,
+		modulusNM: n * m,
+		data:      b[len(b)-r.Len():],
+	}, nil
+}
+
+// N returns the number of distinct items of the filter.
+func (f *Filter) N() uint32 {
+	return f.n
+}
+
+// NBytes returns the filter serialized as BIP158 specifies: the item count
+// as a compact size followed by the coded deltas.
+func (f *Filter) NBytes() []byte {
+	var buf bytes.Buffer
+	buf.Grow(int(util.VarIntSerializeSize(uint64(f.n))) + len(f.data))
+	util.WriteVarInt(&buf, uint64(f.n))
+	buf.Write(f.data)
+	return buf.Bytes()
+}
+
+// Hash returns the double SHA256 of the serialized filter.
+func (f *Filter) Hash() util.Hash {
+	return util.DoubleSha256Hash(f.NBytes())
+}
+
+// Match reports whether the item may belong to the filter.
+func (f *Filter) Match(key [KeySize]byte, item []byte) (bool, error) {
+	return f.MatchAny(key, [][]byte{item})
+}
+
+// MatchAny reports whether any of the items may belong to the filter.
+func (f *Filter) MatchAny(key [KeySize]byte, items [][]byte) (bool, error) {
+	if f.n == 0 || len(items) == 0 {
+		return false, nil
+	}
+
+	queries := make([]uint64, 0, len(items))
+	for _, item := range items {
+		queries = append(queries, f.hashToRange(key, item))
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i] < queries[j] })
+
+	r := bitReader{data: f.data}
+	var value uint64
+	next := 0
+	for i := uint32(0); i < f.n; i++ {
+		delta, err := r.readGolombRice(f.p)
+		if err != nil {
+			return false, err
+		}
+		value += delta
+		for queries[next] < value {
+			next++
+			if next == len(queries) {
+				return false, nil
+			}
+		}
+		if queries[next] == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hashToRange maps the SipHash of the item uniformly to [0, N*M).
+func (f *Filter) hashToRange(key [KeySize]byte, item []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+	hash := util.NewSipHasher(k0, k1).Write(item).Finalize()
+	return mulHi64(hash, f.modulusNM)
+}
+
+// mulHi64 returns the high 64 bits of the 128 bits product of a and b, summing
+// the products of their 32 bits halves.
+func mulHi64(a, b uint64) uint64 {
+	aHi, aLo := a>>32, a&math.MaxUint32
+	bHi, bLo := b>>32, b&math.MaxUint32
+
+	hi := aHi * bHi
+	mid1 := aHi * bLo
+	mid2 := aLo * bHi
+	lo := aLo * bLo
+
+	// The carry of the low 64 bits into the high ones.
+	carry := (mid1&math.MaxUint32 + mid2&math.MaxUint32 + lo>>32) >> 32
+	return hi + mid1>>32 + mid2>>32 + carry
+}
@@ -0,0 +1,107 @@
+package gcs
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// The parameters of the BIP158 basic filter.
+const (
+	testP = 19
+	testM = 784931
+)
+
+var testKey = [KeySize]byte{0x4c, 0xb1, 0xab, 0x12, 0x57, 0x62, 0x1e, 0x41,
+	0x3b, 0x8b, 0x0e, 0x26, 0x64, 0x8d, 0x4a, 0x15}
+
+func testItems(n int) [][]byte {
+	items := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, []byte{byte(i), byte(i >> 8), 0xa5})
+	}
+	return items
+}
+
+func TestGolombRiceRoundTrip(t *testing.T) {
+	values := []uint64{0, 1, 1<<testP - 1, 1 << testP, 3<<testP + 12345}
+	var w bitWriter
+	for _, value := range values {
+		w.writeGolombRice(value, testP)
+	}
+	r := bitReader{data: w.data}
+	for _, want := range values {
+		got, err := r.readGolombRice(testP)
+		if err != nil || got != want {
+			t.Fatalf("read %d, %v, want %d", got, err, want)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	items := testItems(100)
+	// duplicate items are only counted once
+	f, err := BuildGCSFilter(testP, testM, testKey, append(items, items[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.N() != uint32(len(items)) {
+		t.Fatalf("got %d items, want %d", f.N(), len(items))
+	}
+
+	decoded, err := FromNBytes(testP, testM, f.NBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.NBytes(), f.NBytes()) || decoded.Hash() != f.Hash() {
+		t.Fatal("filter changed by serialization")
+	}
+
+	for i, item := range items {
+		if match, err := decoded.Match(testKey, item); err != nil || !match {
+			t.Fatalf("item %d not matched: %v", i, err)
+		}
+	}
+	if match, err := decoded.MatchAny(testKey, [][]byte{[]byte("missing"), items[42]}); err != nil || !match {
+		t.Fatalf("items not matched: %v", err)
+	}
+	if match, err := decoded.Match(testKey, []byte("missing")); err != nil || match {
+		t.Fatalf("missing item matched: %v", err)
+	}
+	// the key is part of the hash, other keys do not match the items
+	if match, _ := decoded.MatchAny([KeySize]byte{}, items[:10]); match {
+		t.Fatal("items matched with another key")
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	f, err := BuildGCSFilter(testP, testM, testKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.NBytes(), []byte{0}) {
+		t.Fatalf("got %x, want 00", f.NBytes())
+	}
+	if match, err := f.Match(testKey, []byte{}); err != nil || match {
+		t.Fatalf("empty filter matched: %v", err)
+	}
+
+	if _, err := BuildGCSFilter(33, testM, testKey, nil); err != ErrPTooBig {
+		t.Fatalf("got %v, want %v", err, ErrPTooBig)
+	}
+}
+
+func TestMulHi64(t *testing.T) {
+	values := []uint64{0, 1, 2, math.MaxUint32, math.MaxUint32 + 1, 0x0123456789abcdef,
+		0xfedcba9876543210, math.MaxUint64 - 1, math.MaxUint64}
+	for _, a := range values {
+		for _, b := range values {
+			product := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+			want := product.Rsh(product, 64).Uint64()
+			if got := mulHi64(a, b); got != want {
+				t.Errorf("mulHi64(%#x, %#x) = %#x, want %#x", a, b, got, want)
+			}
+		}
+	}
+}
@@ -40,8 +40,7 @@ func DoubleSha256Bytes(b []byte) []byte {
 	return second[:]
 }
 func DoubleSha256Hash(b []byte) Hash {
-	first := sha256.Sum256(b)
-	return Hash(sha256.Sum256(first[:Hash256Size]))
+	return DoubleSha256(b)
 }
 
 // Hash160 calculates the hash ripemd160(sha256(b)).
@@ -145,6 +144,24 @@ func (hash *Hash) IsNull() bool {
 	return true
 }
 
+// ParseHash parses a hash from its display form, the big-endian hex string
+// String returns, into the internal little-endian byte order. Unlike
+// GetHashFromStr, the string must be exactly MaxHashStringSize hex digits.
+func ParseHash(hashStr string) (Hash, error) {
+	var hash Hash
+	if len(hashStr) != MaxHashStringSize {
+		return hash, fmt.Errorf("hash string must be of length %d (not %d, for '%s')",
+			MaxHashStringSize, len(hashStr), hashStr)
+	}
+	if _, err := hex.Decode(hash[:], []byte(hashStr)); err != nil {
+		return hash, fmt.Errorf("hash string must be hexadecimal (not '%s')", hashStr)
+	}
+	for i := 0; i < Hash256Size/2; i++ {
+		hash[i], hash[Hash256Size-1-i] = hash[Hash256Size-1-i], hash[i]
+	}
+	return hash, nil
+}
+
 func HashFromString(hexString string) *Hash {
 	hash, err := GetHashFromStr(hexString)
 	if err != nil {
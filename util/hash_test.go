@@ -125,6 +125,31 @@ func TestHashPointerToString(t *testing.T) {
 	assert.Equal(t, "hash: 00000000000743f190a18c5577a3c2d2a1f610ae9601ac046a38084ccb7cd721", s3)
 }
 
+func TestParseHash(t *testing.T) {
+	// the mainnet genesis block hash
+	genesis := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	hash, err := ParseHash(genesis)
+	if err != nil {
+		t.Fatalf("ParseHash(%s) failed: %v", genesis, err)
+	}
+	if hash[0] != 0x6f || hash[Hash256Size-1] != 0x00 {
+		t.Errorf("ParseHash(%s) is not little-endian: %x", genesis, hash[:])
+	}
+	assert.Equal(t, genesis, hash.String())
+	assert.Equal(t, *HashFromString(genesis), hash)
+
+	for _, invalid := range []string{
+		genesis[1:],
+		genesis + "0",
+		"",
+		"x" + genesis[1:],
+	} {
+		if _, err := ParseHash(invalid); err == nil {
+			t.Errorf("ParseHash(%q) succeeded", invalid)
+		}
+	}
+}
+
 func TestHash_IsNull(t *testing.T) {
 	tests := []struct {
 		hash Hash
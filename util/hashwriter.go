@@ -0,0 +1,64 @@
+package util
+
+import (
+	"crypto/sha256"
+	"hash"
+	"sync"
+)
+
+// HashWriter is an io.Writer computing the double SHA-256 of everything
+// written to it, so that objects can be hashed as they are serialized instead
+// of being serialized to a buffer first.
+type HashWriter struct {
+	hasher hash.Hash
+	// sum holds the first SHA-256, so that taking the hash does not
+	// allocate
+	sum [Hash256Size]byte
+}
+
+var hashWriterPool = sync.Pool{
+	New: func() interface{} {
+		return NewHashWriter()
+	},
+}
+
+// NewHashWriter returns a HashWriter with nothing written to it.
+func NewHashWriter() *HashWriter {
+	return &HashWriter{hasher: sha256.New()}
+}
+
+// BorrowHashWriter returns a HashWriter with nothing written to it from a pool
+// shared by the hot hashing paths. It should be given back with
+// ReturnHashWriter once the hash is taken.
+func BorrowHashWriter() *HashWriter {
+	return hashWriterPool.Get().(*HashWriter)
+}
+
+// ReturnHashWriter resets the HashWriter and puts it back in the pool.
+func ReturnHashWriter(w *HashWriter) {
+	w.Reset()
+	hashWriterPool.Put(w)
+}
+
+// Write adds p to the data being hashed. It never returns an error.
+func (w *HashWriter) Write(p []byte) (int, error) {
+	return w.hasher.Write(p)
+}
+
+// Reset discards the data written so far.
+func (w *HashWriter) Reset() {
+	w.hasher.Reset()
+}
+
+// Hash returns the double SHA-256 of the data written so far. It does not
+// change the state of the writer.
+func (w *HashWriter) Hash() Hash {
+	w.hasher.Sum(w.sum[:0])
+	return Hash(sha256.Sum256(w.sum[:]))
+}
+
+// DoubleSha256 returns the double SHA-256 of b.
+func DoubleSha256(b []byte) Hash {
+	first := sha256.Sum256(b)
+	return Hash(sha256.Sum256(first[:]))
+}
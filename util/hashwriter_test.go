@@ -0,0 +1,49 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var doubleSha256TestVec = []struct {
+	data []byte
+	hash string
+}{
+	{[]byte{}, "56944c5d3f98413ef45cf54545538103cc9f298e0575820ad3591376e2e0f65d"},
+	{[]byte("abc"), "58636c3ec08c12d55aedda056d602d5bcca72d8df6a69b519b72d32dc2428b4f"},
+	{bytes.Repeat([]byte("a"), 1000000), "88661512a78701a68778d78b5e70e50748fe1a9f74b206521b3e56779418d180"},
+}
+
+func TestDoubleSha256(t *testing.T) {
+	for _, test := range doubleSha256TestVec {
+		assert.Equal(t, test.hash, DoubleSha256(test.data).String())
+		assert.Equal(t, DoubleSha256(test.data), DoubleSha256Hash(test.data))
+	}
+}
+
+func TestHashWriter(t *testing.T) {
+	for _, test := range doubleSha256TestVec {
+		w := NewHashWriter()
+		// write in uneven chunks to hash incrementally
+		for data := test.data; len(data) > 0; {
+			n := len(data)
+			if n > 7 {
+				n = 7
+			}
+			w.Write(data[:n])
+			data = data[n:]
+		}
+		assert.Equal(t, test.hash, w.Hash().String())
+		// taking the hash leaves the data written in place
+		assert.Equal(t, test.hash, w.Hash().String())
+	}
+
+	w := BorrowHashWriter()
+	w.Write([]byte("abc"))
+	ReturnHashWriter(w)
+	w = BorrowHashWriter()
+	assert.Equal(t, doubleSha256TestVec[0].hash, w.Hash().String())
+	ReturnHashWriter(w)
+}